@@ -0,0 +1,504 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// Package crawler fetches package documentation from version control systems
+// and saves the documentation in the database. The crawler is shared by
+// gddo-server, which crawls packages on demand, and gddo-crawler, which runs
+// the background crawls separately from the web server.
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+// Span is a tracing span of an operation of the crawler.
+type Span interface {
+	SetAttr(key string, value interface{})
+	Finish(err error)
+}
+
+type noSpan struct{}
+
+func (noSpan) SetAttr(key string, value interface{}) {}
+func (noSpan) Finish(err error)                      {}
+
+// Crawler crawls packages. The fields other than DB are optional.
+type Crawler struct {
+	DB *database.Database
+
+	// MaxAge is the minimum time between crawls of a package. The default is
+	// one day.
+	MaxAge time.Duration
+
+	// Client returns the HTTP client used to fetch packages for ctx. The
+	// default is http.DefaultClient.
+	Client func(ctx context.Context) *http.Client
+
+	// Executor fetches the packages. The default is LocalExecutor.
+	Executor Executor
+
+	// Allowed returns true if the package with the import path is in the
+	// corpus. All packages are allowed if Allowed is nil.
+	Allowed func(importPath string) bool
+
+	// Skip returns true if the background crawl skips the package. Skipped
+	// packages are crawled again after MaxAge.
+	Skip func(pdoc *doc.Package) bool
+
+	// Changed is called after a package is saved or deleted.
+	Changed func(importPath string)
+
+	// MaxHostErrorRate is the rate of failed and rate limited fetches from a
+	// host in the current and the previous hour above which the background
+	// crawl of the packages on the host is slowed. Zero disables the limit.
+	MaxHostErrorRate float64
+
+	// Webhooks receives the events of the saved, hidden, deleted and
+	// quarantined packages.
+	Webhooks *Webhooks
+
+	// Logger returns the logger for ctx. The default is slog.Default.
+	Logger func(ctx context.Context) *slog.Logger
+
+	// StartSpan starts a tracing span. Client spans are calls to other
+	// services.
+	StartSpan func(ctx context.Context, name string, client bool) (context.Context, Span)
+}
+
+func (c *Crawler) maxAge() time.Duration {
+	if c.MaxAge <= 0 {
+		return 24 * time.Hour
+	}
+	return c.MaxAge
+}
+
+func (c *Crawler) client(ctx context.Context) *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client(ctx)
+}
+
+func (c *Crawler) executor() Executor {
+	if c.Executor == nil {
+		return LocalExecutor{}
+	}
+	return c.Executor
+}
+
+func (c *Crawler) allowed(importPath string) bool {
+	return c.Allowed == nil || c.Allowed(importPath)
+}
+
+func (c *Crawler) changed(importPath string) {
+	if c.Changed != nil {
+		c.Changed(importPath)
+	}
+}
+
+func (c *Crawler) logger(ctx context.Context) *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger(ctx)
+}
+
+func (c *Crawler) startSpan(ctx context.Context, name string, client bool) (context.Context, Span) {
+	if c.StartSpan == nil {
+		return ctx, noSpan{}
+	}
+	return c.StartSpan(ctx, name, client)
+}
+
+var testdataPat = regexp.MustCompile(`/testdata(?:/|$)`)
+
+// Interval returns the time until the next crawl of the package with the
+// import path and the page views in the last week. Packages with more views
+// are crawled more often, but not more often than MaxAge.
+func (c *Crawler) Interval(importPath string, hasErrors bool, views int) time.Duration {
+	maxAge := c.maxAge()
+	d := maxAge
+	switch {
+	case strings.HasPrefix(importPath, "github.com/") || hasErrors:
+		d = maxAge * 7
+	case strings.HasPrefix(importPath, "gist.github.com/"):
+		// Don't spend time on gists. It's silly thing to do.
+		return maxAge * 30
+	}
+	d = time.Duration(float64(d) / (1 + math.Log10(1+float64(views))))
+	if d < maxAge {
+		d = maxAge
+	}
+	return d
+}
+
+// Crawl fetches the package documentation from the VCS and updates the
+// database. The result of the crawl is logged with the logger for ctx.
+func (c *Crawler) Crawl(ctx context.Context, source string, importPath string, pdoc *doc.Package, hasSubdirs bool, nextCrawl time.Time) (*doc.Package, error) {
+	db := c.DB
+	lg := c.logger(ctx)
+	level := slog.LevelInfo
+	message := []interface{}{"source", strings.TrimSpace(source), "path", importPath}
+	ctx, sp := c.startSpan(ctx, "crawl", false)
+	sp.SetAttr("path", importPath)
+	var spanErr error
+	defer func() {
+		lg.Log(ctx, level, "crawl", message...)
+		sp.Finish(spanErr)
+	}()
+
+	if !nextCrawl.IsZero() {
+		d := time.Since(nextCrawl) / time.Hour
+		if d > 0 {
+			message = append(message, "late_hours", int64(d))
+		}
+	}
+
+	etag := ""
+	if pdoc != nil {
+		etag = pdoc.Etag
+		message = append(message, "etag", etag)
+	}
+
+	start := time.Now()
+	var err error
+	fetched := false
+	if strings.HasPrefix(importPath, "code.google.com/p/go.") {
+		// Old import path for Go sub-repository.
+		pdoc = nil
+		err = gosrc.NotFoundError{Message: "old Go sub-repo", Redirect: "golang.org/x/" + importPath[len("code.google.com/p/go."):]}
+	} else if !c.allowed(importPath) {
+		pdoc = nil
+		err = gosrc.NotFoundError{Message: "not in allow list."}
+	} else if blocked, e := db.IsBlocked(importPath); blocked && e == nil {
+		pdoc = nil
+		err = gosrc.NotFoundError{Message: "blocked."}
+	} else if quarantined, e := db.IsQuarantined(importPath); quarantined && e == nil {
+		pdoc = nil
+		err = gosrc.NotFoundError{Message: "quarantined."}
+	} else if testdataPat.MatchString(importPath) {
+		pdoc = nil
+		err = gosrc.NotFoundError{Message: "testdata."}
+	} else {
+		var pdocNew *doc.Package
+		pdocNew, err = c.executor().Fetch(ctx, c.client(ctx), importPath, etag)
+		fetched = true
+		latency := time.Since(start)
+		message = append(message, "fetch_ms", int64(latency/time.Millisecond))
+		if err := db.RecordFetch(FetchHost(importPath), fetchResult(err), latency); err != nil {
+			lg.Error("db.RecordFetch", "path", importPath, "err", err)
+		}
+		if err == nil && pdocNew.Name == "" && !hasSubdirs {
+			if len(pdocNew.Errors) > 0 {
+				message = append(message, "doc_errors", pdocNew.Errors)
+			}
+			pdoc = nil
+			err = gosrc.NotFoundError{Message: "no Go files or subdirs"}
+		} else if err != gosrc.ErrNotModified {
+			pdoc = pdocNew
+		}
+	}
+
+	views, e := db.RecentViews(importPath, 7)
+	if e != nil {
+		lg.Error("db.RecentViews", "path", importPath, "err", e)
+	}
+	nextCrawl = start.Add(c.Interval(importPath, pdoc != nil && len(pdoc.Errors) > 0, views))
+
+	switch {
+	case err == nil:
+		if (len(pdoc.Licenses) == 0 || pdoc.Module == nil) && pdoc.ProjectRoot != "" && pdoc.ImportPath != pdoc.ProjectRoot {
+			if root, _, err := db.GetDoc(pdoc.ProjectRoot); err != nil {
+				lg.Error("db.GetDoc", "path", pdoc.ProjectRoot, "err", err)
+			} else if root != nil {
+				if len(pdoc.Licenses) == 0 {
+					pdoc.Licenses = root.Licenses
+				}
+				if pdoc.Module == nil {
+					pdoc.Module = root.Module
+				}
+			}
+		}
+		hide := pdoc.OptOut
+		if pdoc.ProjectRoot != "" && pdoc.ImportPath == pdoc.ProjectRoot {
+			if err := db.SetOptOut(pdoc.ProjectRoot, pdoc.OptOut); err != nil {
+				lg.Error("db.SetOptOut", "path", importPath, "err", err)
+			}
+		} else if !hide {
+			hide, _ = db.IsOptedOut(importPath)
+		}
+		if !hide && pdoc.ProjectRoot != "" {
+			if s, err := db.ProjectSettings(pdoc.ProjectRoot); err != nil {
+				lg.Error("db.ProjectSettings", "path", importPath, "err", err)
+			} else {
+				hide = s.Hidden
+			}
+		}
+		if pdoc.Canonical != "" || strings.Contains(importPath, "/vendor/") {
+			// Copies of packages are shown only at the canonical path.
+			// Vendored packages are copies too.
+			hide = true
+		}
+		if hide {
+			message = append(message, "optout", true)
+		}
+		message = append(message, "put", pdoc.Etag)
+		_, ps := c.startSpan(ctx, "db.Put", true)
+		ps.SetAttr("db.system", "redis")
+		err := db.Put(pdoc, nextCrawl, hide)
+		ps.Finish(err)
+		if err != nil {
+			if err == database.ErrWritesPaused {
+				// The package is crawled again after the backup.
+				lg.Info("db.Put", "path", importPath, "err", err)
+			} else {
+				lg.Error("db.Put", "path", importPath, "err", err)
+			}
+			// The package is not saved: the webhooks are not sent and the
+			// owner and the moves are not changed.
+			return pdoc, nil
+		}
+		if pdoc.Module != nil && pdoc.Module.Root != "" {
+			if err := db.UpdateDependencyReport(pdoc.Module, pdoc); err != nil {
+				lg.Error("db.UpdateDependencyReport", "path", importPath, "err", err)
+			}
+		}
+		c.changed(importPath)
+		kind := EventCrawled
+		if hide {
+			kind = EventHidden
+		}
+		c.Webhooks.Send(Event{Kind: kind, Path: importPath, Version: pdoc.Version, Etag: pdoc.Etag})
+		if pdoc.ProjectRoot != "" {
+			// The repository found again at the path did not move.
+			if err := db.ForgetMove(pdoc.ProjectRoot); err != nil {
+				lg.Error("db.ForgetMove", "path", importPath, "err", err)
+			}
+		}
+		if pdoc.ProjectRoot != "" && pdoc.ImportPath == pdoc.ProjectRoot {
+			if err := db.SetOwner(pdoc.ProjectRoot, pdoc.OwnerKeyHash); err != nil {
+				lg.Error("db.SetOwner", "path", importPath, "err", err)
+			}
+		}
+		return pdoc, nil
+	case err == gosrc.ErrNotModified:
+		message = append(message, "touch", true)
+		if err := db.SetNextCrawlEtag(pdoc.ProjectRoot, pdoc.Etag, nextCrawl); err != nil {
+			lg.Error("db.SetNextCrawlEtag", "path", importPath, "err", err)
+		}
+		return pdoc, nil
+	case gosrc.IsQuarantined(err):
+		message = append(message, "quarantine", err)
+		if err := db.Quarantine(err.(gosrc.QuarantineError).ProjectRoot, err.Error()); err != nil {
+			lg.Error("db.Quarantine", "path", importPath, "err", err)
+		}
+		c.Webhooks.Send(Event{Kind: EventQuarantined, Path: importPath, Message: err.Error()})
+		return nil, gosrc.NotFoundError{Message: "quarantined."}
+	case gosrc.IsNotFound(err):
+		message = append(message, "notfound", err)
+		if err := db.Delete(importPath); err != nil {
+			lg.Error("db.Delete", "path", importPath, "err", err)
+		}
+		if fetched {
+			if err := db.PutNotFound(importPath, err.(gosrc.NotFoundError)); err != nil {
+				lg.Error("db.PutNotFound", "path", importPath, "err", err)
+			}
+		}
+		if e := err.(gosrc.NotFoundError); e.Moved && e.MovedTo != "" {
+			message = append(message, "moved", e.MovedTo)
+			if err := db.RecordMove(e.MovedFrom, e.MovedTo); err != nil {
+				lg.Error("db.RecordMove", "path", importPath, "err", err)
+			}
+		}
+		c.changed(importPath)
+		c.Webhooks.Send(Event{Kind: EventNotFound, Path: importPath, Message: err.Error()})
+		return nil, err
+	default:
+		level = slog.LevelError
+		spanErr = err
+		message = append(message, "err", err)
+		return nil, err
+	}
+}
+
+// FetchHost returns the host in the fetch statistics of the package with the
+// import path. The host of the standard library is "std".
+func FetchHost(importPath string) string {
+	host := importPath
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	if !strings.Contains(host, ".") {
+		return "std"
+	}
+	return host
+}
+
+func fetchResult(err error) string {
+	switch {
+	case err == nil || err == gosrc.ErrNotModified || gosrc.IsNotFound(err):
+		return database.FetchOK
+	case gosrc.IsRateLimited(err):
+		return database.FetchRateLimited
+	default:
+		return database.FetchFailed
+	}
+}
+
+const (
+	// hostBudgetMinFetches is the number of fetches from a host required to
+	// check the error rate of the host.
+	hostBudgetMinFetches = 20
+
+	// hostBudgetDelay is the delay of the background crawl of a package on a
+	// host over the error budget.
+	hostBudgetDelay = time.Hour
+)
+
+// overErrorBudget returns true if the rate of failed fetches from the host in
+// the current and the previous hour is above MaxHostErrorRate.
+func (c *Crawler) overErrorBudget(ctx context.Context, host string) bool {
+	if c.MaxHostErrorRate <= 0 {
+		return false
+	}
+	s, err := c.DB.FetchStats(host, time.Now().Add(-time.Hour))
+	if err != nil {
+		c.logger(ctx).Error("db.FetchStats", "host", host, "err", err)
+		return false
+	}
+	return s.Fetches >= hostBudgetMinFetches && s.ErrorRate() > c.MaxHostErrorRate
+}
+
+// CrawlNext crawls a new package added to the database by a crawl of an
+// importer or the existing package with the earliest next crawl time if the
+// time has passed.
+func (c *Crawler) CrawlNext(ctx context.Context) error {
+	db := c.DB
+	lg := c.logger(ctx)
+
+	// Look for new package to crawl.
+	importPath, hasSubdirs, err := db.PopNewCrawl()
+	if err != nil {
+		lg.Error("db.PopNewCrawl", "err", err)
+		return nil
+	}
+	if importPath != "" {
+		if pdoc, err := c.Crawl(ctx, "new", importPath, nil, hasSubdirs, time.Time{}); pdoc == nil && err == nil {
+			if err := db.AddBadCrawl(importPath); err != nil {
+				lg.Error("db.AddBadCrawl", "path", importPath, "err", err)
+			}
+		}
+		return nil
+	}
+
+	// Crawl existing doc.
+	pdoc, pkgs, nextCrawl, err := db.Get("-")
+	if err != nil {
+		lg.Error("db.Get", "path", "-", "err", err)
+		return nil
+	}
+	if pdoc == nil || nextCrawl.After(time.Now()) {
+		return nil
+	}
+	if c.overErrorBudget(ctx, FetchHost(pdoc.ImportPath)) {
+		// Slow the crawl of the host so that the crawl advances to the
+		// packages on other hosts.
+		if err := db.SetNextCrawlEtag(pdoc.ProjectRoot, pdoc.Etag, time.Now().Add(hostBudgetDelay)); err != nil {
+			lg.Error("db.SetNextCrawlEtag", "path", pdoc.ImportPath, "err", err)
+		}
+		return nil
+	}
+	if c.Skip != nil && c.Skip(pdoc) {
+		// Touch the package so that the crawl advances to the next package.
+		if err := db.SetNextCrawlEtag(pdoc.ProjectRoot, pdoc.Etag, time.Now().Add(c.maxAge())); err != nil {
+			lg.Error("db.SetNextCrawlEtag", "path", pdoc.ImportPath, "err", err)
+		}
+		return nil
+	}
+	if _, err = c.Crawl(ctx, "crawl", pdoc.ImportPath, pdoc, len(pkgs) > 0, nextCrawl); err != nil {
+		// Touch package so that crawl advances to next package.
+		if err := db.SetNextCrawlEtag(pdoc.ProjectRoot, pdoc.Etag, time.Now().Add(c.maxAge()/3)); err != nil {
+			lg.Error("db.SetNextCrawlEtag", "path", pdoc.ImportPath, "err", err)
+		}
+	}
+	return nil
+}
+
+// ReadGitHubUpdates bumps the crawl of the GitHub repositories pushed since
+// the last call.
+func (c *Crawler) ReadGitHubUpdates(ctx context.Context) error {
+	const key = "gitHubUpdates"
+	db := c.DB
+	lg := c.logger(ctx)
+	var last string
+	if err := db.GetGob(key, &last); err != nil {
+		return err
+	}
+	last, names, err := gosrc.GetGitHubUpdates(c.client(ctx), last)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		lg.Info("bump crawl", "path", "github.com/"+name)
+		if err := db.BumpCrawl("github.com/" + name); err != nil {
+			lg.Error("db.BumpCrawl", "path", "github.com/"+name, "err", err)
+		}
+	}
+
+	return db.PutGob(key, last)
+}
+
+// ReadModuleIndex bumps the crawl of the modules with versions published to
+// the module index at indexURL since the last call and queues the new
+// modules for the first crawl.
+func (c *Crawler) ReadModuleIndex(ctx context.Context, indexURL string) error {
+	const key = "moduleIndex"
+	db := c.DB
+	lg := c.logger(ctx)
+	var last string
+	if err := db.GetGob(key, &last); err != nil {
+		return err
+	}
+	last, modules, err := gosrc.GetModuleIndexUpdates(c.client(ctx), indexURL, last)
+	if err != nil {
+		return err
+	}
+
+	for _, module := range modules {
+		if !c.allowed(module) {
+			continue
+		}
+		ok, err := db.SetNextCrawl(module, time.Now())
+		if err != nil {
+			lg.Error("db.SetNextCrawl", "path", module, "err", err)
+			continue
+		}
+		if !ok {
+			lg.Info("new module", "path", module)
+			if err := db.AddNewCrawl(module); err != nil {
+				lg.Error("db.AddNewCrawl", "path", module, "err", err)
+			}
+			continue
+		}
+		lg.Info("bump crawl", "path", module)
+		if err := db.BumpCrawl(module); err != nil {
+			lg.Error("db.BumpCrawl", "path", module, "err", err)
+		}
+	}
+
+	return db.PutGob(key, last)
+}
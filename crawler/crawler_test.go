@@ -0,0 +1,49 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+var intervalTests = []struct {
+	importPath string
+	hasErrors  bool
+	views      int
+	interval   time.Duration
+}{
+	{"example.com/p", false, 0, 24 * time.Hour},
+	{"example.com/p", false, 1000, 24 * time.Hour},
+	{"github.com/user/repo", false, 0, 7 * 24 * time.Hour},
+	{"github.com/user/repo", false, 9, 7 * 24 * time.Hour / 2},
+	{"github.com/user/repo", false, 999999, 24 * time.Hour},
+	{"example.com/p", true, 0, 7 * 24 * time.Hour},
+	{"gist.github.com/123", false, 1000, 30 * 24 * time.Hour},
+}
+
+func TestInterval(t *testing.T) {
+	c := &Crawler{MaxAge: 24 * time.Hour}
+	for _, tt := range intervalTests {
+		if d := c.Interval(tt.importPath, tt.hasErrors, tt.views); d != tt.interval {
+			t.Errorf("Interval(%q, %v, %d) = %v, want %v", tt.importPath, tt.hasErrors, tt.views, d, tt.interval)
+		}
+	}
+}
+
+func TestFetchHost(t *testing.T) {
+	for path, want := range map[string]string{
+		"github.com/user/repo": "github.com",
+		"example.com":          "example.com",
+		"net/http":             "std",
+		"fmt":                  "std",
+	} {
+		if got := FetchHost(path); got != want {
+			t.Errorf("FetchHost(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
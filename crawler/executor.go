@@ -0,0 +1,277 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+// An Executor fetches the files of a package and parses the documentation.
+// Fetching runs the version control commands and the parser on the files of
+// untrusted repositories; the ProcessExecutor runs them in a separate
+// process with resource limits, so a pathological repository or a crash of
+// the parser does not take down the process serving the pages.
+type Executor interface {
+	// Fetch returns the documentation of the package with the import path
+	// or gosrc.ErrNotModified if the package matches the etag. The client
+	// is the client of the Crawler for the crawl.
+	Fetch(ctx context.Context, client *http.Client, importPath, etag string) (*doc.Package, error)
+}
+
+// LocalExecutor fetches packages in the calling process. It is the default
+// Executor of a Crawler.
+type LocalExecutor struct{}
+
+// Fetch implements the Executor interface.
+func (LocalExecutor) Fetch(ctx context.Context, client *http.Client, importPath, etag string) (*doc.Package, error) {
+	return doc.Get(client, importPath, etag)
+}
+
+// Limits are the resource limits of a fetch worker process. Zero values do
+// not limit the resource.
+type Limits struct {
+	// CPUTime is the CPU time of the process, including the version control
+	// commands started by the process.
+	CPUTime time.Duration
+
+	// Memory is the size in bytes of the address space of the process and
+	// of each command started by the process.
+	Memory int64
+
+	// Timeout is the wall time of the fetch. The process is killed after
+	// the timeout.
+	Timeout time.Duration
+}
+
+// ProcessExecutor fetches each package in a new worker process. The worker
+// is the command serving the fetches with ServeFetchWorker on its standard
+// input and output, such as gddo-crawler -fetch_worker. The command can be
+// run by a sandbox, such as nsjail, bwrap or a container runtime, to
+// restrict the system calls and the files of the worker. The limits are sent
+// to the worker with the request and applied by the worker before the fetch.
+type ProcessExecutor struct {
+	// Command is the command line of the worker.
+	Command []string
+
+	Limits Limits
+
+	// MaxProcesses is the maximum number of concurrent worker processes.
+	// Zero does not limit the number of processes.
+	MaxProcesses int
+
+	sem chan struct{}
+}
+
+// NewProcessExecutor returns an executor running the worker command with the
+// limits and at most maxProcesses concurrent processes.
+func NewProcessExecutor(command []string, limits Limits, maxProcesses int) *ProcessExecutor {
+	e := &ProcessExecutor{Command: command, Limits: limits, MaxProcesses: maxProcesses}
+	if maxProcesses > 0 {
+		e.sem = make(chan struct{}, maxProcesses)
+	}
+	return e
+}
+
+// FetchArgs is the request of a fetch sent to a worker.
+type FetchArgs struct {
+	ImportPath string
+	Etag       string
+	Limits     Limits
+}
+
+// FetchReply is the reply of a worker to a fetch. The errors of the fetch
+// are returned in Error instead of the error of the call, which does not
+// keep the type of the error.
+type FetchReply struct {
+	Doc   *doc.Package
+	Error *FetchError
+}
+
+// FetchError is an error of a fetch encoded for the reply of a worker.
+type FetchError struct {
+	// Kind is notfound, notmodified, quarantine, remote or empty for other
+	// errors.
+	Kind        string
+	Message     string
+	Redirect    string
+	Moved       bool
+	MovedFrom   string
+	MovedTo     string
+	ProjectRoot string
+	Host        string
+}
+
+// newFetchError returns the encoded err or nil if err is nil.
+func newFetchError(err error) *FetchError {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case gosrc.NotFoundError:
+		return &FetchError{Kind: "notfound", Message: e.Message, Redirect: e.Redirect, Moved: e.Moved, MovedFrom: e.MovedFrom, MovedTo: e.MovedTo}
+	case gosrc.QuarantineError:
+		return &FetchError{Kind: "quarantine", Message: e.Message, ProjectRoot: e.ProjectRoot}
+	case *gosrc.RemoteError:
+		return &FetchError{Kind: "remote", Message: e.Error(), Host: e.Host}
+	}
+	if err == gosrc.ErrNotModified {
+		return &FetchError{Kind: "notmodified"}
+	}
+	return &FetchError{Message: err.Error()}
+}
+
+// err returns the error decoded from e.
+func (e *FetchError) err() error {
+	switch e.Kind {
+	case "notfound":
+		return gosrc.NotFoundError{Message: e.Message, Redirect: e.Redirect, Moved: e.Moved, MovedFrom: e.MovedFrom, MovedTo: e.MovedTo}
+	case "notmodified":
+		return gosrc.ErrNotModified
+	case "quarantine":
+		return gosrc.QuarantineError{ProjectRoot: e.ProjectRoot, Message: e.Message}
+	case "remote":
+		return gosrc.NewRemoteError(e.Host, errors.New(e.Message))
+	}
+	return errors.New(e.Message)
+}
+
+// stdioConn is the connection to a worker over its standard input and
+// output.
+type stdioConn struct {
+	io.ReadCloser
+	w io.WriteCloser
+}
+
+func (c stdioConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c stdioConn) Close() error {
+	err := c.w.Close()
+	if rerr := c.ReadCloser.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Fetch implements the Executor interface. The client is not used; the
+// worker fetches with the client configured by its command line.
+func (e *ProcessExecutor) Fetch(ctx context.Context, client *http.Client, importPath, etag string) (*doc.Package, error) {
+	if len(e.Command) == 0 {
+		return nil, errors.New("fetch worker: no command")
+	}
+	if e.sem != nil {
+		select {
+		case e.sem <- struct{}{}:
+			defer func() { <-e.sem }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if e.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Limits.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("fetch worker: %v", err)
+	}
+	c := rpc.NewClient(stdioConn{r, w})
+	var reply FetchReply
+	callErr := c.Call("Worker.Fetch", &FetchArgs{ImportPath: importPath, Etag: etag, Limits: e.Limits}, &reply)
+	c.Close()
+	waitErr := cmd.Wait()
+	if callErr != nil {
+		// The worker crashed, exceeded a limit or was killed after the
+		// timeout.
+		msg := callErr.Error()
+		if waitErr != nil {
+			msg = waitErr.Error()
+		}
+		if ctx.Err() != nil {
+			msg = ctx.Err().Error()
+		}
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			if i := strings.LastIndex(s, "\n"); i >= 0 {
+				s = s[i+1:]
+			}
+			msg += ": " + s
+		}
+		return nil, fmt.Errorf("fetch worker: %s", msg)
+	}
+	if reply.Error != nil {
+		return reply.Doc, reply.Error.err()
+	}
+	return reply.Doc, nil
+}
+
+// Worker is the RPC service of a fetch worker.
+type Worker struct {
+	client *http.Client
+}
+
+// Fetch applies the limits and fetches the package.
+func (w *Worker) Fetch(args *FetchArgs, reply *FetchReply) error {
+	if err := setLimits(args.Limits); err != nil {
+		return err
+	}
+	pdoc, err := doc.Get(w.client, args.ImportPath, args.Etag)
+	reply.Doc = pdoc
+	reply.Error = newFetchError(err)
+	return nil
+}
+
+// ServeFetchWorker serves the fetches of a ProcessExecutor reading the
+// requests from r and writing the replies to w, usually the standard input
+// and output of the process, until r is closed. The worker fetches with the
+// client and the gosrc and doc settings of the process. The limits of a
+// fetch apply to the process, so the process serves one ProcessExecutor.
+func ServeFetchWorker(r io.ReadCloser, w io.WriteCloser, client *http.Client) error {
+	s := rpc.NewServer()
+	if err := s.Register(&Worker{client: client}); err != nil {
+		return err
+	}
+	s.ServeConn(stdioConn{r, w})
+	return nil
+}
+
+// WorkerCommand returns the command line running the executable of the
+// process as a fetch worker with the worker flag and the arguments of the
+// process, after the command line of the sandbox.
+func WorkerCommand(sandbox []string, workerFlag string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	command := append([]string(nil), sandbox...)
+	command = append(command, exe, workerFlag)
+	return append(command, os.Args[1:]...), nil
+}
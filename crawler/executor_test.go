@@ -0,0 +1,90 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+func TestFetchErrorRoundTrip(t *testing.T) {
+	for _, err := range []error{
+		gosrc.NotFoundError{Message: "moved", Redirect: "github.com/new/repo/sub", Moved: true, MovedFrom: "github.com/old/repo", MovedTo: "github.com/new/repo"},
+		gosrc.QuarantineError{ProjectRoot: "github.com/bad/repo", Message: "malware"},
+		gosrc.ErrNotModified,
+	} {
+		if got := newFetchError(err).err(); !reflect.DeepEqual(got, err) {
+			t.Errorf("round trip of %#v = %#v", err, got)
+		}
+	}
+	rateLimited := gosrc.NewRemoteError("api.github.com", errors.New("429: slow down"))
+	if got := newFetchError(rateLimited).err(); !gosrc.IsRateLimited(got) || got.(*gosrc.RemoteError).Host != "api.github.com" {
+		t.Errorf("round trip of rate limited error = %#v", got)
+	}
+	if got := newFetchError(errors.New("boom")).err(); got.Error() != "boom" {
+		t.Errorf("round trip of other error = %v", got)
+	}
+	if newFetchError(nil) != nil {
+		t.Error("newFetchError(nil) != nil")
+	}
+}
+
+// TestHelperFetchWorker is the worker process of TestProcessExecutor.
+func TestHelperFetchWorker(t *testing.T) {
+	switch os.Getenv("GDDO_TEST_FETCH_WORKER") {
+	case "":
+		return
+	case "crash":
+		os.Stderr.WriteString("parser crashed\n")
+		os.Exit(2)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	ServeFetchWorker(os.Stdin, os.Stdout, http.DefaultClient)
+	os.Exit(0)
+}
+
+func TestWorkerCommand(t *testing.T) {
+	command, err := WorkerCommand([]string{"nsjail", "--"}, "-fetch_worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(command) != 4+len(os.Args)-1 || command[0] != "nsjail" || command[3] != "-fetch_worker" {
+		t.Errorf("WorkerCommand() = %q", command)
+	}
+}
+
+func TestProcessExecutor(t *testing.T) {
+	worker := func(mode string) []string {
+		return []string{"env", "GDDO_TEST_FETCH_WORKER=" + mode, os.Args[0], "-test.run=^TestHelperFetchWorker$"}
+	}
+	ctx := context.Background()
+
+	// The import path without a host is not found without a request.
+	e := NewProcessExecutor(worker("serve"), Limits{Timeout: time.Minute}, 1)
+	if _, err := e.Fetch(ctx, nil, "notahost/pkg", ""); !gosrc.IsNotFound(err) {
+		t.Errorf("Fetch() returned %v, want not found error", err)
+	}
+
+	e = NewProcessExecutor(worker("crash"), Limits{}, 0)
+	if _, err := e.Fetch(ctx, nil, "example.com/pkg", ""); err == nil || !strings.Contains(err.Error(), "parser crashed") {
+		t.Errorf("Fetch() from crashed worker returned %v, want error with the output of the worker", err)
+	}
+
+	e = NewProcessExecutor(worker("hang"), Limits{Timeout: 100 * time.Millisecond}, 0)
+	if _, err := e.Fetch(ctx, nil, "example.com/pkg", ""); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("Fetch() from hung worker returned %v, want timeout", err)
+	}
+}
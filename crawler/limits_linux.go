@@ -0,0 +1,30 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package crawler
+
+import (
+	"syscall"
+	"time"
+)
+
+// setLimits sets the resource limits of the process. The limits are
+// inherited by the commands started by the process.
+func setLimits(l Limits) error {
+	if l.CPUTime > 0 {
+		s := uint64((l.CPUTime + time.Second - 1) / time.Second)
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: s, Max: s}); err != nil {
+			return err
+		}
+	}
+	if l.Memory > 0 {
+		n := uint64(l.Memory)
+		if err := syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: n, Max: n}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
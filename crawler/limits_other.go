@@ -0,0 +1,21 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+//go:build !linux
+// +build !linux
+
+package crawler
+
+import "errors"
+
+// setLimits returns an error if l limits the CPU time or the memory; the
+// limits are supported on Linux only.
+func setLimits(l Limits) error {
+	if l.CPUTime > 0 || l.Memory > 0 {
+		return errors.New("fetch worker: resource limits are not supported on this system")
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package crawler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of webhook events.
+const (
+	EventCrawled     = "crawled"     // The package was saved by a crawl.
+	EventHidden      = "hidden"      // The package was saved and hidden from search.
+	EventNotFound    = "notfound"    // The package was not found and deleted.
+	EventQuarantined = "quarantined" // The project was quarantined by the scanner.
+	EventBlocked     = "blocked"     // The packages matching the path were blocked.
+)
+
+// Event is a webhook event of a package. Path is a blocklist pattern for the
+// blocked events.
+type Event struct {
+	Kind    string    `json:"kind"`
+	Path    string    `json:"path"`
+	Version string    `json:"version,omitempty"`
+	Etag    string    `json:"etag,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Webhook signature headers. The signature is the hex encoded HMAC-SHA256 of
+// the timestamp, a period and the body with the secret, prefixed with
+// "sha256=". The timestamp is the time of the delivery in Unix seconds.
+const (
+	WebhookSignatureHeader = "X-Gddo-Signature"
+	WebhookTimestampHeader = "X-Gddo-Timestamp"
+	WebhookEventHeader     = "X-Gddo-Event"
+)
+
+// webhookQueueSize is the number of events waiting for the delivery. Events
+// are dropped when the queue is full.
+const webhookQueueSize = 1000
+
+// webhookAttempts is the number of deliveries of an event to a URL before the
+// event is dropped.
+const webhookAttempts = 3
+
+// Webhooks posts the events as signed JSON to the configured URLs. The
+// events are delivered in order by one goroutine. The zero value posts
+// nothing until the URLs are set.
+type Webhooks struct {
+	// Client is the HTTP client of the deliveries. The default is
+	// http.DefaultClient. Client must not change after the first call to
+	// SetTargets.
+	Client *http.Client
+
+	mu     sync.Mutex
+	urls   []string
+	secret []byte
+	queue  chan Event
+}
+
+// SetTargets sets the URLs and the secret of the signatures. Empty URLs
+// disable the webhooks.
+func (w *Webhooks) SetTargets(urls []string, secret string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.urls = nil
+	for _, u := range urls {
+		if u != "" {
+			w.urls = append(w.urls, u)
+		}
+	}
+	w.secret = []byte(secret)
+	if len(w.urls) > 0 && w.queue == nil {
+		w.queue = make(chan Event, webhookQueueSize)
+		go w.deliver()
+	}
+}
+
+// Send queues the event for the delivery. The time of the event is set if
+// zero.
+func (w *Webhooks) Send(e Event) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	enabled := len(w.urls) > 0
+	w.mu.Unlock()
+	if !enabled {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	select {
+	case w.queue <- e:
+	default:
+		slog.Error("webhook queue full", "kind", e.Kind, "path", e.Path)
+	}
+}
+
+func (w *Webhooks) deliver() {
+	for e := range w.queue {
+		body, err := json.Marshal(&e)
+		if err != nil {
+			slog.Error("webhook", "err", err)
+			continue
+		}
+		w.mu.Lock()
+		urls, secret := w.urls, w.secret
+		w.mu.Unlock()
+		for _, u := range urls {
+			for i := 0; i < webhookAttempts; i++ {
+				if i > 0 {
+					time.Sleep(time.Duration(i) * time.Second)
+				}
+				if err = w.post(u, e.Kind, body, secret, time.Now()); err == nil {
+					break
+				}
+			}
+			if err != nil {
+				slog.Error("webhook", "url", u, "kind", e.Kind, "path", e.Path, "err", err)
+			}
+		}
+	}
+}
+
+// WebhookSignature returns the signature of the body posted at the time.
+func WebhookSignature(secret []byte, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(timestamp))
+	m.Write([]byte("."))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+func (w *Webhooks) post(u, kind string, body, secret []byte, now time.Time) error {
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, kind)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if len(secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, WebhookSignature(secret, timestamp, body))
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package crawler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	type delivery struct {
+		event     Event
+		kind      string
+		signature string
+	}
+	deliveries := make(chan delivery, 10)
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var d delivery
+		if err := json.Unmarshal(body, &d.event); err != nil {
+			t.Error(err)
+		}
+		d.kind = r.Header.Get(WebhookEventHeader)
+		if r.Header.Get(WebhookSignatureHeader) == WebhookSignature([]byte("secret"), r.Header.Get(WebhookTimestampHeader), body) {
+			d.signature = "ok"
+		}
+		deliveries <- d
+	}))
+	defer srv.Close()
+
+	var w *Webhooks
+	w.Send(Event{Kind: EventCrawled, Path: "example.com/nil"})
+	w = &Webhooks{}
+	w.Send(Event{Kind: EventCrawled, Path: "example.com/disabled"})
+	w.SetTargets([]string{"", srv.URL}, "secret")
+	w.Send(Event{Kind: EventCrawled, Path: "example.com/p", Etag: "e"})
+	w.Send(Event{Kind: EventNotFound, Path: "example.com/q"})
+
+	for _, want := range []Event{
+		{Kind: EventCrawled, Path: "example.com/p", Etag: "e"},
+		{Kind: EventNotFound, Path: "example.com/q"},
+	} {
+		select {
+		case d := <-deliveries:
+			if d.event.Kind != want.Kind || d.event.Path != want.Path || d.event.Etag != want.Etag || d.event.Time.IsZero() {
+				t.Errorf("event = %+v, want %+v", d.event, want)
+			}
+			if d.kind != want.Kind || d.signature != "ok" {
+				t.Errorf("%s: event header %q, signature %s", want.Path, d.kind, d.signature)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s not delivered", want.Path)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// maxAbuseMessages is the number of messages kept with an abuse report.
+const maxAbuseMessages = 20
+
+// AbuseReasons are the reasons for reporting a package.
+var AbuseReasons = []string{"spam", "malware", "dmca", "other"}
+
+// AbuseMessage is the message of one reporter of a package.
+type AbuseMessage struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Contact string    `json:"contact,omitempty"`
+}
+
+// AbuseReport is a report of a package in the moderation queue. The reports
+// of a package for the same reason are collapsed into one report.
+type AbuseReport struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+
+	// Count is the number of times the package was reported for the
+	// reason.
+	Count int `json:"count"`
+
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+
+	// Messages of the last reporters, newest first.
+	Messages []AbuseMessage `json:"messages"`
+}
+
+// abuseReport is the JSON encoding of an AbuseReport in the database. The
+// times are Unix times.
+type abuseReport struct {
+	Path     string `json:"path"`
+	Reason   string `json:"reason"`
+	Count    int    `json:"count"`
+	First    int64  `json:"first"`
+	Last     int64  `json:"last"`
+	Messages []struct {
+		Time    int64  `json:"time"`
+		Message string `json:"message"`
+		Contact string `json:"contact"`
+	} `json:"messages"`
+}
+
+var addAbuseReportScript = newScript(`
+    local id = ARGV[1]
+    local now = tonumber(ARGV[4])
+    local message = ARGV[5]
+    local maxMessages = tonumber(ARGV[7])
+
+    local r = redis.call('HGET', 'reports', id)
+    if r then
+        r = cjson.decode(r)
+    else
+        r = {path = ARGV[2]; reason = ARGV[3]; count = 0; first = now}
+    end
+    r.count = r.count + 1
+    r.last = now
+    if type(r.messages) ~= 'table' then
+        r.messages = nil
+    end
+    if message ~= '' then
+        local messages = {{time = now; message = message; contact = ARGV[6]}}
+        for i = 1, math.min(#(r.messages or {}), maxMessages - 1) do
+            messages[#messages+1] = r.messages[i]
+        end
+        r.messages = messages
+    end
+    redis.call('HSET', 'reports', id, cjson.encode(r))
+`)
+
+func abuseReportID(path, reason string) string {
+	return path + " " + reason
+}
+
+// AddAbuseReport adds a report of the package for the reason to the
+// moderation queue. The message and the contact of the reporter are
+// optional.
+func (db *Database) AddAbuseReport(path, reason, message, contact string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := addAbuseReportScript.Do(c, abuseReportID(path, reason), path, reason, time.Now().Unix(), message, contact, maxAbuseMessages)
+	return err
+}
+
+// AbuseReports returns the moderation queue, oldest report first.
+func (db *Database) AbuseReports() ([]AbuseReport, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.ByteSlices(c.Do("HVALS", Key("reports")))
+	if err != nil {
+		return nil, err
+	}
+	var reports []AbuseReport
+	for _, p := range values {
+		var r abuseReport
+		if err := json.Unmarshal(p, &r); err != nil {
+			return nil, err
+		}
+		report := AbuseReport{
+			Path:   r.Path,
+			Reason: r.Reason,
+			Count:  r.Count,
+			First:  time.Unix(r.First, 0).UTC(),
+			Last:   time.Unix(r.Last, 0).UTC(),
+		}
+		for _, m := range r.Messages {
+			report.Messages = append(report.Messages, AbuseMessage{Time: time.Unix(m.Time, 0).UTC(), Message: m.Message, Contact: m.Contact})
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].First.Equal(reports[j].First) {
+			return reports[i].First.Before(reports[j].First)
+		}
+		return abuseReportID(reports[i].Path, reports[i].Reason) < abuseReportID(reports[j].Path, reports[j].Reason)
+	})
+	return reports, nil
+}
+
+// RemoveAbuseReports removes the reports of the package from the moderation
+// queue and returns the number of removed reports.
+func (db *Database) RemoveAbuseReports(path string) (int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	ids, err := redis.Strings(c.Do("HKEYS", Key("reports")))
+	if err != nil {
+		return 0, err
+	}
+	args := []interface{}{Key("reports")}
+	for _, id := range ids {
+		if strings.HasPrefix(id, path+" ") {
+			args = append(args, id)
+		}
+	}
+	if len(args) == 1 {
+		return 0, nil
+	}
+	return redis.Int(c.Do("HDEL", args...))
+}
@@ -0,0 +1,58 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import "testing"
+
+func TestAbuseReports(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, r := range []struct{ path, reason, message string }{
+		{"github.com/user/spam", "spam", "ads"},
+		{"github.com/user/spam", "spam", ""},
+		{"github.com/user/spam", "dmca", "copied"},
+		{"github.com/user/bad", "malware", "steals keys"},
+	} {
+		if err := db.AddAbuseReport(r.path, r.reason, r.message, "a@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < maxAbuseMessages+5; i++ {
+		if err := db.AddAbuseReport("github.com/user/bad", "malware", "again", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := db.AbuseReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("AbuseReports() returned %d reports, want 3", len(reports))
+	}
+	counts := make(map[string]int)
+	for _, r := range reports {
+		counts[abuseReportID(r.Path, r.Reason)] = r.Count
+		if r.Path == "github.com/user/spam" && r.Reason == "spam" && (len(r.Messages) != 1 || r.Messages[0].Message != "ads") {
+			t.Errorf("spam messages = %+v", r.Messages)
+		}
+		if r.Reason == "malware" && len(r.Messages) != maxAbuseMessages {
+			t.Errorf("malware report has %d messages, want %d", len(r.Messages), maxAbuseMessages)
+		}
+	}
+	if counts["github.com/user/spam spam"] != 2 || counts["github.com/user/spam dmca"] != 1 || counts["github.com/user/bad malware"] != maxAbuseMessages+6 {
+		t.Errorf("counts = %v", counts)
+	}
+
+	if n, err := db.RemoveAbuseReports("github.com/user/spam"); err != nil || n != 2 {
+		t.Errorf("RemoveAbuseReports() = %d, %v, want 2", n, err)
+	}
+	if reports, err := db.AbuseReports(); err != nil || len(reports) != 1 {
+		t.Errorf("AbuseReports() after remove = %+v, %v", reports, err)
+	}
+}
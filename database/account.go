@@ -0,0 +1,107 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+// AccountPackage is an indexed package in the repositories of an account.
+type AccountPackage struct {
+	Path      string    `json:"path"`
+	Synopsis  string    `json:"synopsis,omitempty"`
+	Stars     int       `json:"stars"`
+	Importers int       `json:"importers"`
+	Updated   time.Time `json:"updated"`
+}
+
+// accountOf returns the lowercase host and account, such as
+// github.com/spf13, of the repository containing the package with the import
+// path or "" if the import path does not have a host and an account.
+func accountOf(importPath string) string {
+	elems := strings.SplitN(strings.ToLower(importPath), "/", 4)
+	if len(elems) < 3 || !strings.Contains(elems[0], ".") {
+		return ""
+	}
+	return elems[0] + "/" + elems[1]
+}
+
+// putAccount adds the package to the index of the packages of the account or
+// removes the package from the index if the score is zero.
+func putAccount(c redis.Conn, pdoc *doc.Package, score float64) error {
+	account := accountOf(pdoc.ImportPath)
+	if account == "" {
+		return nil
+	}
+	var err error
+	if score > 0 {
+		value := strconv.Itoa(pdoc.Stars) + " " + strconv.FormatInt(pdoc.Updated.Unix(), 10) + " " + pdoc.Synopsis
+		_, err = c.Do("HSET", Key("account:"+account), pdoc.ImportPath, value)
+	} else {
+		_, err = c.Do("HDEL", Key("account:"+account), pdoc.ImportPath)
+	}
+	return err
+}
+
+func deleteAccount(c redis.Conn, path string) error {
+	account := accountOf(path)
+	if account == "" {
+		return nil
+	}
+	_, err := c.Do("HDEL", Key("account:"+account), path)
+	return err
+}
+
+type byImporters []AccountPackage
+
+func (p byImporters) Len() int { return len(p) }
+func (p byImporters) Less(i, j int) bool {
+	if p[i].Importers != p[j].Importers {
+		return p[i].Importers > p[j].Importers
+	}
+	return p[i].Path < p[j].Path
+}
+func (p byImporters) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// AccountPackages returns the indexed packages in the repositories of the
+// account, such as github.com/spf13, most imported first.
+func (db *Database) AccountPackages(account string) ([]AccountPackage, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.StringMap(c.Do("HGETALL", Key("account:"+strings.ToLower(account))))
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]AccountPackage, 0, len(values))
+	for path, value := range values {
+		fields := strings.SplitN(value, " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		pkg := AccountPackage{Path: path, Synopsis: fields[2]}
+		pkg.Stars, _ = strconv.Atoi(fields[0])
+		if t, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			pkg.Updated = time.Unix(t, 0).UTC()
+		}
+		pkgs = append(pkgs, pkg)
+		c.Send("SCARD", Key("index:import:"+path))
+	}
+	c.Flush()
+	for i := range pkgs {
+		if pkgs[i].Importers, err = redis.Int(c.Receive()); err != nil {
+			return nil, err
+		}
+	}
+	sort.Sort(byImporters(pkgs))
+	return pkgs, nil
+}
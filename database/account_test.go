@@ -0,0 +1,30 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+)
+
+var accountOfTests = []struct {
+	path, account string
+}{
+	{"github.com/spf13/cobra", "github.com/spf13"},
+	{"github.com/Spf13/cobra/doc", "github.com/spf13"},
+	{"github.com/spf13", ""},
+	{"fmt", ""},
+	{"net/http", ""},
+	{"golang.org/x/net/html", "golang.org/x"},
+}
+
+func TestAccountOf(t *testing.T) {
+	for _, tt := range accountOfTests {
+		if account := accountOf(tt.path); account != tt.account {
+			t.Errorf("accountOf(%q) = %q, want %q", tt.path, account, tt.account)
+		}
+	}
+}
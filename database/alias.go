@@ -0,0 +1,100 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// putAlias records that the package with the import path is a copy of the
+// package with the canonical import path. An empty canonical import path
+// removes the record.
+func putAlias(c redis.Conn, path, canonical string) error {
+	old, err := redis.String(c.Do("HGET", Key("alias"), path))
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+	if old == canonical {
+		return nil
+	}
+	c.Send("MULTI")
+	if old != "" {
+		c.Send("SREM", Key("aliases:"+old), path)
+	}
+	if canonical != "" {
+		c.Send("HSET", Key("alias"), path, canonical)
+		c.Send("SADD", Key("aliases:"+canonical), path)
+	} else {
+		c.Send("HDEL", Key("alias"), path)
+	}
+	_, err = c.Do("EXEC")
+	return err
+}
+
+// Aliases returns the import paths of the copies of the package with the
+// canonical import path, such as mirrors of the repository.
+func (db *Database) Aliases(canonical string) ([]string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Strings(c.Do("SMEMBERS", Key("aliases:"+canonical)))
+}
+
+// importerKeys returns the keys of the importer index sets of the package
+// with the import path, of the copies of the package and of the old import
+// paths of the package in moved repositories.
+func importerKeys(c redis.Conn, path string) ([]interface{}, error) {
+	aliases, err := redis.Strings(c.Do("SUNION", Key("aliases:"+path), Key("movedfrom:"+path)))
+	if err != nil {
+		return nil, err
+	}
+	keys := []interface{}{Key("index:import:" + path)}
+	for _, a := range aliases {
+		keys = append(keys, Key("index:import:"+a))
+	}
+	return keys, nil
+}
+
+// ImporterCount returns the number of packages importing the package with
+// the import path, a copy of the package or an old import path of the
+// package.
+func (db *Database) ImporterCount(path string) (int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	keys, err := importerKeys(c, path)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 1 {
+		return redis.Int(c.Do("SCARD", keys[0]))
+	}
+	ids, err := redis.Strings(c.Do("SUNION", keys...))
+	return len(ids), err
+}
+
+// Importers returns the packages importing the package with the import path,
+// a copy of the package or an old import path of the package.
+func (db *Database) Importers(path string) ([]Package, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	keys, err := importerKeys(c, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 1 {
+		return db.getPackages("index:import:"+path, false)
+	}
+	tmp := Key("tmp:importers:" + path)
+	c.Send("MULTI")
+	c.Send("SUNIONSTORE", append([]interface{}{tmp}, keys...)...)
+	c.Send("SORT", tmp, "ALPHA", "BY", Key("pkg:*->path"), "GET", Key("pkg:*->path"), "GET", Key("pkg:*->synopsis"), "GET", Key("pkg:*->kind"))
+	c.Send("DEL", tmp)
+	values, err := redis.Values(c.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	return packages(values[1], false)
+}
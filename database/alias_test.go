@@ -0,0 +1,47 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestAliases(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, pdoc := range []*doc.Package{
+		{ImportPath: "example.com/pkg", ProjectRoot: "example.com/pkg", Name: "pkg"},
+		{ImportPath: "github.com/user/pkg", ProjectRoot: "github.com/user/pkg", Name: "pkg", Canonical: "example.com/pkg"},
+		{ImportPath: "github.com/a/app", ProjectRoot: "github.com/a/app", Name: "main", IsCmd: true, Imports: []string{"example.com/pkg"}},
+		{ImportPath: "github.com/b/app", ProjectRoot: "github.com/b/app", Name: "main", IsCmd: true, Imports: []string{"github.com/user/pkg"}},
+	} {
+		if err := db.Put(pdoc, time.Time{}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if aliases, err := db.Aliases("example.com/pkg"); err != nil || len(aliases) != 1 || aliases[0] != "github.com/user/pkg" {
+		t.Errorf("Aliases() = %v, %v", aliases, err)
+	}
+	if n, err := db.ImporterCount("example.com/pkg"); err != nil || n != 2 {
+		t.Errorf("ImporterCount() = %d, %v, want 2", n, err)
+	}
+	if pkgs, err := db.Importers("example.com/pkg"); err != nil || len(pkgs) != 2 || pkgs[0].Path != "github.com/a/app" || pkgs[1].Path != "github.com/b/app" {
+		t.Errorf("Importers() = %v, %v", pkgs, err)
+	}
+
+	if err := db.Delete("github.com/user/pkg"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.ImporterCount("example.com/pkg"); err != nil || n != 1 {
+		t.Errorf("ImporterCount() after deleting the copy = %d, %v, want 1", n, err)
+	}
+}
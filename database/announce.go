@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// The severities of announcements.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Announcement is a message shown on all the pages of the site, such as the
+// notice of a maintenance window or of a change of policy.
+type Announcement struct {
+	ID       string `json:"id"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+
+	// Start and End are the times between which the announcement is shown.
+	// A zero Start or End does not bound the time.
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	Created time.Time `json:"created"`
+}
+
+// Active returns true if the announcement is shown at time t.
+func (a *Announcement) Active(t time.Time) bool {
+	return (a.Start.IsZero() || !t.Before(a.Start)) && (a.End.IsZero() || t.Before(a.End))
+}
+
+var errBadAnnouncement = errors.New("database: bad announcement message, severity or times")
+
+// AddAnnouncement adds the announcement and returns the ID assigned to the
+// announcement. An empty severity is SeverityInfo.
+func (db *Database) AddAnnouncement(a Announcement) (string, error) {
+	if a.Severity == "" {
+		a.Severity = SeverityInfo
+	}
+	if a.Message == "" ||
+		(a.Severity != SeverityInfo && a.Severity != SeverityWarning && a.Severity != SeverityCritical) ||
+		(!a.Start.IsZero() && !a.End.IsZero() && !a.End.After(a.Start)) {
+		return "", errBadAnnouncement
+	}
+	if a.Created.IsZero() {
+		a.Created = time.Now().UTC()
+	}
+
+	c := db.Pool.Get()
+	defer c.Close()
+	n, err := redis.Int64(c.Do("INCR", Key("maxAnnouncementId")))
+	if err != nil {
+		return "", err
+	}
+	a.ID = strconv.FormatInt(n, 10)
+	p, err := json.Marshal(&a)
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.Do("HSET", Key("announcements"), a.ID, p); err != nil {
+		return "", err
+	}
+	return a.ID, nil
+}
+
+// RemoveAnnouncement removes the announcement with the ID and returns false
+// if there is no announcement with the ID.
+func (db *Database) RemoveAnnouncement(id string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Bool(c.Do("HDEL", Key("announcements"), id))
+}
+
+// Announcements returns the announcements, including the announcements not
+// active yet or anymore, oldest first.
+func (db *Database) Announcements() ([]Announcement, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Values(c.Do("HVALS", Key("announcements")))
+	if err != nil {
+		return nil, err
+	}
+	announcements := make([]Announcement, len(values))
+	for i, v := range values {
+		p, err := redis.Bytes(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(p, &announcements[i]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(announcements, func(i, j int) bool {
+		a, _ := strconv.Atoi(announcements[i].ID)
+		b, _ := strconv.Atoi(announcements[j].ID)
+		return a < b
+	})
+	return announcements, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnnouncements(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	now := time.Now().UTC()
+	if _, err := db.AddAnnouncement(Announcement{Message: "x", Severity: "fatal"}); err == nil {
+		t.Error("AddAnnouncement() accepted bad severity")
+	}
+	if _, err := db.AddAnnouncement(Announcement{Message: "x", Start: now, End: now.Add(-time.Hour)}); err == nil {
+		t.Error("AddAnnouncement() accepted end before start")
+	}
+	var ids []string
+	for _, a := range []Announcement{
+		{Message: "Maintenance tonight", Severity: SeverityWarning, Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{Message: "New policy"},
+		{Message: "Maintenance tomorrow", Start: now.Add(24 * time.Hour)},
+	} {
+		id, err := db.AddAnnouncement(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	all, err := db.Announcements()
+	if err != nil || len(all) != 3 || all[0].ID != ids[0] || all[1].Severity != SeverityInfo || all[2].Message != "Maintenance tomorrow" {
+		t.Fatalf("Announcements() = %+v, %v", all, err)
+	}
+	if !all[0].Active(now) || !all[1].Active(now) || all[2].Active(now) || all[0].Active(now.Add(time.Hour)) {
+		t.Errorf("Active(%v) of %+v is wrong", now, all)
+	}
+
+	if ok, err := db.RemoveAnnouncement(ids[1]); !ok || err != nil {
+		t.Errorf("RemoveAnnouncement(%s) = %v, %v", ids[1], ok, err)
+	}
+	if ok, err := db.RemoveAnnouncement(ids[1]); ok || err != nil {
+		t.Errorf("RemoveAnnouncement(%s) again = %v, %v, want false", ids[1], ok, err)
+	}
+	if all, err := db.Announcements(); err != nil || len(all) != 2 {
+		t.Errorf("Announcements() after remove = %+v, %v", all, err)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+// APIHistory is the summary of the exported API of a package at the last
+// crawl and at the last crawl before the API changed.
+type APIHistory struct {
+	Current     []doc.APISymbol
+	CurrentTime time.Time
+
+	// PreviousTime is zero if the API did not change since the summary was
+	// first stored.
+	Previous     []doc.APISymbol
+	PreviousTime time.Time
+}
+
+// putAPIScript replaces the current summary with the summary in ARGV[2]. The
+// current summary is moved to the previous summary if the summaries differ.
+// The script returns 1 if a current summary was moved.
+var putAPIScript = newScript(`
+    local key = 'api:' .. ARGV[1]
+    local api = ARGV[2]
+    local t = ARGV[3]
+
+    local cur = redis.call('HGET', key, 'cur')
+    if cur == api then
+        redis.call('HSET', key, 'curTime', t)
+        return 0
+    end
+    local moved = 0
+    if cur then
+        redis.call('HMSET', key, 'prev', cur, 'prevTime', redis.call('HGET', key, 'curTime'))
+        moved = 1
+    end
+    redis.call('HMSET', key, 'cur', api, 'curTime', t)
+    return moved
+`)
+
+func encodeAPI(api []doc.APISymbol) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(api); err != nil {
+		return nil, err
+	}
+	return encodeValue(buf.Bytes()), nil
+}
+
+func decodeAPI(p []byte) ([]doc.APISymbol, error) {
+	if p == nil {
+		return nil, nil
+	}
+	p, err := decodeValue(p)
+	if err != nil {
+		return nil, err
+	}
+	var api []doc.APISymbol
+	err = gob.NewDecoder(bytes.NewReader(p)).Decode(&api)
+	return api, err
+}
+
+// putAPI stores the summary of the API at time t and returns true if the API
+// changed since the last stored summary.
+func putAPI(c redis.Conn, importPath string, api []doc.APISymbol, t time.Time) (bool, error) {
+	p, err := encodeAPI(api)
+	if err != nil {
+		return false, err
+	}
+	return redis.Bool(putAPIScript.Do(c, importPath, p, t.Unix()))
+}
+
+// GetAPIHistory returns the stored summaries of the exported API of the
+// package or nil if no summary is stored.
+func (db *Database) GetAPIHistory(importPath string) (*APIHistory, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Values(c.Do("HMGET", Key("api:"+importPath), "cur", "curTime", "prev", "prevTime"))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cur, prev         []byte
+		curTime, prevTime int64
+	)
+	if _, err := redis.Scan(values, &cur, &curTime, &prev, &prevTime); err != nil {
+		return nil, err
+	}
+	if cur == nil {
+		return nil, nil
+	}
+	h := &APIHistory{CurrentTime: time.Unix(curTime, 0)}
+	if h.Current, err = decodeAPI(cur); err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		if h.Previous, err = decodeAPI(prev); err != nil {
+			return nil, err
+		}
+		h.PreviousTime = time.Unix(prevTime, 0)
+	}
+	return h, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestEncodeAPI(t *testing.T) {
+	for _, api := range [][]doc.APISymbol{
+		nil,
+		{{Name: "F", Kind: "func", Decl: "func F()"}, {Name: "T", Kind: "type", Decl: "type T struct"}},
+	} {
+		p, err := encodeAPI(api)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodeAPI(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, api) {
+			t.Errorf("decodeAPI(encodeAPI(%v)) = %v", api, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// APIKeyPrefix is the prefix of the keys created by CreateAPIKey.
+const APIKeyPrefix = "gddo_"
+
+// apiUsageExpiration is the time to live in seconds of the daily usage of an
+// API key.
+const apiUsageExpiration = 40 * 24 * 60 * 60
+
+// APIKey is a key issued to a client of the API.
+type APIKey struct {
+	Name    string
+	Quota   int // Requests per day. Zero is unlimited.
+	Created time.Time
+}
+
+// apiKeyHash returns the hash of the key stored in the database. The keys
+// themselves are not stored.
+func apiKeyHash(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateAPIKey issues a key for the client with the name and the daily quota
+// and returns the key. The key cannot be recovered from the database.
+func (db *Database) CreateAPIKey(name string, quota int) (string, error) {
+	if quota < 0 {
+		return "", errors.New("database: negative API key quota")
+	}
+	var b [20]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	key := APIKeyPrefix + hex.EncodeToString(b[:])
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("HMSET", Key("apikey:"+apiKeyHash(key)), "name", name, "quota", quota, "created", time.Now().Unix())
+	return key, err
+}
+
+// APIKey returns the API key or nil if the key was not issued.
+func (db *Database) APIKey(key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, nil
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Values(c.Do("HMGET", Key("apikey:"+apiKeyHash(key)), "name", "quota", "created"))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		name    string
+		quota   int
+		created int64
+	)
+	if _, err := redis.Scan(values, &name, &quota, &created); err != nil {
+		return nil, err
+	}
+	if created == 0 {
+		return nil, nil
+	}
+	return &APIKey{Name: name, Quota: quota, Created: time.Unix(created, 0).UTC()}, nil
+}
+
+// UseAPIKey counts a request with the key and returns the number of requests
+// with the key today.
+func (db *Database) UseAPIKey(key string) (int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	k := Key("apiusage:" + apiKeyHash(key) + ":" + statsDay(time.Now()))
+	n, err := redis.Int(c.Do("INCR", k))
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		_, err = c.Do("EXPIRE", k, apiUsageExpiration)
+	}
+	return n, err
+}
+
+// APIKeyUsage returns the number of requests with the key on each of the
+// last days, oldest first.
+func (db *Database) APIKeyUsage(key string, days int) ([]int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	now := time.Now()
+	for i := 0; i < days; i++ {
+		c.Send("GET", Key("apiusage:"+apiKeyHash(key)+":"+statsDay(now.AddDate(0, 0, i-days+1))))
+	}
+	c.Flush()
+	usage := make([]int, days)
+	for i := range usage {
+		n, err := redis.Int(c.Receive())
+		if err != nil && err != redis.ErrNil {
+			return nil, err
+		}
+		usage[i] = n
+	}
+	return usage, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyHash(t *testing.T) {
+	const key = APIKeyPrefix + "0123456789abcdef"
+	h := apiKeyHash(key)
+	if len(h) != 64 || strings.Contains(h, "0123456789abcdef") {
+		t.Errorf("apiKeyHash(%q) = %q, want hex SHA-256 not containing the key", key, h)
+	}
+	if h == apiKeyHash(key+"0") {
+		t.Error("different keys have the same hash")
+	}
+}
+
+func TestAPIKeyWithoutPrefix(t *testing.T) {
+	// Keys without the prefix are not looked up in the database.
+	var db Database
+	k, err := db.APIKey("0123456789abcdef")
+	if k != nil || err != nil {
+		t.Errorf("APIKey() = %v, %v; want nil, nil", k, err)
+	}
+}
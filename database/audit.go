@@ -0,0 +1,79 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// auditBatch is the number of entries read from the audit log at a time.
+const auditBatch = 100
+
+// AuditEntry is an administrative action in the audit log.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+
+	// Actor is the operator or the owner who performed the action.
+	Actor string `json:"actor"`
+
+	// Action is the name of the action, such as delete, block, quarantine,
+	// release, restore or reindex.
+	Action string `json:"action"`
+
+	// Target is the import path or project root of the action. The target
+	// is empty for actions on the whole database.
+	Target string `json:"target,omitempty"`
+
+	Reason string `json:"reason,omitempty"`
+}
+
+// AddAuditEntry appends the entry to the audit log. The time of the entry is
+// set to the current time if zero.
+func (db *Database) AddAuditEntry(e AuditEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	p, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err = c.Do("RPUSH", Key("audit"), p)
+	return err
+}
+
+// AuditLog returns the last n entries of the audit log for which match
+// returns true, newest first. A nil match matches every entry.
+func (db *Database) AuditLog(n int, match func(*AuditEntry) bool) ([]AuditEntry, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	var entries []AuditEntry
+	for end := -1; len(entries) < n; end -= auditBatch {
+		values, err := redis.ByteSlices(c.Do("LRANGE", Key("audit"), end-auditBatch+1, end))
+		if err != nil {
+			return nil, err
+		}
+		for i := len(values) - 1; i >= 0 && len(entries) < n; i-- {
+			var e AuditEntry
+			if err := json.Unmarshal(values[i], &e); err != nil {
+				return nil, err
+			}
+			if match == nil || match(&e) {
+				entries = append(entries, e)
+			}
+		}
+		if len(values) < auditBatch {
+			break
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for i := 0; i < 250; i++ {
+		action := "delete"
+		if i%2 == 1 {
+			action = "block"
+		}
+		if err := db.AddAuditEntry(AuditEntry{Actor: "op", Action: action, Target: fmt.Sprintf("example.com/p%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := db.AuditLog(3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Target != "example.com/p249" || entries[2].Target != "example.com/p247" {
+		t.Errorf("AuditLog(3) = %v", entries)
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("entry time not set")
+	}
+
+	entries, err = db.AuditLog(1000, func(e *AuditEntry) bool { return e.Action == "delete" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 125 || entries[124].Target != "example.com/p0" {
+		t.Errorf("AuditLog(delete) returned %d entries", len(entries))
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// A backup is a snapshot of all the keys in the namespace, including the
+// crawl queues, the leases and the gob encoded state such as the GitHub
+// updates cursor. The keys are read with SCAN and DUMP in batches, so Redis
+// serves the other processes during the backup. The backup holds the writes
+// lease while it reads the keys: Put, which writes the documents and the
+// search index, fails with ErrWritesPaused, so the crawls do not change the
+// documents during the backup. The backup is not a point-in-time snapshot:
+// the other writes, such as Delete, the crawl queues, the moves, the owners,
+// the blocklist, the tombstones and the counters of the server, continue
+// while the keys are read, so keys read at different times can disagree. The
+// Redis snapshot saved by BackgroundSave is the only consistent copy. The
+// restored keys of an older schema are migrated. The file is a gzip
+// compressed stream of the gob encoded BackupHeader followed by a
+// backupEntry for each key, with the value in the Redis DUMP format, and an
+// entry with an empty key marking the end.
+
+// BackupHeader describes a backup.
+type BackupHeader struct {
+	// Time of the snapshot.
+	Time time.Time
+
+	// Version of the schema of the data in the backup.
+	SchemaVersion int
+
+	// Number of keys in the backup, set in the header returned by Backup
+	// and RestoreBackup. The header in the file does not have the number.
+	Keys int
+}
+
+type backupEntry struct {
+	// Key without the namespace or empty at the end of the backup.
+	Key string
+
+	// TTL in milliseconds or zero if the key does not expire.
+	TTL int64
+
+	// Value serialized by DUMP.
+	Value []byte
+}
+
+var (
+	errNotEmpty    = errors.New("database: namespace is not empty")
+	errNewerBackup = errors.New("database: backup has a newer schema")
+)
+
+// ErrWritesPaused is returned by Put while a backup holds the writes lease.
+var ErrWritesPaused = errors.New("database: writes paused for a backup")
+
+const (
+	writesLeaseName = "writes"
+	writesLeaseTTL  = time.Minute
+	backupBatch     = 1000
+)
+
+var errBackupRunning = errors.New("database: another backup holds the writes lease")
+
+// keyPattern returns the SCAN pattern matching all keys in the namespace.
+func keyPattern() string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`).Replace(*namespace) + "*"
+}
+
+// Backup writes a snapshot of all the keys in the namespace to w.
+func (db *Database) Backup(w io.Writer) (*BackupHeader, error) {
+	var p [8]byte
+	rand.Read(p[:])
+	holder := "backup-" + hex.EncodeToString(p[:])
+	ok, err := db.AcquireLease(writesLeaseName, holder, writesLeaseTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errBackupRunning
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(writesLeaseTTL / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if _, err := db.AcquireLease(writesLeaseName, holder, writesLeaseTTL); err != nil {
+					slog.Error("extend writes lease", "holder", holder, "err", err)
+				}
+			}
+		}
+	}()
+	defer db.releaseLease(writesLeaseName, holder)
+
+	c := db.Pool.Get()
+	defer c.Close()
+	schema, err := redis.Int(c.Do("GET", Key("schema")))
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+	h := &BackupHeader{Time: time.Now().UTC(), SchemaVersion: schema}
+	zw := gzip.NewWriter(w)
+	enc := gob.NewEncoder(zw)
+	if err := enc.Encode(h); err != nil {
+		return nil, err
+	}
+
+	// SCAN can return a key more than once.
+	seen := make(map[string]bool)
+	cursor := 0
+	for {
+		values, err := redis.Values(c.Do("SCAN", cursor, "MATCH", keyPattern(), "COUNT", backupBatch))
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return nil, err
+		}
+		var batch []string
+		for _, key := range keys {
+			if !seen[key] && key != Key("lease:"+writesLeaseName) {
+				seen[key] = true
+				batch = append(batch, key)
+			}
+		}
+		for _, key := range batch {
+			c.Send("PTTL", key)
+			c.Send("DUMP", key)
+		}
+		if err := c.Flush(); err != nil {
+			return nil, err
+		}
+		for _, key := range batch {
+			ttl, err := redis.Int64(c.Receive())
+			if err != nil {
+				return nil, err
+			}
+			value, err := redis.Bytes(c.Receive())
+			if err == redis.ErrNil {
+				// The key expired or was deleted after the SCAN.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if ttl < 0 {
+				ttl = 0
+			}
+			if err := enc.Encode(&backupEntry{Key: strings.TrimPrefix(key, *namespace), TTL: ttl, Value: value}); err != nil {
+				return nil, err
+			}
+			h.Keys++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	if err := enc.Encode(&backupEntry{}); err != nil {
+		return nil, err
+	}
+	return h, zw.Close()
+}
+
+// RestoreBackup restores the keys in the backup read from r to the
+// namespace. If replace is false, RestoreBackup returns an error if the
+// namespace has keys. If replace is true, the keys in the namespace are
+// deleted first. A backup with a newer schema than SchemaVersion is not
+// restored; the keys of a backup with an older schema are migrated.
+func (db *Database) RestoreBackup(r io.Reader, replace bool) (*BackupHeader, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	dec := gob.NewDecoder(zr)
+	var h BackupHeader
+	if err := dec.Decode(&h); err != nil {
+		return nil, err
+	}
+	if h.SchemaVersion > SchemaVersion() {
+		return nil, errNewerBackup
+	}
+
+	c := db.Pool.Get()
+	defer c.Close()
+	keys, err := db.namespaceKeys(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 && !replace {
+		return nil, errNotEmpty
+	}
+	for _, key := range keys {
+		c.Send("DEL", key)
+	}
+
+	const batch = 100
+	n := len(keys)
+	for {
+		var e backupEntry
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		if e.Key == "" {
+			break
+		}
+		h.Keys++
+		c.Send("RESTORE", Key(e.Key), e.TTL, e.Value, "REPLACE")
+		n++
+		if n >= batch {
+			if err := flushReplies(c, n); err != nil {
+				return nil, err
+			}
+			n = 0
+		}
+	}
+	if err := flushReplies(c, n); err != nil {
+		return nil, err
+	}
+	if h.SchemaVersion < SchemaVersion() {
+		var p [8]byte
+		rand.Read(p[:])
+		// A migrate lease in the backup expires within the TTL.
+		if err := db.Migrate("restore-"+hex.EncodeToString(p[:]), 2*migrateLeaseTTL); err != nil {
+			return nil, err
+		}
+	}
+	return &h, nil
+}
+
+// namespaceKeys returns the keys in the namespace.
+func (db *Database) namespaceKeys(c redis.Conn) ([]string, error) {
+	var keys []string
+	cursor := 0
+	for {
+		values, err := redis.Values(c.Do("SCAN", cursor, "MATCH", keyPattern(), "COUNT", 1000))
+		if err != nil {
+			return nil, err
+		}
+		var batch []string
+		if _, err := redis.Scan(values, &cursor, &batch); err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+// flushReplies flushes the n commands sent on c and returns the first error
+// reply.
+func flushReplies(c redis.Conn, n int) error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	var first error
+	for i := 0; i < n; i++ {
+		if _, err := c.Receive(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// BackgroundSave starts a Redis BGSAVE and waits at most timeout for the
+// snapshot of the Redis server to be saved to disk. BackgroundSave returns
+// the time of the saved snapshot.
+func (db *Database) BackgroundSave(timeout time.Duration) (time.Time, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	last, err := redis.Int64(c.Do("LASTSAVE"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, err := c.Do("BGSAVE"); err != nil {
+		return time.Time{}, err
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		t, err := redis.Int64(c.Do("LASTSAVE"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t != last {
+			return time.Unix(t, 0), nil
+		}
+	}
+	return time.Time{}, errors.New("database: timeout waiting for BGSAVE")
+}
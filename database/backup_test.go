@@ -0,0 +1,99 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+func TestBackup(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	if err := db.AddNewCrawl("example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutGob("gitHubUpdates", "cursor"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate("a", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	h, err := db.Backup(&buf)
+	if err != nil {
+		t.Fatalf("Backup returned %v", err)
+	}
+	if h.Keys != 3 || h.SchemaVersion != SchemaVersion() {
+		t.Errorf("Backup header = %+v, want 3 keys and schema version %d", h, SchemaVersion())
+	}
+
+	if _, err := db.RestoreBackup(bytes.NewReader(buf.Bytes()), false); err != errNotEmpty {
+		t.Errorf("RestoreBackup to non-empty namespace returned %v, want %v", err, errNotEmpty)
+	}
+	c := db.Pool.Get()
+	c.Do("SET", "extra", "x")
+	c.Close()
+	if _, err := db.RestoreBackup(bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatalf("RestoreBackup returned %v", err)
+	}
+
+	c = db.Pool.Get()
+	defer c.Close()
+	if n, _ := redis.Int(c.Do("DBSIZE")); n != 3 {
+		t.Errorf("after RestoreBackup, %d keys, want 3", n)
+	}
+	if ok, _ := redis.Bool(c.Do("SISMEMBER", "newCrawl", "example.com/a")); !ok {
+		t.Error("crawl queue not restored")
+	}
+	var cursor string
+	if err := db.GetGob("gitHubUpdates", &cursor); err != nil || cursor != "cursor" {
+		t.Errorf("GetGob after RestoreBackup = %q, %v, want cursor", cursor, err)
+	}
+
+	if ok, err := db.AcquireLease(writesLeaseName, "other", time.Minute); !ok || err != nil {
+		t.Fatalf("AcquireLease(writes) = %v, %v", ok, err)
+	}
+	if err := db.Put(&doc.Package{ImportPath: "example.com/b"}, time.Time{}, false); err != ErrWritesPaused {
+		t.Errorf("Put during a backup returned %v, want %v", err, ErrWritesPaused)
+	}
+	if _, err := db.Backup(&buf); err != errBackupRunning {
+		t.Errorf("Backup during a backup returned %v, want %v", err, errBackupRunning)
+	}
+}
+
+func TestRestoreBackupSchema(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	backup := func(schema int) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		enc := gob.NewEncoder(zw)
+		enc.Encode(&BackupHeader{Time: time.Now(), SchemaVersion: schema})
+		enc.Encode(&backupEntry{})
+		zw.Close()
+		return buf.Bytes()
+	}
+	if _, err := db.RestoreBackup(bytes.NewReader(backup(SchemaVersion()+1)), true); err != errNewerBackup {
+		t.Errorf("RestoreBackup of newer schema returned %v, want %v", err, errNewerBackup)
+	}
+	if _, err := db.RestoreBackup(bytes.NewReader(backup(0)), true); err != nil {
+		t.Fatalf("RestoreBackup of older schema returned %v", err)
+	}
+	if v, err := db.StoredSchemaVersion(); err != nil || v != SchemaVersion() {
+		t.Errorf("StoredSchemaVersion after RestoreBackup = %d, %v, want %d", v, err, SchemaVersion())
+	}
+}
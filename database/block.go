@@ -0,0 +1,223 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// The kinds of blocks.
+const (
+	// BlockRemoved is a package removed from the site.
+	BlockRemoved = "removed"
+
+	// BlockLegal is a package removed for legal reasons.
+	BlockLegal = "legal"
+)
+
+// BlockEntry is an entry of the blocklist. The entry blocks the packages
+// with an import path matching the pattern and the packages below the
+// matching paths. A pattern is an import path or a glob pattern as
+// understood by path.Match, such as github.com/user/*.
+type BlockEntry struct {
+	Pattern string    `json:"pattern"`
+	Kind    string    `json:"kind"`
+	Reason  string    `json:"reason,omitempty"`
+	Time    time.Time `json:"time,omitempty"`
+}
+
+var errBadBlockEntry = errors.New("database: bad block pattern or kind")
+
+func isBlockPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// matchBlock returns true if the pattern matches the import path or a
+// parent of the import path.
+func matchBlock(pattern, importPath string) bool {
+	for p := importPath; ; {
+		if p == pattern {
+			return true
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		i := strings.LastIndex(p, "/")
+		if i < 0 {
+			return false
+		}
+		p = p[:i]
+	}
+}
+
+// Block adds the import path root to the blocklist and deletes the
+// documentation for root and the packages below root.
+func (db *Database) Block(root string) error {
+	return db.AddBlock(BlockEntry{Pattern: root, Kind: BlockRemoved})
+}
+
+// AddBlock adds the entry to the blocklist and deletes the documentation for
+// the blocked packages. An entry with the same pattern is replaced.
+func (db *Database) AddBlock(e BlockEntry) error {
+	e.Pattern = strings.Trim(e.Pattern, "/")
+	if e.Kind == "" {
+		e.Kind = BlockRemoved
+	}
+	if _, err := path.Match(e.Pattern, ""); err != nil || e.Pattern == "" || (e.Kind != BlockRemoved && e.Kind != BlockLegal) {
+		return errBadBlockEntry
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	p, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	set := "block"
+	if isBlockPattern(e.Pattern) {
+		set = "blockpat"
+	}
+
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("SADD", Key(set), e.Pattern)
+	c.Send("HSET", Key("blockinfo"), e.Pattern, p)
+	if _, err := c.Do("EXEC"); err != nil {
+		return err
+	}
+	if err := putWatchEvent(c, WatchEvent{Path: e.Pattern, Kind: WatchBlocked, Time: e.Time}); err != nil {
+		return err
+	}
+
+	keys, err := redis.Strings(c.Do("HKEYS", Key("ids")))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if matchBlock(e.Pattern, key) {
+			if err := db.deleteDoc(c, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveBlock removes the entry with the pattern from the blocklist and
+// returns false if there is no entry with the pattern.
+func (db *Database) RemoveBlock(pattern string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("SREM", Key("block"), pattern)
+	c.Send("SREM", Key("blockpat"), pattern)
+	c.Send("HDEL", Key("blockinfo"), pattern)
+	values, err := redis.Ints(c.Do("EXEC"))
+	if err != nil {
+		return false, err
+	}
+	return values[0]+values[1] > 0, nil
+}
+
+// blockEntries returns the entries for the patterns. Entries added before
+// the blocklist recorded the kind and reason are returned with the kind
+// BlockRemoved.
+func blockEntries(c redis.Conn, patterns []string) ([]BlockEntry, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{Key("blockinfo")}
+	for _, p := range patterns {
+		args = append(args, p)
+	}
+	values, err := redis.ByteSlices(c.Do("HMGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]BlockEntry, len(patterns))
+	for i, p := range values {
+		if p != nil {
+			if err := json.Unmarshal(p, &entries[i]); err != nil {
+				return nil, err
+			}
+		}
+		entries[i].Pattern = patterns[i]
+		if entries[i].Kind == "" {
+			entries[i].Kind = BlockRemoved
+		}
+	}
+	return entries, nil
+}
+
+// BlockEntries returns the blocklist sorted by pattern.
+func (db *Database) BlockEntries() ([]BlockEntry, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	patterns, err := redis.Strings(c.Do("SUNION", Key("block"), Key("blockpat")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(patterns)
+	return blockEntries(c, patterns)
+}
+
+var isBlockedScript = newScript(`
+    local path = ''
+    for s in string.gmatch(ARGV[1], '[^/]+') do
+        path = path .. s
+        if redis.call('SISMEMBER', 'block', path) == 1 then
+            return path
+        end
+        path = path .. '/'
+    end
+    return false
+`)
+
+// BlockedBy returns the blocklist entry blocking the package with the import
+// path or nil if the package is not blocked.
+func (db *Database) BlockedBy(importPath string) (*BlockEntry, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	pattern, err := redis.String(isBlockedScript.Do(c, importPath))
+	if err == redis.ErrNil {
+		patterns, err := redis.Strings(c.Do("SMEMBERS", Key("blockpat")))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(patterns)
+		for _, p := range patterns {
+			if matchBlock(p, importPath) {
+				pattern = p
+				break
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		return nil, nil
+	}
+	entries, err := blockEntries(c, []string{pattern})
+	if err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// IsBlocked returns true if the package with the import path is blocked.
+func (db *Database) IsBlocked(importPath string) (bool, error) {
+	e, err := db.BlockedBy(importPath)
+	return e != nil, err
+}
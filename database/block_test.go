@@ -0,0 +1,67 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import "testing"
+
+func TestMatchBlock(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"github.com/user/repo", "github.com/user/repo", true},
+		{"github.com/user/repo", "github.com/user/repo/sub", true},
+		{"github.com/user/repo", "github.com/user/repository", false},
+		{"github.com/user/*", "github.com/user/repo", true},
+		{"github.com/user/*", "github.com/user/repo/sub/pkg", true},
+		{"github.com/user/*", "github.com/user", false},
+		{"github.com/user/*", "github.com/username/repo", false},
+		{"github.com/*/spam-*", "github.com/x/spam-tools/cmd", true},
+	} {
+		if got := matchBlock(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchBlock(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBlockEntries(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	if err := db.AddBlock(BlockEntry{Pattern: "github.com/badactor/*", Kind: BlockLegal, Reason: "court order"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Block("github.com/user/repo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddBlock(BlockEntry{Pattern: "github.com/[", Kind: BlockRemoved}); err == nil {
+		t.Error("AddBlock() accepted bad pattern")
+	}
+
+	e, err := db.BlockedBy("github.com/badactor/repo/pkg")
+	if err != nil || e == nil || e.Pattern != "github.com/badactor/*" || e.Kind != BlockLegal || e.Reason != "court order" {
+		t.Errorf("BlockedBy(github.com/badactor/repo/pkg) = %+v, %v", e, err)
+	}
+	if e, err := db.BlockedBy("github.com/user/repo/sub"); err != nil || e == nil || e.Kind != BlockRemoved {
+		t.Errorf("BlockedBy(github.com/user/repo/sub) = %+v, %v", e, err)
+	}
+	if e, err := db.BlockedBy("github.com/badactor"); err != nil || e != nil {
+		t.Errorf("BlockedBy(github.com/badactor) = %+v, %v, want nil", e, err)
+	}
+
+	entries, err := db.BlockEntries()
+	if err != nil || len(entries) != 2 || entries[0].Pattern != "github.com/badactor/*" || entries[1].Pattern != "github.com/user/repo" {
+		t.Errorf("BlockEntries() = %+v, %v", entries, err)
+	}
+
+	if ok, err := db.RemoveBlock("github.com/badactor/*"); !ok || err != nil {
+		t.Errorf("RemoveBlock() = %v, %v, want true", ok, err)
+	}
+	if blocked, err := db.IsBlocked("github.com/badactor/repo"); blocked || err != nil {
+		t.Errorf("IsBlocked() after RemoveBlock() = %v, %v", blocked, err)
+	}
+}
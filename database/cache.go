@@ -0,0 +1,139 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+// docCache is a size bounded LRU cache of the results returned by
+// Database.Get. Entries are invalidated by project root because the
+// subdirectories and crawl times stored with an entry change when any
+// package in the project is updated.
+type docCache struct {
+	mu      sync.Mutex
+	size    int
+	maxAge  time.Duration
+	ll      *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+type docCacheEntry struct {
+	path      string
+	root      string
+	pdoc      *doc.Package
+	subdirs   []Package
+	nextCrawl time.Time
+	added     time.Time
+}
+
+func newDocCache(size int, maxAge time.Duration) *docCache {
+	return &docCache{
+		size:    size,
+		maxAge:  maxAge,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// get returns the cached entry for path. The returned document is shared
+// with other callers and must not be modified.
+func (c *docCache) get(path string) (*docCacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	ce := e.Value.(*docCacheEntry)
+	if c.maxAge > 0 && c.now().Sub(ce.added) > c.maxAge {
+		c.removeElement(e)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return ce, true
+}
+
+func (c *docCache) add(pdoc *doc.Package, subdirs []Package, nextCrawl time.Time) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ce := &docCacheEntry{
+		path:      pdoc.ImportPath,
+		root:      normalizeProjectRoot(pdoc.ProjectRoot),
+		pdoc:      pdoc,
+		subdirs:   subdirs,
+		nextCrawl: nextCrawl,
+		added:     c.now(),
+	}
+	if e, ok := c.entries[ce.path]; ok {
+		e.Value = ce
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.entries[ce.path] = c.ll.PushFront(ce)
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// removeProject removes all entries for packages in the project with the
+// given root.
+func (c *docCache) removeProject(projectRoot string) {
+	if c == nil {
+		return
+	}
+	projectRoot = normalizeProjectRoot(projectRoot)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*docCacheEntry).root == projectRoot {
+			c.removeElement(e)
+		}
+		e = next
+	}
+}
+
+// remove removes the entry for path, the entries for other packages in the
+// same project and the entries for parent directories of path.
+func (c *docCache) remove(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	root := ""
+	if e, ok := c.entries[path]; ok {
+		root = e.Value.(*docCacheEntry).root
+	}
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		ce := e.Value.(*docCacheEntry)
+		if ce.path == path || ce.root == root || strings.HasPrefix(path, ce.path+"/") {
+			c.removeElement(e)
+		}
+		e = next
+	}
+}
+
+func (c *docCache) removeElement(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.entries, e.Value.(*docCacheEntry).path)
+}
@@ -0,0 +1,64 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestDocCache(t *testing.T) {
+	c := newDocCache(2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	a := &doc.Package{ImportPath: "github.com/user/a", ProjectRoot: "github.com/user/a"}
+	b := &doc.Package{ImportPath: "github.com/user/b", ProjectRoot: "github.com/user/b"}
+	bsub := &doc.Package{ImportPath: "github.com/user/b/sub", ProjectRoot: "github.com/user/b"}
+
+	c.add(a, nil, time.Time{})
+	c.add(b, nil, time.Time{})
+	if _, ok := c.get(a.ImportPath); !ok {
+		t.Fatalf("get(%q) not found", a.ImportPath)
+	}
+
+	// Adding a third entry evicts b, the least recently used entry.
+	c.add(bsub, nil, time.Time{})
+	if _, ok := c.get(b.ImportPath); ok {
+		t.Errorf("get(%q) found, want evicted", b.ImportPath)
+	}
+	if ce, ok := c.get(bsub.ImportPath); !ok || ce.pdoc != bsub {
+		t.Errorf("get(%q) = %v, %v; want entry", bsub.ImportPath, ce, ok)
+	}
+
+	// Put of any package in a project invalidates the whole project.
+	c.removeProject("github.com/user/b")
+	if _, ok := c.get(bsub.ImportPath); ok {
+		t.Errorf("get(%q) found after removeProject", bsub.ImportPath)
+	}
+
+	// Deleting a child package invalidates the parent directory.
+	c.add(b, nil, time.Time{})
+	c.remove("github.com/user/b/other")
+	if _, ok := c.get(b.ImportPath); ok {
+		t.Errorf("get(%q) found after removing child", b.ImportPath)
+	}
+
+	// Entries expire after maxAge.
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get(a.ImportPath); ok {
+		t.Errorf("get(%q) found after max age", a.ImportPath)
+	}
+
+	var nilCache *docCache
+	nilCache.add(a, nil, time.Time{})
+	if _, ok := nilCache.get(a.ImportPath); ok {
+		t.Errorf("nil cache returned entry")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Change is the last save or deletion of a package in the log of changes.
+// Seq increases with each change, so a reader that has seen the changes up to
+// Seq only needs the changes after Seq to catch up.
+type Change struct {
+	Path    string
+	Seq     int64
+	Deleted bool
+}
+
+// putChange records a save or deletion of the package with the import path
+// in the log of changes. The log has the last change of each path.
+func putChange(c redis.Conn, path string) error {
+	seq, err := redis.Int64(c.Do("INCR", Key("changes:seq")))
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("ZADD", Key("changes"), seq, path)
+	return err
+}
+
+// Changes returns up to n of the changes after seq, oldest first.
+func (db *Database) Changes(seq int64, n int) ([]Change, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Values(c.Do("ZRANGEBYSCORE", Key("changes"), "("+strconv.FormatInt(seq, 10), "+inf", "WITHSCORES", "LIMIT", 0, n))
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for len(values) > 0 {
+		var ch Change
+		values, err = redis.Scan(values, &ch.Path, &ch.Seq)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, ch)
+		c.Send("HEXISTS", Key("ids"), ch.Path)
+	}
+	c.Flush()
+	for i := range changes {
+		exists, err := redis.Bool(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		changes[i].Deleted = !exists
+	}
+	return changes, nil
+}
+
+// LastChange returns the sequence number of the last change or zero if there
+// are no changes.
+func (db *Database) LastChange() (int64, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	seq, err := redis.Int64(c.Do("GET", Key("changes:seq")))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return seq, err
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestChanges(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, p := range []string{"github.com/user/a", "github.com/user/b", "github.com/user/c"} {
+		if err := db.Put(&doc.Package{ImportPath: p, ProjectRoot: p, Name: "x"}, time.Time{}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Put(&doc.Package{ImportPath: "github.com/user/a", ProjectRoot: "github.com/user/a", Name: "x"}, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("github.com/user/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := db.Changes(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Path: "github.com/user/c", Seq: 3},
+		{Path: "github.com/user/a", Seq: 4},
+		{Path: "github.com/user/b", Seq: 5, Deleted: true},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Changes(0) = %+v, want %+v", changes, want)
+	}
+
+	if seq, err := db.LastChange(); err != nil || seq != 5 {
+		t.Errorf("LastChange() = %d, %v, want 5", seq, err)
+	}
+
+	changes, err = db.Changes(3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, want[1:2]) {
+		t.Errorf("Changes(3, 1) = %+v, want %+v", changes, want[1:2])
+	}
+}
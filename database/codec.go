@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"io/ioutil"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/snappy"
+)
+
+// The package documents, sources, platform variants and API summaries are
+// stored compressed with the codec selected by -db-codec. Values compressed
+// with snappy have no header, as the values stored before the codec could be
+// selected. Values compressed with another codec start with a zero byte
+// followed by the codec ID. A snappy block of a non-empty value never starts
+// with a zero byte, the encoded length of the value.
+
+var codecName = flag.String("db-codec", "snappy", "Compression of the stored package documents: snappy or flate. flate stores smaller documents at a higher CPU cost. Documents stored with either codec are readable; gddo-admin reindex stores all documents with the selected codec.")
+
+const flateCodecID = 'f'
+
+var errUnknownCodec = errors.New("database: unknown codec")
+
+// The package documents are serialised with the encoding selected by
+// -db-doc-encoding before compression. Documents encoded with gob have no
+// header. Documents encoded as a protocol buffer Package message, defined in
+// doc/package.proto, start with a zero byte followed by protoDocID. A gob
+// stream never starts with a zero byte, the length of the first message.
+
+var docEncoding = flag.String("db-doc-encoding", "gob", "Serialisation of the stored package documents: gob or proto. proto documents decode faster and are readable in other languages. Documents stored with either encoding are readable; gddo-admin reindex stores all documents with the selected encoding.")
+
+const protoDocID = 'p'
+
+// checkCodec returns an error if the codec selected by -db-codec is not
+// supported.
+func checkCodec() error {
+	switch *codecName {
+	case "snappy", "flate":
+	default:
+		return errors.New("database: codec not supported: " + *codecName)
+	}
+	switch *docEncoding {
+	case "gob", "proto":
+	default:
+		return errors.New("database: document encoding not supported: " + *docEncoding)
+	}
+	return nil
+}
+
+// encodeValue compresses p with the codec selected by -db-codec.
+func encodeValue(p []byte) []byte {
+	if *codecName != "flate" {
+		return snappy.Encode(nil, p)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(0)
+	buf.WriteByte(flateCodecID)
+	// Writes to a bytes.Buffer do not fail and the level is valid.
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write(p)
+	w.Close()
+	return buf.Bytes()
+}
+
+// decodeValue decompresses p stored with any codec.
+func decodeValue(p []byte) ([]byte, error) {
+	if len(p) == 0 || p[0] != 0 {
+		return snappy.Decode(nil, p)
+	}
+	if len(p) < 2 || p[1] != flateCodecID {
+		return nil, errUnknownCodec
+	}
+	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(p[2:])))
+}
+
+// encodeDoc serialises pdoc with the encoding selected by -db-doc-encoding
+// and compresses it with encodeValue.
+func encodeDoc(pdoc *doc.Package) ([]byte, error) {
+	if *docEncoding == "proto" {
+		return encodeValue(append([]byte{0, protoDocID}, pdoc.MarshalProto()...)), nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(pdoc); err != nil {
+		return nil, err
+	}
+	return encodeValue(buf.Bytes()), nil
+}
+
+// decodeDoc decompresses and deserialises p stored with any codec and
+// encoding.
+func decodeDoc(p []byte, pdoc *doc.Package) error {
+	p, err := decodeValue(p)
+	if err != nil {
+		return err
+	}
+	if len(p) == 0 || p[0] != 0 {
+		return gob.NewDecoder(bytes.NewReader(p)).Decode(pdoc)
+	}
+	if len(p) < 2 || p[1] != protoDocID {
+		return errUnknownCodec
+	}
+	return pdoc.UnmarshalProto(p[2:])
+}
@@ -0,0 +1,93 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/snappy"
+)
+
+func TestCodec(t *testing.T) {
+	defer func(name string) { *codecName = name }(*codecName)
+	value := bytes.Repeat([]byte("package document "), 100)
+
+	*codecName = "snappy"
+	snappyValue := encodeValue(value)
+	if !bytes.Equal(snappyValue, snappy.Encode(nil, value)) {
+		t.Errorf("snappy codec does not store plain snappy blocks")
+	}
+
+	*codecName = "flate"
+	if err := checkCodec(); err != nil {
+		t.Fatal(err)
+	}
+	flateValue := encodeValue(value)
+	if len(flateValue) >= len(snappyValue) {
+		t.Errorf("flate value has %d bytes, want fewer than the %d bytes of the snappy value", len(flateValue), len(snappyValue))
+	}
+
+	// Values stored with either codec are readable with any selected codec.
+	for _, p := range [][]byte{snappyValue, flateValue} {
+		got, err := decodeValue(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, value) {
+			t.Errorf("decodeValue() = %q, want %q", got, value)
+		}
+	}
+
+	if _, err := decodeValue([]byte{0, 'x', 1}); err != errUnknownCodec {
+		t.Errorf("decodeValue() of unknown codec returned %v, want errUnknownCodec", err)
+	}
+	*codecName = "zip"
+	if err := checkCodec(); err == nil {
+		t.Errorf("checkCodec() of unknown codec returned nil error")
+	}
+}
+
+func TestDocEncoding(t *testing.T) {
+	defer func(name string) { *docEncoding = name }(*docEncoding)
+	pdoc := &doc.Package{ImportPath: "example.com/pkg", Name: "pkg", Imports: []string{"fmt"}}
+
+	var values [][]byte
+	for _, name := range []string{"gob", "proto"} {
+		*docEncoding = name
+		if err := checkCodec(); err != nil {
+			t.Fatal(err)
+		}
+		p, err := encodeDoc(pdoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, p)
+	}
+
+	// Documents stored with either encoding are readable with any selected
+	// encoding.
+	for _, p := range values {
+		var got doc.Package
+		if err := decodeDoc(p, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&got, pdoc) {
+			t.Errorf("decodeDoc() = %+v, want %+v", &got, pdoc)
+		}
+	}
+
+	if err := decodeDoc(encodeValue([]byte{0, 'x'}), &doc.Package{}); err != errUnknownCodec {
+		t.Errorf("decodeDoc() of unknown encoding returned %v, want errUnknownCodec", err)
+	}
+	*docEncoding = "json"
+	if err := checkCodec(); err == nil {
+		t.Errorf("checkCodec() of unknown encoding returned nil error")
+	}
+}
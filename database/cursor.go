@@ -0,0 +1,132 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrBadCursor is returned by QueryPage for a cursor not returned by
+// QueryPage.
+var ErrBadCursor = errors.New("database: bad search cursor")
+
+// encodeCursor returns the cursor for the position after qr in the results
+// of a query.
+func encodeCursor(qr *SearchResult) string {
+	fuzzy := "0"
+	if qr.Fuzzy {
+		fuzzy = "1"
+	}
+	s := fuzzy + " " + strconv.FormatFloat(qr.Score, 'g', -1, 64) + " " + qr.Path
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func decodeCursor(cursor string) (*SearchResult, error) {
+	p, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	fields := strings.SplitN(string(p), " ", 3)
+	if len(fields) != 3 || (fields[0] != "0" && fields[0] != "1") || fields[2] == "" {
+		return nil, ErrBadCursor
+	}
+	score, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+	return &SearchResult{Path: fields[2], Score: score, Fuzzy: fields[0] == "1"}, nil
+}
+
+// pageAfter returns up to n of the sorted results after the position encoded
+// in cursor and the cursor for the next page. The cursor for the first page
+// is "". The returned cursor is "" if there are no more results.
+//
+// A cursor encodes the sort key of the last result on a page instead of an
+// offset, so a page does not repeat or skip results when packages are added
+// to or removed from the index between requests.
+func pageAfter(results []*SearchResult, cursor string, n int) ([]*SearchResult, string, error) {
+	start := 0
+	if cursor != "" {
+		last, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(results), func(i int) bool { return last.before(results[i]) })
+	}
+	end := start + n
+	if end >= len(results) {
+		return results[start:], "", nil
+	}
+	return results[start:end], encodeCursor(results[end-1]), nil
+}
+
+// QueryPage returns up to n packages matching the search query q after the
+// position encoded in cursor and the cursor for the next page. The packages
+// not returned by filter are skipped before the page is cut, so the pages
+// before the last have n packages. The filter, if not nil, returns the
+// packages to show in the order given. See Query for the query syntax and
+// pageAfter for the cursors.
+func (db *Database) QueryPage(q, cursor string, n int, filter func([]Package) ([]Package, error)) ([]Package, string, error) {
+	queryResults, err := db.query(q)
+	if err != nil {
+		return nil, "", err
+	}
+	rest, _, err := pageAfter(queryResults, cursor, len(queryResults))
+	if err != nil {
+		return nil, "", err
+	}
+	return filterPage(rest, n, filter)
+}
+
+// filterPage returns up to n of the results kept by filter and the cursor
+// for the next page.
+func filterPage(rest []*SearchResult, n int, filter func([]Package) ([]Package, error)) ([]Package, string, error) {
+	var pkgs []Package
+	for len(rest) > 0 && n > 0 {
+		// Filter more results than needed to fill the page in one pass
+		// when few results are skipped.
+		chunk := rest
+		if len(chunk) > 2*n {
+			chunk = chunk[:2*n]
+		}
+		rest = rest[len(chunk):]
+		candidates := make([]Package, len(chunk))
+		for i, qr := range chunk {
+			candidates[i].Path = qr.Path
+			candidates[i].Synopsis = qr.Synopsis
+		}
+		kept := candidates
+		if filter != nil {
+			var err error
+			kept, err = filter(candidates)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		show := make(map[string]bool, len(kept))
+		for _, pkg := range kept {
+			show[pkg.Path] = true
+		}
+		for i, qr := range chunk {
+			if !show[qr.Path] {
+				continue
+			}
+			pkgs = append(pkgs, Package{Path: qr.Path, Synopsis: qr.Synopsis})
+			if len(pkgs) == n {
+				if i == len(chunk)-1 && len(rest) == 0 {
+					return pkgs, "", nil
+				}
+				return pkgs, encodeCursor(qr), nil
+			}
+		}
+	}
+	return pkgs, "", nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func resultPaths(results []*SearchResult) []string {
+	var paths []string
+	for _, qr := range results {
+		paths = append(paths, qr.Path)
+	}
+	return paths
+}
+
+func TestPageAfter(t *testing.T) {
+	results := []*SearchResult{
+		{Path: "a", Score: 3},
+		{Path: "b", Score: 2},
+		{Path: "c", Score: 2},
+		{Path: "d", Score: 1},
+		{Path: "e", Score: 5, Fuzzy: true},
+	}
+	sort.Sort(byScore(results))
+
+	var pages [][]string
+	cursor := ""
+	for {
+		page, next, err := pageAfter(results, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, resultPaths(page))
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	// Add a package before the cursor and remove the package at the cursor.
+	_, cursor, _ = pageAfter(results, "", 2)
+	updated := []*SearchResult{
+		{Path: "a", Score: 3},
+		{Path: "aa", Score: 2.5},
+		{Path: "c", Score: 2},
+		{Path: "d", Score: 1},
+	}
+	page, _, err := pageAfter(updated, cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := resultPaths(page); !reflect.DeepEqual(paths, []string{"c", "d"}) {
+		t.Errorf("page after update = %v, want [c d]", paths)
+	}
+
+	for _, cursor := range []string{"!", "eA", encodeCursor(&SearchResult{})} {
+		if _, _, err := pageAfter(results, cursor, 2); err != ErrBadCursor {
+			t.Errorf("pageAfter(%q) returned error %v, want ErrBadCursor", cursor, err)
+		}
+	}
+}
+
+func TestFilterPage(t *testing.T) {
+	results := []*SearchResult{
+		{Path: "a", Score: 6},
+		{Path: "x1", Score: 5},
+		{Path: "x2", Score: 4},
+		{Path: "x3", Score: 3},
+		{Path: "b", Score: 2},
+		{Path: "c", Score: 1},
+	}
+	filter := func(pkgs []Package) ([]Package, error) {
+		var kept []Package
+		for _, pkg := range pkgs {
+			if pkg.Path[0] != 'x' {
+				kept = append(kept, pkg)
+			}
+		}
+		return kept, nil
+	}
+
+	var pages [][]string
+	cursor := ""
+	for {
+		rest, _, err := pageAfter(results, cursor, len(results))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs, next, err := filterPage(rest, 2, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, pkg := range pkgs {
+			paths = append(paths, pkg.Path)
+		}
+		pages = append(pages, paths)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	// The skipped results do not shorten the pages.
+	want := [][]string{{"a", "b"}, {"c"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
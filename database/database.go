@@ -12,19 +12,93 @@
 //      terms: space separated search terms
 //      path: import path
 //      synopsis: synopsis
-//      gob: snappy compressed gob encoded doc.Package
+//      gob: compressed gob encoded doc.Package
 //      score: document search score
 //      etag:
 //      kind: p=package, c=command, d=directory with no go files
+//      stars: number of stars of the repository
+//      importers: number of importers at the last daily snapshot
 // index:<term> set: package ids for given search term
+// index:text:<term> set: package ids for given term in doc comments and README files
+// trigram:<trigram> set: search terms of the packages containing the trigram
+// suggest hash: import path -> space separated prefixes in the suggestion index
+// suggest:<prefix> zset: import path, score of packages with a path, name or last path element starting with prefix
+// account:<host>/<account> hash: import path -> stars, Unix time of the last crawl and synopsis of indexed packages in the repositories of the account
+// similar hash: import path -> space separated import paths of the most similar packages
 // index:import:<path> set: packages with import path
 // index:project:<root> set: packages in project with root
+// alias hash maps import path of a package fetched from a non-canonical path to the canonical import path
+// aliases:<path> set: import paths of the copies of the package with the canonical import path
+// moves hash maps old project root of a moved repository to the new project root
+// movequeue set: old project roots of the moves not merged by MergeMoves
+// movedfrom:<path> set: old import paths of the package with the import path in moved repositories
+// majors:<path> set: import paths with a major version suffix of the package with the import path without the suffix
 // block set: packages to block
+// blockpat set: glob patterns of packages to block
+// blockinfo hash maps block set and blockpat set members to JSON encoded BlockEntry
 // popular zset: package id, score
+// access zset: package id, Unix time of the last view of the package or crawl of a package importing the package
+// evicted set: import paths of the packages with the document evicted by EvictColdDocs
+// evictions hash: evicted: number of evicted documents, rebuilt: number of evicted documents saved again
 // popular:0 string: scaled base time for popular scores
 // nextCrawl zset: package id, Unix time for next crawl
 // newCrawl set: new paths to crawl
 // badCrawl set: paths that returned error when crawling.
+// owners hash maps project root to hash of the verified owner's key.
+// quarantine hash maps project root to reason the project was quarantined.
+// feed:new zset: import path, Unix time the package was first saved
+// feed:updated zset: import path, Unix time the package was last updated
+// private set: import paths of packages in private repositories
+// optout set: project roots opted out of indexing by the owner
+// meta:<path> string: gob encoded gosrc.ImportMeta, expires after -db-meta-expire
+// notfound:<path> hash: not found result of a crawl, expires after -db-notfound-ttl
+//      message: message of the gosrc.NotFoundError
+//      redirect: redirect of the gosrc.NotFoundError
+// platform:<path> hash: GOOS/GOARCH -> compressed gob encoded doc.Package variant
+// source:<path> string: compressed gob encoded []*doc.Source
+// api:<path> hash: summaries of the exported API at the last crawl and at the crawl before the API changed
+//      cur: compressed gob encoded []doc.APISymbol
+//      curTime: Unix time of the last crawl
+//      prev: compressed gob encoded []doc.APISymbol
+//      prevTime: Unix time of the last crawl with the previous API
+// tombstone:<path> hash: expires after the grace period
+//      gob: compressed gob encoded doc.Package of deleted package
+//      deleted: Unix time of deletion
+// tombstones zset: deleted import path, Unix time of deletion
+// searchShadow string: name of the copy of the search index being rebuilt
+// versions:<path> zset: version, Unix time the version was saved
+// version:<path>@<version> string: compressed gob encoded doc.Package
+// views:<day> hash: import path, page views on day (YYYYMMDD)
+// importers:<day> hash: import path, importer count on day (YYYYMMDD)
+// hoststats:<hour> hash: "<host> <result>" and "<host> l<latency bucket>", fetches from host in hour (YYYYMMDDHH)
+// apikey:<hash> hash: API key with SHA-256 hash
+//      name: name of the client
+//      quota: requests per day, zero is unlimited
+//      created: Unix time the key was created
+// apiusage:<hash>:<day> string: requests with the API key on day (YYYYMMDD)
+// changes zset: import path, sequence number of the last save or deletion
+// changes:seq string: sequence number of the last change
+// lease:<name> string: holder of the lease on name, expires with the lease; Put fails while a backup holds lease:writes
+// schema string: version of the schema of the stored data, the number of migrations applied by Migrate
+// pausedtasks set: names of the paused background tasks
+// gob:report string: gob encoded Report saved by UpdateReport
+// audit list: JSON encoded AuditEntry of administrative actions, oldest first
+// modimports:<root> hash: import path -> space separated imports of the package and its tests in the module with root
+// deps hash maps module root to JSON encoded DependencyReport
+// settings hash maps project root to JSON encoded ProjectSettings set by the verified owner.
+// reports hash maps "<path> <reason>" to the JSON encoded abuse report of the package for the reason
+// notes hash maps import path to the JSON encoded []Note of the package
+// vulns hash maps module path to the JSON encoded []Vuln of the module replaced by SetVulns
+// updates channel: import paths of the packages saved by Put
+// watches hash maps watch ID to the JSON encoded Watch
+// watchevents list: JSON encoded WatchEvent queued for the watches, oldest first
+// announcements hash maps announcement ID to the JSON encoded Announcement
+// maxAnnouncementId string: last assigned announcement ID
+//
+// All keys are prefixed with the value of the -db-namespace flag.
+// Compressed values are compressed with the codec selected by -db-codec.
+// The doc.Package values described as gob encoded are encoded with the
+// encoding selected by -db-doc-encoding, gob or protocol buffers.
 
 // Package database manages storage for GoPkgDoc.
 package database
@@ -48,13 +122,20 @@ import (
 	"github.com/garyburd/redigo/redis"
 	"github.com/golang/gddo/doc"
 	"github.com/golang/gddo/gosrc"
-	"github.com/golang/snappy"
+	"github.com/golang/gddo/search"
 )
 
 type Database struct {
 	Pool interface {
 		Get() redis.Conn
 	}
+
+	// cache is an optional in-process cache of documents returned by Get.
+	cache *docCache
+
+	// index is the search index used by Query. Nil selects the search
+	// terms stored in Redis.
+	index SearchIndex
 }
 
 type Package struct {
@@ -72,6 +153,12 @@ var (
 	redisServer      = flag.String("db-server", "redis://127.0.0.1:6379", "URI of Redis server.")
 	redisIdleTimeout = flag.Duration("db-idle-timeout", 250*time.Second, "Close Redis connections after remaining idle for this duration.")
 	redisLog         = flag.Bool("db-log", false, "Log database commands")
+	cacheSize        = flag.Int("db-cache-size", 0, "Number of package documents to cache in memory. Zero disables the cache.")
+	cacheMaxAge      = flag.Duration("db-cache-max-age", time.Minute, "Maximum age of a package document in the in-memory cache.")
+	tombstoneTTL     = flag.Duration("db-tombstone-ttl", 30*24*time.Hour, "Keep the documentation of deleted packages for this duration. Zero disables tombstones.")
+	maxVersions      = flag.Int("db-max-versions", 10, "Number of versions of a package document to keep. Zero disables versioned documents.")
+	feedSize         = flag.Int("db-feed-size", 1000, "Number of new and updated packages to keep for feeds.")
+	maxDocSize       = flag.Int("db-max-doc-size", 400000, "Maximum size in bytes of a stored compressed package document. The declarations of larger documents are not stored.")
 )
 
 func dialDb() (c redis.Conn, err error) {
@@ -108,6 +195,10 @@ func dialDb() (c redis.Conn, err error) {
 
 // New creates a database configured from command line flags.
 func New() (*Database, error) {
+	if err := checkCodec(); err != nil {
+		return nil, err
+	}
+
 	pool := &redis.Pool{
 		Dial:        dialDb,
 		MaxIdle:     10,
@@ -120,17 +211,26 @@ func New() (*Database, error) {
 		c.Close()
 	}
 
-	return &Database{Pool: pool}, nil
+	db := &Database{Pool: pool}
+	if *cacheSize > 0 {
+		db.cache = newDocCache(*cacheSize, *cacheMaxAge)
+	}
+	index, err := newSearchIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	db.index = index
+	return db, nil
 }
 
 // Exists returns true if package with import path exists in the database.
 func (db *Database) Exists(path string) (bool, error) {
 	c := db.Pool.Get()
 	defer c.Close()
-	return redis.Bool(c.Do("HEXISTS", "ids", path))
+	return redis.Bool(c.Do("HEXISTS", Key("ids"), path))
 }
 
-var putScript = redis.NewScript(0, `
+var putScript = newScript(`
     local path = ARGV[1]
     local synopsis = ARGV[2]
     local score = ARGV[3]
@@ -139,13 +239,30 @@ var putScript = redis.NewScript(0, `
     local etag = ARGV[6]
     local kind = ARGV[7]
     local nextCrawl = ARGV[8]
+    local updated = ARGV[9]
+    local feedSize = tonumber(ARGV[10])
+    local stars = ARGV[11]
+
+    if redis.call('EXISTS', 'lease:writes') == 1 then
+        return redis.error_reply('writes paused')
+    end
 
     local id = redis.call('HGET', 'ids', path)
-    if not id then
+    local new = not id
+    if new then
         id = redis.call('INCR', 'maxPackageId')
         redis.call('HSET', 'ids', path, id)
     end
 
+    if nextCrawl ~= '0' and kind ~= 'd' and feedSize > 0 then
+        if new then
+            redis.call('ZADD', 'feed:new', updated, path)
+            redis.call('ZREMRANGEBYRANK', 'feed:new', 0, -(feedSize + 1))
+        end
+        redis.call('ZADD', 'feed:updated', updated, path)
+        redis.call('ZREMRANGEBYRANK', 'feed:updated', 0, -(feedSize + 1))
+    end
+
     if etag ~= '' and etag == redis.call('HGET', 'pkg:' .. id, 'clone') then
         terms = ''
         score = 0
@@ -160,9 +277,13 @@ var putScript = redis.NewScript(0, `
         update[term] = (update[term] or 0) + 2
     end
 
+    local unused = {}
     for term, x in pairs(update) do
         if x == 1 then
             redis.call('SREM', 'index:' .. term, id)
+            if redis.call('EXISTS', 'index:' .. term) == 0 then
+                unused[#unused + 1] = term
+            end
         elseif x == 2 then 
             redis.call('SADD', 'index:' .. term, id)
         end
@@ -176,10 +297,11 @@ var putScript = redis.NewScript(0, `
         redis.call('HSET', 'pkg:' .. id, 'crawl', nextCrawl)
     end
 
-    return redis.call('HMSET', 'pkg:' .. id, 'path', path, 'synopsis', synopsis, 'score', score, 'gob', gob, 'terms', terms, 'etag', etag, 'kind', kind)
+    redis.call('HMSET', 'pkg:' .. id, 'path', path, 'synopsis', synopsis, 'score', score, 'gob', gob, 'terms', terms, 'etag', etag, 'kind', kind, 'stars', stars)
+    return unused
 `)
 
-var addCrawlScript = redis.NewScript(0, `
+var addCrawlScript = newScript(`
     for i=1,#ARGV do
         local pkg = ARGV[i]
         if redis.call('HEXISTS', 'ids',  pkg) == 0  and redis.call('SISMEMBER', 'badCrawl', pkg) == 0 then
@@ -198,7 +320,19 @@ func (db *Database) AddNewCrawl(importPath string) error {
 	return err
 }
 
-// Put adds the package documentation to the database.
+// indexTerms returns the terms of the search and importer indexes for the
+// package with the search score.
+func indexTerms(pdoc *doc.Package, score float64) []string {
+	terms := documentTerms(pdoc, score)
+	terms = append(terms, filterTerms(pdoc, score)...)
+	if *fullTextSearch && score > 0 {
+		terms = append(terms, textTerms(pdoc, terms)...)
+	}
+	return terms
+}
+
+// Put adds the package documentation to the database. Put returns
+// ErrWritesPaused while a backup is running.
 func (db *Database) Put(pdoc *doc.Package, nextCrawl time.Time, hide bool) error {
 	c := db.Pool.Get()
 	defer c.Close()
@@ -207,17 +341,36 @@ func (db *Database) Put(pdoc *doc.Package, nextCrawl time.Time, hide bool) error
 	if !hide {
 		score = documentScore(pdoc)
 	}
-	terms := documentTerms(pdoc, score)
+	terms := indexTerms(pdoc, score)
 
-	var gobBuf bytes.Buffer
-	if err := gob.NewEncoder(&gobBuf).Encode(pdoc); err != nil {
-		return err
+	// Store the sources separately from the document. A document read from
+	// the database does not have sources, but the stored sources are kept.
+	sources := pdoc.Sources
+	keepSources := sources == nil && pdoc.SourcesStored
+	if sources != nil {
+		pdocNew := *pdoc
+		pdoc = &pdocNew
+		pdoc.Sources = nil
+		pdoc.SourcesStored = storeSources(sources)
 	}
 
-	gobBytes := snappy.Encode(nil, gobBuf.Bytes())
+	// Store the platform variants separately in the same way.
+	variants := pdoc.Variants
+	keepVariants := variants == nil && len(pdoc.Platforms) > 0
+	if !keepVariants {
+		pdocNew := *pdoc
+		pdoc = &pdocNew
+		pdoc.Variants = nil
+		pdoc.Platforms = platforms(pdoc, variants)
+	}
+
+	gobBytes, err := encodeDoc(pdoc)
+	if err != nil {
+		return err
+	}
 
 	// Truncate large documents.
-	if len(gobBytes) > 400000 {
+	if len(gobBytes) > *maxDocSize {
 		pdocNew := *pdoc
 		pdoc = &pdocNew
 		pdoc.Truncated = true
@@ -226,11 +379,10 @@ func (db *Database) Put(pdoc *doc.Package, nextCrawl time.Time, hide bool) error
 		pdoc.Types = nil
 		pdoc.Consts = nil
 		pdoc.Examples = nil
-		gobBuf.Reset()
-		if err := gob.NewEncoder(&gobBuf).Encode(pdoc); err != nil {
+		gobBytes, err = encodeDoc(pdoc)
+		if err != nil {
 			return err
 		}
-		gobBytes = snappy.Encode(nil, gobBuf.Bytes())
 	}
 
 	kind := "p"
@@ -246,11 +398,128 @@ func (db *Database) Put(pdoc *doc.Package, nextCrawl time.Time, hide bool) error
 		t = nextCrawl.Unix()
 	}
 
-	_, err := putScript.Do(c, pdoc.ImportPath, pdoc.Synopsis, score, gobBytes, strings.Join(terms, " "), pdoc.Etag, kind, t)
+	n := *feedSize
+	if hide {
+		n = 0
+	}
+	unused, err := redis.Strings(putScript.Do(c, pdoc.ImportPath, pdoc.Synopsis, score, gobBytes, strings.Join(terms, " "), pdoc.Etag, kind, t, pdoc.Updated.Unix(), n, pdoc.Stars))
+	db.cache.remove(pdoc.ImportPath)
+	db.cache.removeProject(pdoc.ProjectRoot)
+	if e, ok := err.(redis.Error); ok && e.Error() == "writes paused" {
+		return ErrWritesPaused
+	}
+	if err != nil {
+		return err
+	}
+
+	if *fuzzyDistance > 0 {
+		if err := removeTrigrams(c, unused); err != nil {
+			return err
+		}
+		if err := putTrigrams(c, terms); err != nil {
+			return err
+		}
+	}
+
+	if err := putSuggest(c, pdoc.ImportPath, pdoc.Name, score); err != nil {
+		return err
+	}
+
+	if err := putAccount(c, pdoc, score); err != nil {
+		return err
+	}
+
+	if err := db.searchIndex().Put(pdoc, score); err != nil {
+		return err
+	}
+	if shadow, err := db.shadowSearchIndex(c); err != nil {
+		return err
+	} else if shadow != nil {
+		if err := shadow.Put(pdoc, score); err != nil {
+			return err
+		}
+	}
+
+	if pdoc.Private {
+		_, err = c.Do("SADD", Key("private"), pdoc.ImportPath)
+	} else {
+		_, err = c.Do("SREM", Key("private"), pdoc.ImportPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := putAlias(c, pdoc.ImportPath, pdoc.Canonical); err != nil {
+		return err
+	}
+
+	if err := putMajor(c, pdoc.ImportPath); err != nil {
+		return err
+	}
+
+	if err := putNotes(c, pdoc); err != nil {
+		return err
+	}
+
+	if err := putAccess(c, pdoc, time.Now()); err != nil {
+		return err
+	}
+
+	switch {
+	case keepSources:
+	case pdoc.SourcesStored:
+		err = putSources(c, pdoc.ImportPath, sources)
+	default:
+		_, err = c.Do("DEL", Key("source:"+pdoc.ImportPath))
+	}
 	if err != nil {
 		return err
 	}
 
+	switch {
+	case keepVariants:
+	case len(variants) > 0:
+		err = putVariants(c, pdoc.ImportPath, variants)
+	default:
+		_, err = c.Do("DEL", Key("platform:"+pdoc.ImportPath))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Store the API summary of crawled packages only. A document saved
+	// without a crawl may be decoded from a version of doc.Package without
+	// the summary.
+	if pdoc.Name != "" && !nextCrawl.IsZero() {
+		changed, err := putAPI(c, pdoc.ImportPath, pdoc.API, time.Now())
+		if err != nil {
+			return err
+		}
+		if changed {
+			if err := putWatchEvent(c, WatchEvent{Path: pdoc.ImportPath, Kind: WatchAPIChanged}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if pdoc.Version != "" && *maxVersions > 0 {
+		if _, err := putVersionScript.Do(c, pdoc.ImportPath, pdoc.Version, gobBytes, pdoc.Updated.Unix(), *maxVersions); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.Do("DEL", Key("notfound:"+pdoc.ImportPath)); err != nil {
+		return err
+	}
+
+	if err := putChange(c, pdoc.ImportPath); err != nil {
+		return err
+	}
+
+	if err := publishUpdate(c, pdoc.ImportPath); err != nil {
+		return err
+	}
+
 	if nextCrawl.IsZero() {
 		// Skip crawling related packages if this is not a full save.
 		return nil
@@ -287,7 +556,81 @@ func (db *Database) Put(pdoc *doc.Package, nextCrawl time.Time, hide bool) error
 	return err
 }
 
-var setNextCrawlEtagScript = redis.NewScript(0, `
+var putVersionScript = newScript(`
+    local path = ARGV[1]
+    local version = ARGV[2]
+    local gob = ARGV[3]
+    local t = ARGV[4]
+    local n = tonumber(ARGV[5])
+
+    redis.call('SET', 'version:' .. path .. '@' .. version, gob)
+    redis.call('ZADD', 'versions:' .. path, t, version)
+
+    for _, v in ipairs(redis.call('ZREVRANGE', 'versions:' .. path, n, -1)) do
+        redis.call('DEL', 'version:' .. path .. '@' .. v)
+        redis.call('ZREM', 'versions:' .. path, v)
+    end
+`)
+
+// GetVersion returns the saved documentation for the given import path and
+// version. GetVersion returns nil if the version is not found.
+func (db *Database) GetVersion(path, version string) (*doc.Package, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("GET", Key("version:"+path+"@"+version)))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pdoc doc.Package
+	if err := decodeDoc(p, &pdoc); err != nil {
+		return nil, err
+	}
+	return &pdoc, nil
+}
+
+// Versions returns the saved versions of the given import path, newest first.
+func (db *Database) Versions(path string) ([]string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Strings(c.Do("ZREVRANGE", Key("versions:"+path), 0, -1))
+}
+
+// PutVersion saves the documentation for a version of the package without
+// changing the current documentation of the package, such as a version built
+// on request. The version is saved with the time pdoc.Updated.
+func (db *Database) PutVersion(pdoc *doc.Package) error {
+	if pdoc.Version == "" || *maxVersions <= 0 {
+		return nil
+	}
+	pdocNew := *pdoc
+	pdocNew.Sources = nil
+	pdocNew.SourcesStored = false
+	pdocNew.Variants = nil
+	p, err := encodeDoc(&pdocNew)
+	if err != nil {
+		return err
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err = putVersionScript.Do(c, pdoc.ImportPath, pdoc.Version, p, pdoc.Updated.Unix(), *maxVersions)
+	return err
+}
+
+// VersionAt returns the newest version of the given import path saved at or
+// before time t. VersionAt returns "" if there is no such version.
+func (db *Database) VersionAt(path string, t time.Time) (string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	versions, err := redis.Strings(c.Do("ZREVRANGEBYSCORE", Key("versions:"+path), t.Unix(), "-inf", "LIMIT", 0, 1))
+	if err != nil || len(versions) == 0 {
+		return "", err
+	}
+	return versions[0], nil
+}
+
+var setNextCrawlEtagScript = newScript(`
     local root = ARGV[1]
     local etag = ARGV[2]
     local nextCrawl = ARGV[3]
@@ -307,12 +650,37 @@ func (db *Database) SetNextCrawlEtag(projectRoot string, etag string, t time.Tim
 	c := db.Pool.Get()
 	defer c.Close()
 	_, err := setNextCrawlEtagScript.Do(c, normalizeProjectRoot(projectRoot), etag, t.Unix())
+	db.cache.removeProject(projectRoot)
 	return err
 }
 
+var setNextCrawlScript = newScript(`
+    local path = ARGV[1]
+    local nextCrawl = ARGV[2]
+
+    local id = redis.call('HGET', 'ids', path)
+    if not id then
+        return 0
+    end
+    redis.call('ZADD', 'nextCrawl', nextCrawl, id)
+    redis.call('HSET', 'pkg:' .. id, 'crawl', nextCrawl)
+    return 1
+`)
+
+// SetNextCrawl sets the next crawl time of the package. The crawler crawls
+// the packages in the order of the next crawl times. SetNextCrawl returns
+// false if the package is not in the database.
+func (db *Database) SetNextCrawl(path string, t time.Time) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	ok, err := redis.Bool(setNextCrawlScript.Do(c, path, t.Unix()))
+	db.cache.remove(path)
+	return ok, err
+}
+
 // bumpCrawlScript sets the crawl time to now. To avoid continuously crawling
 // frequently updated repositories, the crawl is scheduled in the future.
-var bumpCrawlScript = redis.NewScript(0, `
+var bumpCrawlScript = newScript(`
     local root = ARGV[1]
     local now = tonumber(ARGV[2])
     local nextCrawl = now + 7200
@@ -339,12 +707,13 @@ func (db *Database) BumpCrawl(projectRoot string) error {
 	c := db.Pool.Get()
 	defer c.Close()
 	_, err := bumpCrawlScript.Do(c, normalizeProjectRoot(projectRoot), time.Now().Unix())
+	db.cache.removeProject(projectRoot)
 	return err
 }
 
 // getDocScript gets the package documentation and update time for the
 // specified path. If path is "-", then the oldest document is returned.
-var getDocScript = redis.NewScript(0, `
+var getDocScript = newScript(`
     local path = ARGV[1]
 
     local id
@@ -392,13 +761,8 @@ func (db *Database) getDoc(c redis.Conn, path string) (*doc.Package, time.Time,
 		return nil, time.Time{}, err
 	}
 
-	p, err = snappy.Decode(nil, p)
-	if err != nil {
-		return nil, time.Time{}, err
-	}
-
 	var pdoc doc.Package
-	if err := gob.NewDecoder(bytes.NewReader(p)).Decode(&pdoc); err != nil {
+	if err := decodeDoc(p, &pdoc); err != nil {
 		return nil, time.Time{}, err
 	}
 
@@ -410,7 +774,7 @@ func (db *Database) getDoc(c redis.Conn, path string) (*doc.Package, time.Time,
 	return &pdoc, nextCrawl, err
 }
 
-var getSubdirsScript = redis.NewScript(0, `
+var getSubdirsScript = newScript(`
     local reply
     for i = 1,#ARGV do
         reply = redis.call('SORT', 'index:project:' .. ARGV[i], 'ALPHA', 'BY', 'pkg:*->path', 'GET', 'pkg:*->path', 'GET', 'pkg:*->synopsis', 'GET', 'pkg:*->kind')
@@ -468,8 +832,13 @@ func (db *Database) getSubdirs(c redis.Conn, path string, pdoc *doc.Package) ([]
 }
 
 // Get gets the package documenation and sub-directories for the the given
-// import path.
+// import path. Documents returned from the in-memory cache are shared between
+// callers and must not be modified.
 func (db *Database) Get(path string) (*doc.Package, []Package, time.Time, error) {
+	if ce, ok := db.cache.get(path); ok {
+		return ce.pdoc, ce.subdirs, ce.nextCrawl, nil
+	}
+
 	c := db.Pool.Get()
 	defer c.Close()
 
@@ -487,6 +856,9 @@ func (db *Database) Get(path string) (*doc.Package, []Package, time.Time, error)
 	if err != nil {
 		return nil, nil, time.Time{}, err
 	}
+	if pdoc != nil && pdoc.ImportPath == path {
+		db.cache.add(pdoc, subdirs, nextCrawl)
+	}
 	return pdoc, subdirs, nextCrawl, nil
 }
 
@@ -496,31 +868,172 @@ func (db *Database) GetDoc(path string) (*doc.Package, time.Time, error) {
 	return db.getDoc(c, path)
 }
 
-var deleteScript = redis.NewScript(0, `
+var deleteScript = newScript(`
     local path = ARGV[1]
+    local ttl = tonumber(ARGV[2])
+    local t = tonumber(ARGV[3])
 
     local id = redis.call('HGET', 'ids', path)
     if not id then
-        return false
+        return {}
+    end
+
+    local gob = redis.call('HGET', 'pkg:' .. id, 'gob')
+    if ttl > 0 and gob then
+        redis.call('DEL', 'tombstone:' .. path)
+        redis.call('HMSET', 'tombstone:' .. path, 'gob', gob, 'deleted', t)
+        redis.call('EXPIRE', 'tombstone:' .. path, ttl)
+        redis.call('ZADD', 'tombstones', t, path)
+        redis.call('ZREMRANGEBYSCORE', 'tombstones', '-inf', t - ttl)
     end
 
+    local unused = {}
     for term in string.gmatch(redis.call('HGET', 'pkg:' .. id, 'terms') or '', '([^ ]+)') do
         redis.call('SREM', 'index:' .. term, id)
+        if redis.call('EXISTS', 'index:' .. term) == 0 then
+            unused[#unused + 1] = term
+        end
     end
 
     redis.call('ZREM', 'nextCrawl', id)
     redis.call('SREM', 'newCrawl', path)
     redis.call('ZREM', 'popular', id)
+    redis.call('ZREM', 'access', id)
+    redis.call('SREM', 'evicted', path)
+    redis.call('SREM', 'private', path)
+    redis.call('ZREM', 'feed:new', path)
+    redis.call('ZREM', 'feed:updated', path)
     redis.call('DEL', 'pkg:' .. id)
-    return redis.call('HDEL', 'ids', path)
+
+    for _, v in ipairs(redis.call('ZRANGE', 'versions:' .. path, 0, -1)) do
+        redis.call('DEL', 'version:' .. path .. '@' .. v)
+    end
+    redis.call('DEL', 'versions:' .. path)
+
+    redis.call('HDEL', 'ids', path)
+    return unused
 `)
 
+// deleteDoc deletes the documentation for path. The last stored document is
+// kept in a tombstone for the -db-tombstone-ttl grace period.
+func (db *Database) deleteDoc(c redis.Conn, path string) error {
+	unused, err := redis.Strings(deleteScript.Do(c, path, int64(*tombstoneTTL/time.Second), time.Now().Unix()))
+	db.cache.remove(path)
+	if err != nil {
+		return err
+	}
+	if *fuzzyDistance > 0 {
+		if err := removeTrigrams(c, unused); err != nil {
+			return err
+		}
+	}
+	if err := putSuggest(c, path, "", 0); err != nil {
+		return err
+	}
+	if err := deleteAccount(c, path); err != nil {
+		return err
+	}
+	if err := putChange(c, path); err != nil {
+		return err
+	}
+	if err := putAlias(c, path, ""); err != nil {
+		return err
+	}
+	if err := deleteMajor(c, path); err != nil {
+		return err
+	}
+	if err := db.searchIndex().Delete(path); err != nil {
+		return err
+	}
+	if shadow, err := db.shadowSearchIndex(c); err != nil {
+		return err
+	} else if shadow != nil {
+		if err := shadow.Delete(path); err != nil {
+			return err
+		}
+	}
+	if _, err := c.Do("HDEL", Key("notes"), path); err != nil {
+		return err
+	}
+	if _, err := c.Do("HDEL", Key("similar"), path); err != nil {
+		return err
+	}
+	_, err = c.Do("DEL", Key("source:"+path), Key("platform:"+path), Key("api:"+path))
+	return err
+}
+
 // Delete deletes the documenation for the given import path.
 func (db *Database) Delete(path string) error {
 	c := db.Pool.Get()
 	defer c.Close()
-	_, err := deleteScript.Do(c, path)
-	return err
+	return db.deleteDoc(c, path)
+}
+
+// Tombstone is a deleted package that can be restored.
+type Tombstone struct {
+	Path    string
+	Deleted time.Time
+}
+
+// Tombstones returns the deleted packages that can be restored, most recently
+// deleted first.
+func (db *Database) Tombstones() ([]Tombstone, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	min := time.Now().Add(-*tombstoneTTL).Unix()
+	values, err := redis.Values(c.Do("ZREVRANGEBYSCORE", Key("tombstones"), "+inf", min, "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	var result []Tombstone
+	for len(values) > 0 {
+		var path string
+		var t int64
+		values, err = redis.Scan(values, &path, &t)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Tombstone{Path: path, Deleted: time.Unix(t, 0).UTC()})
+	}
+	return result, nil
+}
+
+var errBlocked = errors.New("database: path is blocked or quarantined")
+
+// Restore restores the documentation for a deleted package from its
+// tombstone. Restore returns false if there is no tombstone for the path.
+func (db *Database) Restore(path string, nextCrawl time.Time) (bool, error) {
+	if blocked, err := db.IsBlocked(path); err != nil {
+		return false, err
+	} else if blocked {
+		return false, errBlocked
+	}
+	if quarantined, err := db.IsQuarantined(path); err != nil {
+		return false, err
+	} else if quarantined {
+		return false, errBlocked
+	}
+
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("HGET", Key("tombstone:"+path), "gob"))
+	if err == redis.ErrNil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var pdoc doc.Package
+	if err := decodeDoc(p, &pdoc); err != nil {
+		return false, err
+	}
+	if err := db.Put(&pdoc, nextCrawl, false); err != nil {
+		return false, err
+	}
+	if _, err := c.Do("DEL", Key("tombstone:"+path)); err != nil {
+		return false, err
+	}
+	_, err = c.Do("ZREM", Key("tombstones"), path)
+	return true, err
 }
 
 func packages(reply interface{}, all bool) ([]Package, error) {
@@ -550,7 +1063,7 @@ func packages(reply interface{}, all bool) ([]Package, error) {
 func (db *Database) getPackages(key string, all bool) ([]Package, error) {
 	c := db.Pool.Get()
 	defer c.Close()
-	reply, err := c.Do("SORT", key, "ALPHA", "BY", "pkg:*->path", "GET", "pkg:*->path", "GET", "pkg:*->synopsis", "GET", "pkg:*->kind")
+	reply, err := c.Do("SORT", Key(key), "ALPHA", "BY", Key("pkg:*->path"), "GET", Key("pkg:*->path"), "GET", Key("pkg:*->synopsis"), "GET", Key("pkg:*->kind"))
 	if err != nil {
 		return nil, err
 	}
@@ -576,7 +1089,7 @@ func (db *Database) Project(projectRoot string) ([]Package, error) {
 func (db *Database) AllPackages() ([]Package, error) {
 	c := db.Pool.Get()
 	defer c.Close()
-	values, err := redis.Values(c.Do("SORT", "nextCrawl", "DESC", "BY", "pkg:*->score", "GET", "pkg:*->path", "GET", "pkg:*->kind"))
+	values, err := redis.Values(c.Do("SORT", Key("nextCrawl"), "DESC", "BY", Key("pkg:*->score"), "GET", Key("pkg:*->path"), "GET", Key("pkg:*->kind")))
 	if err != nil {
 		return nil, err
 	}
@@ -596,7 +1109,7 @@ func (db *Database) AllPackages() ([]Package, error) {
 	return result, nil
 }
 
-var packagesScript = redis.NewScript(0, `
+var packagesScript = newScript(`
     local result = {}
     for i = 1,#ARGV do
         local path = ARGV[i]
@@ -630,29 +1143,188 @@ func (db *Database) Packages(paths []string) ([]Package, error) {
 	return pkgs, err
 }
 
-func (db *Database) ImporterCount(path string) (int, error) {
+// FilterPrivate returns pkgs with the packages in private repositories
+// removed.
+func (db *Database) FilterPrivate(pkgs []Package) ([]Package, error) {
+	if len(pkgs) == 0 {
+		return pkgs, nil
+	}
 	c := db.Pool.Get()
 	defer c.Close()
-	return redis.Int(c.Do("SCARD", "index:import:"+path))
+	for _, pkg := range pkgs {
+		c.Send("SISMEMBER", Key("private"), pkg.Path)
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	result := make([]Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		private, err := redis.Bool(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if !private {
+			result = append(result, pkg)
+		}
+	}
+	return result, nil
 }
 
-func (db *Database) Importers(path string) ([]Package, error) {
-	return db.getPackages("index:import:"+path, false)
+// FeedEntry is a package in a feed of new or updated packages.
+type FeedEntry struct {
+	Package
+	Updated time.Time
 }
 
-func (db *Database) Block(root string) error {
+var feedScript = newScript(`
+    local key = 'feed:new'
+    if ARGV[1] == 'updated' then
+        key = 'feed:updated'
+    end
+    local prefix = ARGV[2]
+    local n = tonumber(ARGV[3])
+
+    local result = {}
+    local entries = redis.call('ZREVRANGE', key, 0, -1, 'WITHSCORES')
+    for i = 1, #entries, 2 do
+        local path = entries[i]
+        if prefix == '' or path == prefix or string.sub(path, 1, #prefix + 1) == prefix .. '/' then
+            local id = redis.call('HGET', 'ids', path)
+            if id then
+                table.insert(result, path)
+                table.insert(result, redis.call('HGET', 'pkg:' .. id, 'synopsis') or '')
+                table.insert(result, entries[i + 1])
+                if #result >= n * 3 then
+                    break
+                end
+            end
+        end
+    end
+    return result
+`)
+
+func (db *Database) feed(kind, prefix string, n int) ([]FeedEntry, error) {
 	c := db.Pool.Get()
 	defer c.Close()
-	if _, err := c.Do("SADD", "block", root); err != nil {
-		return err
+	values, err := redis.Values(feedScript.Do(c, kind, prefix, n))
+	if err != nil {
+		return nil, err
+	}
+	var result []FeedEntry
+	for len(values) > 0 {
+		var e FeedEntry
+		var t int64
+		values, err = redis.Scan(values, &e.Path, &e.Synopsis, &t)
+		if err != nil {
+			return nil, err
+		}
+		e.Updated = time.Unix(t, 0).UTC()
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// NewPackages returns the n packages most recently added to the database.
+func (db *Database) NewPackages(n int) ([]FeedEntry, error) {
+	return db.feed("new", "", n)
+}
+
+// UpdatedPackages returns the n most recently updated packages with the
+// given import path or with import paths below prefix. All packages are
+// returned if prefix is "".
+func (db *Database) UpdatedPackages(prefix string, n int) ([]FeedEntry, error) {
+	return db.feed("updated", prefix, n)
+}
+
+// ImportsOf returns the packages imported by the package with the given
+// import path. The imports are read from the search index and do not include
+// test imports or imports with invalid paths.
+func (db *Database) ImportsOf(path string) ([]Package, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	r, err := redis.Values(importGraphScript.Do(c, path))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var synopsis, terms string
+	if _, err := redis.Scan(r, &synopsis, &terms); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, term := range strings.Fields(terms) {
+		if strings.HasPrefix(term, "import:") {
+			paths = append(paths, term[len("import:"):])
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return db.Packages(paths)
+}
+
+// maxDependents is the maximum number of packages returned by
+// ReverseDependencyClosure.
+const maxDependents = 1000
+
+// ReverseDependencyClosure returns the packages that import the package with
+// the given import path directly or through a chain of at most depth-1 other
+// packages. The result is sorted by path and is limited to maxDependents
+// packages.
+func (db *Database) ReverseDependencyClosure(path string, depth int) ([]Package, error) {
+
+	// This breadth-first traversal sends one query per package in the current
+	// level of the traversal and reads the replies in a batch.
+
+	c := db.Pool.Get()
+	defer c.Close()
+
+	seen := map[string]bool{path: true}
+	frontier := []string{path}
+	var result []Package
+
+	for d := 0; d < depth && len(frontier) > 0 && len(result) < maxDependents; d++ {
+		for _, p := range frontier {
+			c.Send("SORT", Key("index:import:"+p), "BY", "nosort", "GET", Key("pkg:*->path"), "GET", Key("pkg:*->synopsis"), "GET", Key("pkg:*->kind"))
+		}
+		if err := c.Flush(); err != nil {
+			return nil, err
+		}
+		var next []string
+		for range frontier {
+			reply, err := c.Receive()
+			if err != nil {
+				return nil, err
+			}
+			pkgs, err := packages(reply, false)
+			if err != nil {
+				return nil, err
+			}
+			for _, pkg := range pkgs {
+				if seen[pkg.Path] || len(result) >= maxDependents {
+					continue
+				}
+				seen[pkg.Path] = true
+				result = append(result, pkg)
+				next = append(next, pkg.Path)
+			}
+		}
+		frontier = next
 	}
-	keys, err := redis.Strings(c.Do("HKEYS", "ids"))
+	sort.Sort(byPath(result))
+	return result, nil
+}
+
+// deleteTree deletes the documentation for root and the packages below root.
+func (db *Database) deleteTree(c redis.Conn, root string) error {
+	keys, err := redis.Strings(c.Do("HKEYS", Key("ids")))
 	if err != nil {
 		return err
 	}
 	for _, key := range keys {
 		if key == root || strings.HasPrefix(key, root) && key[len(root)] == '/' {
-			if _, err := deleteScript.Do(c, key); err != nil {
+			if err := db.deleteDoc(c, key); err != nil {
 				return err
 			}
 		}
@@ -660,11 +1332,91 @@ func (db *Database) Block(root string) error {
 	return nil
 }
 
-var isBlockedScript = redis.NewScript(0, `
+// SetOwner records the hash of the owner's key for the project with the given
+// root. An empty hash removes the owner.
+func (db *Database) SetOwner(projectRoot string, keyHash string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	var err error
+	if keyHash == "" {
+		_, err = c.Do("HDEL", Key("owners"), normalizeProjectRoot(projectRoot))
+	} else {
+		_, err = c.Do("HSET", Key("owners"), normalizeProjectRoot(projectRoot), keyHash)
+	}
+	return err
+}
+
+// Owner returns the hash of the verified owner's key for the project with the
+// given root or "" if the project does not have a verified owner.
+func (db *Database) Owner(projectRoot string) (string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	keyHash, err := redis.String(c.Do("HGET", Key("owners"), normalizeProjectRoot(projectRoot)))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return keyHash, err
+}
+
+// Quarantine records that a scanner found a threat in the project with the
+// given root and deletes the documentation for the project. Quarantined
+// projects are not crawled until released.
+func (db *Database) Quarantine(root, reason string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	if _, err := c.Do("HSET", Key("quarantine"), root, reason); err != nil {
+		return err
+	}
+	return db.deleteTree(c, root)
+}
+
+// ReleaseQuarantine removes the project with the given root from quarantine.
+func (db *Database) ReleaseQuarantine(root string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("HDEL", Key("quarantine"), root)
+	return err
+}
+
+// Quarantined returns the roots of the quarantined projects and the reasons
+// the projects were quarantined.
+func (db *Database) Quarantined() (map[string]string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.StringMap(c.Do("HGETALL", Key("quarantine")))
+}
+
+var isQuarantinedScript = newScript(`
+    local path = ''
+    for s in string.gmatch(ARGV[1], '[^/]+') do
+        path = path .. s
+        if redis.call('HEXISTS', 'quarantine', path) == 1 then
+            return 1
+        end
+        path = path .. '/'
+    end
+    return  0
+`)
+
+// SetOptOut records whether the owner of the project with the given root
+// opted out of indexing.
+func (db *Database) SetOptOut(root string, optOut bool) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	var err error
+	if optOut {
+		_, err = c.Do("SADD", Key("optout"), normalizeProjectRoot(root))
+	} else {
+		_, err = c.Do("SREM", Key("optout"), normalizeProjectRoot(root))
+	}
+	return err
+}
+
+var isOptedOutScript = newScript(`
     local path = ''
     for s in string.gmatch(ARGV[1], '[^/]+') do
         path = path .. s
-        if redis.call('SISMEMBER', 'block', path) == 1 then
+        if redis.call('SISMEMBER', 'optout', path) == 1 then
             return 1
         end
         path = path .. '/'
@@ -672,59 +1424,58 @@ var isBlockedScript = redis.NewScript(0, `
     return  0
 `)
 
-func (db *Database) IsBlocked(path string) (bool, error) {
+// IsOptedOut returns true if path is in a project opted out of indexing.
+func (db *Database) IsOptedOut(path string) (bool, error) {
 	c := db.Pool.Get()
 	defer c.Close()
-	return redis.Bool(isBlockedScript.Do(c, path))
+	return redis.Bool(isOptedOutScript.Do(c, path))
 }
 
-type queryResult struct {
-	Path     string
-	Synopsis string
-	Score    float64
+// IsQuarantined returns true if path is in a quarantined project.
+func (db *Database) IsQuarantined(path string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Bool(isQuarantinedScript.Do(c, path))
 }
 
-type byScore []*queryResult
+// byScore sorts query results by decreasing score. Results found by fuzzy
+// matching are sorted after the other results. Results with the same score
+// are sorted by path.
+type byScore []*SearchResult
 
 func (p byScore) Len() int           { return len(p) }
-func (p byScore) Less(i, j int) bool { return p[j].Score < p[i].Score }
+func (p byScore) Less(i, j int) bool { return p[i].before(p[j]) }
 func (p byScore) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+// Query returns the packages matching the search query q. The syntax of the
+// query is defined by package search.
 func (db *Database) Query(q string) ([]Package, error) {
-	terms := parseQuery(q)
-	if len(terms) == 0 {
-		return nil, nil
-	}
-	c := db.Pool.Get()
-	defer c.Close()
-	n, err := redis.Int(c.Do("INCR", "maxQueryId"))
+	queryResults, err := db.query(q)
 	if err != nil {
 		return nil, err
 	}
-	id := "tmp:query-" + strconv.Itoa(n)
-
-	args := []interface{}{id}
-	for _, term := range terms {
-		args = append(args, "index:"+term)
-	}
-	c.Send("SINTERSTORE", args...)
-	c.Send("SORT", id, "DESC", "BY", "nosort", "GET", "pkg:*->path", "GET", "pkg:*->synopsis", "GET", "pkg:*->score")
-	c.Send("DEL", id)
-	c.Flush()
-	c.Receive()                              // SINTERSTORE
-	values, err := redis.Values(c.Receive()) // SORT
-	if err != nil {
-		return nil, err
+	pkgs := make([]Package, len(queryResults))
+	for i, qr := range queryResults {
+		pkgs[i].Path = qr.Path
+		pkgs[i].Synopsis = qr.Synopsis
 	}
-	c.Receive() // DEL
+	return pkgs, nil
+}
 
-	var queryResults []*queryResult
-	if err := redis.ScanSlice(values, &queryResults, "Path", "Synopsis", "Score"); err != nil {
+// query returns the results of the search query q sorted with byScore.
+func (db *Database) query(q string) ([]*SearchResult, error) {
+	queryResults, err := db.searchIndex().Query(q)
+	if err != nil || len(queryResults) == 0 {
 		return nil, err
 	}
 
+	// Adjust the scores of the search index with the importer counts and the
+	// standard packages in the database.
+	q = search.Parse(q).Text
+	c := db.Pool.Get()
+	defer c.Close()
 	for _, qr := range queryResults {
-		c.Send("SCARD", "index:import:"+qr.Path)
+		c.Send("SCARD", Key("index:import:"+qr.Path))
 	}
 	c.Flush()
 
@@ -736,7 +1487,7 @@ func (db *Database) Query(q string) ([]Package, error) {
 
 		qr.Score *= math.Log(float64(10 + importCount))
 
-		if isStandardPackage(qr.Path) {
+		if isStandardPackage(qr.Path) && q != "" {
 			if strings.HasSuffix(qr.Path, q) {
 				// Big bump for exact match on standard package name.
 				qr.Score *= 10000
@@ -751,14 +1502,112 @@ func (db *Database) Query(q string) ([]Package, error) {
 	}
 
 	sort.Sort(byScore(queryResults))
+	return queryResults, nil
+}
 
-	pkgs := make([]Package, len(queryResults))
-	for i, qr := range queryResults {
-		pkgs[i].Path = qr.Path
-		pkgs[i].Synopsis = qr.Synopsis
+// Query returns the results of the search query q using the search terms
+// stored in Redis by Put.
+func (idx redisIndex) Query(q string) ([]*SearchResult, error) {
+	db := idx.db
+	query := search.Parse(q)
+	q = query.Text
+	terms := parseQuery(q)
+	var filters []string
+	for _, f := range query.Filters {
+		filters = append(filters, Key("index:"+queryFilterTerm(f)))
+	}
+	if len(terms) == 0 && len(filters) == 0 {
+		return nil, nil
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	n, err := redis.Int(c.Do("INCR", Key("maxQueryId")))
+	if err != nil {
+		return nil, err
 	}
+	id := Key("tmp:query-" + strconv.Itoa(n))
+	defer c.Do("DEL", id)
 
-	return pkgs, nil
+	args := []interface{}{id}
+	for _, term := range terms {
+		args = append(args, Key("index:"+term))
+	}
+	for _, f := range filters {
+		args = append(args, f)
+	}
+	c.Send("SINTERSTORE", args...)
+	c.Send("SORT", id, "DESC", "BY", "nosort", "GET", Key("pkg:*->path"), "GET", Key("pkg:*->synopsis"), "GET", Key("pkg:*->score"))
+	c.Flush()
+	c.Receive()                              // SINTERSTORE
+	values, err := redis.Values(c.Receive()) // SORT
+	if err != nil {
+		return nil, err
+	}
+
+	var queryResults []*SearchResult
+	if err := redis.ScanSlice(values, &queryResults, "Path", "Synopsis", "Score"); err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		// The import sets used by the imports filter include packages that
+		// are not in the index.
+		indexed := queryResults[:0]
+		for _, qr := range queryResults {
+			if qr.Score > 0 {
+				indexed = append(indexed, qr)
+			}
+		}
+		queryResults = indexed
+	}
+
+	if *fullTextSearch && len(terms) > 0 {
+		// Find the packages matching each term with a term from the doc
+		// comments or README file and not found above.
+		textID := id + "-text"
+		textArgs := []interface{}{textID}
+		for i, term := range terms {
+			termID := textID + "-" + strconv.Itoa(i)
+			c.Send("SUNIONSTORE", termID, Key("index:"+term), Key("index:"+textTermPrefix+term))
+			textArgs = append(textArgs, termID)
+		}
+		tempKeys := textArgs
+		for _, f := range filters {
+			textArgs = append(textArgs, f)
+		}
+		c.Send("SINTERSTORE", textArgs...)
+		c.Send("SDIFFSTORE", textID, textID, id)
+		c.Send("SORT", textID, "DESC", "BY", "nosort", "GET", Key("pkg:*->path"), "GET", Key("pkg:*->synopsis"), "GET", Key("pkg:*->score"))
+		c.Send("DEL", tempKeys...)
+		c.Flush()
+		for range terms {
+			c.Receive() // SUNIONSTORE
+		}
+		c.Receive()                             // SINTERSTORE
+		c.Receive()                             // SDIFFSTORE
+		values, err = redis.Values(c.Receive()) // SORT
+		c.Receive()                             // DEL
+		if err != nil {
+			return nil, err
+		}
+		var textResults []*SearchResult
+		if err := redis.ScanSlice(values, &textResults, "Path", "Synopsis", "Score"); err != nil {
+			return nil, err
+		}
+		for _, qr := range textResults {
+			qr.Score *= textScore
+		}
+		queryResults = append(queryResults, textResults...)
+	}
+
+	if *fuzzyDistance > 0 && len(terms) > 0 && len(queryResults) < fuzzyMinResults {
+		fuzzyResults, err := fuzzyQuery(c, id, terms, filters, queryResults)
+		if err != nil {
+			return nil, err
+		}
+		queryResults = append(queryResults, fuzzyResults...)
+	}
+
+	return queryResults, nil
 }
 
 type PackageInfo struct {
@@ -773,7 +1622,7 @@ func (db *Database) Do(f func(*PackageInfo) error) error {
 	c := db.Pool.Get()
 	defer c.Close()
 	cursor := 0
-	c.Send("SCAN", cursor, "MATCH", "pkg:*")
+	c.Send("SCAN", cursor, "MATCH", Key("pkg:*"))
 	c.Flush()
 	for {
 		// Recieve previous SCAN.
@@ -785,13 +1634,12 @@ func (db *Database) Do(f func(*PackageInfo) error) error {
 		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
 			return err
 		}
-		if cursor == 0 {
-			break
-		}
 		for _, key := range keys {
 			c.Send("HMGET", key, "gob", "score", "kind", "path", "terms", "synopis")
 		}
-		c.Send("SCAN", cursor, "MATCH", "pkg:*")
+		if cursor != 0 {
+			c.Send("SCAN", cursor, "MATCH", Key("pkg:*"))
+		}
 		c.Flush()
 		for _ = range keys {
 			values, err := redis.Values(c.Receive())
@@ -817,23 +1665,22 @@ func (db *Database) Do(f func(*PackageInfo) error) error {
 
 			pi.Size = len(path) + len(p) + len(terms) + len(synopsis)
 
-			p, err = snappy.Decode(nil, p)
-			if err != nil {
-				return fmt.Errorf("snappy decoding %s: %v", path, err)
-			}
-
-			if err := gob.NewDecoder(bytes.NewReader(p)).Decode(&pi.PDoc); err != nil {
-				return fmt.Errorf("gob decoding %s: %v", path, err)
+			pi.PDoc = new(doc.Package)
+			if err := decodeDoc(p, pi.PDoc); err != nil {
+				return fmt.Errorf("decoding %s: %v", path, err)
 			}
 			if err := f(&pi); err != nil {
 				return fmt.Errorf("func %s: %v", path, err)
 			}
 		}
+		if cursor == 0 {
+			break
+		}
 	}
 	return nil
 }
 
-var importGraphScript = redis.NewScript(0, `
+var importGraphScript = newScript(`
     local path = ARGV[1]
 
     local id = redis.call('HGET', 'ids', path)
@@ -920,14 +1767,14 @@ func (db *Database) PutGob(key string, value interface{}) error {
 	}
 	c := db.Pool.Get()
 	defer c.Close()
-	_, err := c.Do("SET", "gob:"+key, buf.Bytes())
+	_, err := c.Do("SET", Key("gob:"+key), buf.Bytes())
 	return err
 }
 
 func (db *Database) GetGob(key string, value interface{}) error {
 	c := db.Pool.Get()
 	defer c.Close()
-	p, err := redis.Bytes(c.Do("GET", "gob:"+key))
+	p, err := redis.Bytes(c.Do("GET", Key("gob:"+key)))
 	if err == redis.ErrNil {
 		return nil
 	} else if err != nil {
@@ -936,15 +1783,17 @@ func (db *Database) GetGob(key string, value interface{}) error {
 	return gob.NewDecoder(bytes.NewReader(p)).Decode(value)
 }
 
-var incrementPopularScoreScript = redis.NewScript(0, `
+var incrementPopularScoreScript = newScript(`
     local path = ARGV[1]
     local n = ARGV[2]
     local t = ARGV[3]
+    local now = ARGV[4]
 
     local id = redis.call('HGET', 'ids', path)
     if not id then
         return
     end
+    redis.call('ZADD', 'access', now, id)
 
     local t0 = redis.call('GET', 'popular:0') or '0'
     local f = math.exp(tonumber(t) - tonumber(t0))
@@ -965,7 +1814,7 @@ func (db *Database) incrementPopularScoreInternal(path string, delta float64, t
 	defer c.Close()
 	const lambda = math.Ln2 / float64(popularHalfLife)
 	scaledTime := lambda * float64(t.Sub(time.Unix(1257894000, 0)))
-	_, err := incrementPopularScoreScript.Do(c, path, delta, scaledTime)
+	_, err := incrementPopularScoreScript.Do(c, path, delta, scaledTime, t.Unix())
 	return err
 }
 
@@ -973,7 +1822,7 @@ func (db *Database) IncrementPopularScore(path string) error {
 	return db.incrementPopularScoreInternal(path, 1, time.Now())
 }
 
-var popularScript = redis.NewScript(0, `
+var popularScript = newScript(`
     local stop = ARGV[1]
     local ids = redis.call('ZREVRANGE', 'popular', '0', stop)
     local result = {}
@@ -997,7 +1846,7 @@ func (db *Database) Popular(count int) ([]Package, error) {
 	return pkgs, err
 }
 
-var popularWithScoreScript = redis.NewScript(0, `
+var popularWithScoreScript = newScript(`
     local ids = redis.call('ZREVRANGE', 'popular', '0', -1, 'WITHSCORES')
     local result = {}
     for i=1,#ids,2 do
@@ -1025,7 +1874,7 @@ func (db *Database) PopNewCrawl() (string, bool, error) {
 
 	var subdirs []Package
 
-	path, err := redis.String(c.Do("SPOP", "newCrawl"))
+	path, err := redis.String(c.Do("SPOP", Key("newCrawl")))
 	switch {
 	case err == redis.ErrNil:
 		err = nil
@@ -1039,11 +1888,11 @@ func (db *Database) PopNewCrawl() (string, bool, error) {
 func (db *Database) AddBadCrawl(path string) error {
 	c := db.Pool.Get()
 	defer c.Close()
-	_, err := c.Do("SADD", "badCrawl", path)
+	_, err := c.Do("SADD", Key("badCrawl"), path)
 	return err
 }
 
-var incrementCounterScript = redis.NewScript(0, `
+var incrementCounterScript = newScript(`
     local key = 'counter:' .. ARGV[1]
     local n = tonumber(ARGV[2])
     local t = tonumber(ARGV[3])
@@ -1075,3 +1924,11 @@ func (db *Database) incrementCounterInternal(key string, delta float64, t time.T
 func (db *Database) IncrementCounter(key string, delta float64) (float64, error) {
 	return db.incrementCounterInternal(key, delta, time.Now())
 }
+
+// Ping checks the connection to the Redis server.
+func (db *Database) Ping() error {
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("PING")
+	return err
+}
@@ -92,6 +92,18 @@ func TestPutGet(t *testing.T) {
 		t.Errorf("actualCrawl=%v, expect value between %v and %v", actualCrawl.Unix(), before, after)
 	}
 
+	ok, err := db.SetNextCrawl(pdoc.ImportPath, time.Unix(1, 0))
+	if !ok || err != nil {
+		t.Errorf("db.SetNextCrawl() returned %v, %v", ok, err)
+	}
+	_, _, actualCrawl, _ = db.Get("github.com/user/repo/foo/bar")
+	if actualCrawl.Unix() != 1 {
+		t.Errorf("actualCrawl=%v after SetNextCrawl, want 1", actualCrawl.Unix())
+	}
+	if ok, err := db.SetNextCrawl("github.com/user/repo/missing", time.Now()); ok || err != nil {
+		t.Errorf("db.SetNextCrawl(missing) returned %v, %v", ok, err)
+	}
+
 	// Popular
 
 	if err := db.IncrementPopularScore(pdoc.ImportPath); err != nil {
@@ -140,6 +152,21 @@ func TestPutGet(t *testing.T) {
 	if !reflect.DeepEqual(actualImports, expectedImports) {
 		t.Errorf("db.Imports() = %v, want %v", actualImports, expectedImports)
 	}
+	actualImportsOf, err := db.ImportsOf("github.com/user/repo/foo/bar")
+	if err != nil {
+		t.Fatalf("db.ImportsOf() returned error %v", err)
+	}
+	expectedImportsOf := []Package{{"errors", ""}, {"github.com/user/repo/foo/bar", "hello"}}
+	if !reflect.DeepEqual(actualImportsOf, expectedImportsOf) {
+		t.Errorf("db.ImportsOf() = %v, want %v", actualImportsOf, expectedImportsOf)
+	}
+	actualDependents, err := db.ReverseDependencyClosure("github.com/user/repo/foo/bar", 3)
+	if err != nil {
+		t.Fatalf("db.ReverseDependencyClosure() returned error %v", err)
+	}
+	if len(actualDependents) != 0 {
+		t.Errorf("db.ReverseDependencyClosure() = %v, want none", actualDependents)
+	}
 	importerCount, _ := db.ImporterCount("github.com/user/repo/foo/bar")
 	if importerCount != 1 {
 		t.Errorf("db.ImporterCount() = %d, want %d", importerCount, 1)
@@ -148,6 +175,21 @@ func TestPutGet(t *testing.T) {
 		t.Errorf("db.Delete() returned error %v", err)
 	}
 
+	tombstones, err := db.Tombstones()
+	if err != nil || len(tombstones) != 1 || tombstones[0].Path != "github.com/user/repo/foo/bar" {
+		t.Errorf("db.Tombstones() returned %v, %v, want github.com/user/repo/foo/bar", tombstones, err)
+	}
+	if ok, err := db.Restore("github.com/user/repo/foo/bar", time.Time{}); !ok || err != nil {
+		t.Errorf("db.Restore() returned %v, %v, want true, nil", ok, err)
+	}
+	actualPdoc, _, _, err = db.Get("github.com/user/repo/foo/bar")
+	if err != nil || actualPdoc == nil {
+		t.Errorf("db.Get() after db.Restore() returned %v, %v, want document", actualPdoc, err)
+	}
+	if err := db.Delete("github.com/user/repo/foo/bar"); err != nil {
+		t.Errorf("db.Delete() returned error %v", err)
+	}
+
 	db.Query("bar")
 
 	if err := db.Put(pdoc, time.Time{}, false); err != nil {
@@ -175,6 +217,8 @@ func TestPutGet(t *testing.T) {
 	c.Send("DEL", "block")
 	c.Send("DEL", "popular:0")
 	c.Send("DEL", "newCrawl")
+	c.Send("DEL", "tombstones")
+	c.Send("DEL", "tombstone:github.com/user/repo/foo/bar")
 	keys, err := redis.Values(c.Do("HKEYS", "ids"))
 	for _, key := range keys {
 		t.Errorf("unexpected id %s", key)
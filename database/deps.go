@@ -0,0 +1,152 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+// DependencyReport compares the requirements in the go.mod file of a module
+// with the imports of the packages in the module. The report is updated when
+// a package in the module is crawled, so the report reflects the packages
+// crawled so far.
+type DependencyReport struct {
+	// Module is the module path declared in the go.mod file.
+	Module string `json:"module"`
+
+	// Unused are the required modules that no package in the module
+	// imports.
+	Unused []string `json:"unused,omitempty"`
+
+	// Unlisted are the imported paths that are not in the standard library,
+	// the module or a required module.
+	Unlisted []string `json:"unlisted,omitempty"`
+
+	// Packages is the number of packages in the module compared.
+	Packages int `json:"packages"`
+
+	// Updated is the time of the last update of the report.
+	Updated time.Time `json:"updated"`
+}
+
+// inModule returns true if the package with the import path is the module
+// with the path or a package in the module.
+func inModule(importPath, modulePath string) bool {
+	return importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/")
+}
+
+// compareDependencies returns the report of the module with the imports of
+// the packages in the module keyed by the import path of the packages.
+func compareDependencies(m *doc.Module, imports map[string][]string) *DependencyReport {
+	r := &DependencyReport{Module: m.Path, Packages: len(imports)}
+	if r.Module == "" {
+		r.Module = m.Root
+	}
+	used := make(map[string]bool)
+	unlisted := make(map[string]bool)
+	for _, paths := range imports {
+		for _, p := range paths {
+			if isStandardPackage(p) || p == "C" || inModule(p, r.Module) || inModule(p, m.Root) {
+				continue
+			}
+			// The longest requirement provides the package of nested
+			// modules, for example example.com/a/b in example.com/a.
+			best := ""
+			for _, req := range m.Requires {
+				if inModule(p, req.Path) && len(req.Path) > len(best) {
+					best = req.Path
+				}
+			}
+			if best != "" {
+				used[best] = true
+			} else {
+				unlisted[p] = true
+			}
+		}
+	}
+	for _, req := range m.Requires {
+		if !used[req.Path] {
+			r.Unused = append(r.Unused, req.Path)
+		}
+	}
+	for p := range unlisted {
+		r.Unlisted = append(r.Unlisted, p)
+	}
+	sort.Strings(r.Unused)
+	sort.Strings(r.Unlisted)
+	return r
+}
+
+// UpdateDependencyReport records the imports of the package in the module
+// and updates the dependency report of the module. The imports are the
+// imports of the package and of its tests.
+func (db *Database) UpdateDependencyReport(m *doc.Module, pdoc *doc.Package) error {
+	c := db.Pool.Get()
+	defer c.Close()
+
+	var paths []string
+	for _, imports := range [][]string{pdoc.Imports, pdoc.TestImports, pdoc.XTestImports} {
+		paths = append(paths, imports...)
+	}
+	key := Key("modimports:" + m.Root)
+	if _, err := c.Do("HSET", key, pdoc.ImportPath, strings.Join(paths, " ")); err != nil {
+		return err
+	}
+	values, err := redis.StringMap(c.Do("HGETALL", key))
+	if err != nil {
+		return err
+	}
+
+	imports := make(map[string][]string)
+	for path, s := range values {
+		// Remove the packages deleted since they were recorded.
+		ok, err := redis.Bool(c.Do("HEXISTS", Key("ids"), path))
+		if err != nil {
+			return err
+		}
+		if !ok && path != pdoc.ImportPath {
+			if _, err := c.Do("HDEL", key, path); err != nil {
+				return err
+			}
+			continue
+		}
+		imports[path] = strings.Fields(s)
+	}
+
+	r := compareDependencies(m, imports)
+	r.Updated = time.Now().UTC()
+	p, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("HSET", Key("deps"), m.Root, p)
+	return err
+}
+
+// DependencyReport returns the dependency report of the module with the root
+// or nil if the module has no report.
+func (db *Database) DependencyReport(moduleRoot string) (*DependencyReport, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("HGET", Key("deps"), moduleRoot))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var r DependencyReport
+	if err := json.Unmarshal(p, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
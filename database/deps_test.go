@@ -0,0 +1,39 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestCompareDependencies(t *testing.T) {
+	m := &doc.Module{
+		Path: "example.com/m/v2",
+		Root: "example.com/m",
+		Requires: []doc.Requirement{
+			{Path: "example.com/a", Version: "v1.0.0"},
+			{Path: "example.com/a/b", Version: "v1.0.0"},
+			{Path: "example.com/unused", Version: "v1.0.0"},
+		},
+	}
+	r := compareDependencies(m, map[string][]string{
+		"example.com/m":     {"fmt", "C", "example.com/m/v2/sub", "example.com/a/b/c"},
+		"example.com/m/sub": {"example.com/a", "example.com/x/y", "example.com/x/y"},
+	})
+	want := &DependencyReport{
+		Module:   "example.com/m/v2",
+		Unused:   []string{"example.com/unused"},
+		Unlisted: []string{"example.com/x/y"},
+		Packages: 2,
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("compareDependencies() = %+v, want %+v", r, want)
+	}
+}
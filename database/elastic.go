@@ -0,0 +1,263 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+	"github.com/golang/gddo/search"
+)
+
+var (
+	elasticURL       = flag.String("db-elasticsearch-url", "http://127.0.0.1:9200", "URL of the Elasticsearch server used by -db-search-index=elasticsearch.")
+	elasticIndexName = flag.String("db-elasticsearch-index", "gddo", "Name of the Elasticsearch index used by -db-search-index=elasticsearch.")
+)
+
+const (
+	// elasticMaxResults is the maximum number of results of a query.
+	elasticMaxResults = 1000
+
+	elasticTimeout = 10 * time.Second
+)
+
+// elasticMapping is the mapping of the fields of elasticDoc used to create
+// the index. The filters are matched exactly.
+const elasticMapping = `{
+  "mappings": {
+    "properties": {
+      "path":     {"type": "text"},
+      "name":     {"type": "text"},
+      "synopsis": {"type": "text", "analyzer": "english"},
+      "doc":      {"type": "text", "analyzer": "english"},
+      "readme":   {"type": "text", "analyzer": "english"},
+      "filters":  {"type": "keyword"},
+      "score":    {"type": "float"}
+    }
+  }
+}`
+
+// elasticDoc is the document stored in Elasticsearch for a package.
+type elasticDoc struct {
+	Path     string   `json:"path"`
+	Name     string   `json:"name"`
+	Synopsis string   `json:"synopsis"`
+	Doc      string   `json:"doc"`
+	Readme   string   `json:"readme,omitempty"`
+	Filters  []string `json:"filters"`
+	Score    float64  `json:"score"`
+}
+
+func elasticDocument(pdoc *doc.Package, score float64) *elasticDoc {
+	d := &elasticDoc{
+		Path:     pdoc.ImportPath,
+		Name:     pdoc.Name,
+		Synopsis: pdoc.Synopsis,
+		Doc:      pdoc.Doc,
+		Filters:  filterTerms(pdoc, score),
+		Score:    score,
+	}
+	if pdoc.Readme != nil {
+		d.Readme = string(pdoc.Readme.Data)
+	}
+	for _, path := range pdoc.Imports {
+		if gosrc.IsValidPath(path) {
+			d.Filters = append(d.Filters, search.Filter{Name: "imports", Value: path}.String())
+		}
+	}
+	return d
+}
+
+// elasticQuery returns the Elasticsearch request body for the search query
+// q. The relevance of a package to the words of the query is multiplied by
+// the score of the package.
+func elasticQuery(q string) map[string]interface{} {
+	query := search.Parse(q)
+	var must interface{} = map[string]interface{}{"match_all": map[string]interface{}{}}
+	if query.Text != "" {
+		match := map[string]interface{}{
+			"query":    query.Text,
+			"fields":   []string{"name^4", "path^2", "synopsis^2", "doc", "readme"},
+			"operator": "and",
+		}
+		if *fuzzyDistance > 0 {
+			match["fuzziness"] = "AUTO"
+		}
+		must = map[string]interface{}{"multi_match": match}
+	}
+	filter := []interface{}{}
+	for _, f := range query.Filters {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"filters": f.String()}})
+	}
+	return map[string]interface{}{
+		"size":    elasticMaxResults,
+		"_source": []string{"path", "synopsis"},
+		"query": map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query": map[string]interface{}{
+					"bool": map[string]interface{}{"must": must, "filter": filter},
+				},
+				"field_value_factor": map[string]interface{}{"field": "score"},
+				"boost_mode":         "multiply",
+			},
+		},
+	}
+}
+
+// elasticIndex is a search index stored in an Elasticsearch server. The
+// index name is an alias of the concrete index so that the index can be
+// rebuilt in a copy and swapped without downtime.
+type elasticIndex struct {
+	server string
+	name   string
+	client *http.Client
+}
+
+// newElasticIndex returns the search index in the Elasticsearch index name
+// at the server with URL u. The index is created if it does not exist.
+func newElasticIndex(u, name string) (*elasticIndex, error) {
+	idx := &elasticIndex{
+		server: strings.TrimSuffix(u, "/"),
+		name:   name,
+		client: &http.Client{Timeout: elasticTimeout},
+	}
+	found, err := idx.do("HEAD", "", nil, nil)
+	if err != nil || found {
+		return idx, err
+	}
+	concrete, err := idx.newShadow()
+	if err != nil {
+		return nil, err
+	}
+	return idx, idx.swap(concrete)
+}
+
+// newShadow creates an empty concrete index with the mapping. The name of the
+// concrete index is the alias followed by the creation time.
+func (idx *elasticIndex) newShadow() (string, error) {
+	name := idx.name + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	_, err := idx.withName(name).do("PUT", "", json.RawMessage(elasticMapping), nil)
+	return name, err
+}
+
+// withName returns the index name on the same server.
+func (idx *elasticIndex) withName(name string) *elasticIndex {
+	return &elasticIndex{server: idx.server, name: name, client: idx.client}
+}
+
+func (idx *elasticIndex) shadow(name string) SearchIndex {
+	return idx.withName(name)
+}
+
+// swap points the alias at the concrete index name and deletes the indexes
+// previously named by the alias in one atomic request.
+func (idx *elasticIndex) swap(name string) error {
+	var old map[string]interface{}
+	if _, err := idx.do("GET", "/_alias", nil, &old); err != nil {
+		return err
+	}
+	actions := []interface{}{
+		map[string]interface{}{"add": map[string]interface{}{"index": name, "alias": idx.name}},
+	}
+	for concrete := range old {
+		if concrete != name {
+			actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": concrete}})
+		}
+	}
+	_, err := idx.withName("_aliases").do("POST", "", map[string]interface{}{"actions": actions}, nil)
+	return err
+}
+
+func (idx *elasticIndex) drop(name string) error {
+	_, err := idx.withName(name).do("DELETE", "", nil, nil)
+	return err
+}
+
+// do sends a request with the JSON encoded body to the path in the index
+// and decodes the response to result. Do returns false if the index or
+// document is not found.
+func (idx *elasticIndex) do(method, path string, body, result interface{}) (bool, error) {
+	u := idx.server + "/" + url.PathEscape(idx.name) + path
+	var r bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&r).Encode(body); err != nil {
+			return false, err
+		}
+	}
+	req, err := http.NewRequest(method, u, &r)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := idx.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode/100 != 2:
+		return false, fmt.Errorf("database: elasticsearch %s %s: %s", method, u, resp.Status)
+	case result != nil:
+		return true, json.NewDecoder(resp.Body).Decode(result)
+	}
+	return true, nil
+}
+
+func elasticDocPath(path string) string {
+	return "/_doc/" + url.PathEscape(path)
+}
+
+func (idx *elasticIndex) Put(pdoc *doc.Package, score float64) error {
+	if score <= 0 {
+		return idx.Delete(pdoc.ImportPath)
+	}
+	_, err := idx.do("PUT", elasticDocPath(pdoc.ImportPath), elasticDocument(pdoc, score), nil)
+	return err
+}
+
+func (idx *elasticIndex) Delete(path string) error {
+	_, err := idx.do("DELETE", elasticDocPath(path), nil, nil)
+	return err
+}
+
+func (idx *elasticIndex) Query(q string) ([]*SearchResult, error) {
+	if query := search.Parse(q); query.Text == "" && len(query.Filters) == 0 {
+		return nil, nil
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Score  float64 `json:"_score"`
+				Source struct {
+					Path     string `json:"path"`
+					Synopsis string `json:"synopsis"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if _, err := idx.do("POST", "/_search", elasticQuery(q), &result); err != nil {
+		return nil, err
+	}
+	var results []*SearchResult
+	for _, h := range result.Hits.Hits {
+		results = append(results, &SearchResult{Path: h.Source.Path, Synopsis: h.Source.Synopsis, Score: h.Score})
+	}
+	return results, nil
+}
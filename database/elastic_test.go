@@ -0,0 +1,118 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestElasticDocument(t *testing.T) {
+	pdoc := &doc.Package{
+		ImportPath: "github.com/user/repo/dir",
+		Name:       "dir",
+		Imports:    []string{"fmt", "github.com/foo/bar"},
+	}
+	d := elasticDocument(pdoc, 1)
+	want := []string{"host:github.com", "org:user", "is:library", "imports:fmt", "imports:github.com/foo/bar"}
+	if !reflect.DeepEqual(d.Filters, want) {
+		t.Errorf("filters = %v, want %v", d.Filters, want)
+	}
+}
+
+var timePat = regexp.MustCompile(`-[0-9]+`)
+
+func TestElasticIndex(t *testing.T) {
+	var requests []string
+	var query map[string]interface{}
+	var aliases string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+timePat.ReplaceAllString(r.URL.EscapedPath(), "-T"))
+		switch {
+		case r.Method == "HEAD" || strings.HasSuffix(r.URL.Path, "/_alias"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/_aliases":
+			p, _ := ioutil.ReadAll(r.Body)
+			aliases = timePat.ReplaceAllString(string(p), "-T")
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			p, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(p, &query)
+			w.Write([]byte(`{"hits": {"hits": [{"_score": 2.5, "_source": {"path": "github.com/user/repo", "synopsis": "Package repo."}}]}}`))
+		}
+	}))
+	defer server.Close()
+
+	idx, err := newElasticIndex(server.URL+"/", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Put(&doc.Package{ImportPath: "github.com/user/repo", Name: "repo"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Put(&doc.Package{ImportPath: "github.com/user/hidden"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	results, err := idx.Query("repo is:library")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRequests := []string{
+		"HEAD /test",
+		"PUT /test-T",
+		"GET /test/_alias",
+		"POST /_aliases",
+		"PUT /test/_doc/github.com%2Fuser%2Frepo",
+		"DELETE /test/_doc/github.com%2Fuser%2Fhidden",
+		"POST /test/_search",
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("requests = %q, want %q", requests, wantRequests)
+	}
+	if want := `{"actions":[{"add":{"alias":"test","index":"test-T"}}]}` + "\n"; aliases != want {
+		t.Errorf("aliases = %q, want %q", aliases, want)
+	}
+	wantResults := []*SearchResult{{Path: "github.com/user/repo", Synopsis: "Package repo.", Score: 2.5}}
+	if !reflect.DeepEqual(results, wantResults) {
+		t.Errorf("results = %+v, want %+v", results, wantResults)
+	}
+	filter := query["query"].(map[string]interface{})["function_score"].(map[string]interface{})["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"]
+	if !reflect.DeepEqual(filter, []interface{}{map[string]interface{}{"term": map[string]interface{}{"filters": "is:library"}}}) {
+		t.Errorf("filter = %v", filter)
+	}
+}
+
+func TestElasticSwap(t *testing.T) {
+	var aliases string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/test/_alias":
+			w.Write([]byte(`{"test-1": {"aliases": {"test": {}}}}`))
+		case "/_aliases":
+			p, _ := ioutil.ReadAll(r.Body)
+			aliases = string(p)
+		}
+	}))
+	defer server.Close()
+
+	idx := &elasticIndex{server: server.URL, name: "test", client: http.DefaultClient}
+	if err := idx.swap("test-2"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"actions":[{"add":{"alias":"test","index":"test-2"}},{"remove_index":{"index":"test-1"}}]}` + "\n"
+	if aliases != want {
+		t.Errorf("aliases = %q, want %q", aliases, want)
+	}
+}
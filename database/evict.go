@@ -0,0 +1,133 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+// The documents of cold packages, the packages not viewed and not imported by
+// a crawled package since a time, can be evicted to bound the memory used by
+// Redis. The metadata of an evicted package, such as the path, the synopsis
+// and the search terms, is kept. The document is rebuilt by the next crawl.
+
+// putAccessScript records the access of the package by a crawl. The packages
+// imported by the package are accessed, the package itself only if it has no
+// access time. The names of the counter fields are passed in ARGV in this
+// file because literals starting with a key name are namespaced.
+var putAccessScript = newScript(`
+    local t = ARGV[1]
+    local path = ARGV[2]
+    local counter = ARGV[3]
+
+    local id = redis.call('HGET', 'ids', path)
+    if id then
+        redis.call('ZADD', 'access', 'NX', t, id)
+    end
+    if redis.call('SREM', 'evicted', path) == 1 then
+        redis.call('HINCRBY', 'evictions', counter, 1)
+    end
+
+    for i = 4,#ARGV do
+        id = redis.call('HGET', 'ids', ARGV[i])
+        if id then
+            redis.call('ZADD', 'access', t, id)
+        end
+    end
+`)
+
+func putAccess(c redis.Conn, pdoc *doc.Package, t time.Time) error {
+	args := redis.Args{t.Unix(), pdoc.ImportPath, "rebuilt"}.AddFlat(pdoc.Imports)
+	_, err := putAccessScript.Do(c, args...)
+	return err
+}
+
+var evictScript = newScript(`
+    local before = ARGV[1]
+    local n = ARGV[2]
+    local counter = ARGV[3]
+
+    local paths = {}
+    for _, id in ipairs(redis.call('ZRANGEBYSCORE', 'access', '-inf', before, 'LIMIT', 0, n)) do
+        redis.call('ZREM', 'access', id)
+        local path = redis.call('HGET', 'pkg:' .. id, 'path')
+        if path and redis.call('HDEL', 'pkg:' .. id, 'gob', 'crawl') > 0 then
+            redis.call('ZREM', 'nextCrawl', id)
+            redis.call('DEL', 'source:' .. path, 'platform:' .. path)
+            redis.call('SADD', 'evicted', path)
+            paths[#paths + 1] = path
+        end
+    end
+    if #paths > 0 then
+        redis.call('HINCRBY', 'evictions', counter, #paths)
+    end
+    return paths
+`)
+
+// EvictColdDocs evicts the documents of at most n packages not accessed
+// since before and returns the import paths of the evicted packages. A
+// package is accessed when the package page is viewed and when a package
+// importing the package is crawled.
+func (db *Database) EvictColdDocs(before time.Time, n int) ([]string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	paths, err := redis.Strings(evictScript.Do(c, before.Unix(), n, "evicted"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		db.cache.remove(path)
+	}
+	return paths, nil
+}
+
+// IsEvicted returns true if the document of the package with the import path
+// is evicted.
+func (db *Database) IsEvicted(path string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Bool(c.Do("SISMEMBER", Key("evicted"), path))
+}
+
+// EvictionStats is the number of evicted documents returned by
+// EvictionStats.
+type EvictionStats struct {
+	// Evicted is the number of documents evicted now, Evictions the number
+	// of evictions and Rebuilt the number of evicted documents rebuilt by a
+	// crawl since the database was created.
+	Evicted   int   `json:"evicted"`
+	Evictions int64 `json:"evictions"`
+	Rebuilt   int64 `json:"rebuilt"`
+}
+
+// EvictionStats returns the number of evicted documents.
+func (db *Database) EvictionStats() (*EvictionStats, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("SCARD", Key("evicted"))
+	c.Send("HMGET", Key("evictions"), "evicted", "rebuilt")
+	c.Flush()
+	var s EvictionStats
+	var err error
+	if s.Evicted, err = redis.Int(c.Receive()); err != nil {
+		return nil, err
+	}
+	values, err := redis.Values(c.Receive())
+	if err != nil {
+		return nil, err
+	}
+	var evictions, rebuilt []byte
+	if _, err := redis.Scan(values, &evictions, &rebuilt); err != nil {
+		return nil, err
+	}
+	s.Evictions, _ = redis.Int64(evictions, nil)
+	s.Rebuilt, _ = redis.Int64(rebuilt, nil)
+	return &s, nil
+}
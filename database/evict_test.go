@@ -0,0 +1,77 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestEvictColdDocs(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	nextCrawl := time.Now().Add(time.Hour)
+	for _, pdoc := range []*doc.Package{
+		{ImportPath: "github.com/a/cold", Name: "cold", Synopsis: "Package cold is cold."},
+		{ImportPath: "github.com/a/viewed", Name: "viewed"},
+		{ImportPath: "github.com/a/imported", Name: "imported"},
+	} {
+		if err := db.Put(pdoc, nextCrawl, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now().Add(time.Minute)
+	later := before.Add(time.Minute)
+	if err := db.incrementPopularScoreInternal("github.com/a/viewed", 1, later); err != nil {
+		t.Fatal(err)
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	if err := putAccess(c, &doc.Package{ImportPath: "github.com/b/importer", Imports: []string{"github.com/a/imported"}}, later); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := db.EvictColdDocs(before, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"github.com/a/cold"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("EvictColdDocs() = %v, want %v", paths, want)
+	}
+	if paths, err := db.EvictColdDocs(before, 10); err != nil || len(paths) != 0 {
+		t.Errorf("second EvictColdDocs() = %v, %v, want none", paths, err)
+	}
+
+	pdoc, _, nextCrawl, err := db.Get("github.com/a/cold")
+	if err != nil || pdoc != nil || !nextCrawl.IsZero() {
+		t.Errorf("Get(evicted) = %v, %v, %v, want nil document", pdoc, nextCrawl, err)
+	}
+	if evicted, err := db.IsEvicted("github.com/a/cold"); err != nil || !evicted {
+		t.Errorf("IsEvicted() = %v, %v, want true", evicted, err)
+	}
+	if pkgs, err := db.Query("cold"); err != nil || len(pkgs) != 1 {
+		t.Errorf("Query(cold) = %v, %v, want the evicted package", pkgs, err)
+	}
+
+	if err := db.Put(&doc.Package{ImportPath: "github.com/a/cold", Name: "cold"}, nextCrawl, false); err != nil {
+		t.Fatal(err)
+	}
+	if evicted, err := db.IsEvicted("github.com/a/cold"); err != nil || evicted {
+		t.Errorf("IsEvicted(rebuilt) = %v, %v, want false", evicted, err)
+	}
+	stats, err := db.EvictionStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (EvictionStats{Evicted: 0, Evictions: 1, Rebuilt: 1}); *stats != want {
+		t.Errorf("EvictionStats() = %+v, want %+v", *stats, want)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+var fullTextSearch = flag.Bool("db-full-text-search", true, "Index the doc comments and README files of packages for search. Disable to reduce the size of the index.")
+
+const (
+	// textTermPrefix is the prefix of terms collected from doc comments and
+	// README files.
+	textTermPrefix = "text:"
+
+	// maxTextTerms is the maximum number of text terms indexed for a package.
+	maxTextTerms = 2000
+
+	// textScore is the factor applied to the score of packages matching a
+	// query only with text terms.
+	textScore = 0.5
+)
+
+// collectTextTerms adds the terms in s to terms. Words that are too short or
+// too long to be useful in a query and words containing digits are ignored.
+func collectTextTerms(terms map[string]bool, s string) {
+	s = httpPat.ReplaceAllLiteralString(s, "")
+	for _, f := range strings.FieldsFunc(s, isTermSep) {
+		if len(terms) >= maxTextTerms {
+			return
+		}
+		if len(f) < 3 || len(f) > 32 || strings.ContainsAny(f, "0123456789") {
+			continue
+		}
+		f = strings.ToLower(f)
+		if !stopWord[f] {
+			terms[term(f)] = true
+		}
+	}
+}
+
+func collectFuncTextTerms(terms map[string]bool, funcs []*doc.Func) {
+	for _, f := range funcs {
+		collectTextTerms(terms, f.Doc)
+	}
+}
+
+func collectValueTextTerms(terms map[string]bool, values []*doc.Value) {
+	for _, v := range values {
+		collectTextTerms(terms, v.Doc)
+	}
+}
+
+// textTerms returns the terms in the doc comments and README file of the
+// package that are not in documentTerms. The terms have textTermPrefix.
+func textTerms(pdoc *doc.Package, documentTerms []string) []string {
+	terms := make(map[string]bool)
+	collectTextTerms(terms, pdoc.Doc)
+	collectValueTextTerms(terms, pdoc.Consts)
+	collectValueTextTerms(terms, pdoc.Vars)
+	collectFuncTextTerms(terms, pdoc.Funcs)
+	for _, t := range pdoc.Types {
+		collectTextTerms(terms, t.Doc)
+		collectValueTextTerms(terms, t.Consts)
+		collectValueTextTerms(terms, t.Vars)
+		collectFuncTextTerms(terms, t.Funcs)
+		collectFuncTextTerms(terms, t.Methods)
+	}
+	if pdoc.Readme != nil {
+		collectTextTerms(terms, string(pdoc.Readme.Data))
+	}
+	for _, t := range documentTerms {
+		delete(terms, t)
+	}
+	result := make([]string, 0, len(terms))
+	for t := range terms {
+		result = append(result, textTermPrefix+t)
+	}
+	return result
+}
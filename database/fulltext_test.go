@@ -0,0 +1,43 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestTextTerms(t *testing.T) {
+	pdoc := &doc.Package{
+		Doc:    "Package router routes HTTP requests. See https://example.com/docs.",
+		Funcs:  []*doc.Func{{Doc: "Handle registers a handler for the pattern."}},
+		Types:  []*doc.Type{{Doc: "Mux is a multiplexer.", Methods: []*doc.Func{{Doc: "ServeHTTP dispatches to v2 handlers."}}}},
+		Readme: &doc.Readme{Name: "README.md", Data: []byte("# Router\n\nFast middleware.")},
+	}
+	terms := textTerms(pdoc, []string{"rout"})
+	sort.Strings(terms)
+	want := []string{
+		"text:dispatch",
+		"text:fast",
+		"text:handl",
+		"text:http",
+		"text:middlew",
+		"text:multiplex",
+		"text:mux",
+		"text:pack",
+		"text:pattern",
+		"text:reg",
+		"text:request",
+		"text:servehttp",
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("textTerms() =\n%v\nwant\n%v", terms, want)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var fuzzyDistance = flag.Int("db-fuzzy-distance", 1, "Maximum edit distance between a misspelled search term and the terms in the index. Zero disables fuzzy search.")
+
+const (
+	// fuzzyMinResults is the number of results below which a query is
+	// repeated with fuzzy matching.
+	fuzzyMinResults = 10
+
+	// maxFuzzyTerms is the maximum number of index terms matched by a query
+	// term.
+	maxFuzzyTerms = 8
+
+	// maxTrigramTerms is the size of the trigram sets not read by a fuzzy
+	// lookup. The common trigrams do not narrow the candidates and reading
+	// the sets is the bulk of the work.
+	maxTrigramTerms = 5000
+)
+
+// trigrams returns the trigrams of term padded with spaces.
+func trigrams(term string) []string {
+	r := []rune(" " + term + " ")
+	seen := make(map[string]bool)
+	var result []string
+	for i := 0; i+3 <= len(r); i++ {
+		t := string(r[i : i+3])
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters needed to change a into b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// isFuzzyTerm returns true if fuzzy matching applies to the search term.
+// Terms with prefixes such as import: and text: are excluded.
+func isFuzzyTerm(term string) bool {
+	return !strings.Contains(term, ":") && utf8.RuneCountInString(term) >= 3
+}
+
+// putTrigrams adds the search terms to the trigram index.
+func putTrigrams(c redis.Conn, terms []string) error {
+	n := 0
+	for _, term := range terms {
+		if !isFuzzyTerm(term) {
+			continue
+		}
+		for _, t := range trigrams(term) {
+			c.Send("SADD", Key("trigram:"+t), term)
+			n++
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if _, err := c.Receive(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTrigrams removes the terms no longer used by any package from the
+// trigram index. The terms used again since are kept.
+func removeTrigrams(c redis.Conn, terms []string) error {
+	var removed []string
+	for _, term := range terms {
+		if isFuzzyTerm(term) {
+			c.Send("EXISTS", Key("index:"+term))
+			removed = append(removed, term)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	unused := removed[:0]
+	for _, term := range removed {
+		used, err := redis.Bool(c.Receive())
+		if err != nil {
+			return err
+		}
+		if !used {
+			unused = append(unused, term)
+		}
+	}
+	n := 0
+	for _, term := range unused {
+		for _, t := range trigrams(term) {
+			c.Send("SREM", Key("trigram:"+t), term)
+			n++
+		}
+	}
+	return flushReplies(c, n)
+}
+
+type fuzzyMatch struct {
+	term     string
+	distance int
+}
+
+type byDistance []fuzzyMatch
+
+func (p byDistance) Len() int { return len(p) }
+func (p byDistance) Less(i, j int) bool {
+	if p[i].distance != p[j].distance {
+		return p[i].distance < p[j].distance
+	}
+	return p[i].term < p[j].term
+}
+func (p byDistance) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// fuzzyMatches returns the terms in candidates within maxDistance of term,
+// closest first. The number of the n trigrams of term looked up shared by
+// each candidate is in count. A candidate within distance d of term shares
+// all but 4d of the trigrams because an edit changes at most four trigrams.
+func fuzzyMatches(term string, count map[string]int, n, maxDistance int) []string {
+	var matches []fuzzyMatch
+	for candidate, shared := range count {
+		if candidate == term || shared < n-4*maxDistance {
+			continue
+		}
+		if d := editDistance(term, candidate); d <= maxDistance {
+			matches = append(matches, fuzzyMatch{candidate, d})
+		}
+	}
+	sort.Sort(byDistance(matches))
+	if len(matches) > maxFuzzyTerms {
+		matches = matches[:maxFuzzyTerms]
+	}
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.term
+	}
+	return result
+}
+
+// fuzzyTerms returns the terms in the trigram index within -db-fuzzy-distance
+// of term. The trigram sets larger than maxTrigramTerms are not read.
+func fuzzyTerms(c redis.Conn, term string) ([]string, error) {
+	if !isFuzzyTerm(term) {
+		return nil, nil
+	}
+	tris := trigrams(term)
+	for _, t := range tris {
+		c.Send("SCARD", Key("trigram:"+t))
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	var lookup []string
+	for _, t := range tris {
+		n, err := redis.Int(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 && n <= maxTrigramTerms {
+			lookup = append(lookup, t)
+		}
+	}
+	if len(lookup) == 0 {
+		return nil, nil
+	}
+	for _, t := range lookup {
+		c.Send("SMEMBERS", Key("trigram:"+t))
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	count := make(map[string]int)
+	for range lookup {
+		members, err := redis.Strings(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			count[m]++
+		}
+	}
+	return fuzzyMatches(term, count, len(lookup), *fuzzyDistance), nil
+}
+
+// fuzzyQuery returns the packages matching the search terms or the terms
+// within -db-fuzzy-distance of the search terms and the filter sets. The
+// packages in exclude are not returned.
+func fuzzyQuery(c redis.Conn, id string, terms, filters []string, exclude []*SearchResult) ([]*SearchResult, error) {
+	matches := make([][]string, len(terms))
+	found := false
+	for i, term := range terms {
+		var err error
+		matches[i], err = fuzzyTerms(c, term)
+		if err != nil {
+			return nil, err
+		}
+		found = found || len(matches[i]) > 0
+	}
+	if !found {
+		return nil, nil
+	}
+
+	fuzzyID := id + "-fuzzy"
+	args := []interface{}{fuzzyID}
+	for i, term := range terms {
+		termArgs := []interface{}{fuzzyID + "-" + strconv.Itoa(i), Key("index:" + term)}
+		if *fullTextSearch {
+			termArgs = append(termArgs, Key("index:"+textTermPrefix+term))
+		}
+		for _, m := range matches[i] {
+			termArgs = append(termArgs, Key("index:"+m))
+		}
+		c.Send("SUNIONSTORE", termArgs...)
+		args = append(args, termArgs[0])
+	}
+	tempKeys := args
+	for _, f := range filters {
+		args = append(args, f)
+	}
+	c.Send("SINTERSTORE", args...)
+	c.Send("SORT", fuzzyID, "DESC", "BY", "nosort", "GET", Key("pkg:*->path"), "GET", Key("pkg:*->synopsis"), "GET", Key("pkg:*->score"))
+	c.Send("DEL", tempKeys...)
+	c.Flush()
+	for range terms {
+		c.Receive() // SUNIONSTORE
+	}
+	c.Receive()                              // SINTERSTORE
+	values, err := redis.Values(c.Receive()) // SORT
+	c.Receive()                              // DEL
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*SearchResult
+	if err := redis.ScanSlice(values, &results, "Path", "Synopsis", "Score"); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, qr := range exclude {
+		seen[qr.Path] = true
+	}
+	fuzzy := results[:0]
+	for _, qr := range results {
+		if !seen[qr.Path] {
+			qr.Fuzzy = true
+			fuzzy = append(fuzzy, qr)
+		}
+	}
+	return fuzzy, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+var editDistanceTests = []struct {
+	a, b string
+	d    int
+}{
+	{"json", "json", 0},
+	{"jsno", "json", 1},
+	{"htttp", "http", 1},
+	{"rotuer", "router", 1},
+	{"kitten", "sitting", 3},
+	{"", "abc", 3},
+	{"héllo", "hello", 1},
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range editDistanceTests {
+		if d := editDistance(tt.a, tt.b); d != tt.d {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, d, tt.d)
+		}
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	want := []string{" ht", "htt", "ttp", "tp "}
+	if tris := trigrams("http"); !reflect.DeepEqual(tris, want) {
+		t.Errorf("trigrams(http) = %q, want %q", tris, want)
+	}
+}
+
+func countTrigrams(term string, vocabulary []string) map[string]int {
+	count := make(map[string]int)
+	for _, t := range trigrams(term) {
+		for _, v := range vocabulary {
+			for _, vt := range trigrams(v) {
+				if t == vt {
+					count[v]++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func TestFuzzyMatches(t *testing.T) {
+	vocabulary := []string{"json", "jsonp", "http", "httpd", "router", "route", "xml"}
+	for _, tt := range []struct {
+		term    string
+		matches []string
+	}{
+		{"jsno", []string{"json"}},
+		{"htttp", []string{"http"}},
+		{"rotuer", []string{"router"}},
+		{"http", []string{"httpd"}},
+		{"yaml", nil},
+	} {
+		matches := fuzzyMatches(tt.term, countTrigrams(tt.term, vocabulary), len(trigrams(tt.term)), 1)
+		if len(matches) == 0 {
+			matches = nil
+		}
+		if !reflect.DeepEqual(matches, tt.matches) {
+			t.Errorf("fuzzyMatches(%q) = %v, want %v", tt.term, matches, tt.matches)
+		}
+	}
+}
+
+func TestByScoreFuzzy(t *testing.T) {
+	results := []*SearchResult{
+		{Path: "a", Score: 10, Fuzzy: true},
+		{Path: "b", Score: 1},
+		{Path: "c", Score: 2},
+	}
+	sort.Sort(byScore(results))
+	var paths []string
+	for _, qr := range results {
+		paths = append(paths, qr.Path)
+	}
+	if want := []string{"c", "b", "a"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("sorted paths = %v, want %v", paths, want)
+	}
+}
+
+func TestRemoveTrigrams(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	pdoc := &doc.Package{
+		ImportPath:  "github.com/user/zebrafish",
+		Name:        "zebrafish",
+		ProjectRoot: "github.com/user/zebrafish",
+		Updated:     time.Now(),
+	}
+	if err := db.Put(pdoc, time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	if ok, _ := redis.Bool(c.Do("SISMEMBER", "trigram:zeb", "zebrafish")); !ok {
+		t.Fatal("term not added to the trigram index")
+	}
+	if err := db.Delete(pdoc.ImportPath); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := redis.Bool(c.Do("SISMEMBER", "trigram:zeb", "zebrafish")); ok {
+		t.Error("term of the deleted package not removed from the trigram index")
+	}
+}
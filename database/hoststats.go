@@ -0,0 +1,227 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Fetch results recorded by RecordFetch.
+const (
+	FetchOK          = "ok"          // The package was fetched, not modified or not found.
+	FetchFailed      = "failed"      // The fetch failed.
+	FetchRateLimited = "ratelimited" // The host limited the rate of the requests.
+)
+
+// hostStatsTTL is the time the hourly fetch statistics are kept.
+const hostStatsTTL = 48 * time.Hour
+
+// fetchLatencyBuckets are the upper bounds of the latency histogram buckets
+// of the fetch statistics. Longer fetches are counted in the last bucket.
+var fetchLatencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+func hostStatsHour(t time.Time) string {
+	return t.UTC().Format("2006010215")
+}
+
+// RecordFetch records the result and the latency of a fetch from the host
+// in the fetch statistics of the current hour.
+func (db *Database) RecordFetch(host, result string, latency time.Duration) error {
+	b := len(fetchLatencyBuckets) - 1
+	for i, max := range fetchLatencyBuckets {
+		if latency <= max {
+			b = i
+			break
+		}
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	key := Key("hoststats:" + hostStatsHour(time.Now()))
+	c.Send("HINCRBY", key, host+" "+result, 1)
+	c.Send("HINCRBY", key, host+" l"+strconv.Itoa(b), 1)
+	c.Send("EXPIRE", key, int(hostStatsTTL/time.Second))
+	c.Flush()
+	for i := 0; i < 3; i++ {
+		if _, err := c.Receive(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HostStats is the fetch statistics of a host. The latency percentiles are
+// the upper bounds of the histogram buckets with the percentiles.
+type HostStats struct {
+	Host        string        `json:"host"`
+	Fetches     int           `json:"fetches"`
+	Failures    int           `json:"failures"`
+	RateLimited int           `json:"rateLimited"`
+	P50         time.Duration `json:"p50"`
+	P90         time.Duration `json:"p90"`
+	P99         time.Duration `json:"p99"`
+
+	latencies []int
+}
+
+// ErrorRate returns the rate of the failed and rate limited fetches.
+func (s *HostStats) ErrorRate() float64 {
+	if s.Fetches == 0 {
+		return 0
+	}
+	return float64(s.Failures+s.RateLimited) / float64(s.Fetches)
+}
+
+func (s *HostStats) add(field string, n int) {
+	switch {
+	case field == FetchOK:
+		s.Fetches += n
+	case field == FetchFailed:
+		s.Fetches += n
+		s.Failures += n
+	case field == FetchRateLimited:
+		s.Fetches += n
+		s.RateLimited += n
+	case strings.HasPrefix(field, "l"):
+		b, err := strconv.Atoi(field[1:])
+		if err != nil || b < 0 || b >= len(fetchLatencyBuckets) {
+			return
+		}
+		if s.latencies == nil {
+			s.latencies = make([]int, len(fetchLatencyBuckets))
+		}
+		s.latencies[b] += n
+	}
+}
+
+// percentile returns the upper bound of the latency bucket with the
+// percentile p.
+func (s *HostStats) percentile(p float64) time.Duration {
+	total := 0
+	for _, n := range s.latencies {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := p * float64(total)
+	seen := 0
+	for i, n := range s.latencies {
+		seen += n
+		if float64(seen) >= rank {
+			return fetchLatencyBuckets[i]
+		}
+	}
+	return fetchLatencyBuckets[len(fetchLatencyBuckets)-1]
+}
+
+// hostStatsKeys returns the keys of the hourly fetch statistics from since
+// to now.
+func hostStatsKeys(since, now time.Time) []string {
+	if now.Sub(since) > hostStatsTTL {
+		since = now.Add(-hostStatsTTL)
+	}
+	var keys []string
+	for t := since.Truncate(time.Hour); !t.After(now); t = t.Add(time.Hour) {
+		keys = append(keys, Key("hoststats:"+hostStatsHour(t)))
+	}
+	return keys
+}
+
+// AllHostStats returns the fetch statistics of the hosts since the hour of
+// the time since, most fetches first.
+func (db *Database) AllHostStats(since time.Time) ([]*HostStats, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	keys := hostStatsKeys(since, time.Now())
+	for _, key := range keys {
+		c.Send("HGETALL", key)
+	}
+	c.Flush()
+	hosts := make(map[string]*HostStats)
+	for range keys {
+		m, err := redis.StringMap(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		for field, v := range m {
+			i := strings.LastIndex(field, " ")
+			n, err := strconv.Atoi(v)
+			if i < 0 || err != nil {
+				continue
+			}
+			host := field[:i]
+			s := hosts[host]
+			if s == nil {
+				s = &HostStats{Host: host}
+				hosts[host] = s
+			}
+			s.add(field[i+1:], n)
+		}
+	}
+	var result []*HostStats
+	for _, s := range hosts {
+		s.P50, s.P90, s.P99 = s.percentile(0.5), s.percentile(0.9), s.percentile(0.99)
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Fetches != result[j].Fetches {
+			return result[i].Fetches > result[j].Fetches
+		}
+		return result[i].Host < result[j].Host
+	})
+	return result, nil
+}
+
+// FetchStats returns the fetch statistics of the host since the hour of the
+// time since.
+func (db *Database) FetchStats(host string, since time.Time) (*HostStats, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	keys := hostStatsKeys(since, time.Now())
+	fields := []string{FetchOK, FetchFailed, FetchRateLimited}
+	for _, key := range keys {
+		args := redis.Args{key}
+		for _, f := range fields {
+			args = append(args, host+" "+f)
+		}
+		c.Send("HMGET", args...)
+	}
+	c.Flush()
+	s := &HostStats{Host: host}
+	for range keys {
+		values, err := redis.Values(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			n, err := redis.Int(v, nil)
+			if err != nil {
+				return nil, err
+			}
+			s.add(fields[i], n)
+		}
+	}
+	return s, nil
+}
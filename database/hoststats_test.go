@@ -0,0 +1,66 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostStatsPercentile(t *testing.T) {
+	s := &HostStats{}
+	s.add("l0", 50)
+	s.add("l3", 40)
+	s.add("l8", 10)
+	s.add("l99", 1)
+	s.add(FetchOK, 90)
+	s.add(FetchFailed, 8)
+	s.add(FetchRateLimited, 2)
+	if got := []time.Duration{s.percentile(0.5), s.percentile(0.9), s.percentile(0.99)}; got[0] != 100*time.Millisecond || got[1] != time.Second || got[2] != time.Minute {
+		t.Errorf("percentiles = %v, want 100ms, 1s, 1m", got)
+	}
+	if s.Fetches != 100 || s.Failures != 8 || s.RateLimited != 2 || s.ErrorRate() != 0.1 {
+		t.Errorf("stats = %+v, error rate %v", s, s.ErrorRate())
+	}
+	if p := (&HostStats{}).percentile(0.5); p != 0 {
+		t.Errorf("percentile without fetches = %v, want 0", p)
+	}
+}
+
+func TestHostStats(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, f := range []struct {
+		host, result string
+		latency      time.Duration
+	}{
+		{"github.com", FetchOK, 200 * time.Millisecond},
+		{"github.com", FetchOK, 300 * time.Millisecond},
+		{"github.com", FetchRateLimited, 50 * time.Millisecond},
+		{"example.com", FetchFailed, 20 * time.Second},
+	} {
+		if err := db.RecordFetch(f.host, f.result, f.latency); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := db.AllHostStats(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Host != "github.com" || stats[0].Fetches != 3 || stats[0].RateLimited != 1 || stats[0].P50 != 250*time.Millisecond {
+		t.Errorf("AllHostStats() = %+v", stats)
+	}
+	s, err := db.FetchStats("example.com", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Fetches != 1 || s.ErrorRate() != 1 {
+		t.Errorf("FetchStats(example.com) = %+v", s)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// The orders of the importers returned by ImporterPage.
+const (
+	ImportersByPath      = "path"
+	ImportersByStars     = "stars"
+	ImportersByImporters = "importers"
+)
+
+// ImporterQuery selects a page of the importers of a package.
+type ImporterQuery struct {
+	// Sort is the order of the importers, ImportersByPath by default.
+	// Importers with the same stars or importer count are sorted by path.
+	Sort string
+
+	// Host selects the importers with import paths on the host, such as
+	// github.com. An empty host selects all importers.
+	Host string
+
+	// Offset is the number of importers skipped and Limit is the maximum
+	// number of importers returned. Zero limit returns all importers after
+	// the offset.
+	Offset int
+	Limit  int
+
+	// Private is true if packages in private repositories are included.
+	Private bool
+}
+
+// Importer is a package importing another package.
+type Importer struct {
+	Path     string `json:"path"`
+	Synopsis string `json:"synopsis,omitempty"`
+
+	// Stars is the number of stars of the repository of the importer.
+	Stars int `json:"stars"`
+
+	// Importers is the number of importers of the importer at the last
+	// daily snapshot of the importer counts.
+	Importers int `json:"importers"`
+}
+
+var importerPageScript = newScript(`
+    local by = ARGV[1]
+    local host = ARGV[2]
+    local offset = tonumber(ARGV[3])
+    local limit = tonumber(ARGV[4])
+    local private = ARGV[5] == '1'
+
+    local seen = {}
+    local rows = {}
+    for i = 6,#ARGV do
+        for _, id in ipairs(redis.call('SMEMBERS', 'index:import:' .. ARGV[i])) do
+            if not seen[id] then
+                seen[id] = true
+                local f = redis.call('HMGET', 'pkg:' .. id, 'path', 'synopsis', 'kind', 'stars', 'importers')
+                local path = f[1]
+                if path and f[3] ~= 'd' and
+                        (host == '' or string.sub(path, 1, #host + 1) == host .. '/') and
+                        (private or redis.call('SISMEMBER', 'private', path) == 0) then
+                    rows[#rows+1] = {path, f[2] or '', tonumber(f[4]) or 0, tonumber(f[5]) or 0}
+                end
+            end
+        end
+    end
+
+    local field = ({stars = 3, importers = 4})[by]
+    table.sort(rows, function(a, b)
+        if field and a[field] ~= b[field] then
+            return a[field] > b[field]
+        end
+        return a[1] < b[1]
+    end)
+
+    local last = #rows
+    if limit > 0 and offset + limit < last then
+        last = offset + limit
+    end
+    local result = {#rows}
+    for i = offset + 1, last do
+        local r = rows[i]
+        result[#result+1] = r[1]
+        result[#result+1] = r[2]
+        result[#result+1] = r[3]
+        result[#result+1] = r[4]
+    end
+    return result
+`)
+
+// ImporterPage returns a page of the packages importing the package with the
+// import path, a copy of the package or an old import path of the package
+// and the number of importers selected by the query.
+func (db *Database) ImporterPage(path string, q ImporterQuery) ([]Importer, int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	aliases, err := redis.Strings(c.Do("SUNION", Key("aliases:"+path), Key("movedfrom:"+path)))
+	if err != nil {
+		return nil, 0, err
+	}
+	private := 0
+	if q.Private {
+		private = 1
+	}
+	args := []interface{}{q.Sort, q.Host, q.Offset, q.Limit, private, path}
+	for _, a := range aliases {
+		args = append(args, a)
+	}
+	values, err := redis.Values(importerPageScript.Do(c, args...))
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	values, err = redis.Scan(values, &total)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]Importer, 0, len(values)/4)
+	for len(values) > 0 {
+		var imp Importer
+		values, err = redis.Scan(values, &imp.Path, &imp.Synopsis, &imp.Stars, &imp.Importers)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, imp)
+	}
+	return result, total, nil
+}
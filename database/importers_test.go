@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestImporterPage(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, pdoc := range []*doc.Package{
+		{ImportPath: "github.com/a/x", Name: "x", Stars: 5},
+		{ImportPath: "github.com/b/y", Name: "y", Stars: 20},
+		{ImportPath: "gitlab.com/c/z", Name: "z", Stars: 10},
+	} {
+		pdoc.Imports = []string{"example.com/lib"}
+		pdoc.Updated = time.Now()
+		if err := db.Put(pdoc, time.Now().Add(time.Hour), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := func(pkgs []Importer) []string {
+		var result []string
+		for _, pkg := range pkgs {
+			result = append(result, pkg.Path)
+		}
+		return result
+	}
+	for _, tt := range []struct {
+		q     ImporterQuery
+		want  []string
+		total int
+	}{
+		{ImporterQuery{}, []string{"github.com/a/x", "github.com/b/y", "gitlab.com/c/z"}, 3},
+		{ImporterQuery{Sort: ImportersByStars}, []string{"github.com/b/y", "gitlab.com/c/z", "github.com/a/x"}, 3},
+		{ImporterQuery{Sort: ImportersByStars, Offset: 1, Limit: 1}, []string{"gitlab.com/c/z"}, 3},
+		{ImporterQuery{Host: "github.com"}, []string{"github.com/a/x", "github.com/b/y"}, 2},
+		{ImporterQuery{Offset: 5}, nil, 3},
+	} {
+		pkgs, total, err := db.ImporterPage("example.com/lib", tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := paths(pkgs); !reflect.DeepEqual(got, tt.want) || total != tt.total {
+			t.Errorf("ImporterPage(%+v) = %v, %d, want %v, %d", tt.q, got, total, tt.want, tt.total)
+		}
+	}
+}
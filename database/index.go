@@ -7,13 +7,17 @@
 package database
 
 import (
+	"math"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/golang/gddo/doc"
 	"github.com/golang/gddo/gosrc"
+	"github.com/golang/gddo/search"
 )
 
 func isStandardPackage(path string) bool {
@@ -148,6 +152,75 @@ func documentTerms(pdoc *doc.Package, score float64) []string {
 	return termSlice(terms)
 }
 
+// filterTerms returns the terms used for the search query filters defined in
+// package search. The terms are only returned for packages in the index.
+func filterTerms(pdoc *doc.Package, score float64) []string {
+	if score <= 0 {
+		return nil
+	}
+	var terms []string
+	if elems := strings.Split(strings.ToLower(pdoc.ImportPath), "/"); strings.Contains(elems[0], ".") {
+		terms = append(terms, "host:"+elems[0])
+		if len(elems) >= 3 {
+			terms = append(terms, "org:"+elems[1])
+		}
+	}
+	if pdoc.IsCmd {
+		terms = append(terms, "is:command")
+	} else {
+		terms = append(terms, "is:library")
+	}
+	if hasExamples(pdoc) {
+		terms = append(terms, "has:examples")
+	}
+	for _, l := range pdoc.Licenses {
+		terms = append(terms, "license:"+strings.ToLower(l.ID))
+	}
+	if pdoc.Quality != nil {
+		for _, t := range search.QualityThresholds {
+			if pdoc.Quality.Score >= t {
+				terms = append(terms, "quality:"+strconv.Itoa(t))
+			}
+		}
+	}
+	return terms
+}
+
+func hasExamples(pdoc *doc.Package) bool {
+	if len(pdoc.Examples) > 0 {
+		return true
+	}
+	for _, f := range pdoc.Funcs {
+		if len(f.Examples) > 0 {
+			return true
+		}
+	}
+	for _, t := range pdoc.Types {
+		if len(t.Examples) > 0 {
+			return true
+		}
+		for _, f := range t.Funcs {
+			if len(f.Examples) > 0 {
+				return true
+			}
+		}
+		for _, f := range t.Methods {
+			if len(f.Examples) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queryFilterTerm returns the index term for a search query filter.
+func queryFilterTerm(f search.Filter) string {
+	if f.Name == "imports" {
+		return "import:" + f.Value
+	}
+	return f.String()
+}
+
 // vendorPat matches the path of a vendored package.
 var vendorPat = regexp.MustCompile(
 	// match directories used by tools to vendor packages.
@@ -216,6 +289,38 @@ func documentScore(pdoc *doc.Package) float64 {
 			}
 		}
 	}
+	if pdoc.Quality != nil {
+		// Bump from 0.9 for a quality score of 0 to 1.1 for 100.
+		r *= 0.9 + float64(pdoc.Quality.Score)/500
+	}
+	return r * repositoryScore(pdoc)
+}
+
+// staleAge is the time since the last push after which a repository is
+// considered inactive.
+const staleAge = 2 * 365 * 24 * time.Hour
+
+// repositoryScore returns the factor applied to the score of a package for
+// the repository signals reported by the version control service. The
+// signals rank upstream repositories above forks with the same packages.
+func repositoryScore(pdoc *doc.Package) float64 {
+	r := 1.0
+	if pdoc.Fork {
+		// Penalty for forks.
+		r *= 0.2
+	}
+	if pdoc.Archived {
+		// Penalty for archived repositories.
+		r *= 0.5
+	}
+	if !pdoc.Pushed.IsZero() && pdoc.Updated.Sub(pdoc.Pushed) > staleAge {
+		// Penalty for inactive repositories.
+		r *= 0.8
+	}
+	if pdoc.Stars > 0 {
+		// Bump for starred repositories, 1.3 for 1000 stars.
+		r *= 1 + math.Log10(float64(pdoc.Stars))/10
+	}
 	return r
 }
 
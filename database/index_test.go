@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/golang/gddo/doc"
 )
@@ -200,3 +201,41 @@ func TestSynopsisTerms(t *testing.T) {
 		}
 	}
 }
+
+var filterTermsTests = []struct {
+	pdoc  *doc.Package
+	terms []string
+}{
+	{&doc.Package{ImportPath: "strconv", Name: "strconv"}, []string{"is:library"}},
+	{&doc.Package{ImportPath: "GitHub.com/Gorilla/mux", Name: "mux", Examples: []*doc.Example{{}}}, []string{"host:github.com", "org:gorilla", "is:library", "has:examples"}},
+	{&doc.Package{ImportPath: "example.com/cmd", IsCmd: true, Funcs: []*doc.Func{{Examples: []*doc.Example{{}}}}}, []string{"host:example.com", "is:command", "has:examples"}},
+	{&doc.Package{ImportPath: "github.com/user/repo", Name: "repo", Licenses: []doc.License{{ID: "MIT"}, {ID: "Apache-2.0"}}}, []string{"host:github.com", "org:user", "is:library", "license:mit", "license:apache-2.0"}},
+	{&doc.Package{ImportPath: "github.com/user/repo", Name: "repo", Quality: &doc.Quality{Score: 80}}, []string{"host:github.com", "org:user", "is:library", "quality:50", "quality:75"}},
+}
+
+func TestFilterTerms(t *testing.T) {
+	for _, tt := range filterTermsTests {
+		if terms := filterTerms(tt.pdoc, 1); !reflect.DeepEqual(terms, tt.terms) {
+			t.Errorf("filterTerms(%s) = %v, want %v", tt.pdoc.ImportPath, terms, tt.terms)
+		}
+	}
+	if terms := filterTerms(filterTermsTests[1].pdoc, 0); terms != nil {
+		t.Errorf("filterTerms(pdoc, 0) = %v, want nil", terms)
+	}
+}
+
+func TestRepositoryScore(t *testing.T) {
+	updated := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	upstream := &doc.Package{Stars: 1000, Pushed: updated.Add(-time.Hour), Updated: updated}
+	fork := &doc.Package{Fork: true, Pushed: updated.Add(-3 * 365 * 24 * time.Hour), Updated: updated}
+	archived := &doc.Package{Archived: true, Updated: updated}
+	if s := repositoryScore(&doc.Package{}); s != 1 {
+		t.Errorf("repositoryScore(no signals) = %g, want 1", s)
+	}
+	if s := repositoryScore(upstream); s < 1.29 || s > 1.31 {
+		t.Errorf("repositoryScore(upstream) = %g, want 1.3", s)
+	}
+	if s, a := repositoryScore(fork), repositoryScore(archived); !(s < a && a < 1) {
+		t.Errorf("repositoryScore(fork) = %g, repositoryScore(archived) = %g, want fork < archived < 1", s, a)
+	}
+}
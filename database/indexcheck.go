@@ -0,0 +1,158 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// IndexCheck is the result of checking the search and importer indexes of a
+// package against the stored document.
+type IndexCheck struct {
+	Path string
+
+	// Terms are the terms of the document, sorted.
+	Terms []string
+
+	// Missing are the terms of the document whose index does not have the
+	// package.
+	Missing []string
+
+	// Stale are the terms stored with the package in the last Put that the
+	// document does not have. The indexes of the terms may have the package.
+	Stale []string
+}
+
+// OK returns true if the indexes are consistent with the document.
+func (ic *IndexCheck) OK() bool {
+	return len(ic.Missing) == 0 && len(ic.Stale) == 0
+}
+
+// missingTerms returns the terms not in the set of terms have.
+func missingTerms(terms []string, have map[string]bool) []string {
+	var missing []string
+	for _, term := range terms {
+		if !have[term] {
+			missing = append(missing, term)
+		}
+	}
+	return missing
+}
+
+// termSet returns the terms as a set.
+func termSet(terms []string) map[string]bool {
+	m := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		m[term] = true
+	}
+	return m
+}
+
+// CheckIndex compares the indexes of the package with the import path to the
+// terms of the stored document. CheckIndex returns nil if the package is not
+// in the database.
+func (db *Database) CheckIndex(path string) (*IndexCheck, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+
+	id, err := redis.String(c.Do("HGET", Key("ids"), path))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	values, err := redis.Values(c.Do("HMGET", Key("pkg:"+id), "terms", "score", "etag", "clone"))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		stored, etag, clone string
+		score               float64
+	)
+	if _, err := redis.Scan(values, &stored, &score, &etag, &clone); err != nil {
+		return nil, err
+	}
+	pdoc, _, err := db.getDoc(c, path)
+	if err != nil || pdoc == nil {
+		return nil, err
+	}
+
+	ic := &IndexCheck{Path: path}
+	if etag == "" || etag != clone {
+		// Put does not index the clones of other repositories.
+		ic.Terms = indexTerms(pdoc, score)
+	}
+	sort.Strings(ic.Terms)
+	ic.Stale = missingTerms(strings.Fields(stored), termSet(ic.Terms))
+
+	for _, term := range ic.Terms {
+		c.Send("SISMEMBER", Key("index:"+term), id)
+	}
+	c.Flush()
+	for _, term := range ic.Terms {
+		ok, err := redis.Bool(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			ic.Missing = append(ic.Missing, term)
+		}
+	}
+	return ic, nil
+}
+
+// CheckIndexes calls f with the result of CheckIndex for each package in the
+// database.
+func (db *Database) CheckIndexes(f func(*IndexCheck) error) error {
+	c := db.Pool.Get()
+	paths, err := redis.Strings(c.Do("HKEYS", Key("ids")))
+	c.Close()
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		ic, err := db.CheckIndex(path)
+		if err != nil {
+			return err
+		}
+		if ic == nil {
+			continue
+		}
+		if err := f(ic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepairIndex adds the package to the indexes of the missing terms, removes
+// the package from the indexes of the stale terms and stores the terms of the
+// document with the package.
+func (db *Database) RepairIndex(ic *IndexCheck) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	id, err := redis.String(c.Do("HGET", Key("ids"), ic.Path))
+	if err == redis.ErrNil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	c.Send("MULTI")
+	for _, term := range ic.Missing {
+		c.Send("SADD", Key("index:"+term), id)
+	}
+	for _, term := range ic.Stale {
+		c.Send("SREM", Key("index:"+term), id)
+	}
+	c.Send("HSET", Key("pkg:"+id), "terms", strings.Join(ic.Terms, " "))
+	_, err = c.Do("EXEC")
+	return err
+}
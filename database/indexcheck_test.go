@@ -0,0 +1,71 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+func TestCheckIndex(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	pdoc := &doc.Package{
+		ImportPath:  "github.com/user/repo/foo",
+		Name:        "foo",
+		Synopsis:    "Package foo does things.",
+		ProjectRoot: "github.com/user/repo",
+		ProjectName: "repo",
+		Imports:     []string{"github.com/user/repo/bar"},
+		Updated:     time.Now(),
+	}
+	if err := db.Put(pdoc, time.Now().Add(time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+	ic, err := db.CheckIndex(pdoc.ImportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ic == nil || !ic.OK() || len(ic.Terms) == 0 {
+		t.Fatalf("CheckIndex() = %+v, want consistent index", ic)
+	}
+
+	c := db.Pool.Get()
+	defer c.Close()
+	id, err := redis.String(c.Do("HGET", Key("ids"), pdoc.ImportPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Do("SREM", Key("index:import:github.com/user/repo/bar"), id)
+	c.Do("HSET", Key("pkg:"+id), "terms", "import:github.com/user/repo/bar gone")
+	c.Do("SADD", Key("index:gone"), id)
+
+	ic, err = db.CheckIndex(pdoc.ImportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ic.Missing) != 1 || ic.Missing[0] != "import:github.com/user/repo/bar" {
+		t.Errorf("Missing = %v, want [import:github.com/user/repo/bar]", ic.Missing)
+	}
+	if len(ic.Stale) != 1 || ic.Stale[0] != "gone" {
+		t.Errorf("Stale = %v, want [gone]", ic.Stale)
+	}
+	if err := db.RepairIndex(ic); err != nil {
+		t.Fatal(err)
+	}
+	if ic, err := db.CheckIndex(pdoc.ImportPath); err != nil || !ic.OK() {
+		t.Errorf("CheckIndex() after RepairIndex = %+v, %v", ic, err)
+	}
+
+	if ic, err := db.CheckIndex("github.com/user/missing"); ic != nil || err != nil {
+		t.Errorf("CheckIndex(missing) = %+v, %v, want nil", ic, err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// A lease gives one of the processes sharing the database the ownership of a
+// named resource, such as a background task, until the lease expires. The
+// holder extends the lease before it expires to keep the ownership. Another
+// process acquires the lease when the holder stops extending it.
+
+var acquireLeaseScript = newScript(`
+    local key = 'lease:' .. ARGV[1]
+    local holder = ARGV[2]
+    local ttl = ARGV[3]
+
+    local current = redis.call('GET', key)
+    if current == holder then
+        redis.call('PEXPIRE', key, ttl)
+        return 1
+    end
+    if current then
+        return 0
+    end
+    redis.call('SET', key, holder, 'PX', ttl)
+    return 1
+`)
+
+// AcquireLease acquires or extends the lease on name for holder and returns
+// true if holder has the lease for ttl. AcquireLease returns false if another
+// holder has the lease.
+func (db *Database) AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Bool(acquireLeaseScript.Do(c, name, holder, int64(ttl/time.Millisecond)))
+}
+
+var releaseLeaseScript = newScript(`
+    local key = 'lease:' .. ARGV[1]
+    if redis.call('GET', key) == ARGV[2] then
+        redis.call('DEL', key)
+    end
+`)
+
+// releaseLease releases the lease on name if holder has the lease.
+func (db *Database) releaseLease(name, holder string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := releaseLeaseScript.Do(c, name, holder)
+	return err
+}
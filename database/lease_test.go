@@ -0,0 +1,42 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, tt := range []struct {
+		holder string
+		want   bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"a", true},
+	} {
+		ok, err := db.AcquireLease("task", tt.holder, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("AcquireLease(%q) = %v, want %v", tt.holder, ok, tt.want)
+		}
+	}
+
+	if ok, _ := db.AcquireLease("task", "a", 10*time.Millisecond); !ok {
+		t.Error("lease not extended")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := db.AcquireLease("task", "b", time.Minute); !ok {
+		t.Error("expired lease not acquired")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/gosrc"
+)
+
+// putMajor records the package with the import path in the set of the major
+// versions of the package. Import paths without a major version are not
+// recorded, the import path is the base of the set.
+func putMajor(c redis.Conn, path string) error {
+	base, version := gosrc.MajorVersion(path)
+	if version == "" {
+		return nil
+	}
+	_, err := c.Do("SADD", Key("majors:"+base), path)
+	return err
+}
+
+func deleteMajor(c redis.Conn, path string) error {
+	base, version := gosrc.MajorVersion(path)
+	if version == "" {
+		return nil
+	}
+	_, err := c.Do("SREM", Key("majors:"+base), path)
+	return err
+}
+
+// SortMajors sorts the import paths of the major versions of a package,
+// oldest first.
+func SortMajors(paths []string) {
+	sort.Slice(paths, func(i, j int) bool {
+		_, vi := gosrc.MajorVersion(paths[i])
+		_, vj := gosrc.MajorVersion(paths[j])
+		return gosrc.MajorNumber(vi) < gosrc.MajorNumber(vj)
+	})
+}
+
+// MajorVersions returns the import paths of the stored major versions of the
+// package with the import path, oldest first. The result includes the import
+// path if the package is stored.
+func (db *Database) MajorVersions(path string) ([]string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	base, _ := gosrc.MajorVersion(path)
+	paths, err := redis.Strings(c.Do("SMEMBERS", Key("majors:"+base)))
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := redis.Bool(c.Do("HEXISTS", Key("ids"), base)); err != nil {
+		return nil, err
+	} else if ok {
+		paths = append(paths, base)
+	}
+	SortMajors(paths)
+	return paths, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"flag"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/gosrc"
+)
+
+var metaExpire = flag.Duration("db-meta-expire", 30*24*time.Hour, "Time to keep resolved go-import meta tags for use when the tags cannot be fetched.")
+
+// GetImportMeta returns the cached go-import meta tag resolution for the
+// import path or nil if the resolution is not cached.
+func (db *Database) GetImportMeta(importPath string) (*gosrc.ImportMeta, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("GET", Key("meta:"+importPath)))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m gosrc.ImportMeta
+	if err := gob.NewDecoder(bytes.NewReader(p)).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// PutImportMeta caches the go-import meta tag resolution for the import path.
+func (db *Database) PutImportMeta(importPath string, m *gosrc.ImportMeta) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		return err
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("SET", Key("meta:"+importPath), buf.Bytes(), "EX", int64(*metaExpire/time.Second))
+	return err
+}
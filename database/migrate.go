@@ -0,0 +1,134 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// The schema key records the version of the layout of the stored data: the
+// number of migrations applied. Changes to the layout, such as a new
+// encoding of the documents or a new index, are rolled out as a migration
+// appended to migrations instead of converting the data when it is read.
+// Migrate runs the pending migrations at startup. One of the processes
+// sharing the database holds the migrate lease and runs the migrations; the
+// other processes wait until the schema is current.
+
+// migration converts the stored data to the next version of the schema. The
+// function must be safe to run again after an interrupted run.
+type migration struct {
+	name string
+	fn   func(db *Database) error
+}
+
+// migrations are the migrations of the schema in order. New migrations are
+// appended; the migrations are never reordered or removed.
+var migrations = []migration{
+	// The layout of the data stored before the schema key.
+	{"baseline", func(db *Database) error { return nil }},
+}
+
+// SchemaVersion is the version of the schema written by this version of the
+// package.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+const (
+	migrateLeaseName = "migrate"
+	migrateLeaseTTL  = time.Minute
+	migratePoll      = time.Second
+)
+
+var errMigrateTimeout = errors.New("database: timeout waiting for the migration of the schema")
+
+// StoredSchemaVersion returns the version of the schema of the stored data.
+// Data stored before the schema key has version zero.
+func (db *Database) StoredSchemaVersion() (int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	v, err := redis.Int(c.Do("GET", Key("schema")))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// Migrate runs the pending migrations of the schema. The migrations run in
+// the process that acquires the migrate lease for holder; Migrate waits at
+// most timeout for another holder of the lease to complete the migrations.
+// Data stored by a newer version of the package is not changed.
+func (db *Database) Migrate(holder string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		v, err := db.StoredSchemaVersion()
+		if err != nil {
+			return err
+		}
+		if v >= len(migrations) {
+			return nil
+		}
+		ok, err := db.AcquireLease(migrateLeaseName, holder, migrateLeaseTTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return db.runMigrations(holder)
+		}
+		if time.Now().After(deadline) {
+			return errMigrateTimeout
+		}
+		time.Sleep(migratePoll)
+	}
+}
+
+// runMigrations runs the pending migrations while extending the migrate
+// lease of holder.
+func (db *Database) runMigrations(holder string) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(migrateLeaseTTL / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if _, err := db.AcquireLease(migrateLeaseName, holder, migrateLeaseTTL); err != nil {
+					slog.Error("extend migrate lease", "holder", holder, "err", err)
+				}
+			}
+		}
+	}()
+	defer db.releaseLease(migrateLeaseName, holder)
+
+	// Read the version again; another holder may have completed the
+	// migrations before the lease was acquired.
+	v, err := db.StoredSchemaVersion()
+	if err != nil {
+		return err
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	for ; v < len(migrations); v++ {
+		m := migrations[v]
+		slog.Info("migrate schema", "version", v+1, "migration", m.name)
+		if err := m.fn(db); err != nil {
+			return fmt.Errorf("database: migration %d (%s): %v", v+1, m.name, err)
+		}
+		if _, err := c.Do("SET", Key("schema"), v+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
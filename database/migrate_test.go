@@ -0,0 +1,55 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	defer func(saved []migration) { migrations = saved }(migrations)
+	runs := 0
+	migrations = append(migrations[:len(migrations):len(migrations)],
+		migration{"count", func(db *Database) error { runs++; return nil }})
+
+	if v, err := db.StoredSchemaVersion(); err != nil || v != 0 {
+		t.Fatalf("StoredSchemaVersion() = %d, %v, want 0", v, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Migrate("a", time.Second); err != nil {
+			t.Fatalf("Migrate returned %v", err)
+		}
+	}
+	if v, _ := db.StoredSchemaVersion(); v != SchemaVersion() || runs != 1 {
+		t.Errorf("after Migrate, version = %d, runs = %d, want %d, 1", v, runs, SchemaVersion())
+	}
+	if ok, _ := db.AcquireLease(migrateLeaseName, "b", time.Minute); !ok {
+		t.Error("migrate lease not released")
+	}
+
+	// A failed migration is run again by the next Migrate.
+	migrations = append(migrations[:len(migrations):len(migrations)],
+		migration{"fail", func(db *Database) error { return errors.New("fail") }})
+	db.releaseLease(migrateLeaseName, "b")
+	if err := db.Migrate("a", time.Second); err == nil {
+		t.Error("Migrate with failing migration returned nil")
+	}
+	if v, _ := db.StoredSchemaVersion(); v != SchemaVersion()-1 {
+		t.Errorf("after failed migration, version = %d, want %d", v, SchemaVersion()-1)
+	}
+
+	// Another holder of the lease runs the migrations.
+	db.AcquireLease(migrateLeaseName, "b", time.Minute)
+	if err := db.Migrate("a", 0); err != errMigrateTimeout {
+		t.Errorf("Migrate while another holder has the lease returned %v, want %v", err, errMigrateTimeout)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// RecordMove records that the repository with the project root oldRoot
+// moved to the project root newRoot. The packages of the old repository are
+// merged into the new repository by MergeMoves.
+func (db *Database) RecordMove(oldRoot, newRoot string) error {
+	if oldRoot == newRoot || oldRoot == "" || newRoot == "" {
+		return nil
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	// A repository moved back is not redirected to itself.
+	c.Send("HDEL", Key("moves"), newRoot)
+	c.Send("HSET", Key("moves"), oldRoot, newRoot)
+	c.Send("SADD", Key("movequeue"), oldRoot)
+	_, err := c.Do("EXEC")
+	return err
+}
+
+// ForgetMove removes the move of the repository with the project root. The
+// crawler forgets the move of a repository found again at the old path.
+func (db *Database) ForgetMove(projectRoot string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("HDEL", Key("moves"), projectRoot)
+	c.Send("SREM", Key("movequeue"), projectRoot)
+	_, err := c.Do("EXEC")
+	return err
+}
+
+// MovedPath returns the import path of the package with the import path in
+// the moved repository or "" if the repository did not move.
+func (db *Database) MovedPath(importPath string) (string, error) {
+	names := strings.Split(importPath, "/")
+	if len(names) < 2 {
+		return "", nil
+	}
+	var args []interface{}
+	args = append(args, Key("moves"))
+	for i := len(names); i >= 2; i-- {
+		args = append(args, strings.Join(names[:i], "/"))
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	roots, err := redis.Strings(c.Do("HMGET", args...))
+	if err != nil {
+		return "", err
+	}
+	for i, root := range roots {
+		if root != "" {
+			return root + importPath[len(args[i+1].(string)):], nil
+		}
+	}
+	return "", nil
+}
+
+// MergeMoves merges the packages of the moved repositories recorded since
+// the last merge into the new repositories. The packages at the old import
+// paths are deleted, the packages at the new import paths are queued for
+// crawling and the importers of the old import paths count as importers of
+// the new import paths.
+func (db *Database) MergeMoves() error {
+	c := db.Pool.Get()
+	defer c.Close()
+	for {
+		oldRoot, err := redis.String(c.Do("SPOP", Key("movequeue")))
+		if err == redis.ErrNil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		newRoot, err := redis.String(c.Do("HGET", Key("moves"), oldRoot))
+		if err == redis.ErrNil {
+			continue
+		} else if err != nil {
+			return err
+		}
+		pkgs, err := db.Project(oldRoot)
+		if err != nil {
+			return err
+		}
+		for _, pkg := range pkgs {
+			if pkg.Path != oldRoot && !strings.HasPrefix(pkg.Path, oldRoot+"/") {
+				continue
+			}
+			newPath := newRoot + pkg.Path[len(oldRoot):]
+			if _, err := c.Do("SADD", Key("movedfrom:"+newPath), pkg.Path); err != nil {
+				return err
+			}
+			if err := db.Delete(pkg.Path); err != nil {
+				return err
+			}
+			if err := db.AddNewCrawl(newPath); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestMoves(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	old := &doc.Package{ImportPath: "github.com/alice/old/sub", ProjectRoot: "github.com/alice/old", Name: "sub", Updated: time.Now()}
+	importer := &doc.Package{ImportPath: "example.com/app", Name: "app", Imports: []string{old.ImportPath}, Updated: time.Now()}
+	for _, pdoc := range []*doc.Package{old, importer} {
+		if err := db.Put(pdoc, time.Now().Add(time.Hour), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.RecordMove("github.com/alice/old", "github.com/bob/new"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := db.MovedPath("github.com/alice/old/sub/x"); err != nil || p != "github.com/bob/new/sub/x" {
+		t.Errorf("MovedPath() = %q, %v, want the path in the new repository", p, err)
+	}
+	if p, err := db.MovedPath("github.com/alice/other"); err != nil || p != "" {
+		t.Errorf("MovedPath(not moved) = %q, %v", p, err)
+	}
+
+	// A transfer to another owner moves only the repository.
+	if err := db.RecordMove("github.com/alice/repo", "github.com/carol/repo"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := db.MovedPath("github.com/alice/lib"); err != nil || p != "" {
+		t.Errorf("MovedPath(other repository of owner) = %q, %v, want not moved", p, err)
+	}
+	if err := db.ForgetMove("github.com/alice/repo"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := db.MovedPath("github.com/alice/repo/x"); err != nil || p != "" {
+		t.Errorf("MovedPath(forgotten move) = %q, %v, want not moved", p, err)
+	}
+
+	if err := db.MergeMoves(); err != nil {
+		t.Fatal(err)
+	}
+	if pdoc, _, err := db.GetDoc(old.ImportPath); err != nil || pdoc != nil {
+		t.Errorf("GetDoc(old path) after merge = %v, %v, want deleted", pdoc, err)
+	}
+	if n, err := db.ImporterCount("github.com/bob/new/sub"); err != nil || n != 1 {
+		t.Errorf("ImporterCount(new path) = %d, %v, want 1", n, err)
+	}
+}
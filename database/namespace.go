@@ -0,0 +1,103 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var namespace = flag.String("db-namespace", "", "Prefix for all Redis keys. Use different prefixes to share a Redis server between instances.")
+
+// Key returns the Redis key for name in the configured namespace.
+func Key(name string) string {
+	return *namespace + name
+}
+
+// keyNames are the names and name prefixes of the keys used in Lua scripts.
+// Keep this list in sync with the keys documented at the top of database.go.
+var keyNames = []string{
+	"access",
+	"api:",
+	"badCrawl",
+	"block",
+	"counter:",
+	"evict",
+	"feed:",
+	"ids",
+	"importers:",
+	"index:",
+	"lease:",
+	"maxPackageId",
+	"newCrawl",
+	"nextCrawl",
+	"notes",
+	"optout",
+	"owners",
+	"pkg:",
+	"platform:",
+	"popular",
+	"private",
+	"quarantine",
+	"reports",
+	"source:",
+	"suggest",
+	"tombstone",
+	"version:",
+	"versions:",
+	"views:",
+	"watchevents",
+	"watches",
+}
+
+var keyLiteralPat = regexp.MustCompile(`'(` + strings.Join(keyNames, "|") + `)`)
+
+// namespaceScript returns the Lua source src with the string literals that
+// name keys prefixed by ns.
+func namespaceScript(src, ns string) string {
+	if ns == "" {
+		return src
+	}
+	return keyLiteralPat.ReplaceAllStringFunc(src, func(s string) string {
+		return "'" + ns + s[1:]
+	})
+}
+
+// script is a Lua script that uses the configured namespace for keys. The
+// namespace is applied on first use of the script, after flags are parsed.
+type script struct {
+	src  string
+	once sync.Once
+	s    *redis.Script
+}
+
+func newScript(src string) *script {
+	return &script{src: src}
+}
+
+func (s *script) get() *redis.Script {
+	s.once.Do(func() {
+		s.s = redis.NewScript(0, namespaceScript(s.src, *namespace))
+	})
+	return s.s
+}
+
+func (s *script) Do(c redis.Conn, args ...interface{}) (interface{}, error) {
+	return s.get().Do(c, args...)
+}
+
+func (s *script) Send(c redis.Conn, args ...interface{}) error {
+	return s.get().Send(c, args...)
+}
+
+func (s *script) Load(c redis.Conn) error {
+	return s.get().Load(c)
+}
@@ -0,0 +1,30 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+)
+
+var namespaceScriptTests = []struct {
+	src, ns, out string
+}{
+	{`redis.call('HGET', 'ids', path)`, "", `redis.call('HGET', 'ids', path)`},
+	{`redis.call('HGET', 'ids', path)`, "staging:", `redis.call('HGET', 'staging:ids', path)`},
+	{`redis.call('HGET', 'pkg:' .. id, 'path')`, "x:", `redis.call('HGET', 'x:pkg:' .. id, 'path')`},
+	{`redis.call('SORT', 'index:project:' .. root, 'BY', 'pkg:*->path')`, "x:", `redis.call('SORT', 'x:index:project:' .. root, 'BY', 'x:pkg:*->path')`},
+	{`redis.call('ZUNIONSTORE', 'popular', 1, 'popular')`, "x:", `redis.call('ZUNIONSTORE', 'x:popular', 1, 'x:popular')`},
+	{`redis.call('DEL', 'source:' .. path, 'platform:' .. path)`, "x:", `redis.call('DEL', 'x:source:' .. path, 'x:platform:' .. path)`},
+}
+
+func TestNamespaceScript(t *testing.T) {
+	for _, tt := range namespaceScriptTests {
+		if out := namespaceScript(tt.src, tt.ns); out != tt.out {
+			t.Errorf("namespaceScript(%q, %q) = %q, want %q", tt.src, tt.ns, out, tt.out)
+		}
+	}
+}
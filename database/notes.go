@@ -0,0 +1,133 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+// Note is a note, such as BUG(uid) or TODO(uid), in the source of a package.
+type Note struct {
+	Tag  string `json:"tag"`
+	UID  string `json:"uid,omitempty"`
+	Body string `json:"body"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+
+	// URL is the URL of the line declaring the note or "".
+	URL string `json:"url,omitempty"`
+}
+
+// PackageNotes are the notes of a package.
+type PackageNotes struct {
+	Path  string `json:"path"`
+	Notes []Note `json:"notes"`
+}
+
+// packageNotes returns the notes of the package document sorted by tag, file
+// and line.
+func packageNotes(pdoc *doc.Package) []Note {
+	var notes []Note
+	for tag, values := range pdoc.Notes {
+		for _, v := range values {
+			n := Note{Tag: tag, UID: v.UID, Body: v.Body}
+			if v.Pos.Line != 0 && int(v.Pos.File) < len(pdoc.Files) {
+				f := pdoc.Files[v.Pos.File]
+				n.File = f.Name
+				n.Line = int(v.Pos.Line)
+				switch {
+				case pdoc.SourcesStored:
+					n.URL = fmt.Sprintf("/src/%s/%s#L%d", pdoc.ImportPath, f.Name, v.Pos.Line)
+				case pdoc.LineFmt != "" && f.URL != "":
+					n.URL = fmt.Sprintf(pdoc.LineFmt, f.URL, v.Pos.Line)
+				}
+			}
+			notes = append(notes, n)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if a.Tag != b.Tag {
+			return a.Tag < b.Tag
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return notes
+}
+
+// putNotes stores the notes of the package document in the notes hash.
+func putNotes(c redis.Conn, pdoc *doc.Package) error {
+	notes := packageNotes(pdoc)
+	if len(notes) == 0 {
+		_, err := c.Do("HDEL", Key("notes"), pdoc.ImportPath)
+		return err
+	}
+	p, err := json.Marshal(notes)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("HSET", Key("notes"), pdoc.ImportPath, p)
+	return err
+}
+
+var notesScript = newScript(`
+    local prefix = ARGV[1]
+    local result = {}
+    local entries = redis.call('HGETALL', 'notes')
+    for i = 1, #entries, 2 do
+        local path = entries[i]
+        if prefix == '' or path == prefix or string.sub(path, 1, #prefix + 1) == prefix .. '/' then
+            table.insert(result, path)
+            table.insert(result, entries[i + 1])
+        end
+    end
+    return result
+`)
+
+// Notes returns the notes of the packages with the given import path or with
+// import paths below prefix, sorted by import path. The notes of all packages
+// are returned if prefix is "". If tag is not "", only the notes with the tag
+// are returned.
+func (db *Database) Notes(prefix, tag string) ([]PackageNotes, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Values(notesScript.Do(c, prefix))
+	if err != nil {
+		return nil, err
+	}
+	var result []PackageNotes
+	for len(values) > 0 {
+		var pn PackageNotes
+		var p []byte
+		values, err = redis.Scan(values, &pn.Path, &p)
+		if err != nil {
+			return nil, err
+		}
+		var notes []Note
+		if err := json.Unmarshal(p, &notes); err != nil {
+			return nil, err
+		}
+		for _, n := range notes {
+			if tag == "" || n.Tag == tag {
+				pn.Notes = append(pn.Notes, n)
+			}
+		}
+		if len(pn.Notes) > 0 {
+			result = append(result, pn)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func notesDoc(importPath string) *doc.Package {
+	return &doc.Package{
+		ImportPath: importPath,
+		LineFmt:    "%s#L%d",
+		Files:      []*doc.File{{Name: "a.go", URL: "https://example.com/a.go"}, {Name: "b.go"}},
+		Notes: map[string][]*doc.Note{
+			"BUG":  {{Pos: doc.Pos{Line: 7}, UID: "bob", Body: "Fails on Windows."}},
+			"TODO": {{Pos: doc.Pos{Line: 3, File: 1}, UID: "ann", Body: "Add tests."}, {Pos: doc.Pos{Line: 2}, UID: "ann", Body: "Faster."}},
+		},
+	}
+}
+
+func TestPackageNotes(t *testing.T) {
+	want := []Note{
+		{Tag: "BUG", UID: "bob", Body: "Fails on Windows.", File: "a.go", Line: 7, URL: "https://example.com/a.go#L7"},
+		{Tag: "TODO", UID: "ann", Body: "Faster.", File: "a.go", Line: 2, URL: "https://example.com/a.go#L2"},
+		{Tag: "TODO", UID: "ann", Body: "Add tests.", File: "b.go", Line: 3},
+	}
+	if notes := packageNotes(notesDoc("example.com/p")); !reflect.DeepEqual(notes, want) {
+		t.Errorf("packageNotes() = %+v, want %+v", notes, want)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	c := db.Pool.Get()
+	defer c.Close()
+	for _, p := range []string{"github.com/a/x", "github.com/a/x/y", "github.com/b/z"} {
+		if err := putNotes(c, notesDoc(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := putNotes(c, &doc.Package{ImportPath: "github.com/b/z"}); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := db.Notes("github.com/a", "BUG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, pn := range notes {
+		paths = append(paths, pn.Path)
+		if len(pn.Notes) != 1 || pn.Notes[0].Tag != "BUG" {
+			t.Errorf("notes of %s = %+v, want the BUG note", pn.Path, pn.Notes)
+		}
+	}
+	if want := []string{"github.com/a/x", "github.com/a/x/y"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Notes() paths = %v, want %v", paths, want)
+	}
+
+	notes, err = db.Notes("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 || len(notes[0].Notes) != 3 {
+		t.Errorf("Notes(\"\", \"\") = %+v, want all notes of two packages", notes)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"flag"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/gosrc"
+)
+
+var notFoundTTL = flag.Duration("db-notfound-ttl", time.Hour, "Time to keep the not found results of crawls. Requests for the import paths are not found without a fetch until the result expires. Zero disables the cache.")
+
+// GetNotFound returns the cached not found result of a crawl of the import
+// path or nil if no result is cached.
+func (db *Database) GetNotFound(importPath string) (*gosrc.NotFoundError, error) {
+	if *notFoundTTL <= 0 {
+		return nil, nil
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Strings(c.Do("HMGET", Key("notfound:"+importPath), "message", "redirect"))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 || (values[0] == "" && values[1] == "") {
+		return nil, nil
+	}
+	return &gosrc.NotFoundError{Message: values[0], Redirect: values[1]}, nil
+}
+
+// PutNotFound caches the not found result of a crawl of the import path for
+// -db-notfound-ttl.
+func (db *Database) PutNotFound(importPath string, e gosrc.NotFoundError) error {
+	if *notFoundTTL <= 0 {
+		return nil
+	}
+	if e.Message == "" && e.Redirect == "" {
+		e.Message = "not found"
+	}
+	key := Key("notfound:" + importPath)
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("HMSET", key, "message", e.Message, "redirect", e.Redirect)
+	c.Send("EXPIRE", key, int64(*notFoundTTL/time.Second))
+	_, err := c.Do("EXEC")
+	return err
+}
+
+// DeleteNotFound removes the cached not found result of the import path.
+func (db *Database) DeleteNotFound(importPath string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("DEL", Key("notfound:"+importPath))
+	return err
+}
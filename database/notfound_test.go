@@ -0,0 +1,36 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+func TestNotFound(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	const path = "github.com/user/missing"
+	if e, err := db.GetNotFound(path); err != nil || e != nil {
+		t.Fatalf("GetNotFound() before put = %v, %v, want nil", e, err)
+	}
+	want := gosrc.NotFoundError{Message: "moved", Redirect: "github.com/user/found"}
+	if err := db.PutNotFound(path, want); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := db.GetNotFound(path); err != nil || e == nil || *e != want {
+		t.Errorf("GetNotFound() = %v, %v, want %v", e, err, want)
+	}
+	if err := db.DeleteNotFound(path); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := db.GetNotFound(path); err != nil || e != nil {
+		t.Errorf("GetNotFound() after delete = %v, %v, want nil", e, err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+// platforms returns the value of Platforms for a package document with the
+// given variants.
+func platforms(pdoc *doc.Package, variants []*doc.Package) []string {
+	if len(variants) == 0 {
+		return nil
+	}
+	result := []string{pdoc.Platform()}
+	for _, v := range variants {
+		result = append(result, v.Platform())
+	}
+	return result
+}
+
+func putVariants(c redis.Conn, importPath string, variants []*doc.Package) error {
+	args := redis.Args{Key("platform:" + importPath)}
+	for _, v := range variants {
+		p, err := encodeDoc(v)
+		if err != nil {
+			return err
+		}
+		args = args.Add(v.Platform(), p)
+	}
+	if _, err := c.Do("DEL", args[0]); err != nil {
+		return err
+	}
+	_, err := c.Do("HMSET", args...)
+	return err
+}
+
+// GetVariant returns the documentation of the package for the platform
+// formatted as GOOS/GOARCH or nil if no variant is stored for the platform.
+func (db *Database) GetVariant(importPath, platform string) (*doc.Package, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("HGET", Key("platform:"+importPath), platform))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pdoc doc.Package
+	if err := decodeDoc(p, &pdoc); err != nil {
+		return nil, err
+	}
+	return &pdoc, nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestPlatforms(t *testing.T) {
+	pdoc := &doc.Package{GOOS: "linux", GOARCH: "amd64"}
+	if p := platforms(pdoc, nil); p != nil {
+		t.Errorf("platforms(pdoc, nil) = %v, want nil", p)
+	}
+	variants := []*doc.Package{
+		{GOOS: "windows", GOARCH: "amd64"},
+		{GOOS: "js", GOARCH: "wasm"},
+	}
+	want := []string{"linux/amd64", "windows/amd64", "js/wasm"}
+	if p := platforms(pdoc, variants); !reflect.DeepEqual(p, want) {
+		t.Errorf("platforms(pdoc, variants) = %v, want %v", p, want)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bufio"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// HostCount is the number of packages with import paths on a host.
+type HostCount struct {
+	Host     string `json:"host"`
+	Packages int    `json:"packages"`
+}
+
+// Report is the statistics about the instance computed by NewReport.
+type Report struct {
+	Updated time.Time `json:"updated"`
+
+	// Libraries and commands in the database. Directories are the
+	// directories without Go files saved for their subdirectories.
+	Libraries   int `json:"libraries"`
+	Commands    int `json:"commands"`
+	Directories int `json:"directories"`
+
+	// Suppressed is the number of libraries and commands with a zero search
+	// score, such as the packages opted out of indexing, the clones of other
+	// repositories and the internal packages. They are not in the search
+	// results and the index.
+	Suppressed int `json:"suppressed"`
+
+	Private     int `json:"private"`
+	Blocked     int `json:"blocked"`
+	Quarantined int `json:"quarantined"`
+
+	// Number of libraries and commands by host, most packages first. The
+	// host of the standard library is "std".
+	Hosts []HostCount `json:"hosts"`
+
+	// CrawlDue is the number of packages with a next crawl time in the past,
+	// NewCrawl the number of new import paths waiting for the first crawl
+	// and BadCrawl the number of import paths that failed the first crawl.
+	CrawlDue int `json:"crawlDue"`
+	NewCrawl int `json:"newCrawl"`
+	BadCrawl int `json:"badCrawl"`
+
+	// AverageAge is the average time since the documentation of the
+	// libraries and commands was built.
+	AverageAge time.Duration `json:"averageAge"`
+
+	// Fetches is the fetch statistics of the hosts in the last day, most
+	// fetches first.
+	Fetches []*HostStats `json:"fetches"`
+
+	// Evictions is the number of documents evicted by EvictColdDocs.
+	Evictions *EvictionStats `json:"evictions"`
+
+	// MemoryBytes is the memory used by the Redis server, including the keys
+	// of other namespaces.
+	MemoryBytes int64 `json:"memoryBytes"`
+}
+
+// Packages returns the number of libraries and commands.
+func (r *Report) Packages() int {
+	return r.Libraries + r.Commands
+}
+
+// packageHost returns the host of the import path for the report.
+func packageHost(importPath string) string {
+	host := importPath
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	if !strings.Contains(host, ".") {
+		return "std"
+	}
+	return host
+}
+
+// usedMemory returns the used_memory field of the output of the Redis INFO
+// memory command.
+func usedMemory(info string) int64 {
+	s := bufio.NewScanner(strings.NewReader(info))
+	for s.Scan() {
+		if v := strings.TrimPrefix(s.Text(), "used_memory:"); v != s.Text() {
+			n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// NewReport computes the statistics about the instance. NewReport reads every
+// document in the database.
+func (db *Database) NewReport() (*Report, error) {
+	r := &Report{Updated: time.Now().UTC()}
+	now := time.Now()
+	hosts := make(map[string]int)
+	var age time.Duration
+	err := db.Do(func(pi *PackageInfo) error {
+		switch pi.Kind {
+		case "d":
+			r.Directories++
+			return nil
+		case "c":
+			r.Commands++
+		default:
+			r.Libraries++
+		}
+		if pi.Score == 0 {
+			r.Suppressed++
+		}
+		hosts[packageHost(pi.PDoc.ImportPath)]++
+		age += now.Sub(pi.PDoc.Updated)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n := r.Packages(); n > 0 {
+		r.AverageAge = age / time.Duration(n)
+	}
+	for host, n := range hosts {
+		r.Hosts = append(r.Hosts, HostCount{Host: host, Packages: n})
+	}
+	sort.Slice(r.Hosts, func(i, j int) bool {
+		if r.Hosts[i].Packages != r.Hosts[j].Packages {
+			return r.Hosts[i].Packages > r.Hosts[j].Packages
+		}
+		return r.Hosts[i].Host < r.Hosts[j].Host
+	})
+
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("SCARD", Key("private"))
+	c.Send("SCARD", Key("block"))
+	c.Send("SCARD", Key("blockpat"))
+	c.Send("HLEN", Key("quarantine"))
+	c.Send("ZCOUNT", Key("nextCrawl"), "-inf", now.Unix())
+	c.Send("SCARD", Key("newCrawl"))
+	c.Send("SCARD", Key("badCrawl"))
+	c.Flush()
+	var blockPatterns int
+	for _, n := range []*int{&r.Private, &r.Blocked, &blockPatterns, &r.Quarantined, &r.CrawlDue, &r.NewCrawl, &r.BadCrawl} {
+		if *n, err = redis.Int(c.Receive()); err != nil {
+			return nil, err
+		}
+	}
+	r.Blocked += blockPatterns
+	if r.Evictions, err = db.EvictionStats(); err != nil {
+		return nil, err
+	}
+	if r.Fetches, err = db.AllHostStats(now.Add(-24 * time.Hour)); err != nil {
+		return nil, err
+	}
+	info, err := redis.String(c.Do("INFO", "memory"))
+	if err != nil {
+		return nil, err
+	}
+	r.MemoryBytes = usedMemory(info)
+	return r, nil
+}
+
+// UpdateReport computes the statistics about the instance and saves the
+// statistics for Report.
+func (db *Database) UpdateReport() error {
+	r, err := db.NewReport()
+	if err != nil {
+		return err
+	}
+	return db.PutGob("report", r)
+}
+
+// Report returns the statistics saved by the last UpdateReport or nil if the
+// statistics have not been computed.
+func (db *Database) Report() (*Report, error) {
+	var r *Report
+	if err := db.GetGob("report", &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import "testing"
+
+func TestPackageHost(t *testing.T) {
+	for _, tt := range []struct{ path, want string }{
+		{"github.com/user/repo", "github.com"},
+		{"k8s.io", "k8s.io"},
+		{"net/http", "std"},
+		{"fmt", "std"},
+	} {
+		if got := packageHost(tt.path); got != tt.want {
+			t.Errorf("packageHost(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestUsedMemory(t *testing.T) {
+	info := "# Memory\r\nused_memory:1032576\r\nused_memory_human:1008.38K\r\nused_memory_rss:9412608\r\n"
+	if got := usedMemory(info); got != 1032576 {
+		t.Errorf("usedMemory() = %d, want 1032576", got)
+	}
+	if got := usedMemory(""); got != 0 {
+		t.Errorf("usedMemory(\"\") = %d, want 0", got)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+var searchBackend = flag.String("db-search-index", "redis", "Search index used to find packages: redis or elasticsearch.")
+
+// SearchIndex finds the packages matching a search query. The syntax of the
+// query is defined by package search.
+type SearchIndex interface {
+	// Put adds the package to the index with the search score or removes
+	// the package from the index if the score is zero.
+	Put(pdoc *doc.Package, score float64) error
+
+	// Delete removes the package with the import path from the index.
+	Delete(path string) error
+
+	// Query returns the results of the search query q in any order.
+	Query(q string) ([]*SearchResult, error)
+}
+
+// SearchResult is a package matching a search query.
+type SearchResult struct {
+	Path     string
+	Synopsis string
+	Score    float64
+
+	// True if the package was found by fuzzy matching only.
+	Fuzzy bool
+}
+
+// before returns true if qr is sorted before r in the results of a query.
+func (qr *SearchResult) before(r *SearchResult) bool {
+	if qr.Fuzzy != r.Fuzzy {
+		return !qr.Fuzzy
+	}
+	if qr.Score != r.Score {
+		return qr.Score > r.Score
+	}
+	return qr.Path < r.Path
+}
+
+// redisIndex is the default search index. The search terms of a package are
+// stored by Database.Put in the same script as the document, so Put and
+// Delete do nothing.
+type redisIndex struct {
+	db *Database
+}
+
+func (idx redisIndex) Put(pdoc *doc.Package, score float64) error { return nil }
+func (idx redisIndex) Delete(path string) error                   { return nil }
+
+// newSearchIndex returns the search index selected by the -db-search-index
+// flag.
+func newSearchIndex(db *Database) (SearchIndex, error) {
+	switch *searchBackend {
+	case "redis":
+		return redisIndex{db}, nil
+	case "elasticsearch":
+		return newElasticIndex(*elasticURL, *elasticIndexName)
+	}
+	return nil, fmt.Errorf("database: unknown search index %q", *searchBackend)
+}
+
+func (db *Database) searchIndex() SearchIndex {
+	if db.index == nil {
+		return redisIndex{db}
+	}
+	return db.index
+}
+
+// shadowIndex is implemented by search indexes that can be rebuilt in a
+// shadow copy while the live index serves queries.
+type shadowIndex interface {
+	SearchIndex
+
+	// newShadow creates an empty copy of the index and returns the name of
+	// the copy.
+	newShadow() (string, error)
+
+	// shadow returns the copy of the index with the name.
+	shadow(name string) SearchIndex
+
+	// swap atomically replaces the live index with the copy.
+	swap(name string) error
+
+	// drop deletes the copy.
+	drop(name string) error
+}
+
+// shadowSearchIndex returns the copy of the search index being rebuilt by
+// RebuildSearchIndex or nil if the index is not being rebuilt. The name of
+// the copy is stored in the searchShadow key so that the documents put by
+// other processes during a rebuild are also put in the copy.
+func (db *Database) shadowSearchIndex(c redis.Conn) (SearchIndex, error) {
+	idx, ok := db.searchIndex().(shadowIndex)
+	if !ok {
+		return nil, nil
+	}
+	name, err := redis.String(c.Do("GET", Key("searchShadow")))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return idx.shadow(name), nil
+}
+
+// RebuildSearchIndex rebuilds the search index from the stored documents in
+// a copy of the index while the live index serves queries, then replaces the
+// live index with the copy. Progress is called with the number of packages
+// indexed and the total number of packages.
+func (db *Database) RebuildSearchIndex(progress func(n, total int)) error {
+	idx, ok := db.searchIndex().(shadowIndex)
+	if !ok {
+		return fmt.Errorf("database: search index %s cannot be rebuilt in a shadow copy", *searchBackend)
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	total, err := redis.Int(c.Do("HLEN", Key("ids")))
+	if err != nil {
+		return err
+	}
+	name, err := idx.newShadow()
+	if err != nil {
+		return err
+	}
+	ok, err = redis.Bool(c.Do("SETNX", Key("searchShadow"), name))
+	if err == nil && !ok {
+		err = errors.New("database: search index is already being rebuilt")
+	}
+	if err != nil {
+		idx.drop(name)
+		return err
+	}
+
+	shadow := idx.shadow(name)
+	n := 0
+	err = db.Do(func(pi *PackageInfo) error {
+		n++
+		if n%1000 == 0 {
+			progress(n, total)
+		}
+		return shadow.Put(pi.PDoc, pi.Score)
+	})
+	if err == nil {
+		progress(n, total)
+		err = idx.swap(name)
+	}
+	if err != nil {
+		idx.drop(name)
+	}
+	if _, delErr := c.Do("DEL", Key("searchShadow")); err == nil {
+		err = delErr
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ProjectSettings are the settings of a project set by the verified owner.
+// The settings are kept when the project is crawled.
+type ProjectSettings struct {
+	// Hidden is true if the packages in the project are hidden from the
+	// search results as if the owner opted out of indexing.
+	Hidden bool `json:"hidden,omitempty"`
+
+	// Canonical is the import path shown in a notice on the pages of the
+	// packages in the project, for example the new import path of a project
+	// that moved.
+	Canonical string `json:"canonical,omitempty"`
+
+	// Vendor is true if the packages in the vendor directory at the root of
+	// the project are indexed. The vendored packages are shown on pages
+	// labelled as vendored by the project and are hidden from the search
+	// results.
+	Vendor bool `json:"vendor,omitempty"`
+}
+
+// SetProjectSettings sets the settings of the project with the given root.
+func (db *Database) SetProjectSettings(projectRoot string, s ProjectSettings) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	root := normalizeProjectRoot(projectRoot)
+	if s == (ProjectSettings{}) {
+		_, err := c.Do("HDEL", Key("settings"), root)
+		return err
+	}
+	p, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+	_, err = c.Do("HSET", Key("settings"), root, p)
+	return err
+}
+
+// VendorIndexed returns true if the owner of the project with the root set
+// the packages in the vendor directory of the project to be indexed.
+func (db *Database) VendorIndexed(projectRoot string) bool {
+	s, err := db.ProjectSettings(projectRoot)
+	return err == nil && s.Vendor
+}
+
+// ProjectSettings returns the settings of the project with the given root.
+func (db *Database) ProjectSettings(projectRoot string) (ProjectSettings, error) {
+	var s ProjectSettings
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("HGET", Key("settings"), normalizeProjectRoot(projectRoot)))
+	if err == redis.ErrNil {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(p, &s)
+	return s, err
+}
@@ -0,0 +1,31 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import "testing"
+
+func TestProjectSettings(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	want := ProjectSettings{Hidden: true, Canonical: "example.com/new", Vendor: true}
+	if err := db.SetProjectSettings("github.com/user/repo", want); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := db.ProjectSettings("github.com/user/repo"); err != nil || s != want {
+		t.Errorf("ProjectSettings() = %+v, %v, want %+v", s, err, want)
+	}
+	if !db.VendorIndexed("github.com/user/repo") || db.VendorIndexed("github.com/user/other") {
+		t.Error("VendorIndexed does not return the vendor setting")
+	}
+	if err := db.SetProjectSettings("github.com/user/repo", ProjectSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := db.ProjectSettings("github.com/user/repo"); err != nil || s != (ProjectSettings{}) {
+		t.Errorf("ProjectSettings() after reset = %+v, %v", s, err)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// similarSize is the number of similar packages stored for a package.
+	similarSize = 5
+
+	// maxSimilarFanout is the maximum number of packages sharing an
+	// importer or a word considered similar through that importer or word.
+	// Importers of many packages and common words say little about the
+	// similarity of packages.
+	maxSimilarFanout = 100
+
+	// minSimilarity is the minimum similarity of a similar package.
+	minSimilarity = 0.1
+)
+
+// similarDoc is the part of a package document used to find similar
+// packages.
+type similarDoc struct {
+	path    string
+	project string
+	indexed bool
+	imports []string
+	words   map[string]bool
+}
+
+// newSimilarDoc returns the similarDoc for the package with the search terms
+// and score stored by Put. The words are the search terms for the name,
+// synopsis, doc comments and README file.
+func newSimilarDoc(path, terms string, score float64) *similarDoc {
+	d := &similarDoc{path: path, indexed: score > 0, words: make(map[string]bool)}
+	for _, term := range strings.Fields(terms) {
+		switch {
+		case strings.HasPrefix(term, "import:"):
+			d.imports = append(d.imports, term[len("import:"):])
+		case strings.HasPrefix(term, "project:"):
+			d.project = term[len("project:"):]
+		case strings.HasPrefix(term, textTermPrefix):
+			d.words[term[len(textTermPrefix):]] = true
+		case !strings.Contains(term, ":"):
+			d.words[term] = true
+		}
+	}
+	return d
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	n := 0
+	for k := range a {
+		if b[k] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a)+len(b)-n)
+}
+
+type similarity struct {
+	path  string
+	score float64
+}
+
+type bySimilarity []similarity
+
+func (p bySimilarity) Len() int { return len(p) }
+func (p bySimilarity) Less(i, j int) bool {
+	if p[i].score != p[j].score {
+		return p[i].score > p[j].score
+	}
+	return p[i].path < p[j].path
+}
+func (p bySimilarity) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// similarPackages returns the import paths of up to n indexed packages most
+// similar to each indexed package in docs. The similarity of two packages is
+// the mean of the Jaccard indexes of their importers and of their words.
+// Packages in the same project are not similar.
+func similarPackages(docs []*similarDoc, n int) map[string][]string {
+	indexed := make(map[string]*similarDoc)
+	all := make(map[string]*similarDoc)
+	importers := make(map[string]map[string]bool)
+	postings := make(map[string][]*similarDoc)
+	for _, d := range docs {
+		all[d.path] = d
+		for _, p := range d.imports {
+			if importers[p] == nil {
+				importers[p] = make(map[string]bool)
+			}
+			importers[p][d.path] = true
+		}
+		if d.indexed {
+			indexed[d.path] = d
+			for w := range d.words {
+				postings[w] = append(postings[w], d)
+			}
+		}
+	}
+
+	result := make(map[string][]string)
+	for _, a := range indexed {
+		candidates := make(map[*similarDoc]bool)
+		for p := range importers[a.path] {
+			if importer := all[p]; importer != nil && len(importer.imports) <= maxSimilarFanout {
+				for _, ip := range importer.imports {
+					if b := indexed[ip]; b != nil {
+						candidates[b] = true
+					}
+				}
+			}
+		}
+		for w := range a.words {
+			if ds := postings[w]; len(ds) <= maxSimilarFanout {
+				for _, b := range ds {
+					candidates[b] = true
+				}
+			}
+		}
+
+		var similar []similarity
+		for b := range candidates {
+			if b == a || (a.project != "" && b.project == a.project) {
+				continue
+			}
+			s := (jaccard(importers[a.path], importers[b.path]) + jaccard(a.words, b.words)) / 2
+			if s >= minSimilarity {
+				similar = append(similar, similarity{b.path, s})
+			}
+		}
+		sort.Sort(bySimilarity(similar))
+		if len(similar) > n {
+			similar = similar[:n]
+		}
+		for _, s := range similar {
+			result[a.path] = append(result[a.path], s.path)
+		}
+	}
+	return result
+}
+
+// UpdateSimilar finds the similar packages of every indexed package. The
+// similar packages are replaced atomically when all packages are done.
+func (db *Database) UpdateSimilar() error {
+	c := db.Pool.Get()
+	defer c.Close()
+
+	ids, err := redis.StringMap(c.Do("HGETALL", Key("ids")))
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(ids))
+	for path := range ids {
+		paths = append(paths, path)
+	}
+
+	const batchSize = 1000
+	docs := make([]*similarDoc, 0, len(paths))
+	for i := 0; i < len(paths); i += batchSize {
+		batch := paths[i:]
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		for _, path := range batch {
+			c.Send("HMGET", Key("pkg:"+ids[path]), "terms", "score")
+		}
+		c.Flush()
+		for _, path := range batch {
+			values, err := redis.Values(c.Receive())
+			if err != nil {
+				return err
+			}
+			var terms string
+			var score float64
+			if _, err := redis.Scan(values, &terms, &score); err != nil {
+				return err
+			}
+			docs = append(docs, newSimilarDoc(path, terms, score))
+		}
+	}
+
+	similar := similarPackages(docs, similarSize)
+	tmp := Key("tmp:similar")
+	if _, err := c.Do("DEL", tmp); err != nil {
+		return err
+	}
+	args := []interface{}{tmp}
+	for path, s := range similar {
+		args = append(args, path, strings.Join(s, " "))
+		if len(args) > 2*batchSize {
+			if _, err := c.Do("HMSET", args...); err != nil {
+				return err
+			}
+			args = args[:1]
+		}
+	}
+	if len(args) > 1 {
+		if _, err := c.Do("HMSET", args...); err != nil {
+			return err
+		}
+	}
+	if len(similar) == 0 {
+		_, err = c.Do("DEL", Key("similar"))
+		return err
+	}
+	_, err = c.Do("RENAME", tmp, Key("similar"))
+	return err
+}
+
+// Similar returns the packages similar to the package with the import path,
+// most similar first. The similar packages are found by UpdateSimilar.
+func (db *Database) Similar(path string) ([]Package, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	s, err := redis.String(c.Do("HGET", Key("similar"), path))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var args []interface{}
+	for _, p := range strings.Fields(s) {
+		args = append(args, p)
+	}
+	values, err := redis.Values(packagesScript.Do(c, args...))
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []Package
+	for len(values) > 0 {
+		var pkg Package
+		var kind string
+		values, err = redis.Scan(values, &pkg.Path, &pkg.Synopsis, &kind)
+		if err != nil {
+			return nil, err
+		}
+		// Skip the packages deleted since the last update.
+		if kind != "u" {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimilarPackages(t *testing.T) {
+	docs := []*similarDoc{
+		newSimilarDoc("github.com/a/mux", "project:github.com/a/mux rout http text:handler", 1),
+		newSimilarDoc("github.com/a/mux/internal", "project:github.com/a/mux rout http", 0),
+		newSimilarDoc("github.com/a/mux/sub", "project:github.com/a/mux rout http", 1),
+		newSimilarDoc("github.com/b/router", "project:github.com/b/router rout http", 1),
+		newSimilarDoc("github.com/c/yaml", "project:github.com/c/yaml yaml", 1),
+		newSimilarDoc("github.com/d/app", "project:github.com/d/app import:github.com/a/mux import:github.com/b/router", 0),
+	}
+	got := similarPackages(docs, 5)
+	want := map[string][]string{
+		"github.com/a/mux":     {"github.com/b/router"},
+		"github.com/a/mux/sub": {"github.com/b/router"},
+		"github.com/b/router":  {"github.com/a/mux", "github.com/a/mux/sub"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("similarPackages = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"flag"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/doc"
+)
+
+var maxSourceSize = flag.Int("db-max-source-size", 1<<20, "Maximum total size in bytes of the source files stored for a package. Zero disables stored sources.")
+
+// storeSources returns true if the sources should be stored.
+func storeSources(sources []*doc.Source) bool {
+	if len(sources) == 0 {
+		return false
+	}
+	n := 0
+	for _, s := range sources {
+		n += len(s.Data)
+	}
+	return n <= *maxSourceSize
+}
+
+func putSources(c redis.Conn, importPath string, sources []*doc.Source) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sources); err != nil {
+		return err
+	}
+	_, err := c.Do("SET", Key("source:"+importPath), encodeValue(buf.Bytes()))
+	return err
+}
+
+// GetSource returns the stored source of the file with the given name in the
+// package or nil if the source is not stored.
+func (db *Database) GetSource(importPath, name string) (*doc.Source, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("GET", Key("source:"+importPath)))
+	if err == redis.ErrNil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	p, err = decodeValue(p)
+	if err != nil {
+		return nil, err
+	}
+	var sources []*doc.Source
+	if err := gob.NewDecoder(bytes.NewReader(p)).Decode(&sources); err != nil {
+		return nil, err
+	}
+	for _, s := range sources {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestStoreSources(t *testing.T) {
+	defer func(n int) { *maxSourceSize = n }(*maxSourceSize)
+	*maxSourceSize = 10
+	sources := []*doc.Source{{Name: "a.go", Data: []byte("12345")}, {Name: "b.go", Data: []byte("12345")}}
+	if !storeSources(sources) {
+		t.Errorf("storeSources returned false for sources at the size limit")
+	}
+	sources[1].Data = append(sources[1].Data, '6')
+	if storeSources(sources) {
+		t.Errorf("storeSources returned true for sources above the size limit")
+	}
+	if storeSources(nil) {
+		t.Errorf("storeSources returned true for no sources")
+	}
+}
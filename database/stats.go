@@ -0,0 +1,149 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"flag"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var statsDays = flag.Int("db-stats-days", 90, "Number of days to keep daily package statistics.")
+
+// DailyStats is the popularity of a package on a day.
+type DailyStats struct {
+	Day       time.Time `json:"day"`
+	Views     int       `json:"views"`
+	Importers int       `json:"importers"`
+}
+
+func statsDay(t time.Time) string {
+	return t.UTC().Format("20060102")
+}
+
+// statsExpiration returns the time to live in seconds for a day of statistics.
+func statsExpiration() int {
+	return (*statsDays + 1) * 24 * 60 * 60
+}
+
+// IncrementViews increments today's page view count for the package with the
+// given import path.
+func (db *Database) IncrementViews(path string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	key := Key("views:" + statsDay(time.Now()))
+	if _, err := c.Do("HINCRBY", key, path, 1); err != nil {
+		return err
+	}
+	_, err := c.Do("EXPIRE", key, statsExpiration())
+	return err
+}
+
+// RecentViews returns the page views of the package with the given import
+// path in the last days days, including today.
+func (db *Database) RecentViews(path string, days int) (int, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	now := time.Now()
+	for i := 0; i < days; i++ {
+		c.Send("HGET", Key("views:"+statsDay(now.AddDate(0, 0, -i))), path)
+	}
+	c.Flush()
+	views := 0
+	for i := 0; i < days; i++ {
+		n, err := redis.Int(c.Receive())
+		if err != nil && err != redis.ErrNil {
+			return 0, err
+		}
+		views += n
+	}
+	return views, nil
+}
+
+// SnapshotImporterCounts records today's importer count for every package.
+// The count is also saved with the package for sorting importer pages.
+func (db *Database) SnapshotImporterCounts() error {
+	c := db.Pool.Get()
+	defer c.Close()
+
+	ids, err := redis.StringMap(c.Do("HGETALL", Key("ids")))
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(ids))
+	for path := range ids {
+		paths = append(paths, path)
+	}
+
+	const batchSize = 1000
+	key := Key("importers:" + statsDay(time.Now()))
+	for len(paths) > 0 {
+		batch := paths
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		paths = paths[len(batch):]
+
+		for _, path := range batch {
+			c.Send("SCARD", Key("index:import:"+path))
+		}
+		c.Flush()
+		args := []interface{}{key}
+		counts := make([]int, len(batch))
+		for i, path := range batch {
+			n, err := redis.Int(c.Receive())
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				args = append(args, path, n)
+			}
+			counts[i] = n
+		}
+		c.Send("MULTI")
+		if len(args) > 1 {
+			c.Send("HMSET", args...)
+		}
+		for i, path := range batch {
+			c.Send("HSET", Key("pkg:"+ids[path]), "importers", counts[i])
+		}
+		if _, err := c.Do("EXEC"); err != nil {
+			return err
+		}
+	}
+	_, err = c.Do("EXPIRE", key, statsExpiration())
+	return err
+}
+
+// PackageStats returns the daily statistics for the package with the given
+// import path for the last days days, oldest first.
+func (db *Database) PackageStats(path string, days int) ([]DailyStats, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	stats := make([]DailyStats, days)
+	for i := range stats {
+		stats[i].Day = today.AddDate(0, 0, i-days+1)
+		day := statsDay(stats[i].Day)
+		c.Send("HGET", Key("views:"+day), path)
+		c.Send("HGET", Key("importers:"+day), path)
+	}
+	c.Flush()
+	for i := range stats {
+		var err error
+		if stats[i].Views, err = redis.Int(c.Receive()); err != nil && err != redis.ErrNil {
+			return nil, err
+		}
+		if stats[i].Importers, err = redis.Int(c.Receive()); err != nil && err != redis.ErrNil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
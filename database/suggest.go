@@ -0,0 +1,116 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"path"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// minSuggestPrefix and maxSuggestPrefix are the lengths of the shortest
+	// and longest prefixes in the suggestion index.
+	minSuggestPrefix = 2
+	maxSuggestPrefix = 32
+
+	// suggestSetSize is the number of packages kept for each prefix.
+	suggestSetSize = 50
+)
+
+// suggestPrefixes returns the prefixes of the lowercase import path, package
+// name and last path element used to find the package in the suggestion
+// index.
+func suggestPrefixes(importPath, name string) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, s := range []string{importPath, name, path.Base(importPath)} {
+		s = strings.ToLower(s)
+		for n := minSuggestPrefix; n <= len(s) && n <= maxSuggestPrefix; n++ {
+			p := s[:n]
+			if !seen[p] {
+				seen[p] = true
+				prefixes = append(prefixes, p)
+			}
+		}
+	}
+	return prefixes
+}
+
+// putSuggestScript replaces the prefixes of the package in ARGV[1] with the
+// space separated prefixes in ARGV[3]. The package is removed from the index
+// if the score in ARGV[2] is zero.
+var putSuggestScript = newScript(`
+    local path = ARGV[1]
+    local score = tonumber(ARGV[2])
+    local prefixes = ARGV[3]
+    local n = tonumber(ARGV[4])
+
+    for p in string.gmatch(redis.call('HGET', 'suggest', path) or '', '([^ ]+)') do
+        redis.call('ZREM', 'suggest:' .. p, path)
+    end
+
+    if score <= 0 or prefixes == '' then
+        return redis.call('HDEL', 'suggest', path)
+    end
+
+    for p in string.gmatch(prefixes, '([^ ]+)') do
+        redis.call('ZADD', 'suggest:' .. p, score, path)
+        redis.call('ZREMRANGEBYRANK', 'suggest:' .. p, 0, -(n + 1))
+    end
+    return redis.call('HSET', 'suggest', path, prefixes)
+`)
+
+func putSuggest(c redis.Conn, importPath, name string, score float64) error {
+	prefixes := ""
+	if score > 0 {
+		prefixes = strings.Join(suggestPrefixes(importPath, name), " ")
+	}
+	_, err := putSuggestScript.Do(c, importPath, score, prefixes, suggestSetSize)
+	return err
+}
+
+var suggestScript = newScript(`
+    local result = {}
+    for _, path in ipairs(redis.call('ZREVRANGE', 'suggest:' .. ARGV[1], 0, -1)) do
+        local id = redis.call('HGET', 'ids', path)
+        if id then
+            result[#result+1] = path
+            result[#result+1] = redis.call('HGET', 'pkg:' .. id, 'synopsis') or ''
+        end
+    end
+    return result
+`)
+
+// Suggest returns up to n packages with an import path, package name or last
+// path element starting with prefix, ordered by search score.
+func (db *Database) Suggest(prefix string, n int) ([]Package, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if len(prefix) < minSuggestPrefix || strings.Contains(prefix, " ") {
+		return nil, nil
+	}
+	key := prefix
+	if len(key) > maxSuggestPrefix {
+		key = key[:maxSuggestPrefix]
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.Strings(suggestScript.Do(c, key))
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []Package
+	for i := 0; i+1 < len(values) && len(pkgs) < n; i += 2 {
+		p := strings.ToLower(values[i])
+		if len(prefix) > maxSuggestPrefix && !strings.HasPrefix(p, prefix) && !strings.HasPrefix(path.Base(p), prefix) {
+			continue
+		}
+		pkgs = append(pkgs, Package{Path: values[i], Synopsis: values[i+1]})
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestPrefixes(t *testing.T) {
+	got := suggestPrefixes("github.com/Gorilla/mux", "mux")
+	seen := make(map[string]bool)
+	for _, p := range got {
+		if seen[p] {
+			t.Errorf("duplicate prefix %q", p)
+		}
+		seen[p] = true
+	}
+	for _, p := range []string{"gi", "github.com/", "github.com/gorilla/mux", "mu", "mux"} {
+		if !seen[p] {
+			t.Errorf("prefix %q not found in %v", p, got)
+		}
+	}
+	if seen["g"] || seen["m"] {
+		t.Errorf("prefixes %v include prefixes shorter than %d", got, minSuggestPrefix)
+	}
+
+	long := "example.com/" + "abcdefghijklmnopqrstuvwxyz/abcdefghijklmnopqrstuvwxyz"
+	for _, p := range suggestPrefixes(long, "") {
+		if len(p) > maxSuggestPrefix {
+			t.Errorf("prefix %q is longer than %d", p, maxSuggestPrefix)
+		}
+	}
+
+	if got := suggestPrefixes("io", "io"); !reflect.DeepEqual(got, []string{"io"}) {
+		t.Errorf("suggestPrefixes(io) = %v, want [io]", got)
+	}
+}
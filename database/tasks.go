@@ -0,0 +1,42 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// PauseTask pauses the background task with the name in all the processes
+// sharing the database until ResumeTask is called.
+func (db *Database) PauseTask(name string) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("SADD", Key("pausedtasks"), name)
+	return err
+}
+
+// ResumeTask resumes the background task with the name and returns false if
+// the task was not paused.
+func (db *Database) ResumeTask(name string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Bool(c.Do("SREM", Key("pausedtasks"), name))
+}
+
+// PausedTasks returns the sorted names of the paused background tasks.
+func (db *Database) PausedTasks() ([]string, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	names, err := redis.Strings(c.Do("SMEMBERS", Key("pausedtasks")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
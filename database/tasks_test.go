@@ -0,0 +1,36 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPausedTasks(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	for _, name := range []string{"Crawl", "Sitemaps", "Crawl"} {
+		if err := db.PauseTask(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, err := db.ResumeTask("Sitemaps"); err != nil || !ok {
+		t.Errorf("ResumeTask(Sitemaps) = %v, %v, want true", ok, err)
+	}
+	if ok, err := db.ResumeTask("Docsets"); err != nil || ok {
+		t.Errorf("ResumeTask(Docsets) = %v, %v, want false", ok, err)
+	}
+	names, err := db.PausedTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Crawl"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("PausedTasks() = %v, want %v", names, want)
+	}
+}
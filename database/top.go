@@ -0,0 +1,213 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// topSize is the number of packages in each list of top packages.
+	topSize = 50
+
+	// topWeek is the number of days in the periods compared for trending
+	// packages.
+	topWeek = 7
+
+	// trendSmoothing is added to the count of the previous period when
+	// computing growth so that packages with few views or importers do not
+	// trend on small changes.
+	trendSmoothing = 10
+)
+
+// TopPackage is a package in a list of top packages.
+type TopPackage struct {
+	Path     string  `json:"path"`
+	Synopsis string  `json:"synopsis,omitempty"`
+	Count    int     `json:"count"`
+	Growth   float64 `json:"growth,omitempty"`
+}
+
+// Top is the lists of top packages computed by UpdateTop.
+type Top struct {
+	Updated time.Time
+
+	// Packages with the most importers.
+	Imported []TopPackage
+
+	// Packages with the most page views in the last week.
+	Viewed []TopPackage
+
+	// Packages with the largest relative growth in views and importers
+	// from the week before to the last week. The count is the views in the
+	// last week.
+	Trending []TopPackage
+}
+
+// topCounts returns the packages with the largest counts, largest first.
+func topCounts(counts map[string]int, n int) []TopPackage {
+	var pkgs []TopPackage
+	for path, count := range counts {
+		if count > 0 {
+			pkgs = append(pkgs, TopPackage{Path: path, Count: count})
+		}
+	}
+	sort.Sort(byCount(pkgs))
+	if len(pkgs) > n {
+		pkgs = pkgs[:n]
+	}
+	return pkgs
+}
+
+// growth returns the relative growth from prev to cur.
+func growth(prev, cur int) float64 {
+	return float64(cur-prev) / float64(prev+trendSmoothing)
+}
+
+// trending returns the packages with the largest growth in views and
+// importers from the previous week to the last week.
+func trending(prevViews, views, prevImporters, importers map[string]int, n int) []TopPackage {
+	var pkgs []TopPackage
+	for path, v := range views {
+		g := growth(prevViews[path], v)
+		if i, ok := importers[path]; ok {
+			g += growth(prevImporters[path], i)
+		}
+		if g > 0 {
+			pkgs = append(pkgs, TopPackage{Path: path, Count: v, Growth: g})
+		}
+	}
+	sort.Sort(byGrowth(pkgs))
+	if len(pkgs) > n {
+		pkgs = pkgs[:n]
+	}
+	return pkgs
+}
+
+type byCount []TopPackage
+
+func (p byCount) Len() int { return len(p) }
+func (p byCount) Less(i, j int) bool {
+	if p[i].Count != p[j].Count {
+		return p[i].Count > p[j].Count
+	}
+	return p[i].Path < p[j].Path
+}
+func (p byCount) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+type byGrowth []TopPackage
+
+func (p byGrowth) Len() int { return len(p) }
+func (p byGrowth) Less(i, j int) bool {
+	if p[i].Growth != p[j].Growth {
+		return p[i].Growth > p[j].Growth
+	}
+	return p[i].Path < p[j].Path
+}
+func (p byGrowth) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// dailyCounts returns the sum of the counts in the daily statistics hashes
+// with the prefix for the days from start to end, exclusive.
+func dailyCounts(c redis.Conn, prefix string, start, end time.Time) (map[string]int, error) {
+	counts := make(map[string]int)
+	for t := start; t.Before(end); t = t.AddDate(0, 0, 1) {
+		m, err := redis.IntMap(c.Do("HGETALL", Key(prefix+statsDay(t))))
+		if err != nil {
+			return nil, err
+		}
+		for path, n := range m {
+			counts[path] += n
+		}
+	}
+	return counts, nil
+}
+
+// UpdateTop computes the lists of top packages from the daily statistics and
+// saves the lists for Top.
+func (db *Database) UpdateTop() error {
+	c := db.Pool.Get()
+	defer c.Close()
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	tomorrow := today.AddDate(0, 0, 1)
+	weekAgo := today.AddDate(0, 0, 1-topWeek)
+	twoWeeksAgo := weekAgo.AddDate(0, 0, -topWeek)
+
+	views, err := dailyCounts(c, "views:", weekAgo, tomorrow)
+	if err != nil {
+		return err
+	}
+	prevViews, err := dailyCounts(c, "views:", twoWeeksAgo, weekAgo)
+	if err != nil {
+		return err
+	}
+	importers, err := dailyCounts(c, "importers:", today, tomorrow)
+	if err != nil {
+		return err
+	}
+	if len(importers) == 0 {
+		// Use yesterday's snapshot before today's snapshot is taken.
+		importers, err = dailyCounts(c, "importers:", today.AddDate(0, 0, -1), today)
+		if err != nil {
+			return err
+		}
+	}
+	prevImporters, err := dailyCounts(c, "importers:", weekAgo.AddDate(0, 0, -1), weekAgo)
+	if err != nil {
+		return err
+	}
+
+	top := &Top{
+		Updated:  now,
+		Imported: topCounts(importers, topSize),
+		Viewed:   topCounts(views, topSize),
+		Trending: trending(prevViews, views, prevImporters, importers, topSize),
+	}
+	for _, pkgs := range [][]TopPackage{top.Imported, top.Viewed, top.Trending} {
+		if err := topSynopses(c, pkgs); err != nil {
+			return err
+		}
+	}
+	return db.PutGob("top", top)
+}
+
+// topSynopses sets the synopses of the packages.
+func topSynopses(c redis.Conn, pkgs []TopPackage) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	var args []interface{}
+	for _, pkg := range pkgs {
+		args = append(args, pkg.Path)
+	}
+	values, err := redis.Values(packagesScript.Do(c, args...))
+	if err != nil {
+		return err
+	}
+	for i := range pkgs {
+		var path, kind string
+		values, err = redis.Scan(values, &path, &pkgs[i].Synopsis, &kind)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Top returns the lists of top packages saved by the last UpdateTop or nil if
+// the lists have not been computed.
+func (db *Database) Top() (*Top, error) {
+	var top *Top
+	if err := db.GetGob("top", &top); err != nil {
+		return nil, err
+	}
+	return top, nil
+}
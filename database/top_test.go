@@ -0,0 +1,38 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func topPaths(pkgs []TopPackage) []string {
+	var paths []string
+	for _, pkg := range pkgs {
+		paths = append(paths, pkg.Path)
+	}
+	return paths
+}
+
+func TestTopCounts(t *testing.T) {
+	pkgs := topCounts(map[string]int{"a": 1, "b": 3, "c": 3, "d": 0}, 2)
+	if paths := topPaths(pkgs); !reflect.DeepEqual(paths, []string{"b", "c"}) {
+		t.Errorf("topCounts = %v, want [b c]", paths)
+	}
+}
+
+func TestTrending(t *testing.T) {
+	prevViews := map[string]int{"big": 1000, "new": 0, "steady": 20, "small": 1}
+	views := map[string]int{"big": 1100, "new": 30, "steady": 20, "small": 3}
+	prevImporters := map[string]int{"steady": 5}
+	importers := map[string]int{"steady": 10}
+	pkgs := trending(prevViews, views, prevImporters, importers, 10)
+	if paths := topPaths(pkgs); !reflect.DeepEqual(paths, []string{"new", "steady", "small", "big"}) {
+		t.Errorf("trending = %v, want [new steady small big]", paths)
+	}
+}
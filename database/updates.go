@@ -0,0 +1,37 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// publishUpdate publishes the import path of a package saved by Put to the
+// subscribers of SubscribeUpdates in all processes using the database.
+func publishUpdate(c redis.Conn, path string) error {
+	_, err := c.Do("PUBLISH", Key("updates"), path)
+	return err
+}
+
+// SubscribeUpdates calls fn with the import path of each package saved after
+// the subscription. SubscribeUpdates returns when the connection to the
+// database fails.
+func (db *Database) SubscribeUpdates(fn func(path string)) error {
+	c := redis.PubSubConn{Conn: db.Pool.Get()}
+	defer c.Close()
+	if err := c.Subscribe(Key("updates")); err != nil {
+		return err
+	}
+	for {
+		switch v := c.Receive().(type) {
+		case redis.Message:
+			fn(string(v.Data))
+		case error:
+			return v
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/gddo/gosrc"
+)
+
+// StdlibModule is the module path of the standard library packages in the
+// Go vulnerability database.
+const StdlibModule = "stdlib"
+
+// Vuln is a known vulnerability of a module.
+type Vuln struct {
+	// ID is the identifier of the vulnerability, such as GO-2022-0969.
+	ID string `json:"id"`
+
+	// Aliases are other identifiers of the vulnerability, such as CVE
+	// identifiers.
+	Aliases []string `json:"aliases,omitempty"`
+
+	Summary  string    `json:"summary,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Modified time.Time `json:"modified"`
+
+	// Fixed are the versions of the module fixing the vulnerability.
+	Fixed []string `json:"fixed,omitempty"`
+
+	// Packages are the affected packages of the module. All packages of the
+	// module are affected if Packages is empty.
+	Packages []VulnPackage `json:"packages,omitempty"`
+}
+
+// VulnPackage is a package affected by a vulnerability.
+type VulnPackage struct {
+	Path string `json:"path"`
+
+	// Symbols are the affected functions, methods and types of the package.
+	// All symbols are affected if Symbols is empty.
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// affects returns the affected package with the import path or false if the
+// vulnerability does not affect the package.
+func (v *Vuln) affects(importPath string) (VulnPackage, bool) {
+	if len(v.Packages) == 0 {
+		return VulnPackage{Path: importPath}, true
+	}
+	for _, p := range v.Packages {
+		if p.Path == importPath {
+			return p, true
+		}
+	}
+	return VulnPackage{}, false
+}
+
+// SetVulns replaces the known vulnerabilities with the vulnerabilities of
+// each module.
+func (db *Database) SetVulns(vulns map[string][]Vuln) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	args := redis.Args{Key("vulns")}
+	for module, v := range vulns {
+		if len(v) == 0 {
+			continue
+		}
+		p, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		args = args.Add(module, p)
+	}
+	c.Send("MULTI")
+	c.Send("DEL", Key("vulns"))
+	if len(args) > 1 {
+		c.Send("HMSET", args...)
+	}
+	_, err := c.Do("EXEC")
+	return err
+}
+
+// Vulns returns the known vulnerabilities affecting the package with the
+// import path, newest first. The vulnerabilities of the module containing
+// the package are found by looking up each prefix of the import path. Each
+// returned vulnerability has only the entry for the package in Packages.
+func (db *Database) Vulns(importPath string) ([]Vuln, error) {
+	args := []interface{}{Key("vulns")}
+	if gosrc.IsGoRepoPath(importPath) {
+		args = append(args, StdlibModule)
+	} else {
+		for p := importPath; ; {
+			args = append(args, p)
+			i := strings.LastIndex(p, "/")
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.ByteSlices(c.Do("HMGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	var result []Vuln
+	for _, p := range values {
+		if p == nil {
+			continue
+		}
+		var vulns []Vuln
+		if err := json.Unmarshal(p, &vulns); err != nil {
+			return nil, err
+		}
+		for _, v := range vulns {
+			if pkg, ok := v.affects(importPath); ok {
+				v.Packages = []VulnPackage{pkg}
+				result = append(result, v)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+	return result, nil
+}
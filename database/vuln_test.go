@@ -0,0 +1,68 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"testing"
+)
+
+func TestVulns(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	if err := db.SetVulns(map[string][]Vuln{
+		"github.com/user/repo": {
+			{ID: "GO-2022-0001", Fixed: []string{"v1.2.3"}},
+			{ID: "GO-2023-0002", Packages: []VulnPackage{{Path: "github.com/user/repo/sub", Symbols: []string{"Parse"}}}},
+		},
+		StdlibModule: {
+			{ID: "GO-2023-0003", Packages: []VulnPackage{{Path: "net/http", Symbols: []string{"Server.Serve"}}}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		path string
+		want []string
+	}{
+		{"github.com/user/repo", []string{"GO-2022-0001"}},
+		{"github.com/user/repo/sub", []string{"GO-2023-0002", "GO-2022-0001"}},
+		{"github.com/user/other", nil},
+		{"net/http", []string{"GO-2023-0003"}},
+		{"net/url", nil},
+	} {
+		vulns, err := db.Vulns(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range vulns {
+			got = append(got, v.ID)
+			if len(v.Packages) != 1 || v.Packages[0].Path != tt.path {
+				t.Errorf("Vulns(%q) %s packages = %v", tt.path, v.ID, v.Packages)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Vulns(%q) = %v, want %v", tt.path, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Vulns(%q) = %v, want %v", tt.path, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if err := db.SetVulns(nil); err != nil {
+		t.Fatal(err)
+	}
+	if vulns, err := db.Vulns("github.com/user/repo"); err != nil || len(vulns) != 0 {
+		t.Errorf("Vulns() after SetVulns(nil) = %v, %v", vulns, err)
+	}
+}
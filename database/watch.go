@@ -0,0 +1,223 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// A watch subscribes an email address or a webhook to the notifications of
+// the packages at and below an import path prefix. The events of the watched
+// packages are queued until the notifications are sent. Events are queued
+// only when there are watches. An email watch of an API key is pending until
+// the address confirms the watch; pending watches are not notified.
+
+// maxWatchEvents is the number of events kept in the queue. The oldest
+// events are dropped when the notifications are not sent.
+const maxWatchEvents = 10000
+
+// Kinds of watch events.
+const (
+	WatchAPIChanged = "api"     // The exported API changed in a crawl.
+	WatchHidden     = "hidden"  // The packages were hidden from search.
+	WatchBlocked    = "blocked" // The packages were added to the blocklist.
+)
+
+// Watch is a subscription to the events of the packages below Prefix. One
+// of Email and Webhook is set.
+type Watch struct {
+	ID      string    `json:"id"`
+	Owner   string    `json:"owner"`
+	Prefix  string    `json:"prefix"`
+	Email   string    `json:"email,omitempty"`
+	Webhook string    `json:"webhook,omitempty"`
+	Pending bool      `json:"pending,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// WatchEvent is an event of a package or, for the hidden and blocked
+// events, of a project root or a blocklist pattern.
+type WatchEvent struct {
+	Path string    `json:"path"`
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+}
+
+var errBadWatch = errors.New("database: bad watch prefix or target")
+
+// APIKeyID returns the identifier of the API key stored in the database.
+func APIKeyID(key string) string {
+	return apiKeyHash(key)
+}
+
+// AddWatch adds the watch with a new ID and returns the ID.
+func (db *Database) AddWatch(w Watch) (string, error) {
+	w.Prefix = strings.Trim(w.Prefix, "/")
+	if w.Prefix == "" || (w.Email == "") == (w.Webhook == "") {
+		return "", errBadWatch
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	w.ID = hex.EncodeToString(b[:])
+	if w.Created.IsZero() {
+		w.Created = time.Now().UTC()
+	}
+	p, err := json.Marshal(&w)
+	if err != nil {
+		return "", err
+	}
+	c := db.Pool.Get()
+	defer c.Close()
+	_, err = c.Do("HSET", Key("watches"), w.ID, p)
+	return w.ID, err
+}
+
+// RemoveWatch removes the watch and returns false if there is no watch with
+// the ID.
+func (db *Database) RemoveWatch(id string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	return redis.Bool(c.Do("HDEL", Key("watches"), id))
+}
+
+var confirmWatchScript = newScript(`
+    if redis.call('HEXISTS', 'watches', ARGV[1]) == 0 then
+        return 0
+    end
+    redis.call('HSET', 'watches', ARGV[1], ARGV[2])
+    return 1
+`)
+
+// ConfirmWatch activates the pending watch and returns false if there is no
+// watch with the ID.
+func (db *Database) ConfirmWatch(id string) (bool, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	p, err := redis.Bytes(c.Do("HGET", Key("watches"), id))
+	if err == redis.ErrNil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var w Watch
+	if err := json.Unmarshal(p, &w); err != nil {
+		return false, err
+	}
+	if !w.Pending {
+		return true, nil
+	}
+	w.Pending = false
+	if p, err = json.Marshal(&w); err != nil {
+		return false, err
+	}
+	return redis.Bool(confirmWatchScript.Do(c, id, p))
+}
+
+// Watches returns the watches, oldest first.
+func (db *Database) Watches() ([]Watch, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	values, err := redis.ByteSlices(c.Do("HVALS", Key("watches")))
+	if err != nil {
+		return nil, err
+	}
+	var watches []Watch
+	for _, p := range values {
+		var w Watch
+		if err := json.Unmarshal(p, &w); err != nil {
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	sort.Slice(watches, func(i, j int) bool {
+		if !watches[i].Created.Equal(watches[j].Created) {
+			return watches[i].Created.Before(watches[j].Created)
+		}
+		return watches[i].ID < watches[j].ID
+	})
+	return watches, nil
+}
+
+// Matches returns true if the event is of a package below the prefix of the
+// watch. A hidden or blocked event of a path above the prefix also matches.
+func (w *Watch) Matches(e WatchEvent) bool {
+	below := func(p, root string) bool {
+		return p == root || strings.HasPrefix(p, root+"/")
+	}
+	switch e.Kind {
+	case WatchBlocked:
+		return below(e.Path, w.Prefix) || matchBlock(e.Path, w.Prefix)
+	case WatchHidden:
+		return below(e.Path, w.Prefix) || below(w.Prefix, e.Path)
+	}
+	return below(e.Path, w.Prefix)
+}
+
+var putWatchEventScript = newScript(`
+    if redis.call('HLEN', 'watches') == 0 then
+        return 0
+    end
+    redis.call('RPUSH', 'watchevents', ARGV[1])
+    redis.call('LTRIM', 'watchevents', -tonumber(ARGV[2]), -1)
+    return 1
+`)
+
+func putWatchEvent(c redis.Conn, e WatchEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	p, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	_, err = putWatchEventScript.Do(c, p, maxWatchEvents)
+	return err
+}
+
+// AddWatchEvent queues the event if there are watches.
+func (db *Database) AddWatchEvent(e WatchEvent) error {
+	c := db.Pool.Get()
+	defer c.Close()
+	return putWatchEvent(c, e)
+}
+
+// PopWatchEvents removes the queued events from the queue and returns the
+// events, oldest first.
+func (db *Database) PopWatchEvents() ([]WatchEvent, error) {
+	c := db.Pool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("LRANGE", Key("watchevents"), 0, -1)
+	c.Send("DEL", Key("watchevents"))
+	values, err := redis.Values(c.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+	items, err := redis.ByteSlices(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	var events []WatchEvent
+	for _, p := range items {
+		var e WatchEvent
+		if err := json.Unmarshal(p, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package database
+
+import "testing"
+
+func TestWatches(t *testing.T) {
+	db := newDB(t)
+	defer closeDB(db)
+
+	if err := db.AddWatchEvent(WatchEvent{Path: "github.com/user/repo", Kind: WatchHidden}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddWatch(Watch{Prefix: "github.com/user"}); err != errBadWatch {
+		t.Errorf("AddWatch without target returned %v, want %v", err, errBadWatch)
+	}
+	id, err := db.AddWatch(Watch{Owner: "o", Prefix: "/github.com/user/", Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watches, err := db.Watches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watches) != 1 || watches[0].ID != id || watches[0].Prefix != "github.com/user" {
+		t.Errorf("Watches() = %+v", watches)
+	}
+
+	if err := db.Block("github.com/user/bad"); err != nil {
+		t.Fatal(err)
+	}
+	events, err := db.PopWatchEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Path != "github.com/user/bad" || events[0].Kind != WatchBlocked {
+		t.Errorf("PopWatchEvents() = %+v, want the blocked event only", events)
+	}
+	if events, err := db.PopWatchEvents(); err != nil || len(events) != 0 {
+		t.Errorf("PopWatchEvents() after pop = %+v, %v", events, err)
+	}
+
+	pending, err := db.AddWatch(Watch{Owner: "o", Prefix: "github.com/other", Email: "b@example.com", Pending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := db.ConfirmWatch(pending); err != nil || !ok {
+		t.Errorf("ConfirmWatch() = %v, %v, want true", ok, err)
+	}
+	if watches, err := db.Watches(); err != nil || len(watches) != 2 || watches[1].ID != pending || watches[1].Pending {
+		t.Errorf("Watches() after ConfirmWatch = %+v, %v", watches, err)
+	}
+	if ok, err := db.RemoveWatch(pending); err != nil || !ok {
+		t.Errorf("RemoveWatch(pending) = %v, %v, want true", ok, err)
+	}
+	if ok, err := db.ConfirmWatch(pending); err != nil || ok {
+		t.Errorf("ConfirmWatch() after RemoveWatch = %v, %v, want false", ok, err)
+	}
+
+	if ok, err := db.RemoveWatch(id); err != nil || !ok {
+		t.Errorf("RemoveWatch() = %v, %v, want true", ok, err)
+	}
+	if ok, err := db.RemoveWatch(id); err != nil || ok {
+		t.Errorf("RemoveWatch() again = %v, %v, want false", ok, err)
+	}
+}
+
+func TestWatchMatches(t *testing.T) {
+	w := Watch{Prefix: "github.com/user/repo"}
+	for _, tt := range []struct {
+		e    WatchEvent
+		want bool
+	}{
+		{WatchEvent{Path: "github.com/user/repo", Kind: WatchAPIChanged}, true},
+		{WatchEvent{Path: "github.com/user/repo/sub", Kind: WatchAPIChanged}, true},
+		{WatchEvent{Path: "github.com/user/repository", Kind: WatchAPIChanged}, false},
+		{WatchEvent{Path: "github.com/user", Kind: WatchAPIChanged}, false},
+		{WatchEvent{Path: "github.com/user", Kind: WatchHidden}, true},
+		{WatchEvent{Path: "github.com/other", Kind: WatchHidden}, false},
+		{WatchEvent{Path: "github.com/user", Kind: WatchBlocked}, true},
+		{WatchEvent{Path: "github.com/*/repo", Kind: WatchBlocked}, true},
+		{WatchEvent{Path: "github.com/user/repo/sub", Kind: WatchBlocked}, true},
+		{WatchEvent{Path: "github.com/other/repo", Kind: WatchBlocked}, false},
+	} {
+		if got := w.Matches(tt.e); got != tt.want {
+			t.Errorf("Matches(%+v) = %v, want %v", tt.e, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/types"
+	"sort"
+)
+
+// APISymbol is an exported symbol in the summary of a package API.
+type APISymbol struct {
+	// Name of the symbol. Methods and fields are named Type.Name.
+	Name string
+
+	// One of const, var, func, type, method or field.
+	Kind string
+
+	// Declaration of the symbol without names of parameters, comments and
+	// bodies. Two symbols with the same declaration are considered equal.
+	Decl string
+}
+
+type byAPIName []APISymbol
+
+func (s byAPIName) Len() int           { return len(s) }
+func (s byAPIName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s byAPIName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func exprString(x ast.Expr) string {
+	if x == nil {
+		return ""
+	}
+	return types.ExprString(x)
+}
+
+func apiValues(symbols []APISymbol, values []*doc.Value) []APISymbol {
+	for _, v := range values {
+		kind := "var"
+		if v.Decl.Tok.String() == "const" {
+			kind = "const"
+		}
+		for _, spec := range v.Decl.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range vs.Names {
+				if !name.IsExported() {
+					continue
+				}
+				decl := kind + " " + name.Name
+				if vs.Type != nil {
+					decl += " " + exprString(vs.Type)
+				}
+				if kind == "const" && i < len(vs.Values) {
+					decl += " = " + exprString(vs.Values[i])
+				}
+				symbols = append(symbols, APISymbol{Name: name.Name, Kind: kind, Decl: decl})
+			}
+		}
+	}
+	return symbols
+}
+
+// apiSignature formats the parameter and result types of a function type in
+// the style of a declaration.
+func apiSignature(t *ast.FuncType) string {
+	s := "(" + fieldTypes(t.Params) + ")"
+	if t.Results == nil || len(t.Results.List) == 0 {
+		return s
+	}
+	results := fieldTypes(t.Results)
+	if len(t.Results.List) == 1 && len(t.Results.List[0].Names) <= 1 {
+		return s + " " + results
+	}
+	return s + " (" + results + ")"
+}
+
+func apiFunc(name, kind string, f *doc.Func) APISymbol {
+	decl := "func "
+	if f.Recv != "" {
+		decl += "(" + f.Recv + ") "
+	}
+	decl += f.Name + apiSignature(f.Decl.Type)
+	return APISymbol{Name: name, Kind: kind, Decl: decl}
+}
+
+func apiType(symbols []APISymbol, d *doc.Type) []APISymbol {
+	for _, spec := range d.Decl.Specs {
+		ts, ok := spec.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != d.Name {
+			continue
+		}
+		decl := "type " + d.Name
+		if ts.Assign.IsValid() {
+			decl += " ="
+		}
+		switch t := ts.Type.(type) {
+		case *ast.StructType:
+			decl += " struct"
+			for _, f := range t.Fields.List {
+				typ := exprString(f.Type)
+				if f.Tag != nil {
+					typ += " " + f.Tag.Value
+				}
+				if len(f.Names) == 0 {
+					name := embeddedName(f.Type)
+					if ast.IsExported(name) {
+						symbols = append(symbols, APISymbol{Name: d.Name + "." + name, Kind: "field", Decl: "field " + typ})
+					}
+				}
+				for _, name := range f.Names {
+					if name.IsExported() {
+						symbols = append(symbols, APISymbol{Name: d.Name + "." + name.Name, Kind: "field", Decl: "field " + name.Name + " " + typ})
+					}
+				}
+			}
+		case *ast.InterfaceType:
+			decl += " interface"
+			for _, f := range t.Methods.List {
+				switch x := f.Type.(type) {
+				case *ast.FuncType:
+					for _, name := range f.Names {
+						if name.IsExported() {
+							symbols = append(symbols, APISymbol{Name: d.Name + "." + name.Name, Kind: "method", Decl: "method " + name.Name + apiSignature(x)})
+						}
+					}
+				default:
+					name := embeddedName(x)
+					symbols = append(symbols, APISymbol{Name: d.Name + "." + name, Kind: "method", Decl: "embedded " + exprString(x)})
+				}
+			}
+		default:
+			decl += " " + exprString(ts.Type)
+		}
+		symbols = append(symbols, APISymbol{Name: d.Name, Kind: "type", Decl: decl})
+	}
+	symbols = apiValues(symbols, d.Consts)
+	symbols = apiValues(symbols, d.Vars)
+	for _, f := range d.Funcs {
+		symbols = append(symbols, apiFunc(f.Name, "func", f))
+	}
+	for _, m := range d.Methods {
+		symbols = append(symbols, apiFunc(d.Name+"."+m.Name, "method", m))
+	}
+	return symbols
+}
+
+// embeddedName returns the name of the type in an embedded field or
+// interface.
+func embeddedName(x ast.Expr) string {
+	switch t := x.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return exprString(x)
+}
+
+// apiSummary returns the exported API of the package sorted by name.
+func apiSummary(dpkg *doc.Package) []APISymbol {
+	var symbols []APISymbol
+	symbols = apiValues(symbols, dpkg.Consts)
+	symbols = apiValues(symbols, dpkg.Vars)
+	for _, f := range dpkg.Funcs {
+		symbols = append(symbols, apiFunc(f.Name, "func", f))
+	}
+	for _, d := range dpkg.Types {
+		symbols = apiType(symbols, d)
+	}
+	sort.Stable(byAPIName(symbols))
+	return symbols
+}
+
+// APIChange is a difference between two summaries of a package API. Old is
+// nil for an added symbol and New is nil for a removed symbol.
+type APIChange struct {
+	Name string
+	Old  *APISymbol
+	New  *APISymbol
+}
+
+// DiffAPI returns the changes from the API summary old to the API summary
+// new sorted by name. Both summaries must be sorted by name.
+func DiffAPI(old, new []APISymbol) []APIChange {
+	var changes []APIChange
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case j == len(new) || (i < len(old) && old[i].Name < new[j].Name):
+			changes = append(changes, APIChange{Name: old[i].Name, Old: &old[i]})
+			i++
+		case i == len(old) || new[j].Name < old[i].Name:
+			changes = append(changes, APIChange{Name: new[j].Name, New: &new[j]})
+			j++
+		default:
+			if old[i] != new[j] {
+				changes = append(changes, APIChange{Name: new[j].Name, Old: &old[i], New: &new[j]})
+			}
+			i++
+			j++
+		}
+	}
+	return changes
+}
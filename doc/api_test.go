@@ -0,0 +1,103 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const apiSrc = `package p
+
+import "io"
+
+const (
+	A = 1
+	b = 2
+)
+
+var V, w io.Reader
+
+// F does something.
+func F(name string, n int) (x int, err error) { return 0, nil }
+
+type T struct {
+	io.Reader
+	Name string ` + "`json:\"name\"`" + `
+	hidden int
+}
+
+func NewT() *T { return nil }
+
+func (t *T) M(p []byte) error { return nil }
+
+func (t *T) m() {}
+
+type I interface {
+	io.Closer
+	Get(key string) string
+}
+
+type S = string
+`
+
+func TestAPISummary(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", apiSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apkg, _ := ast.NewPackage(fset, map[string]*ast.File{"p.go": file}, simpleImporter, nil)
+	api := apiSummary(doc.New(apkg, "example.com/p", 0))
+	want := []APISymbol{
+		{"A", "const", "const A = 1"},
+		{"F", "func", "func F(string, int) (int, error)"},
+		{"I", "type", "type I interface"},
+		{"I.Closer", "method", "embedded io.Closer"},
+		{"I.Get", "method", "method Get(string) string"},
+		{"NewT", "func", "func NewT() *T"},
+		{"S", "type", "type S = string"},
+		{"T", "type", "type T struct"},
+		{"T.M", "method", "func (*T) M([]byte) error"},
+		{"T.Name", "field", "field Name string `json:\"name\"`"},
+		{"T.Reader", "field", "field io.Reader"},
+		{"V", "var", "var V io.Reader"},
+	}
+	if !reflect.DeepEqual(api, want) {
+		t.Errorf("apiSummary() =\n%v\nwant\n%v", api, want)
+	}
+}
+
+func TestDiffAPI(t *testing.T) {
+	old := []APISymbol{
+		{"A", "const", "const A = 1"},
+		{"F", "func", "func F()"},
+		{"G", "func", "func G()"},
+	}
+	new := []APISymbol{
+		{"B", "const", "const B = 2"},
+		{"F", "func", "func F(int)"},
+		{"G", "func", "func G()"},
+		{"H", "func", "func H()"},
+	}
+	want := []APIChange{
+		{Name: "A", Old: &old[0]},
+		{Name: "B", New: &new[0]},
+		{Name: "F", Old: &old[1], New: &new[1]},
+		{Name: "H", New: &new[3]},
+	}
+	if changes := DiffAPI(old, new); !reflect.DeepEqual(changes, want) {
+		t.Errorf("DiffAPI() = %v, want %v", changes, want)
+	}
+	if changes := DiffAPI(old, old); changes != nil {
+		t.Errorf("DiffAPI(old, old) = %v, want nil", changes)
+	}
+}
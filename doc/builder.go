@@ -129,6 +129,18 @@ func addReferences(references map[string]bool, s []byte) {
 	}
 }
 
+var ownerKeyHashPat = regexp.MustCompile(`(?m)^sha256:([0-9a-f]{64})\s*$`)
+
+// ownerKeyHash returns the key hash in the contents of a gosrc.OwnerFile
+// file.
+func ownerKeyHash(p []byte) string {
+	m := ownerKeyHashPat.FindSubmatch(p)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
 type byFuncName []*doc.Func
 
 func (s byFuncName) Len() int           { return len(s) }
@@ -148,6 +160,7 @@ type builder struct {
 	srcs     map[string]*source
 	fset     *token.FileSet
 	examples []*doc.Example
+	usedBy   map[string][]string
 	buf      []byte // scratch space for printNode method.
 }
 
@@ -155,15 +168,24 @@ type Value struct {
 	Decl Code
 	Pos  Pos
 	Doc  string
+
+	// Names of the constants or variables in the declaration.
+	Names []string
+
+	// Message of the "Deprecated: " paragraph in Doc or "" if the
+	// declaration is not deprecated.
+	Deprecated string
 }
 
 func (b *builder) values(vdocs []*doc.Value) []*Value {
 	var result []*Value
 	for _, d := range vdocs {
 		result = append(result, &Value{
-			Decl: b.printDecl(d.Decl),
-			Pos:  b.position(d.Decl),
-			Doc:  d.Doc,
+			Decl:       b.printDecl(d.Decl),
+			Pos:        b.position(d.Decl),
+			Doc:        d.Doc,
+			Names:      d.Names,
+			Deprecated: deprecation(d.Doc),
 		})
 	}
 	return result
@@ -205,6 +227,16 @@ type Example struct {
 	Code   Code
 	Play   string
 	Output string
+
+	// True if the example has an output comment, possibly with no output.
+	// go test verifies the output of the example.
+	HasOutput bool
+
+	// True if the example program was compiled by the checker set with
+	// SetExampleChecker. CheckErrors are the compiler errors or "" if the
+	// program compiles.
+	Checked     bool
+	CheckErrors string
 }
 
 var exampleOutputRx = regexp.MustCompile(`(?i)//[[:space:]]*output:`)
@@ -240,11 +272,12 @@ func (b *builder) getExamples(name string) []*Example {
 		}
 
 		docs = append(docs, &Example{
-			Name:   n,
-			Doc:    e.Doc,
-			Code:   code,
-			Output: output,
-			Play:   play})
+			Name:      n,
+			Doc:       e.Doc,
+			Code:      code,
+			Output:    output,
+			Play:      play,
+			HasOutput: output != "" || e.EmptyOutput})
 	}
 	return docs
 }
@@ -256,28 +289,53 @@ type Func struct {
 	Name     string
 	Recv     string
 	Examples []*Example
+
+	// Anchors of the exported functions and methods in the package that
+	// reference this function.
+	UsedBy []string
+
+	// Name of the function in C if the function is exported with a cgo
+	// //export comment.
+	CgoExport string
+
+	// Type declaring the method if the method is promoted from an embedded
+	// type.
+	PromotedFrom string
+
+	// Message of the "Deprecated: " paragraph in Doc or "" if the function
+	// is not deprecated.
+	Deprecated string
 }
 
 func (b *builder) funcs(fdocs []*doc.Func) []*Func {
 	var result []*Func
 	for _, d := range fdocs {
-		var exampleName string
+		var exampleName, anchor string
 		switch {
 		case d.Recv == "":
 			exampleName = d.Name
+			anchor = d.Name
 		case d.Recv[0] == '*':
 			exampleName = d.Recv[1:] + "_" + d.Name
+			anchor = d.Recv[1:] + "." + d.Name
 		default:
 			exampleName = d.Recv + "_" + d.Name
+			anchor = d.Recv + "." + d.Name
 		}
-		result = append(result, &Func{
-			Decl:     b.printDecl(d.Decl),
-			Pos:      b.position(d.Decl),
-			Doc:      d.Doc,
-			Name:     d.Name,
-			Recv:     d.Recv,
-			Examples: b.getExamples(exampleName),
-		})
+		f := &Func{
+			Decl:       b.printDecl(d.Decl),
+			Pos:        b.position(d.Decl),
+			Doc:        d.Doc,
+			Name:       d.Name,
+			Recv:       d.Recv,
+			Examples:   b.getExamples(exampleName),
+			UsedBy:     b.usedBy[anchor],
+			Deprecated: deprecation(d.Doc),
+		}
+		if d.Level > 0 {
+			f.PromotedFrom = strings.TrimPrefix(d.Orig, "*")
+		}
+		result = append(result, f)
 	}
 	return result
 }
@@ -292,21 +350,38 @@ type Type struct {
 	Funcs    []*Func
 	Methods  []*Func
 	Examples []*Example
+
+	// Interfaces implemented by the type and, for an interface, the types in
+	// the package implementing the interface.
+	Implements    []*TypeRef
+	ImplementedBy []*TypeRef
+
+	// Exported fields and embedded types of a struct type.
+	Fields   []string
+	Embedded []*Embedded
+
+	// Message of the "Deprecated: " paragraph in Doc or "" if the type is
+	// not deprecated.
+	Deprecated string
 }
 
 func (b *builder) types(tdocs []*doc.Type) []*Type {
 	var result []*Type
 	for _, d := range tdocs {
+		fields, embedded := structFields(d)
 		result = append(result, &Type{
-			Doc:      d.Doc,
-			Name:     d.Name,
-			Decl:     b.printDecl(d.Decl),
-			Pos:      b.position(d.Decl),
-			Consts:   b.values(d.Consts),
-			Vars:     b.values(d.Vars),
-			Funcs:    b.funcs(d.Funcs),
-			Methods:  b.funcs(d.Methods),
-			Examples: b.getExamples(d.Name),
+			Doc:        d.Doc,
+			Name:       d.Name,
+			Decl:       b.printDecl(d.Decl),
+			Pos:        b.position(d.Decl),
+			Consts:     b.values(d.Consts),
+			Vars:       b.values(d.Vars),
+			Funcs:      b.funcs(d.Funcs),
+			Methods:    b.funcs(d.Methods),
+			Examples:   b.getExamples(d.Name),
+			Fields:     fields,
+			Embedded:   embedded,
+			Deprecated: deprecation(d.Doc),
 		})
 	}
 	return result
@@ -357,6 +432,48 @@ type File struct {
 	URL  string
 }
 
+// Readme is a README file.
+type Readme struct {
+	Name      string
+	BrowseURL string
+	Data      []byte
+}
+
+// Talk is a talk in the present format, a .slide or .article file.
+type Talk struct {
+	Name      string
+	Title     string
+	BrowseURL string
+}
+
+// talkTitle returns the title of a talk, the first line of the file that is
+// not blank or a comment.
+func talkTitle(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// maxReadmeSize is the size of the largest README file stored in a package.
+const maxReadmeSize = 1 << 16
+
+var markdownPat = regexp.MustCompile(`(?i)\.(?:md|markdown)$`)
+
+// IsMarkdown returns true if the file name has a Markdown extension.
+func IsMarkdown(name string) bool {
+	return markdownPat.MatchString(name)
+}
+
+// Source is the contents of a file in Files or TestFiles.
+type Source struct {
+	Name string
+	Data []byte
+}
+
 type Pos struct {
 	Line int32  // 0 if not valid.
 	N    uint16 // number of lines - 1
@@ -367,6 +484,7 @@ type source struct {
 	name      string
 	browseURL string
 	data      []byte
+	raw       []byte
 	index     int
 }
 
@@ -392,12 +510,63 @@ type Package struct {
 	// Packages referenced in README files.
 	References []string
 
+	// README file in the directory. A Markdown README is preferred over
+	// other README files.
+	Readme *Readme
+
+	// Talks in the directory.
+	Talks []*Talk
+
 	// Version control system: git, hg, bzr, ...
 	VCS string
 
 	// Version control: belongs to a dead end fork
 	DeadEndFork bool
 
+	// Repository signals copied from gosrc.Directory.
+	Fork     bool
+	Archived bool
+	Stars    int
+	Pushed   time.Time
+
+	// True if the package is in a private repository. Private packages are
+	// only served to authenticated users.
+	Private bool
+
+	// True if the source is from a module zip matching the Go checksum
+	// database.
+	Verified bool
+
+	// True if the package is the root of a module nested in the repository.
+	NestedModule bool
+
+	// True if the owner opted out of indexing with a gosrc.IgnoreFile or by
+	// retracting the version in the go.mod file.
+	OptOut bool
+
+	// Canonical import path of the package in the import comment or the
+	// go.mod file if the package is fetched from another path, such as a
+	// mirror, or "".
+	Canonical string
+
+	// Licenses detected in the license files of the directory or, for a
+	// package below the project root without license files, of the project
+	// root.
+	Licenses []License
+
+	// Module metadata in the go.mod file of the directory or, for a package
+	// below the module root, of the module root, or nil if there is no go.mod
+	// file.
+	Module *Module
+
+	// Report card of the package or nil if the directory does not have a
+	// package.
+	Quality *Quality
+
+	// Hash of the project owner's key found in the gosrc.OwnerFile file or
+	// the gosrc.OwnerMeta meta tag, or "" if neither is present.
+	OwnerKeyHash string
+
 	// The time this object was created.
 	Updated time.Time
 
@@ -405,9 +574,17 @@ type Package struct {
 	// The tag is "" if there is no meaningful cache validation for the VCS.
 	Etag string
 
+	// Release tag or revision of the source used to build this object. The
+	// version is "" if the VCS does not identify revisions.
+	Version string
+
 	// Subdirectories, possibly containing Go code.
 	Subdirectories []string
 
+	// Versions of the module in the module proxy, newest first, copied from
+	// gosrc.Directory.
+	ModuleVersions []string
+
 	// Package name or "" if no package for this import path. The proceeding
 	// fields are set even if a package is not found for the import path.
 	Name string
@@ -416,26 +593,51 @@ type Package struct {
 	Synopsis string
 	Doc      string
 
+	// Message of the "Deprecated: " paragraph in Doc or "" if the package is
+	// not deprecated.
+	Deprecated string
+
 	// Format this package as a command.
 	IsCmd bool
 
+	// Usage text in the doc comment and flags of a command.
+	Usage string
+	Flags []*Flag
+
 	// True if package documentation is incomplete.
 	Truncated bool
 
+	// Number of declarations omitted by the size limits set with
+	// SetSizeLimits.
+	OmittedDecls int
+
 	// Environment
 	GOOS, GOARCH string
 
+	// Build tags satisfied in addition to the default build tags.
+	BuildTags []string
+
 	// Top-level declarations.
 	Consts []*Value
 	Funcs  []*Func
 	Types  []*Type
 	Vars   []*Value
 
+	// Functions exported to C with cgo //export comments.
+	CgoExports []*Func
+
+	// Summary of the exported API sorted by name.
+	API []APISymbol
+
 	// Package examples
 	Examples []*Example
 
 	Notes map[string][]*Note
 
+	// Targets of the doc links in the doc comments by the text between the
+	// brackets of the link.
+	DocLinks map[string]DocLink
+
 	// Source.
 	LineFmt   string
 	BrowseURL string
@@ -446,13 +648,28 @@ type Package struct {
 	SourceSize     int
 	TestSourceSize int
 
+	// Contents of the files in Files and TestFiles. The database stores the
+	// sources separately from the package document and sets SourcesStored
+	// in the stored document.
+	Sources       []*Source
+	SourcesStored bool
+
+	// Documentation for other build contexts with different files. The
+	// database stores the variants separately from the package document and
+	// sets Platforms in the stored document to GOOS/GOARCH of the document
+	// followed by GOOS/GOARCH of each variant.
+	Variants  []*Package
+	Platforms []string
+
 	// Imports
 	Imports      []string
 	TestImports  []string
 	XTestImports []string
 }
 
-var goEnvs = []struct{ GOOS, GOARCH string }{
+type goEnv struct{ GOOS, GOARCH string }
+
+var goEnvs = []goEnv{
 	{"linux", "amd64"},
 	{"darwin", "amd64"},
 	{"windows", "amd64"},
@@ -482,7 +699,22 @@ func SetDefaultGOOS(goos string) {
 	goEnvs[0], goEnvs[i] = goEnvs[i], goEnvs[0]
 }
 
-func newPackage(dir *gosrc.Directory) (*Package, error) {
+// buildContext returns the build context used to find the files of a
+// package for env.
+func buildContext(env goEnv) build.Context {
+	return build.Context{
+		GOOS:        env.GOOS,
+		GOARCH:      env.GOARCH,
+		CgoEnabled:  true,
+		ReleaseTags: build.Default.ReleaseTags,
+		BuildTags:   append(append([]string(nil), build.Default.BuildTags...), buildTags...),
+		Compiler:    "gc",
+	}
+}
+
+// newPackageForEnvs builds the package documentation for the first build
+// context in envs with Go files.
+func newPackageForEnvs(dir *gosrc.Directory, envs []goEnv) (*Package, error) {
 
 	pkg := &Package{
 		Updated:        time.Now().UTC(),
@@ -493,9 +725,24 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 		ProjectURL:     dir.ProjectURL,
 		BrowseURL:      dir.BrowseURL,
 		Etag:           PackageVersion + "-" + dir.Etag,
+		Version:        dir.Version,
 		VCS:            dir.VCS,
 		DeadEndFork:    dir.DeadEndFork,
+		Fork:           dir.Fork,
+		Archived:       dir.Archived,
+		Stars:          dir.Stars,
+		Verified:       dir.Verified,
+		Pushed:         dir.Pushed,
+		Private:        dir.Private,
+		NestedModule:   dir.NestedModule,
+		OptOut:         dir.OptOut,
+		Canonical:      dir.Canonical,
+		OwnerKeyHash:   dir.OwnerKeyHash,
 		Subdirectories: dir.Subdirectories,
+		ModuleVersions: dir.ModuleVersions,
+	}
+	if pkg.Version == "" {
+		pkg.Version = dir.Etag
 	}
 
 	var b builder
@@ -503,10 +750,24 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 	references := make(map[string]bool)
 	for _, file := range dir.Files {
 		if strings.HasSuffix(file.Name, ".go") {
+			raw := append([]byte(nil), file.Data...)
 			gosrc.OverwriteLineComments(file.Data)
-			b.srcs[file.Name] = &source{name: file.Name, browseURL: file.BrowseURL, data: file.Data}
-		} else {
+			b.srcs[file.Name] = &source{name: file.Name, browseURL: file.BrowseURL, data: file.Data, raw: raw}
+		} else if file.Name == gosrc.OwnerFile {
+			pkg.OwnerKeyHash = ownerKeyHash(file.Data)
+		} else if gosrc.IsLicenseFile(file.Name) {
+			if l, ok := detectLicense(file.Name, file.Data); ok {
+				pkg.Licenses = append(pkg.Licenses, l)
+			}
+		} else if file.Name == "go.mod" {
+			pkg.Module = parseGoMod(dir.ImportPath, file.Data)
+		} else if gosrc.IsTalkFile(file.Name) {
+			pkg.Talks = append(pkg.Talks, &Talk{Name: file.Name, Title: talkTitle(file.Data), BrowseURL: file.BrowseURL})
+		} else if file.Name != gosrc.IgnoreFile {
 			addReferences(references, file.Data)
+			if len(file.Data) <= maxReadmeSize && (pkg.Readme == nil || IsMarkdown(file.Name)) {
+				pkg.Readme = &Readme{Name: file.Name, BrowseURL: file.BrowseURL, Data: file.Data}
+			}
 		}
 	}
 
@@ -522,21 +783,12 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 
 	// Find the package and associated files.
 
-	ctxt := build.Context{
-		GOOS:        "linux",
-		GOARCH:      "amd64",
-		CgoEnabled:  true,
-		ReleaseTags: build.Default.ReleaseTags,
-		BuildTags:   build.Default.BuildTags,
-		Compiler:    "gc",
-	}
-
+	var ctxt build.Context
 	var err error
 	var bpkg *build.Package
 
-	for _, env := range goEnvs {
-		ctxt.GOOS = env.GOOS
-		ctxt.GOARCH = env.GOARCH
+	for _, env := range envs {
+		ctxt = buildContext(env)
 		bpkg, err = dir.Import(&ctxt, build.ImportComment)
 		if _, ok := err.(*build.NoGoError); !ok {
 			break
@@ -550,10 +802,7 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 	}
 
 	if bpkg.ImportComment != "" && bpkg.ImportComment != dir.ImportPath {
-		return nil, gosrc.NotFoundError{
-			Message:  "not at canonical import path",
-			Redirect: bpkg.ImportComment,
-		}
+		pkg.Canonical = bpkg.ImportComment
 	}
 
 	// Parse the Go files
@@ -572,10 +821,12 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 		src := b.srcs[name]
 		src.index = i
 		pkg.Files[i] = &File{Name: name, URL: src.browseURL}
+		pkg.Sources = append(pkg.Sources, &Source{Name: name, Data: src.raw})
 		pkg.SourceSize += len(src.data)
 	}
 
 	apkg, _ := ast.NewPackage(b.fset, files, simpleImporter, nil)
+	imports := importNames(files, names)
 
 	// Find examples in the test files.
 
@@ -590,10 +841,17 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 			b.examples = append(b.examples, doc.Examples(file)...)
 		}
 		pkg.TestFiles[i] = &File{Name: name, URL: b.srcs[name].browseURL}
+		pkg.Sources = append(pkg.Sources, &Source{Name: name, Data: b.srcs[name].raw})
 		pkg.TestSourceSize += len(b.srcs[name].data)
 	}
 
 	b.vetPackage(pkg, apkg)
+	b.usedBy = usedBy(apkg)
+	pkg.CgoExports = b.cgoExports(apkg)
+	if bpkg.IsCommand() {
+		// Find the flags before doc.New removes the function bodies.
+		pkg.Flags = commandFlags(files)
+	}
 
 	mode := doc.Mode(0)
 	if pkg.ImportPath == "builtin" {
@@ -609,17 +867,28 @@ func newPackage(dir *gosrc.Directory) (*Package, error) {
 	pkg.Name = dpkg.Name
 	pkg.Doc = strings.TrimRight(dpkg.Doc, " \t\n\r")
 	pkg.Synopsis = synopsis(pkg.Doc)
+	pkg.Deprecated = deprecation(pkg.Doc)
 
 	pkg.Examples = b.getExamples("")
 	pkg.IsCmd = bpkg.IsCommand()
+	if pkg.IsCmd {
+		pkg.Usage = commandUsage(pkg.Doc)
+	}
 	pkg.GOOS = ctxt.GOOS
 	pkg.GOARCH = ctxt.GOARCH
+	pkg.BuildTags = buildTags
 
 	pkg.Consts = b.values(dpkg.Consts)
 	pkg.Funcs = b.funcs(dpkg.Funcs)
 	pkg.Types = b.types(dpkg.Types)
+	setImplementations(dpkg.Types, pkg.Types)
 	pkg.Vars = b.values(dpkg.Vars)
+	pkg.API = apiSummary(dpkg)
 	pkg.Notes = b.notes(dpkg.Notes)
+	pkg.Quality = b.quality(pkg)
+	pkg.DocLinks = docLinks(pkg, imports)
+	checkExamples(pkg)
+	applySizeLimits(pkg)
 
 	pkg.Imports = bpkg.Imports
 	pkg.TestImports = bpkg.TestImports
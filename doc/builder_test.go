@@ -69,6 +69,24 @@ func TestReferences(t *testing.T) {
 	}
 }
 
+var ownerKeyHashTests = []struct {
+	in, out string
+}{
+	{"", ""},
+	{"sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n", "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+	{"# comment\nsha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+	{"sha256:0123", ""},
+	{"md5:0123456789abcdef0123456789abcdef", ""},
+}
+
+func TestOwnerKeyHash(t *testing.T) {
+	for _, tt := range ownerKeyHashTests {
+		if s := ownerKeyHash([]byte(tt.in)); s != tt.out {
+			t.Errorf("ownerKeyHash(%q) = %q, want %q", tt.in, s, tt.out)
+		}
+	}
+}
+
 var simpleImporterTests = []struct {
 	path string
 	name string
@@ -112,3 +130,17 @@ func TestSimpleImporter(t *testing.T) {
 		}
 	}
 }
+
+func TestTalkTitle(t *testing.T) {
+	for _, tt := range []struct {
+		data, want string
+	}{
+		{"Go Concurrency Patterns\nGoogle I/O 2012\n\n* Intro\n", "Go Concurrency Patterns"},
+		{"# comment\n\n  Talk  \n", "Talk"},
+		{"", ""},
+	} {
+		if got := talkTitle([]byte(tt.data)); got != tt.want {
+			t.Errorf("talkTitle(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+// ExampleChecker compiles the programs of examples.
+type ExampleChecker interface {
+	// Check compiles the example program src of the package with the import
+	// path. Check returns the compiler errors or "" if the program compiles.
+	// An error is returned if the program cannot be checked.
+	Check(importPath string, src []byte) (errors string, err error)
+}
+
+var exampleChecker ExampleChecker
+
+// SetExampleChecker sets the checker applied by Build to the examples with a
+// program. A nil checker disables checking.
+func SetExampleChecker(c ExampleChecker) {
+	exampleChecker = c
+}
+
+// allExamples returns the examples of the package and its declarations.
+func allExamples(pkg *Package) []*Example {
+	examples := append([]*Example(nil), pkg.Examples...)
+	for _, f := range pkg.Funcs {
+		examples = append(examples, f.Examples...)
+	}
+	for _, t := range pkg.Types {
+		examples = append(examples, t.Examples...)
+		for _, f := range t.Funcs {
+			examples = append(examples, f.Examples...)
+		}
+		for _, f := range t.Methods {
+			examples = append(examples, f.Examples...)
+		}
+	}
+	return examples
+}
+
+// checkExamples compiles the programs of the examples of the package with the
+// checker set by SetExampleChecker. Examples without a program are not
+// checked.
+func checkExamples(pkg *Package) {
+	if exampleChecker == nil {
+		return
+	}
+	for _, e := range allExamples(pkg) {
+		if e.Play == "" {
+			continue
+		}
+		errs, err := exampleChecker.Check(pkg.ImportPath, []byte(e.Play))
+		if err != nil {
+			continue
+		}
+		e.Checked = true
+		e.CheckErrors = errs
+	}
+}
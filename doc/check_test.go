@@ -0,0 +1,58 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testChecker struct{}
+
+func (testChecker) Check(importPath string, src []byte) (string, error) {
+	switch {
+	case strings.Contains(string(src), "broken"):
+		return "undefined: broken", nil
+	case strings.Contains(string(src), "timeout"):
+		return "", errors.New("timeout")
+	}
+	return "", nil
+}
+
+func TestCheckExamples(t *testing.T) {
+	ok := &Example{Name: "ok", Play: "package main\nfunc main() {}"}
+	broken := &Example{Name: "broken", Play: "package main\nfunc main() { broken() }"}
+	timeout := &Example{Name: "timeout", Play: "package main\nfunc main() { timeout() }"}
+	noPlay := &Example{Name: "noplay"}
+	pkg := &Package{
+		ImportPath: "example.com/p",
+		Examples:   []*Example{ok, noPlay},
+		Types: []*Type{{
+			Name:    "T",
+			Methods: []*Func{{Name: "M", Examples: []*Example{broken}}},
+			Funcs:   []*Func{{Name: "NewT", Examples: []*Example{timeout}}},
+		}},
+	}
+	SetExampleChecker(testChecker{})
+	defer SetExampleChecker(nil)
+	checkExamples(pkg)
+	for _, tt := range []struct {
+		e           *Example
+		checked     bool
+		checkErrors string
+	}{
+		{ok, true, ""},
+		{broken, true, "undefined: broken"},
+		{timeout, false, ""},
+		{noPlay, false, ""},
+	} {
+		if tt.e.Checked != tt.checked || tt.e.CheckErrors != tt.checkErrors {
+			t.Errorf("example %s: Checked = %v, CheckErrors = %q, want %v, %q", tt.e.Name, tt.e.Checked, tt.e.CheckErrors, tt.checked, tt.checkErrors)
+		}
+	}
+}
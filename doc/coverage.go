@@ -0,0 +1,90 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"strings"
+)
+
+// Coverage is the documentation coverage of the exported declarations of a
+// package. The package clause counts as a declaration.
+type Coverage struct {
+	// Exported is the number of exported declarations and Documented is the
+	// number of these with doc comments.
+	Exported   int
+	Documented int
+
+	// Undocumented are the exported declarations without doc comments in
+	// the order of the documentation page.
+	Undocumented []UndocumentedDecl
+}
+
+// UndocumentedDecl is an exported declaration without a doc comment.
+type UndocumentedDecl struct {
+	// Kind is package, const, var, func, type or method.
+	Kind string
+
+	// Name is the name of the declaration. The name of a method is
+	// TYPE.METHOD. The name of a const or var declaration is the list of the
+	// declared names separated by commas.
+	Name string
+
+	Pos Pos
+}
+
+// Percent returns the percentage of the exported declarations with doc
+// comments.
+func (c *Coverage) Percent() int {
+	if c.Exported == 0 {
+		return 100
+	}
+	return c.Documented * 100 / c.Exported
+}
+
+func (c *Coverage) add(kind, name, comment string, pos Pos) {
+	c.Exported++
+	if comment != "" {
+		c.Documented++
+	} else {
+		c.Undocumented = append(c.Undocumented, UndocumentedDecl{Kind: kind, Name: name, Pos: pos})
+	}
+}
+
+func (c *Coverage) addValues(kind string, values []*Value) {
+	for _, v := range values {
+		c.add(kind, strings.Join(v.Names, ", "), v.Doc, v.Pos)
+	}
+}
+
+func (c *Coverage) addFuncs(kind, prefix string, funcs []*Func) {
+	for _, f := range funcs {
+		if f.PromotedFrom == "" {
+			c.add(kind, prefix+f.Name, f.Doc, f.Pos)
+		}
+	}
+}
+
+// Coverage returns the documentation coverage of the package. Commands have
+// only the package clause.
+func (pkg *Package) Coverage() *Coverage {
+	c := &Coverage{}
+	c.add("package", pkg.Name, pkg.Doc, Pos{})
+	if pkg.IsCmd {
+		return c
+	}
+	c.addValues("const", pkg.Consts)
+	c.addValues("var", pkg.Vars)
+	c.addFuncs("func", "", pkg.Funcs)
+	for _, t := range pkg.Types {
+		c.add("type", t.Name, t.Doc, t.Pos)
+		c.addValues("const", t.Consts)
+		c.addValues("var", t.Vars)
+		c.addFuncs("func", "", t.Funcs)
+		c.addFuncs("method", t.Name+".", t.Methods)
+	}
+	return c
+}
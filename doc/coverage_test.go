@@ -0,0 +1,50 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	pkg := &Package{
+		Name:   "a",
+		Consts: []*Value{{Names: []string{"A", "B"}, Pos: Pos{Line: 3}}},
+		Vars:   []*Value{{Names: []string{"V"}, Doc: "V is a variable."}},
+		Funcs:  []*Func{{Name: "F", Doc: "F does things."}},
+		Types: []*Type{{
+			Name:    "T",
+			Doc:     "T is a thing.",
+			Funcs:   []*Func{{Name: "NewT"}},
+			Methods: []*Func{{Name: "M", Pos: Pos{Line: 10}}, {Name: "P", PromotedFrom: "U"}},
+		}},
+	}
+	c := pkg.Coverage()
+	want := &Coverage{
+		Exported:   7,
+		Documented: 3,
+		Undocumented: []UndocumentedDecl{
+			{Kind: "package", Name: "a"},
+			{Kind: "const", Name: "A, B", Pos: Pos{Line: 3}},
+			{Kind: "func", Name: "NewT"},
+			{Kind: "method", Name: "T.M", Pos: Pos{Line: 10}},
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Coverage() = %+v, want %+v", c, want)
+	}
+	if p := c.Percent(); p != 42 {
+		t.Errorf("Percent() = %d, want 42", p)
+	}
+
+	pkg.IsCmd = true
+	pkg.Doc = "Command a does things."
+	if c := pkg.Coverage(); c.Exported != 1 || c.Percent() != 100 || len(c.Undocumented) != 0 {
+		t.Errorf("Coverage() of command = %+v", c)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"strings"
+)
+
+const deprecatedPrefix = "Deprecated: "
+
+// deprecation returns the message of the paragraph starting with
+// "Deprecated: " in the doc comment s or "" if the comment does not have a
+// deprecation paragraph. All runs of whitespace in the message are replaced
+// by a single space.
+func deprecation(s string) string {
+	for _, p := range strings.Split(s, "\n\n") {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, deprecatedPrefix) {
+			return strings.Join(strings.Fields(p[len(deprecatedPrefix):]), " ")
+		}
+	}
+	return ""
+}
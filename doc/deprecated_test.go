@@ -0,0 +1,29 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"testing"
+)
+
+var deprecationTests = []struct {
+	doc, message string
+}{
+	{"F does something.\n", ""},
+	{"F does something.\n\nDeprecated: Use G instead.\n", "Use G instead."},
+	{"F does something.\n\nDeprecated: Use G\ninstead.\n\nMore text.\n", "Use G instead."},
+	{"Deprecated: F is obsolete.\n", "F is obsolete."},
+	{"F is not Deprecated: really.\n", ""},
+}
+
+func TestDeprecation(t *testing.T) {
+	for _, tt := range deprecationTests {
+		if message := deprecation(tt.doc); message != tt.message {
+			t.Errorf("deprecation(%q) = %q, want %q", tt.doc, message, tt.message)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+// DocLink is the target of a doc link in a doc comment. A doc link is an
+// identifier in square brackets: [Name], [Type.Method], [pkg], [pkg.Name],
+// [pkg.Type.Method] or one of these with a full import path for pkg, such as
+// [net/http.Handler]. A leading * is allowed.
+type DocLink struct {
+	// ImportPath is the import path of the linked package or "" for the
+	// package with the comment.
+	ImportPath string
+
+	// Name is the linked declaration, NAME or TYPE.METHOD, or "" for a link
+	// to the package.
+	Name string
+}
+
+// docLinkPat matches the doc links in doc comments. The first submatch is the
+// text used as the key of Package.DocLinks.
+var docLinkPat = regexp.MustCompile(`\[\*?([A-Za-z_][-A-Za-z0-9_./~]*)\]`)
+
+// importNames returns the import paths of the packages imported by the files
+// by the names of the packages in the files. The name of a package without a
+// name in the import declaration is guessed from the import path.
+func importNames(files map[string]*ast.File, names []string) map[string]string {
+	m := make(map[string]string)
+	for _, name := range names {
+		file := files[name]
+		if file == nil {
+			continue
+		}
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || path == "C" {
+				continue
+			}
+			var n string
+			if spec.Name != nil {
+				n = spec.Name.Name
+			} else if obj, err := simpleImporter(map[string]*ast.Object{}, path); err == nil {
+				n = obj.Name
+			}
+			if n == "" || n == "_" || n == "." {
+				continue
+			}
+			if _, ok := m[n]; !ok {
+				m[n] = path
+			}
+		}
+	}
+	return m
+}
+
+// docLinks returns the targets of the doc links in the doc comments of pkg.
+// The imports map the package names used in the files to import paths.
+func docLinks(pkg *Package, imports map[string]string) map[string]DocLink {
+	decls := make(map[string]bool)
+	var docs []string
+	addValues := func(values []*Value) {
+		for _, v := range values {
+			for _, name := range v.Names {
+				decls[name] = true
+			}
+			docs = append(docs, v.Doc)
+		}
+	}
+	addFuncs := func(prefix string, funcs []*Func) {
+		for _, f := range funcs {
+			decls[prefix+f.Name] = true
+			docs = append(docs, f.Doc)
+		}
+	}
+	docs = append(docs, pkg.Doc)
+	addValues(pkg.Consts)
+	addValues(pkg.Vars)
+	addFuncs("", pkg.Funcs)
+	for _, t := range pkg.Types {
+		decls[t.Name] = true
+		docs = append(docs, t.Doc)
+		addValues(t.Consts)
+		addValues(t.Vars)
+		addFuncs("", t.Funcs)
+		addFuncs(t.Name+".", t.Methods)
+	}
+
+	var links map[string]DocLink
+	for _, d := range docs {
+		for _, m := range docLinkPat.FindAllStringSubmatch(d, -1) {
+			text := m[1]
+			if _, ok := links[text]; ok {
+				continue
+			}
+			link, ok := resolveDocLink(text, decls, imports)
+			if !ok {
+				continue
+			}
+			if links == nil {
+				links = make(map[string]DocLink)
+			}
+			links[text] = link
+		}
+	}
+	return links
+}
+
+// resolveDocLink returns the target of the doc link with the text. The decls
+// are the exported declarations of the package with the comment.
+func resolveDocLink(text string, decls map[string]bool, imports map[string]string) (DocLink, bool) {
+	if decls[text] {
+		return DocLink{Name: text}, true
+	}
+
+	// Split the text into the package and the declaration. The package is
+	// the import path up to the first dot after the last slash or the name
+	// of an imported package.
+	var pkg, name string
+	if i := strings.LastIndex(text, "/"); i >= 0 {
+		pkg = text
+		if j := strings.Index(text[i:], "."); j >= 0 {
+			pkg, name = text[:i+j], text[i+j+1:]
+		}
+		if !gosrc.IsValidPath(pkg) {
+			return DocLink{}, false
+		}
+	} else {
+		pkg = text
+		if i := strings.Index(text, "."); i >= 0 {
+			pkg, name = text[:i], text[i+1:]
+		}
+		var ok bool
+		if pkg, ok = imports[pkg]; !ok {
+			return DocLink{}, false
+		}
+	}
+
+	if name != "" {
+		parts := strings.Split(name, ".")
+		if len(parts) > 2 {
+			return DocLink{}, false
+		}
+		for _, p := range parts {
+			if !ast.IsExported(p) {
+				return DocLink{}, false
+			}
+		}
+	}
+	return DocLink{ImportPath: pkg, Name: name}, true
+}
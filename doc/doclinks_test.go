@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestDocLinks(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "a.go", `package a
+
+import (
+	"io"
+	"net/http"
+	yaml "gopkg.in/yaml.v2"
+	_ "embed"
+)
+`, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := importNames(map[string]*ast.File{"a.go": file}, []string{"a.go"})
+	if want := map[string]string{"io": "io", "http": "net/http", "yaml": "gopkg.in/yaml.v2"}; !reflect.DeepEqual(imports, want) {
+		t.Errorf("importNames() = %v, want %v", imports, want)
+	}
+
+	pkg := &Package{
+		Doc:   "Package a uses [io], [http.Handler], [*http.Request], [http.Request.URL] and [encoding/json.Marshal].",
+		Funcs: []*Func{{Name: "F", Doc: "F calls [yaml.Marshal] and [G]. See [RFC 1] and [unknown.X] and [http.handler]."}},
+		Types: []*Type{{
+			Name:    "T",
+			Doc:     "T is returned by [F] and [T.M].",
+			Methods: []*Func{{Name: "M"}},
+		}},
+	}
+	got := docLinks(pkg, imports)
+	want := map[string]DocLink{
+		"io":                    {ImportPath: "io"},
+		"http.Handler":          {ImportPath: "net/http", Name: "Handler"},
+		"http.Request":          {ImportPath: "net/http", Name: "Request"},
+		"http.Request.URL":      {ImportPath: "net/http", Name: "Request.URL"},
+		"encoding/json.Marshal": {ImportPath: "encoding/json", Name: "Marshal"},
+		"yaml.Marshal":          {ImportPath: "gopkg.in/yaml.v2", Name: "Marshal"},
+		"F":                     {Name: "F"},
+		"T.M":                   {Name: "T.M"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("docLinks() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/doc"
+	"strconv"
+)
+
+// Embedded is a type embedded in a struct type.
+type Embedded struct {
+	TypeRef
+	Pointer bool
+}
+
+// structFields returns the names of the exported fields and the embedded
+// types of the struct type declared by d. The embedded types are resolved to
+// import paths using the imports of the file declaring the struct.
+func structFields(d *doc.Type) ([]string, []*Embedded) {
+	var st *ast.StructType
+	for _, spec := range d.Decl.Specs {
+		if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == d.Name {
+			st, _ = ts.Type.(*ast.StructType)
+		}
+	}
+	if st == nil {
+		return nil, nil
+	}
+
+	var fields []string
+	var embedded []*Embedded
+	for _, f := range st.Fields.List {
+		for _, name := range f.Names {
+			if name.IsExported() {
+				fields = append(fields, name.Name)
+			}
+		}
+		if len(f.Names) != 0 {
+			continue
+		}
+		t := f.Type
+		e := &Embedded{}
+		if star, ok := t.(*ast.StarExpr); ok {
+			e.Pointer = true
+			t = star.X
+		}
+		switch t := t.(type) {
+		case *ast.Ident:
+			e.Name = t.Name
+		case *ast.SelectorExpr:
+			x, ok := t.X.(*ast.Ident)
+			if !ok || x.Obj == nil {
+				continue
+			}
+			spec, ok := x.Obj.Decl.(*ast.ImportSpec)
+			if !ok {
+				continue
+			}
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			e.ImportPath = importPath
+			e.Package = x.Name
+			e.Name = t.Sel.Name
+		default:
+			continue
+		}
+		if ast.IsExported(e.Name) {
+			embedded = append(embedded, e)
+		}
+	}
+	return fields, embedded
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const embeddedSrc = `package p
+
+import (
+	"bytes"
+	xio "io"
+)
+
+type Base struct {
+	ID int
+}
+
+type T struct {
+	Base
+	*bytes.Buffer
+	xio.Reader
+	Name, hidden string
+	unexported
+}
+
+type unexported struct{}
+`
+
+func TestStructFields(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", embeddedSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apkg, _ := ast.NewPackage(fset, map[string]*ast.File{"p.go": file}, simpleImporter, nil)
+	dpkg := doc.New(apkg, "example.com/p", 0)
+	for _, d := range dpkg.Types {
+		if d.Name != "T" {
+			continue
+		}
+		fields, embedded := structFields(d)
+		if want := []string{"Name"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("fields = %v, want %v", fields, want)
+		}
+		want := []*Embedded{
+			{TypeRef: TypeRef{Name: "Base"}},
+			{TypeRef: TypeRef{ImportPath: "bytes", Package: "bytes", Name: "Buffer"}, Pointer: true},
+			{TypeRef: TypeRef{ImportPath: "io", Package: "xio", Name: "Reader"}},
+		}
+		if !reflect.DeepEqual(embedded, want) {
+			for _, e := range embedded {
+				t.Logf("got %+v", *e)
+			}
+			t.Errorf("embedded types differ")
+		}
+		return
+	}
+	t.Fatal("type T not found")
+}
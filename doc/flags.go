@@ -0,0 +1,200 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flag is a command line flag defined by a command with the flag package.
+type Flag struct {
+	Name string
+
+	// Type is the name of the flag function without the Var suffix in
+	// lower case, such as "string" or "duration", or "value" for flags
+	// defined with flag.Var and "func" for flags defined with flag.Func.
+	Type string
+
+	// Default is the Go expression of the default value or "".
+	Default string
+
+	Usage string
+}
+
+// flagArgs are the positions of the name, default value and usage arguments
+// of the flag functions. A negative position is a missing argument.
+var flagArgs = map[string][3]int{
+	"Bool":     {0, 1, 2},
+	"Duration": {0, 1, 2},
+	"Float64":  {0, 1, 2},
+	"Int":      {0, 1, 2},
+	"Int64":    {0, 1, 2},
+	"String":   {0, 1, 2},
+	"Uint":     {0, 1, 2},
+	"Uint64":   {0, 1, 2},
+
+	"BoolVar":     {1, 2, 3},
+	"DurationVar": {1, 2, 3},
+	"Float64Var":  {1, 2, 3},
+	"IntVar":      {1, 2, 3},
+	"Int64Var":    {1, 2, 3},
+	"StringVar":   {1, 2, 3},
+	"UintVar":     {1, 2, 3},
+	"Uint64Var":   {1, 2, 3},
+	"TextVar":     {1, 2, 3},
+
+	"Var":      {1, -1, 2},
+	"Func":     {0, -1, 1},
+	"BoolFunc": {0, -1, 1},
+}
+
+// isFlagPackage returns true if x is the name of the imported flag package.
+func isFlagPackage(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	if !ok || id.Obj == nil {
+		return false
+	}
+	spec, ok := id.Obj.Decl.(*ast.ImportSpec)
+	return ok && spec.Path.Value == `"flag"`
+}
+
+// stringValue returns the value of a string literal or a concatenation of
+// string literals.
+func stringValue(x ast.Expr) (string, bool) {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if x.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(x.Value)
+		return s, err == nil
+	case *ast.BinaryExpr:
+		if x.Op != token.ADD {
+			return "", false
+		}
+		a, ok := stringValue(x.X)
+		if !ok {
+			return "", false
+		}
+		b, ok := stringValue(x.Y)
+		return a + b, ok
+	case *ast.ParenExpr:
+		return stringValue(x.X)
+	}
+	return "", false
+}
+
+// commandFlags returns the flags defined by calls of the functions of the
+// flag package in the files, sorted by name. Flags defined with a name that
+// is not a string constant in the call are skipped.
+func commandFlags(files map[string]*ast.File) []*Flag {
+	m := make(map[string]*Flag)
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || !isFlagPackage(sel.X) {
+				return true
+			}
+			args, ok := flagArgs[sel.Sel.Name]
+			if !ok || len(call.Args) <= args[2] {
+				return true
+			}
+			name, ok := stringValue(call.Args[args[0]])
+			if !ok || name == "" || m[name] != nil {
+				return true
+			}
+			f := &Flag{Name: name}
+			switch sel.Sel.Name {
+			case "Var":
+				f.Type = "value"
+			case "Func", "BoolFunc":
+				f.Type = "func"
+			default:
+				f.Type = strings.ToLower(strings.TrimSuffix(sel.Sel.Name, "Var"))
+			}
+			if args[1] >= 0 {
+				f.Default = types.ExprString(call.Args[args[1]])
+			}
+			f.Usage, _ = stringValue(call.Args[args[2]])
+			m[name] = f
+			return true
+		})
+	}
+	var flags []*Flag
+	for _, f := range m {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// commandUsage returns the usage text in the doc comment of a command: the
+// text after "Usage:" on the same line and the indented lines following the
+// line.
+func commandUsage(doc string) string {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "Usage:") {
+			continue
+		}
+		var usage []string
+		if s := strings.TrimSpace(strings.TrimSpace(line)[len("Usage:"):]); s != "" {
+			usage = append(usage, s)
+		}
+		blank := 0
+		for _, line := range lines[i+1:] {
+			if strings.TrimSpace(line) == "" {
+				blank++
+				continue
+			}
+			if line[0] != ' ' && line[0] != '\t' {
+				break
+			}
+			if len(usage) > 0 {
+				for ; blank > 0; blank-- {
+					usage = append(usage, "")
+				}
+			}
+			blank = 0
+			usage = append(usage, line)
+		}
+		return unindent(usage)
+	}
+	return ""
+}
+
+// unindent returns the lines joined with the common indentation of the
+// indented lines removed.
+func unindent(lines []string) string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if line == "" || (line[0] != ' ' && line[0] != '\t') {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const flagsSrc = `package main
+
+import (
+	"flag"
+	"time"
+)
+
+var (
+	addr    = flag.String("addr", ":8080", "Listen on this "+"address.")
+	timeout = flag.Duration("timeout", 10*time.Second, "Request timeout.")
+	verbose bool
+	level   int
+	name    = os.Getenv("NAME")
+)
+
+func init() {
+	flag.BoolVar(&verbose, "v", false, "Verbose output.")
+	flag.Var(&level, "level", "Log level.")
+	flag.Func("tag", "Add a tag.", func(string) error { return nil })
+	flag.String(name, "", "Not a constant name.")
+}
+
+func main() {
+	fs := flag.NewFlagSet("sub", flag.ExitOnError)
+	fs.Int("n", 1, "Not found.")
+	flag.Parse()
+}
+`
+
+func TestCommandFlags(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", flagsSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*ast.File{"main.go": file}
+	ast.NewPackage(fset, files, simpleImporter, nil)
+	want := []*Flag{
+		{Name: "addr", Type: "string", Default: `":8080"`, Usage: "Listen on this address."},
+		{Name: "level", Type: "value", Usage: "Log level."},
+		{Name: "tag", Type: "func", Usage: "Add a tag."},
+		{Name: "timeout", Type: "duration", Default: "10 * time.Second", Usage: "Request timeout."},
+		{Name: "v", Type: "bool", Default: "false", Usage: "Verbose output."},
+	}
+	if flags := commandFlags(files); !reflect.DeepEqual(flags, want) {
+		for _, f := range flags {
+			t.Logf("%+v", f)
+		}
+		t.Errorf("commandFlags() returned unexpected flags")
+	}
+}
+
+var commandUsageTests = []struct {
+	doc, usage string
+}{
+	{"Command foo does things.", ""},
+	{"Command foo does things.\n\nUsage:\n\n\tfoo [flags] file...\n\n\t\t-v\tverbose\n\nThe flags are:", "foo [flags] file...\n\n\t-v\tverbose"},
+	{"Command foo does things.\n\nUsage: foo [flags] file\n\nMore text.", "foo [flags] file"},
+}
+
+func TestCommandUsage(t *testing.T) {
+	for _, tt := range commandUsageTests {
+		if usage := commandUsage(tt.doc); usage != tt.usage {
+			t.Errorf("commandUsage(%q) = %q, want %q", tt.doc, usage, tt.usage)
+		}
+	}
+}
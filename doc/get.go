@@ -53,3 +53,10 @@ func Get(client *http.Client, importPath string, etag string) (*Package, error)
 
 	return pdoc, nil
 }
+
+// Build returns the documentation of the directory. Build is used by tools
+// finding the directories without the version control services, such as the
+// local mode of gddo-server.
+func Build(dir *gosrc.Directory) (*Package, error) {
+	return newPackage(dir)
+}
@@ -0,0 +1,219 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/types"
+	"strings"
+)
+
+// TypeRef is a reference to a type declared in the package or in another
+// package.
+type TypeRef struct {
+	// Import path of the package declaring the type or "" if the type is
+	// declared in the package.
+	ImportPath string
+
+	// Qualifier used in source for the type or "" if the type is declared in
+	// the package or predeclared.
+	Package string
+
+	Name string
+}
+
+// methodSet maps method names to signatures formatted by signature.
+type methodSet map[string]string
+
+// knownInterfaces is a list of commonly implemented standard library
+// interfaces. The types in a package are checked against these interfaces
+// in addition to the interfaces declared in the package.
+var knownInterfaces = []struct {
+	ref     TypeRef
+	methods methodSet
+}{
+	{TypeRef{"builtin", "", "error"}, methodSet{"Error": "()(string)"}},
+	{TypeRef{"fmt", "fmt", "Stringer"}, methodSet{"String": "()(string)"}},
+	{TypeRef{"fmt", "fmt", "GoStringer"}, methodSet{"GoString": "()(string)"}},
+	{TypeRef{"fmt", "fmt", "Formatter"}, methodSet{"Format": "(fmt.State, rune)()"}},
+	{TypeRef{"io", "io", "Reader"}, methodSet{"Read": "([]byte)(int, error)"}},
+	{TypeRef{"io", "io", "Writer"}, methodSet{"Write": "([]byte)(int, error)"}},
+	{TypeRef{"io", "io", "Closer"}, methodSet{"Close": "()(error)"}},
+	{TypeRef{"io", "io", "ReadCloser"}, methodSet{"Read": "([]byte)(int, error)", "Close": "()(error)"}},
+	{TypeRef{"io", "io", "WriteCloser"}, methodSet{"Write": "([]byte)(int, error)", "Close": "()(error)"}},
+	{TypeRef{"io", "io", "ReadWriter"}, methodSet{"Read": "([]byte)(int, error)", "Write": "([]byte)(int, error)"}},
+	{TypeRef{"io", "io", "ReaderAt"}, methodSet{"ReadAt": "([]byte, int64)(int, error)"}},
+	{TypeRef{"io", "io", "WriterAt"}, methodSet{"WriteAt": "([]byte, int64)(int, error)"}},
+	{TypeRef{"io", "io", "ReaderFrom"}, methodSet{"ReadFrom": "(io.Reader)(int64, error)"}},
+	{TypeRef{"io", "io", "WriterTo"}, methodSet{"WriteTo": "(io.Writer)(int64, error)"}},
+	{TypeRef{"io", "io", "Seeker"}, methodSet{"Seek": "(int64, int)(int64, error)"}},
+	{TypeRef{"io", "io", "ByteReader"}, methodSet{"ReadByte": "()(byte, error)"}},
+	{TypeRef{"io", "io", "RuneReader"}, methodSet{"ReadRune": "()(rune, int, error)"}},
+	{TypeRef{"sort", "sort", "Interface"}, methodSet{"Len": "()(int)", "Less": "(int, int)(bool)", "Swap": "(int, int)()"}},
+	{TypeRef{"net/http", "http", "Handler"}, methodSet{"ServeHTTP": "(http.ResponseWriter, *http.Request)()"}},
+	{TypeRef{"encoding", "encoding", "TextMarshaler"}, methodSet{"MarshalText": "()([]byte, error)"}},
+	{TypeRef{"encoding", "encoding", "TextUnmarshaler"}, methodSet{"UnmarshalText": "([]byte)(error)"}},
+	{TypeRef{"encoding", "encoding", "BinaryMarshaler"}, methodSet{"MarshalBinary": "()([]byte, error)"}},
+	{TypeRef{"encoding", "encoding", "BinaryUnmarshaler"}, methodSet{"UnmarshalBinary": "([]byte)(error)"}},
+	{TypeRef{"encoding/json", "json", "Marshaler"}, methodSet{"MarshalJSON": "()([]byte, error)"}},
+	{TypeRef{"encoding/json", "json", "Unmarshaler"}, methodSet{"UnmarshalJSON": "([]byte)(error)"}},
+	{TypeRef{"flag", "flag", "Value"}, methodSet{"String": "()(string)", "Set": "(string)(error)"}},
+	{TypeRef{"database/sql", "sql", "Scanner"}, methodSet{"Scan": "(interface{})(error)"}},
+	{TypeRef{"database/sql/driver", "driver", "Valuer"}, methodSet{"Value": "()(driver.Value, error)"}},
+}
+
+func fieldTypes(fields *ast.FieldList) string {
+	if fields == nil {
+		return ""
+	}
+	var result []string
+	for _, f := range fields.List {
+		s := types.ExprString(f.Type)
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			result = append(result, s)
+		}
+	}
+	return strings.Join(result, ", ")
+}
+
+// signature returns the parameter and result types of a function type. The
+// names of the parameters and results are ignored.
+func signature(t *ast.FuncType) string {
+	return "(" + fieldTypes(t.Params) + ")(" + fieldTypes(t.Results) + ")"
+}
+
+// interfaceMethods returns the method set of the interface type t. The
+// function returns false if the method set cannot be determined, for example
+// because the interface has unexported methods or embeds an unknown
+// interface.
+func interfaceMethods(t *ast.InterfaceType, local map[string]*ast.InterfaceType, seen map[*ast.InterfaceType]bool) (methodSet, bool) {
+	if t.Incomplete || seen[t] {
+		return nil, false
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	methods := methodSet{}
+	add := func(embedded methodSet) {
+		for name, sig := range embedded {
+			methods[name] = sig
+		}
+	}
+	for _, f := range t.Methods.List {
+		switch x := f.Type.(type) {
+		case *ast.FuncType:
+			for _, name := range f.Names {
+				methods[name.Name] = signature(x)
+			}
+		case *ast.Ident:
+			it := local[x.Name]
+			if it == nil {
+				if x.Name == "error" {
+					add(knownInterfaces[0].methods)
+					continue
+				}
+				return nil, false
+			}
+			embedded, ok := interfaceMethods(it, local, seen)
+			if !ok {
+				return nil, false
+			}
+			add(embedded)
+		case *ast.SelectorExpr:
+			found := false
+			if pkg, ok := x.X.(*ast.Ident); ok {
+				for _, ki := range knownInterfaces {
+					if ki.ref.Package == pkg.Name && ki.ref.Name == x.Sel.Name {
+						add(ki.methods)
+						found = true
+						break
+					}
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return methods, true
+}
+
+// implements returns true if the methods of a type include all of the
+// methods of an interface.
+func implements(methods, iface methodSet) bool {
+	if len(iface) == 0 {
+		return false
+	}
+	for name, sig := range iface {
+		if methods[name] != sig {
+			return false
+		}
+	}
+	return true
+}
+
+// setImplementations sets the Implements and ImplementedBy fields of
+// pkgTypes, where pkgTypes[i] is built from tdocs[i]. Types are checked
+// against the interfaces declared in the package and against
+// knownInterfaces. The check compares the source text of method signatures
+// and does not resolve types, so types implementing an interface through
+// methods promoted from another package are not found.
+func setImplementations(tdocs []*doc.Type, pkgTypes []*Type) {
+	local := make(map[string]*ast.InterfaceType)
+	for _, d := range tdocs {
+		for _, spec := range d.Decl.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == d.Name {
+				if it, ok := ts.Type.(*ast.InterfaceType); ok {
+					local[d.Name] = it
+				}
+			}
+		}
+	}
+
+	ifaces := make(map[string]methodSet)
+	for name, it := range local {
+		if methods, ok := interfaceMethods(it, local, make(map[*ast.InterfaceType]bool)); ok {
+			ifaces[name] = methods
+		}
+	}
+
+	byName := make(map[string]*Type)
+	for _, t := range pkgTypes {
+		byName[t.Name] = t
+	}
+
+	for i, d := range tdocs {
+		if local[d.Name] != nil || len(d.Methods) == 0 {
+			continue
+		}
+		methods := methodSet{}
+		for _, m := range d.Methods {
+			methods[m.Name] = signature(m.Decl.Type)
+		}
+		t := pkgTypes[i]
+		for _, di := range tdocs {
+			if iface, ok := ifaces[di.Name]; ok && implements(methods, iface) {
+				t.Implements = append(t.Implements, &TypeRef{Name: di.Name})
+				it := byName[di.Name]
+				it.ImplementedBy = append(it.ImplementedBy, &TypeRef{Name: d.Name})
+			}
+		}
+		for _, ki := range knownInterfaces {
+			if implements(methods, ki.methods) {
+				ref := ki.ref
+				t.Implements = append(t.Implements, &ref)
+			}
+		}
+	}
+}
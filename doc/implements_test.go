@@ -0,0 +1,79 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const implementsSrc = `package p
+
+import "io"
+
+type Shape interface {
+	Area() float64
+}
+
+type ReadShape interface {
+	Shape
+	io.Reader
+}
+
+type hidden interface {
+	Shape
+	secret()
+}
+
+type Square struct{}
+
+func (s Square) Area() float64 { return 0 }
+func (s *Square) Read(p []byte) (n int, err error) { return 0, nil }
+func (s Square) Error() string { return "" }
+
+type Circle struct{}
+
+func (c Circle) Area() int { return 0 }
+func (c Circle) String() string { return "" }
+`
+
+func TestSetImplementations(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", implementsSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apkg := &ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": file}}
+	dpkg := doc.New(apkg, "example.com/p", 0)
+	var types []*Type
+	for _, d := range dpkg.Types {
+		types = append(types, &Type{Name: d.Name})
+	}
+	setImplementations(dpkg.Types, types)
+
+	want := map[string][2][]*TypeRef{
+		"Circle":    {{{"fmt", "fmt", "Stringer"}}, nil},
+		"ReadShape": {nil, {{Name: "Square"}}},
+		"Shape":     {nil, {{Name: "Square"}}},
+		"Square": {{
+			{Name: "ReadShape"},
+			{Name: "Shape"},
+			{"builtin", "", "error"},
+			{"io", "io", "Reader"},
+		}, nil},
+	}
+	for _, typ := range types {
+		got := [2][]*TypeRef{typ.Implements, typ.ImplementedBy}
+		if !reflect.DeepEqual(got, want[typ.Name]) {
+			t.Errorf("%s: got Implements %v, ImplementedBy %v; want %v, %v", typ.Name, got[0], got[1], want[typ.Name][0], want[typ.Name][1])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestDetectLicense(t *testing.T) {
+	for id, texts := range licenseTexts {
+		for _, text := range texts {
+			data := "Copyright (c) 2020 Some Author <author@example.com>\n\n" + text + "\n"
+			l, ok := detectLicense("LICENSE", []byte(data))
+			if !ok || l.ID != id || l.Confidence < minLicenseConfidence {
+				t.Errorf("detectLicense(%s text) = %+v, %v", id, l, ok)
+			}
+		}
+	}
+
+	// The license of this repository is BSD-3-Clause with the name of the
+	// copyright holder in the third clause.
+	p, err := ioutil.ReadFile("../LICENSE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := detectLicense("LICENSE", p); !ok || l.ID != "BSD-3-Clause" {
+		t.Errorf("detectLicense(../LICENSE) = %+v, %v, want BSD-3-Clause", l, ok)
+	}
+
+	if l, ok := detectLicense("LICENSE", []byte("All rights reserved. Do not copy.")); ok {
+		t.Errorf("detectLicense(proprietary) = %+v, want no license", l)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	maxDeclSize int
+	maxDocSize  int
+)
+
+// SetSizeLimits sets the size budgets of package documents. Declarations
+// with code larger than declSize bytes are collapsed to their first lines.
+// When the code and the doc comments of the declarations of a package are
+// larger than docSize bytes, the declarations after the budget is spent are
+// omitted and the package is marked as truncated. Generated packages with
+// thousands of declarations are the documents hitting the limits. Zero
+// disables a limit.
+func SetSizeLimits(declSize, docSize int) {
+	maxDeclSize = declSize
+	maxDocSize = docSize
+}
+
+// collapseCode returns the code cut at the last line break before max bytes.
+// The cut lines are replaced with a comment and the last line of the code,
+// the closing brace or parenthesis of the declaration.
+func collapseCode(c Code, max int) Code {
+	if max <= 0 || len(c.Text) <= max {
+		return c
+	}
+	i := strings.LastIndex(c.Text[:max], "\n")
+	j := strings.LastIndex(c.Text, "\n")
+	if i <= 0 || i >= j {
+		return c
+	}
+	var annotations []Annotation
+	for _, a := range c.Annotations {
+		if int(a.End) <= i {
+			annotations = append(annotations, a)
+		}
+	}
+	comment := fmt.Sprintf("\t// %d bytes not displayed", j-i)
+	pos := int32(i + 1)
+	annotations = append(annotations, Annotation{Kind: CommentAnnotation, Pos: pos + 1, End: pos + int32(len(comment))})
+	return Code{
+		Text:        c.Text[:i+1] + comment + c.Text[j:],
+		Annotations: annotations,
+		Paths:       c.Paths,
+	}
+}
+
+// sizeBudget is the budget of the declarations of a package.
+type sizeBudget struct {
+	left    int
+	spent   bool
+	omitted int
+}
+
+// fits returns true if a declaration of the size fits in the budget and
+// spends the size. After a declaration does not fit, no declaration fits.
+func (b *sizeBudget) fits(size int) bool {
+	if maxDocSize <= 0 {
+		return true
+	}
+	if !b.spent && size <= b.left {
+		b.left -= size
+		return true
+	}
+	b.spent = true
+	b.omitted++
+	return false
+}
+
+func (b *sizeBudget) values(values []*Value) []*Value {
+	var kept []*Value
+	for _, v := range values {
+		v.Decl = collapseCode(v.Decl, maxDeclSize)
+		if b.fits(len(v.Decl.Text) + len(v.Doc)) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func (b *sizeBudget) funcs(funcs []*Func) []*Func {
+	var kept []*Func
+	for _, f := range funcs {
+		f.Decl = collapseCode(f.Decl, maxDeclSize)
+		if b.fits(len(f.Decl.Text) + len(f.Doc)) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// applySizeLimits collapses the large declarations of the package and omits
+// the declarations after the size budget of the package is spent, in the
+// order of the documentation page.
+func applySizeLimits(pkg *Package) {
+	b := &sizeBudget{left: maxDocSize}
+	pkg.Consts = b.values(pkg.Consts)
+	pkg.Vars = b.values(pkg.Vars)
+	pkg.Funcs = b.funcs(pkg.Funcs)
+	var types []*Type
+	for _, t := range pkg.Types {
+		t.Decl = collapseCode(t.Decl, maxDeclSize)
+		if !b.fits(len(t.Decl.Text) + len(t.Doc)) {
+			b.omitted += len(t.Consts) + len(t.Vars) + len(t.Funcs) + len(t.Methods)
+			continue
+		}
+		t.Consts = b.values(t.Consts)
+		t.Vars = b.values(t.Vars)
+		t.Funcs = b.funcs(t.Funcs)
+		t.Methods = b.funcs(t.Methods)
+		types = append(types, t)
+	}
+	pkg.Types = types
+	if b.omitted > 0 {
+		pkg.Truncated = true
+		pkg.OmittedDecls = b.omitted
+	}
+}
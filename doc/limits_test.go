@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollapseCode(t *testing.T) {
+	c := Code{
+		Text: "type T struct {\n\tA int\n\tB int\n\tC int\n}",
+		Annotations: []Annotation{
+			{Kind: AnchorAnnotation, Pos: 17, End: 18},
+			{Kind: AnchorAnnotation, Pos: 24, End: 25},
+			{Kind: AnchorAnnotation, Pos: 31, End: 32},
+		},
+	}
+	got := collapseCode(c, 25)
+	want := Code{
+		Text: "type T struct {\n\tA int\n\t// 14 bytes not displayed\n}",
+		Annotations: []Annotation{
+			{Kind: AnchorAnnotation, Pos: 17, End: 18},
+			{Kind: CommentAnnotation, Pos: 24, End: 49},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collapseCode() = %+v, want %+v", got, want)
+	}
+	if got := collapseCode(c, 100); !reflect.DeepEqual(got, c) {
+		t.Errorf("collapseCode() of short code = %+v, want unchanged", got)
+	}
+}
+
+func TestApplySizeLimits(t *testing.T) {
+	defer SetSizeLimits(0, 0)
+	SetSizeLimits(0, 30)
+	pkg := &Package{
+		Consts: []*Value{{Decl: Code{Text: "const A = 1"}}},
+		Funcs:  []*Func{{Name: "F", Decl: Code{Text: "func F()"}, Doc: "F does."}},
+		Types: []*Type{
+			{Name: "T", Decl: Code{Text: "type T int"}, Methods: []*Func{{Name: "M", Decl: Code{Text: "func (T) M()"}}}},
+			{Name: "U", Decl: Code{Text: "type U int"}, Funcs: []*Func{{Name: "NewU"}}},
+		},
+	}
+	applySizeLimits(pkg)
+	if len(pkg.Consts) != 1 || len(pkg.Funcs) != 1 || len(pkg.Types) != 0 {
+		t.Errorf("kept %d consts, %d funcs and %d types, want 1, 1 and 0", len(pkg.Consts), len(pkg.Funcs), len(pkg.Types))
+	}
+	if !pkg.Truncated || pkg.OmittedDecls != 4 {
+		t.Errorf("Truncated, OmittedDecls = %v, %d, want true, 4", pkg.Truncated, pkg.OmittedDecls)
+	}
+}
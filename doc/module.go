@@ -0,0 +1,108 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Module is the module metadata in the go.mod file of a module root.
+type Module struct {
+	// Path is the module path declared by the module directive or "" if the
+	// file does not declare a module path.
+	Path string
+
+	// Root is the import path of the directory containing the go.mod file.
+	Root string
+
+	// GoVersion is the minimum Go version declared by the go directive or "".
+	GoVersion string
+
+	// Requires are the direct requirements of the module. Requirements
+	// marked with an // indirect comment are omitted.
+	Requires []Requirement
+}
+
+// Requirement is a module required by a module.
+type Requirement struct {
+	Path    string
+	Version string
+}
+
+var majorVersionSuffix = regexp.MustCompile(`/v[0-9]+$`)
+
+// PathMismatch returns true if the declared module path is not the import
+// path of the module root. The major version suffix of a module path
+// developed on a major branch is not a mismatch.
+func (m *Module) PathMismatch() bool {
+	return m.Path != m.Root && majorVersionSuffix.ReplaceAllString(m.Path, "") != m.Root
+}
+
+// goModFields returns the fields of a go.mod line with the comment removed
+// and true if the comment marks the line as // indirect.
+func goModFields(line string) ([]string, bool) {
+	indirect := false
+	if i := strings.Index(line, "//"); i >= 0 {
+		c := strings.TrimSpace(line[i+len("//"):])
+		indirect = c == "indirect" || strings.HasPrefix(c, "indirect;")
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if strings.HasPrefix(f, `"`) || strings.HasPrefix(f, "`") {
+			if s, err := strconv.Unquote(f); err == nil {
+				fields[i] = s
+			}
+		}
+	}
+	return fields, indirect
+}
+
+// parseGoMod returns the module metadata of the go.mod file with the
+// contents p in the directory with the import path root.
+func parseGoMod(root string, p []byte) *Module {
+	m := &Module{Root: root}
+	block := ""
+	s := bufio.NewScanner(bytes.NewReader(p))
+	for s.Scan() {
+		fields, indirect := goModFields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		verb := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			verb = fields[0]
+			fields = fields[1:]
+		}
+		switch verb {
+		case "module":
+			if len(fields) == 1 {
+				m.Path = fields[0]
+			}
+		case "go":
+			if len(fields) == 1 {
+				m.GoVersion = fields[0]
+			}
+		case "require":
+			if len(fields) == 2 && !indirect {
+				m.Requires = append(m.Requires, Requirement{Path: fields[0], Version: fields[1]})
+			}
+		}
+	}
+	return m
+}
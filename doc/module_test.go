@@ -0,0 +1,64 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testGoMod = `// Module example.
+module "github.com/user/repo/v2" // comment
+
+go 1.21
+
+require github.com/a/b v1.0.0
+
+require (
+	github.com/c/d v0.2.0
+	github.com/e/f v1.3.0 // indirect
+	golang.org/x/text v0.14.0 // indirect; used by github.com/c/d
+	"github.com/g/h" v2.0.0+incompatible
+)
+
+replace github.com/a/b => ../b
+`
+
+func TestParseGoMod(t *testing.T) {
+	got := parseGoMod("github.com/user/repo", []byte(testGoMod))
+	want := &Module{
+		Path:      "github.com/user/repo/v2",
+		Root:      "github.com/user/repo",
+		GoVersion: "1.21",
+		Requires: []Requirement{
+			{"github.com/a/b", "v1.0.0"},
+			{"github.com/c/d", "v0.2.0"},
+			{"github.com/g/h", "v2.0.0+incompatible"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGoMod() = %+v, want %+v", got, want)
+	}
+}
+
+func TestModulePathMismatch(t *testing.T) {
+	for _, tt := range []struct {
+		path, root string
+		want       bool
+	}{
+		{"github.com/user/repo", "github.com/user/repo", false},
+		{"github.com/user/repo/v2", "github.com/user/repo", false},
+		{"github.com/user/repo/v2", "github.com/user/repo/v2", false},
+		{"example.com/repo", "github.com/user/repo", true},
+		{"github.com/old/repo", "github.com/user/repo", true},
+	} {
+		m := &Module{Path: tt.path, Root: tt.root}
+		if got := m.PathMismatch(); got != tt.want {
+			t.Errorf("PathMismatch(%q, %q) = %v, want %v", tt.path, tt.root, got, tt.want)
+		}
+	}
+}
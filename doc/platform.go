@@ -0,0 +1,74 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/build"
+	"sort"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+// platformEnvs are the build contexts checked for documentation variants.
+// A variant is built for a context if the files of the package for the
+// context differ from the files of the package document.
+var platformEnvs = []goEnv{
+	{"linux", "amd64"},
+	{"darwin", "amd64"},
+	{"windows", "amd64"},
+	{"freebsd", "amd64"},
+	{"linux", "arm64"},
+	{"js", "wasm"},
+}
+
+// Platform returns the build context of the package formatted as
+// GOOS/GOARCH.
+func (pkg *Package) Platform() string {
+	return pkg.GOOS + "/" + pkg.GOARCH
+}
+
+func sortedFileNames(names []string) []string {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	return names
+}
+
+func sameFiles(files []*File, names []string) bool {
+	if len(files) != len(names) {
+		return false
+	}
+	for i, f := range files {
+		if f.Name != names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newPackage(dir *gosrc.Directory) (*Package, error) {
+	pkg, err := newPackageForEnvs(dir, goEnvs)
+	if err != nil || pkg.Name == "" {
+		return pkg, err
+	}
+	for _, env := range platformEnvs {
+		if env.GOOS == pkg.GOOS && env.GOARCH == pkg.GOARCH {
+			continue
+		}
+		ctxt := buildContext(env)
+		bpkg, err := dir.Import(&ctxt, build.ImportComment)
+		if err != nil || sameFiles(pkg.Files, sortedFileNames(append(bpkg.GoFiles, bpkg.CgoFiles...))) {
+			continue
+		}
+		variant, err := newPackageForEnvs(dir, []goEnv{env})
+		if err != nil || variant.Name == "" {
+			continue
+		}
+		variant.Sources = nil
+		pkg.Variants = append(pkg.Variants, variant)
+	}
+	return pkg, nil
+}
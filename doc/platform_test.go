@@ -0,0 +1,34 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"testing"
+)
+
+var sameFilesTests = []struct {
+	files []string
+	names []string
+	same  bool
+}{
+	{nil, nil, true},
+	{[]string{"a.go", "b.go"}, []string{"b.go", "a.go"}, true},
+	{[]string{"a.go", "b.go"}, []string{"a.go", "b_windows.go"}, false},
+	{[]string{"a.go"}, []string{"a.go", "a_windows.go"}, false},
+}
+
+func TestSameFiles(t *testing.T) {
+	for _, tt := range sameFilesTests {
+		var files []*File
+		for _, name := range tt.files {
+			files = append(files, &File{Name: name})
+		}
+		if same := sameFiles(files, sortedFileNames(tt.names)); same != tt.same {
+			t.Errorf("sameFiles(%v, %v) = %v, want %v", tt.files, tt.names, same, tt.same)
+		}
+	}
+}
@@ -0,0 +1,872 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	_ "embed"
+	"sort"
+	"time"
+
+	"github.com/golang/gddo/proto"
+)
+
+// This file encodes the package documents as the messages in package.proto.
+// A field added to a type of the package must be added to the message and to
+// the append and decode functions of the type; TestProto fails for a field
+// that does not survive the encoding.
+
+// PackageProto is the contents of package.proto, the definitions of the
+// messages encoded by MarshalProto.
+//
+//go:embed package.proto
+var PackageProto string
+
+// MarshalProto returns the encoding of the package as a Package message.
+func (p *Package) MarshalProto() []byte {
+	var b proto.Buffer
+	appendPackage(&b, p)
+	return b.Bytes()
+}
+
+// UnmarshalProto decodes a Package message into the package.
+func (p *Package) UnmarshalProto(data []byte) error {
+	*p = Package{}
+	d := proto.NewDecoder(data)
+	decodePackage(d, p)
+	return d.Err()
+}
+
+func appendPackage(b *proto.Buffer, p *Package) {
+	b.AppendString(1, p.ImportPath)
+	b.AppendString(2, p.ProjectRoot)
+	b.AppendString(3, p.ProjectName)
+	b.AppendString(4, p.ProjectURL)
+	b.AppendStrings(5, p.Errors)
+	b.AppendStrings(6, p.References)
+	if p.Readme != nil {
+		b.AppendMessage(7, func(b *proto.Buffer) {
+			b.AppendString(1, p.Readme.Name)
+			b.AppendString(2, p.Readme.BrowseURL)
+			b.AppendBytes(3, p.Readme.Data)
+		})
+	}
+	for _, t := range p.Talks {
+		b.AppendMessage(8, func(b *proto.Buffer) {
+			b.AppendString(1, t.Name)
+			b.AppendString(2, t.Title)
+			b.AppendString(3, t.BrowseURL)
+		})
+	}
+	b.AppendString(9, p.VCS)
+	b.AppendBool(10, p.DeadEndFork)
+	b.AppendBool(11, p.Fork)
+	b.AppendBool(12, p.Archived)
+	b.AppendInt(13, int64(p.Stars))
+	appendTime(b, 14, p.Pushed)
+	b.AppendBool(15, p.Private)
+	b.AppendBool(16, p.Verified)
+	b.AppendBool(17, p.NestedModule)
+	b.AppendBool(18, p.OptOut)
+	b.AppendString(19, p.Canonical)
+	for _, l := range p.Licenses {
+		b.AppendMessage(20, func(b *proto.Buffer) {
+			b.AppendString(1, l.ID)
+			b.AppendDouble(2, l.Confidence)
+			b.AppendString(3, l.File)
+		})
+	}
+	if m := p.Module; m != nil {
+		b.AppendMessage(21, func(b *proto.Buffer) {
+			b.AppendString(1, m.Path)
+			b.AppendString(2, m.Root)
+			b.AppendString(3, m.GoVersion)
+			for _, r := range m.Requires {
+				b.AppendMessage(4, func(b *proto.Buffer) {
+					b.AppendString(1, r.Path)
+					b.AppendString(2, r.Version)
+				})
+			}
+		})
+	}
+	if q := p.Quality; q != nil {
+		b.AppendMessage(22, func(b *proto.Buffer) {
+			b.AppendBool(1, q.HasTests)
+			b.AppendBool(2, q.HasExamples)
+			b.AppendBool(3, q.HasReadme)
+			b.AppendInt(4, int64(q.Exported))
+			b.AppendInt(5, int64(q.Documented))
+			b.AppendStrings(6, q.Unformatted)
+			b.AppendInt(7, int64(q.Score))
+		})
+	}
+	b.AppendString(23, p.OwnerKeyHash)
+	appendTime(b, 24, p.Updated)
+	b.AppendString(25, p.Etag)
+	b.AppendString(26, p.Version)
+	b.AppendStrings(27, p.Subdirectories)
+	b.AppendStrings(28, p.ModuleVersions)
+	b.AppendString(29, p.Name)
+	b.AppendString(30, p.Synopsis)
+	b.AppendString(31, p.Doc)
+	b.AppendString(32, p.Deprecated)
+	b.AppendBool(33, p.IsCmd)
+	b.AppendString(34, p.Usage)
+	for _, f := range p.Flags {
+		b.AppendMessage(35, func(b *proto.Buffer) {
+			b.AppendString(1, f.Name)
+			b.AppendString(2, f.Type)
+			b.AppendString(3, f.Default)
+			b.AppendString(4, f.Usage)
+		})
+	}
+	b.AppendBool(36, p.Truncated)
+	b.AppendInt(37, int64(p.OmittedDecls))
+	b.AppendString(38, p.GOOS)
+	b.AppendString(39, p.GOARCH)
+	b.AppendStrings(40, p.BuildTags)
+	appendValues(b, 41, p.Consts)
+	appendFuncs(b, 42, p.Funcs)
+	for _, t := range p.Types {
+		b.AppendMessage(43, func(b *proto.Buffer) { appendType(b, t) })
+	}
+	appendValues(b, 44, p.Vars)
+	appendFuncs(b, 45, p.CgoExports)
+	for _, s := range p.API {
+		b.AppendMessage(46, func(b *proto.Buffer) {
+			b.AppendString(1, s.Name)
+			b.AppendString(2, s.Kind)
+			b.AppendString(3, s.Decl)
+		})
+	}
+	appendExamples(b, 47, p.Examples)
+	// The map entries are sorted by key for a deterministic encoding.
+	var keys []string
+	for k := range p.Notes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.AppendMessage(48, func(b *proto.Buffer) {
+			b.AppendString(1, k)
+			b.AppendMessage(2, func(b *proto.Buffer) {
+				for _, n := range p.Notes[k] {
+					b.AppendMessage(1, func(b *proto.Buffer) {
+						appendPos(b, 1, n.Pos)
+						b.AppendString(2, n.UID)
+						b.AppendString(3, n.Body)
+					})
+				}
+			})
+		})
+	}
+	keys = keys[:0]
+	for k := range p.DocLinks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.AppendMessage(49, func(b *proto.Buffer) {
+			b.AppendString(1, k)
+			b.AppendMessage(2, func(b *proto.Buffer) {
+				b.AppendString(1, p.DocLinks[k].ImportPath)
+				b.AppendString(2, p.DocLinks[k].Name)
+			})
+		})
+	}
+	b.AppendString(50, p.LineFmt)
+	b.AppendString(51, p.BrowseURL)
+	appendFiles(b, 52, p.Files)
+	appendFiles(b, 53, p.TestFiles)
+	b.AppendInt(54, int64(p.SourceSize))
+	b.AppendInt(55, int64(p.TestSourceSize))
+	for _, s := range p.Sources {
+		b.AppendMessage(56, func(b *proto.Buffer) {
+			b.AppendString(1, s.Name)
+			b.AppendBytes(2, s.Data)
+		})
+	}
+	b.AppendBool(57, p.SourcesStored)
+	for _, v := range p.Variants {
+		b.AppendMessage(58, func(b *proto.Buffer) { appendPackage(b, v) })
+	}
+	b.AppendStrings(59, p.Platforms)
+	b.AppendStrings(60, p.Imports)
+	b.AppendStrings(61, p.TestImports)
+	b.AppendStrings(62, p.XTestImports)
+}
+
+// appendTime appends t as a google.protobuf.Timestamp message.
+func appendTime(b *proto.Buffer, field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	b.AppendMessage(field, func(b *proto.Buffer) {
+		b.AppendInt(1, t.Unix())
+		b.AppendInt(2, int64(t.Nanosecond()))
+	})
+}
+
+func appendPos(b *proto.Buffer, field int, pos Pos) {
+	if pos == (Pos{}) {
+		return
+	}
+	b.AppendMessage(field, func(b *proto.Buffer) {
+		b.AppendInt(1, int64(pos.Line))
+		b.AppendUint(2, uint64(pos.N))
+		b.AppendInt(3, int64(pos.File))
+	})
+}
+
+func appendCode(b *proto.Buffer, field int, c Code) {
+	b.AppendMessage(field, func(b *proto.Buffer) {
+		b.AppendString(1, c.Text)
+		for _, a := range c.Annotations {
+			b.AppendMessage(2, func(b *proto.Buffer) {
+				b.AppendInt(1, int64(a.Pos))
+				b.AppendInt(2, int64(a.End))
+				b.AppendInt(3, int64(a.Kind))
+				b.AppendInt(4, int64(a.PathIndex))
+			})
+		}
+		b.AppendStrings(3, c.Paths)
+	})
+}
+
+func appendValues(b *proto.Buffer, field int, values []*Value) {
+	for _, v := range values {
+		b.AppendMessage(field, func(b *proto.Buffer) {
+			appendCode(b, 1, v.Decl)
+			appendPos(b, 2, v.Pos)
+			b.AppendString(3, v.Doc)
+			b.AppendStrings(4, v.Names)
+			b.AppendString(5, v.Deprecated)
+		})
+	}
+}
+
+func appendExamples(b *proto.Buffer, field int, examples []*Example) {
+	for _, e := range examples {
+		b.AppendMessage(field, func(b *proto.Buffer) {
+			b.AppendString(1, e.Name)
+			b.AppendString(2, e.Doc)
+			appendCode(b, 3, e.Code)
+			b.AppendString(4, e.Play)
+			b.AppendString(5, e.Output)
+			b.AppendBool(6, e.HasOutput)
+			b.AppendBool(7, e.Checked)
+			b.AppendString(8, e.CheckErrors)
+		})
+	}
+}
+
+func appendFuncs(b *proto.Buffer, field int, funcs []*Func) {
+	for _, f := range funcs {
+		b.AppendMessage(field, func(b *proto.Buffer) {
+			appendCode(b, 1, f.Decl)
+			appendPos(b, 2, f.Pos)
+			b.AppendString(3, f.Doc)
+			b.AppendString(4, f.Name)
+			b.AppendString(5, f.Recv)
+			appendExamples(b, 6, f.Examples)
+			b.AppendStrings(7, f.UsedBy)
+			b.AppendString(8, f.CgoExport)
+			b.AppendString(9, f.PromotedFrom)
+			b.AppendString(10, f.Deprecated)
+		})
+	}
+}
+
+func appendTypeRefs(b *proto.Buffer, field int, refs []*TypeRef) {
+	for _, r := range refs {
+		b.AppendMessage(field, func(b *proto.Buffer) {
+			b.AppendString(1, r.ImportPath)
+			b.AppendString(2, r.Package)
+			b.AppendString(3, r.Name)
+		})
+	}
+}
+
+func appendType(b *proto.Buffer, t *Type) {
+	b.AppendString(1, t.Doc)
+	b.AppendString(2, t.Name)
+	appendCode(b, 3, t.Decl)
+	appendPos(b, 4, t.Pos)
+	appendValues(b, 5, t.Consts)
+	appendValues(b, 6, t.Vars)
+	appendFuncs(b, 7, t.Funcs)
+	appendFuncs(b, 8, t.Methods)
+	appendExamples(b, 9, t.Examples)
+	appendTypeRefs(b, 10, t.Implements)
+	appendTypeRefs(b, 11, t.ImplementedBy)
+	b.AppendStrings(12, t.Fields)
+	for _, e := range t.Embedded {
+		b.AppendMessage(13, func(b *proto.Buffer) {
+			b.AppendString(1, e.ImportPath)
+			b.AppendString(2, e.Package)
+			b.AppendString(3, e.Name)
+			b.AppendBool(4, e.Pointer)
+		})
+	}
+	b.AppendString(14, t.Deprecated)
+}
+
+func appendFiles(b *proto.Buffer, field int, files []*File) {
+	for _, f := range files {
+		b.AppendMessage(field, func(b *proto.Buffer) {
+			b.AppendString(1, f.Name)
+			b.AppendString(2, f.URL)
+		})
+	}
+}
+
+func decodePackage(d *proto.Decoder, p *Package) {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			p.ImportPath = d.String()
+		case 2:
+			p.ProjectRoot = d.String()
+		case 3:
+			p.ProjectName = d.String()
+		case 4:
+			p.ProjectURL = d.String()
+		case 5:
+			p.Errors = append(p.Errors, d.String())
+		case 6:
+			p.References = append(p.References, d.String())
+		case 7:
+			p.Readme = &Readme{}
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						p.Readme.Name = d.String()
+					case 2:
+						p.Readme.BrowseURL = d.String()
+					case 3:
+						p.Readme.Data = d.Bytes()
+					}
+				}
+			})
+		case 8:
+			t := &Talk{}
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						t.Name = d.String()
+					case 2:
+						t.Title = d.String()
+					case 3:
+						t.BrowseURL = d.String()
+					}
+				}
+			})
+			p.Talks = append(p.Talks, t)
+		case 9:
+			p.VCS = d.String()
+		case 10:
+			p.DeadEndFork = d.Bool()
+		case 11:
+			p.Fork = d.Bool()
+		case 12:
+			p.Archived = d.Bool()
+		case 13:
+			p.Stars = int(d.Int())
+		case 14:
+			p.Pushed = decodeTime(d)
+		case 15:
+			p.Private = d.Bool()
+		case 16:
+			p.Verified = d.Bool()
+		case 17:
+			p.NestedModule = d.Bool()
+		case 18:
+			p.OptOut = d.Bool()
+		case 19:
+			p.Canonical = d.String()
+		case 20:
+			var l License
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						l.ID = d.String()
+					case 2:
+						l.Confidence = d.Double()
+					case 3:
+						l.File = d.String()
+					}
+				}
+			})
+			p.Licenses = append(p.Licenses, l)
+		case 21:
+			p.Module = &Module{}
+			d.Message(func(d *proto.Decoder) { decodeModule(d, p.Module) })
+		case 22:
+			p.Quality = &Quality{}
+			d.Message(func(d *proto.Decoder) { decodeQuality(d, p.Quality) })
+		case 23:
+			p.OwnerKeyHash = d.String()
+		case 24:
+			p.Updated = decodeTime(d)
+		case 25:
+			p.Etag = d.String()
+		case 26:
+			p.Version = d.String()
+		case 27:
+			p.Subdirectories = append(p.Subdirectories, d.String())
+		case 28:
+			p.ModuleVersions = append(p.ModuleVersions, d.String())
+		case 29:
+			p.Name = d.String()
+		case 30:
+			p.Synopsis = d.String()
+		case 31:
+			p.Doc = d.String()
+		case 32:
+			p.Deprecated = d.String()
+		case 33:
+			p.IsCmd = d.Bool()
+		case 34:
+			p.Usage = d.String()
+		case 35:
+			f := &Flag{}
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						f.Name = d.String()
+					case 2:
+						f.Type = d.String()
+					case 3:
+						f.Default = d.String()
+					case 4:
+						f.Usage = d.String()
+					}
+				}
+			})
+			p.Flags = append(p.Flags, f)
+		case 36:
+			p.Truncated = d.Bool()
+		case 37:
+			p.OmittedDecls = int(d.Int())
+		case 38:
+			p.GOOS = d.String()
+		case 39:
+			p.GOARCH = d.String()
+		case 40:
+			p.BuildTags = append(p.BuildTags, d.String())
+		case 41:
+			p.Consts = append(p.Consts, decodeValue(d))
+		case 42:
+			p.Funcs = append(p.Funcs, decodeFunc(d))
+		case 43:
+			t := &Type{}
+			d.Message(func(d *proto.Decoder) { decodeType(d, t) })
+			p.Types = append(p.Types, t)
+		case 44:
+			p.Vars = append(p.Vars, decodeValue(d))
+		case 45:
+			p.CgoExports = append(p.CgoExports, decodeFunc(d))
+		case 46:
+			var s APISymbol
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						s.Name = d.String()
+					case 2:
+						s.Kind = d.String()
+					case 3:
+						s.Decl = d.String()
+					}
+				}
+			})
+			p.API = append(p.API, s)
+		case 47:
+			p.Examples = append(p.Examples, decodeExample(d))
+		case 48:
+			var k string
+			var notes []*Note
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						k = d.String()
+					case 2:
+						d.Message(func(d *proto.Decoder) {
+							for d.Next() {
+								if d.Field() == 1 {
+									notes = append(notes, decodeNote(d))
+								}
+							}
+						})
+					}
+				}
+			})
+			if p.Notes == nil {
+				p.Notes = make(map[string][]*Note)
+			}
+			p.Notes[k] = notes
+		case 49:
+			var k string
+			var l DocLink
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						k = d.String()
+					case 2:
+						d.Message(func(d *proto.Decoder) {
+							for d.Next() {
+								switch d.Field() {
+								case 1:
+									l.ImportPath = d.String()
+								case 2:
+									l.Name = d.String()
+								}
+							}
+						})
+					}
+				}
+			})
+			if p.DocLinks == nil {
+				p.DocLinks = make(map[string]DocLink)
+			}
+			p.DocLinks[k] = l
+		case 50:
+			p.LineFmt = d.String()
+		case 51:
+			p.BrowseURL = d.String()
+		case 52:
+			p.Files = append(p.Files, decodeFile(d))
+		case 53:
+			p.TestFiles = append(p.TestFiles, decodeFile(d))
+		case 54:
+			p.SourceSize = int(d.Int())
+		case 55:
+			p.TestSourceSize = int(d.Int())
+		case 56:
+			s := &Source{}
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						s.Name = d.String()
+					case 2:
+						s.Data = d.Bytes()
+					}
+				}
+			})
+			p.Sources = append(p.Sources, s)
+		case 57:
+			p.SourcesStored = d.Bool()
+		case 58:
+			v := &Package{}
+			d.Message(func(d *proto.Decoder) { decodePackage(d, v) })
+			p.Variants = append(p.Variants, v)
+		case 59:
+			p.Platforms = append(p.Platforms, d.String())
+		case 60:
+			p.Imports = append(p.Imports, d.String())
+		case 61:
+			p.TestImports = append(p.TestImports, d.String())
+		case 62:
+			p.XTestImports = append(p.XTestImports, d.String())
+		}
+	}
+}
+
+func decodeTime(d *proto.Decoder) time.Time {
+	var sec, nsec int64
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				sec = d.Int()
+			case 2:
+				nsec = d.Int()
+			}
+		}
+	})
+	return time.Unix(sec, nsec).UTC()
+}
+
+func decodeModule(d *proto.Decoder, m *Module) {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Path = d.String()
+		case 2:
+			m.Root = d.String()
+		case 3:
+			m.GoVersion = d.String()
+		case 4:
+			var r Requirement
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						r.Path = d.String()
+					case 2:
+						r.Version = d.String()
+					}
+				}
+			})
+			m.Requires = append(m.Requires, r)
+		}
+	}
+}
+
+func decodeQuality(d *proto.Decoder, q *Quality) {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			q.HasTests = d.Bool()
+		case 2:
+			q.HasExamples = d.Bool()
+		case 3:
+			q.HasReadme = d.Bool()
+		case 4:
+			q.Exported = int(d.Int())
+		case 5:
+			q.Documented = int(d.Int())
+		case 6:
+			q.Unformatted = append(q.Unformatted, d.String())
+		case 7:
+			q.Score = int(d.Int())
+		}
+	}
+}
+
+func decodePos(d *proto.Decoder) Pos {
+	var pos Pos
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				pos.Line = int32(d.Int())
+			case 2:
+				pos.N = uint16(d.Uint())
+			case 3:
+				pos.File = int16(d.Int())
+			}
+		}
+	})
+	return pos
+}
+
+func decodeCode(d *proto.Decoder) Code {
+	var c Code
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				c.Text = d.String()
+			case 2:
+				var a Annotation
+				d.Message(func(d *proto.Decoder) {
+					for d.Next() {
+						switch d.Field() {
+						case 1:
+							a.Pos = int32(d.Int())
+						case 2:
+							a.End = int32(d.Int())
+						case 3:
+							a.Kind = AnnotationKind(d.Int())
+						case 4:
+							a.PathIndex = int16(d.Int())
+						}
+					}
+				})
+				c.Annotations = append(c.Annotations, a)
+			case 3:
+				c.Paths = append(c.Paths, d.String())
+			}
+		}
+	})
+	return c
+}
+
+func decodeValue(d *proto.Decoder) *Value {
+	v := &Value{}
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				v.Decl = decodeCode(d)
+			case 2:
+				v.Pos = decodePos(d)
+			case 3:
+				v.Doc = d.String()
+			case 4:
+				v.Names = append(v.Names, d.String())
+			case 5:
+				v.Deprecated = d.String()
+			}
+		}
+	})
+	return v
+}
+
+func decodeNote(d *proto.Decoder) *Note {
+	n := &Note{}
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				n.Pos = decodePos(d)
+			case 2:
+				n.UID = d.String()
+			case 3:
+				n.Body = d.String()
+			}
+		}
+	})
+	return n
+}
+
+func decodeExample(d *proto.Decoder) *Example {
+	e := &Example{}
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				e.Name = d.String()
+			case 2:
+				e.Doc = d.String()
+			case 3:
+				e.Code = decodeCode(d)
+			case 4:
+				e.Play = d.String()
+			case 5:
+				e.Output = d.String()
+			case 6:
+				e.HasOutput = d.Bool()
+			case 7:
+				e.Checked = d.Bool()
+			case 8:
+				e.CheckErrors = d.String()
+			}
+		}
+	})
+	return e
+}
+
+func decodeFunc(d *proto.Decoder) *Func {
+	f := &Func{}
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				f.Decl = decodeCode(d)
+			case 2:
+				f.Pos = decodePos(d)
+			case 3:
+				f.Doc = d.String()
+			case 4:
+				f.Name = d.String()
+			case 5:
+				f.Recv = d.String()
+			case 6:
+				f.Examples = append(f.Examples, decodeExample(d))
+			case 7:
+				f.UsedBy = append(f.UsedBy, d.String())
+			case 8:
+				f.CgoExport = d.String()
+			case 9:
+				f.PromotedFrom = d.String()
+			case 10:
+				f.Deprecated = d.String()
+			}
+		}
+	})
+	return f
+}
+
+func decodeTypeRef(d *proto.Decoder) *TypeRef {
+	r := &TypeRef{}
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				r.ImportPath = d.String()
+			case 2:
+				r.Package = d.String()
+			case 3:
+				r.Name = d.String()
+			}
+		}
+	})
+	return r
+}
+
+func decodeType(d *proto.Decoder, t *Type) {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			t.Doc = d.String()
+		case 2:
+			t.Name = d.String()
+		case 3:
+			t.Decl = decodeCode(d)
+		case 4:
+			t.Pos = decodePos(d)
+		case 5:
+			t.Consts = append(t.Consts, decodeValue(d))
+		case 6:
+			t.Vars = append(t.Vars, decodeValue(d))
+		case 7:
+			t.Funcs = append(t.Funcs, decodeFunc(d))
+		case 8:
+			t.Methods = append(t.Methods, decodeFunc(d))
+		case 9:
+			t.Examples = append(t.Examples, decodeExample(d))
+		case 10:
+			t.Implements = append(t.Implements, decodeTypeRef(d))
+		case 11:
+			t.ImplementedBy = append(t.ImplementedBy, decodeTypeRef(d))
+		case 12:
+			t.Fields = append(t.Fields, d.String())
+		case 13:
+			e := &Embedded{}
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 1:
+						e.ImportPath = d.String()
+					case 2:
+						e.Package = d.String()
+					case 3:
+						e.Name = d.String()
+					case 4:
+						e.Pointer = d.Bool()
+					}
+				}
+			})
+			t.Embedded = append(t.Embedded, e)
+		case 14:
+			t.Deprecated = d.String()
+		}
+	}
+}
+
+func decodeFile(d *proto.Decoder) *File {
+	f := &File{}
+	d.Message(func(d *proto.Decoder) {
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				f.Name = d.String()
+			case 2:
+				f.URL = d.String()
+			}
+		}
+	})
+	return f
+}
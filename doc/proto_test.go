@@ -0,0 +1,108 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fill sets every field reachable from v to a distinct value that is not the
+// zero value. Packages are filled to the given depth of variants.
+func fill(v reflect.Value, n *int, depth int) {
+	*n++
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fmt.Sprintf("s%d", *n))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int16, reflect.Int32:
+		v.SetInt(int64(*n))
+	case reflect.Uint16:
+		v.SetUint(uint64(*n))
+	case reflect.Float64:
+		v.SetFloat(float64(*n) + 0.5)
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), n, depth)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(fmt.Sprintf("b%d", *n)))
+			return
+		}
+		if v.Type().Elem() == reflect.TypeOf(&Package{}) {
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), n, depth)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 0; i < 2; i++ {
+			k := reflect.New(v.Type().Key()).Elem()
+			e := reflect.New(v.Type().Elem()).Elem()
+			fill(k, n, depth)
+			fill(e, n, depth)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.Unix(int64(*n)*1000, int64(*n)).UTC()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), n, depth)
+			}
+		}
+	default:
+		panic("fill: unsupported kind " + v.Kind().String())
+	}
+}
+
+func TestProto(t *testing.T) {
+	var n int
+	var pdoc Package
+	fill(reflect.ValueOf(&pdoc).Elem(), &n, 1)
+	if len(pdoc.Variants) == 0 {
+		t.Fatal("variants not filled")
+	}
+
+	p := pdoc.MarshalProto()
+	var got Package
+	if err := got.UnmarshalProto(p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, &pdoc) {
+		t.Errorf("UnmarshalProto(MarshalProto(%+v)) = %+v", &pdoc, &got)
+	}
+	if !bytes.Equal(got.MarshalProto(), p) {
+		t.Errorf("encoding is not deterministic")
+	}
+
+	if err := got.UnmarshalProto(p[:len(p)-1]); err == nil {
+		t.Errorf("UnmarshalProto() of a truncated message returned no error")
+	}
+}
+
+func TestProtoEmpty(t *testing.T) {
+	var got Package
+	pdoc := Package{Readme: &Readme{}, Consts: []*Value{{}}}
+	if err := got.UnmarshalProto(pdoc.MarshalProto()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, &pdoc) {
+		t.Errorf("UnmarshalProto(MarshalProto(%+v)) = %+v", &pdoc, &got)
+	}
+}
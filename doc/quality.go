@@ -0,0 +1,125 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"bytes"
+	"go/format"
+)
+
+// Quality is the report card of a package computed from the signals found
+// when the package is built.
+type Quality struct {
+	// HasTests is true if a test file of the package declares a test
+	// function.
+	HasTests bool
+
+	// HasExamples is true if the package has examples.
+	HasExamples bool
+
+	// HasReadme is true if the directory of the package has a README file.
+	HasReadme bool
+
+	// Exported is the number of exported declarations and the package
+	// clause, and Documented is the number of these with doc comments.
+	Exported   int
+	Documented int
+
+	// Unformatted are the names of the Go files of the package that are not
+	// formatted with gofmt.
+	Unformatted []string
+
+	// Score is the quality score of the package from 0 to 100.
+	Score int
+}
+
+// Weights of the signals in the quality score. The weights add up to 100.
+const (
+	testsWeight    = 25
+	examplesWeight = 15
+	readmeWeight   = 15
+	docWeight      = 30
+	gofmtWeight    = 15
+)
+
+// DocCoverage returns the percentage of the exported declarations with doc
+// comments.
+func (q *Quality) DocCoverage() int {
+	return (&Coverage{Exported: q.Exported, Documented: q.Documented}).Percent()
+}
+
+func (q *Quality) score() int {
+	score := q.DocCoverage() * docWeight / 100
+	if q.HasTests {
+		score += testsWeight
+	}
+	if q.HasExamples {
+		score += examplesWeight
+	}
+	if q.HasReadme {
+		score += readmeWeight
+	}
+	if len(q.Unformatted) == 0 {
+		score += gofmtWeight
+	}
+	return score
+}
+
+// quality returns the report card of the package built from the Go files
+// and test files of the package.
+func (b *builder) quality(pkg *Package) *Quality {
+	c := pkg.Coverage()
+	q := &Quality{
+		HasExamples: hasExamples(pkg),
+		HasReadme:   pkg.Readme != nil,
+		Exported:    c.Exported,
+		Documented:  c.Documented,
+	}
+
+	for _, f := range pkg.Files {
+		raw := b.srcs[f.Name].raw
+		if p, err := format.Source(raw); err == nil && !bytes.Equal(p, raw) {
+			q.Unformatted = append(q.Unformatted, f.Name)
+		}
+	}
+	for _, f := range pkg.TestFiles {
+		if bytes.Contains(b.srcs[f.Name].raw, []byte("\nfunc Test")) {
+			q.HasTests = true
+			break
+		}
+	}
+
+	q.Score = q.score()
+	return q
+}
+
+func hasExamples(pkg *Package) bool {
+	if len(pkg.Examples) > 0 {
+		return true
+	}
+	for _, f := range pkg.Funcs {
+		if len(f.Examples) > 0 {
+			return true
+		}
+	}
+	for _, t := range pkg.Types {
+		if len(t.Examples) > 0 {
+			return true
+		}
+		for _, f := range t.Funcs {
+			if len(f.Examples) > 0 {
+				return true
+			}
+		}
+		for _, f := range t.Methods {
+			if len(f.Examples) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
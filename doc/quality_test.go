@@ -0,0 +1,48 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuality(t *testing.T) {
+	b := &builder{srcs: map[string]*source{
+		"a.go":      {raw: []byte("package a\n\nfunc F() {}\n")},
+		"b.go":      {raw: []byte("package a\nfunc  G() {}\n")},
+		"a_test.go": {raw: []byte("package a\n\nimport \"testing\"\n\nfunc TestF(t *testing.T) {}\n")},
+	}}
+	pkg := &Package{
+		Doc:       "Package a does things.",
+		Funcs:     []*Func{{Name: "F", Doc: "F does things."}, {Name: "G"}},
+		Types:     []*Type{{Name: "T", Methods: []*Func{{Name: "M", Examples: []*Example{{}}}, {Name: "P", PromotedFrom: "U"}}}},
+		Files:     []*File{{Name: "a.go"}, {Name: "b.go"}},
+		TestFiles: []*File{{Name: "a_test.go"}},
+	}
+	q := b.quality(pkg)
+	want := &Quality{
+		HasTests:    true,
+		HasExamples: true,
+		Exported:    5,
+		Documented:  2,
+		Unformatted: []string{"b.go"},
+		Score:       testsWeight + examplesWeight + docWeight*40/100,
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("quality() = %+v, want %+v", q, want)
+	}
+
+	pkg.Readme = &Readme{Name: "README.md"}
+	pkg.Funcs[1].Doc = "G does things."
+	pkg.Types[0].Doc = "T is a thing."
+	pkg.Types[0].Methods[0].Doc = "M does things."
+	b.srcs["b.go"].raw = []byte("package a\n\nfunc G() {}\n")
+	if q := b.quality(pkg); q.Score != 100 || q.DocCoverage() != 100 {
+		t.Errorf("quality() = %+v, want score 100", q)
+	}
+}
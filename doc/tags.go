@@ -0,0 +1,72 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"sort"
+	"strings"
+)
+
+var buildTags []string
+
+// SetBuildTags sets the build tags satisfied in addition to the default
+// build tags when building package documents.
+func SetBuildTags(tags []string) {
+	buildTags = nil
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			buildTags = append(buildTags, tag)
+		}
+	}
+}
+
+const cgoExportPrefix = "//export "
+
+// cgoExports returns the functions in apkg exported to C with a cgo
+// //export comment. Unlike the functions returned by go/doc, the functions
+// are not required to be exported from the Go package.
+func (b *builder) cgoExports(apkg *ast.Package) []*Func {
+	var names []string
+	for name := range apkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []*Func
+	for _, name := range names {
+		for _, decl := range apkg.Files[name].Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil || fd.Doc == nil {
+				continue
+			}
+			export := ""
+			var comments []*ast.Comment
+			for _, c := range fd.Doc.List {
+				if strings.HasPrefix(c.Text, cgoExportPrefix) {
+					export = strings.TrimSpace(c.Text[len(cgoExportPrefix):])
+				} else {
+					comments = append(comments, c)
+				}
+			}
+			if export == "" {
+				continue
+			}
+			d := *fd
+			d.Doc = nil
+			d.Body = nil
+			result = append(result, &Func{
+				Decl:      b.printDecl(&d),
+				Pos:       b.position(fd),
+				Doc:       (&ast.CommentGroup{List: comments}).Text(),
+				Name:      fd.Name.Name,
+				CgoExport: export,
+			})
+		}
+	}
+	return result
+}
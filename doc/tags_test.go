@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestSetBuildTags(t *testing.T) {
+	defer SetBuildTags(nil)
+	SetBuildTags([]string{"integration", " tools ", ""})
+	if want := []string{"integration", "tools"}; !reflect.DeepEqual(buildTags, want) {
+		t.Errorf("buildTags = %v, want %v", buildTags, want)
+	}
+	ctxt := buildContext(goEnv{"linux", "amd64"})
+	if n := len(ctxt.BuildTags); n < 2 || ctxt.BuildTags[n-2] != "integration" || ctxt.BuildTags[n-1] != "tools" {
+		t.Errorf("build context tags = %v, want tags ending with integration, tools", ctxt.BuildTags)
+	}
+}
+
+const cgoExportSrc = `package p
+
+import "C"
+
+// Add adds two numbers.
+//export goAdd
+func add(a, b C.int) C.int { return a + b }
+
+// Sub is not exported to C.
+func Sub(a, b int) int { return a - b }
+`
+
+func TestCgoExports(t *testing.T) {
+	b := builder{fset: token.NewFileSet(), srcs: map[string]*source{}}
+	file, err := parser.ParseFile(b.fset, "p.go", cgoExportSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apkg := &ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": file}}
+	exports := b.cgoExports(apkg)
+	if len(exports) != 1 {
+		t.Fatalf("got %d exports, want 1", len(exports))
+	}
+	f := exports[0]
+	if f.Name != "add" || f.CgoExport != "goAdd" || f.Doc != "Add adds two numbers.\n" {
+		t.Errorf("got export %q as %q with doc %q", f.Name, f.CgoExport, f.Doc)
+	}
+	if want := "func add(a, b C.int) C.int"; f.Decl.Text != want {
+		t.Errorf("decl = %q, want %q", f.Decl.Text, want)
+	}
+}
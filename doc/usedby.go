@@ -0,0 +1,109 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"sort"
+)
+
+// funcAnchor returns the anchor used on the package page for a function or
+// method declaration, or "" if the function or its receiver type is not
+// exported.
+func funcAnchor(decl *ast.FuncDecl) string {
+	if !decl.Name.IsExported() {
+		return ""
+	}
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	t := decl.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	ident, ok := t.(*ast.Ident)
+	if !ok || !ident.IsExported() {
+		return ""
+	}
+	return ident.Name + "." + decl.Name.Name
+}
+
+// usedBy returns a map from the anchor of each exported function and method
+// in apkg to the sorted anchors of the exported functions and methods that
+// reference it. References to functions are found with the identifier
+// resolution done by the parser. Without type information, a selector is
+// taken as a reference to a method only if a single exported type in the
+// package declares a method with the selected name.
+func usedBy(apkg *ast.Package) map[string][]string {
+	funcs := make(map[*ast.Object]string)
+	methods := make(map[string][]string)
+	var decls []*ast.FuncDecl
+	for _, file := range apkg.Files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			anchor := funcAnchor(fd)
+			if anchor == "" {
+				continue
+			}
+			if fd.Recv == nil {
+				if fd.Name.Obj != nil {
+					funcs[fd.Name.Obj] = anchor
+				}
+			} else {
+				methods[fd.Name.Name] = append(methods[fd.Name.Name], anchor)
+			}
+			decls = append(decls, fd)
+		}
+	}
+
+	users := make(map[string]map[string]bool)
+	for _, fd := range decls {
+		if fd.Body == nil {
+			continue
+		}
+		user := funcAnchor(fd)
+		add := func(anchor string) {
+			if anchor == "" || anchor == user {
+				return
+			}
+			if users[anchor] == nil {
+				users[anchor] = make(map[string]bool)
+			}
+			users[anchor][user] = true
+		}
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				if n.Obj != nil {
+					add(funcs[n.Obj])
+				}
+			case *ast.SelectorExpr:
+				// Skip package qualified identifiers. Package names are
+				// not resolved by the parser.
+				if x, ok := n.X.(*ast.Ident); ok && (x.Obj == nil || x.Obj.Kind == ast.Pkg) {
+					return false
+				}
+				if anchors := methods[n.Sel.Name]; len(anchors) == 1 {
+					add(anchors[0])
+				}
+			}
+			return true
+		})
+	}
+
+	result := make(map[string][]string)
+	for anchor, m := range users {
+		for user := range m {
+			result[anchor] = append(result[anchor], user)
+		}
+		sort.Strings(result[anchor])
+	}
+	return result
+}
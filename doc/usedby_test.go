@@ -0,0 +1,67 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package doc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+var usedBySrcs = map[string]string{
+	"a.go": `package p
+
+import "fmt"
+
+func New() *T { return &T{} }
+
+func Must() *T {
+	t := New()
+	t.Start()
+	fmt.Println(t)
+	return t
+}
+
+func helper() { New() }
+
+type T struct{}
+
+func (t *T) Start() { t.Stop() }
+func (t *T) Stop()  { t.Stop() }
+`,
+	"b.go": `package p
+
+func Run() {
+	Must().Start()
+}
+`,
+}
+
+func TestUsedBy(t *testing.T) {
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	for name, src := range usedBySrcs {
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = file
+	}
+	apkg, _ := ast.NewPackage(fset, files, simpleImporter, nil)
+	got := usedBy(apkg)
+	want := map[string][]string{
+		"New":     {"Must"},
+		"Must":    {"Run"},
+		"T.Start": {"Must", "Run"},
+		"T.Stop":  {"T.Start"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("usedBy = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	announceCommand = &command{
+		name:  "announce",
+		usage: "announce [-severity info|warning|critical] [-start time] [-end time] message",
+	}
+	announceSeverity = announceCommand.flag.String("severity", database.SeverityInfo, "Severity of the announcement: info, warning or critical.")
+	announceStart    = announceCommand.flag.String("start", "", "Show the announcement from this RFC 3339 time. Empty shows the announcement now.")
+	announceEnd      = announceCommand.flag.String("end", "", "Stop showing the announcement at this RFC 3339 time. Empty shows the announcement until it is removed.")
+
+	unannounceCommand = &command{
+		name:  "unannounce",
+		run:   unannounce,
+		usage: "unannounce id",
+	}
+
+	announcementsCommand = &command{
+		name:  "announcements",
+		run:   listAnnouncements,
+		usage: "announcements",
+	}
+)
+
+func init() {
+	announceCommand.run = announce
+}
+
+// parseTimeFlag parses the RFC 3339 time of the flag value. An empty value
+// is the zero time.
+func parseTimeFlag(name, v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		log.Fatalf("-%s: %v", name, err)
+	}
+	return t
+}
+
+func announce(c *command) {
+	if len(c.flag.Args()) == 0 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	message := strings.Join(c.flag.Args(), " ")
+	start := parseTimeFlag("start", *announceStart)
+	end := parseTimeFlag("end", *announceEnd)
+	if isRemote() {
+		var data struct {
+			Results []database.Announcement `json:"results"`
+		}
+		remote("POST", "/-/admin/announcements", url.Values{"message": {message}, "severity": {*announceSeverity}, "start": {*announceStart}, "end": {*announceEnd}}, &data)
+		if n := len(data.Results); n > 0 {
+			fmt.Println(data.Results[n-1].ID)
+		}
+		return
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	id, err := db.AddAnnouncement(database.Announcement{Message: message, Severity: *announceSeverity, Start: start, End: end})
+	if err != nil {
+		log.Fatal(err)
+	}
+	audit(db, "announce", id)
+	fmt.Println(id)
+}
+
+func unannounce(c *command) {
+	if len(c.flag.Args()) != 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	id := c.flag.Args()[0]
+	if isRemote() {
+		remote("DELETE", "/-/admin/announcements", url.Values{"id": {id}}, nil)
+		return
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ok, err := db.RemoveAnnouncement(id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		log.Fatalf("no announcement with id %s", id)
+	}
+	audit(db, "unannounce", id)
+}
+
+func listAnnouncements(c *command) {
+	if len(c.flag.Args()) != 0 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	var all []database.Announcement
+	if isRemote() {
+		var data struct {
+			Results []database.Announcement `json:"results"`
+		}
+		remote("GET", "/-/admin/announcements", url.Values{}, &data)
+		all = data.Results
+	} else {
+		db, err := database.New()
+		if err != nil {
+			log.Fatal(err)
+		}
+		all, err = db.Announcements()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	}
+	now := time.Now()
+	for _, a := range all {
+		status := "inactive"
+		if a.Active(now) {
+			status = "active"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Severity, status, formatTime(a.Start), formatTime(a.End), a.Message)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	createKeyCommand = &command{
+		name:  "create-key",
+		usage: "create-key [-quota n] name",
+	}
+	createKeyQuota = createKeyCommand.flag.Int("quota", 1000, "Requests per day allowed with the key. Zero is unlimited.")
+
+	keyUsageCommand = &command{
+		name:  "key-usage",
+		usage: "key-usage [-days n] key",
+	}
+	keyUsageDays = keyUsageCommand.flag.Int("days", 30, "Number of days of usage to print.")
+)
+
+func init() {
+	createKeyCommand.run = createKey
+	keyUsageCommand.run = keyUsage
+}
+
+func createKey(c *command) {
+	if len(c.flag.Args()) != 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	key, err := db.CreateAPIKey(c.flag.Args()[0], *createKeyQuota)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(key)
+}
+
+func keyUsage(c *command) {
+	if len(c.flag.Args()) != 1 || *keyUsageDays < 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	key := c.flag.Args()[0]
+	k, err := db.APIKey(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if k == nil {
+		log.Fatal("key not found")
+	}
+	usage, err := db.APIKeyUsage(key, *keyUsageDays)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s, quota %d, created %s\n", k.Name, k.Quota, k.Created.Format("2006-01-02"))
+	now := time.Now().UTC()
+	for i, n := range usage {
+		day := now.AddDate(0, 0, i-len(usage)+1)
+		fmt.Printf("%s %d\n", day.Format("2006-01-02"), n)
+	}
+}
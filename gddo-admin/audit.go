@@ -0,0 +1,84 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	auditReason = flag.String("reason", "", "Reason recorded in the audit log for the action of the command.")
+	auditActor  = flag.String("actor", os.Getenv("GDDO_ADMIN_ACTOR"), "Operator recorded in the audit log for the action of the command. The default is the name of the current user.")
+
+	auditCommand = &command{
+		name:  "audit",
+		usage: "audit [-n count] [-action action] [prefix]",
+	}
+	auditCount  = auditCommand.flag.Int("n", 50, "Number of entries to print.")
+	auditAction = auditCommand.flag.String("action", "", "Print only the entries with the action.")
+)
+
+func init() {
+	auditCommand.run = printAudit
+}
+
+// actor returns the operator recorded in the audit log.
+func actor() string {
+	if *auditActor != "" {
+		return *auditActor
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// audit records the action on the target in the audit log of the database.
+func audit(db *database.Database, action, target string) {
+	err := db.AddAuditEntry(database.AuditEntry{
+		Actor:  actor(),
+		Action: action,
+		Target: target,
+		Reason: *auditReason,
+	})
+	if err != nil {
+		log.Fatalf("Error recording %s of %s in the audit log: %v", action, target, err)
+	}
+}
+
+func printAudit(c *command) {
+	if len(c.flag.Args()) > 1 || *auditCount < 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	prefix := ""
+	if len(c.flag.Args()) == 1 {
+		prefix = strings.Trim(c.flag.Args()[0], "/")
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := db.AuditLog(*auditCount, func(e *database.AuditEntry) bool {
+		return (*auditAction == "" || e.Action == *auditAction) &&
+			(prefix == "" || e.Target == prefix || strings.HasPrefix(e.Target, prefix+"/"))
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range entries {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.Actor, e.Action, e.Target, e.Reason)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	backupCommand = &command{
+		name:  "backup",
+		usage: "backup [-bgsave] file",
+	}
+	backupBGSave = backupCommand.flag.Bool("bgsave", false, "Also save a snapshot of the Redis server to its disk with BGSAVE and wait for the save to complete. The BGSAVE snapshot is the only consistent copy; the writes other than the crawls continue while the backup file is written.")
+)
+
+func init() {
+	backupCommand.run = backup
+}
+
+// backup writes a copy of the keys of the database to a file. The crawls are
+// paused while the keys are read, the other writes are not, so the file is
+// not a point-in-time snapshot; -bgsave also saves the consistent snapshot of
+// Redis. The copy is restored with restore -backup.
+func backup(c *command) {
+	if len(c.flag.Args()) != 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *backupBGSave {
+		t, err := db.BackgroundSave(time.Hour)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("bgsave\t%s\n", t.Format(time.RFC3339))
+	}
+
+	name := c.flag.Args()[0]
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h, err := db.Backup(f)
+	if err != nil {
+		f.Close()
+		os.Remove(name)
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("backup\t%s\t%d keys\tschema %d\n", h.Time.Format(time.RFC3339), h.Keys, h.SchemaVersion)
+	audit(db, "backup", name)
+}
+
+// restoreBackup restores the snapshot in the file written by backup. The
+// server and the crawler should be stopped during the restore; the restored
+// data replaces the data written by the processes.
+func restoreBackup(name string, replace bool) {
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	h, err := db.RestoreBackup(f, replace)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("restored\t%s\t%d keys\tschema %d\n", h.Time.Format(time.RFC3339), h.Keys, h.SchemaVersion)
+	if h.SchemaVersion < database.SchemaVersion() {
+		fmt.Printf("migrated\tschema %d\n", database.SchemaVersion())
+	}
+	audit(db, "restore-backup", name)
+}
@@ -7,15 +7,37 @@
 package main
 
 import (
-	"github.com/golang/gddo/database"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"time"
+
+	"github.com/golang/gddo/database"
 )
 
-var blockCommand = &command{
-	name:  "block",
-	run:   block,
-	usage: "block path",
+var (
+	blockCommand = &command{
+		name:  "block",
+		usage: "block [-legal] pattern",
+	}
+	blockLegal = blockCommand.flag.Bool("legal", false, "Block for legal reasons. The pages of the blocked packages respond with status 451 instead of 410.")
+
+	unblockCommand = &command{
+		name:  "unblock",
+		run:   unblock,
+		usage: "unblock pattern",
+	}
+
+	blocklistCommand = &command{
+		name:  "blocklist",
+		run:   blocklist,
+		usage: "blocklist",
+	}
+)
+
+func init() {
+	blockCommand.run = block
 }
 
 func block(c *command) {
@@ -23,11 +45,74 @@ func block(c *command) {
 		c.printUsage()
 		os.Exit(1)
 	}
+	kind := database.BlockRemoved
+	if *blockLegal {
+		kind = database.BlockLegal
+	}
+	if isRemote() {
+		remote("POST", "/-/admin/blocklist", url.Values{"pattern": {c.flag.Args()[0]}, "kind": {kind}}, nil)
+		return
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.AddBlock(database.BlockEntry{Pattern: c.flag.Args()[0], Kind: kind, Reason: *auditReason}); err != nil {
+		log.Fatal(err)
+	}
+	audit(db, "block", c.flag.Args()[0])
+}
+
+func unblock(c *command) {
+	if len(c.flag.Args()) != 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	if isRemote() {
+		remote("DELETE", "/-/admin/blocklist", url.Values{"pattern": {c.flag.Args()[0]}}, nil)
+		return
+	}
 	db, err := database.New()
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := db.Block(c.flag.Args()[0]); err != nil {
+	ok, err := db.RemoveBlock(c.flag.Args()[0])
+	if err != nil {
 		log.Fatal(err)
 	}
+	if !ok {
+		log.Fatalf("%s is not in the blocklist", c.flag.Args()[0])
+	}
+	audit(db, "unblock", c.flag.Args()[0])
+}
+
+func blocklist(c *command) {
+	if len(c.flag.Args()) != 0 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	var entries []database.BlockEntry
+	if isRemote() {
+		var data struct {
+			Results []database.BlockEntry `json:"results"`
+		}
+		remote("GET", "/-/admin/blocklist", url.Values{}, &data)
+		entries = data.Results
+	} else {
+		db, err := database.New()
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries, err = db.BlockEntries()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, e := range entries {
+		t := ""
+		if !e.Time.IsZero() {
+			t = e.Time.Format(time.RFC3339)
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", e.Pattern, e.Kind, t, e.Reason)
+	}
 }
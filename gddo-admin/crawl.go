@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"strings"
 
@@ -28,6 +29,10 @@ func crawl(c *command) {
 		c.printUsage()
 		os.Exit(1)
 	}
+	if isRemote() {
+		remoteCrawl(c)
+		return
+	}
 	db, err := database.New()
 	if err != nil {
 		log.Fatal(err)
@@ -45,7 +50,7 @@ func crawl(c *command) {
 
 	conn := db.Pool.Get()
 	defer conn.Close()
-	paths, err := redis.Strings(conn.Do("SMEMBERS", "newCrawl"))
+	paths, err := redis.Strings(conn.Do("SMEMBERS", database.Key("newCrawl")))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -54,7 +59,7 @@ func crawl(c *command) {
 		fmt.Println(path)
 	}
 
-	paths, err = redis.Strings(conn.Do("SMEMBERS", "badCrawl"))
+	paths, err = redis.Strings(conn.Do("SMEMBERS", database.Key("badCrawl")))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -63,3 +68,18 @@ func crawl(c *command) {
 		fmt.Println(path)
 	}
 }
+
+// remoteCrawl queues the packages in the file for a crawl with the API of
+// -server. The crawl queues are read from the database only.
+func remoteCrawl(c *command) {
+	if len(c.flag.Args()) != 1 {
+		log.Fatal("crawl with -server requires a file with the import paths")
+	}
+	p, err := ioutil.ReadFile(c.flag.Args()[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range strings.Fields(string(p)) {
+		remote("POST", "/-/admin/crawl", url.Values{"path": {p}}, nil)
+	}
+}
@@ -8,6 +8,7 @@ package main
 
 import (
 	"log"
+	"net/url"
 	"os"
 
 	"github.com/golang/gddo/database"
@@ -24,6 +25,10 @@ func del(c *command) {
 		c.printUsage()
 		os.Exit(1)
 	}
+	if isRemote() {
+		remote("POST", "/-/admin/delete", url.Values{"path": {c.flag.Args()[0]}}, nil)
+		return
+	}
 	db, err := database.New()
 	if err != nil {
 		log.Fatal(err)
@@ -31,4 +36,5 @@ func del(c *command) {
 	if err := db.Delete(c.flag.Args()[0]); err != nil {
 		log.Fatal(err)
 	}
+	audit(db, "delete", c.flag.Args()[0])
 }
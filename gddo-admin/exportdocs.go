@@ -0,0 +1,80 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	exportDocsCommand = &command{
+		name:  "export-docs",
+		usage: "export-docs [-server url] [-token token | -key key] [-o file] prefix",
+	}
+	exportDocsServer = exportDocsCommand.flag.String("server", "https://godoc.org", "Base URL of the documentation server.")
+	exportDocsToken  = exportDocsCommand.flag.String("token", os.Getenv("GDDO_API_TOKEN"), "Token in the -api_tokens file of the server. The archive has every package under the prefix.")
+	exportDocsKey    = exportDocsCommand.flag.String("key", "", "Owner key. The archive has the packages in the projects with the key.")
+	exportDocsOutput = exportDocsCommand.flag.String("o", "", "Output file. The default is the prefix with slashes replaced by underscores and the .zip extension.")
+)
+
+func init() {
+	exportDocsCommand.run = exportDocs
+}
+
+func exportDocs(c *command) {
+	if len(c.flag.Args()) != 1 || (*exportDocsToken == "" && *exportDocsKey == "") {
+		c.printUsage()
+		os.Exit(1)
+	}
+	prefix := strings.Trim(c.flag.Args()[0], "/")
+	output := *exportDocsOutput
+	if output == "" {
+		output = strings.Replace(prefix, "/", "_", -1) + ".zip"
+	}
+
+	form := url.Values{"prefix": {prefix}}
+	if *exportDocsKey != "" {
+		form.Set("key", *exportDocsKey)
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(*exportDocsServer, "/")+"/-/archive", strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if *exportDocsToken != "" {
+		req.Header.Set("Authorization", "Bearer "+*exportDocsToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		p, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Fatalf("%s: %s", resp.Status, strings.TrimSpace(string(p)))
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, err := io.Copy(f, resp.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %d bytes to %s\n", n, output)
+}
@@ -0,0 +1,138 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	indexTermsCommand = &command{
+		name:  "index-terms",
+		usage: "index-terms path",
+	}
+
+	indexMissingCommand = &command{
+		name:  "index-missing",
+		usage: "index-missing",
+	}
+
+	indexRepairCommand = &command{
+		name:  "index-repair",
+		usage: "index-repair [-n]",
+	}
+	indexRepairDryRun = indexRepairCommand.flag.Bool("n", false, "Print the inconsistencies without repairing them.")
+)
+
+func init() {
+	indexTermsCommand.run = indexTerms
+	indexMissingCommand.run = indexMissing
+	indexRepairCommand.run = indexRepair
+}
+
+// indexTerms prints the search and importer index terms of a package. Terms
+// whose index does not have the package are marked "missing" and terms
+// stored with the package that the document does not have are marked
+// "stale".
+func indexTerms(c *command) {
+	if len(c.flag.Args()) != 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ic, err := db.CheckIndex(c.flag.Args()[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ic == nil {
+		log.Fatalf("%s not in database", c.flag.Args()[0])
+	}
+	missing := make(map[string]bool)
+	for _, term := range ic.Missing {
+		missing[term] = true
+	}
+	for _, term := range ic.Terms {
+		if missing[term] {
+			fmt.Printf("%s missing\n", term)
+		} else {
+			fmt.Println(term)
+		}
+	}
+	for _, term := range ic.Stale {
+		fmt.Printf("%s stale\n", term)
+	}
+}
+
+// indexMissing prints the packages that are missing from the index of one or
+// more of the terms of the document.
+func indexMissing(c *command) {
+	if len(c.flag.Args()) != 0 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.CheckIndexes(func(ic *database.IndexCheck) error {
+		if len(ic.Missing) > 0 {
+			fmt.Printf("%s %d\n", ic.Path, len(ic.Missing))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// indexRepair makes the search and importer indexes consistent with the
+// stored documents.
+func indexRepair(c *command) {
+	if len(c.flag.Args()) != 0 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var n, bad int
+	err = db.CheckIndexes(func(ic *database.IndexCheck) error {
+		n++
+		if ic.OK() {
+			return nil
+		}
+		bad++
+		if len(ic.Missing) > 0 {
+			fmt.Printf("%s missing %s\n", ic.Path, strings.Join(ic.Missing, " "))
+		}
+		if len(ic.Stale) > 0 {
+			fmt.Printf("%s stale %s\n", ic.Path, strings.Join(ic.Stale, " "))
+		}
+		if *indexRepairDryRun {
+			return nil
+		}
+		return db.RepairIndex(ic)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *indexRepairDryRun {
+		log.Printf("Found %d of %d packages with inconsistent indexes", bad, n)
+		return
+	}
+	audit(db, "index-repair", "")
+	log.Printf("Repaired %d of %d packages", bad, n)
+}
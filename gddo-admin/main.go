@@ -28,12 +28,32 @@ func (c *command) printUsage() {
 
 var commands = []*command{
 	blockCommand,
+	unblockCommand,
+	blocklistCommand,
 	reindexCommand,
 	deleteCommand,
 	popularCommand,
 	dangleCommand,
 	crawlCommand,
+	seedCommand,
 	statsCommand,
+	quarantineCommand,
+	restoreCommand,
+	createKeyCommand,
+	keyUsageCommand,
+	exportDocsCommand,
+	recrawlCommand,
+	indexTermsCommand,
+	indexMissingCommand,
+	indexRepairCommand,
+	auditCommand,
+	reportsCommand,
+	tasksCommand,
+	migrateCommand,
+	backupCommand,
+	announceCommand,
+	unannounceCommand,
+	announcementsCommand,
 }
 
 func printUsage() {
@@ -0,0 +1,53 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var migrateCommand = &command{
+	name:  "migrate",
+	usage: "migrate [-n]",
+}
+
+var migrateDryRun = migrateCommand.flag.Bool("n", false, "Print the schema versions without running the migrations.")
+
+func init() {
+	migrateCommand.run = migrate
+}
+
+// migrate runs the pending migrations of the database schema, as the server
+// and the crawler do at startup.
+func migrate(c *command) {
+	if len(c.flag.Args()) != 0 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	v, err := db.StoredSchemaVersion()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("stored\t%d\ncurrent\t%d\n", v, database.SchemaVersion())
+	if *migrateDryRun || v >= database.SchemaVersion() {
+		return
+	}
+	host, _ := os.Hostname()
+	if err := db.Migrate(fmt.Sprintf("gddo-admin-%s-%d", host, os.Getpid()), 10*time.Minute); err != nil {
+		log.Fatal(err)
+	}
+	audit(db, "migrate", fmt.Sprint(database.SchemaVersion()))
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/golang/gddo/database"
+)
+
+var quarantineCommand = &command{
+	name:  "quarantine",
+	run:   quarantine,
+	usage: "quarantine [add|release root]",
+}
+
+func quarantine(c *command) {
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case len(c.flag.Args()) == 2 && c.flag.Args()[0] == "add":
+		reason := *auditReason
+		if reason == "" {
+			reason = "quarantined by operator"
+		}
+		if err := db.Quarantine(c.flag.Args()[1], reason); err != nil {
+			log.Fatal(err)
+		}
+		audit(db, "quarantine", c.flag.Args()[1])
+	case len(c.flag.Args()) == 2 && c.flag.Args()[0] == "release":
+		if err := db.ReleaseQuarantine(c.flag.Args()[1]); err != nil {
+			log.Fatal(err)
+		}
+		audit(db, "release", c.flag.Args()[1])
+	case len(c.flag.Args()) == 0:
+		roots, err := db.Quarantined()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var keys []string
+		for root := range roots {
+			keys = append(keys, root)
+		}
+		sort.Strings(keys)
+		for _, root := range keys {
+			fmt.Printf("%s\t%s\n", root, roots[root])
+		}
+	default:
+		c.printUsage()
+		os.Exit(1)
+	}
+}
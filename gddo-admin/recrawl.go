@@ -0,0 +1,85 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	recrawlCommand = &command{
+		name:  "recrawl",
+		usage: "recrawl [-c n] prefix",
+	}
+	recrawlConcurrency = recrawlCommand.flag.Int("c", 4, "Number of packages to update in the database at the same time.")
+)
+
+func init() {
+	recrawlCommand.run = recrawl
+}
+
+// recrawl schedules the packages under the prefix for a crawl before the
+// other packages. The next crawl time of the packages is set to the Unix
+// epoch because the crawler crawls the package with the earliest time first.
+func recrawl(c *command) {
+	if len(c.flag.Args()) != 1 || *recrawlConcurrency < 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	prefix := strings.Trim(c.flag.Args()[0], "/")
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pkgs, err := db.AllPackages()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var paths []string
+	for _, pkg := range pkgs {
+		if pkg.Path == prefix || strings.HasPrefix(pkg.Path, prefix+"/") {
+			paths = append(paths, pkg.Path)
+		}
+	}
+	log.Printf("Scheduling %d packages under %s", len(paths), prefix)
+
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	var done, failed int64
+	for i := 0; i < *recrawlConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range ch {
+				if _, err := db.SetNextCrawl(p, time.Unix(0, 0)); err != nil {
+					log.Printf("%s: %v", p, err)
+					atomic.AddInt64(&failed, 1)
+				}
+				if n := atomic.AddInt64(&done, 1); n%100 == 0 {
+					log.Printf("Scheduled %d of %d packages", n, len(paths))
+				}
+			}
+		}()
+	}
+	for _, p := range paths {
+		ch <- p
+	}
+	close(ch)
+	wg.Wait()
+	log.Printf("Scheduled %d packages, %d errors", done-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -15,10 +15,16 @@ import (
 	"github.com/golang/gddo/doc"
 )
 
-var reindexCommand = &command{
-	name:  "reindex",
-	run:   reindex,
-	usage: "reindex",
+var (
+	reindexCommand = &command{
+		name:  "reindex",
+		usage: "reindex [-search]",
+	}
+	reindexSearch = reindexCommand.flag.Bool("search", false, "Only rebuild the search index selected by -db-search-index. The index is rebuilt in a copy while the live index serves queries.")
+)
+
+func init() {
+	reindexCommand.run = reindex
 }
 
 func fix(pdoc *doc.Package) {
@@ -55,6 +61,17 @@ func reindex(c *command) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *reindexSearch {
+		err := db.RebuildSearchIndex(func(n, total int) {
+			log.Printf("Indexed %d of %d packages", n, total)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		audit(db, "reindex-search", "")
+		log.Print("Replaced the search index")
+		return
+	}
 	var n int
 	err = db.Do(func(pi *database.PackageInfo) error {
 		n += 1
@@ -64,5 +81,6 @@ func reindex(c *command) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	audit(db, "reindex", "")
 	log.Printf("Updated %d documents", n)
 }
@@ -0,0 +1,93 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// With the -server flag, the block, unblock, blocklist, delete, reports,
+// crawl, seed, tasks and announcement commands use the administrative HTTP
+// API of the server instead of the database. The server checks the role of
+// the -token, viewer, operator or admin, for each action and records the
+// name of the token in the audit log.
+
+var (
+	remoteServer = flag.String("server", os.Getenv("GDDO_SERVER"), "Base URL of the documentation server, such as https://godoc.org. If set, the administrative commands use the API of the server with -token instead of the database.")
+	remoteToken  = flag.String("token", os.Getenv("GDDO_API_TOKEN"), "Token in the -api_tokens file of the server for the -server requests.")
+)
+
+// remoteClient does not follow redirects: the administrative endpoints
+// redirect browsers after a change.
+var remoteClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// isRemote returns true if the commands use the API of -server.
+func isRemote() bool {
+	return *remoteServer != ""
+}
+
+// remote sends the request with the form to the administrative API of
+// -server and decodes the JSON response into v if v is not nil. remote exits
+// on errors.
+func remote(method, path string, form url.Values, v interface{}) {
+	if err := remoteRequest(method, path, form, v); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// remoteRequest is remote returning the errors.
+func remoteRequest(method, path string, form url.Values, v interface{}) error {
+	if *auditReason != "" {
+		form.Set("reason", *auditReason)
+	}
+	u := strings.TrimSuffix(*remoteServer, "/") + path
+	var body io.Reader
+	if method == "GET" || method == "DELETE" {
+		u += "?" + form.Encode()
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if *remoteToken != "" {
+		req.Header.Set("Authorization", "Bearer "+*remoteToken)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusFound:
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		p, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(p)))
+	}
+	if v != nil {
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+	return nil
+}
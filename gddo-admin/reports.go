@@ -0,0 +1,89 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var reportsCommand = &command{
+	name:  "reports",
+	usage: "reports [block|dismiss path]",
+}
+
+func init() {
+	reportsCommand.run = reports
+}
+
+// reports prints the moderation queue or blocks or dismisses the reports of
+// a package.
+func reports(c *command) {
+	args := c.flag.Args()
+	if len(args) != 0 && (len(args) != 2 || (args[0] != "block" && args[0] != "dismiss")) {
+		c.printUsage()
+		os.Exit(1)
+	}
+	if isRemote() {
+		remoteReports(args)
+		return
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) == 0 {
+		reports, err := db.AbuseReports()
+		if err != nil {
+			log.Fatal(err)
+		}
+		printReports(reports)
+		return
+	}
+
+	action, path := args[0], args[1]
+	if action == "block" {
+		if err := db.Block(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	n, err := db.RemoveAbuseReports(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	audit(db, action, path)
+	fmt.Printf("Removed %d reports of %s.\n", n, path)
+}
+
+// remoteReports prints the moderation queue or blocks or dismisses the
+// reports of a package with the API of -server.
+func remoteReports(args []string) {
+	if len(args) == 0 {
+		var data struct {
+			Results []database.AbuseReport `json:"results"`
+		}
+		remote("GET", "/-/admin/reports", url.Values{"format": {"json"}}, &data)
+		printReports(data.Results)
+		return
+	}
+	remote("POST", "/-/admin/reports", url.Values{"action": {args[0]}, "path": {args[1]}}, nil)
+	fmt.Printf("Removed the reports of %s.\n", args[1])
+}
+
+func printReports(reports []database.AbuseReport) {
+	for _, r := range reports {
+		fmt.Printf("%s\t%s\t%d\t%s\t%s\n", r.Path, r.Reason, r.Count, r.First.Format(time.RFC3339), r.Last.Format(time.RFC3339))
+		for _, m := range r.Messages {
+			fmt.Printf("\t%s\t%q\t%s\n", m.Time.Format(time.RFC3339), m.Message, m.Contact)
+		}
+	}
+}
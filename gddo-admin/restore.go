@@ -0,0 +1,65 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	restoreCommand = &command{
+		name:  "restore",
+		usage: "restore [path] | restore -backup file [-replace]",
+	}
+	restoreBackupFile = restoreCommand.flag.String("backup", "", "Restore the database from the file written by backup instead of a package from its tombstone.")
+	restoreReplace    = restoreCommand.flag.Bool("replace", false, "Delete the data in the database before restoring the backup. Without -replace, the backup is only restored to an empty database.")
+)
+
+func init() {
+	restoreCommand.run = restore
+}
+
+func restore(c *command) {
+	if len(c.flag.Args()) > 1 || (*restoreBackupFile != "" && len(c.flag.Args()) != 0) {
+		c.printUsage()
+		os.Exit(1)
+	}
+	if *restoreBackupFile != "" {
+		restoreBackup(*restoreBackupFile, *restoreReplace)
+		return
+	}
+	db, err := database.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(c.flag.Args()) == 0 {
+		tombstones, err := db.Tombstones()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, t := range tombstones {
+			fmt.Printf("%s\t%s\n", t.Deleted.Format(time.RFC3339), t.Path)
+		}
+		return
+	}
+
+	path := c.flag.Args()[0]
+	ok, err := db.Restore(path, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		log.Fatalf("No tombstone for %s.", path)
+	}
+	audit(db, "restore", path)
+}
@@ -0,0 +1,199 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+var (
+	seedCommand = &command{
+		name:  "seed",
+		usage: "seed [-c n] [-n max] [-since time] file|-|url",
+	}
+	seedConcurrency = seedCommand.flag.Int("c", 4, "Number of import paths to queue at the same time.")
+	seedMax         = seedCommand.flag.Int("n", 0, "Maximum number of import paths to queue. Zero queues all the paths.")
+	seedSince       = seedCommand.flag.String("since", "", "RFC 3339 time of the first module version read from a module index URL.")
+)
+
+func init() {
+	seedCommand.run = seed
+}
+
+// seedPageSize is the number of module versions read from a module index with
+// each request.
+const seedPageSize = 2000
+
+// seed queues the import paths of the known packages for the first crawl to
+// populate a new instance. The paths are read from a file with the paths
+// separated by white space, from the standard input with the file name "-" or
+// from a module index with the protocol of https://index.golang.org/index.
+// The paths are queued in the database or with the API of -server.
+func seed(c *command) {
+	if len(c.flag.Args()) != 1 || *seedConcurrency < 1 {
+		c.printUsage()
+		os.Exit(1)
+	}
+	var since time.Time
+	if *seedSince != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, *seedSince); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	add := func(p string) error {
+		return remoteRequest("POST", "/-/admin/crawl", url.Values{"path": {p}}, nil)
+	}
+	if !isRemote() {
+		db, err := database.New()
+		if err != nil {
+			log.Fatal(err)
+		}
+		add = db.AddNewCrawl
+	}
+
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	var done, failed int64
+	for i := 0; i < *seedConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range ch {
+				if err := add(p); err != nil {
+					log.Printf("%s: %v", p, err)
+					atomic.AddInt64(&failed, 1)
+				}
+				if n := atomic.AddInt64(&done, 1); n%1000 == 0 {
+					log.Printf("Queued %d paths, %d errors", n-atomic.LoadInt64(&failed), atomic.LoadInt64(&failed))
+				}
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	emit := func(p string) bool {
+		p = strings.Trim(p, "/")
+		if p == "" || seen[p] {
+			return true
+		}
+		if *seedMax > 0 && len(seen) >= *seedMax {
+			return false
+		}
+		seen[p] = true
+		ch <- p
+		return true
+	}
+	src := c.flag.Args()[0]
+	var err error
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		err = readModuleIndex(src, since, emit)
+	} else {
+		err = readSeedFile(src, emit)
+	}
+	close(ch)
+	wg.Wait()
+	log.Printf("Queued %d paths, %d errors", done-failed, failed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readSeedFile calls emit with the paths in the file until emit returns
+// false.
+func readSeedFile(name string, emit func(string) bool) error {
+	r := io.Reader(os.Stdin)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+	for s.Scan() {
+		if !emit(s.Text()) {
+			return nil
+		}
+	}
+	return s.Err()
+}
+
+// indexEntry is a module version in a module index.
+type indexEntry struct {
+	Path      string
+	Version   string
+	Timestamp time.Time
+}
+
+// readModuleIndex calls emit with the module paths in the module index at u
+// from the time since until emit returns false or the end of the index.
+func readModuleIndex(u string, since time.Time, emit func(string) bool) error {
+	for {
+		q := url.Values{"limit": {fmt.Sprint(seedPageSize)}}
+		if !since.IsZero() {
+			q.Set("since", since.UTC().Format(time.RFC3339Nano))
+		}
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		resp, err := http.Get(u + sep + q.Encode())
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("%s: %s", u, resp.Status)
+		}
+		start := since
+		n := 0
+		d := json.NewDecoder(resp.Body)
+		for {
+			var e indexEntry
+			if err = d.Decode(&e); err != nil {
+				break
+			}
+			n++
+			since = e.Timestamp
+			if !emit(e.Path) {
+				resp.Body.Close()
+				return nil
+			}
+		}
+		resp.Body.Close()
+		if err != io.EOF {
+			return fmt.Errorf("%s: %v", u, err)
+		}
+		log.Printf("Read %d module versions up to %s", n, since.Format(time.RFC3339))
+		if n == seedPageSize && !since.After(start) {
+			return fmt.Errorf("%s: more than %d module versions at %s", u, seedPageSize, since.Format(time.RFC3339Nano))
+		}
+		if n < seedPageSize {
+			return nil
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"log"
 	"os"
 	"sort"
+	"time"
 
 	"github.com/golang/gddo/database"
 )
@@ -42,6 +43,29 @@ func stats(c *command) {
 		log.Fatal(err)
 	}
 
+	r, err := db.NewReport()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("SUMMARY")
+	fmt.Printf("%-12s %d\n", "packages", r.Packages())
+	fmt.Printf("%-12s %d\n", "libraries", r.Libraries)
+	fmt.Printf("%-12s %d\n", "commands", r.Commands)
+	fmt.Printf("%-12s %d\n", "directories", r.Directories)
+	fmt.Printf("%-12s %d\n", "suppressed", r.Suppressed)
+	fmt.Printf("%-12s %d\n", "private", r.Private)
+	fmt.Printf("%-12s %d\n", "blocked", r.Blocked)
+	fmt.Printf("%-12s %d\n", "quarantined", r.Quarantined)
+	fmt.Printf("%-12s %d\n", "crawl due", r.CrawlDue)
+	fmt.Printf("%-12s %d\n", "new crawl", r.NewCrawl)
+	fmt.Printf("%-12s %d\n", "bad crawl", r.BadCrawl)
+	fmt.Printf("%-12s %v\n", "average age", r.AverageAge.Round(time.Minute))
+	fmt.Printf("%-12s %d\n", "memory", r.MemoryBytes)
+	fmt.Println("HOSTS")
+	for _, h := range r.Hosts {
+		fmt.Printf("%6d %s\n", h.Packages, h.Host)
+	}
+
 	var packageSizes []itemSize
 	var truncatedPackages []string
 	projectSizes := make(map[string]int)
@@ -0,0 +1,71 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+)
+
+var tasksCommand = &command{
+	name:  "tasks",
+	usage: "tasks [pause|resume task]",
+}
+
+func init() {
+	tasksCommand.run = tasks
+}
+
+// tasks prints the status of the background tasks of the server or pauses
+// or resumes a task in all the replicas. The command requires -server.
+func tasks(c *command) {
+	args := c.flag.Args()
+	if len(args) != 0 && (len(args) != 2 || (args[0] != "pause" && args[0] != "resume")) {
+		c.printUsage()
+		os.Exit(1)
+	}
+	if !isRemote() {
+		log.Fatal("tasks requires -server")
+	}
+	form := url.Values{}
+	method := "GET"
+	if len(args) == 2 {
+		method = "POST"
+		form.Set("action", args[0])
+		form.Set("task", args[1])
+	}
+	var data struct {
+		Results []struct {
+			Name     string    `json:"name"`
+			Interval string    `json:"interval"`
+			Enabled  bool      `json:"enabled"`
+			Paused   bool      `json:"paused"`
+			Standby  bool      `json:"standby"`
+			Last     time.Time `json:"last"`
+		} `json:"results"`
+	}
+	remote(method, "/-/admin/tasks", form, &data)
+	for _, t := range data.Results {
+		status := "disabled"
+		switch {
+		case t.Paused:
+			status = "paused"
+		case t.Standby:
+			status = "standby"
+		case t.Enabled:
+			status = "enabled"
+		}
+		last := ""
+		if !t.Last.IsZero() {
+			last = t.Last.Format(time.RFC3339)
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", t.Name, status, t.Interval, last)
+	}
+}
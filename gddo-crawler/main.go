@@ -0,0 +1,352 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// Command gddo-crawler runs the background crawls of gddo-server as a separate
+// process. The crawler updates the packages in the database shared with the
+// web servers, finds the packages imported by crawled packages and bumps the
+// crawl of updated GitHub repositories.
+//
+// The crawler and the web servers coordinate the background tasks with leases
+// in the database, so a task runs in one process at a time. Run the web
+// servers with -crawl_interval 0 and -github_interval 0 to leave the tasks to
+// the crawler, and with -changes_interval to refresh the cached pages after
+// the crawls. The flags configuring the fetches have the same names as the
+// flags of gddo-server.
+//
+// With -fetch_executor process, each package is fetched and parsed by a
+// worker process, the crawler run again with -fetch_worker and the same
+// flags, with the limits of the -fetch flags and optionally in the sandbox
+// of -fetch_sandbox.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/crawler"
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+var (
+	crawlInterval        = flag.Duration("crawl_interval", 10*time.Second, "Package updater sleeps for this duration between package updates. Zero disables updates.")
+	indexInterval        = flag.Duration("module_index_interval", 0, "The modules published to module_index since the last read are crawled at this interval. Zero disables the reads.")
+	moduleIndex          = flag.String("module_index", "https://index.golang.org/index", "URL of the module index read by the module index task.")
+	gitHubInterval       = flag.Duration("github_interval", 0, "Github updates crawler sleeps for this duration between fetches. Zero disables the crawler.")
+	maxAge               = flag.Duration("max_age", 24*time.Hour, "Update package documents older than this age.")
+	maxHostErrorRate     = flag.Float64("max_host_error_rate", 0, "Rate of failed and rate limited fetches from a host in the current and the previous hour, between 0 and 1, above which the background crawl of the packages on the host is delayed by an hour. Zero disables the limit.")
+	taskLease            = flag.Duration("task_lease", 3*time.Minute, "Duration of the leases that give a process the ownership of the background tasks. Zero runs the tasks without coordination.")
+	replicaID            = flag.String("replica_id", "", "Name of the process in the leases of the background tasks. The default is the host name, the process ID and a random suffix.")
+	fixturesMode         = flag.String("fixtures", "", "Record the responses of the source hosts to fixtures_dir or replay the recorded responses: record or replay. Empty fetches from the hosts.")
+	fixturesDir          = flag.String("fixtures_dir", "", "Directory of the responses recorded and replayed with the fixtures flag.")
+	migrateTimeout       = flag.Duration("migrate_timeout", 10*time.Minute, "Time to wait at startup for another process to complete the migration of the database schema.")
+	requestTimeout       = flag.Duration("request_timeout", 20*time.Second, "Time out for roundtripping an HTTP request.")
+	userAgent            = flag.String("user_agent", "", "User-Agent header sent with the fetches.")
+	gitHubCredentials    = flag.String("github_credentials", "", "Query string with the client_id and client_secret of a GitHub application sent with GitHub API requests.")
+	allowPrefixes        = flag.String("allow_prefixes", "", "Comma separated list of import path prefixes to crawl. Use std for the standard library. If set, other packages are never fetched.")
+	credentialsFile      = flag.String("credentials", "", "File with the tokens and SSH keys used to fetch private repositories. Each line has the form: host token <token> or host ssh-key <path>.")
+	gitLabHosts          = flag.String("gitlab_hosts", "", "Comma separated list of GitLab instances to fetch packages from, each as host or host=token.")
+	giteaHosts           = flag.String("gitea_hosts", "", "Comma separated list of Gitea or Forgejo instances to fetch packages from, each as baseURL or baseURL=token.")
+	bitbucketServerHosts = flag.String("bitbucket_server_hosts", "", "Comma separated list of Bitbucket Server or Data Center instances to fetch packages from, each as baseURL or baseURL=token.")
+	azureDevOpsHosts     = flag.String("azure_devops_hosts", "", "Comma separated list of Azure DevOps organizations or collections to fetch packages from, each as baseURL or baseURL=token where token is a personal access token.")
+	vcsCommands          = flag.String("vcs_commands", "git,svn", "Comma separated list of version control commands to run for repositories on hosts without an API: git, hg and svn.")
+	metaCacheTTL         = flag.Duration("meta_cache_ttl", 24*time.Hour, "Time to use resolved go-import meta tags before fetching the tags again. Zero disables the cache.")
+	maxArchiveSize       = flag.Int64("max_archive_size", 0, "Maximum size in bytes of repository archives downloaded to fetch directory files in one request. Zero fetches files one by one.")
+	maxFileSize          = flag.Int64("max_file_size", 4<<20, "Maximum size in bytes of a fetched file. Larger files are skipped. Zero disables the limit.")
+	maxFetchSize         = flag.Int64("max_fetch_size", 32<<20, "Maximum size in bytes of the files fetched for a package. Packages with larger files are not found. Zero disables the limit.")
+	skipDirs             = flag.String("skip_dirs", "testdata,vendor", "Comma separated list of directory names that are not fetched.")
+	gitFallback          = flag.Bool("git_fallback", false, "Clone import paths without a go-import meta tag as Git repositories over HTTPS.")
+	moduleProxy          = flag.String("module_proxy", "", "Base URL of a Go module proxy to fetch packages in modules from, for example https://proxy.golang.org.")
+	checksumDB           = flag.String("checksum_db", "", "Base URL of the Go checksum database to verify the module zips fetched from module_proxy against, for example https://sum.golang.org. Modules not matching the checksum database are not indexed.")
+	outboundCheck        = flag.Bool("outbound_check", true, "Fetch only from hosts resolving to public addresses, not from the private, loopback, link-local and reserved address ranges, such as the cloud metadata endpoints. The configured hosts, such as gitlab_hosts, gitea_hosts, module_proxy and the hosts in the credentials file, are always fetched.")
+	outboundAllow        = flag.String("outbound_allow", "", "Comma separated list of hosts and address ranges fetched with outbound_check, each as an IP address, a CIDR network, a host name or a *.domain pattern.")
+	outboundDeny         = flag.String("outbound_deny", "", "Comma separated list of hosts and address ranges never fetched with outbound_check, each as an IP address, a CIDR network, a host name or a *.domain pattern.")
+	defaultGOOS          = flag.String("default_goos", "", "Default GOOS to use when building package documents.")
+	goRoots              = flag.String("goroots", "", "Comma separated list of Go distribution trees to read the standard library from instead of golang.org, newest first, each as dir or version=dir. The version defaults to the first line of the VERSION file of the tree. Older versions are served at import/path@version.")
+	buildTags            = flag.String("build_tags", "", "Comma separated list of additional build tags to satisfy when building package documents.")
+	maxDeclSize          = flag.Int("max_decl_size", 32<<10, "Maximum size in bytes of the code of a declaration in package documents. Larger declarations are collapsed. Zero disables the limit.")
+	webhookURLs          = flag.String("crawl_webhooks", "", "Comma separated list of URLs receiving a POST with a JSON event after each package is crawled, hidden, deleted or quarantined.")
+	webhookSecret        = flag.String("crawl_webhook_secret", "", "Secret of the HMAC-SHA256 signatures of the crawl webhook events sent in the X-Gddo-Signature header.")
+	maxDocSize           = flag.Int("max_doc_size", 1<<20, "Maximum size in bytes of the code and doc comments of the declarations of a package document. The declarations after the limit are omitted. Zero disables the limit.")
+	fetchExecutor        = flag.String("fetch_executor", "local", "Where packages are fetched and parsed: local, in the crawler process, or process, in a worker process started for each fetch with the fetch limits.")
+	fetchSandbox         = flag.String("fetch_sandbox", "", "Space separated command line running the fetch worker processes in a sandbox, such as nsjail or bwrap with its arguments. The command line of the worker is appended.")
+	fetchCPULimit        = flag.Duration("fetch_cpu_limit", time.Minute, "CPU time of a fetch worker process. Zero disables the limit.")
+	fetchMemoryLimit     = flag.Int64("fetch_memory_limit", 4<<30, "Size in bytes of the address space of a fetch worker process. Zero disables the limit.")
+	fetchTimeout         = flag.Duration("fetch_timeout", 5*time.Minute, "Time after which a fetch worker process is killed. Zero disables the limit.")
+	fetchProcesses       = flag.Int("fetch_processes", 4, "Maximum number of concurrent fetch worker processes. Zero does not limit the number.")
+	fetchWorker          = flag.Bool("fetch_worker", false, "Serve one fetch on the standard input and output and exit. The fetch executor starts the worker processes with this flag.")
+)
+
+type transport struct {
+	t http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "api.github.com" && *gitHubCredentials != "" {
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = *gitHubCredentials
+		} else {
+			req.URL.RawQuery += "&" + *gitHubCredentials
+		}
+	}
+	if *userAgent != "" {
+		req.Header.Set("User-Agent", *userAgent)
+	}
+	return t.t.RoundTrip(req)
+}
+
+// allowed returns a function that returns true if the import path is in the
+// comma separated list of prefixes or nil if the list is empty.
+func allowed(spec string) func(string) bool {
+	var prefixes []string
+	for _, p := range strings.Split(spec, ",") {
+		p = strings.Trim(strings.TrimSpace(p), "/")
+		if p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	if prefixes == nil {
+		return nil
+	}
+	return func(importPath string) bool {
+		for _, p := range prefixes {
+			switch {
+			case p == "std":
+				if gosrc.IsGoRepoPath(importPath) {
+					return true
+				}
+			case importPath == p || strings.HasPrefix(importPath, p+"/"):
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// splitHostToken splits a host=token element of a host list flag.
+func splitHostToken(h string) (string, string) {
+	if i := strings.Index(h, "="); i >= 0 {
+		return h[:i], h[i+1:]
+	}
+	return h, ""
+}
+
+func configure() error {
+	if *maxHostErrorRate < 0 || *maxHostErrorRate > 1 {
+		return fmt.Errorf("max_host_error_rate %v is not between 0 and 1", *maxHostErrorRate)
+	}
+	doc.SetDefaultGOOS(*defaultGOOS)
+	doc.SetBuildTags(strings.Split(*buildTags, ","))
+	doc.SetSizeLimits(*maxDeclSize, *maxDocSize)
+	gosrc.SetModuleProxy(*moduleProxy)
+	gosrc.SetChecksumDB(*checksumDB)
+	gosrc.SetGitFallback(*gitFallback)
+	gosrc.SetMaxArchiveSize(*maxArchiveSize)
+	gosrc.SetFetchLimits(*maxFileSize, *maxFetchSize)
+	gosrc.SetSkipDirs(strings.Split(*skipDirs, ","))
+	if err := gosrc.SetVCSCommands(strings.Split(*vcsCommands, ",")); err != nil {
+		return err
+	}
+	if err := gosrc.SetGoRoots(strings.Split(*goRoots, ",")); err != nil {
+		return err
+	}
+	if err := gosrc.SetOutboundPolicy(*outboundCheck, strings.Split(*outboundAllow, ","), strings.Split(*outboundDeny, ",")); err != nil {
+		return err
+	}
+	if err := gosrc.SetFixtures(*fixturesMode, *fixturesDir); err != nil {
+		return err
+	}
+	for _, h := range strings.Split(*gitLabHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			gosrc.AddGitLabHost(splitHostToken(h))
+		}
+	}
+	for _, h := range strings.Split(*giteaHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			if err := gosrc.AddGiteaHost(splitHostToken(h)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, h := range strings.Split(*bitbucketServerHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			if err := gosrc.AddBitbucketServerHost(splitHostToken(h)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, h := range strings.Split(*azureDevOpsHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			if err := gosrc.AddAzureDevOpsHost(splitHostToken(h)); err != nil {
+				return err
+			}
+		}
+	}
+	if *credentialsFile != "" {
+		f, err := os.Open(*credentialsFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		c, err := gosrc.ReadCredentials(f)
+		if err != nil {
+			return err
+		}
+		gosrc.SetCredentials(c)
+	}
+	return nil
+}
+
+// executor returns the fetch executor selected by the flags.
+func executor() (crawler.Executor, error) {
+	switch *fetchExecutor {
+	case "local":
+		return crawler.LocalExecutor{}, nil
+	case "process":
+		command, err := crawler.WorkerCommand(strings.Fields(*fetchSandbox), "-fetch_worker")
+		if err != nil {
+			return nil, err
+		}
+		limits := crawler.Limits{CPUTime: *fetchCPULimit, Memory: *fetchMemoryLimit, Timeout: *fetchTimeout}
+		return crawler.NewProcessExecutor(command, limits, *fetchProcesses), nil
+	}
+	return nil, fmt.Errorf("unknown fetch_executor %q", *fetchExecutor)
+}
+
+// task is a background task. The task runs while the process holds the lease
+// of the task. The names of the tasks are the names of the same tasks in
+// gddo-server.
+type task struct {
+	name     string
+	fn       func(ctx context.Context) error
+	interval time.Duration
+	next     time.Time
+}
+
+func holdLease(db *database.Database, t *task) bool {
+	if *taskLease <= 0 {
+		return true
+	}
+	ok, err := db.AcquireLease("task:"+t.name, *replicaID, *taskLease)
+	if err != nil {
+		slog.Error("db.AcquireLease", "task", t.name, "err", err)
+		return false
+	}
+	return ok
+}
+
+// run runs the task and extends the lease of the task while the task runs.
+func run(ctx context.Context, db *database.Database, t *task) {
+	done := make(chan struct{})
+	if *taskLease > 0 {
+		go func() {
+			ticker := time.NewTicker(*taskLease / 3)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					holdLease(db, t)
+				}
+			}
+		}()
+	}
+	if err := t.fn(ctx); err != nil {
+		slog.Error("background task", "task", t.name, "err", err)
+	}
+	close(done)
+	t.next = time.Now().Add(t.interval)
+}
+
+func main() {
+	flag.Parse()
+	if err := configure(); err != nil {
+		log.Fatal(err)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = gosrc.OutboundDialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	client := &http.Client{
+		Timeout:   *requestTimeout,
+		Transport: transport{t},
+	}
+	if *fetchWorker {
+		// The worker has no database: the resolved go-import meta tags
+		// are not cached and the vendored packages are not filtered.
+		if err := crawler.ServeFetchWorker(os.Stdin, os.Stdout, client); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	fetcher, err := executor()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *replicaID == "" {
+		host, _ := os.Hostname()
+		var p [4]byte
+		rand.Read(p[:])
+		*replicaID = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(p[:]))
+	}
+
+	db, err := database.New()
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	if err := db.Migrate(*replicaID, *migrateTimeout); err != nil {
+		log.Fatalf("Error migrating database: %v", err)
+	}
+	if *metaCacheTTL > 0 {
+		gosrc.SetMetaCache(db, *metaCacheTTL)
+	}
+	gosrc.SetVendorFilter(db.VendorIndexed)
+
+	c := &crawler.Crawler{
+		DB:               db,
+		MaxAge:           *maxAge,
+		MaxHostErrorRate: *maxHostErrorRate,
+		Client:           func(context.Context) *http.Client { return client },
+		Executor:         fetcher,
+		Allowed:          allowed(*allowPrefixes),
+	}
+	if *webhookURLs != "" {
+		c.Webhooks = &crawler.Webhooks{Client: &http.Client{Timeout: *requestTimeout}}
+		c.Webhooks.SetTargets(strings.Split(*webhookURLs, ","), *webhookSecret)
+	}
+	tasks := []*task{
+		{name: "GitHub updates", fn: c.ReadGitHubUpdates, interval: *gitHubInterval},
+		{name: "Module index", fn: func(ctx context.Context) error { return c.ReadModuleIndex(ctx, *moduleIndex) }, interval: *indexInterval},
+		{name: "Crawl", fn: c.CrawlNext, interval: *crawlInterval},
+	}
+
+	sleep := time.Minute
+	for _, t := range tasks {
+		if t.interval > 0 && sleep > t.interval {
+			sleep = t.interval
+		}
+	}
+	slog.Info("crawler started", "replica", *replicaID)
+	ctx := context.Background()
+	for {
+		for _, t := range tasks {
+			if t.interval > 0 && holdLease(db, t) && time.Now().After(t.next) {
+				run(ctx, db, t)
+			}
+		}
+		time.Sleep(sleep)
+	}
+}
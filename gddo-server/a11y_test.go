@@ -0,0 +1,202 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+var (
+	headingPat    = regexp.MustCompile(`<h([1-6])[\s>]`)
+	imgPat        = regexp.MustCompile(`<img\s[^>]*>`)
+	inputPat      = regexp.MustCompile(`<input\s[^>]*>`)
+	contrastPat   = regexp.MustCompile(`body\.contrast[^{]*\{([^}]*)\}`)
+	colorPat      = regexp.MustCompile(`(?:^|;)\s*color:\s*(#[0-9a-fA-F]{6})`)
+	backgroundPat = regexp.MustCompile(`background-color:\s*(#[0-9a-fA-F]{6})`)
+)
+
+func renderA11yPage(t *testing.T, name string, data map[string]interface{}) string {
+	var buf bytes.Buffer
+	if err := templates[name].Execute(&buf, data); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return buf.String()
+}
+
+// checkA11y reports accessibility problems in a rendered page.
+func checkA11y(t *testing.T, name, page string) {
+	if n := strings.Count(page, `role="main"`); n != 1 {
+		t.Errorf("%s: found %d main landmarks, want 1", name, n)
+	}
+	if !strings.Contains(page, `href="#x-main"`) || !strings.Contains(page, `id="x-main"`) {
+		t.Errorf("%s: skip link or skip link target missing", name)
+	}
+	if !strings.Contains(page, `role="navigation"`) || !strings.Contains(page, `role="contentinfo"`) {
+		t.Errorf("%s: navigation or contentinfo landmark missing", name)
+	}
+
+	// Heading levels must not skip a level below the preceding heading in
+	// the main content.
+	main := page[strings.Index(page, `role="main"`):]
+	if i := strings.Index(main, `role="contentinfo"`); i >= 0 {
+		main = main[:i]
+	}
+	prev := 1
+	for _, m := range headingPat.FindAllStringSubmatch(main, -1) {
+		level, _ := strconv.Atoi(m[1])
+		if level > prev+1 {
+			t.Errorf("%s: heading h%d follows h%d", name, level, prev)
+		}
+		prev = level
+	}
+
+	for _, img := range imgPat.FindAllString(page, -1) {
+		if !strings.Contains(img, " alt=") {
+			t.Errorf("%s: image without alt text: %s", name, img)
+		}
+	}
+	for _, input := range inputPat.FindAllString(page, -1) {
+		if !strings.Contains(input, `type="hidden"`) && !strings.Contains(input, " aria-label=") {
+			t.Errorf("%s: input without label: %s", name, input)
+		}
+	}
+}
+
+func TestA11y(t *testing.T) {
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{
+		{"about.html", "common.html", "layout.html"},
+		{"home.html", "common.html", "layout.html"},
+		{"notfound.html", "common.html", "layout.html"},
+		{"pkg.html", "common.html", "layout.html"},
+		{"tools.html", "common.html", "layout.html"},
+		{"reports.html", "common.html", "layout.html"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pdoc := newTDoc(&doc.Package{
+		ImportPath:  "github.com/user/repo",
+		ProjectRoot: "github.com/user/repo",
+		ProjectName: "repo",
+		Name:        "repo",
+		Synopsis:    "Package repo does things.",
+	})
+
+	for _, tt := range []struct {
+		name string
+		data map[string]interface{}
+	}{
+		{"about.html", map[string]interface{}{}},
+		{"home.html", map[string]interface{}{}},
+		{"notfound.html", map[string]interface{}{}},
+		{"pkg.html", map[string]interface{}{"pdoc": pdoc}},
+		{"tools.html", map[string]interface{}{"pdoc": pdoc, "uri": "http://godoc.org/github.com/user/repo", "ownerKey": "k", "ownerKeyHash": "h"}},
+		{"reports.html", map[string]interface{}{"reports": []database.AbuseReport{{Path: "github.com/user/repo", Reason: "spam", Count: 1}}}},
+	} {
+		checkA11y(t, tt.name, renderA11yPage(t, tt.name, tt.data))
+	}
+}
+
+func relativeLuminance(hex string) float64 {
+	var l [3]float64
+	for i := range l {
+		v, _ := strconv.ParseUint(hex[1+2*i:3+2*i], 16, 8)
+		c := float64(v) / 255
+		if c <= 0.03928 {
+			l[i] = c / 12.92
+		} else {
+			l[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*l[0] + 0.7152*l[1] + 0.0722*l[2]
+}
+
+// contrastRatio returns the WCAG contrast ratio of two colors.
+func contrastRatio(a, b string) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+func TestContrast(t *testing.T) {
+	p, err := ioutil.ReadFile(filepath.Join("assets", "site.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := contrastPat.FindAllStringSubmatch(string(p), -1)
+	if len(rules) == 0 {
+		t.Fatal("no body.contrast rules found in site.css")
+	}
+	for _, rule := range rules {
+		m := colorPat.FindStringSubmatch(rule[1])
+		if m == nil {
+			continue
+		}
+		bg := "#ffffff"
+		if m := backgroundPat.FindStringSubmatch(rule[1]); m != nil {
+			bg = m[1]
+		}
+		if r := contrastRatio(m[1], bg); r < 7 {
+			t.Errorf("contrast of %s on %s is %.2f, want at least 7 (%s)", m[1], bg, r, strings.TrimSpace(rule[0]))
+		}
+	}
+}
+
+var (
+	palettePat = regexp.MustCompile(`(?s)(:root[^{]*)\{([^{}]*)\}`)
+	varPat     = regexp.MustCompile(`(--[a-z-]+):\s*([^;]+);`)
+)
+
+func TestThemePalettes(t *testing.T) {
+	p, err := ioutil.ReadFile(filepath.Join("assets", "site.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	palettes := map[string]map[string]string{}
+	for _, m := range palettePat.FindAllStringSubmatch(string(p), -1) {
+		vars := map[string]string{}
+		for _, v := range varPat.FindAllStringSubmatch(m[2], -1) {
+			vars[v[1]] = strings.TrimSpace(v[2])
+		}
+		palettes[strings.TrimSpace(m[1])] = vars
+	}
+	light := palettes[":root"]
+	dark := palettes[`:root[data-theme="dark"]`]
+	auto := palettes[`:root:not([data-theme="light"])`]
+	if light == nil || dark == nil || auto == nil {
+		t.Fatalf("palettes not found in site.css: %v", palettes)
+	}
+	for name := range light {
+		if dark[name] == "" {
+			t.Errorf("%s not set in dark palette", name)
+		}
+	}
+	if !reflect.DeepEqual(dark, auto) {
+		t.Errorf("dark palette %v differs from prefers-color-scheme palette %v", dark, auto)
+	}
+	for _, pair := range [][2]string{{"--fg", "--bg"}, {"--link", "--bg"}, {"--muted", "--bg"}, {"--code-fg", "--pre-bg"}} {
+		if r := contrastRatio(dark[pair[0]], dark[pair[1]]); r < 4.5 {
+			t.Errorf("dark contrast of %s on %s is %.2f, want at least 4.5", pair[0], pair[1], r)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+// Visitors report packages with spam, malware or content infringing
+// copyright from the tools page. The reports are collapsed by package and
+// reason into a moderation queue reviewed at /-/admin/reports or with the
+// gddo-admin reports command.
+
+const (
+	maxReportMessage = 2000
+	maxReportContact = 200
+)
+
+func isAbuseReason(reason string) bool {
+	for _, r := range database.AbuseReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// serveReportAbuse adds a report of a package to the moderation queue.
+func serveReportAbuse(resp http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+	if err := checkRate(req.Context(), reportLimiter); err != nil {
+		return err
+	}
+	importPath := req.Form.Get("path")
+	reason := req.Form.Get("reason")
+	message := strings.TrimSpace(req.Form.Get("message"))
+	contact := strings.TrimSpace(req.Form.Get("contact"))
+	if !isAbuseReason(reason) || len(message) > maxReportMessage || len(contact) > maxReportContact {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	pdoc, _, err := db.GetDoc(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	text := "Thank you. The report has been sent to the moderators."
+	if *readOnly {
+		text = errorText(errReadOnly)
+	} else {
+		logger(req.Context()).Info("abuse report", "path", importPath, "reason", reason)
+		if err := db.AddAbuseReport(importPath, reason, message, contact); err != nil {
+			return err
+		}
+	}
+	setFlashMessages(resp, []flashMessage{{ID: "report", Args: []string{text}}})
+	http.Redirect(resp, req, "/"+importPath+"?tools", http.StatusFound)
+	return nil
+}
+
+// serveAbuseReports serves the moderation queue to requests with the viewer
+// role, as JSON with the format parameter json. A POST request with the
+// action block or dismiss blocks the package in the path parameter or
+// dismisses the reports of the package. Blocking requires the admin role and
+// dismissing the operator role.
+func serveAbuseReports(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRole(req, roleViewer); err != nil {
+		return err
+	}
+	if req.Method == "POST" {
+		if *readOnly {
+			return errReadOnly
+		}
+		importPath := req.Form.Get("path")
+		if importPath == "" {
+			return &httpError{status: http.StatusBadRequest}
+		}
+		action := req.Form.Get("action")
+		switch action {
+		case "block":
+			if err := checkRole(req, roleAdmin); err != nil {
+				return err
+			}
+			if err := db.Block(importPath); err != nil {
+				return err
+			}
+			pages.invalidate(importPath)
+			packageBlocked(importPath, "abuse report")
+		case "dismiss":
+			if err := checkRole(req, roleOperator); err != nil {
+				return err
+			}
+		default:
+			return &httpError{status: http.StatusBadRequest}
+		}
+		if _, err := db.RemoveAbuseReports(importPath); err != nil {
+			return err
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: adminActor(req), Action: action, Target: importPath, Reason: req.Form.Get("reason")}); err != nil {
+			return err
+		}
+		http.Redirect(resp, req, "/-/admin/reports", http.StatusFound)
+		return nil
+	}
+
+	reports, err := db.AbuseReports()
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Cache-Control", "private")
+	if req.Form.Get("format") == "json" {
+		if reports == nil {
+			reports = []database.AbuseReport{}
+		}
+		resp.Header().Set("Content-Type", jsonMIMEType)
+		return json.NewEncoder(resp).Encode(map[string]interface{}{"results": reports})
+	}
+	return executeTemplate(resp, "reports.html", http.StatusOK, nil, map[string]interface{}{
+		"reports": reports,
+	})
+}
+
+// adminActor returns the name of the administrator for the audit log: the
+// value of the -auth_header set by the proxy, the name of the signed in user
+// or the name of the API token.
+func adminActor(req *http.Request) string {
+	if *authHeader != "" {
+		if v := req.Header.Get(*authHeader); v != "" {
+			return v
+		}
+	}
+	if u := authSession(req); u != nil {
+		return u.Name
+	}
+	if t := requestToken(req); t != nil {
+		return t.name
+	}
+	return "api"
+}
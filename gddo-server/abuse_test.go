@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServeReportAbuseBadRequest(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		form   url.Values
+		status int
+	}{
+		{"GET", url.Values{"path": {"github.com/user/repo"}, "reason": {"spam"}}, http.StatusMethodNotAllowed},
+		{"POST", url.Values{"path": {"github.com/user/repo"}, "reason": {"boring"}}, http.StatusBadRequest},
+		{"POST", url.Values{"path": {"github.com/user/repo"}, "reason": {"spam"}, "message": {strings.Repeat("x", maxReportMessage+1)}}, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(tt.method, "http://godoc.org/-/report", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.ParseForm()
+		err := serveReportAbuse(httptest.NewRecorder(), req)
+		if e, ok := err.(*httpError); !ok || e.status != tt.status {
+			t.Errorf("%s %v: err = %v, want status %d", tt.method, tt.form, err, tt.status)
+		}
+	}
+}
+
+func TestServeAbuseReportsUnauthorized(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://godoc.org/-/admin/reports", nil)
+	req.ParseForm()
+	err := serveAbuseReports(httptest.NewRecorder(), req)
+	if e, ok := err.(*httpError); !ok || e.status != http.StatusUnauthorized {
+		t.Errorf("err = %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminActor(t *testing.T) {
+	defer func(h string) { *authHeader = h }(*authHeader)
+	*authHeader = "X-Auth-User"
+	req := httptest.NewRequest("POST", "http://godoc.org/-/admin/reports", nil)
+	if got := adminActor(req); got != "api" {
+		t.Errorf("adminActor() = %q, want api", got)
+	}
+	req.Header.Set("X-Auth-User", "alice@example.com")
+	if got := adminActor(req); got != "alice@example.com" {
+		t.Errorf("adminActor() = %q, want alice@example.com", got)
+	}
+}
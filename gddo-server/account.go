@@ -0,0 +1,63 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+// accountHosts are the hosts with repositories named <host>/<account>/<repo>.
+// The page for <host>/<account> lists the packages of the account.
+var accountHosts = map[string]bool{
+	"bitbucket.org": true,
+	"github.com":    true,
+	"gitlab.com":    true,
+}
+
+// isAccountPath returns true if p is the path of an account on one of the
+// accountHosts.
+func isAccountPath(p string) bool {
+	i := strings.Index(p, "/")
+	return i > 0 && accountHosts[p[:i]] && i < len(p)-1 && !strings.Contains(p[i+1:], "/")
+}
+
+// serveAccount serves the page listing the indexed packages in the
+// repositories of the account.
+func serveAccount(resp http.ResponseWriter, req *http.Request, account string) error {
+	pkgs, err := db.AccountPackages(account)
+	if err != nil {
+		return err
+	}
+	var paths []database.Package
+	for _, pkg := range pkgs {
+		paths = append(paths, database.Package{Path: pkg.Path})
+	}
+	paths, err = filterPrivate(req, filterAllowed(paths))
+	if err != nil {
+		return err
+	}
+	visible := make(map[string]bool)
+	for _, pkg := range paths {
+		visible[pkg.Path] = true
+	}
+	allowed := pkgs[:0]
+	for _, pkg := range pkgs {
+		if visible[pkg.Path] {
+			allowed = append(allowed, pkg)
+		}
+	}
+	if len(allowed) == 0 {
+		return &httpError{status: http.StatusNotFound}
+	}
+	return executeTemplate(resp, "account.html", http.StatusOK, nil, map[string]interface{}{
+		"account": account,
+		"pkgs":    allowed,
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+)
+
+var isAccountPathTests = []struct {
+	path string
+	ok   bool
+}{
+	{"github.com/spf13", true},
+	{"gitlab.com/user", true},
+	{"github.com/spf13/cobra", false},
+	{"github.com/", false},
+	{"github.com", false},
+	{"example.com/user", false},
+}
+
+func TestIsAccountPath(t *testing.T) {
+	for _, tt := range isAccountPathTests {
+		if ok := isAccountPath(tt.path); ok != tt.ok {
+			t.Errorf("isAccountPath(%q) = %v, want %v", tt.path, ok, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+// The administrative HTTP API at /-/admin/ is used by the gddo-admin command
+// with the -server flag. The endpoints check the role of the request before
+// each action and record the action in the audit log.
+
+// adminAction checks the method, the role and the read-only mode of a
+// request for an action on the path parameter and returns the path.
+func adminAction(req *http.Request, r role) (string, error) {
+	if req.Method != "POST" {
+		return "", &httpError{status: http.StatusMethodNotAllowed}
+	}
+	if err := checkRole(req, r); err != nil {
+		return "", err
+	}
+	if *readOnly {
+		return "", errReadOnly
+	}
+	importPath := strings.Trim(req.Form.Get("path"), "/")
+	if importPath == "" {
+		return "", &httpError{status: http.StatusBadRequest}
+	}
+	return importPath, nil
+}
+
+func writeAdminResult(resp http.ResponseWriter, req *http.Request, action, target string) error {
+	if err := db.AddAuditEntry(database.AuditEntry{Actor: adminActor(req), Action: action, Target: target, Reason: req.Form.Get("reason")}); err != nil {
+		return err
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "private")
+	return json.NewEncoder(resp).Encode(map[string]string{"action": action, "target": target})
+}
+
+// serveAdminDelete deletes the package in the path parameter. The request
+// requires the admin role.
+func serveAdminDelete(resp http.ResponseWriter, req *http.Request) error {
+	importPath, err := adminAction(req, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := db.Delete(importPath); err != nil {
+		return err
+	}
+	pages.invalidate(importPath)
+	return writeAdminResult(resp, req, "delete", importPath)
+}
+
+// serveAdminCrawl queues the package in the path parameter for a crawl
+// before the other packages. The request requires the operator role.
+func serveAdminCrawl(resp http.ResponseWriter, req *http.Request) error {
+	importPath, err := adminAction(req, roleOperator)
+	if err != nil {
+		return err
+	}
+	if err := db.AddNewCrawl(importPath); err != nil {
+		return &httpError{status: http.StatusBadRequest, err: err}
+	}
+	return writeAdminResult(resp, req, "crawl", importPath)
+}
+
+// taskStatus is the status of a background task served by serveAdminTasks.
+type taskStatus struct {
+	Name     string    `json:"name"`
+	Interval string    `json:"interval"`
+	Enabled  bool      `json:"enabled"`
+	Paused   bool      `json:"paused"`
+	Standby  bool      `json:"standby"`
+	Last     time.Time `json:"last,omitempty"`
+}
+
+// serveAdminTasks serves the status of the background tasks of the replica
+// as JSON to requests with the viewer role. A POST request with the action
+// pause or resume pauses or resumes the task in the task parameter in all
+// the replicas. The changes require the operator role.
+func serveAdminTasks(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRole(req, roleViewer); err != nil {
+		return err
+	}
+	switch req.Method {
+	case "GET", "HEAD":
+	case "POST":
+		if err := checkRole(req, roleOperator); err != nil {
+			return err
+		}
+		name := req.Form.Get("task")
+		if findBackgroundTask(name) == nil {
+			return &httpError{status: http.StatusNotFound}
+		}
+		action := req.Form.Get("action")
+		switch action {
+		case "pause":
+			if err := db.PauseTask(name); err != nil {
+				return err
+			}
+		case "resume":
+			if _, err := db.ResumeTask(name); err != nil {
+				return err
+			}
+		default:
+			return &httpError{status: http.StatusBadRequest}
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: adminActor(req), Action: action, Target: name, Reason: req.Form.Get("reason")}); err != nil {
+			return err
+		}
+	default:
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+
+	paused, err := db.PausedTasks()
+	if err != nil {
+		return err
+	}
+	isPaused := make(map[string]bool)
+	for _, name := range paused {
+		isPaused[name] = true
+	}
+	backgroundMu.Lock()
+	tasks := make([]taskStatus, 0, len(backgroundTasks))
+	for _, task := range backgroundTasks {
+		tasks = append(tasks, taskStatus{
+			Name:     task.name,
+			Interval: task.intervalValue().String(),
+			Enabled:  task.enabled(),
+			Paused:   isPaused[task.name],
+			Standby:  task.standby,
+			Last:     task.last,
+		})
+	}
+	backgroundMu.Unlock()
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "private")
+	return json.NewEncoder(resp).Encode(map[string]interface{}{"results": tasks})
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminAction(t *testing.T) {
+	setTestTokens(t, "view viewer\nops operator\n")
+	for _, tt := range []struct {
+		method, token, body string
+		role                role
+		status              int
+	}{
+		{"GET", "ops", "path=a/b", roleOperator, http.StatusMethodNotAllowed},
+		{"POST", "", "path=a/b", roleOperator, http.StatusUnauthorized},
+		{"POST", "view", "path=a/b", roleOperator, http.StatusForbidden},
+		{"POST", "ops", "path=a/b", roleAdmin, http.StatusForbidden},
+		{"POST", "ops", "path=/", roleOperator, http.StatusBadRequest},
+		{"POST", "ops", "path=/a/b/", roleOperator, 0},
+	} {
+		req := httptest.NewRequest(tt.method, "http://godoc.org/-/admin/crawl", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		req.ParseForm()
+		importPath, err := adminAction(req, tt.role)
+		status := 0
+		if e, ok := err.(*httpError); ok {
+			status = e.status
+		} else if err != nil {
+			t.Errorf("%s %s %q: %v", tt.method, tt.token, tt.body, err)
+			continue
+		}
+		if status != tt.status {
+			t.Errorf("%s %s %q: status %d, want %d", tt.method, tt.token, tt.body, status, tt.status)
+		}
+		if status == 0 && importPath != "a/b" {
+			t.Errorf("adminAction() = %q, want a/b", importPath)
+		}
+	}
+}
+
+func TestFindBackgroundTask(t *testing.T) {
+	if task := findBackgroundTask("Crawl"); task == nil || task.name != "Crawl" {
+		t.Errorf("findBackgroundTask(Crawl) = %v", task)
+	}
+	if task := findBackgroundTask("crawl"); task != nil {
+		t.Errorf("findBackgroundTask(crawl) = %v, want nil", task)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/gosrc"
+)
+
+var allowPrefixes = flag.String("allow_prefixes", "", "Comma separated list of import path prefixes to serve. Use std for the standard library. If set, other packages are not found and are never fetched.")
+
+// allowList is the parsed value of the -allow_prefixes flag. A nil list
+// allows all packages.
+var allowList []string
+
+func setAllowList(spec string) {
+	allowList = nil
+	for _, p := range strings.Split(spec, ",") {
+		p = strings.Trim(strings.TrimSpace(p), "/")
+		if p != "" {
+			allowList = append(allowList, p)
+		}
+	}
+}
+
+// isAllowed returns true if the package with the given import path is in the
+// corpus configured by the -allow_prefixes flag.
+func isAllowed(importPath string) bool {
+	if allowList == nil {
+		return true
+	}
+	for _, p := range allowList {
+		switch {
+		case p == "std":
+			if gosrc.IsGoRepoPath(importPath) {
+				return true
+			}
+		case importPath == p || strings.HasPrefix(importPath, p+"/"):
+			return true
+		}
+	}
+	return false
+}
+
+// filterAllowed removes the packages that are not allowed from pkgs.
+func filterAllowed(pkgs []database.Package) []database.Package {
+	if allowList == nil {
+		return pkgs
+	}
+	result := pkgs[:0]
+	for _, pkg := range pkgs {
+		if isAllowed(pkg.Path) {
+			result = append(result, pkg)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+var isAllowedTests = []struct {
+	importPath string
+	allowed    bool
+}{
+	{"fmt", true},
+	{"net/http", true},
+	{"example.com/corp", true},
+	{"example.com/corp/pkg", true},
+	{"example.com/corporate", false},
+	{"github.com/alice/pkg", true},
+	{"github.com/alice/pkg/sub", true},
+	{"github.com/alice/other", false},
+	{"github.com/bob/pkg", false},
+}
+
+func TestIsAllowed(t *testing.T) {
+	defer setAllowList("")
+
+	setAllowList("")
+	for _, tt := range isAllowedTests {
+		if !isAllowed(tt.importPath) {
+			t.Errorf("isAllowed(%q) = false with empty allow list, want true", tt.importPath)
+		}
+	}
+
+	setAllowList("std, example.com/corp/,github.com/alice/pkg")
+	for _, tt := range isAllowedTests {
+		if allowed := isAllowed(tt.importPath); allowed != tt.allowed {
+			t.Errorf("isAllowed(%q) = %v, want %v", tt.importPath, allowed, tt.allowed)
+		}
+	}
+
+	pkgs := filterAllowed([]database.Package{{Path: "github.com/bob/pkg"}, {Path: "github.com/alice/pkg"}})
+	if len(pkgs) != 1 || pkgs[0].Path != "github.com/alice/pkg" {
+		t.Errorf("filterAllowed() = %v, want [github.com/alice/pkg]", pkgs)
+	}
+}
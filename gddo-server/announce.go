@@ -0,0 +1,162 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+// The announcements in the database are shown as a banner on all the pages
+// between their start and end times. Each replica reads the announcements
+// at startup and every -announcement_interval; the replica changing the
+// announcements with the administrative API reads them immediately. The
+// page cache is reset when the active announcements change.
+
+var announcements struct {
+	mu  sync.Mutex
+	all []database.Announcement
+
+	// active is the key of the announcements active at the last refresh.
+	active string
+}
+
+// refreshAnnouncements reads the announcements from the database and resets
+// the page cache if the active announcements changed.
+func refreshAnnouncements() error {
+	all, err := db.Announcements()
+	if err != nil {
+		return err
+	}
+	announcements.mu.Lock()
+	announcements.all = all
+	active := announcementsKey(activeAnnouncementsLocked(time.Now()))
+	changed := active != announcements.active
+	announcements.active = active
+	announcements.mu.Unlock()
+	if changed {
+		pages.reset()
+	}
+	return nil
+}
+
+func activeAnnouncementsLocked(now time.Time) []database.Announcement {
+	var active []database.Announcement
+	for _, a := range announcements.all {
+		if a.Active(now) {
+			active = append(active, a)
+		}
+	}
+	return active
+}
+
+// activeAnnouncements returns the announcements shown on the pages now.
+func activeAnnouncements() []database.Announcement {
+	announcements.mu.Lock()
+	defer announcements.mu.Unlock()
+	return activeAnnouncementsLocked(time.Now())
+}
+
+// announcementsKey returns a string identifying the announcements for the
+// entity tags of the pages.
+func announcementsKey(active []database.Announcement) string {
+	ids := make([]string, len(active))
+	for i, a := range active {
+		ids[i] = a.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+// alertClassFn returns the Bootstrap alert class of the severity of an
+// announcement.
+func alertClassFn(severity string) string {
+	switch severity {
+	case database.SeverityCritical:
+		return "alert-danger"
+	case database.SeverityWarning:
+		return "alert-warning"
+	}
+	return "alert-info"
+}
+
+// parseAnnouncementTime parses the RFC 3339 time of the form value. An empty
+// value is the zero time.
+func parseAnnouncementTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// serveAdminAnnouncements serves the announcements as JSON to requests with
+// the viewer role. A POST request adds the announcement with the message,
+// severity, start and end parameters and a DELETE request removes the
+// announcement with the id parameter. The changes require the operator
+// role.
+func serveAdminAnnouncements(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRole(req, roleViewer); err != nil {
+		return err
+	}
+	switch req.Method {
+	case "GET", "HEAD":
+	case "POST", "DELETE":
+		if err := checkRole(req, roleOperator); err != nil {
+			return err
+		}
+		if *readOnly {
+			return errReadOnly
+		}
+		action, target := "unannounce", req.Form.Get("id")
+		if req.Method == "POST" {
+			start, err := parseAnnouncementTime(req.Form.Get("start"))
+			if err != nil {
+				return &httpError{status: http.StatusBadRequest, err: err}
+			}
+			end, err := parseAnnouncementTime(req.Form.Get("end"))
+			if err != nil {
+				return &httpError{status: http.StatusBadRequest, err: err}
+			}
+			action = "announce"
+			target, err = db.AddAnnouncement(database.Announcement{
+				Message:  req.Form.Get("message"),
+				Severity: req.Form.Get("severity"),
+				Start:    start,
+				End:      end,
+			})
+			if err != nil {
+				return &httpError{status: http.StatusBadRequest, err: err}
+			}
+		} else if target == "" {
+			return &httpError{status: http.StatusBadRequest}
+		} else if ok, err := db.RemoveAnnouncement(target); err != nil {
+			return err
+		} else if !ok {
+			return &httpError{status: http.StatusNotFound}
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: adminActor(req), Action: action, Target: target, Reason: req.Form.Get("reason")}); err != nil {
+			return err
+		}
+		if err := refreshAnnouncements(); err != nil {
+			return err
+		}
+	default:
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+
+	all, err := db.Announcements()
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "private")
+	return json.NewEncoder(resp).Encode(map[string]interface{}{"results": all})
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+func TestAnnouncementBanner(t *testing.T) {
+	defer func(all []database.Announcement) { announcements.all = all }(announcements.all)
+	now := time.Now()
+	announcements.all = []database.Announcement{
+		{ID: "1", Message: "Maintenance <tonight>", Severity: database.SeverityCritical, Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{ID: "2", Message: "Old notice", End: now.Add(-time.Hour)},
+		{ID: "3", Message: "New policy", Severity: database.SeverityInfo},
+	}
+	if key := announcementsKey(activeAnnouncements()); key != "1,3" {
+		t.Errorf("active announcements = %s, want 1,3", key)
+	}
+
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{{"about.html", "common.html", "layout.html"}}); err != nil {
+		t.Fatal(err)
+	}
+	resp := httptest.NewRecorder()
+	if err := executeTemplate(resp, "about.html", http.StatusOK, nil, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	body := resp.Body.String()
+	for _, want := range []string{`<div class="alert alert-danger" role="alert">Maintenance &lt;tonight&gt;</div>`, `<div class="alert alert-info" role="status">New policy</div>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %s\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Old notice") {
+		t.Errorf("page contains the ended announcement")
+	}
+}
+
+func TestServeAdminAnnouncementsUnauthorized(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://godoc.org/-/admin/announcements?message=hello", nil)
+	req.ParseForm()
+	err := serveAdminAnnouncements(httptest.NewRecorder(), req)
+	if e, ok := err.(*httpError); !ok || e.status != http.StatusUnauthorized {
+		t.Errorf("err = %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var requireAPIKeys = flag.Bool("require_api_keys", true, "Require an API key created with gddo-admin create-key or a token in the -api_tokens file for the bulk API endpoints.")
+
+// checkAPIKey returns an error if the request to a bulk API endpoint does not
+// have a bearer API key or the key exceeds its daily quota. Requests with a
+// token in the -api_tokens file do not use a quota. The quota is not used in
+// read-only mode.
+func checkAPIKey(resp http.ResponseWriter, req *http.Request) error {
+	if !*requireAPIKeys || validAPIToken(req) {
+		return nil
+	}
+	unauthorized := &httpError{
+		status: http.StatusUnauthorized,
+		header: http.Header{"Www-Authenticate": {`Bearer realm="api"`}},
+	}
+	key := bearerToken(req)
+	if key == "" {
+		return unauthorized
+	}
+	k, err := db.APIKey(key)
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		return unauthorized
+	}
+	if *readOnly {
+		return nil
+	}
+	used, err := db.UseAPIKey(key)
+	if err != nil {
+		return err
+	}
+	if k.Quota == 0 {
+		return nil
+	}
+	header := quotaHeader(k.Quota, used, time.Now())
+	if used > k.Quota {
+		return &httpError{status: http.StatusTooManyRequests, header: header}
+	}
+	for name, v := range header {
+		if name != "Retry-After" {
+			resp.Header()[name] = v
+		}
+	}
+	return nil
+}
+
+// quotaHeader returns the headers reporting the use of a daily quota at time
+// now. The quota is reset at midnight UTC.
+func quotaHeader(quota, used int, now time.Time) http.Header {
+	remaining := quota - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	now = now.UTC()
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return http.Header{
+		"X-Ratelimit-Limit":     {strconv.Itoa(quota)},
+		"X-Ratelimit-Remaining": {strconv.Itoa(remaining)},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+		"Retry-After":           {strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds())))},
+	}
+}
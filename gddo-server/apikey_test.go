@@ -0,0 +1,48 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuotaHeader(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		quota, used int
+		remaining   string
+	}{
+		{100, 1, "99"},
+		{100, 100, "0"},
+		{100, 150, "0"},
+	} {
+		h := quotaHeader(tt.quota, tt.used, now)
+		if got := h.Get("X-RateLimit-Remaining"); got != tt.remaining {
+			t.Errorf("quotaHeader(%d, %d) remaining = %q, want %q", tt.quota, tt.used, got, tt.remaining)
+		}
+		if got := h.Get("Retry-After"); got != "3600" {
+			t.Errorf("Retry-After = %q, want 3600", got)
+		}
+		if got := h.Get("X-RateLimit-Reset"); got != "1709337600" {
+			t.Errorf("X-RateLimit-Reset = %q, want 1709337600", got)
+		}
+	}
+}
+
+func TestCheckAPIKeyUnauthorized(t *testing.T) {
+	h := apiHandler(func(resp http.ResponseWriter, req *http.Request) error {
+		return checkAPIKey(resp, req)
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/packages", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("request without key: status %d, header %v; want 401 with WWW-Authenticate", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements version 1 of the JSON API. The API is served at
+// /api/v1/ with the endpoints:
+//
+//  /api/v1/search?q=query
+//  /api/v1/packages/<import path>
+//  /api/v1/packages/<import path>/-/funcs
+//  /api/v1/packages/<import path>/-/types
+//  /api/v1/packages/<import path>/-/importers
+//  /api/v1/packages/<import path>/-/imports
+//  /api/v1/packages/<import path>/-/coverage
+//  /api/v1/pkg/<import path>/symbols
+//  /api/v1/sync?cursor=cursor
+//  /api/v1/notes?prefix=prefix&tag=tag
+//  /api/v1/watches
+//
+// List endpoints are paginated with the page and per_page parameters. Search
+// results are also paginated with the cursor and per_page parameters: pass an
+// empty cursor for the first page and the nextCursor of the response for the
+// following pages. The schemas of the responses do not change within a
+// version of the API.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+const (
+	apiV1PerPage    = 100
+	apiV1MaxPerPage = 1000
+)
+
+// corsHandler serves an API endpoint that allows cross-origin requests.
+type corsHandler func(resp http.ResponseWriter, req *http.Request) error
+
+func (h corsHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Access-Control-Allow-Origin", "*")
+	resp.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	resp.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	if req.Method == "OPTIONS" {
+		resp.Header().Set("Access-Control-Max-Age", "86400")
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+	runHandler(resp, req, h, handleAPIError)
+}
+
+// apiV1Page describes the page of results in a list response.
+type apiV1Page struct {
+	Page    int `json:"page"`
+	PerPage int `json:"perPage"`
+	Total   int `json:"total"`
+}
+
+// paginate returns the bounds of the requested page in a list of n results
+// and sets the Link header for the previous and next pages.
+func paginate(resp http.ResponseWriter, req *http.Request, n int) (start, end int, p apiV1Page, err error) {
+	p = apiV1Page{Page: 1, PerPage: apiV1PerPage, Total: n}
+	if s := req.Form.Get("page"); s != "" {
+		p.Page, err = strconv.Atoi(s)
+		if err != nil || p.Page < 1 {
+			return 0, 0, p, &httpError{status: http.StatusBadRequest}
+		}
+	}
+	if s := req.Form.Get("per_page"); s != "" {
+		p.PerPage, err = strconv.Atoi(s)
+		if err != nil || p.PerPage < 1 || p.PerPage > apiV1MaxPerPage {
+			return 0, 0, p, &httpError{status: http.StatusBadRequest}
+		}
+	}
+
+	// The page is checked before multiplying to not overflow.
+	start = n
+	if p.Page-1 <= n/p.PerPage {
+		start = (p.Page - 1) * p.PerPage
+	}
+	if start > n {
+		start = n
+	}
+	end = start + p.PerPage
+	if end > n {
+		end = n
+	}
+
+	var links []string
+	link := func(page int, rel string) {
+		u := *req.URL
+		q := u.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", strconv.Itoa(p.PerPage))
+		u.RawQuery = q.Encode()
+		links = append(links, "<"+u.RequestURI()+`>; rel="`+rel+`"`)
+	}
+	if p.Page > 1 && start > 0 {
+		link(p.Page-1, "prev")
+	}
+	if end < n {
+		link(p.Page+1, "next")
+	}
+	if links != nil {
+		resp.Header().Set("Link", strings.Join(links, ", "))
+	}
+	return start, end, p, nil
+}
+
+// writeAPIV1List writes a page of results. The results must be the slice of
+// the page returned by paginate.
+func writeAPIV1List(resp http.ResponseWriter, p apiV1Page, results interface{}) error {
+	data := struct {
+		apiV1Page
+		Results interface{} `json:"results"`
+	}{
+		p,
+		results,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
+
+func writeAPIV1Packages(resp http.ResponseWriter, req *http.Request, pkgs []database.Package) error {
+	start, end, p, err := paginate(resp, req, len(pkgs))
+	if err != nil {
+		return err
+	}
+	results := pkgs[start:end]
+	if results == nil {
+		results = []database.Package{}
+	}
+	return writeAPIV1List(resp, p, results)
+}
+
+type apiV1Package struct {
+	ImportPath  string    `json:"importPath"`
+	Name        string    `json:"name"`
+	Synopsis    string    `json:"synopsis"`
+	Doc         string    `json:"doc"`
+	IsCmd       bool      `json:"isCmd"`
+	ProjectRoot string    `json:"projectRoot"`
+	ProjectName string    `json:"projectName"`
+	ProjectURL  string    `json:"projectURL"`
+	Version     string    `json:"version"`
+	Updated     time.Time `json:"updated"`
+	Imports     []string  `json:"imports"`
+	TestImports []string  `json:"testImports"`
+	Consts      []apiDecl `json:"consts"`
+	Vars        []apiDecl `json:"vars"`
+	Funcs       []apiDecl `json:"funcs"`
+	Types       []apiType `json:"types"`
+}
+
+// apiV1Coverage is the documentation coverage of a package. CI jobs can
+// compare Percent with a minimum to fail builds when the coverage regresses.
+type apiV1Coverage struct {
+	Exported     int                     `json:"exported"`
+	Documented   int                     `json:"documented"`
+	Percent      int                     `json:"percent"`
+	Undocumented []apiV1UndocumentedDecl `json:"undocumented"`
+}
+
+type apiV1UndocumentedDecl struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+func apiV1Types(types []*doc.Type) []apiType {
+	result := []apiType{}
+	for _, t := range types {
+		result = append(result, apiType{
+			apiDecl: apiDecl{Name: t.Name, Decl: t.Decl.Text, Doc: t.Doc},
+			Consts:  apiValues(t.Consts),
+			Vars:    apiValues(t.Vars),
+			Funcs:   apiFuncs(t.Funcs),
+			Methods: apiFuncs(t.Methods),
+		})
+	}
+	return result
+}
+
+// nonNil returns an empty slice for nil so that lists are encoded as [].
+func nonNil(decls []apiDecl) []apiDecl {
+	if decls == nil {
+		return []apiDecl{}
+	}
+	return decls
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func serveAPIV1(resp http.ResponseWriter, req *http.Request) error {
+	p := strings.TrimPrefix(req.URL.Path, "/api/v1/")
+	if p == "watches" {
+		return serveAPIV1Watches(resp, req)
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+	switch {
+	case p == "search":
+		return serveAPIV1Search(resp, req)
+	case p == "sync":
+		return serveAPIV1Sync(resp, req)
+	case p == "notes":
+		return serveAPIV1Notes(resp, req)
+	case p == "compare":
+		return serveAPIV1Compare(resp, req)
+	case strings.HasPrefix(p, "pkg/") && strings.HasSuffix(p, "/symbols"):
+		return serveAPIV1Symbols(resp, req, strings.TrimSuffix(strings.TrimPrefix(p, "pkg/"), "/symbols"))
+	case strings.HasPrefix(p, "packages/"):
+		importPath, resource := strings.TrimPrefix(p, "packages/"), ""
+		if i := strings.Index(importPath, "/-/"); i >= 0 {
+			importPath, resource = importPath[:i], importPath[i+len("/-/"):]
+		}
+		return serveAPIV1Package(resp, req, importPath, resource)
+	}
+	return &httpError{status: http.StatusNotFound}
+}
+
+func serveAPIV1Search(resp http.ResponseWriter, req *http.Request) error {
+	q := strings.TrimSpace(req.Form.Get("q"))
+	if q == "" {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	if err := checkRate(req.Context(), searchLimiter); err != nil {
+		return err
+	}
+	if _, ok := req.Form["cursor"]; ok {
+		return serveAPIV1SearchCursor(resp, req, q)
+	}
+	pkgs, err := db.Query(q)
+	if err == nil {
+		pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+	}
+	if err != nil {
+		return err
+	}
+	return writeAPIV1Packages(resp, req, pkgs)
+}
+
+// serveAPIV1SearchCursor serves a page of search results after the position
+// in the cursor parameter. An empty cursor requests the first page. Unlike
+// page numbers, cursors do not repeat or skip results when the index changes
+// between requests.
+func serveAPIV1SearchCursor(resp http.ResponseWriter, req *http.Request, q string) error {
+	perPage, err := cursorPerPage(req)
+	if err != nil {
+		return err
+	}
+	pkgs, next, err := db.QueryPage(q, req.Form.Get("cursor"), perPage, func(pkgs []database.Package) ([]database.Package, error) {
+		return filterPrivate(req, filterAllowed(pkgs))
+	})
+	if err == database.ErrBadCursor {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	if err != nil {
+		return err
+	}
+	if pkgs == nil {
+		pkgs = []database.Package{}
+	}
+	if next != "" {
+		resp.Header().Set("Link", "<"+cursorURI(req, next, perPage)+`>; rel="next"`)
+	}
+	data := struct {
+		PerPage    int                `json:"perPage"`
+		NextCursor string             `json:"nextCursor"`
+		Results    []database.Package `json:"results"`
+	}{
+		perPage,
+		next,
+		pkgs,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
+
+func cursorPerPage(req *http.Request) (int, error) {
+	s := req.Form.Get("per_page")
+	if s == "" {
+		return apiV1PerPage, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > apiV1MaxPerPage {
+		return 0, &httpError{status: http.StatusBadRequest}
+	}
+	return n, nil
+}
+
+// cursorURI returns the URI of the page of results after cursor.
+func cursorURI(req *http.Request, cursor string, perPage int) string {
+	u := *req.URL
+	q := u.Query()
+	q.Set("cursor", cursor)
+	q.Set("per_page", strconv.Itoa(perPage))
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+func serveAPIV1Package(resp http.ResponseWriter, req *http.Request, importPath, resource string) error {
+	pdoc, _, err := getDoc(req.Context(), importPath, apiRequest)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	switch resource {
+	case "":
+		data := apiV1Package{
+			ImportPath:  pdoc.ImportPath,
+			Name:        pdoc.Name,
+			Synopsis:    pdoc.Synopsis,
+			Doc:         pdoc.Doc,
+			IsCmd:       pdoc.IsCmd,
+			ProjectRoot: pdoc.ProjectRoot,
+			ProjectName: pdoc.ProjectName,
+			ProjectURL:  pdoc.ProjectURL,
+			Version:     pdoc.Version,
+			Updated:     pdoc.Updated,
+			Imports:     nonNilStrings(pdoc.Imports),
+			TestImports: nonNilStrings(pdoc.TestImports),
+			Consts:      nonNil(apiValues(pdoc.Consts)),
+			Vars:        nonNil(apiValues(pdoc.Vars)),
+			Funcs:       nonNil(apiFuncs(pdoc.Funcs)),
+			Types:       apiV1Types(pdoc.Types),
+		}
+		resp.Header().Set("Content-Type", jsonMIMEType)
+		return json.NewEncoder(resp).Encode(&data)
+	case "funcs":
+		funcs := nonNil(apiFuncs(pdoc.Funcs))
+		start, end, p, err := paginate(resp, req, len(funcs))
+		if err != nil {
+			return err
+		}
+		return writeAPIV1List(resp, p, funcs[start:end])
+	case "types":
+		types := apiV1Types(pdoc.Types)
+		start, end, p, err := paginate(resp, req, len(types))
+		if err != nil {
+			return err
+		}
+		return writeAPIV1List(resp, p, types[start:end])
+	case "coverage":
+		c := pdoc.Coverage()
+		data := apiV1Coverage{
+			Exported:     c.Exported,
+			Documented:   c.Documented,
+			Percent:      c.Percent(),
+			Undocumented: []apiV1UndocumentedDecl{},
+		}
+		for _, d := range c.Undocumented {
+			u := apiV1UndocumentedDecl{Kind: d.Kind, Name: d.Name}
+			if d.Pos.Line != 0 && int(d.Pos.File) < len(pdoc.Files) {
+				u.File = pdoc.Files[d.Pos.File].Name
+				u.Line = int(d.Pos.Line)
+			}
+			data.Undocumented = append(data.Undocumented, u)
+		}
+		resp.Header().Set("Content-Type", jsonMIMEType)
+		return json.NewEncoder(resp).Encode(&data)
+	case "importers":
+		if err := checkAPIKey(resp, req); err != nil {
+			return err
+		}
+		pkgs, err := db.Importers(pdoc.ImportPath)
+		if err == nil {
+			pkgs, err = filterPrivate(req, pkgs)
+		}
+		if err != nil {
+			return err
+		}
+		return writeAPIV1Packages(resp, req, pkgs)
+	case "imports":
+		pkgs, err := db.Packages(pdoc.Imports)
+		if err == nil {
+			pkgs, err = filterPrivate(req, pkgs)
+		}
+		if err != nil {
+			return err
+		}
+		return writeAPIV1Packages(resp, req, pkgs)
+	}
+	return &httpError{status: http.StatusNotFound}
+}
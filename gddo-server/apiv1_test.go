@@ -0,0 +1,82 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var paginateTests = []struct {
+	query      string
+	n          int
+	start, end int
+	link       string
+	status     int
+}{
+	{"", 5, 0, 5, "", 0},
+	{"per_page=2", 5, 0, 2, `</api/v1/search?page=2&per_page=2&q=x>; rel="next"`, 0},
+	{"page=2&per_page=2", 5, 2, 4, `</api/v1/search?page=1&per_page=2&q=x>; rel="prev", </api/v1/search?page=3&per_page=2&q=x>; rel="next"`, 0},
+	{"page=9&per_page=2", 5, 5, 5, `</api/v1/search?page=8&per_page=2&q=x>; rel="prev"`, 0},
+	{"page=9223372036854775807&per_page=100", 5, 5, 5, `</api/v1/search?page=9223372036854775806&per_page=100&q=x>; rel="prev"`, 0},
+	{"page=0", 5, 0, 0, "", http.StatusBadRequest},
+	{"per_page=5000", 5, 0, 0, "", http.StatusBadRequest},
+}
+
+func TestPaginate(t *testing.T) {
+	for _, tt := range paginateTests {
+		req, err := http.NewRequest("GET", "/api/v1/search?q=x&"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.ParseForm()
+		resp := httptest.NewRecorder()
+		start, end, _, err := paginate(resp, req, tt.n)
+		if tt.status != 0 {
+			if e, ok := err.(*httpError); !ok || e.status != tt.status {
+				t.Errorf("%q: paginate returned error %v, want status %d", tt.query, err, tt.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: paginate returned error %v", tt.query, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("%q: paginate = %d, %d; want %d, %d", tt.query, start, end, tt.start, tt.end)
+		}
+		if link := resp.Header().Get("Link"); link != tt.link {
+			t.Errorf("%q: Link = %q, want %q", tt.query, link, tt.link)
+		}
+	}
+}
+
+func TestAPIV1Preflight(t *testing.T) {
+	req, err := http.NewRequest("OPTIONS", "/api/v1/search", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := httptest.NewRecorder()
+	corsHandler(serveAPIV1).ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.Code, http.StatusNoContent)
+	}
+	if origin := resp.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", origin)
+	}
+}
+
+func TestCursorURI(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/search?q=x&cursor=", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri, want := cursorURI(req, "abc", 10), "/api/v1/search?cursor=abc&per_page=10&q=x"; uri != want {
+		t.Errorf("cursorURI = %q, want %q", uri, want)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+var archiveMaxPackages = flag.Int("archive_max_packages", 2000, "Maximum number of packages in a documentation archive.")
+
+// packagesUnder returns the sorted import paths of the allowed packages at or
+// under the import path prefix. The packages of accounts such as
+// github.com/user are found in all of the repositories of the account.
+func packagesUnder(prefix string) ([]string, error) {
+	var pkgs []database.Package
+	if isAccountPath(prefix) {
+		apkgs, err := db.AccountPackages(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range apkgs {
+			pkgs = append(pkgs, database.Package{Path: pkg.Path})
+		}
+	} else {
+		pdoc, subdirs, _, err := db.Get(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if pdoc != nil && pdoc.Name != "" {
+			pkgs = append(pkgs, database.Package{Path: pdoc.ImportPath})
+		}
+		pkgs = append(pkgs, subdirs...)
+	}
+	var paths []string
+	for _, pkg := range filterAllowed(pkgs) {
+		if pkg.Path == prefix || strings.HasPrefix(pkg.Path, prefix+"/") {
+			paths = append(paths, pkg.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// serveArchive serves a zip archive with the exported documentation of the
+// packages under the import path prefix. Requests with a token in the
+// -api_tokens file get every package. Otherwise the archive has the packages
+// in the projects with the owner key.
+func serveArchive(resp http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+	prefix := strings.Trim(req.Form.Get("prefix"), "/")
+	if prefix == "" {
+		return &httpError{status: http.StatusNotFound}
+	}
+	authenticated := isAuthenticated(req)
+	key := req.Form.Get("key")
+	if !authenticated && key == "" {
+		return &httpError{
+			status: http.StatusUnauthorized,
+			header: http.Header{"Www-Authenticate": {`Bearer realm="api"`}},
+		}
+	}
+
+	paths, err := packagesUnder(prefix)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	owned := make(map[string]bool)
+	n := 0
+	for _, p := range paths {
+		if n >= *archiveMaxPackages {
+			break
+		}
+		pdoc, _, err := db.GetDoc(p)
+		if err != nil {
+			return err
+		}
+		if pdoc == nil || pdoc.Name == "" {
+			continue
+		}
+		if !authenticated {
+			ok, seen := owned[pdoc.ProjectRoot]
+			if !seen {
+				switch err := checkOwnerKey(pdoc.ProjectRoot, key); err {
+				case nil:
+					ok = true
+				case errBadOwnerKey:
+				default:
+					return err
+				}
+				owned[pdoc.ProjectRoot] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		if err := addArchiveFile(zw, req, pdoc); err != nil {
+			return err
+		}
+		n++
+	}
+	if n == 0 {
+		return &httpError{status: http.StatusForbidden}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	logger(req.Context()).Info("served documentation archive", "prefix", prefix, "packages", n)
+
+	resp.Header().Set("Content-Type", "application/zip")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", strings.Replace(prefix, "/", "_", -1)+".zip"))
+	resp.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	resp.Header().Set("Cache-Control", "private")
+	_, err = resp.Write(buf.Bytes())
+	return err
+}
+
+// addArchiveFile adds the exported documentation of the package to the
+// archive as IMPORTPATH/index.html.
+func addArchiveFile(zw *zip.Writer, req *http.Request, pdoc *doc.Package) error {
+	page, err := renderExport(pageURI(req, pdoc.ImportPath), defaultLanguage, map[string]interface{}{"pdoc": newTDoc(pdoc)})
+	if err != nil {
+		return err
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     pdoc.ImportPath + "/index.html",
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(page)
+	return err
+}
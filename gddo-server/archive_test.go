@@ -0,0 +1,78 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestServeArchiveErrors(t *testing.T) {
+	for _, tt := range []struct {
+		method, body string
+		status       int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "key=k", http.StatusNotFound},
+		{"POST", "prefix=/", http.StatusNotFound},
+		{"POST", "prefix=github.com/user", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(tt.method, "http://godoc.org/-/archive", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.ParseForm()
+		err := serveArchive(httptest.NewRecorder(), req)
+		if e, ok := err.(*httpError); !ok || e.status != tt.status {
+			t.Errorf("%s %q: err = %v, want status %d", tt.method, tt.body, err, tt.status)
+		}
+	}
+}
+
+func TestAddArchiveFile(t *testing.T) {
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{{"export.html", "pkg.html", "common.html"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	req := httptest.NewRequest("POST", "http://godoc.org/-/archive", nil)
+	pdoc := &doc.Package{ImportPath: "github.com/user/repo/sub", ProjectRoot: "github.com/user/repo", Name: "sub"}
+	if err := addArchiveFile(zw, req, pdoc); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "github.com/user/repo/sub/index.html" {
+		t.Fatalf("files = %v", zr.File)
+	}
+	r, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(p, []byte("https://godoc.org/github.com/user/repo/sub")) {
+		t.Errorf("page does not link to the package:\n%s", p)
+	}
+}
@@ -10,6 +10,44 @@ $(function() {
     var $filter = $('#x-jump-filter');
     var $modal = $('#x-jump');
 
+    // match returns the score of a fuzzy match of filter against the text
+    // and the text with the matched characters in bold, or null if the
+    // characters of filter do not appear in order in the text. Lower scores
+    // are better matches.
+    var match = function(id, filter) {
+        if (!filter) {
+            return {score: 0, html: id.text};
+        }
+        var lfilter = filter.toLowerCase();
+        var i = id.ltext.indexOf(lfilter);
+        if (i >= 0) {
+            // Prefer substring matches, and matches at the start of the
+            // identifier or of a method or field name.
+            var score = (i == 0 || id.text.charAt(i-1) == '.') ? 0 : 1;
+            return {
+                score: score,
+                html: id.text.substring(0, i) + '<b>' + id.text.substring(i, i + filter.length) + '</b>' + id.text.substring(i + filter.length)
+            };
+        }
+        var html = '', score = 2, last = -1, j = 0;
+        for (i = 0; i < id.text.length; i++) {
+            if (j < lfilter.length && id.ltext.charAt(i) == lfilter.charAt(j)) {
+                if (last >= 0) {
+                    score += i - last - 1;
+                }
+                last = i;
+                j++;
+                html += '<b>' + id.text.charAt(i) + '</b>';
+            } else {
+                html += id.text.charAt(i);
+            }
+        }
+        if (j < lfilter.length) {
+            return null;
+        }
+        return {score: score, html: html};
+    }
+
     var update = function(filter) {
         lastFilter = filter;
         if (active >= 0) {
@@ -17,19 +55,19 @@ $(function() {
             active = -1;
         }
         visible = []
-        var re = new RegExp(filter.replace(/([.*+?^=!:${}()|\[\]\/\\])/g, "\\$1"), "gi");
         all.forEach(function (id) {
             id.e.detach();
-            var text = id.text;
-            if (filter) {
-                text = id.text.replace(re, function (s) { return '<b>' + s + '</b>'; });
-                if (text == id.text) {
-                    return
-                }
+            var m = match(id, filter);
+            if (!m) {
+                return
             }
-            id.e.html(text + ' ' + '<i>' + id.kind + '</i>');
+            id.score = m.score;
+            id.e.html(m.html + ' ' + '<i>' + id.kind + '</i>');
             visible.push(id);
         });
+        visible.sort(function (a, b) {
+            return a.score - b.score || a.index - b.index;
+        });
         $body.scrollTop(0);
         if (visible.length > 0) {
             active = 0;
@@ -66,24 +104,20 @@ $(function() {
     $modal.on('show.bs.modal', function() {
         if (!all) {
             all = []
-            var kinds = {'c': 'constant', 'v': 'variable', 'f': 'function', 't': 'type', 'd': 'field', 'm': 'method'}
-            $('*[id]').each(function() {
-                var e = $(this);
-                var id = e.attr('id');
-                if (/^[^_][^-]*$/.test(id)) {
-                    all.push({
-                        text: id,
-                        ltext: id.toLowerCase(),
-                        kind: kinds[e.closest('[data-kind]').attr('data-kind')],
-                        e: $('<a/>', {href: '#' + id, 'class': 'list-group-item', tabindex: '-1'})
-                    });
-                }
+            $.each($modal.data('symbols') || [], function(i, symbol) {
+                all.push({
+                    text: symbol.id,
+                    ltext: symbol.id.toLowerCase(),
+                    kind: symbol.kind,
+                    e: $('<a/>', {href: '#' + symbol.id, 'class': 'list-group-item', tabindex: '-1'})
+                });
             });
             all.sort(function (a, b) {
                 if (a.ltext > b.ltext) { return 1; }
                 if (a.ltext < b.ltext) { return -1; }
                 return 0
             });
+            all.forEach(function (id, i) { id.index = i; });
         }
     }).on('shown.bs.modal', function() {
         update('');
@@ -129,7 +163,13 @@ $(function() {
             }
             highlightedSel = window.location.hash.replace( /(:|\.|\[|\]|,)/g, "\\$1" );
             if (highlightedSel && (highlightedSel.indexOf("example-") == -1)) {
-                $(highlightedSel).addClass("highlighted");
+                var el = $(highlightedSel).addClass("highlighted");
+                // Expand the collapsed declaration with the target field or method.
+                var pre = el.closest('pre.x-collapsed');
+                if (pre.length) {
+                    expandDecl(pre);
+                    el[0].scrollIntoView();
+                }
             }
         };
         window.onhashchange();
@@ -137,6 +177,18 @@ $(function() {
 
 });
 
+// collapsed type declarations
+function expandDecl(pre) {
+    pre.removeClass('x-collapsed').css('max-height', '').next('.x-expand').remove();
+}
+
+$(function() {
+    $(document).on('click', '.x-expand a', function(e) {
+        e.preventDefault();
+        expandDecl($(this).closest('.x-expand').prev('pre.x-collapsed'));
+    });
+});
+
 // keyboard shortcuts
 $(function() {
     var prevCh = null, prevTime = 0, modal = false;
@@ -194,6 +246,10 @@ $(function() {
 
         switch (ch) {
         case "/":
+            if ($('#x-jump').length > 0) {
+                $('#x-jump').modal();
+                return false;
+            }
             $('#x-search-query').focus();
             return false;
         case "?":
@@ -229,3 +285,192 @@ $(function() {
         offset: 10
     });
 });
+
+// run examples in the playground
+$(function() {
+    $(document).on('click', 'button.x-run', function() {
+        var $button = $(this);
+        var id = $button.attr('data-example');
+        var $output = $(document.getElementById('run-' + id));
+        var $pre = $output.find('pre');
+        $button.prop('disabled', true);
+        $output.prop('hidden', false);
+        $pre.text('Waiting for remote server...');
+        $.post(window.location.pathname + '?run=' + encodeURIComponent(id)).done(function(result) {
+            $pre.text(result.errors ? result.errors : result.output + '\nProgram exited.');
+        }).fail(function() {
+            $pre.text('Error communicating with remote server.');
+        }).always(function() {
+            $button.prop('disabled', false);
+        });
+    });
+});
+
+// high contrast theme
+$(function() {
+    var key = 'gddo-contrast';
+    function apply(on) {
+        $('body').toggleClass('contrast', on);
+        $('#x-contrast').attr('aria-pressed', on ? 'true' : 'false');
+    }
+    try {
+        apply(window.localStorage.getItem(key) === '1');
+    } catch (e) {}
+    $('#x-contrast').on('click', function(e) {
+        e.preventDefault();
+        var on = !$('body').hasClass('contrast');
+        apply(on);
+        try {
+            window.localStorage.setItem(key, on ? '1' : '0');
+        } catch (e) {}
+    });
+});
+
+// light and dark themes
+$(function() {
+    var names = {'': 'Auto', 'light': 'Light', 'dark': 'Dark'};
+    var next = {'': 'dark', 'dark': 'light', 'light': ''};
+    function current() {
+        return document.documentElement.getAttribute('data-theme') || '';
+    }
+    function apply(theme) {
+        if (theme) {
+            document.documentElement.setAttribute('data-theme', theme);
+            document.cookie = 'gddo-theme=' + theme + '; path=/; max-age=31536000';
+        } else {
+            document.documentElement.removeAttribute('data-theme');
+            document.cookie = 'gddo-theme=; path=/; max-age=0';
+        }
+        $('#x-theme').text('Theme: ' + names[theme]);
+    }
+    $('#x-theme').text('Theme: ' + names[current()]);
+    $('#x-theme').on('click', function(e) {
+        e.preventDefault();
+        apply(next[current()]);
+    });
+});
+
+// hide deprecated symbols
+$(function() {
+    var key = 'gddo-hide-deprecated';
+    function apply(on) {
+        $('body').toggleClass('hide-deprecated', on);
+        $('#x-hide-deprecated').prop('checked', on);
+    }
+    if ($('#x-hide-deprecated').length === 0) {
+        return;
+    }
+    try {
+        apply(window.localStorage.getItem(key) === '1');
+    } catch (e) {}
+    $('#x-hide-deprecated').on('change', function() {
+        var on = $(this).prop('checked');
+        apply(on);
+        try {
+            window.localStorage.setItem(key, on ? '1' : '0');
+        } catch (e) {}
+    });
+});
+
+// links to declarations in other sections of large packages
+$(function() {
+    if ($('#x-sections').length === 0) {
+        return;
+    }
+    $(document).on('click', 'a[href^="#"]', function(e) {
+        var id = $(this).attr('href').substring(1);
+        if (id === '' || document.getElementById(id) || !/^[A-Za-z]/.test(id)) {
+            return;
+        }
+        e.preventDefault();
+        window.location = '?section=' + encodeURIComponent(id.charAt(0).toUpperCase()) + '#' + id;
+    });
+});
+
+// search suggestions
+$(function() {
+    var list = $('#x-suggestions');
+    var timer = null;
+    var last = '';
+    $('input[list="x-suggestions"]').on('input', function() {
+        var q = $.trim($(this).val());
+        clearTimeout(timer);
+        if (q.length < 2 || q.indexOf(' ') >= 0 || q === last) {
+            return;
+        }
+        timer = setTimeout(function() {
+            last = q;
+            $.getJSON('/search/suggest', {q: q}, function(data) {
+                list.empty();
+                $.each(data[1] || [], function(i, path) {
+                    $('<option>').attr('value', path).attr('label', data[2][i] || '').appendTo(list);
+                });
+            });
+        }, 150);
+    });
+});
+
+// documentation updated banner
+$(function() {
+    var banner = $('#x-updated');
+    if (banner.length === 0 || !window.EventSource) {
+        return;
+    }
+    var events = new EventSource('/-/events?path=' + encodeURIComponent(banner.data('path')));
+    events.addEventListener('updated', function() {
+        events.close();
+        banner.show();
+    });
+});
+
+// CSRF token of the forms and requests changing the state of the server
+$(function() {
+    var m = document.cookie.match(/(?:^|; )csrf=([^;]*)/);
+    if (!m) {
+        return;
+    }
+    $('input[name=csrf]').val(m[1]);
+    $.ajaxSetup({
+        beforeSend: function(xhr, settings) {
+            if (!settings.crossDomain) {
+                xhr.setRequestHeader('X-CSRF-Token', m[1]);
+            }
+        }
+    });
+});
+
+// slides of the talks
+$(function() {
+    var slides = $('#x-slides .x-slide');
+    if (slides.length === 0) {
+        return;
+    }
+    var current = 0;
+    function show(i) {
+        current = Math.max(0, Math.min(slides.length - 1, i));
+        slides.hide().eq(current).show();
+        $('#x-slide-number').text((current + 1) + ' / ' + slides.length);
+        history.replaceState(null, '', '#' + (current + 1));
+    }
+    show((parseInt(location.hash.substring(1), 10) || 1) - 1);
+    $('#x-slide-prev').on('click', function() { show(current - 1); return false; });
+    $('#x-slide-next').on('click', function() { show(current + 1); return false; });
+    $(document).on('keydown', function(e) {
+        var t = e.target.tagName;
+        if (t == 'INPUT' || t == 'SELECT' || t == 'TEXTAREA' || e.altKey || e.ctrlKey || e.metaKey) {
+            return true;
+        }
+        switch (e.which) {
+        case 37: // left
+        case 33: // page up
+            show(current - 1);
+            return false;
+        case 39: // right
+        case 34: // page down
+        case 32: // space
+            show(current + 1);
+            return false;
+        }
+        return true;
+    });
+});
@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+const (
+	auditDefaultCount = 100
+	auditMaxCount     = 1000
+)
+
+// serveAudit serves the last entries of the audit log as JSON to requests
+// with an API token. The n parameter is the number of entries, the action
+// parameter selects the entries with the action and the prefix parameter
+// selects the entries with a target under the import path prefix.
+func serveAudit(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRole(req, roleViewer); err != nil {
+		return err
+	}
+	n := auditDefaultCount
+	if s := req.Form.Get("n"); s != "" {
+		var err error
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return &httpError{status: http.StatusBadRequest}
+		}
+		if n > auditMaxCount {
+			n = auditMaxCount
+		}
+	}
+	action := req.Form.Get("action")
+	prefix := strings.Trim(req.Form.Get("prefix"), "/")
+	entries, err := db.AuditLog(n, func(e *database.AuditEntry) bool {
+		return (action == "" || e.Action == action) &&
+			(prefix == "" || e.Target == prefix || strings.HasPrefix(e.Target, prefix+"/"))
+	})
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []database.AuditEntry{}
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "private")
+	return json.NewEncoder(resp).Encode(map[string]interface{}{"results": entries})
+}
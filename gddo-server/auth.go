@@ -0,0 +1,501 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Private instances can require users to sign in with an OAuth2 provider to
+// view the site and use the API: a generic OpenID Connect provider, Google
+// with an optional Workspace domain, or GitHub with an optional organization.
+// The groups of a user, the groups claim of the OIDC ID token or the teams
+// of the GitHub organization, select the users allowed to sign in and the
+// users allowed to view private packages. The server sets a signed session
+// cookie with the name and the groups of the user.
+
+var (
+	authProvider      = flag.String("auth_provider", "", "Require users to sign in to view the site and use the API: oidc, google or github. API requests are also allowed with an API key or a token in the -api_tokens file. Empty disables the sign in.")
+	authClientID      = flag.String("auth_client_id", "", "Client ID of the OAuth2 application of auth_provider.")
+	authClientSecret  = flag.String("auth_client_secret", "", "Client secret of the OAuth2 application of auth_provider.")
+	authIssuer        = flag.String("auth_issuer", "", "Issuer URL of the OpenID Connect provider for auth_provider oidc. The endpoints are read from the discovery document of the issuer.")
+	authDomain        = flag.String("auth_domain", "", "Google Workspace domain of the users allowed to sign in with auth_provider google. Empty allows all Google accounts.")
+	authGitHubOrg     = flag.String("auth_github_org", "", "GitHub organization of the users allowed to sign in with auth_provider github. The groups of the users are the slugs of their teams in the organization. Empty allows all GitHub users.")
+	authGroupsClaim   = flag.String("auth_groups_claim", "groups", "Claim of the OpenID Connect ID token with the groups of the user.")
+	authGroups        = flag.String("auth_groups", "", "Comma separated list of the groups of the users allowed to sign in. Empty allows all the users of the provider.")
+	authPrivateGroups = flag.String("auth_private_groups", "", "Comma separated list of the groups of the users allowed to view private packages. Empty allows all signed in users.")
+	authCallbackURL   = flag.String("auth_callback_url", "", "URL of /-/auth/callback registered as the redirect URI of the OAuth2 application. Defaults to the URL on the host of the request.")
+	authSessionMaxAge = flag.Duration("auth_session_max_age", 12*time.Hour, "Duration of the sessions of signed in users. The sessions are signed with owner_session_secret.")
+	authSecureCookies = flag.Bool("auth_secure_cookies", false, "Send the session cookies only over HTTPS also for requests received without TLS, such as behind a proxy terminating TLS.")
+)
+
+const (
+	// sessionCookie is the cookie with the signed name and groups of the
+	// signed in user.
+	sessionCookie = "session"
+
+	// authStateCookie is the cookie with the nonce of the sign in state,
+	// which binds the sign in to the browser that started it.
+	authStateCookie = "authstate"
+
+	// authStateMaxAge is the time to complete the sign in.
+	authStateMaxAge = 10 * time.Minute
+
+	// The prefixes of the signed session values and sign in states, which
+	// keep the values signed for owners from being used as sessions.
+	sessionPrefix   = "session:"
+	authStatePrefix = "auth:"
+)
+
+// googleIssuer is the issuer of the Google OpenID Connect provider, replaced
+// in tests.
+var googleIssuer = "https://accounts.google.com"
+
+var (
+	errAuthDenied  = errors.New("auth: user not allowed to sign in")
+	errBadIDToken  = errors.New("auth: bad ID token")
+	errNoProvider  = errors.New("auth: unknown provider")
+	authHTTPClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// authUser is a signed in user.
+type authUser struct {
+	Name   string
+	Groups []string
+}
+
+// inGroups returns true if the user is in one of the comma separated groups
+// or if there are no groups.
+func (u *authUser) inGroups(groups string) bool {
+	if strings.TrimSpace(groups) == "" {
+		return true
+	}
+	for _, g := range strings.Split(groups, ",") {
+		g = strings.TrimSpace(g)
+		for _, ug := range u.Groups {
+			if g != "" && g == ug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authSession returns the user signed in with the request or nil.
+func authSession(req *http.Request) *authUser {
+	if *authProvider == "" {
+		return nil
+	}
+	c, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	v, ok := verifyValue(c.Value)
+	if !ok || !strings.HasPrefix(v, sessionPrefix) {
+		return nil
+	}
+	fields := strings.Split(v[len(sessionPrefix):], "\n")
+	u := &authUser{Name: fields[0]}
+	if len(fields) > 1 && fields[1] != "" {
+		u.Groups = strings.Split(fields[1], ",")
+	}
+	return u
+}
+
+// secureCookies returns true if the cookies of the sign in are sent only
+// over HTTPS.
+func secureCookies(req *http.Request) bool {
+	return req.TLS != nil || *authSecureCookies
+}
+
+func setAuthSession(resp http.ResponseWriter, req *http.Request, u *authUser) {
+	value := sessionPrefix + u.Name + "\n" + strings.Join(u.Groups, ",")
+	http.SetCookie(resp, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    signValue(value, time.Now().Add(*authSessionMaxAge)),
+		Path:     "/",
+		MaxAge:   int(*authSessionMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   secureCookies(req),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// authEndpoints are the OAuth2 endpoints of the provider.
+type authEndpoints struct {
+	Issuer        string `json:"issuer"`
+	AuthorizeURL  string `json:"authorization_endpoint"`
+	TokenURL      string `json:"token_endpoint"`
+	OpenIDConnect bool   `json:"-"`
+}
+
+var oidcDiscovery struct {
+	mu        sync.Mutex
+	issuer    string
+	endpoints *authEndpoints
+}
+
+// providerEndpoints returns the endpoints of the -auth_provider. The
+// discovery document of an OpenID Connect provider is read once.
+func providerEndpoints() (*authEndpoints, error) {
+	issuer := *authIssuer
+	switch *authProvider {
+	case "github":
+		return &authEndpoints{AuthorizeURL: githubAuthorizeURL, TokenURL: githubTokenURL}, nil
+	case "google":
+		issuer = googleIssuer
+	case "oidc":
+	default:
+		return nil, errNoProvider
+	}
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	oidcDiscovery.mu.Lock()
+	defer oidcDiscovery.mu.Unlock()
+	if oidcDiscovery.endpoints != nil && oidcDiscovery.issuer == issuer {
+		return oidcDiscovery.endpoints, nil
+	}
+	resp, err := authHTTPClient.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: discovery of %s returned %d", issuer, resp.StatusCode)
+	}
+	var e authEndpoints
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return nil, err
+	}
+	if e.Issuer != issuer || e.AuthorizeURL == "" || e.TokenURL == "" {
+		return nil, fmt.Errorf("auth: bad discovery document of %s", issuer)
+	}
+	e.OpenIDConnect = true
+	oidcDiscovery.issuer = issuer
+	oidcDiscovery.endpoints = &e
+	return &e, nil
+}
+
+func authRedirectURI(req *http.Request) string {
+	if *authCallbackURL != "" {
+		return *authCallbackURL
+	}
+	return pageURI(req, "-/auth/callback")
+}
+
+// isLocalPath returns true if the redirect to next stays on the site.
+// Browsers treat a backslash like a slash, so /\host is another site.
+func isLocalPath(next string) bool {
+	return strings.HasPrefix(next, "/") && !strings.HasPrefix(next, "//") && !strings.HasPrefix(next, "/\\")
+}
+
+// serveAuthLogin redirects to the authorization page of the provider. The
+// next parameter is the page shown after the sign in. The signed state has
+// the nonce of the authstate cookie and the next page.
+func serveAuthLogin(resp http.ResponseWriter, req *http.Request) error {
+	e, err := providerEndpoints()
+	if err == errNoProvider {
+		return &httpError{status: http.StatusNotFound}
+	} else if err != nil {
+		return err
+	}
+	next := req.Form.Get("next")
+	if !isLocalPath(next) {
+		next = "/"
+	}
+	p := make([]byte, 16)
+	if _, err := rand.Read(p); err != nil {
+		return err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(p)
+	http.SetCookie(resp, &http.Cookie{
+		Name:     authStateCookie,
+		Value:    nonce,
+		Path:     "/-/auth/",
+		MaxAge:   int(authStateMaxAge / time.Second),
+		HttpOnly: true,
+		Secure:   secureCookies(req),
+		SameSite: http.SameSiteLaxMode,
+	})
+	q := url.Values{
+		"client_id":     {*authClientID},
+		"redirect_uri":  {authRedirectURI(req)},
+		"response_type": {"code"},
+		"state":         {signValue(authStatePrefix+nonce+"\n"+next, time.Now().Add(authStateMaxAge))},
+	}
+	if e.OpenIDConnect {
+		q.Set("scope", "openid email profile")
+	} else {
+		q.Set("scope", "read:org")
+	}
+	if *authProvider == "google" && *authDomain != "" {
+		q.Set("hd", *authDomain)
+	}
+	http.Redirect(resp, req, e.AuthorizeURL+"?"+q.Encode(), http.StatusFound)
+	return nil
+}
+
+// serveAuthCallback completes the sign in and starts the session of the user
+// if the user is allowed to sign in. The sign in must be started by the
+// browser with the nonce of the state in the authstate cookie.
+func serveAuthCallback(resp http.ResponseWriter, req *http.Request) error {
+	state, ok := verifyValue(req.Form.Get("state"))
+	if !ok || !strings.HasPrefix(state, authStatePrefix) {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	i := strings.Index(state, "\n")
+	if i < 0 {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	nonce, next := state[len(authStatePrefix):i], state[i+1:]
+	c, err := req.Cookie(authStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(nonce)) != 1 {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	http.SetCookie(resp, &http.Cookie{Name: authStateCookie, Value: "", Path: "/-/auth/", MaxAge: -1, HttpOnly: true, Secure: secureCookies(req)})
+	e, err := providerEndpoints()
+	if err == errNoProvider {
+		return &httpError{status: http.StatusNotFound}
+	} else if err != nil {
+		return err
+	}
+	u, err := authenticate(e, req.Form.Get("code"), authRedirectURI(req))
+	if err == nil && !u.inGroups(*authGroups) {
+		err = errAuthDenied
+	}
+	switch {
+	case err == errAuthDenied:
+		return &httpError{status: http.StatusForbidden, err: err}
+	case err != nil:
+		logger(req.Context()).Error("auth sign in", "provider", *authProvider, "err", err)
+		return &httpError{status: http.StatusBadGateway, err: err}
+	}
+	setAuthSession(resp, req, u)
+	http.Redirect(resp, req, next, http.StatusFound)
+	return nil
+}
+
+// serveAuthLogout ends the session of the signed in user.
+func serveAuthLogout(resp http.ResponseWriter, req *http.Request) error {
+	http.SetCookie(resp, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: secureCookies(req)})
+	http.Redirect(resp, req, "/", http.StatusFound)
+	return nil
+}
+
+// authenticate exchanges the code of an authorization for the signed in
+// user.
+func authenticate(e *authEndpoints, code, redirectURI string) (*authUser, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {*authClientID},
+		"client_secret": {*authClientSecret},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+	}
+	req, err := http.NewRequest("POST", e.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := authHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.Error != "" || token.AccessToken == "" {
+		return nil, fmt.Errorf("auth: token request failed: %s", token.Error)
+	}
+	if e.OpenIDConnect {
+		return idTokenUser(e.Issuer, token.IDToken, time.Now())
+	}
+	return githubUser(token.AccessToken)
+}
+
+// idTokenUser returns the user of an OpenID Connect ID token. The token is
+// received from the token endpoint over TLS, which validates the issuer in
+// place of the signature of the token.
+func idTokenUser(issuer, idToken string, now time.Time) (*authUser, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errBadIDToken
+	}
+	p, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errBadIDToken
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(p, &claims); err != nil {
+		return nil, errBadIDToken
+	}
+	str := func(name string) string {
+		s, _ := claims[name].(string)
+		return s
+	}
+	audOK := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audOK = aud == *authClientID
+	case []interface{}:
+		for _, a := range aud {
+			audOK = audOK || a == *authClientID
+		}
+	}
+	exp, _ := claims["exp"].(float64)
+	if str("iss") != issuer || !audOK || int64(exp) < now.Unix() {
+		return nil, errBadIDToken
+	}
+	if *authProvider == "google" && *authDomain != "" && str("hd") != *authDomain {
+		return nil, errAuthDenied
+	}
+
+	u := &authUser{Name: str("email")}
+	if u.Name == "" {
+		u.Name = str("sub")
+	}
+	if u.Name == "" || strings.Contains(u.Name, "\n") {
+		return nil, errBadIDToken
+	}
+	groups, _ := claims[*authGroupsClaim].([]interface{})
+	for _, g := range groups {
+		if s, ok := g.(string); ok && !strings.ContainsAny(s, ",\n") {
+			u.Groups = append(u.Groups, s)
+		}
+	}
+	return u, nil
+}
+
+// githubGet decodes the response of a GitHub API request with the access
+// token. A nil error with false is returned for status 404.
+func githubGet(token, path string, v interface{}) (bool, error) {
+	req, err := http.NewRequest("GET", githubAPIURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := authHTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound, http.StatusForbidden:
+		return false, nil
+	}
+	return false, fmt.Errorf("auth: GitHub API %s returned %d", path, resp.StatusCode)
+}
+
+// githubUser returns the GitHub user of the access token with the teams of
+// the user in -auth_github_org as groups.
+func githubUser(token string) (*authUser, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, err := githubGet(token, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.Login == "" {
+		return nil, errAuthDenied
+	}
+	u := &authUser{Name: user.Login}
+	if *authGitHubOrg == "" {
+		return u, nil
+	}
+
+	var membership struct {
+		State string `json:"state"`
+	}
+	if ok, err := githubGet(token, "/user/memberships/orgs/"+url.PathEscape(*authGitHubOrg), &membership); err != nil {
+		return nil, err
+	} else if !ok || membership.State != "active" {
+		return nil, errAuthDenied
+	}
+	var teams []struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if _, err := githubGet(token, "/user/teams?per_page=100", &teams); err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		if strings.EqualFold(t.Organization.Login, *authGitHubOrg) {
+			u.Groups = append(u.Groups, t.Slug)
+		}
+	}
+	return u, nil
+}
+
+// authHandler requires the requests to the site to have the session of a
+// signed in user or a token in the -api_tokens file. API requests are also
+// allowed with an API key.
+type authHandler struct {
+	h http.Handler
+}
+
+// authPublicPaths are the paths served without a session.
+var authPublicPaths = map[string]bool{
+	"/-/auth/login":    true,
+	"/-/auth/callback": true,
+	"/-/auth/logout":   true,
+	"/-/site.css":      true,
+	"/-/site.js":       true,
+	"/favicon.ico":     true,
+	"/healthz":         true,
+	"/readyz":          true,
+}
+
+func (h authHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if authPublicPaths[req.URL.Path] || authSession(req) != nil || validAPIToken(req) {
+		h.h.ServeHTTP(resp, req)
+		return
+	}
+	host := req.Host
+	if hh, _, err := net.SplitHostPort(host); err == nil {
+		host = hh
+	}
+	if strings.HasPrefix(host, "api.") {
+		if key := bearerToken(req); key != "" && db != nil {
+			if k, err := db.APIKey(key); err == nil && k != nil {
+				h.h.ServeHTTP(resp, req)
+				return
+			}
+		}
+		resp.Header().Set("Www-Authenticate", `Bearer realm="api"`)
+		http.Error(resp, "Sign in required.", http.StatusUnauthorized)
+		return
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(resp, "Sign in required.", http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(resp, req, "/-/auth/login?"+url.Values{"next": {req.URL.RequestURI()}}.Encode(), http.StatusFound)
+}
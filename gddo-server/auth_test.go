@@ -0,0 +1,265 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testIDToken(claims map[string]interface{}) string {
+	p, _ := json.Marshal(claims)
+	return "e30." + base64.RawURLEncoding.EncodeToString(p) + ".sig"
+}
+
+func setAuthFlags(t *testing.T, provider string) {
+	old := []string{*authProvider, *authClientID, *authDomain, *authGitHubOrg, *authGroups, *authPrivateGroups}
+	t.Cleanup(func() {
+		*authProvider, *authClientID, *authDomain, *authGitHubOrg, *authGroups, *authPrivateGroups = old[0], old[1], old[2], old[3], old[4], old[5]
+	})
+	*authProvider = provider
+	*authClientID = "client"
+	*authDomain, *authGitHubOrg, *authGroups, *authPrivateGroups = "", "", "", ""
+}
+
+func TestIDTokenUser(t *testing.T) {
+	setAuthFlags(t, "google")
+	*authDomain = "example.com"
+	now := time.Unix(1000, 0)
+	good := map[string]interface{}{
+		"iss":    "https://issuer",
+		"aud":    "client",
+		"exp":    2000,
+		"email":  "gopher@example.com",
+		"hd":     "example.com",
+		"groups": []string{"eng", "bad,group"},
+	}
+	u, err := idTokenUser("https://issuer", testIDToken(good), now)
+	if err != nil || u.Name != "gopher@example.com" || len(u.Groups) != 1 || u.Groups[0] != "eng" {
+		t.Errorf("idTokenUser(good) = %+v, %v", u, err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		value interface{}
+		want  error
+	}{
+		{"iss", "https://other", errBadIDToken},
+		{"aud", "other", errBadIDToken},
+		{"exp", 500, errBadIDToken},
+		{"hd", "other.com", errAuthDenied},
+	} {
+		claims := make(map[string]interface{})
+		for k, v := range good {
+			claims[k] = v
+		}
+		claims[tt.name] = tt.value
+		if _, err := idTokenUser("https://issuer", testIDToken(claims), now); err != tt.want {
+			t.Errorf("idTokenUser(%s %v) = %v, want %v", tt.name, tt.value, err, tt.want)
+		}
+	}
+	if _, err := idTokenUser("https://issuer", "bad", now); err != errBadIDToken {
+		t.Errorf("idTokenUser(bad) = %v, want %v", err, errBadIDToken)
+	}
+}
+
+func TestAuthOIDC(t *testing.T) {
+	setAuthFlags(t, "oidc")
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(resp, `{"issuer": %q, "authorization_endpoint": %q, "token_endpoint": %q}`, ts.URL, ts.URL+"/authorize", ts.URL+"/token")
+		case "/token":
+			req.ParseForm()
+			if req.Form.Get("code") != "good" {
+				fmt.Fprint(resp, `{"error": "invalid_grant"}`)
+				return
+			}
+			json.NewEncoder(resp).Encode(map[string]string{
+				"access_token": "t",
+				"id_token": testIDToken(map[string]interface{}{
+					"iss": ts.URL, "aud": []string{"client"}, "exp": time.Now().Add(time.Hour).Unix(), "sub": "gopher",
+				}),
+			})
+		default:
+			http.NotFound(resp, req)
+		}
+	}))
+	defer ts.Close()
+	defer func(issuer string) { *authIssuer = issuer }(*authIssuer)
+	*authIssuer = ts.URL
+
+	e, err := providerEndpoints()
+	if err != nil || !e.OpenIDConnect || e.TokenURL != ts.URL+"/token" {
+		t.Fatalf("providerEndpoints() = %+v, %v", e, err)
+	}
+	if u, err := authenticate(e, "good", "http://godoc.org/-/auth/callback"); err != nil || u.Name != "gopher" {
+		t.Errorf("authenticate(good) = %+v, %v", u, err)
+	}
+	if _, err := authenticate(e, "bad", "http://godoc.org/-/auth/callback"); err == nil {
+		t.Error("authenticate(bad) returned no error")
+	}
+}
+
+func TestAuthGitHubUser(t *testing.T) {
+	setAuthFlags(t, "github")
+	*authGitHubOrg = "org"
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		member := req.Header.Get("Authorization") == "token member"
+		switch req.URL.Path {
+		case "/user":
+			fmt.Fprint(resp, `{"login": "gopher"}`)
+		case "/user/memberships/orgs/org":
+			if !member {
+				http.NotFound(resp, req)
+				return
+			}
+			fmt.Fprint(resp, `{"state": "active"}`)
+		case "/user/teams":
+			fmt.Fprint(resp, `[{"slug": "eng", "organization": {"login": "Org"}}, {"slug": "ops", "organization": {"login": "other"}}]`)
+		default:
+			http.NotFound(resp, req)
+		}
+	}))
+	defer ts.Close()
+	defer func(api string) { githubAPIURL = api }(githubAPIURL)
+	githubAPIURL = ts.URL
+
+	u, err := githubUser("member")
+	if err != nil || u.Name != "gopher" || strings.Join(u.Groups, ",") != "eng" {
+		t.Errorf("githubUser(member) = %+v, %v", u, err)
+	}
+	if _, err := githubUser("other"); err != errAuthDenied {
+		t.Errorf("githubUser(other) = %v, want %v", err, errAuthDenied)
+	}
+}
+
+func TestAuthUserInGroups(t *testing.T) {
+	u := &authUser{Name: "gopher", Groups: []string{"eng", "docs"}}
+	for _, tt := range []struct {
+		groups string
+		want   bool
+	}{
+		{"", true},
+		{"docs", true},
+		{"ops, eng", true},
+		{"ops", false},
+	} {
+		if got := u.inGroups(tt.groups); got != tt.want {
+			t.Errorf("inGroups(%q) = %v, want %v", tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestAuthHandler(t *testing.T) {
+	setAuthFlags(t, "github")
+	*authPrivateGroups = "eng"
+	h := authHandler{http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(resp, isAuthenticated(req))
+	})}
+
+	session := func(u *authUser) *http.Cookie {
+		w := httptest.NewRecorder()
+		setAuthSession(w, httptest.NewRequest("GET", "http://godoc.org/", nil), u)
+		return w.Result().Cookies()[0]
+	}
+	for _, tt := range []struct {
+		url    string
+		cookie *http.Cookie
+		status int
+		body   string
+	}{
+		{"http://godoc.org/github.com/user/repo?x=1", nil, http.StatusFound, ""},
+		{"http://api.godoc.org/search", nil, http.StatusUnauthorized, ""},
+		{"http://godoc.org/-/site.css", nil, http.StatusOK, "false"},
+		{"http://godoc.org/", session(&authUser{Name: "gopher", Groups: []string{"eng"}}), http.StatusOK, "true"},
+		{"http://api.godoc.org/search", session(&authUser{Name: "gopher"}), http.StatusOK, "false"},
+		// Values signed for owners are not sessions.
+		{"http://godoc.org/", &http.Cookie{Name: sessionCookie, Value: signValue("github.com/user/repo", time.Now().Add(time.Hour))}, http.StatusFound, ""},
+	} {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s: status %d %q, want %d %q", tt.url, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+		if w.Code == http.StatusFound && !strings.HasPrefix(w.Header().Get("Location"), "/-/auth/login?next=") {
+			t.Errorf("%s: redirect to %q", tt.url, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAuthState(t *testing.T) {
+	setAuthFlags(t, "github")
+	defer func(secure bool) { *authSecureCookies = secure }(*authSecureCookies)
+	*authSecureCookies = true
+
+	req := httptest.NewRequest("GET", `http://godoc.org/-/auth/login?next=/\evil.com`, nil)
+	req.ParseForm()
+	w := httptest.NewRecorder()
+	if err := serveAuthLogin(w, req); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != authStateCookie || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("cookies = %v, want secure HttpOnly %s cookie", cookies, authStateCookie)
+	}
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := u.Query().Get("state")
+	if v, _ := verifyValue(state); v != authStatePrefix+cookies[0].Value+"\n/" {
+		t.Errorf("state = %q, want nonce of the cookie and next /", v)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{"no cookie", nil},
+		{"other nonce", &http.Cookie{Name: authStateCookie, Value: "other"}},
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/-/auth/callback?code=c&state="+url.QueryEscape(state), nil)
+		req.ParseForm()
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		if err, ok := serveAuthCallback(httptest.NewRecorder(), req).(*httpError); !ok || err.status != http.StatusBadRequest {
+			t.Errorf("%s: serveAuthCallback returned %v, want status 400", tt.name, err)
+		}
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	for _, tt := range []struct {
+		next string
+		want bool
+	}{
+		{"/", true},
+		{"/github.com/user/repo?x=1", true},
+		{"", false},
+		{"https://evil.com", false},
+		{"//evil.com", false},
+		{`/\evil.com`, false},
+	} {
+		if got := isLocalPath(tt.next); got != tt.want {
+			t.Errorf("isLocalPath(%q) = %v, want %v", tt.next, got, tt.want)
+		}
+	}
+}
@@ -7,108 +7,273 @@
 package main
 
 import (
-	"flag"
-	"github.com/golang/gddo/gosrc"
-	"log"
+	"context"
+	"log/slog"
+	"sync"
 	"time"
 )
 
-var backgroundTasks = []*struct {
+type backgroundTask struct {
 	name     string
 	fn       func() error
 	interval *time.Duration
 	next     time.Time
-}{
+
+	// readsOnly is true if the task does not write to the database. Only
+	// these tasks run in read-only mode.
+	readsOnly bool
+
+	// perReplica is true if the task runs on every replica instead of the
+	// replica holding the lease of the task.
+	perReplica bool
+
+	// last is the time the task last finished, standby is true if another
+	// replica holds the lease of the task and paused is true if the task is
+	// paused with the administrative API. They are guarded by backgroundMu.
+	last    time.Time
+	standby bool
+	paused  bool
+}
+
+// intervalValue returns the interval of the task, which changes on SIGHUP.
+func (task *backgroundTask) intervalValue() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return *task.interval
+}
+
+// enabled returns true if the task runs.
+func (task *backgroundTask) enabled() bool {
+	return task.intervalValue() > 0 && (!*readOnly || task.readsOnly)
+}
+
+var backgroundTasks = []*backgroundTask{
 	{
 		name:     "GitHub updates",
 		fn:       readGitHubUpdates,
-		interval: flag.Duration("github_interval", 0, "Github updates crawler sleeps for this duration between fetches. Zero disables the crawler."),
+		interval: reloadableDuration("github_interval", 0, "Github updates crawler sleeps for this duration between fetches. Zero disables the crawler."),
+	},
+	{
+		name:     "Module index",
+		fn:       readModuleIndex,
+		interval: reloadableDuration("module_index_interval", 0, "The modules published to module_index since the last read are crawled at this interval. Zero disables the reads."),
 	},
 	{
 		name:     "Crawl",
 		fn:       doCrawl,
-		interval: flag.Duration("crawl_interval", 0, "Package updater sleeps for this duration between package updates. Zero disables updates."),
+		interval: reloadableDuration("crawl_interval", 0, "Package updater sleeps for this duration between package updates. Zero disables updates."),
+	},
+	{
+		name:     "Moved repositories",
+		fn:       mergeMoves,
+		interval: reloadableDuration("move_interval", 0, "The packages of repositories moved to another path, such as renamed GitHub repositories, are merged into the packages at the new path at this interval. Zero disables the merges."),
+	},
+	{
+		name:     "Importer count snapshot",
+		fn:       snapshotImporterCounts,
+		interval: reloadableDuration("stats_interval", 0, "Importer counts are recorded for the daily package statistics at this interval. Zero disables the snapshots."),
+	},
+	{
+		name:      "Corpus dump",
+		fn:        writeDump,
+		interval:  reloadableDuration("dump_interval", 0, "Corpus dumps are written to dump_dir at this interval. Zero disables the dumps."),
+		readsOnly: true,
+	},
+	{
+		name:     "Similar packages",
+		fn:       updateSimilar,
+		interval: reloadableDuration("similar_interval", 0, "Similar packages are recomputed at this interval. Zero disables the updates."),
+	},
+	{
+		name:     "Top packages",
+		fn:       updateTop,
+		interval: reloadableDuration("top_interval", 0, "Top packages are recomputed at this interval. Zero disables the updates."),
+	},
+	{
+		name:      "Docsets",
+		fn:        updateDocsets,
+		interval:  reloadableDuration("docset_interval", 0, "Docsets in docset_dir with packages crawled since the last update are regenerated at this interval. Zero disables the updates."),
+		readsOnly: true,
+	},
+	{
+		name:     "Stats report",
+		fn:       updateReport,
+		interval: reloadableDuration("report_interval", 0, "The instance statistics served at /-/stats are recomputed at this interval. Zero disables the updates."),
+	},
+	{
+		name:     "Sync",
+		fn:       syncFromPrimary,
+		interval: reloadableDuration("sync_interval", 0, "Changes are copied from sync_primary at this interval. Zero disables the sync."),
+	},
+	{
+		name:       "Changes",
+		fn:         readChanges,
+		interval:   reloadableDuration("changes_interval", 0, "The packages saved or deleted by other processes, such as gddo-crawler, are read at this interval to refresh the page cache and the docsets. Zero disables the updates."),
+		readsOnly:  true,
+		perReplica: true,
+	},
+	{
+		name:     "Vulnerabilities",
+		fn:       updateVulns,
+		interval: reloadableDuration("vuln_interval", 0, "Known vulnerabilities are copied from vulndb_url at this interval. Zero disables the updates."),
+	},
+	{
+		name:     "Watch notifications",
+		fn:       sendWatchNotifications,
+		interval: reloadableDuration("notify_interval", 0, "The events of the watched packages are sent to the watches at this interval. Zero disables the notifications."),
+	},
+	{
+		name:     "Cold document eviction",
+		fn:       evictColdDocs,
+		interval: reloadableDuration("evict_interval", 0, "The documents of the packages not accessed for evict_after are evicted at this interval. Zero disables the evictions."),
+	},
+	{
+		name:      "Sitemaps",
+		fn:        writeSitemaps,
+		interval:  reloadableDuration("sitemap_interval", 0, "Sitemaps are regenerated at this interval. Zero disables the sitemaps."),
+		readsOnly: true,
+	},
+	{
+		name:       "Announcements",
+		fn:         refreshAnnouncements,
+		interval:   reloadableDuration("announcement_interval", time.Minute, "The announcements shown on the pages are read at this interval. Zero disables the updates."),
+		readsOnly:  true,
+		perReplica: true,
 	},
 }
 
+var (
+	backgroundMu    sync.Mutex
+	backgroundStart time.Time
+)
+
 func runBackgroundTasks() {
-	defer log.Println("ERROR: Background exiting!")
+	defer slog.Error("background exiting")
+
+	backgroundMu.Lock()
+	backgroundStart = time.Now()
+	backgroundMu.Unlock()
 
 	sleep := time.Minute
 	for _, task := range backgroundTasks {
-		if *task.interval > 0 && sleep > *task.interval {
-			sleep = *task.interval
+		if interval := task.intervalValue(); task.enabled() && sleep > interval {
+			sleep = interval
 		}
 	}
 
 	for {
+		paused := pausedBackgroundTasks()
 		for _, task := range backgroundTasks {
+			if !task.enabled() {
+				continue
+			}
+			backgroundMu.Lock()
+			if task.paused && !paused[task.name] {
+				// A resumed task is not stale until its interval passes.
+				task.last = time.Now()
+			}
+			task.paused = paused[task.name]
+			backgroundMu.Unlock()
+			if paused[task.name] {
+				continue
+			}
+			held := holdTaskLease(task)
+			backgroundMu.Lock()
+			if task.standby && held {
+				slog.Info("acquired lease of background task", "task", task.name, "replica", *replicaID)
+			}
+			task.standby = !held
+			backgroundMu.Unlock()
 			start := time.Now()
-			if *task.interval > 0 && start.After(task.next) {
+			if held && start.After(task.next) {
+				stop := extendTaskLease(task)
 				if err := task.fn(); err != nil {
-					log.Printf("Task %s: %v", task.name, err)
+					slog.Error("background task", "task", task.name, "err", err)
 				}
-				task.next = time.Now().Add(*task.interval)
+				stop()
+				task.next = time.Now().Add(task.intervalValue())
+				backgroundMu.Lock()
+				task.last = time.Now()
+				backgroundMu.Unlock()
 			}
 		}
 		time.Sleep(sleep)
 	}
 }
 
-func doCrawl() error {
-	// Look for new package to crawl.
-	importPath, hasSubdirs, err := db.PopNewCrawl()
-	if err != nil {
-		log.Printf("db.PopNewCrawl() returned error %v", err)
+// staleBackgroundTasks returns the names of the enabled background tasks that
+// have not finished for longer than their interval plus grace.
+func staleBackgroundTasks(now time.Time, grace time.Duration) []string {
+	backgroundMu.Lock()
+	defer backgroundMu.Unlock()
+	if backgroundStart.IsZero() {
 		return nil
 	}
-	if importPath != "" {
-		if pdoc, err := crawlDoc("new", importPath, nil, hasSubdirs, time.Time{}); pdoc == nil && err == nil {
-			if err := db.AddBadCrawl(importPath); err != nil {
-				log.Printf("ERROR db.AddBadCrawl(%q): %v", importPath, err)
-			}
+	var names []string
+	for _, task := range backgroundTasks {
+		if !task.enabled() || task.standby || task.paused {
+			continue
+		}
+		last := task.last
+		if last.IsZero() {
+			last = backgroundStart
+		}
+		if now.Sub(last) > task.intervalValue()+grace {
+			names = append(names, task.name)
 		}
-		return nil
 	}
+	return names
+}
 
-	// Crawl existing doc.
-	pdoc, pkgs, nextCrawl, err := db.Get("-")
+// pausedBackgroundTasks returns the set of the names of the paused tasks. The
+// tasks are not paused if the paused tasks cannot be read.
+func pausedBackgroundTasks() map[string]bool {
+	names, err := db.PausedTasks()
 	if err != nil {
-		log.Printf("db.Get(\"-\") returned error %v", err)
+		slog.Error("paused background tasks", "err", err)
 		return nil
 	}
-	if pdoc == nil || nextCrawl.After(time.Now()) {
-		return nil
+	paused := make(map[string]bool)
+	for _, name := range names {
+		paused[name] = true
 	}
-	if _, err = crawlDoc("crawl", pdoc.ImportPath, pdoc, len(pkgs) > 0, nextCrawl); err != nil {
-		// Touch package so that crawl advances to next package.
-		if err := db.SetNextCrawlEtag(pdoc.ProjectRoot, pdoc.Etag, time.Now().Add(*maxAge/3)); err != nil {
-			log.Printf("ERROR db.TouchLastCrawl(%q): %v", pdoc.ImportPath, err)
+	return paused
+}
+
+// findBackgroundTask returns the background task with the name or nil.
+func findBackgroundTask(name string) *backgroundTask {
+	for _, task := range backgroundTasks {
+		if task.name == name {
+			return task
 		}
 	}
 	return nil
 }
 
+func doCrawl() error {
+	return docCrawler.CrawlNext(context.Background())
+}
+
 func readGitHubUpdates() error {
-	const key = "gitHubUpdates"
-	var last string
-	if err := db.GetGob(key, &last); err != nil {
-		return err
-	}
-	last, names, err := gosrc.GetGitHubUpdates(httpClient, last)
-	if err != nil {
-		return err
-	}
+	return docCrawler.ReadGitHubUpdates(context.Background())
+}
 
-	for _, name := range names {
-		log.Printf("bump crawl github.com/%s", name)
-		if err := db.BumpCrawl("github.com/" + name); err != nil {
-			log.Println("ERROR force crawl:", err)
-		}
-	}
+func readModuleIndex() error {
+	return docCrawler.ReadModuleIndex(context.Background(), *moduleIndex)
+}
 
-	if err := db.PutGob(key, last); err != nil {
-		return err
-	}
-	return nil
+func mergeMoves() error {
+	return db.MergeMoves()
+}
+
+func snapshotImporterCounts() error {
+	return db.SnapshotImporterCounts()
+}
+
+func updateSimilar() error {
+	return db.UpdateSimilar()
+}
+
+func updateTop() error {
+	return db.UpdateTop()
 }
@@ -0,0 +1,155 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	badgeHeight   = 20
+	badgePadding  = 10
+	badgeMaxText  = 40
+	badgeLabel    = "godoc"
+	badgeMessage  = "reference"
+	badgeColor    = "#5272B4"
+	badgeCacheAge = 24 * 60 * 60
+)
+
+var badgeColors = map[string]string{
+	"blue":   badgeColor,
+	"green":  "#44CC11",
+	"yellow": "#DFB317",
+	"orange": "#FE7D37",
+	"red":    "#E05D44",
+	"gray":   "#555555",
+}
+
+var badgeHexColorPat = regexp.MustCompile(`^(?:[0-9a-fA-F]{3}){1,2}$`)
+
+// badgeTextWidth estimates the width in pixels of s rendered in the 11px
+// badge font.
+func badgeTextWidth(s string) int {
+	w := 0.0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("ilj.,:;!|'", r):
+			w += 3.5
+		case strings.ContainsRune("mwMW", r):
+			w += 9.5
+		case r >= 'A' && r <= 'Z':
+			w += 7.5
+		default:
+			w += 6.5
+		}
+	}
+	return int(w + 0.5)
+}
+
+// badgeColorValue returns the fill color for the color query parameter.
+func badgeColorValue(s string) (string, bool) {
+	if s == "" {
+		return badgeColor, true
+	}
+	if c, ok := badgeColors[s]; ok {
+		return c, true
+	}
+	if badgeHexColorPat.MatchString(s) {
+		return "#" + s, true
+	}
+	return "", false
+}
+
+// renderBadge returns an SVG badge with the label on the left and the
+// message on the right. The style is flat or flat-square.
+func renderBadge(label, message, color, style string) []byte {
+	lw := badgeTextWidth(label) + badgePadding
+	mw := badgeTextWidth(message) + badgePadding
+	w := lw + mw
+	rx := 3
+	if style == "flat-square" {
+		rx = 0
+	}
+	label = template.HTMLEscapeString(label)
+	message = template.HTMLEscapeString(message)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, w, badgeHeight)
+	fmt.Fprintf(&buf, `<title>%s: %s</title>`, label, message)
+	if style != "flat-square" {
+		buf.WriteString(`<linearGradient id="a" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	}
+	fmt.Fprintf(&buf, `<rect rx="%d" width="%d" height="%d" fill="#555"/>`, rx, w, badgeHeight)
+	fmt.Fprintf(&buf, `<rect rx="%d" x="%d" width="%d" height="%d" fill="%s"/>`, rx, lw, mw, badgeHeight, color)
+	fmt.Fprintf(&buf, `<path fill="%s" d="M%d 0h4v%dh-4z"/>`, color, lw, badgeHeight)
+	if style != "flat-square" {
+		fmt.Fprintf(&buf, `<rect rx="%d" width="%d" height="%d" fill="url(#a)"/>`, rx, w, badgeHeight)
+	}
+	buf.WriteString(`<g fill="#fff" text-anchor="middle" font-family="DejaVu Sans,Verdana,Geneva,sans-serif" font-size="11">`)
+	for _, t := range []struct {
+		x    float64
+		text string
+	}{{float64(lw) / 2, label}, {float64(lw) + float64(mw)/2, message}} {
+		fmt.Fprintf(&buf, `<text x="%.1f" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%.1f" y="14">%s</text>`, t.x, t.text, t.x, t.text)
+	}
+	buf.WriteString(`</g></svg>`)
+	return buf.Bytes()
+}
+
+// serveBadge serves a badge for the package at /badge/<import path>.svg. The
+// style, color, label and message query parameters customize the badge.
+func serveBadge(resp http.ResponseWriter, req *http.Request) error {
+	importPath := strings.TrimPrefix(req.URL.Path, "/badge/")
+	if !strings.HasSuffix(importPath, ".svg") || importPath == ".svg" {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	style := req.Form.Get("style")
+	switch style {
+	case "":
+		style = "flat"
+	case "flat", "flat-square":
+	default:
+		return &httpError{status: http.StatusBadRequest}
+	}
+	color, ok := badgeColorValue(req.Form.Get("color"))
+	if !ok {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	label := badgeLabel
+	if _, ok := req.Form["label"]; ok {
+		label = req.Form.Get("label")
+	}
+	message := badgeMessage
+	if s := req.Form.Get("message"); s != "" {
+		message = s
+	}
+	if utf8.RuneCountInString(label) > badgeMaxText || utf8.RuneCountInString(message) > badgeMaxText {
+		return &httpError{status: http.StatusBadRequest}
+	}
+
+	p := renderBadge(label, message, color, style)
+	h := sha1.Sum(p)
+	etag := `"` + hex.EncodeToString(h[:]) + `"`
+	resp.Header().Set("Etag", etag)
+	resp.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", badgeCacheAge))
+	if req.Header.Get("If-None-Match") == etag {
+		resp.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	resp.Header().Set("Content-Type", "image/svg+xml")
+	_, err := resp.Write(p)
+	return err
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var serveBadgeTests = []struct {
+	url    string
+	status int
+	text   string
+}{
+	{"/badge/github.com/user/repo.svg", http.StatusOK, ">reference<"},
+	{"/badge/github.com/user/repo.svg?style=flat-square&color=green&label=docs", http.StatusOK, ">docs<"},
+	{"/badge/github.com/user/repo.svg?color=ff0000&message=%3Cv1%3E", http.StatusOK, "&lt;v1&gt;"},
+	{"/badge/github.com/user/repo.svg?style=plastic", http.StatusBadRequest, ""},
+	{"/badge/github.com/user/repo.svg?color=url(x)", http.StatusBadRequest, ""},
+	{"/badge/github.com/user/repo", http.StatusNotFound, ""},
+}
+
+func TestServeBadge(t *testing.T) {
+	for _, tt := range serveBadgeTests {
+		req, err := http.NewRequest("GET", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.ParseForm()
+		resp := httptest.NewRecorder()
+		err = serveBadge(resp, req)
+		if tt.status != http.StatusOK {
+			if e, ok := err.(*httpError); !ok || e.status != tt.status {
+				t.Errorf("%s: serveBadge returned %v, want status %d", tt.url, err, tt.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: serveBadge returned %v", tt.url, err)
+			continue
+		}
+		if body := resp.Body.String(); !strings.Contains(body, tt.text) {
+			t.Errorf("%s: body %q does not contain %q", tt.url, body, tt.text)
+		}
+		if resp.Header().Get("Cache-Control") == "" || resp.Header().Get("Etag") == "" {
+			t.Errorf("%s: missing cache headers", tt.url)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+// blockedError is the reason of the error response for a blocked package.
+type blockedError struct {
+	entry *database.BlockEntry
+}
+
+func (e blockedError) Error() string {
+	return "blocked by " + e.entry.Pattern
+}
+
+// blockStatus returns the status of the responses for the packages blocked
+// by the entry.
+func blockStatus(e *database.BlockEntry) int {
+	if e.Kind == database.BlockLegal {
+		return http.StatusUnavailableForLegalReasons
+	}
+	return http.StatusGone
+}
+
+// checkBlocked returns an error with status 410 or 451 if the package with
+// the import path is blocked.
+func checkBlocked(importPath string) error {
+	e, err := db.BlockedBy(importPath)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return nil
+	}
+	return &httpError{status: blockStatus(e), err: blockedError{e}}
+}
+
+// serveBlocklist serves the blocklist as JSON to requests with the viewer
+// role. A POST request adds the entry with the pattern, kind and reason
+// parameters and a DELETE request removes the entry with the pattern
+// parameter. The changes require the admin role.
+func serveBlocklist(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRole(req, roleViewer); err != nil {
+		return err
+	}
+	pattern := strings.Trim(req.Form.Get("pattern"), "/")
+	switch req.Method {
+	case "GET", "HEAD":
+	case "POST", "DELETE":
+		if err := checkRole(req, roleAdmin); err != nil {
+			return err
+		}
+		if *readOnly {
+			return errReadOnly
+		}
+		if pattern == "" {
+			return &httpError{status: http.StatusBadRequest}
+		}
+		action := "unblock"
+		if req.Method == "POST" {
+			action = "block"
+			kind := req.Form.Get("kind")
+			if kind != "" && kind != database.BlockRemoved && kind != database.BlockLegal {
+				return &httpError{status: http.StatusBadRequest}
+			}
+			if err := db.AddBlock(database.BlockEntry{Pattern: pattern, Kind: kind, Reason: req.Form.Get("reason")}); err != nil {
+				return &httpError{status: http.StatusBadRequest, err: err}
+			}
+			pages.reset()
+			packageBlocked(pattern, req.Form.Get("reason"))
+		} else if ok, err := db.RemoveBlock(pattern); err != nil {
+			return err
+		} else if !ok {
+			return &httpError{status: http.StatusNotFound}
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: adminActor(req), Action: action, Target: pattern, Reason: req.Form.Get("reason")}); err != nil {
+			return err
+		}
+	default:
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+
+	entries, err := db.BlockEntries()
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []database.BlockEntry{}
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "private")
+	return json.NewEncoder(resp).Encode(map[string]interface{}{"results": entries})
+}
@@ -0,0 +1,64 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+func TestBlockStatus(t *testing.T) {
+	if got := blockStatus(&database.BlockEntry{Kind: database.BlockRemoved}); got != http.StatusGone {
+		t.Errorf("removed: status %d, want %d", got, http.StatusGone)
+	}
+	if got := blockStatus(&database.BlockEntry{Kind: database.BlockLegal}); got != http.StatusUnavailableForLegalReasons {
+		t.Errorf("legal: status %d, want %d", got, http.StatusUnavailableForLegalReasons)
+	}
+}
+
+func TestBlockedPage(t *testing.T) {
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{{"blocked.html", "common.html", "layout.html"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := parseTextTemplates([][]string{{"blocked.txt", "common.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		accept string
+		status int
+		want   string
+	}{
+		{"text/html", http.StatusUnavailableForLegalReasons, "Unavailable For Legal Reasons"},
+		{"text/plain", http.StatusUnavailableForLegalReasons, "UNAVAILABLE FOR LEGAL REASONS"},
+		{"text/html", http.StatusGone, "<h1>Removed</h1>"},
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/github.com/badactor/repo", nil)
+		req.Header.Set("Accept", tt.accept)
+		resp := httptest.NewRecorder()
+		handleError(resp, req, tt.status, blockedError{&database.BlockEntry{Pattern: "github.com/badactor/*", Reason: "court order"}})
+		body := resp.Body.String()
+		if resp.Code != tt.status || !strings.Contains(body, tt.want) || !strings.Contains(body, "court order") {
+			t.Errorf("%s %d: got %d\n%s", tt.accept, tt.status, resp.Code, body)
+		}
+	}
+}
+
+func TestServeBlocklistUnauthorized(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://godoc.org/-/admin/blocklist?pattern=github.com/user/*", nil)
+	req.ParseForm()
+	err := serveBlocklist(httptest.NewRecorder(), req)
+	if e, ok := err.(*httpError); !ok || e.status != http.StatusUnauthorized {
+		t.Errorf("err = %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
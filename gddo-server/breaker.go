@@ -0,0 +1,119 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A package that is not in the database is fetched while the request waits
+// for -first_get_timeout. After the timeout, the request is served a page
+// asking to retry shortly and the fetch continues in the background.
+//
+// The on-demand fetches from each host go through a circuit breaker. After
+// -breaker_failures consecutive failed fetches from a host, the breaker of
+// the host opens: for -breaker_cooldown, requests do not fetch from the
+// host and the packages are queued for the background crawl instead. After
+// the cooldown, one fetch tries the host again and closes the breaker on
+// success.
+
+var (
+	breakerFailures = flag.Int("breaker_failures", 5, "Consecutive failed on-demand fetches from a host that suspend the on-demand fetches from the host. Zero disables the circuit breakers.")
+	breakerCooldown = flag.Duration("breaker_cooldown", time.Minute, "Time the on-demand fetches from a host are suspended after breaker_failures failures.")
+	fetchRetryAfter = flag.Duration("fetch_retry_after", 10*time.Second, "Time after which the page of a package fetched in the background asks the browser to retry.")
+)
+
+// errFetching is the reason of the responses for packages fetched in the
+// background.
+var errFetching = errors.New("package is being fetched")
+
+func fetchRetrySeconds() int {
+	return int(math.Ceil(fetchRetryAfter.Seconds()))
+}
+
+// fetchingError returns the error served for a package fetched in the
+// background.
+func fetchingError() error {
+	return &httpError{
+		status: http.StatusServiceUnavailable,
+		err:    errFetching,
+		header: http.Header{"Retry-After": {strconv.Itoa(fetchRetrySeconds())}},
+	}
+}
+
+// hostBreaker is the state of the circuit breaker of one host.
+type hostBreaker struct {
+	failures  int
+	openUntil time.Time
+	trial     bool // A fetch tries the host after the cooldown.
+}
+
+type breakerSet struct {
+	mu    sync.Mutex
+	hosts map[string]*hostBreaker
+}
+
+var breakers = &breakerSet{}
+
+// fetchHost returns the host of the import path.
+func fetchHost(importPath string) string {
+	if i := strings.Index(importPath, "/"); i >= 0 {
+		return importPath[:i]
+	}
+	return importPath
+}
+
+// allow returns true if a request can fetch from the host at time now.
+func (s *breakerSet) allow(host string, now time.Time) bool {
+	if *breakerFailures <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.hosts[host]
+	if b == nil || b.failures < *breakerFailures {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record records the result of a fetch from the host at time now.
+func (s *breakerSet) record(host string, ok bool, now time.Time) {
+	if *breakerFailures <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok {
+		delete(s.hosts, host)
+		return
+	}
+	if s.hosts == nil {
+		s.hosts = make(map[string]*hostBreaker)
+	}
+	b := s.hosts[host]
+	if b == nil {
+		b = &hostBreaker{}
+		s.hosts[host] = b
+	}
+	b.failures++
+	b.trial = false
+	if b.failures >= *breakerFailures {
+		b.openUntil = now.Add(*breakerCooldown)
+	}
+}
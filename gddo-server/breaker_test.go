@@ -0,0 +1,61 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	defer func(n int, d time.Duration) { *breakerFailures, *breakerCooldown = n, d }(*breakerFailures, *breakerCooldown)
+	*breakerFailures = 2
+	*breakerCooldown = time.Minute
+
+	var s breakerSet
+	now := time.Now()
+	s.record("example.com", false, now)
+	if !s.allow("example.com", now) {
+		t.Fatal("breaker open after one failure")
+	}
+	s.record("example.com", false, now)
+	if s.allow("example.com", now.Add(30*time.Second)) {
+		t.Error("breaker closed during the cooldown")
+	}
+	if !s.allow("other.com", now) {
+		t.Error("breaker of another host open")
+	}
+
+	later := now.Add(2 * time.Minute)
+	if !s.allow("example.com", later) {
+		t.Fatal("trial fetch not allowed after the cooldown")
+	}
+	if s.allow("example.com", later) {
+		t.Error("second fetch allowed during the trial")
+	}
+	s.record("example.com", false, later)
+	if s.allow("example.com", later.Add(30*time.Second)) {
+		t.Error("breaker closed after the failed trial")
+	}
+	s.record("example.com", true, later)
+	if !s.allow("example.com", later) {
+		t.Error("breaker open after a successful fetch")
+	}
+}
+
+func TestFetchingError(t *testing.T) {
+	defer func(d time.Duration) { *fetchRetryAfter = d }(*fetchRetryAfter)
+	*fetchRetryAfter = 1500 * time.Millisecond
+	e, ok := fetchingError().(*httpError)
+	if !ok || e.status != http.StatusServiceUnavailable || e.err != errFetching || e.header.Get("Retry-After") != "2" {
+		t.Errorf("fetchingError() = %+v", e)
+	}
+	if got := fetchHost("github.com/user/repo"); got != "github.com" {
+		t.Errorf("fetchHost() = %q, want github.com", got)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+// apiChanges is the list of changes to the exported API of a package grouped
+// by the kind of change.
+type apiChanges struct {
+	Added   []doc.APIChange
+	Removed []doc.APIChange
+	Changed []doc.APIChange
+}
+
+func groupAPIChanges(changes []doc.APIChange) *apiChanges {
+	var g apiChanges
+	for _, c := range changes {
+		switch {
+		case c.Old == nil:
+			g.Added = append(g.Added, c)
+		case c.New == nil:
+			g.Removed = append(g.Removed, c)
+		default:
+			g.Changed = append(g.Changed, c)
+		}
+	}
+	return &g
+}
+
+// hasAPI returns true if the API summary of the package document is
+// available. Documents saved before summaries were added have no summary.
+func hasAPI(pdoc *doc.Package) bool {
+	return pdoc.API != nil || (pdoc.Consts == nil && pdoc.Vars == nil && pdoc.Funcs == nil && pdoc.Types == nil)
+}
+
+// serveChanges serves the changes to the exported API of a package at
+// /changes/<import path>. Without parameters, the API at the last crawl is
+// compared to the API before the last change found by a crawl. The from and
+// to parameters select saved versions to compare. The current documentation
+// is used when to is not set.
+func serveChanges(resp http.ResponseWriter, req *http.Request) error {
+	importPath := strings.TrimPrefix(req.URL.Path, "/changes/")
+	if importPath == "" || !isAllowed(importPath) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	pdoc, _, _, err := db.Get(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	versions, err := db.Versions(importPath)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"flashMessages": getFlashMessages(resp, req),
+		"pdoc":          newTDoc(pdoc),
+		"versions":      versions,
+	}
+
+	from, to := req.Form.Get("from"), req.Form.Get("to")
+	if from == "" && to == "" {
+		h, err := db.GetAPIHistory(importPath)
+		if err != nil {
+			return err
+		}
+		if h != nil && !h.PreviousTime.IsZero() {
+			data["changes"] = groupAPIChanges(doc.DiffAPI(h.Previous, h.Current))
+			data["fromTime"] = h.PreviousTime
+			data["toTime"] = h.CurrentTime
+		}
+		return executeTemplate(resp, "changes.html", http.StatusOK, nil, data)
+	}
+
+	if from == "" {
+		return &httpError{status: http.StatusNotFound}
+	}
+	old, err := db.GetVersion(importPath, from)
+	if err != nil {
+		return err
+	}
+	cur := pdoc
+	if to != "" {
+		cur, err = db.GetVersion(importPath, to)
+		if err != nil {
+			return err
+		}
+	}
+	if old == nil || cur == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	data["from"] = from
+	data["to"] = to
+	if hasAPI(old) && hasAPI(cur) {
+		data["changes"] = groupAPIChanges(doc.DiffAPI(old.API, cur.API))
+	}
+	data["fromTime"] = old.Updated
+	data["toTime"] = cur.Updated
+	return executeTemplate(resp, "changes.html", http.StatusOK, nil, data)
+}
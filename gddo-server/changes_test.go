@@ -0,0 +1,44 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestGroupAPIChanges(t *testing.T) {
+	a := doc.APISymbol{Name: "A", Kind: "func", Decl: "func A()"}
+	b := doc.APISymbol{Name: "A", Kind: "func", Decl: "func A(int)"}
+	g := groupAPIChanges([]doc.APIChange{
+		{Name: "A", Old: &a, New: &b},
+		{Name: "B", New: &b},
+		{Name: "C", Old: &a},
+	})
+	if len(g.Added) != 1 || g.Added[0].Name != "B" {
+		t.Errorf("Added = %v, want [B]", g.Added)
+	}
+	if len(g.Removed) != 1 || g.Removed[0].Name != "C" {
+		t.Errorf("Removed = %v, want [C]", g.Removed)
+	}
+	if len(g.Changed) != 1 || g.Changed[0].Name != "A" {
+		t.Errorf("Changed = %v, want [A]", g.Changed)
+	}
+}
+
+func TestHasAPI(t *testing.T) {
+	if !hasAPI(&doc.Package{}) {
+		t.Error("hasAPI(empty package) = false, want true")
+	}
+	if hasAPI(&doc.Package{Funcs: []*doc.Func{{Name: "F"}}}) {
+		t.Error("hasAPI(package without summary) = true, want false")
+	}
+	if !hasAPI(&doc.Package{Funcs: []*doc.Func{{Name: "F"}}, API: []doc.APISymbol{{Name: "F"}}}) {
+		t.Error("hasAPI(package with summary) = false, want true")
+	}
+}
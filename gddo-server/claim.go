@@ -0,0 +1,266 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The owners of GitHub repositories can also sign in with GitHub instead of
+// presenting the owner key. The server checks with the GitHub API that the
+// signed in user is an administrator of the repository and sets a signed
+// cookie that authorizes owner actions on the packages in the project.
+
+var (
+	githubClientID     = flag.String("github_client_id", "", "Client ID of the GitHub OAuth application used to verify the administrators of GitHub repositories as owners. Empty disables sign in with GitHub.")
+	githubClientSecret = flag.String("github_client_secret", "", "Client secret of the GitHub OAuth application.")
+	ownerSessionSecret = flag.String("owner_session_secret", "", "Secret for signing the sessions of owners signed in with GitHub. Use the same secret on every replica. If empty, a random secret is used and the sessions end when the server restarts.")
+	ownerSessionMaxAge = flag.Duration("owner_session_max_age", 12*time.Hour, "Duration of the sessions of owners signed in with GitHub.")
+)
+
+const (
+	// ownerCookie is the cookie with the signed project root of the owner
+	// signed in with GitHub.
+	ownerCookie = "owner"
+
+	// githubStateMaxAge is the time to complete the sign in with GitHub.
+	githubStateMaxAge = 10 * time.Minute
+)
+
+// The GitHub endpoints, replaced in tests.
+var (
+	githubAuthorizeURL = "https://github.com/login/oauth/authorize"
+	githubTokenURL     = "https://github.com/login/oauth/access_token"
+	githubAPIURL       = "https://api.github.com"
+)
+
+var githubOAuthClient = &http.Client{Timeout: 10 * time.Second}
+
+var errNotRepoAdmin = errors.New("not an administrator of the repository")
+
+var sessionKey struct {
+	once sync.Once
+	key  []byte
+}
+
+// signingKey returns the key for signing owner sessions and sign in states.
+func signingKey() []byte {
+	sessionKey.once.Do(func() {
+		if *ownerSessionSecret != "" {
+			sessionKey.key = []byte(*ownerSessionSecret)
+			return
+		}
+		sessionKey.key = make([]byte, 32)
+		rand.Read(sessionKey.key)
+	})
+	return sessionKey.key
+}
+
+// signValue returns the value with an expiration time and a signature.
+func signValue(value string, expires time.Time) string {
+	payload := value + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, signingKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyValue returns the value signed by signValue if the signature is valid
+// and the value has not expired.
+func verifyValue(s string) (string, bool) {
+	i := strings.Index(s, ".")
+	if i < 0 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(s[:i])
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s[i+1:])
+	if err != nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, signingKey())
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", false
+	}
+	j := strings.LastIndex(string(payload), "|")
+	if j < 0 {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(string(payload[j+1:]), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", false
+	}
+	return string(payload[:j]), true
+}
+
+// ownerSession returns the project root of the owner signed in with GitHub
+// or "" if the request does not have a valid session.
+func ownerSession(req *http.Request) string {
+	c, err := req.Cookie(ownerCookie)
+	if err != nil {
+		return ""
+	}
+	root, _ := verifyValue(c.Value)
+	return root
+}
+
+// checkOwner returns nil if the request is from the verified owner of the
+// project with the given root: the request has the owner key or a session of
+// an owner signed in with GitHub.
+func checkOwner(req *http.Request, projectRoot string) error {
+	if key := req.Form.Get("key"); key != "" {
+		return checkOwnerKey(projectRoot, key)
+	}
+	if projectRoot != "" && ownerSession(req) == projectRoot {
+		return nil
+	}
+	return errBadOwnerKey
+}
+
+// githubLoginEnabled returns true if the owner of the project can sign in
+// with GitHub.
+func githubLoginEnabled(projectRoot string) bool {
+	return *githubClientID != "" && strings.HasPrefix(projectRoot, "github.com/") && strings.Count(projectRoot, "/") == 2
+}
+
+// serveGitHubLogin redirects to the GitHub authorization page to sign in as
+// the owner of the project of the package in the path parameter.
+func serveGitHubLogin(resp http.ResponseWriter, req *http.Request) error {
+	importPath := req.Form.Get("path")
+	pdoc, _, _, err := db.Get(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || !githubLoginEnabled(pdoc.ProjectRoot) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	q := url.Values{
+		"client_id":    {*githubClientID},
+		"redirect_uri": {pageURI(req, "-/owner/github/callback")},
+		"state":        {signValue(importPath, time.Now().Add(githubStateMaxAge))},
+	}
+	http.Redirect(resp, req, githubAuthorizeURL+"?"+q.Encode(), http.StatusFound)
+	return nil
+}
+
+// githubToken exchanges the code of a GitHub authorization for an access
+// token.
+func githubToken(code string) (string, error) {
+	form := url.Values{
+		"client_id":     {*githubClientID},
+		"client_secret": {*githubClientSecret},
+		"code":          {code},
+	}
+	req, err := http.NewRequest("POST", githubTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := githubOAuthClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var r struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", err
+	}
+	if r.AccessToken == "" {
+		return "", fmt.Errorf("github: %s", r.Error)
+	}
+	return r.AccessToken, nil
+}
+
+// checkRepoAdmin returns nil if the user of the access token is an
+// administrator of the GitHub repository with the project root.
+func checkRepoAdmin(token, projectRoot string) error {
+	req, err := http.NewRequest("GET", githubAPIURL+"/repos/"+strings.TrimPrefix(projectRoot, "github.com/"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := githubOAuthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errNotRepoAdmin
+	}
+	var repo struct {
+		Permissions struct {
+			Admin bool `json:"admin"`
+		} `json:"permissions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return err
+	}
+	if !repo.Permissions.Admin {
+		return errNotRepoAdmin
+	}
+	return nil
+}
+
+// serveGitHubCallback completes the sign in with GitHub and starts the owner
+// session if the user is an administrator of the repository.
+func serveGitHubCallback(resp http.ResponseWriter, req *http.Request) error {
+	importPath, ok := verifyValue(req.Form.Get("state"))
+	if !ok || *githubClientID == "" {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	pdoc, _, _, err := db.Get(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || !githubLoginEnabled(pdoc.ProjectRoot) {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	token, err := githubToken(req.Form.Get("code"))
+	if err == nil {
+		err = checkRepoAdmin(token, pdoc.ProjectRoot)
+	}
+	switch {
+	case err == errNotRepoAdmin:
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{"You are not an administrator of " + pdoc.ProjectRoot + " on GitHub."}}})
+	case err != nil:
+		logger(req.Context()).Error("github sign in", "path", importPath, "err", err)
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{"Sign in with GitHub failed. Try again later."}}})
+	default:
+		http.SetCookie(resp, &http.Cookie{
+			Name:     ownerCookie,
+			Value:    signValue(pdoc.ProjectRoot, time.Now().Add(*ownerSessionMaxAge)),
+			Path:     "/",
+			MaxAge:   int(*ownerSessionMaxAge / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{"You are signed in as the owner of " + pdoc.ProjectRoot + "."}}})
+	}
+	http.Redirect(resp, req, "/"+importPath+"?tools", http.StatusFound)
+	return nil
+}
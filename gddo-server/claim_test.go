@@ -0,0 +1,110 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignValue(t *testing.T) {
+	s := signValue("github.com/user/repo", time.Now().Add(time.Hour))
+	if v, ok := verifyValue(s); !ok || v != "github.com/user/repo" {
+		t.Errorf("verifyValue(signValue(...)) = %q, %v", v, ok)
+	}
+	for _, s := range []string{
+		signValue("github.com/user/repo", time.Now().Add(-time.Second)),
+		s[:len(s)-2] + "AA",
+		"bm90IHNpZ25lZHwx.AAAA",
+		"",
+	} {
+		if v, ok := verifyValue(s); ok {
+			t.Errorf("verifyValue(%q) = %q, true, want false", s, v)
+		}
+	}
+}
+
+func TestCheckOwnerSession(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://godoc.org/-/owner", nil)
+	req.AddCookie(&http.Cookie{Name: ownerCookie, Value: signValue("github.com/user/repo", time.Now().Add(time.Hour))})
+	req.ParseForm()
+	if err := checkOwner(req, "github.com/user/repo"); err != nil {
+		t.Errorf("checkOwner(session project) = %v", err)
+	}
+	if err := checkOwner(req, "github.com/user/other"); err != errBadOwnerKey {
+		t.Errorf("checkOwner(other project) = %v, want %v", err, errBadOwnerKey)
+	}
+	if err := checkOwner(req, ""); err != errBadOwnerKey {
+		t.Errorf("checkOwner(\"\") = %v, want %v", err, errBadOwnerKey)
+	}
+}
+
+func TestGitHubLoginEnabled(t *testing.T) {
+	defer func(id string) { *githubClientID = id }(*githubClientID)
+	*githubClientID = ""
+	if githubLoginEnabled("github.com/user/repo") {
+		t.Error("sign in enabled without -github_client_id")
+	}
+	*githubClientID = "id"
+	for _, tt := range []struct {
+		root string
+		want bool
+	}{
+		{"github.com/user/repo", true},
+		{"github.com/user", false},
+		{"example.com/repo", false},
+		{"", false},
+	} {
+		if got := githubLoginEnabled(tt.root); got != tt.want {
+			t.Errorf("githubLoginEnabled(%q) = %v, want %v", tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubRepoAdmin(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/token":
+			req.ParseForm()
+			if req.Form.Get("code") != "good" {
+				fmt.Fprint(resp, `{"error_description": "bad code"}`)
+				return
+			}
+			fmt.Fprint(resp, `{"access_token": "t"}`)
+		case "/repos/user/repo":
+			fmt.Fprintf(resp, `{"permissions": {"admin": %v}}`, req.Header.Get("Authorization") == "token admin")
+		default:
+			http.NotFound(resp, req)
+		}
+	}))
+	defer ts.Close()
+	defer func(token, api string) { githubTokenURL, githubAPIURL = token, api }(githubTokenURL, githubAPIURL)
+	githubTokenURL = ts.URL + "/token"
+	githubAPIURL = ts.URL
+
+	if token, err := githubToken("good"); err != nil || token != "t" {
+		t.Errorf("githubToken(good) = %q, %v", token, err)
+	}
+	if _, err := githubToken("bad"); err == nil {
+		t.Error("githubToken(bad) returned no error")
+	}
+	for _, tt := range []struct {
+		token, root string
+		want        error
+	}{
+		{"admin", "github.com/user/repo", nil},
+		{"t", "github.com/user/repo", errNotRepoAdmin},
+		{"admin", "github.com/user/missing", errNotRepoAdmin},
+	} {
+		if err := checkRepoAdmin(tt.token, tt.root); err != tt.want {
+			t.Errorf("checkRepoAdmin(%q, %q) = %v, want %v", tt.token, tt.root, err, tt.want)
+		}
+	}
+}
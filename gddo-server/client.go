@@ -10,16 +10,42 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/golang/gddo/gosrc"
 )
 
 var (
-	dialTimeout    = flag.Duration("dial_timeout", 5*time.Second, "Timeout for dialing an HTTP connection.")
-	requestTimeout = flag.Duration("request_timeout", 20*time.Second, "Time out for roundtripping an HTTP request.")
+	dialTimeout          = flag.Duration("dial_timeout", 5*time.Second, "Timeout for dialing an HTTP connection.")
+	requestTimeout       = flag.Duration("request_timeout", 20*time.Second, "Time out for roundtripping an HTTP request.")
+	gitLabHosts          = flag.String("gitlab_hosts", "", "Comma separated list of GitLab instances to fetch packages from, each as host or host=token.")
+	vcsCommands          = flag.String("vcs_commands", "git,svn", "Comma separated list of version control commands to run for repositories on hosts without an API: git, hg and svn.")
+	metaCacheTTL         = flag.Duration("meta_cache_ttl", 24*time.Hour, "Time to use resolved go-import meta tags before fetching the tags again. Zero disables the cache.")
+	maxArchiveSize       = flag.Int64("max_archive_size", 0, "Maximum size in bytes of repository archives downloaded to fetch directory files in one request. Zero fetches files one by one.")
+	maxFileSize          = flag.Int64("max_file_size", 4<<20, "Maximum size in bytes of a fetched file. Larger files are skipped. Zero disables the limit.")
+	maxFetchSize         = flag.Int64("max_fetch_size", 32<<20, "Maximum size in bytes of the files fetched for a package. Packages with larger files are not found. Zero disables the limit.")
+	skipDirs             = flag.String("skip_dirs", "testdata,vendor", "Comma separated list of directory names that are not fetched.")
+	respCacheSize        = flag.Int("response_cache_size", 4<<20, "Maximum size in bytes of the validators of the API responses kept to send conditional requests when crawling. Zero disables conditional requests.")
+	gitFallback          = flag.Bool("git_fallback", false, "Clone import paths without a go-import meta tag as Git repositories over HTTPS.")
+	moduleProxy          = flag.String("module_proxy", "", "Base URL of a Go module proxy to fetch packages in modules from, for example https://proxy.golang.org.")
+	checksumDB           = flag.String("checksum_db", "", "Base URL of the Go checksum database to verify the module zips fetched from module_proxy against, for example https://sum.golang.org. Modules not matching the checksum database are not indexed.")
+	sourceHosts          = flag.String("source_hosts", "", "Comma separated list of source browsers for View Source links to repositories on hosts without an API, each as host=kind:baseURL where kind is cgit, gitweb or sourcegraph.")
+	giteaHosts           = flag.String("gitea_hosts", "", "Comma separated list of Gitea or Forgejo instances to fetch packages from, each as baseURL or baseURL=token.")
+	outboundCheck        = flag.Bool("outbound_check", true, "Fetch only from hosts resolving to public addresses, not from the private, loopback, link-local and reserved address ranges, such as the cloud metadata endpoints. The configured hosts, such as gitlab_hosts, gitea_hosts, module_proxy and the hosts in the credentials file, are always fetched.")
+	outboundAllow        = flag.String("outbound_allow", "", "Comma separated list of hosts and address ranges fetched with outbound_check, each as an IP address, a CIDR network, a host name or a *.domain pattern. The HTTP proxies on private addresses must be allowed.")
+	outboundDeny         = flag.String("outbound_deny", "", "Comma separated list of hosts and address ranges never fetched with outbound_check, each as an IP address, a CIDR network, a host name or a *.domain pattern.")
+	bitbucketServerHosts = flag.String("bitbucket_server_hosts", "", "Comma separated list of Bitbucket Server or Data Center instances to fetch packages from, each as baseURL or baseURL=token.")
+	azureDevOpsHosts     = flag.String("azure_devops_hosts", "", "Comma separated list of Azure DevOps organizations or collections to fetch packages from, each as baseURL or baseURL=token where token is a personal access token.")
+	fixturesMode         = flag.String("fixtures", "", "Record the responses of the source hosts to fixtures_dir or replay the recorded responses: record or replay. Empty fetches from the hosts.")
+	fixturesDir          = flag.String("fixtures_dir", "", "Directory of the responses recorded and replayed with the fixtures flag.")
+	goRoots              = flag.String("goroots", "", "Comma separated list of Go distribution trees to read the standard library from instead of golang.org, newest first, each as dir or version=dir. The version defaults to the first line of the VERSION file of the tree. Older versions are served at import/path@version.")
 )
 
 type timeoutConn struct {
@@ -32,8 +58,9 @@ func (c timeoutConn) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func timeoutDial(network, addr string) (net.Conn, error) {
-	c, err := net.DialTimeout(network, addr, *dialTimeout)
+// timeoutDial dials with the outbound policy of gosrc.
+func timeoutDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	c, err := gosrc.OutboundDialContext(&net.Dialer{Timeout: *dialTimeout})(ctx, network, addr)
 	if err != nil {
 		return c, err
 	}
@@ -52,9 +79,20 @@ type transport struct {
 }
 
 func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	timer := time.AfterFunc(*requestTimeout, func() {
-		t.t.CancelRequest(req)
-		log.Printf("Canceled request for %s", req.URL)
+	rt, timeout := &t.t, *requestTimeout
+	configMu.RLock()
+	hc := hostConfigs[req.URL.Host]
+	configMu.RUnlock()
+	if hc != nil {
+		rt, timeout = hc.t, hc.requestTimeout()
+	}
+	timer := time.AfterFunc(timeout, func() {
+		rt.CancelRequest(req)
+		args := []interface{}{"url", req.URL.String()}
+		if id := req.Header.Get(requestIDHeader); id != "" {
+			args = append(args, "request_id", id)
+		}
+		slog.Warn("canceled request", args...)
 	})
 	defer timer.Stop()
 	if req.URL.Host == "api.github.com" && gitHubCredentials != "" {
@@ -67,12 +105,145 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if userAgent != "" {
 		req.Header.Set("User-Agent", userAgent)
 	}
-	return t.t.RoundTrip(req)
+	resp, err := rt.RoundTrip(req)
+	if err == nil && hc != nil && hc.maxResponseSize > 0 {
+		if resp.ContentLength > hc.maxResponseSize {
+			resp.Body.Close()
+			return nil, errResponseTooLarge
+		}
+		resp.Body = &limitedBody{resp.Body, hc.maxResponseSize}
+	}
+	return resp, err
 }
 
 var httpClient = &http.Client{Transport: &transport{
 	t: http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		Dial:  timeoutDial,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           timeoutDial,
 		ResponseHeaderTimeout: *requestTimeout / 2,
 	}}}
+
+// splitHostToken splits a host=token element of a host list flag.
+func splitHostToken(h string) (string, string) {
+	if i := strings.Index(h, "="); i >= 0 {
+		return h[:i], h[i+1:]
+	}
+	return h, ""
+}
+
+// addGitLabHosts registers the GitLab instances in spec with gosrc. The
+// gitlab.com service is built in, but it can be listed to set a token.
+func addGitLabHosts(spec string) {
+	for _, h := range strings.Split(spec, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		gosrc.AddGitLabHost(splitHostToken(h))
+	}
+}
+
+// addSourceHosts registers the source browsers in spec with gosrc.
+func addSourceHosts(spec string) error {
+	for _, h := range strings.Split(spec, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		host, browser := splitHostToken(h)
+		i := strings.Index(browser, ":")
+		if i < 0 {
+			return fmt.Errorf("source host %q does not have the form host=kind:baseURL", h)
+		}
+		if err := gosrc.AddSourceHost(host, browser[:i], browser[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addGiteaHosts registers the Gitea and Forgejo instances in spec with gosrc.
+func addGiteaHosts(spec string) error {
+	for _, h := range strings.Split(spec, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if err := gosrc.AddGiteaHost(splitHostToken(h)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBitbucketServerHosts registers the Bitbucket Server and Data Center
+// instances in spec with gosrc.
+func addBitbucketServerHosts(spec string) error {
+	for _, h := range strings.Split(spec, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if err := gosrc.AddBitbucketServerHost(splitHostToken(h)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addAzureDevOpsHosts registers the Azure DevOps organizations and
+// collections in spec with gosrc.
+func addAzureDevOpsHosts(spec string) error {
+	for _, h := range strings.Split(spec, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if err := gosrc.AddAzureDevOpsHost(splitHostToken(h)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchTransport sends the request ID and the trace context in ctx with
+// outbound requests and times the requests in client spans.
+type fetchTransport struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+func (t fetchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+2)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	if id := requestID(t.ctx); id != "" {
+		r.Header.Set(requestIDHeader, id)
+	}
+	_, s := startSpan(t.ctx, "HTTP "+req.Method, spanKindClient)
+	if s != nil {
+		r.Header.Set(traceparentHeader, s.traceparent())
+		s.setAttr("http.method", req.Method)
+		s.setAttr("http.url", req.URL.String())
+	}
+	resp, err := t.rt.RoundTrip(r)
+	if err == nil {
+		s.setAttr("http.status_code", resp.StatusCode)
+	}
+	s.finish(err)
+	return resp, err
+}
+
+// fetchClient returns the client used to fetch packages for ctx.
+func fetchClient(ctx context.Context) *http.Client {
+	if requestID(ctx) == "" && spanFromContext(ctx) == nil {
+		return httpClient
+	}
+	c := *httpClient
+	c.Transport = fetchTransport{ctx, httpClient.Transport}
+	return &c
+}
@@ -0,0 +1,159 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+// The compare page at /compare?from=<path>&to=<path> and the compare
+// endpoint of the API at /api/v1/compare compare the exported APIs of two
+// packages, such as a fork and the upstream package, or of two saved versions
+// of a package when the paths are followed by @version.
+
+// comparePackage returns the document of the package or the saved version of
+// the package with the spec import/path or import/path@version.
+func comparePackage(req *http.Request, spec string) (*doc.Package, error) {
+	importPath, version := spec, ""
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		importPath, version = spec[:i], spec[i+1:]
+	}
+	importPath = strings.Trim(importPath, "/")
+	if !gosrc.IsValidPath(importPath) || !isAllowed(importPath) {
+		return nil, &httpError{status: http.StatusNotFound}
+	}
+	if err := checkBlocked(importPath); err != nil {
+		return nil, err
+	}
+	var pdoc *doc.Package
+	var err error
+	if version == "" {
+		pdoc, _, _, err = db.Get(importPath)
+	} else {
+		pdoc, err = db.GetVersion(importPath, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
+		return nil, &httpError{status: http.StatusNotFound}
+	}
+	return pdoc, nil
+}
+
+// compareAPIs returns the documents of the from and to parameters and the
+// changes from the API of from to the API of to, or nil changes if the API
+// summary of a document is not available.
+func compareAPIs(req *http.Request) (from, to *doc.Package, changes *apiChanges, err error) {
+	from, err = comparePackage(req, req.Form.Get("from"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	to, err = comparePackage(req, req.Form.Get("to"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if hasAPI(from) && hasAPI(to) {
+		changes = groupAPIChanges(doc.DiffAPI(from.API, to.API))
+	}
+	return from, to, changes, nil
+}
+
+// serveCompare serves the page comparing the APIs of the from and to
+// parameters. Without parameters, the page has the form to select the
+// packages.
+func serveCompare(resp http.ResponseWriter, req *http.Request) error {
+	data := map[string]interface{}{
+		"flashMessages": getFlashMessages(resp, req),
+		"from":          req.Form.Get("from"),
+		"to":            req.Form.Get("to"),
+	}
+	if req.Form.Get("from") == "" && req.Form.Get("to") == "" {
+		return executeTemplate(resp, "compare.html", http.StatusOK, nil, data)
+	}
+	from, to, changes, err := compareAPIs(req)
+	if err != nil {
+		return err
+	}
+	data["fromDoc"] = from
+	data["toDoc"] = to
+	data["changes"] = changes
+	return executeTemplate(resp, "compare.html", http.StatusOK, nil, data)
+}
+
+// apiV1ComparedPackage is a compared package in the compare endpoint of the
+// API.
+type apiV1ComparedPackage struct {
+	ImportPath string `json:"importPath"`
+	Version    string `json:"version,omitempty"`
+}
+
+// apiV1CompareSymbol is an exported symbol in the compare endpoint of the API.
+type apiV1CompareSymbol struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Decl string `json:"decl"`
+}
+
+// apiV1CompareChange is a changed symbol in the compare endpoint of the API.
+type apiV1CompareChange struct {
+	Name string             `json:"name"`
+	Old  apiV1CompareSymbol `json:"old"`
+	New  apiV1CompareSymbol `json:"new"`
+}
+
+func apiV1CompareSymbols(changes []doc.APIChange, old bool) []apiV1CompareSymbol {
+	symbols := []apiV1CompareSymbol{}
+	for _, c := range changes {
+		s := c.New
+		if old {
+			s = c.Old
+		}
+		symbols = append(symbols, apiV1CompareSymbol{s.Name, s.Kind, s.Decl})
+	}
+	return symbols
+}
+
+// serveAPIV1Compare serves the changes from the API of the from parameter to
+// the API of the to parameter as JSON. The response has status 409 if the
+// API summary of a package is not available.
+func serveAPIV1Compare(resp http.ResponseWriter, req *http.Request) error {
+	from, to, changes, err := compareAPIs(req)
+	if err != nil {
+		return err
+	}
+	if changes == nil {
+		return &httpError{status: http.StatusConflict}
+	}
+	data := struct {
+		From    apiV1ComparedPackage `json:"from"`
+		To      apiV1ComparedPackage `json:"to"`
+		Added   []apiV1CompareSymbol `json:"added"`
+		Removed []apiV1CompareSymbol `json:"removed"`
+		Changed []apiV1CompareChange `json:"changed"`
+	}{
+		From:    apiV1ComparedPackage{from.ImportPath, from.Version},
+		To:      apiV1ComparedPackage{to.ImportPath, to.Version},
+		Added:   apiV1CompareSymbols(changes.Added, false),
+		Removed: apiV1CompareSymbols(changes.Removed, true),
+		Changed: []apiV1CompareChange{},
+	}
+	for _, c := range changes.Changed {
+		data.Changed = append(data.Changed, apiV1CompareChange{
+			Name: c.Name,
+			Old:  apiV1CompareSymbol{c.Old.Name, c.Old.Kind, c.Old.Decl},
+			New:  apiV1CompareSymbol{c.New.Name, c.New.Kind, c.New.Decl},
+		})
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
@@ -0,0 +1,38 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestAPIV1CompareSymbols(t *testing.T) {
+	a := doc.APISymbol{Name: "A", Kind: "func", Decl: "func A()"}
+	b := doc.APISymbol{Name: "B", Kind: "type", Decl: "type B struct"}
+	changes := []doc.APIChange{{Name: "A", Old: &a}, {Name: "B", Old: &a, New: &b}}
+	if got, want := apiV1CompareSymbols(changes, true), []apiV1CompareSymbol{{"A", "func", "func A()"}, {"A", "func", "func A()"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("apiV1CompareSymbols(old) = %v, want %v", got, want)
+	}
+	if got := apiV1CompareSymbols(nil, false); got == nil || len(got) != 0 {
+		t.Errorf("apiV1CompareSymbols(nil) = %#v, want empty list", got)
+	}
+}
+
+func TestComparePackageBadSpec(t *testing.T) {
+	req := httptest.NewRequest("GET", "/compare", nil)
+	for _, spec := range []string{"", "/", "@v1.0.0"} {
+		_, err := comparePackage(req, spec)
+		if e, ok := err.(*httpError); !ok || e.status != http.StatusNotFound {
+			t.Errorf("comparePackage(%q) returned %v, want status 404", spec, err)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements loading the flags from a configuration file and from
+// environment variables, and reloading the configuration on SIGHUP.
+//
+// The reload applies only the flags in reloadableFlags; the other flags
+// change on restart. The new values are checked and the files named by the
+// flags are read before anything changes, so a configuration that fails to
+// load leaves the running configuration unchanged. The values are then
+// swapped in under configMu, which guards the reloadable flags and the
+// settings read from the files.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+var configFile = flag.String("config", "", "File with flag values, one name = value per line in TOML syntax. Environment variables named GDDO_ followed by the upper case flag name override the file, and command line flags override both. The file is read again on SIGHUP to apply the background task intervals, api_tokens, auth_header_role, credentials and host_config; the other flags change on restart.")
+
+// configMu guards the reloadable flags, apiTokens and hostConfigs.
+var configMu sync.RWMutex
+
+// reloadableFlags is the set of the names of the flags applied on SIGHUP.
+// The flags are strings or durations.
+var reloadableFlags = map[string]bool{
+	"api_tokens":       true,
+	"auth_header_role": true,
+	"credentials":      true,
+	"host_config":      true,
+}
+
+// reloadableDuration defines a duration flag applied on SIGHUP. The flag is
+// read with configMu held.
+func reloadableDuration(name string, value time.Duration, usage string) *time.Duration {
+	reloadableFlags[name] = true
+	return flag.Duration(name, value, usage)
+}
+
+// envPrefix is the prefix of the environment variables setting flags.
+const envPrefix = "GDDO_"
+
+// envName returns the name of the environment variable setting the flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(flagName))
+}
+
+// parseConfig parses a configuration file. Each line of the file has the form
+//
+//	name = value
+//
+// where value is a TOML basic or literal string, a number, a boolean or an
+// unquoted string such as a duration. Blank lines and lines starting with #
+// are ignored.
+func parseConfig(r io.Reader) (map[string]string, error) {
+	m := make(map[string]string)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("config line %d: expected name = value", n)
+		}
+		name, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if name == "" || strings.ContainsAny(name, " \t[]\"'") {
+			return nil, fmt.Errorf("config line %d: bad name %q", n, name)
+		}
+		switch {
+		case strings.HasPrefix(value, `"`):
+			v, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("config line %d: bad string %s", n, value)
+			}
+			value = v
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") || strings.Contains(value[1:len(value)-1], "'") {
+				return nil, fmt.Errorf("config line %d: bad string %s", n, value)
+			}
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, "#"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("config line %d: duplicate name %s", n, name)
+		}
+		m[name] = value
+	}
+	return m, s.Err()
+}
+
+// applyConfig sets the flags in fs that are not in cmdline to the value of
+// the environment variable, the value in the file or the default, in that
+// order.
+func applyConfig(fs *flag.FlagSet, file map[string]string, getenv func(string) string, cmdline map[string]bool) error {
+	for name := range file {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("config: unknown flag %s", name)
+		}
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || cmdline[f.Name] || f.Name == "config" {
+			return
+		}
+		v, ok := getenv(envName(f.Name)), true
+		if v == "" {
+			v, ok = file[f.Name]
+		}
+		if !ok {
+			v = f.DefValue
+		}
+		if v != f.Value.String() {
+			if e := fs.Set(f.Name, v); e != nil {
+				err = fmt.Errorf("config: %s: %v", f.Name, e)
+			}
+		}
+	})
+	return err
+}
+
+// cmdlineFlags is the set of flags set on the command line.
+var cmdlineFlags map[string]bool
+
+// readConfigFile returns the values in the -config file.
+func readConfigFile() (map[string]string, error) {
+	if *configFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	file, err := parseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", *configFile, err)
+	}
+	return file, nil
+}
+
+// loadConfig sets the flags from the environment and the -config file at
+// startup.
+func loadConfig() error {
+	if cmdlineFlags == nil {
+		cmdlineFlags = make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { cmdlineFlags[f.Name] = true })
+	}
+	file, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	return applyConfig(flag.CommandLine, file, os.Getenv, cmdlineFlags)
+}
+
+// stageFlags returns a flag set with copies of the flags in fs. The values of
+// the flags are of the types defined by the flag package.
+func stageFlags(fs *flag.FlagSet) *flag.FlagSet {
+	staged := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	fs.VisitAll(func(f *flag.Flag) {
+		v := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value)
+		v.Set(f.Value.String())
+		staged.Var(v, f.Name, f.Usage)
+		staged.Lookup(f.Name).DefValue = f.DefValue
+	})
+	return staged
+}
+
+// configure applies the flags at startup. The reloadable flags are applied
+// again by reloadConfig.
+func configure() error {
+	if err := setupLogging(); err != nil {
+		return err
+	}
+	doc.SetDefaultGOOS(*defaultGOOS)
+	doc.SetBuildTags(strings.Split(*buildTags, ","))
+	doc.SetSizeLimits(*maxDeclSize, *maxDocSize)
+	gosrc.SetModuleProxy(*moduleProxy)
+	gosrc.SetChecksumDB(*checksumDB)
+	gosrc.SetGitFallback(*gitFallback)
+	gosrc.SetMaxArchiveSize(*maxArchiveSize)
+	gosrc.SetFetchLimits(*maxFileSize, *maxFetchSize)
+	gosrc.SetSkipDirs(strings.Split(*skipDirs, ","))
+	if err := gosrc.SetVCSCommands(strings.Split(*vcsCommands, ",")); err != nil {
+		return err
+	}
+	if err := gosrc.SetGoRoots(strings.Split(*goRoots, ",")); err != nil {
+		return err
+	}
+	if err := gosrc.SetOutboundPolicy(*outboundCheck, strings.Split(*outboundAllow, ","), strings.Split(*outboundDeny, ",")); err != nil {
+		return err
+	}
+	if err := gosrc.SetFixtures(*fixturesMode, *fixturesDir); err != nil {
+		return err
+	}
+	setAllowList(*allowPrefixes)
+	if err := setRobotRateLimits(*robotRateLimits); err != nil {
+		return err
+	}
+	docCrawler.MaxAge = *maxAge
+	if *maxHostErrorRate < 0 || *maxHostErrorRate > 1 {
+		return fmt.Errorf("max_host_error_rate %v is not between 0 and 1", *maxHostErrorRate)
+	}
+	docCrawler.MaxHostErrorRate = *maxHostErrorRate
+	if err := setFetchExecutor(); err != nil {
+		return err
+	}
+	setWebhooks()
+	install, err := readReloadable(func(name string) string { return flag.Lookup(name).Value.String() })
+	if err != nil {
+		return err
+	}
+	configMu.Lock()
+	install()
+	configMu.Unlock()
+	return nil
+}
+
+// readReloadable checks the reloadable flags with the values returned by
+// value and reads the files named by the flags. It returns the function
+// installing the settings read from the files, called with configMu held.
+func readReloadable(value func(name string) string) (func(), error) {
+	if r, ok := parseRole(value("auth_header_role")); !ok || r == roleNone {
+		return nil, fmt.Errorf("unknown auth_header_role %q", value("auth_header_role"))
+	}
+	tokens, err := readAPITokens(value("api_tokens"))
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := readCredentials(value("credentials"))
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := readHostConfig(value("host_config"))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		apiTokens = tokens
+		hostConfigs = hosts
+		gosrc.SetCredentials(credentials)
+	}, nil
+}
+
+// reloadConfig loads the configuration again and applies the reloadable
+// flags. It returns the names of the changed flags that apply on restart.
+func reloadConfig() ([]string, error) {
+	file, err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	staged := stageFlags(flag.CommandLine)
+	if err := applyConfig(staged, file, os.Getenv, cmdlineFlags); err != nil {
+		return nil, err
+	}
+	changed := make(map[string]string)
+	var restart []string
+	staged.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		if v == flag.Lookup(f.Name).Value.String() {
+			return
+		}
+		if reloadableFlags[f.Name] {
+			changed[f.Name] = v
+		} else {
+			restart = append(restart, f.Name)
+		}
+	})
+	install, err := readReloadable(func(name string) string {
+		if v, ok := changed[name]; ok {
+			return v
+		}
+		return flag.Lookup(name).Value.String()
+	})
+	if err != nil {
+		return nil, err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	for name, v := range changed {
+		// The value was checked by setting the staged flag.
+		flag.Set(name, v)
+	}
+	install()
+	return restart, nil
+}
+
+// reloadOnSIGHUP loads the configuration again when the process receives
+// SIGHUP.
+func reloadOnSIGHUP() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		restart, err := reloadConfig()
+		if err != nil {
+			slog.Error("reload configuration", "err", err)
+			continue
+		}
+		if len(restart) > 0 {
+			slog.Warn("changed flags apply on restart", "flags", strings.Join(restart, ","))
+		}
+		slog.Info("reloaded configuration")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	const config = `
+# Crawler settings.
+max_age = 12h
+github_token = "abc\"def"
+build_tags = 'a,b'
+compress = true # gzip responses
+db-server = "redis://127.0.0.1:6379"
+`
+	m, err := parseConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"max_age":      "12h",
+		"github_token": `abc"def`,
+		"build_tags":   "a,b",
+		"compress":     "true",
+		"db-server":    "redis://127.0.0.1:6379",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("parseConfig() = %v, want %v", m, want)
+	}
+
+	for _, bad := range []string{
+		"max_age",
+		"[server]\nhttp = ':80'",
+		`s = "unterminated`,
+		"s = 'unterminated",
+		"a = 1\na = 2",
+	} {
+		if _, err := parseConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseConfig(%q) returned nil error", bad)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	interval := fs.Duration("crawl_interval", time.Hour, "")
+	host := fs.String("db-host", "localhost", "")
+	cmd := fs.String("http", ":8080", "")
+	if err := fs.Parse([]string{"-http", ":9090"}); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := map[string]bool{"http": true}
+	env := map[string]string{"GDDO_DB_HOST": "redis.example.com"}
+	getenv := func(name string) string { return env[name] }
+
+	file := map[string]string{"crawl_interval": "10m", "db-host": "db.example.com", "http": ":80"}
+	if err := applyConfig(fs, file, getenv, cmdline); err != nil {
+		t.Fatal(err)
+	}
+	if *interval != 10*time.Minute || *host != "redis.example.com" || *cmd != ":9090" {
+		t.Errorf("after apply: interval %v, host %q, http %q", *interval, *host, *cmd)
+	}
+
+	// Removing a value from the file restores the default.
+	env = nil
+	if err := applyConfig(fs, nil, getenv, cmdline); err != nil {
+		t.Fatal(err)
+	}
+	if *interval != time.Hour || *host != "localhost" {
+		t.Errorf("after reload: interval %v, host %q", *interval, *host)
+	}
+
+	if err := applyConfig(fs, map[string]string{"unknown": "x"}, getenv, cmdline); err == nil {
+		t.Error("unknown flag in file not reported")
+	}
+	if err := applyConfig(fs, map[string]string{"crawl_interval": "often"}, getenv, cmdline); err == nil {
+		t.Error("bad value not reported")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(tokens, []byte("ops operator\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config")
+	writeConfig := func(s string) {
+		t.Helper()
+		if err := ioutil.WriteFile(config, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(file string, cmdline map[string]bool, tokens []apiToken) {
+		*configFile, cmdlineFlags, apiTokens = file, cmdline, tokens
+		for _, name := range []string{"crawl_interval", "api_tokens", "max_age"} {
+			f := flag.Lookup(name)
+			f.Value.Set(f.DefValue)
+		}
+	}(*configFile, cmdlineFlags, apiTokens)
+	*configFile, cmdlineFlags = config, make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { cmdlineFlags[f.Name] = true })
+
+	writeConfig("crawl_interval = 10m\napi_tokens = '" + tokens + "'\nmax_age = 1h\n")
+	restart, err := reloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := "," + strings.Join(restart, ",") + ","; !strings.Contains(r, ",max_age,") || strings.Contains(r, ",crawl_interval,") {
+		t.Errorf("reloadConfig() returned restart flags %v, want max_age", restart)
+	}
+	if v := flag.Lookup("crawl_interval").Value.String(); v != "10m0s" {
+		t.Errorf("crawl_interval = %s, want 10m0s", v)
+	}
+	if *maxAge != 24*time.Hour {
+		t.Errorf("max_age = %v, want the value until restart", *maxAge)
+	}
+	if len(apiTokens) != 1 || apiTokens[0].role != roleOperator {
+		t.Errorf("apiTokens = %v, want the token in the file", apiTokens)
+	}
+
+	// A configuration that fails to load changes nothing.
+	writeConfig("crawl_interval = 1m\napi_tokens = '" + filepath.Join(dir, "missing") + "'\n")
+	if _, err := reloadConfig(); err == nil {
+		t.Error("reloadConfig() with a missing tokens file returned nil error")
+	}
+	if v := flag.Lookup("crawl_interval").Value.String(); v != "10m0s" {
+		t.Errorf("crawl_interval after failed reload = %s, want 10m0s", v)
+	}
+	if len(apiTokens) != 1 {
+		t.Errorf("apiTokens after failed reload = %v, want the old tokens", apiTokens)
+	}
+	writeConfig("crawl_interval = often\n")
+	if _, err := reloadConfig(); err == nil {
+		t.Error("reloadConfig() with a bad duration returned nil error")
+	}
+}
@@ -7,95 +7,133 @@
 package main
 
 import (
-	"log"
-	"regexp"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
-	"github.com/golang/gddo/doc"
-	"github.com/golang/gddo/gosrc"
+	"github.com/golang/gddo/crawler"
 )
 
-var testdataPat = regexp.MustCompile(`/testdata(?:/|$)`)
+var (
+	crawlWebhooks      = flag.String("crawl_webhooks", "", "Comma separated list of URLs receiving a POST with a JSON event after each package is crawled, hidden, deleted, quarantined or blocked.")
+	crawlWebhookSecret = flag.String("crawl_webhook_secret", "", "Secret of the HMAC-SHA256 signatures of the crawl webhook events sent in the X-Gddo-Signature header.")
+	maxHostErrorRate   = flag.Float64("max_host_error_rate", 0, "Rate of failed and rate limited fetches from a host in the current and the previous hour, between 0 and 1, above which the background crawl of the packages on the host is delayed by an hour. Zero disables the limit.")
+	moduleIndex        = flag.String("module_index", "https://index.golang.org/index", "URL of the module index read by the module index task.")
+	fetchExecutor      = flag.String("fetch_executor", "local", "Where packages are fetched and parsed: local, in the server process, or process, in a worker process started for each fetch with the fetch limits.")
+	fetchSandbox       = flag.String("fetch_sandbox", "", "Space separated command line running the fetch worker processes in a sandbox, such as nsjail or bwrap with its arguments. The command line of the worker is appended.")
+	fetchCPULimit      = flag.Duration("fetch_cpu_limit", time.Minute, "CPU time of a fetch worker process. Zero disables the limit.")
+	fetchMemoryLimit   = flag.Int64("fetch_memory_limit", 4<<30, "Size in bytes of the address space of a fetch worker process. Zero disables the limit.")
+	fetchTimeout       = flag.Duration("fetch_timeout", 5*time.Minute, "Time after which a fetch worker process is killed. Zero disables the limit.")
+	fetchProcesses     = flag.Int("fetch_processes", 4, "Maximum number of concurrent fetch worker processes. Zero does not limit the number.")
+	fetchWorker        = flag.Bool("fetch_worker", false, "Serve one fetch on the standard input and output and exit. The fetch executor starts the worker processes with this flag.")
+)
 
-// crawlDoc fetches the package documentation from the VCS and updates the database.
-func crawlDoc(source string, importPath string, pdoc *doc.Package, hasSubdirs bool, nextCrawl time.Time) (*doc.Package, error) {
-	message := []interface{}{source}
-	defer func() {
-		message = append(message, importPath)
-		log.Println(message...)
-	}()
+// webhooks sends the events of the crawler and of the blocked packages.
+// The URLs are set by configure.
+var webhooks = &crawler.Webhooks{}
 
-	if !nextCrawl.IsZero() {
-		d := time.Since(nextCrawl) / time.Hour
-		if d > 0 {
-			message = append(message, "late:", int64(d))
-		}
-	}
+// docCrawler crawls packages on demand and in the background tasks. The
+// database and the maximum age are set by main and configure.
+var docCrawler = &crawler.Crawler{
+	Client:    fetchClient,
+	Allowed:   isAllowed,
+	Skip:      isMirrored,
+	Changed:   packageChanged,
+	Webhooks:  webhooks,
+	Logger:    logger,
+	StartSpan: startCrawlSpan,
+}
+
+// crawlSpan records the spans of the crawler.
+type crawlSpan struct {
+	s *span
+}
+
+func (s crawlSpan) SetAttr(key string, value interface{}) { s.s.setAttr(key, value) }
+func (s crawlSpan) Finish(err error)                      { s.s.finish(err) }
 
-	etag := ""
-	if pdoc != nil {
-		etag = pdoc.Etag
-		message = append(message, "etag:", etag)
+func startCrawlSpan(ctx context.Context, name string, client bool) (context.Context, crawler.Span) {
+	kind := spanKindInternal
+	if client {
+		kind = spanKindClient
 	}
+	ctx, s := startSpan(ctx, name, kind)
+	return ctx, crawlSpan{s}
+}
 
-	start := time.Now()
-	var err error
-	if strings.HasPrefix(importPath, "code.google.com/p/go.") {
-		// Old import path for Go sub-repository.
-		pdoc = nil
-		err = gosrc.NotFoundError{Message: "old Go sub-repo", Redirect: "golang.org/x/" + importPath[len("code.google.com/p/go."):]}
-	} else if blocked, e := db.IsBlocked(importPath); blocked && e == nil {
-		pdoc = nil
-		err = gosrc.NotFoundError{Message: "blocked."}
-	} else if testdataPat.MatchString(importPath) {
-		pdoc = nil
-		err = gosrc.NotFoundError{Message: "testdata."}
-	} else {
-		var pdocNew *doc.Package
-		pdocNew, err = doc.Get(httpClient, importPath, etag)
-		message = append(message, "fetch:", int64(time.Since(start)/time.Millisecond))
-		if err == nil && pdocNew.Name == "" && !hasSubdirs {
-			for _, e := range pdocNew.Errors {
-				message = append(message, "err:", e)
-			}
-			pdoc = nil
-			err = gosrc.NotFoundError{Message: "no Go files or subdirs"}
-		} else if err != gosrc.ErrNotModified {
-			pdoc = pdocNew
+// setFetchExecutor sets the executor of the crawler selected by the flags.
+// The process executor runs the server again with -fetch_worker, so a crash
+// or a pathological repository in a fetch does not take down the serving
+// process.
+func setFetchExecutor() error {
+	switch *fetchExecutor {
+	case "local":
+		docCrawler.Executor = crawler.LocalExecutor{}
+	case "process":
+		command, err := crawler.WorkerCommand(strings.Fields(*fetchSandbox), "-fetch_worker")
+		if err != nil {
+			return err
 		}
+		limits := crawler.Limits{CPUTime: *fetchCPULimit, Memory: *fetchMemoryLimit, Timeout: *fetchTimeout}
+		docCrawler.Executor = crawler.NewProcessExecutor(command, limits, *fetchProcesses)
+	default:
+		return fmt.Errorf("unknown fetch_executor %q", *fetchExecutor)
 	}
+	return nil
+}
 
-	nextCrawl = start.Add(*maxAge)
-	switch {
-	case strings.HasPrefix(importPath, "github.com/") || (pdoc != nil && len(pdoc.Errors) > 0):
-		nextCrawl = start.Add(*maxAge * 7)
-	case strings.HasPrefix(importPath, "gist.github.com/"):
-		// Don't spend time on gists. It's silly thing to do.
-		nextCrawl = start.Add(*maxAge * 30)
+// setWebhooks configures the crawl webhooks from the flags. The client is
+// set once, before the first delivery.
+func setWebhooks() {
+	if webhooks.Client == nil {
+		webhooks.Client = &http.Client{Timeout: *requestTimeout}
 	}
+	webhooks.SetTargets(strings.Split(*crawlWebhooks, ","), *crawlWebhookSecret)
+}
+
+// packageBlocked sends the blocked event of the pattern.
+func packageBlocked(pattern, reason string) {
+	webhooks.Send(crawler.Event{Kind: crawler.EventBlocked, Path: pattern, Message: reason})
+}
+
+// packageChanged refreshes the cached pages and docsets after the package is
+// saved or deleted.
+func packageChanged(importPath string) {
+	pages.invalidate(importPath)
+	markDocsetsStale(importPath)
+}
 
-	switch {
-	case err == nil:
-		message = append(message, "put:", pdoc.Etag)
-		if err := db.Put(pdoc, nextCrawl, false); err != nil {
-			log.Printf("ERROR db.Put(%q): %v", importPath, err)
+// changesSeq is the sequence number of the last change read by
+// readChanges or -1 before the first read. It is only used by the
+// background task.
+var changesSeq int64 = -1
+
+// readChanges calls packageChanged for the packages saved or deleted by other
+// processes, such as gddo-crawler, since the last call.
+func readChanges() error {
+	if changesSeq < 0 {
+		seq, err := db.LastChange()
+		if err != nil {
+			return err
+		}
+		changesSeq = seq
+		return nil
+	}
+	const n = 1000
+	for {
+		changes, err := db.Changes(changesSeq, n)
+		if err != nil {
+			return err
 		}
-		return pdoc, nil
-	case err == gosrc.ErrNotModified:
-		message = append(message, "touch")
-		if err := db.SetNextCrawlEtag(pdoc.ProjectRoot, pdoc.Etag, nextCrawl); err != nil {
-			log.Printf("ERROR db.SetNextCrawlEtag(%q): %v", importPath, err)
+		for _, ch := range changes {
+			packageChanged(ch.Path)
+			changesSeq = ch.Seq
 		}
-		return pdoc, nil
-	case gosrc.IsNotFound(err):
-		message = append(message, "notfound:", err)
-		if err := db.Delete(importPath); err != nil {
-			log.Printf("ERROR db.Delete(%q): %v", importPath, err)
+		if len(changes) < n {
+			return nil
 		}
-		return nil, err
-	default:
-		message = append(message, "ERROR:", err)
-		return nil, err
 	}
 }
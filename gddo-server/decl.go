@@ -0,0 +1,77 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	htemp "html/template"
+	"net/url"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+// Long type declarations are collapsed on the package page to the first
+// -decl_collapse_lines lines. The whole declaration is in the page, so the
+// anchors of the fields and methods, such as #Client.Timeout, work; the
+// script of the page expands the declaration with the target of the link.
+// Without the script, the link below the declaration loads the page with
+// the expand query parameter, a comma separated list of type names or
+// "all", to show the declarations in full.
+
+var declCollapseLines = flag.Int("decl_collapse_lines", 40, "Collapse the type declarations longer than this number of lines on the package page. Zero disables the collapse.")
+
+// Bootstrap pre elements have a line height of 1.42857143em and a vertical
+// padding of 9.5px.
+const preLineHeight = 1.42857143
+
+// setExpand sets the types with expanded declarations from the value of the
+// expand query parameter and the section of the page.
+func (pdoc *tdoc) setExpand(expand, section string) {
+	pdoc.section = section
+	for _, name := range strings.Split(expand, ",") {
+		switch name {
+		case "":
+		case "all":
+			pdoc.expandAll = true
+		default:
+			if pdoc.expand == nil {
+				pdoc.expand = make(map[string]bool)
+			}
+			pdoc.expand[name] = true
+		}
+	}
+}
+
+// expandURL returns the URL of the page with the declaration of the type
+// expanded.
+func (pdoc *tdoc) expandURL(name string) string {
+	q := "expand=" + url.QueryEscape(name)
+	if pdoc.section != "" {
+		// The section parameter is first; see isView.
+		q = "section=" + url.QueryEscape(pdoc.section) + "&" + q
+	}
+	return "?" + q + "#" + name
+}
+
+// TypeDecl returns the HTML of the declaration of the type, collapsed if
+// the declaration is long and not expanded.
+func (pdoc *tdoc) TypeDecl(t *doc.Type) htemp.HTML {
+	n := strings.Count(t.Decl.Text, "\n") + 1
+	if *declCollapseLines <= 0 || n <= *declCollapseLines || pdoc.expandAll || pdoc.expand[t.Name] {
+		return codeFn(t.Decl, t)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<pre class="x-collapsed" style="max-height: calc(%.2fem + 19px)">`, float64(*declCollapseLines)*preLineHeight)
+	writeCode(&buf, t.Decl, t)
+	buf.WriteString(`</pre><p class="x-expand"><a href="`)
+	htemp.HTMLEscape(&buf, []byte(pdoc.expandURL(t.Name)))
+	fmt.Fprintf(&buf, `">Show all %d lines</a></p>`, n)
+	return htemp.HTML(buf.String())
+}
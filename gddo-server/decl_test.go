@@ -0,0 +1,48 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestTypeDecl(t *testing.T) {
+	defer func(n int) { *declCollapseLines = n }(*declCollapseLines)
+	*declCollapseLines = 2
+
+	typ := &doc.Type{
+		Name: "Client",
+		Decl: doc.Code{
+			Text:        "type Client struct {\n\tTimeout int\n\tJar int\n}",
+			Annotations: []doc.Annotation{{Pos: 22, End: 29, Kind: doc.AnchorAnnotation}, {Pos: 35, End: 38, Kind: doc.AnchorAnnotation}},
+		},
+	}
+	anchor := `<span id="Client.Timeout"><a class="x-anchor" href="#Client.Timeout">Timeout</a></span>`
+
+	pdoc := newTDoc(&doc.Package{Types: []*doc.Type{typ}})
+	got := string(pdoc.TypeDecl(typ))
+	if !strings.HasPrefix(got, `<pre class="x-collapsed"`) || !strings.Contains(got, anchor) || !strings.Contains(got, `<a href="?expand=Client#Client">Show all 4 lines</a>`) {
+		t.Errorf("TypeDecl() = %s, want collapsed declaration", got)
+	}
+
+	pdoc.setExpand("", "C")
+	if got := string(pdoc.TypeDecl(typ)); !strings.Contains(got, `href="?section=C&amp;expand=Client#Client"`) {
+		t.Errorf("TypeDecl() in section = %s, want link to the section", got)
+	}
+
+	for _, expand := range []string{"Other,Client", "all"} {
+		pdoc := newTDoc(&doc.Package{Types: []*doc.Type{typ}})
+		pdoc.setExpand(expand, "")
+		got := string(pdoc.TypeDecl(typ))
+		if !strings.HasPrefix(got, "<pre>") || !strings.Contains(got, anchor) || strings.Contains(got, "x-expand") {
+			t.Errorf("TypeDecl() with expand=%s = %s, want expanded declaration", expand, got)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/md5"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var devMode = flag.Bool("dev", false, "Development mode: before each request, check the files in the -assets directory and parse the templates and clear the static file caches if the files changed.")
+
+// assetsWatcher detects changes to the files in directories by comparing the
+// names, sizes and modification times of the files.
+type assetsWatcher struct {
+	dirs []string
+
+	mu    sync.Mutex
+	state string
+}
+
+func newAssetsWatcher(dirs ...string) (*assetsWatcher, error) {
+	state, err := dirState(dirs)
+	if err != nil {
+		return nil, err
+	}
+	return &assetsWatcher{dirs: dirs, state: state}, nil
+}
+
+// dirState returns a hash of the names, sizes and modification times of the
+// files in the directory trees.
+func dirState(dirs []string) (string, error) {
+	h := md5.New()
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.Mode().IsRegular() {
+				fmt.Fprintf(h, "%s %d %d\n", p, fi.Size(), fi.ModTime().UnixNano())
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// changed returns true if the files changed since the last call.
+func (w *assetsWatcher) changed() (bool, error) {
+	state, err := dirState(w.dirs)
+	if err != nil {
+		return false, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if state == w.state {
+		return false, nil
+	}
+	w.state = state
+	return true, nil
+}
+
+// devHandler calls reload before serving a request if the assets changed.
+type devHandler struct {
+	h       http.Handler
+	watcher *assetsWatcher
+	reload  func() error
+}
+
+func (h devHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	changed, err := h.watcher.changed()
+	if err == nil && changed {
+		if err = h.reload(); err == nil {
+			slog.Info("reloaded assets")
+		}
+	}
+	if err != nil {
+		slog.Error("reload assets", "err", err)
+	}
+	h.h.ServeHTTP(resp, req)
+}
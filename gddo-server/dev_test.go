@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDevHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "site.css")
+	if err := ioutil.WriteFile(fname, []byte("a"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newAssetsWatcher(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloads := 0
+	h := devHandler{http.NotFoundHandler(), w, func() error { reloads++; return nil }}
+	serve := func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	serve()
+	if reloads != 0 {
+		t.Errorf("reloads = %d before change, want 0", reloads)
+	}
+	if err := ioutil.WriteFile(fname, []byte("ab"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	serve()
+	serve()
+	if reloads != 1 {
+		t.Errorf("reloads = %d after change, want 1", reloads)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "new.html"), nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	serve()
+	if reloads != 2 {
+		t.Errorf("reloads = %d after new file, want 2", reloads)
+	}
+}
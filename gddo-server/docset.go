@@ -0,0 +1,345 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+// Docsets (https://kapeli.com/docsets) for the Dash and Zeal documentation
+// browsers are gzip compressed tar archives of a NAME.docset directory with
+// the documentation of the packages under an import path and a SQLite index
+// of the identifiers. The docset directory has the archive ESCAPED.tgz and the
+// JSON encoded docsetInfo ESCAPED.json for each docset, where ESCAPED is the
+// import path escaped with url.PathEscape. A docset is generated on the first
+// request and generated again by a background task after a package under the
+// import path is crawled.
+
+var (
+	docsetDir         = flag.String("docset_dir", "", "Directory for Dash and Zeal docsets. Empty disables the docsets.")
+	docsetMaxPackages = flag.Int("docset_max_packages", 500, "Maximum number of packages in a docset.")
+	sqliteCommand     = flag.String("sqlite_command", "sqlite3", "SQLite command line shell used to create the index of docsets.")
+)
+
+type docsetInfo struct {
+	Path     string    `json:"path"`
+	Host     string    `json:"host"`
+	Packages int       `json:"packages"`
+	Updated  time.Time `json:"updated"`
+}
+
+var docsets struct {
+	// mu guards stale, the import paths of the docsets to generate again.
+	mu    sync.Mutex
+	stale map[string]bool
+
+	// writeMu serializes the generation of docsets.
+	writeMu sync.Mutex
+}
+
+// docsetEntryTypes maps the kinds of symbols to the entry types of the
+// docset index.
+var docsetEntryTypes = map[string]string{
+	"constant": "Constant",
+	"variable": "Variable",
+	"function": "Function",
+	"type":     "Type",
+	"method":   "Method",
+	"field":    "Field",
+}
+
+const docsetPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key><string>%[1]s</string>
+	<key>CFBundleName</key><string>%[1]s</string>
+	<key>DocSetPlatformFamily</key><string>go</string>
+	<key>isDashDocset</key><true/>
+	<key>dashIndexFilePath</key><string>%[2]s</string>
+	<key>DashDocSetFallbackURL</key><string>%[3]s</string>
+</dict>
+</plist>
+`
+
+func docsetFile(importPath, ext string) string {
+	return filepath.Join(*docsetDir, url.PathEscape(importPath)+ext)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// sqlString returns s as an SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func addTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// docsetIndex runs the -sqlite_command to create the SQLite index of a docset
+// with the SQL statements.
+func docsetIndex(sql []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "docset")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "docSet.dsidx")
+	var stderr bytes.Buffer
+	cmd := exec.Command(*sqliteCommand, fname)
+	cmd.Stdin = bytes.NewReader(sql)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", *sqliteCommand, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return ioutil.ReadFile(fname)
+}
+
+// buildDocset writes the docset of the packages under the import path to w
+// and returns the number of packages in the docset. The pages link to the
+// site at host.
+func buildDocset(w io.Writer, importPath, host string) (int, error) {
+	paths, err := packagesUnder(importPath)
+	if err != nil {
+		return 0, err
+	}
+	if len(paths) > *docsetMaxPackages {
+		paths = paths[:*docsetMaxPackages]
+	}
+
+	name := strings.Replace(importPath, "/", "_", -1)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	sql := bytes.NewBufferString("CREATE TABLE searchIndex(id INTEGER PRIMARY KEY, name TEXT, type TEXT, path TEXT);\n" +
+		"CREATE UNIQUE INDEX anchor ON searchIndex (name, type, path);\n" +
+		"BEGIN;\n")
+	insert := func(name, typ, path string) {
+		fmt.Fprintf(sql, "INSERT OR IGNORE INTO searchIndex(name, type, path) VALUES (%s, %s, %s);\n", sqlString(name), sqlString(typ), sqlString(path))
+	}
+
+	var index string
+	n := 0
+	for _, p := range paths {
+		pdoc, _, err := db.GetDoc(p)
+		if err != nil {
+			return 0, err
+		}
+		if pdoc == nil || pdoc.Name == "" || pdoc.Private {
+			continue
+		}
+		tdoc := newTDoc(pdoc)
+		page, err := renderExport(hostURI(host, p), defaultLanguage, map[string]interface{}{"pdoc": tdoc})
+		if err != nil {
+			return 0, err
+		}
+		file := p + ".html"
+		if err := addTarFile(tw, name+".docset/Contents/Resources/Documents/"+file, page); err != nil {
+			return 0, err
+		}
+		insert(p, "Package", file)
+		for _, s := range tdoc.symbols() {
+			insert(s.ID, docsetEntryTypes[s.Kind], file+"#"+s.ID)
+		}
+		if index == "" {
+			index = file
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, gosrc.NotFoundError{Message: "no packages"}
+	}
+	sql.WriteString("COMMIT;\n")
+
+	dsidx, err := docsetIndex(sql.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	if err := addTarFile(tw, name+".docset/Contents/Resources/docSet.dsidx", dsidx); err != nil {
+		return 0, err
+	}
+	plist := fmt.Sprintf(docsetPlist, xmlEscape(name), xmlEscape(index), xmlEscape(hostURI(host, "")))
+	if err := addTarFile(tw, name+".docset/Contents/Info.plist", []byte(plist)); err != nil {
+		return 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return n, gz.Close()
+}
+
+// writeDocset generates the docset described by info in the docset
+// directory.
+func writeDocset(info *docsetInfo) error {
+	docsets.writeMu.Lock()
+	defer docsets.writeMu.Unlock()
+
+	f, err := ioutil.TempFile(*docsetDir, ".docset")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	n, err := buildDocset(f, info.Path, info.Host)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), docsetFile(info.Path, ".tgz")); err != nil {
+		return err
+	}
+
+	info.Packages = n
+	info.Updated = time.Now().UTC()
+	p, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := docsetFile(info.Path, ".json.tmp")
+	if err := ioutil.WriteFile(tmp, p, 0666); err != nil {
+		return err
+	}
+	slog.Info("wrote docset", "path", info.Path, "packages", n)
+	return os.Rename(tmp, docsetFile(info.Path, ".json"))
+}
+
+// readDocsetInfo returns the information about the docset of the import path
+// or nil if the docset was not generated.
+func readDocsetInfo(importPath string) (*docsetInfo, error) {
+	p, err := ioutil.ReadFile(docsetFile(importPath, ".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var info docsetInfo
+	if err := json.Unmarshal(p, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// markDocsetsStale marks the docsets containing the package for generation by
+// the background task.
+func markDocsetsStale(importPath string) {
+	if *docsetDir == "" {
+		return
+	}
+	for p := importPath; ; {
+		if _, err := os.Stat(docsetFile(p, ".json")); err == nil {
+			docsets.mu.Lock()
+			if docsets.stale == nil {
+				docsets.stale = make(map[string]bool)
+			}
+			docsets.stale[p] = true
+			docsets.mu.Unlock()
+		}
+		i := strings.LastIndex(p, "/")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+}
+
+// updateDocsets generates the stale docsets again.
+func updateDocsets() error {
+	docsets.mu.Lock()
+	stale := docsets.stale
+	docsets.stale = nil
+	docsets.mu.Unlock()
+	for p := range stale {
+		info, err := readDocsetInfo(p)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			continue
+		}
+		if err := writeDocset(info); err != nil {
+			slog.Error("write docset", "path", p, "err", err)
+		}
+	}
+	return nil
+}
+
+// serveDocset serves the docset archive of an import path at
+// /-/docset/PATH.tgz and the Dash docset feed at /-/docset/PATH.xml.
+func serveDocset(resp http.ResponseWriter, req *http.Request) error {
+	if *docsetDir == "" {
+		return &httpError{status: http.StatusNotFound}
+	}
+	p := strings.TrimPrefix(req.URL.Path, "/-/docset/")
+	ext := path.Ext(p)
+	importPath := strings.TrimSuffix(p, ext)
+	if (ext != ".tgz" && ext != ".xml") ||
+		importPath == "" ||
+		path.Clean(importPath) != importPath ||
+		strings.HasPrefix(importPath, ".") ||
+		!isAllowed(importPath) {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	info, err := readDocsetInfo(importPath)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		if err := checkRate(req.Context(), crawlLimiter); err != nil {
+			return err
+		}
+		info = &docsetInfo{Path: importPath, Host: req.Host}
+		if err := writeDocset(info); err != nil {
+			return err
+		}
+	}
+
+	if ext == ".xml" {
+		resp.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		_, err := fmt.Fprintf(resp, "<entry>\n<version>%d</version>\n<url>%s</url>\n</entry>\n",
+			info.Updated.Unix(), xmlEscape(pageURI(req, "-/docset/"+importPath+".tgz")))
+		return err
+	}
+	resp.Header().Set("Content-Type", "application/gzip")
+	http.ServeFile(resp, req, docsetFile(importPath, ".tgz"))
+	return nil
+}
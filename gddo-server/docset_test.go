@@ -0,0 +1,124 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSQLString(t *testing.T) {
+	if got, want := sqlString("it's"), `'it''s'`; got != want {
+		t.Errorf("sqlString() = %s, want %s", got, want)
+	}
+}
+
+func TestDocsetIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The fake SQLite shell copies the statements to the database file.
+	script := filepath.Join(dir, "sqlite3")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\ncat >\"$1\"\n"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer func(c string) { *sqliteCommand = c }(*sqliteCommand)
+	*sqliteCommand = script
+
+	const sql = "CREATE TABLE searchIndex(id INTEGER PRIMARY KEY, name TEXT, type TEXT, path TEXT);\n"
+	p, err := docsetIndex([]byte(sql))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p) != sql {
+		t.Errorf("index = %q, want %q", p, sql)
+	}
+
+	*sqliteCommand = "false"
+	if _, err := docsetIndex([]byte(sql)); err == nil {
+		t.Error("failed SQLite shell not reported")
+	}
+}
+
+func TestMarkDocsetsStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { *docsetDir = d }(*docsetDir)
+	*docsetDir = dir
+	defer func() { docsets.stale = nil }()
+
+	for _, p := range []string{"github.com/user/repo", "github.com/user/repo/sub"} {
+		if err := ioutil.WriteFile(docsetFile(p, ".json"), []byte(`{"path":"`+p+`","host":"godoc.org"}`), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	markDocsetsStale("github.com/user/repo/sub/pkg")
+	markDocsetsStale("github.com/other/repo")
+	want := map[string]bool{"github.com/user/repo": true, "github.com/user/repo/sub": true}
+	if !reflect.DeepEqual(docsets.stale, want) {
+		t.Errorf("stale = %v, want %v", docsets.stale, want)
+	}
+
+	info, err := readDocsetInfo("github.com/user/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.Path != "github.com/user/repo" || info.Host != "godoc.org" {
+		t.Errorf("readDocsetInfo() = %+v", info)
+	}
+	if info, err := readDocsetInfo("github.com/other/repo"); info != nil || err != nil {
+		t.Errorf("readDocsetInfo(missing) = %+v, %v", info, err)
+	}
+}
+
+func TestServeDocsetNotFound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { *docsetDir = d }(*docsetDir)
+	*docsetDir = dir
+
+	for _, p := range []string{
+		"/-/docset/github.com/user/repo.zip",
+		"/-/docset/.tgz",
+		"/-/docset/../secret.tgz",
+		"/-/docset/github.com/user/../repo.tgz",
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/", nil)
+		req.URL.Path = p
+		err := serveDocset(httptest.NewRecorder(), req)
+		if e, ok := err.(*httpError); !ok || e.status != http.StatusNotFound {
+			t.Errorf("%s: err = %v, want not found", p, err)
+		}
+	}
+
+	if err := ioutil.WriteFile(docsetFile("github.com/user/repo", ".json"), []byte(`{"path":"github.com/user/repo","updated":"2020-01-02T00:00:00Z"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "http://godoc.org/-/docset/github.com/user/repo.xml", nil)
+	resp := httptest.NewRecorder()
+	if err := serveDocset(resp, req); err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.Body.String(); !strings.Contains(body, "<version>1577923200</version>") || !strings.Contains(body, "<url>https://godoc.org/-/docset/github.com/user/repo.tgz</url>") {
+		t.Errorf("feed = %s", body)
+	}
+}
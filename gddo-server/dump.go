@@ -0,0 +1,225 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+// Corpus dumps are gzip compressed files with one JSON encoded dumpRecord per
+// line. The dump directory contains the dumps and a SHA256SUMS file in the
+// format of the sha256sum command. Only the dumps listed in SHA256SUMS are
+// served.
+
+var (
+	dumpDir  = flag.String("dump_dir", "", "Directory for corpus dumps. Empty disables the dumps.")
+	dumpKeep = flag.Int("dump_keep", 3, "Number of corpus dumps to keep.")
+)
+
+const (
+	dumpPrefix = "packages-"
+	dumpExt    = ".jsonl.gz"
+	dumpSums   = "SHA256SUMS"
+)
+
+type dumpRecord struct {
+	Path        string    `json:"path"`
+	Name        string    `json:"name"`
+	Synopsis    string    `json:"synopsis,omitempty"`
+	ProjectRoot string    `json:"projectRoot,omitempty"`
+	IsCmd       bool      `json:"isCmd,omitempty"`
+	Imports     []string  `json:"imports,omitempty"`
+	TestImports []string  `json:"testImports,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+type dumpFile struct {
+	Name   string
+	SHA256 string
+	Size   int64
+}
+
+// writeDump writes a dump of the packages in the database. Directories,
+// packages hidden from search, private packages and blocked packages are not
+// included.
+func writeDump() error {
+	if *dumpDir == "" {
+		return nil
+	}
+
+	f, err := ioutil.TempFile(*dumpDir, ".dump")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	enc := json.NewEncoder(gz)
+	n := 0
+	err = db.Do(func(pi *database.PackageInfo) error {
+		if pi.Kind == "d" || pi.Score == 0 {
+			return nil
+		}
+		pdoc := pi.PDoc
+		if pdoc.Private {
+			return nil
+		}
+		if blocked, err := db.IsBlocked(pdoc.ImportPath); err != nil || blocked {
+			return err
+		}
+		n++
+		return enc.Encode(&dumpRecord{
+			Path:        pdoc.ImportPath,
+			Name:        pdoc.Name,
+			Synopsis:    pdoc.Synopsis,
+			ProjectRoot: pdoc.ProjectRoot,
+			IsCmd:       pdoc.IsCmd,
+			Imports:     pdoc.Imports,
+			TestImports: pdoc.TestImports,
+			Updated:     pdoc.Updated,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	name := dumpPrefix + time.Now().UTC().Format("20060102") + dumpExt
+	if err := os.Rename(f.Name(), filepath.Join(*dumpDir, name)); err != nil {
+		return err
+	}
+	slog.Info("wrote dump", "name", name, "packages", n)
+	return updateDumpSums(*dumpDir, name, hex.EncodeToString(h.Sum(nil)), *dumpKeep)
+}
+
+// readDumpSums returns the dumps listed in the SHA256SUMS file in dir, newest
+// first.
+func readDumpSums(dir string) ([]dumpFile, error) {
+	f, err := os.Open(filepath.Join(dir, dumpSums))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var files []dumpFile
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		files = append(files, dumpFile{Name: fields[1], SHA256: fields[0]})
+	}
+	sort.Sort(byDumpName(files))
+	return files, s.Err()
+}
+
+// updateDumpSums adds the dump with the given name and checksum to the
+// SHA256SUMS file in dir and removes all but the newest keep dumps.
+func updateDumpSums(dir, name, sum string, keep int) error {
+	files, err := readDumpSums(dir)
+	if err != nil {
+		return err
+	}
+	for i := range files {
+		if files[i].Name == name {
+			files = append(files[:i], files[i+1:]...)
+			break
+		}
+	}
+	files = append(files, dumpFile{Name: name, SHA256: sum})
+	sort.Sort(byDumpName(files))
+	for len(files) > keep && len(files) > 1 {
+		if err := os.Remove(filepath.Join(dir, files[len(files)-1].Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[:len(files)-1]
+	}
+
+	var buf []byte
+	for _, f := range files {
+		buf = append(buf, fmt.Sprintf("%s  %s\n", f.SHA256, f.Name)...)
+	}
+	tmp := filepath.Join(dir, "."+dumpSums)
+	if err := ioutil.WriteFile(tmp, buf, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, dumpSums))
+}
+
+type byDumpName []dumpFile
+
+func (p byDumpName) Len() int           { return len(p) }
+func (p byDumpName) Less(i, j int) bool { return p[i].Name > p[j].Name }
+func (p byDumpName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func serveDumps(resp http.ResponseWriter, req *http.Request) error {
+	var files []dumpFile
+	if *dumpDir != "" {
+		var err error
+		files, err = readDumpSums(*dumpDir)
+		if err != nil {
+			return err
+		}
+		for i := range files {
+			if fi, err := os.Stat(filepath.Join(*dumpDir, files[i].Name)); err == nil {
+				files[i].Size = fi.Size()
+			}
+		}
+	}
+	return executeTemplate(resp, "dumps.html", http.StatusOK, nil, map[string]interface{}{
+		"enabled": *dumpDir != "",
+		"files":   files,
+	})
+}
+
+func serveDumpFile(resp http.ResponseWriter, req *http.Request) error {
+	if *dumpDir == "" {
+		return &httpError{status: http.StatusNotFound}
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/-/dumps/")
+	if name != dumpSums {
+		files, err := readDumpSums(*dumpDir)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, f := range files {
+			found = found || f.Name == name
+		}
+		if !found {
+			return &httpError{status: http.StatusNotFound}
+		}
+	}
+	http.ServeFile(resp, req, filepath.Join(*dumpDir, name))
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateDumpSums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gddo-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"packages-20150101.jsonl.gz", "packages-20150102.jsonl.gz", "packages-20150103.jsonl.gz"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateDumpSums(dir, name, "sum-"+name[9:17], 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := readDumpSums(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []dumpFile{
+		{Name: "packages-20150103.jsonl.gz", SHA256: "sum-20150103"},
+		{Name: "packages-20150102.jsonl.gz", SHA256: "sum-20150102"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("readDumpSums() = %v, want %v", files, expected)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "packages-20150101.jsonl.gz")); !os.IsNotExist(err) {
+		t.Errorf("oldest dump not removed, err = %v", err)
+	}
+}
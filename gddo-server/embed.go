@@ -0,0 +1,127 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+const (
+	embedWidth      = 480
+	embedHeight     = 240
+	embedMaxSymbols = 8
+)
+
+// pageURI returns the absolute URI of the documentation page for importPath.
+func pageURI(req *http.Request, importPath string) string {
+	return hostURI(req.Host, importPath)
+}
+
+// hostURI returns the URI of the page for the import path on the host.
+func hostURI(host, importPath string) string {
+	proto := "http"
+	if host == "godoc.org" {
+		proto = "https"
+	}
+	return fmt.Sprintf("%s://%s/%s", proto, host, importPath)
+}
+
+// embedSymbols returns the names of the first exported types and functions in
+// the package for display in the embedded documentation card.
+func embedSymbols(pdoc *doc.Package) []string {
+	var names []string
+	for _, t := range pdoc.Types {
+		if len(names) >= embedMaxSymbols {
+			return names
+		}
+		names = append(names, t.Name)
+	}
+	for _, f := range pdoc.Funcs {
+		if len(names) >= embedMaxSymbols {
+			return names
+		}
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// serveOEmbed implements the oEmbed protocol (http://oembed.com/) for package
+// documentation pages. The response embeds the ?embed view of the package in
+// an iframe or, with the inline=1 parameter, the documentation fragment of the
+// package.
+func serveOEmbed(resp http.ResponseWriter, req *http.Request) error {
+	if f := req.Form.Get("format"); f != "" && f != "json" {
+		return &httpError{status: http.StatusNotImplemented}
+	}
+
+	u, err := url.Parse(req.Form.Get("url"))
+	if err != nil || u.Host != req.Host {
+		return &httpError{status: http.StatusNotFound}
+	}
+	importPath := strings.TrimPrefix(path.Clean(u.Path), "/")
+	pdoc, _, _, err := db.Get(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	width, height := embedWidth, embedHeight
+	if n, err := strconv.Atoi(req.Form.Get("maxwidth")); err == nil && n > 0 && n < width {
+		width = n
+	}
+	if n, err := strconv.Atoi(req.Form.Get("maxheight")); err == nil && n > 0 && n < height {
+		height = n
+	}
+
+	uri := pageURI(req, pdoc.ImportPath)
+	frame := fmt.Sprintf(`<iframe src="%s?embed" width="%d" height="%d" frameborder="0"></iframe>`, html.EscapeString(uri), width, height)
+	if req.Form.Get("inline") == "1" {
+		fdata, err := fragmentData(pdoc)
+		if err != nil {
+			return err
+		}
+		b, err := renderFragment(req, resp.Header().Get("Content-Language"), pdoc.ImportPath, fdata)
+		if err != nil {
+			return err
+		}
+		frame = string(b)
+	}
+
+	data := struct {
+		Type         string `json:"type"`
+		Version      string `json:"version"`
+		Title        string `json:"title"`
+		ProviderName string `json:"provider_name"`
+		ProviderURL  string `json:"provider_url"`
+		HTML         string `json:"html"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+	}{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        pdoc.ImportPath,
+		ProviderName: "GoDoc",
+		ProviderURL:  pageURI(req, ""),
+		HTML:         frame,
+		Width:        width,
+		Height:       height,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Access-Control-Allow-Origin", "*")
+	return json.NewEncoder(resp).Encode(&data)
+}
@@ -0,0 +1,153 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/gosrc"
+	"github.com/golang/gddo/httputil"
+)
+
+// Package pages open a stream of server-sent events from /-/events. When
+// the package of the page is saved by a crawl in any replica, the database
+// publishes the import path and the server sends an updated event to the
+// pages of the package. The page shows a banner with a link to reload the
+// page.
+
+var (
+	liveUpdates     = flag.Bool("live_updates", true, "Notify the open package pages when the package is crawled again.")
+	maxEventStreams = flag.Int("max_event_streams", 10000, "Maximum number of open event streams of package pages.")
+)
+
+// eventHeartbeat is the interval of the comments sent to keep idle streams
+// open through proxies.
+const eventHeartbeat = 30 * time.Second
+
+// updateHub delivers the import paths of the saved packages to the streams
+// of the pages of the packages.
+type updateHub struct {
+	mu      sync.Mutex
+	n       int
+	streams map[string]map[chan struct{}]bool
+}
+
+var updates = &updateHub{}
+
+var errTooManyStreams = errors.New("too many event streams")
+
+// subscribe returns a channel receiving a value when the package with the
+// import path is saved. The channel is buffered; updates to a stream that
+// did not receive the last update are merged.
+func (h *updateHub) subscribe(path string, max int) (chan struct{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if max > 0 && h.n >= max {
+		return nil, errTooManyStreams
+	}
+	if h.streams == nil {
+		h.streams = make(map[string]map[chan struct{}]bool)
+	}
+	m := h.streams[path]
+	if m == nil {
+		m = make(map[chan struct{}]bool)
+		h.streams[path] = m
+	}
+	ch := make(chan struct{}, 1)
+	m[ch] = true
+	h.n++
+	return ch, nil
+}
+
+func (h *updateHub) unsubscribe(path string, ch chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m := h.streams[path]
+	if !m[ch] {
+		return
+	}
+	delete(m, ch)
+	if len(m) == 0 {
+		delete(h.streams, path)
+	}
+	h.n--
+}
+
+// publish notifies the streams of the package with the import path.
+func (h *updateHub) publish(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.streams[path] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// runUpdateSubscriber delivers the packages saved in all replicas to the
+// hub. The subscription is restarted after database errors.
+func runUpdateSubscriber() {
+	if !*liveUpdates {
+		return
+	}
+	for {
+		err := db.SubscribeUpdates(updates.publish)
+		slog.Error("db.SubscribeUpdates", "err", err)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// serveEvents streams the updated events of the package with the import
+// path in the path parameter.
+func serveEvents(resp http.ResponseWriter, req *http.Request) error {
+	path := req.Form.Get("path")
+	if !*liveUpdates || !gosrc.IsValidPath(path) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	rb, ok := resp.(*httputil.ResponseBuffer)
+	if !ok {
+		return &httpError{status: http.StatusNotImplemented}
+	}
+	ch, err := updates.subscribe(path, *maxEventStreams)
+	if err != nil {
+		return &httpError{status: http.StatusServiceUnavailable, err: err}
+	}
+	defer updates.unsubscribe(path, ch)
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("X-Accel-Buffering", "no")
+	rb.WriteHeader(http.StatusOK)
+	rb.Stream()
+	fmt.Fprintf(rb, "retry: %d\n\n", eventHeartbeat/time.Millisecond)
+	rb.Flush()
+
+	t := time.NewTicker(eventHeartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return nil
+		case <-t.C:
+			if _, err := rb.Write([]byte(": ping\n\n")); err != nil {
+				return nil
+			}
+		case <-ch:
+			if _, err := fmt.Fprintf(rb, "event: updated\ndata: %s\n\n", path); err != nil {
+				return nil
+			}
+		}
+		rb.Flush()
+	}
+}
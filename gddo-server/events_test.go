@@ -0,0 +1,48 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestUpdateHub(t *testing.T) {
+	var h updateHub
+	a, err := h.subscribe("example.com/a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := h.subscribe("example.com/b", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.subscribe("example.com/c", 2); err != errTooManyStreams {
+		t.Errorf("subscribe over the limit returned %v, want %v", err, errTooManyStreams)
+	}
+
+	h.publish("example.com/a")
+	h.publish("example.com/a")
+	select {
+	case <-a:
+	default:
+		t.Error("stream of example.com/a not notified")
+	}
+	select {
+	case <-a:
+		t.Error("updates of example.com/a not merged")
+	case <-b:
+		t.Error("stream of example.com/b notified")
+	default:
+	}
+
+	h.unsubscribe("example.com/a", a)
+	h.unsubscribe("example.com/a", a)
+	if h.n != 1 || len(h.streams) != 1 {
+		t.Errorf("after unsubscribe n = %d, paths = %d, want 1, 1", h.n, len(h.streams))
+	}
+	h.publish("example.com/a")
+}
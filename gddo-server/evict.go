@@ -0,0 +1,37 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"time"
+)
+
+var (
+	evictAfter = flag.Duration("evict_after", 180*24*time.Hour, "The documents of the packages not viewed and not imported by a crawled package for this duration are evicted from the database by the eviction task. The metadata used by search is kept and the documents are rebuilt from source on the next view.")
+	evictBatch = flag.Int("evict_batch", 1000, "Maximum number of documents evicted by each run of the eviction task.")
+)
+
+// evictColdDocs evicts the documents of the cold packages and drops the
+// cached pages of the packages.
+func evictColdDocs() error {
+	if *evictAfter <= 0 || *evictBatch <= 0 {
+		return nil
+	}
+	paths, err := db.EvictColdDocs(time.Now().Add(-*evictAfter), *evictBatch)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		pages.invalidate(p)
+	}
+	if len(paths) > 0 {
+		slog.Info("evicted cold documents", "count", len(paths))
+	}
+	return nil
+}
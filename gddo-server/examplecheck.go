@@ -0,0 +1,48 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	exampleCheckCommand = flag.String("example_checker", "", "Compile the example programs of crawled packages with this command. The command is run with the import path of the package as the last argument and the program on stdin. Exit status 1 reports compiler errors printed by the command. The command is expected to build in a sandbox. Empty disables checking.")
+	exampleCheckTimeout = flag.Duration("example_checker_timeout", 30*time.Second, "Time to wait for the example checker.")
+)
+
+// execExampleChecker runs a command to compile example programs.
+type execExampleChecker struct {
+	args []string
+}
+
+func (c *execExampleChecker) Check(importPath string, src []byte) (string, error) {
+	cmd := exec.Command(c.args[0], append(c.args[1:], importPath)...)
+	cmd.Stdin = bytes.NewReader(src)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	t := time.AfterFunc(*exampleCheckTimeout, func() { cmd.Process.Kill() })
+	err := cmd.Wait()
+	t.Stop()
+	if e, ok := err.(*exec.ExitError); ok && e.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+		errs := strings.TrimSpace(out.String())
+		if errs == "" {
+			errs = "rejected by " + c.args[0]
+		}
+		return errs, nil
+	}
+	return "", err
+}
@@ -0,0 +1,30 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestExecExampleChecker(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		errs string
+		err  bool
+	}{
+		{[]string{"sh", "-c", `cat >/dev/null; test "$0" = example.com/p`}, "", false},
+		{[]string{"sh", "-c", "cat >/dev/null; echo 'main.go:3: undefined: x'; exit 1"}, "main.go:3: undefined: x", false},
+		{[]string{"sh", "-c", "cat >/dev/null; exit 1"}, "rejected by sh", false},
+		{[]string{"sh", "-c", "cat >/dev/null; exit 2"}, "", true},
+	} {
+		c := &execExampleChecker{args: tt.args}
+		errs, err := c.Check("example.com/p", []byte("package main\n"))
+		if errs != tt.errs || (err != nil) != tt.err {
+			t.Errorf("Check() with %v = %q, %v, want %q, error %v", tt.args, errs, err, tt.errs, tt.err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	htemp "html/template"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	pdfCommand = flag.String("pdf_command", "", "Command converting HTML on standard input to PDF on standard output for the ?export=pdf view, for example \"wkhtmltopdf --quiet - -\". PDF export is disabled if empty.")
+	pdfTimeout = flag.Duration("pdf_timeout", 30*time.Second, "Time to wait for the -pdf_command.")
+)
+
+// exportCSS returns the style sheets inlined in exported documentation.
+func exportCSS() (htemp.CSS, error) {
+	names := []string{"site.css"}
+	if *sidebarEnabled {
+		names = append(names, "sidebar.css")
+	}
+	var buf bytes.Buffer
+	for _, name := range names {
+		b, err := ioutil.ReadFile(assetFile(*assetsDir, name))
+		if err != nil {
+			return "", err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return htemp.CSS(buf.String()), nil
+}
+
+// serveExport serves the documentation page of a package with the data as a
+// single HTML file without external resources or as a PDF file.
+func serveExport(resp http.ResponseWriter, req *http.Request, importPath string, data map[string]interface{}) error {
+	format := req.Form.Get("export")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && (format != "pdf" || *pdfCommand == "") {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	body, err := renderExport(pageURI(req, importPath), resp.Header().Get("Content-Language"), data)
+	if err != nil {
+		return err
+	}
+	contentType := htmlMIMEType
+	if format == "pdf" {
+		ctx, cancel := context.WithTimeout(req.Context(), *pdfTimeout)
+		defer cancel()
+		body, err = renderPDF(ctx, body)
+		if err != nil {
+			return err
+		}
+		contentType = "application/pdf"
+	}
+	resp.Header().Set("Content-Type", contentType)
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(importPath)+"."+format))
+	resp.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Header().Set("Cache-Control", "private")
+	_, err = resp.Write(body)
+	return err
+}
+
+// renderExport returns the single file HTML page of the package documentation
+// at uri with the data in the language lang.
+func renderExport(uri, lang string, data map[string]interface{}) ([]byte, error) {
+	css, err := exportCSS()
+	if err != nil {
+		return nil, err
+	}
+	data["css"] = css
+	data["uri"] = uri
+	data["exported"] = time.Now().UTC().Format("2006-01-02")
+	r := &pageRecorder{header: make(http.Header)}
+	r.header.Set("Content-Language", lang)
+	if err := executeTemplate(r, "export.html", http.StatusOK, nil, data); err != nil {
+		return nil, err
+	}
+	return r.buf.Bytes(), nil
+}
+
+// renderPDF converts the HTML page to PDF with the -pdf_command.
+func renderPDF(ctx context.Context, page []byte) ([]byte, error) {
+	args := strings.Fields(*pdfCommand)
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(page)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF")) {
+		return nil, fmt.Errorf("%s: output is not a PDF file", args[0])
+	}
+	return out.Bytes(), nil
+}
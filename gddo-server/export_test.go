@@ -0,0 +1,92 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+// externalResourcePat matches the elements loading style sheets, scripts and
+// frames. Images are blocked by the Content-Security-Policy of the page.
+var externalResourcePat = regexp.MustCompile(`<(?:link|script|iframe)[^>]*(?:src|href)=`)
+
+func serveTestExport(url string) (*httptest.ResponseRecorder, error) {
+	pdoc := newTDoc(&doc.Package{
+		ImportPath:  "github.com/user/repo",
+		ProjectRoot: "github.com/user/repo",
+		ProjectName: "repo",
+		Name:        "repo",
+		Synopsis:    "Package repo does things.",
+		Funcs:       []*doc.Func{{Name: "New", Decl: doc.Code{Text: "func New()"}}},
+	})
+	req := httptest.NewRequest("GET", url, nil)
+	req.ParseForm()
+	resp := httptest.NewRecorder()
+	err := serveExport(resp, req, "github.com/user/repo", map[string]interface{}{"pdoc": pdoc})
+	return resp, err
+}
+
+func TestServeExport(t *testing.T) {
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{{"export.html", "pkg.html", "common.html"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := serveTestExport("http://godoc.org/github.com/user/repo?export=html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := resp.Body.String()
+	if got := resp.Header().Get("Content-Disposition"); got != `attachment; filename="repo.html"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if !strings.Contains(page, "Content-Security-Policy") || !strings.Contains(page, "#x-footer") || !strings.Contains(page, `id="New"`) {
+		t.Errorf("page does not have inlined style sheet or documentation:\n%s", page)
+	}
+	for _, m := range externalResourcePat.FindAllString(page, -1) {
+		t.Errorf("page loads external resource: %s", m)
+	}
+
+	if _, err := serveTestExport("http://godoc.org/github.com/user/repo?export=pdf"); err == nil {
+		t.Error("PDF export served without -pdf_command")
+	}
+
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "topdf")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\ncat >/dev/null\necho %PDF-1.4\n"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer func(c string) { *pdfCommand = c }(*pdfCommand)
+	*pdfCommand = script
+
+	resp, err = serveTestExport("http://godoc.org/github.com/user/repo?export=pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(resp.Body.String(), "%PDF") {
+		t.Errorf("PDF export = %v %q", resp.Header(), resp.Body.String())
+	}
+
+	*pdfCommand = "cat"
+	if _, err := serveTestExport("http://godoc.org/github.com/user/repo?export=pdf"); err == nil {
+		t.Error("HTML output of -pdf_command not reported")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/gosrc"
+)
+
+// feedEntries is the number of entries in a feed.
+const feedEntries = 50
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// filterFeed removes the entries that the request cannot view.
+func filterFeed(req *http.Request, entries []database.FeedEntry) ([]database.FeedEntry, error) {
+	pkgs := make([]database.Package, len(entries))
+	for i, e := range entries {
+		pkgs[i] = e.Package
+	}
+	pkgs, err := filterPrivate(req, filterAllowed(pkgs))
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool)
+	for _, pkg := range pkgs {
+		visible[pkg.Path] = true
+	}
+	var result []database.FeedEntry
+	for _, e := range entries {
+		if visible[e.Path] {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// serveFeed serves the Atom feed, or the RSS feed if the format parameter is
+// rss, at the paths:
+//
+//  /feed/new           packages recently added to the database
+//  /feed/<path>        updates of the package with the import path
+//  /feed/<prefix>/...  updates of the packages below the prefix
+func serveFeed(resp http.ResponseWriter, req *http.Request) error {
+	p := strings.TrimPrefix(req.URL.Path, "/feed/")
+	var (
+		title   string
+		entries []database.FeedEntry
+		err     error
+	)
+	switch {
+	case p == "new":
+		title = "New packages"
+		entries, err = db.NewPackages(feedEntries)
+	case strings.HasSuffix(p, "/..."):
+		p = strings.TrimSuffix(p, "/...")
+		if !gosrc.IsValidPath(p) {
+			return &httpError{status: http.StatusNotFound}
+		}
+		title = "Updates of packages in " + p
+		entries, err = db.UpdatedPackages(p, feedEntries)
+	case gosrc.IsValidPath(p):
+		title = "Updates of " + p
+		entries, err = db.UpdatedPackages(p, feedEntries)
+		if err == nil {
+			// Exclude packages below the import path.
+			i := 0
+			for _, e := range entries {
+				if e.Path == p {
+					entries[i] = e
+					i++
+				}
+			}
+			entries = entries[:i]
+		}
+	default:
+		return &httpError{status: http.StatusNotFound}
+	}
+	if err == nil {
+		entries, err = filterFeed(req, entries)
+	}
+	if err != nil {
+		return err
+	}
+
+	self := pageURI(req, strings.TrimPrefix(req.URL.Path, "/"))
+	site := pageURI(req, "")
+	resp.Header().Set("Cache-Control", "max-age=600")
+
+	var v interface{}
+	if req.Form.Get("format") == "rss" {
+		var feed rssFeed
+		feed.Version = "2.0"
+		feed.Channel.Title = title + " - GoDoc"
+		feed.Channel.Link = site
+		feed.Channel.Description = title + " indexed by GoDoc."
+		for _, e := range entries {
+			u := pageURI(req, e.Path)
+			feed.Channel.Items = append(feed.Channel.Items, rssItem{
+				Title:       e.Path,
+				Link:        u,
+				GUID:        fmt.Sprintf("%s#%d", u, e.Updated.Unix()),
+				PubDate:     e.Updated.Format(time.RFC1123Z),
+				Description: e.Synopsis,
+			})
+		}
+		resp.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		v = &feed
+	} else {
+		feed := atomFeed{
+			Title:   title + " - GoDoc",
+			ID:      self,
+			Links:   []atomLink{{Href: self, Rel: "self"}, {Href: site}},
+			Updated: time.Now().UTC().Format(time.RFC3339),
+		}
+		if len(entries) > 0 {
+			feed.Updated = entries[0].Updated.Format(time.RFC3339)
+		}
+		for _, e := range entries {
+			u := pageURI(req, e.Path)
+			feed.Entries = append(feed.Entries, atomEntry{
+				Title:   e.Path,
+				ID:      fmt.Sprintf("%s#%d", u, e.Updated.Unix()),
+				Link:    atomLink{Href: u},
+				Updated: e.Updated.Format(time.RFC3339),
+				Summary: e.Synopsis,
+			})
+		}
+		resp.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		v = &feed
+	}
+	if _, err := resp.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	return xml.NewEncoder(resp).Encode(v)
+}
@@ -0,0 +1,38 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+func TestFilterFeed(t *testing.T) {
+	defer setAllowList("")
+	setAllowList("github.com/org")
+
+	req, err := http.NewRequest("GET", "/feed/new", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []database.FeedEntry{
+		{Package: database.Package{Path: "github.com/org/a"}},
+		{Package: database.Package{Path: "github.com/other/b"}},
+	}
+	*authHeader = "X-User"
+	defer func() { *authHeader = "" }()
+	req.Header.Set("X-User", "alice")
+	entries, err = filterFeed(req, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != "github.com/org/a" {
+		t.Errorf("filterFeed returned %v, want github.com/org/a", entries)
+	}
+}
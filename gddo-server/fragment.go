@@ -0,0 +1,72 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+// serveFragment serves the documentation of a package with the data as an
+// HTML fragment without the page chrome for embedding in other sites. The
+// fragment can be requested by scripts on other origins.
+func serveFragment(resp http.ResponseWriter, req *http.Request, importPath string, header http.Header, status int, data map[string]interface{}) error {
+	for k, v := range header {
+		resp.Header()[k] = v
+	}
+	resp.Header().Set("Access-Control-Allow-Origin", "*")
+	resp.Header().Set("Access-Control-Expose-Headers", "Etag")
+	if status == http.StatusNotModified {
+		resp.WriteHeader(status)
+		return nil
+	}
+	body, err := renderFragment(req, resp.Header().Get("Content-Language"), importPath, data)
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Content-Type", htmlMIMEType)
+	resp.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, err = resp.Write(body)
+	return err
+}
+
+// renderFragment returns the HTML fragment with the documentation of the
+// package with the data in the language lang. The links relative to the site
+// are absolute in the fragment.
+func renderFragment(req *http.Request, lang, importPath string, data map[string]interface{}) ([]byte, error) {
+	uri := pageURI(req, importPath)
+	data["uri"] = uri
+	r := &pageRecorder{header: make(http.Header)}
+	r.header.Set("Content-Language", lang)
+	if err := executeTemplate(r, "fragment.html", http.StatusOK, nil, data); err != nil {
+		return nil, err
+	}
+	root := html.EscapeString(pageURI(req, ""))
+	abs := strings.NewReplacer(
+		`href="/`, `href="`+root,
+		`src="/`, `src="`+root,
+		`href="?`, `href="`+html.EscapeString(uri)+"?")
+	return []byte(abs.Replace(r.buf.String())), nil
+}
+
+// fragmentData returns the template data of the fragment of the package
+// documentation served by the oEmbed endpoint.
+func fragmentData(pdoc *doc.Package) (map[string]interface{}, error) {
+	vulns, err := db.Vulns(pdoc.ImportPath)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"pdoc":     newTDoc(pdoc),
+		"vulns":    vulns,
+		"promoted": promoted(pdoc),
+	}, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestServeFragment(t *testing.T) {
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{{"fragment.html", "pkg.html", "common.html"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	pdoc := newTDoc(&doc.Package{
+		ImportPath:  "github.com/user/repo",
+		ProjectRoot: "github.com/user/repo",
+		ProjectName: "repo",
+		Name:        "repo",
+		Synopsis:    "Package repo does things.",
+		Funcs:       []*doc.Func{{Name: "New", Decl: doc.Code{Text: "func New()"}}},
+	})
+	req := httptest.NewRequest("GET", "http://godoc.org/github.com/user/repo?fragment", nil)
+	req.ParseForm()
+	resp := httptest.NewRecorder()
+	header := http.Header{"Etag": {`"1"`}}
+	if err := serveFragment(resp, req, "github.com/user/repo", header, http.StatusOK, map[string]interface{}{"pdoc": pdoc}); err != nil {
+		t.Fatal(err)
+	}
+	body := resp.Body.String()
+	if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if resp.Header().Get("Etag") != `"1"` {
+		t.Errorf("Etag = %q, want \"1\"", resp.Header().Get("Etag"))
+	}
+	if !strings.HasPrefix(body, `<div class="gddo-fragment"`) || !strings.Contains(body, `id="New"`) {
+		t.Errorf("fragment does not have the documentation:\n%s", body)
+	}
+	for _, chrome := range []string{"<html", "<head", "x-footer", "x-jump"} {
+		if strings.Contains(body, chrome) {
+			t.Errorf("fragment has page chrome %q", chrome)
+		}
+	}
+	for _, rel := range []string{`href="/`, `href="?`, `src="/`} {
+		if strings.Contains(body, rel) {
+			t.Errorf("fragment has relative link %s", rel)
+		}
+	}
+
+	resp = httptest.NewRecorder()
+	if err := serveFragment(resp, req, "github.com/user/repo", header, http.StatusNotModified, nil); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Errorf("not modified fragment = %d %q", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,740 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements the /graphql endpoint. The endpoint supports the
+// subset of GraphQL needed to query the documentation database: a single
+// query operation with fields, aliases, arguments and variables. Fragments,
+// directives and mutations are not supported.
+//
+// The schema is:
+//
+//  type Query {
+//    package(path: String!): Package
+//    search(q: String!, first: Int = 20): [PackageSummary]
+//    project(root: String!): Project
+//  }
+//  type Package {
+//    importPath, name, synopsis, doc, projectRoot, projectName,
+//    projectURL, version, updated: String
+//    isCmd: Boolean
+//    imports, testImports: [String]
+//    importerCount: Int
+//    importers(first: Int = 20): [PackageSummary]  # requires an API key
+//    consts, vars, funcs: [Decl]
+//    types: [Type]
+//    project: Project
+//  }
+//  type PackageSummary { path, synopsis: String  package: Package }
+//  type Decl { name, decl, doc: String }
+//  type Type { name, decl, doc: String  consts, vars, funcs, methods: [Decl] }
+//  type Project { root: String  packages(first: Int = 100): [PackageSummary] }
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+const (
+	// gqlMaxDepth is the maximum nesting of selection sets in a query.
+	gqlMaxDepth = 6
+
+	// gqlMaxFirst is the maximum value of the first argument.
+	gqlMaxFirst = 100
+
+	// gqlMaxDocs is the maximum number of package documents loaded by a
+	// query.
+	gqlMaxDocs = 100
+)
+
+// gqlField is a field in a parsed query.
+type gqlField struct {
+	alias  string
+	name   string
+	args   map[string]interface{}
+	fields []*gqlField
+}
+
+func (f *gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// gqlParser parses a query document.
+type gqlParser struct {
+	s    string
+	pos  int
+	tok  string // current token; "" at end of input
+	str  bool   // true if tok is a string literal
+	vars map[string]interface{}
+}
+
+type gqlError struct {
+	msg string
+}
+
+func (e *gqlError) Error() string { return e.msg }
+
+func gqlErrorf(format string, args ...interface{}) error {
+	return &gqlError{fmt.Sprintf(format, args...)}
+}
+
+func parseGraphQL(query string, vars map[string]interface{}) (fields []*gqlField, err error) {
+	p := &gqlParser{s: query, vars: vars}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*gqlError)
+			if !ok {
+				panic(r)
+			}
+			fields, err = nil, e
+		}
+	}()
+	p.next()
+	if p.tok == "query" {
+		p.next()
+		if p.tok != "(" && p.tok != "{" {
+			p.name()
+		}
+		if p.tok == "(" {
+			p.variableDefinitions()
+		}
+	} else if p.tok != "{" {
+		p.fail("expected query")
+	}
+	fields = p.selectionSet(1)
+	if p.tok != "" || p.str {
+		p.fail("expected end of document")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) fail(format string, args ...interface{}) {
+	tok := p.tok
+	if tok == "" && !p.str {
+		tok = "end of document"
+	}
+	panic(gqlErrorf("syntax error at %q: %s", tok, fmt.Sprintf(format, args...)))
+}
+
+func (p *gqlParser) next() {
+	p.str = false
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		default:
+			goto token
+		}
+	}
+	p.tok = ""
+	return
+
+token:
+	start := p.pos
+	c := p.s[p.pos]
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "..."):
+		p.pos += 3
+	case strings.IndexByte("{}():!$[]=", c) >= 0:
+		p.pos++
+	case c == '"':
+		p.pos++
+		for p.pos < len(p.s) && p.s[p.pos] != '"' {
+			if p.s[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.s) {
+			p.tok = ""
+			p.fail("unterminated string")
+		}
+		p.pos++
+		s, err := strconv.Unquote(p.s[start:p.pos])
+		if err != nil {
+			p.tok = p.s[start:p.pos]
+			p.fail("invalid string")
+		}
+		p.tok, p.str = s, true
+		return
+	case c == '-' || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+		p.pos++
+		for p.pos < len(p.s) {
+			c := rune(p.s[p.pos])
+			if c != '_' && c != '.' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				break
+			}
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.s[start:p.pos]
+}
+
+func (p *gqlParser) expect(tok string) {
+	if p.str || p.tok != tok {
+		p.fail("expected %q", tok)
+	}
+	p.next()
+}
+
+func (p *gqlParser) name() string {
+	tok := p.tok
+	if p.str || tok == "" || !(tok[0] == '_' || unicode.IsLetter(rune(tok[0]))) || strings.Contains(tok, ".") {
+		p.fail("expected name")
+	}
+	p.next()
+	return tok
+}
+
+func (p *gqlParser) variableDefinitions() {
+	p.expect("(")
+	for p.tok != ")" || p.str {
+		p.expect("$")
+		name := p.name()
+		p.expect(":")
+		p.typeRef()
+		if p.tok == "=" && !p.str {
+			p.next()
+			v := p.value()
+			if _, ok := p.vars[name]; !ok {
+				if p.vars == nil {
+					p.vars = make(map[string]interface{})
+				}
+				p.vars[name] = v
+			}
+		}
+	}
+	p.next()
+}
+
+func (p *gqlParser) typeRef() {
+	if p.tok == "[" && !p.str {
+		p.next()
+		p.typeRef()
+		p.expect("]")
+	} else {
+		p.name()
+	}
+	if p.tok == "!" && !p.str {
+		p.next()
+	}
+}
+
+func (p *gqlParser) value() interface{} {
+	if p.str {
+		s := p.tok
+		p.next()
+		return s
+	}
+	switch tok := p.tok; {
+	case tok == "$":
+		p.next()
+		name := p.name()
+		v, ok := p.vars[name]
+		if !ok {
+			panic(gqlErrorf("variable $%s is not defined", name))
+		}
+		return v
+	case tok == "[":
+		p.next()
+		var list []interface{}
+		for p.tok != "]" || p.str {
+			list = append(list, p.value())
+		}
+		p.next()
+		return list
+	case tok == "true" || tok == "false":
+		p.next()
+		return tok == "true"
+	case tok == "null":
+		p.next()
+		return nil
+	case tok != "" && (tok[0] == '-' || unicode.IsDigit(rune(tok[0]))):
+		n, err := strconv.Atoi(tok)
+		if err != nil {
+			p.fail("expected integer")
+		}
+		p.next()
+		return n
+	}
+	return p.name()
+}
+
+func (p *gqlParser) selectionSet(depth int) []*gqlField {
+	if depth > gqlMaxDepth {
+		panic(gqlErrorf("query exceeds maximum depth of %d", gqlMaxDepth))
+	}
+	p.expect("{")
+	var fields []*gqlField
+	for p.tok != "}" || p.str {
+		if p.tok == "..." && !p.str {
+			p.fail("fragments are not supported")
+		}
+		f := &gqlField{name: p.name()}
+		if p.tok == ":" && !p.str {
+			p.next()
+			f.alias, f.name = f.name, p.name()
+		}
+		if p.tok == "(" && !p.str {
+			p.next()
+			f.args = make(map[string]interface{})
+			for p.tok != ")" || p.str {
+				name := p.name()
+				p.expect(":")
+				f.args[name] = p.value()
+			}
+			p.next()
+		}
+		if p.tok == "{" && !p.str {
+			f.fields = p.selectionSet(depth + 1)
+		}
+		fields = append(fields, f)
+	}
+	p.next()
+	if len(fields) == 0 {
+		p.fail("empty selection set")
+	}
+	return fields
+}
+
+func (f *gqlField) stringArg(name string) (string, error) {
+	v, ok := f.args[name].(string)
+	if !ok {
+		return "", gqlErrorf("argument %q of field %q must be a string", name, f.name)
+	}
+	return v, nil
+}
+
+func (f *gqlField) firstArg(def int) (int, error) {
+	v, ok := f.args["first"]
+	if !ok || v == nil {
+		return def, nil
+	}
+	var n int
+	switch v := v.(type) {
+	case int:
+		n = v
+	case float64:
+		n = int(v)
+		if float64(n) != v {
+			return 0, gqlErrorf("argument \"first\" of field %q must be an integer", f.name)
+		}
+	default:
+		return 0, gqlErrorf("argument \"first\" of field %q must be an integer", f.name)
+	}
+	if n < 0 || n > gqlMaxFirst {
+		return 0, gqlErrorf("argument \"first\" of field %q must be between 0 and %d", f.name, gqlMaxFirst)
+	}
+	return n, nil
+}
+
+// gqlObject is a resolved object. The fields are encoded in the order of the
+// selection set.
+type gqlObject []struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlSelect resolves the fields selected on an object of the named type. The
+// function resolve returns the value of a field or ok false if the type does
+// not have the field.
+func gqlSelect(f *gqlField, typeName string, resolve func(f *gqlField) (v interface{}, ok bool, err error)) (gqlObject, error) {
+	if len(f.fields) == 0 {
+		return nil, gqlErrorf("field %q of type %s must have a selection of subfields", f.name, typeName)
+	}
+	var o gqlObject
+	for _, sf := range f.fields {
+		var v interface{}
+		if sf.name == "__typename" {
+			v = typeName
+		} else {
+			var ok bool
+			var err error
+			v, ok, err = resolve(sf)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, gqlErrorf("cannot query field %q on type %s", sf.name, typeName)
+			}
+		}
+		o = append(o, struct {
+			key   string
+			value interface{}
+		}{sf.key(), v})
+	}
+	return o, nil
+}
+
+// gqlScalar returns v after checking that the field does not have a
+// selection set.
+func gqlScalar(f *gqlField, v interface{}) (interface{}, bool, error) {
+	if len(f.fields) != 0 {
+		return nil, true, gqlErrorf("field %q must not have a selection of subfields", f.name)
+	}
+	return v, true, nil
+}
+
+type gqlResolver struct {
+	resp http.ResponseWriter
+	req  *http.Request
+	docs int
+
+	// keyChecked is true if the API key of the query was checked for a
+	// field of the bulk API.
+	keyChecked bool
+}
+
+// checkAPIKey checks the API key of the query once for the fields of the
+// bulk API, such as the importers.
+func (r *gqlResolver) checkAPIKey() error {
+	if r.keyChecked {
+		return nil
+	}
+	if err := checkAPIKey(r.resp, r.req); err != nil {
+		return err
+	}
+	r.keyChecked = true
+	return nil
+}
+
+func (r *gqlResolver) query(f *gqlField) (interface{}, bool, error) {
+	switch f.name {
+	case "package":
+		path, err := f.stringArg("path")
+		if err != nil {
+			return nil, true, err
+		}
+		pdoc, err := r.getDoc(path, apiRequest)
+		if err != nil || pdoc == nil {
+			return nil, true, err
+		}
+		v, err := r.pkg(f, pdoc)
+		return v, true, err
+	case "search":
+		// Each search field counts against the search rate limit.
+		if err := checkRate(r.req.Context(), searchLimiter); err != nil {
+			return nil, true, err
+		}
+		q, err := f.stringArg("q")
+		if err != nil {
+			return nil, true, err
+		}
+		pkgs, err := db.Query(q)
+		if err == nil {
+			pkgs, err = filterPrivate(r.req, filterAllowed(pkgs))
+		}
+		if err != nil {
+			return nil, true, err
+		}
+		v, err := r.summaries(f, pkgs, 20)
+		return v, true, err
+	case "project":
+		root, err := f.stringArg("root")
+		if err != nil {
+			return nil, true, err
+		}
+		v, err := r.project(f, root)
+		return v, true, err
+	}
+	return nil, false, nil
+}
+
+// getDoc returns the package with the given import path or nil if the
+// package is not found or the request cannot view the package. The package
+// is crawled as for the request type.
+func (r *gqlResolver) getDoc(path string, requestType int) (*doc.Package, error) {
+	r.docs++
+	if r.docs > gqlMaxDocs {
+		return nil, gqlErrorf("query loads more than %d packages", gqlMaxDocs)
+	}
+	pdoc, _, err := getDoc(r.req.Context(), path, requestType)
+	if gosrc.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pdoc == nil || !canView(r.req, pdoc) {
+		return nil, nil
+	}
+	return pdoc, nil
+}
+
+func (r *gqlResolver) pkg(f *gqlField, pdoc *doc.Package) (interface{}, error) {
+	return gqlSelect(f, "Package", func(f *gqlField) (interface{}, bool, error) {
+		switch f.name {
+		case "importPath":
+			return gqlScalar(f, pdoc.ImportPath)
+		case "name":
+			return gqlScalar(f, pdoc.Name)
+		case "synopsis":
+			return gqlScalar(f, pdoc.Synopsis)
+		case "doc":
+			return gqlScalar(f, pdoc.Doc)
+		case "isCmd":
+			return gqlScalar(f, pdoc.IsCmd)
+		case "projectRoot":
+			return gqlScalar(f, pdoc.ProjectRoot)
+		case "projectName":
+			return gqlScalar(f, pdoc.ProjectName)
+		case "projectURL":
+			return gqlScalar(f, pdoc.ProjectURL)
+		case "version":
+			return gqlScalar(f, pdoc.Version)
+		case "updated":
+			return gqlScalar(f, pdoc.Updated.UTC().Format(time.RFC3339))
+		case "imports":
+			return gqlScalar(f, nonNilStrings(pdoc.Imports))
+		case "testImports":
+			return gqlScalar(f, nonNilStrings(pdoc.TestImports))
+		case "importerCount":
+			n, err := db.ImporterCount(pdoc.ImportPath)
+			if err != nil {
+				return nil, true, err
+			}
+			return gqlScalar(f, n)
+		case "importers":
+			if err := r.checkAPIKey(); err != nil {
+				return nil, true, err
+			}
+			pkgs, err := db.Importers(pdoc.ImportPath)
+			if err == nil {
+				pkgs, err = filterPrivate(r.req, filterAllowed(pkgs))
+			}
+			if err != nil {
+				return nil, true, err
+			}
+			v, err := r.summaries(f, pkgs, 20)
+			return v, true, err
+		case "consts":
+			v, err := r.decls(f, apiValues(pdoc.Consts))
+			return v, true, err
+		case "vars":
+			v, err := r.decls(f, apiValues(pdoc.Vars))
+			return v, true, err
+		case "funcs":
+			v, err := r.decls(f, apiFuncs(pdoc.Funcs))
+			return v, true, err
+		case "types":
+			v, err := r.types(f, pdoc.Types)
+			return v, true, err
+		case "project":
+			v, err := r.project(f, pdoc.ProjectRoot)
+			return v, true, err
+		}
+		return nil, false, nil
+	})
+}
+
+func (r *gqlResolver) summaries(f *gqlField, pkgs []database.Package, def int) (interface{}, error) {
+	n, err := f.firstArg(def)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) > n {
+		pkgs = pkgs[:n]
+	}
+	result := []interface{}{}
+	for _, pkg := range pkgs {
+		pkg := pkg
+		o, err := gqlSelect(f, "PackageSummary", func(f *gqlField) (interface{}, bool, error) {
+			switch f.name {
+			case "path":
+				return gqlScalar(f, pkg.Path)
+			case "synopsis":
+				return gqlScalar(f, pkg.Synopsis)
+			case "package":
+				// The summaries are read from the database, so the
+				// packages are not crawled.
+				pdoc, err := r.getDoc(pkg.Path, cachedRequest)
+				if err != nil || pdoc == nil {
+					return nil, true, err
+				}
+				v, err := r.pkg(f, pdoc)
+				return v, true, err
+			}
+			return nil, false, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+func (r *gqlResolver) decls(f *gqlField, decls []apiDecl) (interface{}, error) {
+	result := []interface{}{}
+	for _, d := range decls {
+		d := d
+		o, err := gqlSelect(f, "Decl", func(f *gqlField) (interface{}, bool, error) {
+			switch f.name {
+			case "name":
+				return gqlScalar(f, d.Name)
+			case "decl":
+				return gqlScalar(f, d.Decl)
+			case "doc":
+				return gqlScalar(f, d.Doc)
+			}
+			return nil, false, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+func (r *gqlResolver) types(f *gqlField, types []*doc.Type) (interface{}, error) {
+	result := []interface{}{}
+	for _, t := range types {
+		t := t
+		o, err := gqlSelect(f, "Type", func(f *gqlField) (interface{}, bool, error) {
+			var v interface{}
+			var err error
+			switch f.name {
+			case "name":
+				return gqlScalar(f, t.Name)
+			case "decl":
+				return gqlScalar(f, t.Decl.Text)
+			case "doc":
+				return gqlScalar(f, t.Doc)
+			case "consts":
+				v, err = r.decls(f, apiValues(t.Consts))
+			case "vars":
+				v, err = r.decls(f, apiValues(t.Vars))
+			case "funcs":
+				v, err = r.decls(f, apiFuncs(t.Funcs))
+			case "methods":
+				v, err = r.decls(f, apiFuncs(t.Methods))
+			default:
+				return nil, false, nil
+			}
+			return v, true, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+func (r *gqlResolver) project(f *gqlField, root string) (interface{}, error) {
+	return gqlSelect(f, "Project", func(f *gqlField) (interface{}, bool, error) {
+		switch f.name {
+		case "root":
+			return gqlScalar(f, root)
+		case "packages":
+			pkgs, err := db.Project(root)
+			if err == nil {
+				pkgs, err = filterPrivate(r.req, filterAllowed(pkgs))
+			}
+			if err != nil {
+				return nil, true, err
+			}
+			v, err := r.summaries(f, pkgs, 100)
+			return v, true, err
+		}
+		return nil, false, nil
+	})
+}
+
+type gqlResponse struct {
+	Data   interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
+func serveGraphQL(resp http.ResponseWriter, req *http.Request) error {
+	var params struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	switch {
+	case req.Method == "POST" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json"):
+		if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+			return &httpError{status: http.StatusBadRequest, err: err}
+		}
+	case req.Method == "GET" || req.Method == "POST":
+		params.Query = req.Form.Get("query")
+		if s := req.Form.Get("variables"); s != "" {
+			if err := json.Unmarshal([]byte(s), &params.Variables); err != nil {
+				return &httpError{status: http.StatusBadRequest, err: err}
+			}
+		}
+	default:
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+
+	status := http.StatusOK
+	var data gqlResponse
+	fields, err := parseGraphQL(params.Query, params.Variables)
+	if err == nil {
+		r := &gqlResolver{resp: resp, req: req}
+		data.Data, err = gqlSelect(&gqlField{name: "query", fields: fields}, "Query", r.query)
+	}
+	if err != nil {
+		if _, ok := err.(*gqlError); !ok {
+			return err
+		}
+		status = http.StatusBadRequest
+		data.Data = nil
+		data.Errors = append(data.Errors, struct {
+			Message string `json:"message"`
+		}{err.Error()})
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.WriteHeader(status)
+	return json.NewEncoder(resp).Encode(&data)
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	fields, err := parseGraphQL(`
+		# Look up a package.
+		query Doc($path: String!, $n: Int = 5) {
+			pkg: package(path: $path) {
+				name
+				importers(first: $n) { path }
+			}
+		}`, map[string]interface{}{"path": "github.com/user/repo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 {
+		t.Fatalf("got %d fields, want 1", len(fields))
+	}
+	f := fields[0]
+	if f.alias != "pkg" || f.name != "package" || f.args["path"] != "github.com/user/repo" {
+		t.Errorf("field = %+v", f)
+	}
+	if len(f.fields) != 2 || f.fields[1].name != "importers" || f.fields[1].args["first"] != 5 {
+		t.Errorf("subfields = %+v", f.fields)
+	}
+}
+
+var badGraphQLTests = []string{
+	``,
+	`mutation { refresh }`,
+	`{ package(path: $missing) { name } }`,
+	`{ package(path: "x") { ...fields } }`,
+	`{ package(path: "x" { name } }`,
+	`{ a { b { c { d { e { f { g } } } } } } }`,
+	`{ name } }`,
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	for _, q := range badGraphQLTests {
+		if _, err := parseGraphQL(q, nil); err == nil {
+			t.Errorf("parseGraphQL(%q) did not return an error", q)
+		}
+	}
+}
+
+func TestGraphQLSelect(t *testing.T) {
+	fields, err := parseGraphQL(`{ b: name, a: name, __typename }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := gqlSelect(&gqlField{name: "query", fields: fields}, "Query", func(f *gqlField) (interface{}, bool, error) {
+		if f.name == "name" {
+			return gqlScalar(f, "x")
+		}
+		return nil, false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":"x","a":"x","__typename":"Query"}`; string(p) != want {
+		t.Errorf("got %s, want %s", p, want)
+	}
+
+	fields, err = parseGraphQL(`{ unknown }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &gqlResolver{}
+	_, err = gqlSelect(&gqlField{name: "query", fields: fields}, "Query", r.query)
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("query for unknown field returned error %v", err)
+	}
+}
+
+func TestGraphQLSearchRate(t *testing.T) {
+	defer func(rate float64) { *searchRate = rate }(*searchRate)
+	*searchRate = 1
+	req := httptest.NewRequest("POST", "/graphql", nil)
+	req.RemoteAddr = "192.0.2.10:1234"
+	req = req.WithContext(withClient(req.Context(), req))
+	if ok, _ := searchLimiter.allow("ip:192.0.2.10", time.Now()); !ok {
+		t.Fatal("first search denied")
+	}
+	fields, err := parseGraphQL(`{a: search(q: "x") { importPath } b: search(q: "y") { importPath }}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &gqlResolver{req: req}
+	_, err = gqlSelect(&gqlField{name: "query", fields: fields}, "Query", r.query)
+	if e, ok := err.(*httpError); !ok || e.status != http.StatusTooManyRequests {
+		t.Errorf("search over the rate returned %v, want status 429", err)
+	}
+}
+
+func TestGraphQLImportersAPIKey(t *testing.T) {
+	defer func(r bool) { *requireAPIKeys = r }(*requireAPIKeys)
+	*requireAPIKeys = true
+	r := &gqlResolver{resp: httptest.NewRecorder(), req: httptest.NewRequest("POST", "/graphql", nil)}
+	if e, ok := r.checkAPIKey().(*httpError); !ok || e.status != http.StatusUnauthorized {
+		t.Errorf("importers without a key returned %v, want status 401", e)
+	}
+}
@@ -0,0 +1,497 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements the gRPC API defined in api.proto. The calls are
+// served by net/http over cleartext HTTP/2: the messages of a call are the
+// length-prefixed protocol buffer messages of the request and response
+// bodies, and the status of the call is sent in the grpc-status and
+// grpc-message trailers.
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+	"github.com/golang/gddo/proto"
+)
+
+var grpcAddr = flag.String("grpc", "", "Listen for gRPC connections with cleartext HTTP/2 on this address. The gRPC API is defined in gddo-server/api.proto.")
+
+// gRPC status codes.
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// grpcMaxMessageSize is the maximum size of a request message.
+const grpcMaxMessageSize = 1 << 16
+
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", e.code, e.msg)
+}
+
+// grpcStream is the stream of the request and response messages of a call.
+type grpcStream struct {
+	resp http.ResponseWriter
+	req  *http.Request
+}
+
+// recv returns the next request message or io.EOF at the end of the
+// requests.
+func (s *grpcStream) recv() ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(s.req.Body, prefix[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "truncated message"}
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > grpcMaxMessageSize {
+		return nil, &grpcError{grpcResourceExhausted, "message too large"}
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(s.req.Body, p); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "truncated message"}
+	}
+	return p, nil
+}
+
+// recvOne returns the request message of a call with a single request.
+func (s *grpcStream) recvOne() (*proto.Decoder, error) {
+	p, err := s.recv()
+	if err == io.EOF {
+		return nil, &grpcError{grpcInvalidArgument, "missing request message"}
+	} else if err != nil {
+		return nil, err
+	}
+	return proto.NewDecoder(p), nil
+}
+
+// send sends a response message.
+func (s *grpcStream) send(p []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(p)))
+	if _, err := s.resp.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := s.resp.Write(p); err != nil {
+		return err
+	}
+	http.NewResponseController(s.resp).Flush()
+	return nil
+}
+
+var grpcMethods = map[string]func(s *grpcStream) error{
+	"/gddo.api.v1.GoDoc/GetPackage":                                  grpcGetPackage,
+	"/gddo.api.v1.GoDoc/Search":                                      grpcSearch,
+	"/gddo.api.v1.GoDoc/Importers":                                   grpcImporters,
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      grpcReflection,
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": grpcReflection,
+}
+
+// grpcHandler serves the gRPC API.
+type grpcHandler struct{}
+
+func (grpcHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.ProtoMajor != 2 {
+		http.Error(resp, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if req.Method != "POST" || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(resp, "Not a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx := withClient(withRequestID(req.Context(), inboundRequestID(req)), req)
+	if t, ok := grpcTimeout(req.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+	req = req.WithContext(ctx)
+	req.Body = http.MaxBytesReader(resp, req.Body, 16*grpcMaxMessageSize)
+	resp.Header().Set("Content-Type", "application/grpc")
+
+	err := func() (err error) {
+		defer func() {
+			if rv := recover(); rv != nil {
+				logError(req, errors.New("handler panic"), rv)
+				err = &grpcError{grpcInternal, "internal error"}
+			}
+		}()
+		if *authProvider != "" && authSession(req) == nil && !validAPIToken(req) {
+			return &grpcError{grpcUnauthenticated, "authentication required"}
+		}
+		fn := grpcMethods[req.URL.Path]
+		if fn == nil {
+			return &grpcError{grpcUnimplemented, "unknown method " + req.URL.Path}
+		}
+		return fn(&grpcStream{resp: resp, req: req})
+	}()
+
+	code, msg := grpcStatus(req, err)
+	resp.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		resp.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcEncodeMessage(msg))
+	}
+}
+
+// grpcStatus returns the status code and message of a call ending with err.
+func grpcStatus(req *http.Request, err error) (int, string) {
+	if err == nil {
+		return grpcOK, ""
+	}
+	if e, ok := err.(*grpcError); ok {
+		return e.code, e.msg
+	}
+	status := http.StatusInternalServerError
+	if e, ok := err.(*httpError); ok {
+		status = e.status
+		if e.err != nil {
+			err = e.err
+		}
+	} else if gosrc.IsNotFound(err) {
+		status = http.StatusNotFound
+	}
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInvalidArgument, "invalid request"
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated, "API key required"
+	case http.StatusForbidden:
+		return grpcPermissionDenied, "permission denied"
+	case http.StatusNotFound, http.StatusGone, http.StatusUnavailableForLegalReasons:
+		return grpcNotFound, "package not found"
+	case http.StatusTooManyRequests:
+		return grpcResourceExhausted, "rate limit exceeded"
+	case http.StatusServiceUnavailable:
+		if err == errFetching {
+			return grpcUnavailable, "package is being fetched"
+		}
+		return grpcUnavailable, errorText(err)
+	}
+	logError(req, err, nil)
+	return grpcInternal, "internal error"
+}
+
+// grpcTimeout parses the value of the grpc-timeout header.
+func grpcTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// grpcEncodeMessage percent-encodes the status message msg for the
+// grpc-message trailer.
+func grpcEncodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// serveGRPC serves the gRPC API on the -grpc address.
+func serveGRPC() error {
+	srv := &http.Server{
+		Addr:      *grpcAddr,
+		Handler:   grpcHandler{},
+		Protocols: new(http.Protocols),
+	}
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return srv.ListenAndServe()
+}
+
+// grpcImportPath returns the import_path field of a GetPackageRequest or an
+// ImportersRequest.
+func grpcImportPath(d *proto.Decoder) (string, error) {
+	var importPath string
+	for d.Next() {
+		if d.Field() == 1 {
+			importPath = d.String()
+		}
+	}
+	if err := d.Err(); err != nil {
+		return "", &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	if importPath == "" {
+		return "", &grpcError{grpcInvalidArgument, "missing import_path"}
+	}
+	return importPath, nil
+}
+
+func grpcGetPackage(s *grpcStream) error {
+	d, err := s.recvOne()
+	if err != nil {
+		return err
+	}
+	importPath, err := grpcImportPath(d)
+	if err != nil {
+		return err
+	}
+	pdoc, _, err := getDoc(s.req.Context(), importPath, apiRequest)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(s.req, pdoc) {
+		return &grpcError{grpcNotFound, "package not found"}
+	}
+	return s.send(pdoc.MarshalProto())
+}
+
+// appendPackageSummary appends a PackageSummary message.
+func appendPackageSummary(b *proto.Buffer, path, synopsis string) {
+	b.AppendString(1, path)
+	b.AppendString(2, synopsis)
+}
+
+func grpcSearch(s *grpcStream) error {
+	d, err := s.recvOne()
+	if err != nil {
+		return err
+	}
+	var q string
+	for d.Next() {
+		if d.Field() == 1 {
+			q = d.String()
+		}
+	}
+	if err := d.Err(); err != nil {
+		return &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	pkgs, err := apiSearch(s.req, strings.TrimSpace(q))
+	if err != nil {
+		return err
+	}
+	var b proto.Buffer
+	for _, pkg := range pkgs {
+		b.AppendMessage(1, func(b *proto.Buffer) { appendPackageSummary(b, pkg.Path, pkg.Synopsis) })
+	}
+	return s.send(b.Bytes())
+}
+
+func grpcImporters(s *grpcStream) error {
+	d, err := s.recvOne()
+	if err != nil {
+		return err
+	}
+	importPath, err := grpcImportPath(d)
+	if err != nil {
+		return err
+	}
+	if err := checkAPIKey(s.resp, s.req); err != nil {
+		return err
+	}
+	pkgs, err := apiImporters(s.req, importPath)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		var b proto.Buffer
+		appendPackageSummary(&b, pkg.Path, pkg.Synopsis)
+		if err := s.send(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//go:embed api.proto
+var apiProto string
+
+// timestampProto declares google.protobuf.Timestamp, the well-known type
+// imported by doc/package.proto.
+const timestampProto = `
+syntax = "proto3";
+package google.protobuf;
+option go_package = "google.golang.org/protobuf/types/known/timestamppb";
+message Timestamp {
+  int64 seconds = 1;
+  int32 nanos = 2;
+}
+`
+
+// grpcFiles are the .proto files served by the reflection service.
+var grpcFiles = func() []*proto.File {
+	var files []*proto.File
+	for _, f := range []struct{ name, src string }{
+		{"gddo-server/api.proto", apiProto},
+		{"doc/package.proto", doc.PackageProto},
+		{"google/protobuf/timestamp.proto", timestampProto},
+	} {
+		pf, err := proto.ParseFile(f.name, f.src)
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, pf)
+	}
+	return files
+}()
+
+var grpcDescriptors = func() map[string][]byte {
+	m, err := proto.Descriptors(grpcFiles...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}()
+
+// grpcFileDescriptors returns the descriptors of the file name and of the
+// files it imports, directly or indirectly.
+func grpcFileDescriptors(name string) [][]byte {
+	var result [][]byte
+	seen := make(map[string]bool)
+	var add func(name string)
+	add = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		result = append(result, grpcDescriptors[name])
+		for _, f := range grpcFiles {
+			if f.Name == name {
+				for _, imp := range f.Imports {
+					add(imp)
+				}
+			}
+		}
+	}
+	add(name)
+	return result
+}
+
+// grpcReflection implements the ServerReflectionInfo method of the
+// grpc.reflection.v1 and v1alpha services.
+func grpcReflection(s *grpcStream) error {
+	for {
+		p, err := s.recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var host, arg string
+		var kind int
+		d := proto.NewDecoder(p)
+		for d.Next() {
+			switch d.Field() {
+			case 1:
+				host = d.String()
+			case 3, 4, 6, 7:
+				kind = d.Field()
+				arg = d.String()
+			case 5:
+				kind = d.Field()
+			}
+		}
+		if err := d.Err(); err != nil {
+			return &grpcError{grpcInvalidArgument, err.Error()}
+		}
+
+		var b proto.Buffer
+		b.AppendString(1, host)
+		b.AppendBytes(2, p)
+		errorResponse := func(code int, msg string) {
+			b.AppendMessage(7, func(b *proto.Buffer) {
+				b.AppendInt(1, int64(code))
+				b.AppendString(2, msg)
+			})
+		}
+		fileResponse := func(name string) {
+			b.AppendMessage(4, func(b *proto.Buffer) {
+				for _, fd := range grpcFileDescriptors(name) {
+					b.AppendBytes(1, fd)
+				}
+			})
+		}
+		switch kind {
+		case 3: // file_by_filename
+			if grpcDescriptors[arg] == nil {
+				errorResponse(grpcNotFound, "file not found")
+			} else {
+				fileResponse(arg)
+			}
+		case 4: // file_containing_symbol
+			name := ""
+			for _, f := range grpcFiles {
+				for _, sym := range f.Symbols() {
+					if sym == arg {
+						name = f.Name
+					}
+				}
+			}
+			if name == "" {
+				errorResponse(grpcNotFound, "symbol not found")
+			} else {
+				fileResponse(name)
+			}
+		case 5, 6: // file_containing_extension, all_extension_numbers_of_type
+			errorResponse(grpcNotFound, "extensions are not supported")
+		case 7: // list_services
+			b.AppendMessage(6, func(b *proto.Buffer) {
+				for _, f := range grpcFiles {
+					for _, name := range f.Services() {
+						b.AppendMessage(1, func(b *proto.Buffer) { b.AppendString(1, name) })
+					}
+				}
+			})
+		default:
+			errorResponse(grpcInvalidArgument, "unknown request")
+		}
+		if err := s.send(b.Bytes()); err != nil {
+			return err
+		}
+	}
+}
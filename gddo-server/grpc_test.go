@@ -0,0 +1,174 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/proto"
+)
+
+// grpcCall calls the gRPC method of the server at url with the request
+// messages and returns the response messages and the grpc-status trailer.
+func grpcCall(t *testing.T, url, method string, requests ...[]byte) ([][]byte, string) {
+	t.Helper()
+	var body bytes.Buffer
+	for _, p := range requests {
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(p)))
+		body.Write(prefix[:])
+		body.Write(p)
+	}
+	req, err := http.NewRequest("POST", url+method, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	p, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var responses [][]byte
+	for len(p) >= 5 {
+		n := binary.BigEndian.Uint32(p[1:5])
+		responses = append(responses, p[5:5+n])
+		p = p[5+n:]
+	}
+	return responses, resp.Trailer.Get("Grpc-Status")
+}
+
+func newGRPCServer() *httptest.Server {
+	ts := httptest.NewUnstartedServer(grpcHandler{})
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	return ts
+}
+
+func TestGRPCStatus(t *testing.T) {
+	defer func(r bool) { *requireAPIKeys = r }(*requireAPIKeys)
+	*requireAPIKeys = true
+	ts := newGRPCServer()
+	defer ts.Close()
+
+	if _, status := grpcCall(t, ts.URL, "/gddo.api.v1.GoDoc/Unknown", nil); status != "12" {
+		t.Errorf("unknown method status = %q, want 12", status)
+	}
+	if _, status := grpcCall(t, ts.URL, "/gddo.api.v1.GoDoc/GetPackage"); status != "3" {
+		t.Errorf("call without request status = %q, want 3", status)
+	}
+	var b proto.Buffer
+	b.AppendString(1, "example.com/pkg")
+	if _, status := grpcCall(t, ts.URL, "/gddo.api.v1.GoDoc/Importers", b.Bytes()); status != "16" {
+		t.Errorf("importers without key status = %q, want 16", status)
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	ts := newGRPCServer()
+	defer ts.Close()
+
+	var list, symbol, missing proto.Buffer
+	list.AppendString(7, "*")
+	symbol.AppendString(4, "gddo.api.v1.GoDoc")
+	missing.AppendString(3, "missing.proto")
+	responses, status := grpcCall(t, ts.URL, "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", list.Bytes(), symbol.Bytes(), missing.Bytes())
+	if status != "0" || len(responses) != 3 {
+		t.Fatalf("reflection returned %d responses and status %q, want 3 responses and status 0", len(responses), status)
+	}
+
+	var services []string
+	d := proto.NewDecoder(responses[0])
+	for d.Next() {
+		if d.Field() == 6 {
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					d.Message(func(d *proto.Decoder) {
+						for d.Next() {
+							services = append(services, d.String())
+						}
+					})
+				}
+			})
+		}
+	}
+	if len(services) != 1 || services[0] != "gddo.api.v1.GoDoc" {
+		t.Errorf("services = %q, want [gddo.api.v1.GoDoc]", services)
+	}
+
+	var files []string
+	d = proto.NewDecoder(responses[1])
+	for d.Next() {
+		if d.Field() == 4 {
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					d.Message(func(d *proto.Decoder) {
+						for d.Next() {
+							if d.Field() == 1 {
+								files = append(files, d.String())
+							}
+						}
+					})
+				}
+			})
+		}
+	}
+	if want := []string{"gddo-server/api.proto", "doc/package.proto", "google/protobuf/timestamp.proto"}; len(files) != len(want) || files[0] != want[0] || files[1] != want[1] || files[2] != want[2] {
+		t.Errorf("files = %q, want %q", files, want)
+	}
+
+	var code int64
+	d = proto.NewDecoder(responses[2])
+	for d.Next() {
+		if d.Field() == 7 {
+			d.Message(func(d *proto.Decoder) {
+				for d.Next() {
+					if d.Field() == 1 {
+						code = d.Int()
+					}
+				}
+			})
+		}
+	}
+	if code != grpcNotFound {
+		t.Errorf("missing file error code = %d, want %d", code, grpcNotFound)
+	}
+}
+
+func TestGRPCTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		s  string
+		d  time.Duration
+		ok bool
+	}{
+		{"1S", time.Second, true},
+		{"250m", 250 * time.Millisecond, true},
+		{"", 0, false},
+		{"1x", 0, false},
+		{"123456789S", 0, false},
+	} {
+		if d, ok := grpcTimeout(tt.s); d != tt.d || ok != tt.ok {
+			t.Errorf("grpcTimeout(%q) = %v, %v, want %v, %v", tt.s, d, ok, tt.d, tt.ok)
+		}
+	}
+	if got, want := grpcEncodeMessage("100% é"), "100%25 %C3%A9"; got != want {
+		t.Errorf("grpcEncodeMessage() = %q, want %q", got, want)
+	}
+}
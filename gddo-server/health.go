@@ -0,0 +1,131 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	readyHosts      = flag.String("ready_hosts", "", "Comma separated list of URLs that must be reachable for /readyz to report ready.")
+	readyStaleTasks = flag.Duration("ready_stale_tasks", time.Hour, "Report not ready when a background task has not finished for longer than its interval plus this duration.")
+)
+
+const (
+	// hostCheckTimeout is the time to wait for a remote host.
+	hostCheckTimeout = 5 * time.Second
+
+	// hostCheckMaxAge is the time the result of a remote host check is
+	// reused so that frequent probes do not load the remote hosts.
+	hostCheckMaxAge = time.Minute
+)
+
+// healthCheck is the result of checking a dependency in /readyz.
+type healthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func newHealthCheck(name string, err error) healthCheck {
+	c := healthCheck{Name: name, OK: err == nil}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+var hostChecks = struct {
+	sync.Mutex
+	client  *http.Client
+	results map[string]hostCheck
+}{
+	client:  &http.Client{Timeout: hostCheckTimeout},
+	results: make(map[string]hostCheck),
+}
+
+type hostCheck struct {
+	err  error
+	time time.Time
+}
+
+// checkHost returns an error if the remote host at URL u is not reachable.
+// Any response from the host counts as reachable.
+func checkHost(u string) error {
+	hostChecks.Lock()
+	r, ok := hostChecks.results[u]
+	hostChecks.Unlock()
+	if ok && time.Since(r.time) < hostCheckMaxAge {
+		return r.err
+	}
+
+	resp, err := hostChecks.client.Head(u)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+	}
+
+	hostChecks.Lock()
+	hostChecks.results[u] = hostCheck{err: err, time: time.Now()}
+	hostChecks.Unlock()
+	return err
+}
+
+func checkBackgroundTasks() error {
+	if stale := staleBackgroundTasks(time.Now(), *readyStaleTasks); len(stale) > 0 {
+		return fmt.Errorf("stale tasks: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// serveHealthz reports that the process is alive.
+func serveHealthz(resp http.ResponseWriter, req *http.Request) error {
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "no-cache")
+	return json.NewEncoder(resp).Encode(map[string]string{"status": "ok"})
+}
+
+// serveReadyz reports whether the server can serve requests. The response
+// status is 503 if any dependency check fails.
+func serveReadyz(resp http.ResponseWriter, req *http.Request) error {
+	checks := []healthCheck{
+		newHealthCheck("database", db.Ping()),
+		newHealthCheck("background", checkBackgroundTasks()),
+	}
+	for _, u := range strings.Split(*readyHosts, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			checks = append(checks, newHealthCheck(u, checkHost(u)))
+		}
+	}
+	return writeReadyz(resp, checks)
+}
+
+func writeReadyz(resp http.ResponseWriter, checks []healthCheck) error {
+	data := struct {
+		Status string        `json:"status"`
+		Checks []healthCheck `json:"checks"`
+	}{"ok", checks}
+	status := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			data.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(status)
+	return json.NewEncoder(resp).Encode(&data)
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStaleBackgroundTasks(t *testing.T) {
+	now := time.Now()
+	saved := make([]struct {
+		interval time.Duration
+		last     time.Time
+	}, len(backgroundTasks))
+	for i, task := range backgroundTasks {
+		saved[i].interval, saved[i].last = *task.interval, task.last
+	}
+	savedStart := backgroundStart
+	defer func() {
+		for i, task := range backgroundTasks {
+			*task.interval, task.last = saved[i].interval, saved[i].last
+		}
+		backgroundStart = savedStart
+	}()
+
+	for _, task := range backgroundTasks {
+		*task.interval = 0
+	}
+	backgroundStart = time.Time{}
+	*backgroundTasks[0].interval = time.Hour
+	if stale := staleBackgroundTasks(now, time.Hour); stale != nil {
+		t.Errorf("before start, stale = %v, want none", stale)
+	}
+
+	backgroundStart = now.Add(-3 * time.Hour)
+	if stale, want := staleBackgroundTasks(now, time.Hour), []string{backgroundTasks[0].name}; !reflect.DeepEqual(stale, want) {
+		t.Errorf("never finished, stale = %v, want %v", stale, want)
+	}
+
+	backgroundTasks[0].last = now.Add(-90 * time.Minute)
+	if stale := staleBackgroundTasks(now, time.Hour); stale != nil {
+		t.Errorf("finished recently, stale = %v, want none", stale)
+	}
+
+	backgroundTasks[0].last = time.Time{}
+	backgroundTasks[0].standby = true
+	defer func() { backgroundTasks[0].standby = false }()
+	if stale := staleBackgroundTasks(now, time.Hour); stale != nil {
+		t.Errorf("lease held by other replica, stale = %v, want none", stale)
+	}
+}
+
+func TestWriteReadyz(t *testing.T) {
+	for _, tt := range []struct {
+		checks []healthCheck
+		code   int
+		status string
+	}{
+		{[]healthCheck{newHealthCheck("database", nil)}, http.StatusOK, "ok"},
+		{[]healthCheck{newHealthCheck("database", nil), newHealthCheck("background", errors.New("stale"))}, http.StatusServiceUnavailable, "unavailable"},
+	} {
+		w := httptest.NewRecorder()
+		if err := writeReadyz(w, tt.checks); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.code {
+			t.Errorf("code = %d, want %d", w.Code, tt.code)
+		}
+		var data struct {
+			Status string
+			Checks []healthCheck
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+			t.Fatal(err)
+		}
+		if data.Status != tt.status || !reflect.DeepEqual(data.Checks, tt.checks) {
+			t.Errorf("body = %s, want status %q and checks %v", w.Body, tt.status, tt.checks)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	code := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer ts.Close()
+
+	if err := checkHost(ts.URL); err != nil {
+		t.Errorf("checkHost() = %v, want nil", err)
+	}
+	code = http.StatusBadGateway
+	if err := checkHost(ts.URL); err != nil {
+		t.Errorf("cached checkHost() = %v, want nil", err)
+	}
+	hostChecks.Lock()
+	delete(hostChecks.results, ts.URL)
+	hostChecks.Unlock()
+	if err := checkHost(ts.URL); err == nil {
+		t.Error("checkHost() = nil, want error for 502")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+var hostConfigFile = flag.String("host_config", "", "File with HTTP settings for hosts fetched by the crawler. Each line has the form: host setting value, where setting is timeout, proxy, ca or max_response_size.")
+
+// hostConfig holds the HTTP settings for a host.
+type hostConfig struct {
+	timeout         time.Duration
+	proxy           *url.URL
+	caFile          string
+	maxResponseSize int64
+
+	t *http.Transport
+}
+
+// hostConfigs maps host to settings. Hosts not in the map use the settings
+// from the command line flags. It is guarded by configMu.
+var hostConfigs map[string]*hostConfig
+
+func parseHostConfig(r io.Reader) (map[string]*hostConfig, error) {
+	m := make(map[string]*hostConfig)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("host config:%d: want three fields", n)
+		}
+		hc := m[fields[0]]
+		if hc == nil {
+			hc = &hostConfig{}
+			m[fields[0]] = hc
+		}
+		var err error
+		switch fields[1] {
+		case "timeout":
+			hc.timeout, err = time.ParseDuration(fields[2])
+		case "proxy":
+			hc.proxy, err = url.Parse(fields[2])
+		case "ca":
+			hc.caFile = fields[2]
+		case "max_response_size":
+			hc.maxResponseSize, err = strconv.ParseInt(fields[2], 10, 64)
+		default:
+			err = fmt.Errorf("unknown setting %q", fields[1])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("host config:%d: %v", n, err)
+		}
+	}
+	return m, s.Err()
+}
+
+// readHostConfig returns the host settings in fname with a transport for each
+// host.
+func readHostConfig(fname string) (map[string]*hostConfig, error) {
+	if fname == "" {
+		return nil, nil
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := parseHostConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	for host, hc := range m {
+		if err := hc.newTransport(); err != nil {
+			return nil, fmt.Errorf("host config %s: %v", host, err)
+		}
+	}
+	return m, nil
+}
+
+func (hc *hostConfig) requestTimeout() time.Duration {
+	if hc.timeout > 0 {
+		return hc.timeout
+	}
+	return *requestTimeout
+}
+
+func (hc *hostConfig) newTransport() error {
+	timeout := hc.requestTimeout()
+	hc.t = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c, err := gosrc.OutboundDialContext(&net.Dialer{Timeout: *dialTimeout})(ctx, network, addr)
+			if err != nil {
+				return c, err
+			}
+			c.SetDeadline(time.Now().Add(timeout))
+			return timeoutConn{c}, nil
+		},
+		ResponseHeaderTimeout: timeout / 2,
+	}
+	if hc.proxy != nil {
+		hc.t.Proxy = http.ProxyURL(hc.proxy)
+	}
+	if hc.caFile != "" {
+		p, err := ioutil.ReadFile(hc.caFile)
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(p) {
+			return fmt.Errorf("no certificates in %s", hc.caFile)
+		}
+		hc.t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return nil
+}
+
+var errResponseTooLarge = errors.New("response exceeds max_response_size")
+
+// limitedBody is a response body that returns errResponseTooLarge after more
+// than n bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n < 0 {
+		return 0, errResponseTooLarge
+	}
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	if b.n < 0 {
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
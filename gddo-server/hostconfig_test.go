@@ -0,0 +1,51 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHostConfig(t *testing.T) {
+	m, err := parseHostConfig(strings.NewReader(`
+# Corporate Git server.
+git.example.com timeout 1m
+git.example.com proxy http://proxy.example.com:3128
+git.example.com max_response_size 1024
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := m["git.example.com"]
+	if hc == nil {
+		t.Fatal("no config for git.example.com")
+	}
+	if hc.timeout != time.Minute || hc.proxy.Host != "proxy.example.com:3128" || hc.maxResponseSize != 1024 {
+		t.Errorf("config = %+v", hc)
+	}
+
+	for _, s := range []string{"host timeout", "host timeout forever", "host retries 3"} {
+		if _, err := parseHostConfig(strings.NewReader(s)); err == nil {
+			t.Errorf("parseHostConfig(%q) did not return an error", s)
+		}
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	b := &limitedBody{ioutil.NopCloser(strings.NewReader("0123456789")), 5}
+	p, err := ioutil.ReadAll(b)
+	if err != errResponseTooLarge {
+		t.Errorf("ReadAll returned %q, %v; want error %v", p, err, errResponseTooLarge)
+	}
+	b = &limitedBody{ioutil.NopCloser(strings.NewReader("01234")), 5}
+	if p, err := ioutil.ReadAll(b); err != nil || string(p) != "01234" {
+		t.Errorf("ReadAll returned %q, %v; want 01234, nil", p, err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements the translation of the user interface.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/gddo/httputil"
+)
+
+const (
+	// defaultLanguage is the language of the messages in the templates.
+	defaultLanguage = "en"
+
+	// languageCookie is the cookie with the language selected by the user.
+	languageCookie = "lang"
+)
+
+var validLanguage = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// catalog is the translation of the user interface messages to a language.
+type catalog struct {
+	lang     string
+	name     string // name of the language in the language
+	messages map[string]string
+}
+
+// catalogs maps language tags to catalogs and languageTags lists the tags
+// of the languages with a catalog, default language first. Guarded by
+// templatesMu.
+var (
+	catalogs     = map[string]*catalog{}
+	languageTags = []string{defaultLanguage}
+)
+
+// readCatalogs reads the message catalogs in the messages directory of each
+// of dirs. A catalog is a JSON object mapping the messages in the templates
+// to the translations, with the name of the language under the key "_name".
+// The name of the file is the language tag, such as es.json or pt-BR.json.
+// Catalogs in later dirs replace the catalogs in earlier dirs.
+func readCatalogs(dirs ...string) (map[string]*catalog, error) {
+	m := make(map[string]*catalog)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "messages", "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			lang := strings.TrimSuffix(filepath.Base(f), ".json")
+			if !validLanguage.MatchString(lang) {
+				return nil, fmt.Errorf("%s: bad language tag %q", f, lang)
+			}
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			var messages map[string]string
+			if err := json.Unmarshal(b, &messages); err != nil {
+				return nil, fmt.Errorf("%s: %v", f, err)
+			}
+			name := messages["_name"]
+			if name == "" {
+				name = lang
+			}
+			delete(messages, "_name")
+			m[lang] = &catalog{lang: lang, name: name, messages: messages}
+		}
+	}
+	return m, nil
+}
+
+// setCatalogs sets the catalogs of the languages of the user interface.
+func setCatalogs(m map[string]*catalog) {
+	tags := []string{defaultLanguage}
+	for lang := range m {
+		if lang != defaultLanguage {
+			tags = append(tags, lang)
+		}
+	}
+	sort.Strings(tags[1:])
+	templatesMu.Lock()
+	catalogs = m
+	languageTags = tags
+	templatesMu.Unlock()
+}
+
+// msg returns the translation of the message, formatted with args by
+// fmt.Sprintf if there are args. Messages without a translation are returned
+// unchanged.
+func (c *catalog) msg(s string, args ...interface{}) string {
+	if c != nil {
+		if t := c.messages[s]; t != "" {
+			s = t
+		}
+	}
+	if len(args) > 0 {
+		s = fmt.Sprintf(s, args...)
+	}
+	return s
+}
+
+type languageOption struct {
+	Tag  string
+	Name string
+}
+
+// languageOptions returns the languages for the language switcher.
+func languageOptions() []languageOption {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	var options []languageOption
+	for _, lang := range languageTags {
+		o := languageOption{Tag: lang, Name: "English"}
+		if c := catalogs[lang]; c != nil {
+			o.Name = c.name
+		}
+		options = append(options, o)
+	}
+	return options
+}
+
+// translated returns true if the user interface is available in more than
+// one language.
+func translated() bool {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	return len(languageTags) > 1
+}
+
+// negotiateLanguage returns the language of the response to the request: the
+// language selected with the language switcher or the best language for the
+// Accept-Language header.
+func negotiateLanguage(req *http.Request) string {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	if len(languageTags) == 1 {
+		return defaultLanguage
+	}
+	if c, err := req.Cookie(languageCookie); err == nil {
+		if _, ok := catalogs[c.Value]; ok || c.Value == defaultLanguage {
+			return c.Value
+		}
+	}
+	return httputil.NegotiateLanguage(req, languageTags, defaultLanguage)
+}
+
+type languageKey struct{}
+
+// withLanguage returns a copy of ctx with the language of the response.
+func withLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// requestLanguage returns the language of the response in ctx or the default
+// language.
+func requestLanguage(ctx context.Context) string {
+	if lang, _ := ctx.Value(languageKey{}).(string); lang != "" {
+		return lang
+	}
+	return defaultLanguage
+}
+
+// serveLanguage sets the language selected with the language switcher and
+// redirects to the page with the switcher.
+func serveLanguage(resp http.ResponseWriter, req *http.Request) error {
+	lang := req.Form.Get("l")
+	templatesMu.RLock()
+	_, ok := catalogs[lang]
+	templatesMu.RUnlock()
+	if !ok && lang != defaultLanguage {
+		return &httpError{status: http.StatusNotFound}
+	}
+	http.SetCookie(resp, &http.Cookie{Name: languageCookie, Value: lang, Path: "/", MaxAge: 365 * 24 * 60 * 60})
+	target := "/"
+	if u, err := url.Parse(req.Header.Get("Referer")); err == nil && u.Host == req.Host && u.Path != "" {
+		target = u.RequestURI()
+	}
+	http.Redirect(resp, req, target, http.StatusFound)
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var msgPat = regexp.MustCompile(`\{\{msg ("(?:[^"\\]|\\.)*")`)
+
+func TestCatalogs(t *testing.T) {
+	c, err := readCatalogs("assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c["es"] == nil || c["es"].name != "Español" {
+		t.Fatalf("es catalog = %+v", c["es"])
+	}
+
+	// Every message in the catalogs is used in a template.
+	files, err := filepath.Glob("assets/templates/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[string]bool)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range msgPat.FindAllSubmatch(b, -1) {
+			s, err := strconv.Unquote(string(m[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			used[s] = true
+		}
+	}
+	for lang, c := range c {
+		for s := range c.messages {
+			if !used[s] {
+				t.Errorf("%s: message %q not used in templates", lang, s)
+			}
+		}
+	}
+}
+
+func TestCatalogMsg(t *testing.T) {
+	c := &catalog{messages: map[string]string{"Home": "Inicio", "%d packages": "%d paquetes"}}
+	for _, tt := range []struct {
+		c    *catalog
+		s    string
+		args []interface{}
+		want string
+	}{
+		{c, "Home", nil, "Inicio"},
+		{c, "About", nil, "About"},
+		{c, "%d packages", []interface{}{3}, "3 paquetes"},
+		{nil, "Home", nil, "Home"},
+	} {
+		if got := tt.c.msg(tt.s, tt.args...); got != tt.want {
+			t.Errorf("msg(%q, %v) = %q, want %q", tt.s, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	defer setCatalogs(nil)
+	setCatalogs(map[string]*catalog{"es": {lang: "es", name: "Español"}, "pt-BR": {lang: "pt-BR", name: "Português"}})
+
+	for _, tt := range []struct {
+		acceptLanguage, cookie string
+		want                   string
+	}{
+		{"", "", "en"},
+		{"es-ES,es;q=0.9,en;q=0.8", "", "es"},
+		{"pt", "", "pt-BR"},
+		{"fr", "", "en"},
+		{"es", "en", "en"},
+		{"", "pt-BR", "pt-BR"},
+		{"es", "xx", "es"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: languageCookie, Value: tt.cookie})
+		}
+		if got := negotiateLanguage(req); got != tt.want {
+			t.Errorf("negotiateLanguage(%q, cookie %q) = %q, want %q", tt.acceptLanguage, tt.cookie, got, tt.want)
+		}
+	}
+
+	if got := languageOptions(); len(got) != 3 || got[0].Name != "English" || got[1].Tag != "es" || got[2].Tag != "pt-BR" {
+		t.Errorf("languageOptions() = %v", got)
+	}
+}
+
+func TestServeLanguage(t *testing.T) {
+	defer setCatalogs(nil)
+	setCatalogs(map[string]*catalog{"es": {lang: "es", name: "Español"}})
+
+	for _, tt := range []struct {
+		lang, referer    string
+		status           int
+		location, cookie string
+	}{
+		{"es", "http://godoc.org/github.com/user/repo?imports", http.StatusFound, "/github.com/user/repo?imports", "lang=es"},
+		{"en", "http://example.com/x", http.StatusFound, "/", "lang=en"},
+		{"xx", "", http.StatusNotFound, "", ""},
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/-/lang?l="+tt.lang, nil)
+		req.Header.Set("Referer", tt.referer)
+		req.ParseForm()
+		resp := httptest.NewRecorder()
+		err := serveLanguage(resp, req)
+		status := resp.Code
+		if e, ok := err.(*httpError); ok {
+			status = e.status
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		cookie := ""
+		if c := resp.Result().Cookies(); len(c) == 1 {
+			cookie = c[0].Name + "=" + c[0].Value
+		}
+		if status != tt.status || resp.Header().Get("Location") != tt.location || cookie != tt.cookie {
+			t.Errorf("lang %s: got %d %q %q, want %d %q %q", tt.lang, status, resp.Header().Get("Location"), cookie, tt.status, tt.location, tt.cookie)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements a proxy for the images in READMEs. The images are
+// served from the site instead of the external hosts, so that the pages work
+// on instances without access to the internet from browsers and the external
+// hosts do not see the addresses of the viewers. The URLs of the proxy are
+// signed with the image_proxy_secret, so that the proxy only fetches the
+// images of rendered READMEs.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+var (
+	imageProxySecret    = flag.String("image_proxy_secret", "", "Secret for signing the URLs of the proxy for the images in READMEs. Use the same secret on every replica. Empty disables the proxy and the images are loaded from the external hosts.")
+	imageProxyMaxSize   = flag.Int64("image_proxy_max_size", 5<<20, "Maximum size in bytes of an image served by the image proxy.")
+	imageProxyCacheSize = flag.Int64("image_proxy_cache_size", 64<<20, "Maximum total size in bytes of the images cached by the image proxy. Zero disables the cache.")
+	imageProxyCacheTTL  = flag.Duration("image_proxy_cache_ttl", time.Hour, "Time to cache the images served by the image proxy.")
+)
+
+const imageProxyPath = "/-/image/"
+
+// imageProxyPolicy is the Content-Security-Policy of the proxied images. SVG
+// images opened directly cannot run scripts on the site.
+const imageProxyPolicy = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+
+var errImageTooLarge = errors.New("image too large")
+
+// imageSignature returns the hex encoded signature of the image URL.
+func imageSignature(u string) string {
+	mac := hmac.New(sha256.New, []byte(*imageProxySecret))
+	mac.Write([]byte(u))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// proxyImageURL returns the URL of the image at u on the image proxy or u if
+// the proxy is disabled.
+func proxyImageURL(u string) string {
+	if *imageProxySecret == "" {
+		return u
+	}
+	return imageProxyPath + imageSignature(u) + "/" + hex.EncodeToString([]byte(u))
+}
+
+// proxiedImage is an image fetched by the image proxy.
+type proxiedImage struct {
+	contentType string
+	data        []byte
+	expires     time.Time
+}
+
+// imageCache is an in-process cache of proxied images bounded by the total
+// size of the images.
+type imageCache struct {
+	mu     sync.Mutex
+	images map[string]*proxiedImage
+	size   int64
+}
+
+var images = &imageCache{images: make(map[string]*proxiedImage)}
+
+// get returns the image with the URL or nil if the image is not in the
+// cache.
+func (c *imageCache) get(u string, now time.Time) *proxiedImage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img := c.images[u]
+	if img == nil || now.After(img.expires) {
+		return nil
+	}
+	return img
+}
+
+// put adds the image to the cache. If the cache is full, put removes the
+// expired images and then arbitrary images to make room.
+func (c *imageCache) put(u string, img *proxiedImage, now time.Time) {
+	n := int64(len(img.data))
+	if n > *imageProxyCacheSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.images[u]; old != nil {
+		c.size -= int64(len(old.data))
+		delete(c.images, u)
+	}
+	if c.size+n > *imageProxyCacheSize {
+		for k, q := range c.images {
+			if now.After(q.expires) {
+				c.size -= int64(len(q.data))
+				delete(c.images, k)
+			}
+		}
+	}
+	for k, q := range c.images {
+		if c.size+n <= *imageProxyCacheSize {
+			break
+		}
+		c.size -= int64(len(q.data))
+		delete(c.images, k)
+	}
+	c.images[u] = img
+	c.size += n
+}
+
+// imageProxyClient fetches the images. Redirects are checked against the
+// outbound policy like the first request.
+var imageProxyClient = &http.Client{
+	Transport: httpClient.Transport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		return gosrc.CheckOutboundHost(req.URL.Host)
+	},
+}
+
+// fetchImage fetches the image at u.
+func fetchImage(u string) (*proxiedImage, error) {
+	pu, err := url.Parse(u)
+	if err != nil || (pu.Scheme != "http" && pu.Scheme != "https") {
+		return nil, &httpError{status: http.StatusNotFound}
+	}
+	if err := gosrc.CheckOutboundHost(pu.Host); err != nil {
+		return nil, &httpError{status: http.StatusNotFound, err: err}
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(gosrc.WithOutboundPolicy(req.Context()))
+	req.Header.Set("Accept", "image/*")
+	resp, err := imageProxyClient.Do(req)
+	if err != nil {
+		return nil, &httpError{status: http.StatusBadGateway, err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpError{status: http.StatusNotFound, err: fmt.Errorf("fetching %s: %s", u, resp.Status)}
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "image/") {
+		return nil, &httpError{status: http.StatusNotFound, err: fmt.Errorf("fetching %s: content type %q is not an image", u, resp.Header.Get("Content-Type"))}
+	}
+	if resp.ContentLength > *imageProxyMaxSize {
+		return nil, &httpError{status: http.StatusNotFound, err: errImageTooLarge}
+	}
+	p, err := ioutil.ReadAll(&limitedBody{resp.Body, *imageProxyMaxSize})
+	if err == errResponseTooLarge {
+		return nil, &httpError{status: http.StatusNotFound, err: errImageTooLarge}
+	}
+	if err != nil {
+		return nil, &httpError{status: http.StatusBadGateway, err: err}
+	}
+	return &proxiedImage{contentType: mediaType, data: p}, nil
+}
+
+// serveImageProxy serves the image with the signed URL in the path of the
+// request.
+func serveImageProxy(resp http.ResponseWriter, req *http.Request) error {
+	if *imageProxySecret == "" {
+		return &httpError{status: http.StatusNotFound}
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, imageProxyPath), "/")
+	if len(parts) != 2 {
+		return &httpError{status: http.StatusNotFound}
+	}
+	p, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+	u := string(p)
+	if !hmac.Equal([]byte(parts[0]), []byte(imageSignature(u))) {
+		return &httpError{status: http.StatusForbidden}
+	}
+
+	now := time.Now()
+	img := images.get(u, now)
+	if img == nil {
+		img, err = fetchImage(u)
+		if err != nil {
+			return err
+		}
+		img.expires = now.Add(*imageProxyCacheTTL)
+		images.put(u, img, now)
+	}
+
+	h := resp.Header()
+	h.Set("Content-Security-Policy", imageProxyPolicy)
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(imageProxyCacheTTL.Seconds())))
+	h.Set("Content-Type", img.contentType)
+	h.Set("Content-Length", strconv.Itoa(len(img.data)))
+	if req.Method == "HEAD" {
+		return nil
+	}
+	_, err = resp.Write(img.data)
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeImageProxy(t *testing.T) {
+	defer func(secret string, maxSize int64) {
+		*imageProxySecret = secret
+		*imageProxyMaxSize = maxSize
+		images = &imageCache{images: make(map[string]*proxiedImage)}
+	}(*imageProxySecret, *imageProxyMaxSize)
+	*imageProxySecret = "secret"
+	*imageProxyMaxSize = 10
+
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		switch r.URL.Path {
+		case "/a.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		case "/large.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(strings.Repeat("x", 11)))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>"))
+		}
+	}))
+	defer ts.Close()
+
+	serve := func(path string) (*httptest.ResponseRecorder, error) {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := httptest.NewRecorder()
+		return resp, serveImageProxy(resp, req)
+	}
+
+	u := proxyImageURL(ts.URL + "/a.png")
+	if !strings.HasPrefix(u, imageProxyPath) {
+		t.Fatalf("proxyImageURL returned %q, want URL on the proxy", u)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := serve(u)
+		if err != nil {
+			t.Fatalf("serveImageProxy returned %v", err)
+		}
+		if resp.Body.String() != "png" || resp.Header().Get("Content-Type") != "image/png" || resp.Header().Get("Content-Security-Policy") != imageProxyPolicy {
+			t.Errorf("response = %q %v, want png image", resp.Body.String(), resp.Header())
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("image fetched %d times, want 1", fetches)
+	}
+
+	// The signature of another URL is rejected.
+	bad := proxyImageURL(ts.URL + "/b.png")
+	bad = u[:strings.LastIndex(u, "/")] + bad[strings.LastIndex(bad, "/"):]
+	if _, err := serve(bad); err == nil || err.(*httpError).status != http.StatusForbidden {
+		t.Errorf("serveImageProxy with bad signature returned %v, want status 403", err)
+	}
+	for _, path := range []string{"/large.png", "/page.html"} {
+		if _, err := serve(proxyImageURL(ts.URL + path)); err == nil || err.(*httpError).status != http.StatusNotFound {
+			t.Errorf("serveImageProxy(%s) returned %v, want status 404", path, err)
+		}
+	}
+
+	*imageProxySecret = ""
+	if u := proxyImageURL("https://example.com/a.png"); u != "https://example.com/a.png" {
+		t.Errorf("proxyImageURL with proxy disabled returned %q", u)
+	}
+}
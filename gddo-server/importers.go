@@ -0,0 +1,166 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+// The importers page of a package shows importersPerPage importers per page,
+// sorted by the sort parameter and filtered by the host parameter. The
+// format parameter exports all the selected importers as CSV or JSON.
+
+const importersPerPage = 100
+
+var importerSorts = []string{database.ImportersByPath, database.ImportersByStars, database.ImportersByImporters}
+
+// importerQuery returns the database query and the page number of the
+// importers page request.
+func importerQuery(req *http.Request) (database.ImporterQuery, int, error) {
+	q := database.ImporterQuery{
+		Sort:    req.Form.Get("sort"),
+		Host:    strings.ToLower(strings.Trim(req.Form.Get("host"), "/")),
+		Limit:   importersPerPage,
+		Private: isAuthenticated(req),
+	}
+	if q.Sort == "" {
+		q.Sort = database.ImportersByPath
+	}
+	valid := false
+	for _, s := range importerSorts {
+		valid = valid || q.Sort == s
+	}
+	if !valid || strings.Contains(q.Host, "/") {
+		return q, 0, &httpError{status: http.StatusBadRequest}
+	}
+	page := 1
+	if s := req.Form.Get("page"); s != "" {
+		var err error
+		page, err = strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return q, 0, &httpError{status: http.StatusBadRequest}
+		}
+	}
+	q.Offset = (page - 1) * importersPerPage
+	return q, page, nil
+}
+
+// importersURL returns the URL of the importers page with the query and page.
+func importersURL(importPath string, q database.ImporterQuery, page int) string {
+	v := url.Values{}
+	if q.Sort != database.ImportersByPath {
+		v.Set("sort", q.Sort)
+	}
+	if q.Host != "" {
+		v.Set("host", q.Host)
+	}
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	u := "/" + importPath + "?importers"
+	if len(v) > 0 {
+		u += "&" + v.Encode()
+	}
+	return u
+}
+
+func serveImporters(resp http.ResponseWriter, req *http.Request, pdoc *doc.Package, requestType int, flashMessages []flashMessage) error {
+	q, page, err := importerQuery(req)
+	if err != nil {
+		return err
+	}
+
+	switch format := req.Form.Get("format"); format {
+	case "csv", "json":
+		// The export of all the importers is a bulk listing.
+		if err := checkAPIKey(resp, req); err != nil {
+			return err
+		}
+		q.Offset, q.Limit = 0, 0
+		pkgs, _, err := db.ImporterPage(pdoc.ImportPath, q)
+		if err != nil {
+			return err
+		}
+		return writeImporters(resp, pdoc.ImportPath, format, pkgs)
+	case "":
+	default:
+		return &httpError{status: http.StatusBadRequest}
+	}
+
+	pkgs, total, err := db.ImporterPage(pdoc.ImportPath, q)
+	if err != nil {
+		return err
+	}
+	if page > 1 && q.Offset >= total {
+		return &httpError{status: http.StatusNotFound}
+	}
+	data := map[string]interface{}{
+		"flashMessages": flashMessages,
+		"importers":     pkgs,
+		"total":         total,
+		"pdoc":          newTDoc(pdoc),
+		"sort":          q.Sort,
+		"host":          q.Host,
+		"sorts":         importerSorts,
+		"first":         q.Offset + 1,
+		"last":          q.Offset + len(pkgs),
+	}
+	queryURL := func(q database.ImporterQuery, page int) string { return importersURL(pdoc.ImportPath, q, page) }
+	if page > 1 {
+		data["prev"] = queryURL(q, page-1)
+	}
+	if q.Offset+len(pkgs) < total {
+		data["next"] = queryURL(q, page+1)
+	}
+	sortURLs := make(map[string]string)
+	for _, s := range importerSorts {
+		sq := q
+		sq.Sort = s
+		sortURLs[s] = queryURL(sq, 1)
+	}
+	data["sortURLs"] = sortURLs
+	data["exportURL"] = queryURL(q, 1)
+
+	template := "importers.html"
+	if requestType != humanRequest {
+		// Hide back links from robots.
+		template = "importers_robot.html"
+	}
+	return executeTemplate(resp, template, http.StatusOK, nil, data)
+}
+
+// writeImporters writes the importers of the package with the import path in
+// the format, csv or json.
+func writeImporters(resp http.ResponseWriter, importPath, format string, pkgs []database.Importer) error {
+	name := strings.Replace(importPath, "/", "_", -1) + "-importers." + format
+	resp.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if format == "json" {
+		if pkgs == nil {
+			pkgs = []database.Importer{}
+		}
+		resp.Header().Set("Content-Type", jsonMIMEType)
+		return json.NewEncoder(resp).Encode(&struct {
+			Results []database.Importer `json:"results"`
+		}{pkgs})
+	}
+	resp.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w := csv.NewWriter(resp)
+	w.Write([]string{"path", "synopsis", "stars", "importers"})
+	for _, pkg := range pkgs {
+		w.Write([]string{pkg.Path, pkg.Synopsis, strconv.Itoa(pkg.Stars), strconv.Itoa(pkg.Importers)})
+	}
+	w.Flush()
+	return w.Error()
+}
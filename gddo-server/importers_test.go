@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+func TestImporterQuery(t *testing.T) {
+	req := httptest.NewRequest("GET", "/example.com/lib?importers&sort=stars&host=GitHub.com&page=3", nil)
+	req.ParseForm()
+	q, page, err := importerQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Sort != database.ImportersByStars || q.Host != "github.com" || q.Offset != 2*importersPerPage || q.Limit != importersPerPage || page != 3 {
+		t.Errorf("importerQuery() = %+v, %d", q, page)
+	}
+	if got, want := importersURL("example.com/lib", q, page+1), "/example.com/lib?importers&host=github.com&page=4&sort=stars"; got != want {
+		t.Errorf("importersURL() = %q, want %q", got, want)
+	}
+
+	for _, rawQuery := range []string{"importers&sort=size", "importers&page=0", "importers&host=github.com/user"} {
+		req := httptest.NewRequest("GET", "/example.com/lib?"+rawQuery, nil)
+		req.ParseForm()
+		if _, _, err := importerQuery(req); err == nil {
+			t.Errorf("importerQuery(%q) returned no error", rawQuery)
+		}
+	}
+}
+
+func TestWriteImporters(t *testing.T) {
+	w := httptest.NewRecorder()
+	pkgs := []database.Importer{{Path: "github.com/a/x", Synopsis: "Package x, with a comma.", Stars: 5, Importers: 2}}
+	if err := writeImporters(w, "example.com/lib", "csv", pkgs); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Body.String(), "path,synopsis,stars,importers\ngithub.com/a/x,\"Package x, with a comma.\",5,2\n"; got != want {
+		t.Errorf("csv = %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Content-Disposition"), `attachment; filename="example.com_lib-importers.csv"`; got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	if err := writeImporters(w, "example.com/lib", "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Body.String(), "{\"results\":[]}\n"; got != want {
+		t.Errorf("json = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Replicas sharing a database coordinate the background tasks with a lease
+// on each task. A replica runs a task only while it holds the lease of the
+// task. The holder extends the lease on every pass of the background loop
+// and while the task runs. When the holder stops, the lease expires and
+// another replica takes over the task.
+
+var (
+	taskLease      = flag.Duration("task_lease", 3*time.Minute, "Duration of the leases that give a replica the ownership of the background tasks. A replica takes over the tasks of a replica that stopped after the leases expire. Zero runs the background tasks on every replica.")
+	replicaID      = flag.String("replica_id", "", "Name of the replica in the leases of the background tasks. The default is the host name, the process ID and a random suffix.")
+	migrateTimeout = flag.Duration("migrate_timeout", 10*time.Minute, "Time to wait at startup for another replica to complete the migration of the database schema.")
+)
+
+// defaultReplicaID returns a name that is unique to the process.
+func defaultReplicaID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	var p [4]byte
+	rand.Read(p[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(p[:]))
+}
+
+// useLeases returns true if the background tasks are coordinated with other
+// replicas. Leases are not used in read-only mode because they are writes.
+func useLeases() bool {
+	return *taskLease > 0 && !*readOnly
+}
+
+// holdTaskLease acquires or extends the lease of the task and returns true
+// if this replica holds the lease. Tasks running on every replica do not
+// have a lease.
+func holdTaskLease(task *backgroundTask) bool {
+	if !useLeases() || task.perReplica {
+		return true
+	}
+	ok, err := db.AcquireLease("task:"+task.name, *replicaID, *taskLease)
+	if err != nil {
+		slog.Error("db.AcquireLease", "task", task.name, "err", err)
+		return false
+	}
+	return ok
+}
+
+// extendTaskLease extends the lease of the running task until the returned
+// function is called.
+func extendTaskLease(task *backgroundTask) (stop func()) {
+	if !useLeases() || task.perReplica {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(*taskLease / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if !holdTaskLease(task) {
+					slog.Warn("lost lease of running task", "task", task.name)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,48 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultReplicaID(t *testing.T) {
+	a, b := defaultReplicaID(), defaultReplicaID()
+	if a == b {
+		t.Errorf("defaultReplicaID() returned %q twice", a)
+	}
+	if strings.Count(a, "-") < 2 {
+		t.Errorf("defaultReplicaID() = %q, want host-pid-suffix", a)
+	}
+}
+
+func TestUseLeases(t *testing.T) {
+	defer func(d time.Duration, r bool) { *taskLease, *readOnly = d, r }(*taskLease, *readOnly)
+	for _, tt := range []struct {
+		lease    time.Duration
+		readOnly bool
+		want     bool
+	}{
+		{time.Minute, false, true},
+		{0, false, false},
+		{time.Minute, true, false},
+	} {
+		*taskLease, *readOnly = tt.lease, tt.readOnly
+		if got := useLeases(); got != tt.want {
+			t.Errorf("lease %v, read only %v: useLeases() = %v, want %v", tt.lease, tt.readOnly, got, tt.want)
+		}
+	}
+
+	// Without leases every replica holds the task.
+	*taskLease = 0
+	if !holdTaskLease(backgroundTasks[0]) {
+		t.Error("holdTaskLease() = false without leases")
+	}
+	extendTaskLease(backgroundTasks[0])()
+}
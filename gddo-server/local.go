@@ -0,0 +1,148 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+// In local mode, the server builds the documentation of the packages of a
+// module in a directory of the local file system with the doc builder and
+// the templates of the site. The mode does not use the database and does not
+// crawl, so it works offline. The documentation of a package is built again
+// when a file of the package directory changes.
+
+var localDir = flag.String("local", "", "Serve the documentation of the module in this directory at the -http address without the database or crawling.")
+
+// localSite serves the documentation of a module on the local file system.
+type localSite struct {
+	module *gosrc.LocalModule
+
+	mu   sync.Mutex
+	docs map[string]*doc.Package
+}
+
+// doc returns the documentation of the package with the import path in the
+// module.
+func (s *localSite) doc(importPath string) (*doc.Package, error) {
+	dir, err := s.module.Get(importPath)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	pdoc := s.docs[importPath]
+	s.mu.Unlock()
+	if pdoc != nil && pdoc.Etag == doc.PackageVersion+"-"+dir.Etag {
+		return pdoc, nil
+	}
+	pdoc, err = doc.Build(dir)
+	if err != nil {
+		return nil, err
+	}
+	pdoc.Updated = time.Now().UTC()
+	s.mu.Lock()
+	s.docs[importPath] = pdoc
+	s.mu.Unlock()
+	return pdoc, nil
+}
+
+// packagesUnder returns the packages of the module below the import path.
+func (s *localSite) packagesUnder(importPath string) ([]database.Package, error) {
+	paths, err := s.module.Packages()
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []database.Package
+	for _, p := range paths {
+		if !strings.HasPrefix(p, importPath+"/") {
+			continue
+		}
+		pdoc, err := s.doc(p)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, database.Package{Path: p, Synopsis: pdoc.Synopsis})
+	}
+	return pkgs, nil
+}
+
+// servePackage serves the documentation page and the coverage view of the
+// packages in the module. The home page redirects to the module root.
+func (s *localSite) servePackage(resp http.ResponseWriter, req *http.Request) error {
+	importPath := strings.TrimPrefix(req.URL.Path, "/")
+	if importPath == "" {
+		http.Redirect(resp, req, "/"+s.module.Path, http.StatusFound)
+		return nil
+	}
+	pdoc, err := s.doc(importPath)
+	if err != nil {
+		return err
+	}
+	pkgs, err := s.packagesUnder(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc.Name == "" && len(pkgs) == 0 {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	switch {
+	case len(req.Form) == 0:
+		template := "dir.html"
+		switch {
+		case pdoc.IsCmd:
+			template = "cmd.html"
+		case pdoc.Name != "":
+			template = "pkg.html"
+		}
+		return executeTemplate(resp, template, http.StatusOK, nil, map[string]interface{}{
+			"pkgs": pkgs,
+			"pdoc": newTDoc(pdoc),
+		})
+	case isView(req, "coverage") && pdoc.Name != "":
+		return executeTemplate(resp, "coverage.html", http.StatusOK, nil, map[string]interface{}{
+			"pdoc":     newTDoc(pdoc),
+			"coverage": pdoc.Coverage(),
+		})
+	}
+	return &httpError{status: http.StatusNotFound}
+}
+
+// runLocal serves the documentation of the module in the directory until the
+// server fails.
+func runLocal(dir string) error {
+	m, err := gosrc.OpenLocalModule(dir)
+	if err != nil {
+		return err
+	}
+	s := &localSite{module: m, docs: make(map[string]*doc.Package)}
+
+	staticServer := newStaticServer()
+	mux := http.NewServeMux()
+	mux.Handle("/-/site.js", staticServer.FilesHandler(
+		"third_party/jquery.timeago.js",
+		"site.js"))
+	mux.Handle("/-/site.css", staticServer.FilesHandler("site.css"))
+	if *sidebarEnabled {
+		mux.Handle("/-/sidebar.css", staticServer.FilesHandler("sidebar.css"))
+	}
+	mux.Handle("/favicon.ico", staticServer.FileHandler("favicon.ico"))
+	mux.Handle("/", handler(s.servePackage))
+	cacheBusters.Handler = mux
+
+	slog.Info("serving local module", "path", m.Path, "dir", m.Dir, "addr", *httpAddr)
+	return http.ListenAndServe(*httpAddr, mux)
+}
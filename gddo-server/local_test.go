@@ -0,0 +1,50 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+func TestLocalSite(t *testing.T) {
+	root, err := ioutil.TempDir("", "module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/mod\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	m, err := gosrc.OpenLocalModule(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &localSite{module: m, docs: make(map[string]*doc.Package)}
+
+	resp := httptest.NewRecorder()
+	if err := s.servePackage(resp, httptest.NewRequest("GET", "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusFound || resp.Header().Get("Location") != "/example.com/mod" {
+		t.Errorf("GET / = %d %q, want redirect to /example.com/mod", resp.Code, resp.Header().Get("Location"))
+	}
+
+	for _, p := range []string{"/example.com/other", "/example.com/mod/missing"} {
+		err := s.servePackage(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+		if !gosrc.IsNotFound(err) {
+			t.Errorf("GET %s: err = %v, want not found error", p, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements structured logging and the request IDs used to
+// correlate the log records of a request with the fetches it triggers.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+var (
+	logFormat = flag.String("log_format", "text", "Format of log records: text or json.")
+	logLevel  = flag.String("log_level", "info", "Minimum level of logged records: debug, info, warn or error.")
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID matches the inbound request IDs that are propagated. Other
+// IDs are replaced so that clients cannot inject arbitrary text into logs and
+// outbound requests.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// newLogHandler returns the handler writing records to stderr in the format
+// at or above the level.
+func newLogHandler(format, level string) (slog.Handler, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("bad -log_level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(os.Stderr, opts), nil
+	case "json":
+		return slog.NewJSONHandler(os.Stderr, opts), nil
+	}
+	return nil, fmt.Errorf("bad -log_format %q", format)
+}
+
+// setupLogging sets the default logger from the flags. Records written with
+// package log are logged at the info level.
+func setupLogging() error {
+	h, err := newLogHandler(*logFormat, *logLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+type requestIDKey struct{}
+
+// withRequestID returns a copy of ctx with the request ID.
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns the request ID in ctx or "".
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// inboundRequestID returns the ID of the request from the X-Request-ID header
+// or a new random ID.
+func inboundRequestID(req *http.Request) string {
+	if id := req.Header.Get(requestIDHeader); validRequestID.MatchString(id) {
+		return id
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// logger returns the default logger with the request ID in ctx.
+func logger(ctx context.Context) *slog.Logger {
+	if id := requestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
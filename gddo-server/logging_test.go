@@ -0,0 +1,72 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	for _, tt := range []struct {
+		format, level string
+		ok            bool
+	}{
+		{"text", "info", true},
+		{"json", "debug", true},
+		{"json", "WARN", true},
+		{"xml", "info", false},
+		{"text", "loud", false},
+	} {
+		_, err := newLogHandler(tt.format, tt.level)
+		if (err == nil) != tt.ok {
+			t.Errorf("newLogHandler(%q, %q) returned error %v, want ok %v", tt.format, tt.level, err, tt.ok)
+		}
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(requestIDHeader)
+	}))
+	defer ts.Close()
+
+	h := handler(func(resp http.ResponseWriter, req *http.Request) error {
+		r, err := fetchClient(req.Context()).Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		r.Body.Close()
+		return nil
+	})
+
+	for _, tt := range []struct {
+		inbound string
+		keep    bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"bad id\n", false},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.inbound != "" {
+			req.Header.Set(requestIDHeader, tt.inbound)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		id := w.Header().Get(requestIDHeader)
+		if id == "" || (tt.keep && id != tt.inbound) || (!tt.keep && id == tt.inbound) {
+			t.Errorf("inbound %q: response ID %q", tt.inbound, id)
+		}
+		if got != id {
+			t.Errorf("inbound %q: fetch sent ID %q, want %q", tt.inbound, got, id)
+		}
+	}
+}
@@ -8,7 +8,7 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
@@ -18,6 +18,7 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -28,8 +29,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/golang/gddo/crawler"
 	"github.com/golang/gddo/database"
 	"github.com/golang/gddo/doc"
 	"github.com/golang/gddo/gosrc"
@@ -45,8 +48,9 @@ const (
 var errUpdateTimeout = errors.New("refresh timeout")
 
 type httpError struct {
-	status int   // HTTP status code.
-	err    error // Optional reason for the HTTP error.
+	status int         // HTTP status code.
+	err    error       // Optional reason for the HTTP error.
+	header http.Header // Optional headers of the error response.
 }
 
 func (err *httpError) Error() string {
@@ -62,16 +66,53 @@ const (
 	queryRequest
 	refreshRequest
 	apiRequest
+	cachedRequest // Documents in the database only, such as for some robots.
 )
 
-type crawlResult struct {
+// A crawl is a crawl of a package started by a request. Concurrent requests
+// for the package wait for the crawl in flight instead of starting another.
+type crawl struct {
+	done chan struct{}
 	pdoc *doc.Package
 	err  error
 }
 
+var crawls = struct {
+	mu sync.Mutex
+	m  map[string]*crawl
+}{m: make(map[string]*crawl)}
+
+// crawlInFlight returns the crawl of the package with the import path in
+// flight or nil.
+func crawlInFlight(path string) *crawl {
+	crawls.mu.Lock()
+	defer crawls.mu.Unlock()
+	return crawls.m[path]
+}
+
+// startCrawl starts fn to crawl the package with the import path unless a
+// crawl of the package is in flight and returns the crawl.
+func startCrawl(path string, fn func() (*doc.Package, error)) *crawl {
+	crawls.mu.Lock()
+	defer crawls.mu.Unlock()
+	if c := crawls.m[path]; c != nil {
+		return c
+	}
+	c := &crawl{done: make(chan struct{})}
+	crawls.m[path] = c
+	go func() {
+		c.pdoc, c.err = fn()
+		crawls.mu.Lock()
+		delete(crawls.m, path)
+		crawls.mu.Unlock()
+		close(c.done)
+	}()
+	return c
+}
+
 // getDoc gets the package documentation from the database or from the version
 // control system as needed.
-func getDoc(path string, requestType int) (*doc.Package, []database.Package, error) {
+func getDoc(ctx context.Context, path string, requestType int) (*doc.Package, []database.Package, error) {
 	if path == "-" {
 		// A hack in the database package uses the path "-" to represent the
 		// next document to crawl. Block "-" here so that requests to /- always
@@ -79,7 +120,17 @@ func getDoc(path string, requestType int) (*doc.Package, []database.Package, err
 		return nil, nil, &httpError{status: http.StatusNotFound}
 	}
 
-	pdoc, pkgs, nextCrawl, err := db.Get(path)
+	if !isAllowed(path) {
+		return nil, nil, &httpError{status: http.StatusNotFound}
+	}
+
+	var pdoc *doc.Package
+	var pkgs []database.Package
+	var nextCrawl time.Time
+	err := traceDB(ctx, "db.Get", func() (err error) {
+		pdoc, pkgs, nextCrawl, err = db.Get(path)
+		return err
+	})
 	if err != nil {
 		return nil, nil, err
 	}
@@ -92,17 +143,65 @@ func getDoc(path string, requestType int) (*doc.Package, []database.Package, err
 		needsCrawl = nextCrawl.Before(time.Now())
 	case robotRequest:
 		needsCrawl = nextCrawl.IsZero() && len(pkgs) > 0
+	case cachedRequest:
+		needsCrawl = false
+	}
+	if !needsCrawl && pdoc == nil && requestType != cachedRequest {
+		// The evicted documents are rebuilt for every request.
+		err := traceDB(ctx, "db.IsEvicted", func() (err error) {
+			needsCrawl, err = db.IsEvicted(path)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	if !needsCrawl {
+	if !needsCrawl || isMirrored(pdoc) || *readOnly {
 		return pdoc, pkgs, nil
 	}
+	if pdoc == nil && len(pkgs) == 0 {
+		// Serve the cached result of a crawl that did not find the package.
+		var nf *gosrc.NotFoundError
+		err := traceDB(ctx, "db.GetNotFound", func() (err error) {
+			nf, err = db.GetNotFound(path)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if nf != nil {
+			return nil, nil, *nf
+		}
+	}
+	cr := crawlInFlight(path)
+	if cr == nil {
+		if err := checkRate(ctx, crawlLimiter); err != nil {
+			if pdoc != nil {
+				return pdoc, pkgs, nil
+			}
+			return nil, nil, err
+		}
+		host := fetchHost(path)
+		if !breakers.allow(host, time.Now()) {
+			if pdoc != nil {
+				return pdoc, pkgs, nil
+			}
+			if err := db.AddNewCrawl(path); err != nil {
+				return nil, nil, &httpError{status: http.StatusNotFound}
+			}
+			return nil, nil, fetchingError()
+		}
 
-	c := make(chan crawlResult, 1)
-	go func() {
-		pdoc, err := crawlDoc("web  ", path, pdoc, len(pkgs) > 0, nextCrawl)
-		c <- crawlResult{pdoc, err}
-	}()
+		// The crawl continues after the request times out or ends.
+		crawlCtx := context.WithoutCancel(ctx)
+		stored, hasImporters := pdoc, len(pkgs) > 0
+		cr = startCrawl(path, func() (*doc.Package, error) {
+			pdoc, err := docCrawler.Crawl(crawlCtx, "web  ", path, stored, hasImporters, nextCrawl)
+			breakers.record(host, err == nil || gosrc.IsNotFound(err), time.Now())
+			return pdoc, err
+		})
+	}
 
 	timeout := *getTimeout
 	if pdoc == nil {
@@ -110,7 +209,7 @@ func getDoc(path string, requestType int) (*doc.Package, []database.Package, err
 	}
 
 	select {
-	case cr := <-c:
+	case <-cr.done:
 		err = cr.err
 		if err == nil {
 			pdoc = cr.pdoc
@@ -125,11 +224,11 @@ func getDoc(path string, requestType int) (*doc.Package, []database.Package, err
 	case gosrc.IsNotFound(err):
 		return nil, nil, err
 	case pdoc != nil:
-		log.Printf("Serving %q from database after error getting doc: %v", path, err)
+		logger(ctx).Warn("serving from database after error getting doc", "path", path, "err", err)
 		return pdoc, pkgs, nil
 	case err == errUpdateTimeout:
-		log.Printf("Serving %q as not found after timeout getting doc", path)
-		return nil, nil, &httpError{status: http.StatusNotFound}
+		logger(ctx).Warn("fetching in the background after timeout getting doc", "path", path)
+		return nil, nil, fetchingError()
 	default:
 		return nil, nil, err
 	}
@@ -150,14 +249,17 @@ func isRobot(req *http.Request) bool {
 	if robotPat.MatchString(req.Header.Get("User-Agent")) {
 		return true
 	}
+	if *readOnly {
+		return false
+	}
 	host := httputil.StripPort(req.RemoteAddr)
 	n, err := db.IncrementCounter(host, 1)
 	if err != nil {
-		log.Printf("error incrementing counter for %s, %v", host, err)
+		logger(req.Context()).Error("db.IncrementCounter", "host", host, "err", err)
 		return false
 	}
 	if n > *robot {
-		log.Printf("robot %.2f %s %s", n, host, req.Header.Get("User-Agent"))
+		logger(req.Context()).Info("robot", "count", n, "host", host, "user_agent", req.Header.Get("User-Agent"))
 		return true
 	}
 	return false
@@ -173,9 +275,20 @@ func isView(req *http.Request, key string) bool {
 		(len(rq) == len(key) || rq[len(key)] == '=' || rq[len(key)] == '&')
 }
 
-// httpEtag returns the package entity tag used in HTTP transactions.
-func httpEtag(pdoc *doc.Package, pkgs []database.Package, importerCount int, flashMessages []flashMessage) string {
+// httpEtag returns the package entity tag used in HTTP transactions. The
+// version is the version or day of the saved document shown on the page.
+func httpEtag(template, version string, pdoc *doc.Package, pkgs, similar []database.Package, importerCount int, verified bool, canonical string, vulns []database.Vuln, majors []string, flashMessages []flashMessage) string {
 	b := make([]byte, 0, 128)
+	b = append(b, template...)
+	b = append(b, 0)
+	templatesMu.RLock()
+	b = append(b, templateVersions[template]...)
+	templatesMu.RUnlock()
+	b = append(b, 0)
+	b = append(b, version...)
+	b = append(b, 0)
+	b = append(b, announcementsKey(activeAnnouncements())...)
+	b = append(b, 0)
 	b = strconv.AppendInt(b, pdoc.Updated.Unix(), 16)
 	b = append(b, 0)
 	b = append(b, pdoc.Etag...)
@@ -190,9 +303,29 @@ func httpEtag(pdoc *doc.Package, pkgs []database.Package, importerCount int, fla
 		b = append(b, 0)
 		b = append(b, pkg.Synopsis...)
 	}
+	for _, pkg := range similar {
+		b = append(b, 1)
+		b = append(b, pkg.Path...)
+	}
 	if *sidebarEnabled {
 		b = append(b, "\000xsb"...)
 	}
+	if verified {
+		b = append(b, "\000v"...)
+	}
+	if canonical != "" {
+		b = append(b, "\000c"...)
+		b = append(b, canonical...)
+	}
+	for _, v := range vulns {
+		b = append(b, 2)
+		b = append(b, v.ID...)
+		b = strconv.AppendInt(b, v.Modified.Unix(), 16)
+	}
+	for _, p := range majors {
+		b = append(b, 3)
+		b = append(b, p...)
+	}
 	for _, m := range flashMessages {
 		b = append(b, 0)
 		b = append(b, m.ID...)
@@ -207,6 +340,51 @@ func httpEtag(pdoc *doc.Package, pkgs []database.Package, importerCount int, fla
 	return fmt.Sprintf("\"%x\"", b)
 }
 
+// etagMatch returns true if the If-None-Match header value matches etag.
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// docHeader returns the entity tag and caching headers for a documentation
+// page and the status of the response to the request.
+func docHeader(req *http.Request, etag string, private bool) (http.Header, int) {
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+	cacheControl := scope + ", no-cache"
+	if *docMaxAge > 0 {
+		cacheControl = fmt.Sprintf("%s, max-age=%d", scope, int(docMaxAge.Seconds()))
+	}
+	if lang := requestLanguage(req.Context()); lang != defaultLanguage {
+		etag = strings.TrimSuffix(etag, `"`) + "-" + lang + `"`
+	}
+	header := http.Header{"Etag": {etag}, "Cache-Control": {cacheControl}}
+	if etagMatch(req.Header.Get("If-None-Match"), etag) {
+		return header, http.StatusNotModified
+	}
+	return header, http.StatusOK
+}
+
+// countView counts a view of the package documentation.
+func countView(ctx context.Context, importPath string) {
+	if *readOnly {
+		return
+	}
+	if err := db.IncrementPopularScore(importPath); err != nil {
+		logger(ctx).Error("db.IncrementPopularScore", "path", importPath, "err", err)
+	}
+	if err := db.IncrementViews(importPath); err != nil {
+		logger(ctx).Error("db.IncrementViews", "path", importPath, "err", err)
+	}
+}
+
 func servePackage(resp http.ResponseWriter, req *http.Request) error {
 	p := path.Clean(req.URL.Path)
 	if strings.HasPrefix(p, "/pkg/") {
@@ -227,18 +405,79 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 		return nil
 	}
 
+	if isView(req, "popularity.svg") {
+		return servePopularityImage(resp, req, p[1:])
+	}
+
+	if !isAllowed(strings.TrimPrefix(strings.SplitN(p, "@", 2)[0], "/")) {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	if err := checkBlocked(strings.TrimPrefix(strings.SplitN(p, "@", 2)[0], "/")); err != nil {
+		return err
+	}
+
+	if gosrc.IsTalkFile(p) {
+		return serveTalk(resp, req, p[1:])
+	}
+
+	if isAccountPath(p[1:]) && len(req.Form) == 0 {
+		return serveAccount(resp, req, p[1:])
+	}
+
+	if isView(req, "at") {
+		return servePackageAt(resp, req, p[1:], req.Form.Get("at"))
+	}
+
+	if i := strings.LastIndex(p, "@"); i > 0 {
+		return servePackageVersion(resp, req, p[1:i], p[i+1:])
+	}
+
 	requestType := humanRequest
 	if isRobot(req) {
-		requestType = robotRequest
+		requestType = robotRequestType()
 	}
 
 	importPath := strings.TrimPrefix(req.URL.Path, "/")
-	pdoc, pkgs, err := getDoc(importPath, requestType)
+	if isView(req, "recheck") && requestType == humanRequest && !*readOnly {
+		// Forget the cached not found result and fetch the package again.
+		if err := checkRate(req.Context(), crawlLimiter); err != nil {
+			return err
+		}
+		if err := db.DeleteNotFound(importPath); err != nil {
+			return err
+		}
+		http.Redirect(resp, req, "/"+importPath, http.StatusFound)
+		return nil
+	}
+
+	cacheKey := pageCacheKey(req, importPath)
+	if page := pages.get(cacheKey, time.Now()); page != nil {
+		if requestType == humanRequest && page.countView && !popularLinkReferral(req) {
+			countView(req.Context(), importPath)
+		}
+		page.serve(resp, req)
+		return nil
+	}
+
+	if moved, err := db.MovedPath(importPath); err != nil {
+		return err
+	} else if moved != "" {
+		u := "/" + moved
+		if req.URL.RawQuery != "" {
+			u += "?" + req.URL.RawQuery
+		}
+		setFlashMessages(resp, []flashMessage{{ID: "redir", Args: []string{importPath}}})
+		http.Redirect(resp, req, u, http.StatusMovedPermanently)
+		return nil
+	}
+
+	pdoc, pkgs, err := getDoc(req.Context(), importPath, requestType)
 
 	if e, ok := err.(gosrc.NotFoundError); ok && e.Redirect != "" {
 		// To prevent dumb clients from following redirect loops, respond with
 		// status 404 if the target document is not found.
-		if _, _, err := getDoc(e.Redirect, requestType); gosrc.IsNotFound(err) {
+		if _, _, err := getDoc(req.Context(), e.Redirect, requestType); gosrc.IsNotFound(err) {
 			return &httpError{status: http.StatusNotFound}
 		}
 		u := "/" + e.Redirect
@@ -253,6 +492,14 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 		return err
 	}
 
+	if !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	pkgs, err = filterPrivate(req, pkgs)
+	if err != nil {
+		return err
+	}
+
 	flashMessages := getFlashMessages(resp, req)
 
 	if pdoc == nil {
@@ -271,8 +518,47 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 		}
 	}
 
+	if pdoc.Canonical != "" && pdoc.Canonical != importPath && *canonicalRedirect {
+		if cdoc, cpkgs, err := getDoc(req.Context(), pdoc.Canonical, requestType); err == nil && (cdoc != nil || len(cpkgs) > 0) {
+			u := "/" + pdoc.Canonical
+			if req.URL.RawQuery != "" {
+				u += "?" + req.URL.RawQuery
+			}
+			http.Redirect(resp, req, u, http.StatusMovedPermanently)
+			return nil
+		}
+	}
+
+	platformView := isView(req, "platform") && len(req.Form) == 1
+	if platformView {
+		pdoc, err = platformDoc(pdoc, req.Form.Get("platform"))
+		if err != nil {
+			return err
+		}
+		if pdoc == nil {
+			return &httpError{status: http.StatusNotFound}
+		}
+	}
+
+	exportView := isView(req, "export") && len(req.Form) == 1 && pdoc.Name != "" && !pdoc.IsCmd
+	fragmentView := isView(req, "fragment") && len(req.Form) == 1 && pdoc.Name != "" && !pdoc.IsCmd
+
+	var sections []string
+	if requestType == humanRequest && templateExt(req) == ".html" {
+		sections = docSections(pdoc)
+	}
+
+	// The expand parameter expands collapsed type declarations of the
+	// package page; see TypeDecl.
+	views := len(req.Form)
+	expand, expandParam := req.Form["expand"]
+	if expandParam {
+		views--
+	}
+	sectionView := isView(req, "section") && views == 1 && sections != nil
+
 	switch {
-	case len(req.Form) == 0:
+	case views == 0 || platformView || exportView || fragmentView || sectionView:
 		importerCount := 0
 		if pdoc.Name != "" {
 			importerCount, err = db.ImporterCount(importPath)
@@ -281,23 +567,19 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 			}
 		}
 
-		etag := httpEtag(pdoc, pkgs, importerCount, flashMessages)
-		status := http.StatusOK
-		if req.Header.Get("If-None-Match") == etag {
-			status = http.StatusNotModified
-		}
-
-		if requestType == humanRequest &&
-			pdoc.Name != "" && // not a directory
-			pdoc.ProjectRoot != "" && // not a standard package
-			!pdoc.IsCmd &&
-			len(pdoc.Errors) == 0 &&
-			!popularLinkReferral(req) {
-			if err := db.IncrementPopularScore(pdoc.ImportPath); err != nil {
-				log.Printf("ERROR db.IncrementPopularScore(%s): %v", pdoc.ImportPath, err)
+		var similar []database.Package
+		if pdoc.Name != "" {
+			similar, err = db.Similar(importPath)
+			if err == nil {
+				similar, err = filterPrivate(req, filterAllowed(similar))
+			}
+			if err != nil {
+				return err
 			}
 		}
 
+		verified := isVerified(pdoc.ProjectRoot)
+
 		template := "dir"
 		switch {
 		case pdoc.IsCmd:
@@ -306,13 +588,93 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 			template = "pkg"
 		}
 		template += templateExt(req)
+		if fragmentView {
+			template = "fragment.html"
+		}
+
+		settings, err := db.ProjectSettings(pdoc.ProjectRoot)
+		if err != nil {
+			return err
+		}
+
+		var vulns []database.Vuln
+		if pdoc.Name != "" {
+			vulns, err = db.Vulns(importPath)
+			if err != nil {
+				return err
+			}
+		}
 
-		return executeTemplate(resp, template, status, http.Header{"Etag": {etag}}, map[string]interface{}{
+		majors, err := db.MajorVersions(importPath)
+		if err != nil {
+			return err
+		}
+		if len(majors) < 2 {
+			majors = nil
+		}
+
+		// Show one section of the declarations of large packages. The
+		// export and the fragment have all declarations.
+		var section string
+		if sections != nil && !exportView && !fragmentView {
+			section = sections[0]
+			if sectionView {
+				section = req.Form.Get("section")
+				if i := sort.SearchStrings(sections, section); i == len(sections) || sections[i] != section {
+					return &httpError{status: http.StatusNotFound}
+				}
+			}
+			pdoc = sectionDoc(pdoc, section)
+		}
+
+		var vendorDir string
+		pkgs, vendorDir = splitVendored(importPath, pkgs)
+
+		version := section
+		if expandParam {
+			version += "?expand=" + strings.Join(expand, ",")
+		}
+		etag := httpEtag(template, version, pdoc, pkgs, similar, importerCount, verified, settings.Canonical, vulns, majors, flashMessages)
+		header, status := docHeader(req, etag, pdoc.Private || len(flashMessages) > 0)
+
+		popular := pdoc.Name != "" && // not a directory
+			pdoc.ProjectRoot != "" && // not a standard package
+			!pdoc.IsCmd &&
+			len(pdoc.Errors) == 0
+		if requestType == humanRequest && popular && !exportView && !fragmentView && !popularLinkReferral(req) {
+			countView(req.Context(), pdoc.ImportPath)
+		}
+
+		tpdoc := newTDoc(pdoc)
+		tpdoc.setExpand(strings.Join(expand, ","), section)
+		tpdoc.expandAll = tpdoc.expandAll || exportView
+
+		data := map[string]interface{}{
 			"flashMessages": flashMessages,
 			"pkgs":          pkgs,
-			"pdoc":          newTDoc(pdoc),
+			"pdoc":          tpdoc,
 			"importerCount": importerCount,
-		})
+			"similar":       similar,
+			"verified":      verified,
+			"canonical":     settings.Canonical,
+			"vulns":         vulns,
+			"promoted":      promoted(pdoc),
+			"sections":      sections,
+			"section":       section,
+			"majors":        majors,
+			"vendorDir":     vendorDir,
+		}
+		if exportView {
+			return serveExport(resp, req, importPath, data)
+		}
+		if fragmentView {
+			return serveFragment(resp, req, importPath, header, status, data)
+		}
+		if cacheKey != "" && status == http.StatusOK && !pdoc.Private && len(flashMessages) == 0 {
+			page := &cachedPage{importPath: importPath, countView: popular}
+			return executeCachedTemplate(resp, req, cacheKey, page, template, header, data)
+		}
+		return streamTemplate(resp, template, status, header, data)
 	case isView(req, "imports"):
 		if pdoc.Name == "" {
 			break
@@ -326,34 +688,59 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 			"pkgs":          pkgs,
 			"pdoc":          newTDoc(pdoc),
 		})
-	case isView(req, "tools"):
-		proto := "http"
-		if req.Host == "godoc.org" {
-			proto = "https"
+	case isView(req, "coverage"):
+		if pdoc.Name == "" {
+			break
 		}
-		return executeTemplate(resp, "tools.html", http.StatusOK, nil, map[string]interface{}{
+		return executeTemplate(resp, "coverage.html", http.StatusOK, nil, map[string]interface{}{
 			"flashMessages": flashMessages,
-			"uri":           fmt.Sprintf("%s://%s/%s", proto, req.Host, importPath),
 			"pdoc":          newTDoc(pdoc),
+			"coverage":      pdoc.Coverage(),
 		})
-	case isView(req, "importers"):
-		if pdoc.Name == "" {
+	case isView(req, "overview"):
+		if pdoc.ProjectRoot == "" || importPath != pdoc.ProjectRoot {
 			break
 		}
-		pkgs, err = db.Importers(importPath)
+		return serveOverview(resp, req, pdoc, flashMessages)
+	case isView(req, "tools"):
+		ownerKey, ownerKeyHash, err := newOwnerKey()
+		if err != nil {
+			return err
+		}
+		versions, err := db.Versions(importPath)
 		if err != nil {
 			return err
 		}
-		template := "importers.html"
-		if requestType == robotRequest {
-			// Hide back links from robots.
-			template = "importers_robot.html"
+		monthViews, err := db.RecentViews(importPath, 30)
+		if err != nil {
+			return err
 		}
-		return executeTemplate(resp, template, http.StatusOK, nil, map[string]interface{}{
+		settings, err := db.ProjectSettings(pdoc.ProjectRoot)
+		if err != nil {
+			return err
+		}
+		return executeTemplate(resp, "tools.html", http.StatusOK, nil, map[string]interface{}{
 			"flashMessages": flashMessages,
-			"pkgs":          pkgs,
+			"uri":           pageURI(req, importPath),
 			"pdoc":          newTDoc(pdoc),
+			"verified":      isVerified(pdoc.ProjectRoot),
+			"ownerSession":  pdoc.ProjectRoot != "" && ownerSession(req) == pdoc.ProjectRoot,
+			"githubLogin":   githubLoginEnabled(pdoc.ProjectRoot),
+			"settings":      settings,
+			"ownerFile":     gosrc.OwnerFile,
+			"ownerMeta":     gosrc.OwnerMeta,
+			"ownerKey":      ownerKey,
+			"ownerKeyHash":  ownerKeyHash,
+			"versions":      versions,
+			"monthViews":    monthViews,
+			"pdfExport":     *pdfCommand != "",
+			"docsets":       *docsetDir != "",
 		})
+	case isView(req, "importers"):
+		if pdoc.Name == "" {
+			break
+		}
+		return serveImporters(resp, req, pdoc, requestType, flashMessages)
 	case isView(req, "import-graph"):
 		if pdoc.Name == "" {
 			break
@@ -379,6 +766,17 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 			"pdoc":          newTDoc(pdoc),
 			"hide":          hide,
 		})
+	case isView(req, "embed"):
+		if pdoc.Name == "" {
+			break
+		}
+		return executeTemplate(resp, "embed.html", http.StatusOK, nil, map[string]interface{}{
+			"uri":     pageURI(req, pdoc.ImportPath),
+			"pdoc":    newTDoc(pdoc),
+			"symbols": embedSymbols(pdoc),
+		})
+	case isView(req, "run"):
+		return servePlayRun(resp, req, pdoc)
 	case isView(req, "play"):
 		u, err := playURL(pdoc, req.Form.Get("play"))
 		if err != nil {
@@ -409,15 +807,110 @@ func servePackage(resp http.ResponseWriter, req *http.Request) error {
 	return &httpError{status: http.StatusNotFound}
 }
 
-func serveRefresh(resp http.ResponseWriter, req *http.Request) error {
-	importPath := req.Form.Get("path")
+// servePackageVersion serves the documentation saved for a version of a
+// package. Views other than the documentation are redirected to the latest
+// version of the package.
+func servePackageVersion(resp http.ResponseWriter, req *http.Request, importPath, version string) error {
+	if len(req.Form) != 0 {
+		http.Redirect(resp, req, "/"+importPath+"?"+req.URL.RawQuery, http.StatusFound)
+		return nil
+	}
+
+	pdoc, err := db.GetVersion(importPath, version)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil && gosrc.IsGoRepoPath(importPath) {
+		// Build the documentation of the standard library for the versions
+		// in the -goroots trees on request.
+		var dir *gosrc.Directory
+		dir, err = gosrc.GetGoRoot(importPath, version)
+		if err == nil {
+			pdoc, err = doc.Build(dir)
+		}
+		if err != nil {
+			return err
+		}
+	} else if pdoc == nil {
+		pdoc, err = buildModuleVersion(req.Context(), importPath, version)
+		if err != nil {
+			return err
+		}
+	}
+	if pdoc == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+	return executeVersionTemplate(resp, req, pdoc, version, "")
+}
+
+// servePackageAt serves the documentation for a package as it was at the last
+// crawl on or before the given day.
+func servePackageAt(resp http.ResponseWriter, req *http.Request, importPath, day string) error {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return &httpError{status: http.StatusBadRequest, err: err}
+	}
+	version, err := db.VersionAt(importPath, t.Add(24*time.Hour-time.Second))
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		return &httpError{status: http.StatusNotFound}
+	}
+	pdoc, err := db.GetVersion(importPath, version)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+	return executeVersionTemplate(resp, req, pdoc, version, day)
+}
+
+func executeVersionTemplate(resp http.ResponseWriter, req *http.Request, pdoc *doc.Package, version, day string) error {
+	if !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	template := "dir"
+	switch {
+	case pdoc.IsCmd:
+		template = "cmd"
+	case pdoc.Name != "":
+		template = "pkg"
+	}
+	template += templateExt(req)
+
+	flashMessages := getFlashMessages(resp, req)
+	verified := isVerified(pdoc.ProjectRoot)
+	etag := httpEtag(template, version+"@"+day, pdoc, nil, nil, 0, verified, "", nil, nil, flashMessages)
+	header, status := docHeader(req, etag, pdoc.Private || len(flashMessages) > 0)
+
+	return executeTemplate(resp, template, status, header, map[string]interface{}{
+		"flashMessages": flashMessages,
+		"pkgs":          []database.Package(nil),
+		"pdoc":          newTDoc(pdoc),
+		"importerCount": 0,
+		"verified":      verified,
+		"promoted":      promoted(pdoc),
+		"version":       version,
+		"at":            day,
+	})
+}
+
+// refreshDoc crawls the package with the given import path. If the package
+// moved, then refreshDoc returns a not found error with the new location.
+func refreshDoc(ctx context.Context, importPath string) error {
+	if *readOnly {
+		return errReadOnly
+	}
 	_, pkgs, _, err := db.Get(importPath)
 	if err != nil {
 		return err
 	}
 	c := make(chan error, 1)
 	go func() {
-		_, err := crawlDoc("rfrsh", importPath, nil, len(pkgs) > 0, time.Time{})
+		_, err := docCrawler.Crawl(ctx, "rfrsh", importPath, nil, len(pkgs) > 0, time.Time{})
 		c <- err
 	}()
 	select {
@@ -425,6 +918,15 @@ func serveRefresh(resp http.ResponseWriter, req *http.Request) error {
 	case <-time.After(*getTimeout):
 		err = errUpdateTimeout
 	}
+	return err
+}
+
+func serveRefresh(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRate(req.Context(), refreshLimiter); err != nil {
+		return err
+	}
+	importPath := req.Form.Get("path")
+	err := refreshDoc(req.Context(), importPath)
 	if e, ok := err.(gosrc.NotFoundError); ok && e.Redirect != "" {
 		setFlashMessages(resp, []flashMessage{{ID: "redir", Args: []string{importPath}}})
 		importPath = e.Redirect
@@ -458,6 +960,9 @@ func serveGoSubrepoIndex(resp http.ResponseWriter, req *http.Request) error {
 
 func serveIndex(resp http.ResponseWriter, req *http.Request) error {
 	pkgs, err := db.Index()
+	if err == nil {
+		pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+	}
 	if err != nil {
 		return err
 	}
@@ -497,6 +1002,7 @@ func popular() ([]database.Package, error) {
 	if err != nil {
 		return nil, err
 	}
+	pkgs = filterAllowed(pkgs)
 
 	rank := make([]int, len(pkgs))
 	for i := range pkgs {
@@ -532,6 +1038,9 @@ func popular() ([]database.Package, error) {
 	return pkgs, nil
 }
 
+// searchPageSize is the number of packages on a page of search results.
+const searchPageSize = 100
+
 func serveHome(resp http.ResponseWriter, req *http.Request) error {
 	if req.URL.Path != "/" {
 		return servePackage(resp, req)
@@ -540,6 +1049,9 @@ func serveHome(resp http.ResponseWriter, req *http.Request) error {
 	q := strings.TrimSpace(req.Form.Get("q"))
 	if q == "" {
 		pkgs, err := popular()
+		if err == nil {
+			pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+		}
 		if err != nil {
 			return err
 		}
@@ -548,12 +1060,16 @@ func serveHome(resp http.ResponseWriter, req *http.Request) error {
 			map[string]interface{}{"Popular": pkgs})
 	}
 
+	if err := checkRate(req.Context(), searchLimiter); err != nil {
+		return err
+	}
+
 	if path, ok := isBrowseURL(q); ok {
 		q = path
 	}
 
 	if gosrc.IsValidRemotePath(q) || (strings.Contains(q, "/") && gosrc.IsGoRepoPath(q)) {
-		pdoc, pkgs, err := getDoc(q, queryRequest)
+		pdoc, pkgs, err := getDoc(req.Context(), q, queryRequest)
 		if e, ok := err.(gosrc.NotFoundError); ok && e.Redirect != "" {
 			http.Redirect(resp, req, "/"+e.Redirect, http.StatusFound)
 			return nil
@@ -564,13 +1080,40 @@ func serveHome(resp http.ResponseWriter, req *http.Request) error {
 		}
 	}
 
-	pkgs, err := db.Query(q)
+	var pkgs []database.Package
+	var next string
+	var err error
+	cursor := req.Form.Get("cursor")
+	filter := func(pkgs []database.Package) ([]database.Package, error) {
+		return filterPrivate(req, filterAllowed(pkgs))
+	}
+	err = traceDB(req.Context(), "db.Query", func() (err error) {
+		if templateExt(req) == ".html" {
+			pkgs, next, err = db.QueryPage(q, cursor, searchPageSize, filter)
+		} else {
+			pkgs, err = db.Query(q)
+			if err == nil {
+				pkgs, err = filter(pkgs)
+			}
+		}
+		return err
+	})
+	if err == database.ErrBadCursor {
+		return &httpError{status: http.StatusBadRequest}
+	}
 	if err != nil {
 		return err
 	}
 
+	// Show the major versions of a package as one result with a version
+	// picker on the HTML page.
+	var results interface{} = pkgs
+	if templateExt(req) == ".html" {
+		results = collapseMajors(pkgs)
+	}
+
 	return executeTemplate(resp, "results"+templateExt(req), http.StatusOK, nil,
-		map[string]interface{}{"q": q, "pkgs": pkgs})
+		map[string]interface{}{"q": q, "pkgs": results, "cursor": cursor, "next": next})
 }
 
 func serveAbout(resp http.ResponseWriter, req *http.Request) error {
@@ -584,40 +1127,65 @@ func serveBot(resp http.ResponseWriter, req *http.Request) error {
 
 func logError(req *http.Request, err error, rv interface{}) {
 	if err != nil {
-		var buf bytes.Buffer
-		fmt.Fprintf(&buf, "Error serving %s: %v\n", req.URL, err)
+		args := []interface{}{"url", req.URL.String(), "err", err}
 		if rv != nil {
-			fmt.Fprintln(&buf, rv)
-			buf.Write(debug.Stack())
+			args = append(args, "panic", fmt.Sprint(rv), "stack", string(debug.Stack()))
 		}
-		log.Print(buf.String())
+		logger(req.Context()).Error("error serving request", args...)
 	}
 }
 
 func serveAPISearch(resp http.ResponseWriter, req *http.Request) error {
-	q := strings.TrimSpace(req.Form.Get("q"))
+	pkgs, err := apiSearch(req, strings.TrimSpace(req.Form.Get("q")))
+	if err != nil {
+		return err
+	}
 
-	var pkgs []database.Package
+	var data = struct {
+		Results []database.Package `json:"results"`
+	}{
+		pkgs,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
+
+// apiSearch returns the results of the search API for the query q: the
+// package with the import path q or the packages matching q.
+func apiSearch(req *http.Request, q string) ([]database.Package, error) {
+	if err := checkRate(req.Context(), searchLimiter); err != nil {
+		return nil, err
+	}
 
 	if gosrc.IsValidRemotePath(q) || (strings.Contains(q, "/") && gosrc.IsGoRepoPath(q)) {
-		pdoc, _, err := getDoc(q, apiRequest)
+		pdoc, _, err := getDoc(req.Context(), q, apiRequest)
 		if e, ok := err.(gosrc.NotFoundError); ok && e.Redirect != "" {
-			pdoc, _, err = getDoc(e.Redirect, robotRequest)
+			pdoc, _, err = getDoc(req.Context(), e.Redirect, robotRequest)
 		}
-		if err == nil && pdoc != nil {
-			pkgs = []database.Package{{Path: pdoc.ImportPath, Synopsis: pdoc.Synopsis}}
+		if err == nil && pdoc != nil && canView(req, pdoc) {
+			return []database.Package{{Path: pdoc.ImportPath, Synopsis: pdoc.Synopsis}}, nil
 		}
 	}
 
-	if pkgs == nil {
-		var err error
-		pkgs, err = db.Query(q)
-		if err != nil {
-			return err
-		}
+	pkgs, err := db.Query(q)
+	if err == nil {
+		pkgs, err = filterPrivate(req, filterAllowed(pkgs))
 	}
+	return pkgs, err
+}
 
-	var data = struct {
+func serveAPIPackages(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkAPIKey(resp, req); err != nil {
+		return err
+	}
+	pkgs, err := db.AllPackages()
+	if err == nil {
+		pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+	}
+	if err != nil {
+		return err
+	}
+	data := struct {
 		Results []database.Package `json:"results"`
 	}{
 		pkgs,
@@ -626,8 +1194,24 @@ func serveAPISearch(resp http.ResponseWriter, req *http.Request) error {
 	return json.NewEncoder(resp).Encode(&data)
 }
 
-func serveAPIPackages(resp http.ResponseWriter, req *http.Request) error {
-	pkgs, err := db.AllPackages()
+// apiImporters returns the results of the importers API for the package
+// importPath.
+func apiImporters(req *http.Request, importPath string) ([]database.Package, error) {
+	if !isAllowed(importPath) {
+		return nil, &httpError{status: http.StatusNotFound}
+	}
+	pkgs, err := db.Importers(importPath)
+	if err == nil {
+		pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+	}
+	return pkgs, err
+}
+
+func serveAPIImporters(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkAPIKey(resp, req); err != nil {
+		return err
+	}
+	pkgs, err := apiImporters(req, strings.TrimPrefix(req.URL.Path, "/importers/"))
 	if err != nil {
 		return err
 	}
@@ -640,9 +1224,59 @@ func serveAPIPackages(resp http.ResponseWriter, req *http.Request) error {
 	return json.NewEncoder(resp).Encode(&data)
 }
 
-func serveAPIImporters(resp http.ResponseWriter, req *http.Request) error {
-	importPath := strings.TrimPrefix(req.URL.Path, "/importers/")
-	pkgs, err := db.Importers(importPath)
+// maxStatsDays is the maximum number of days returned by the stats API.
+const maxStatsDays = 365
+
+func serveAPIStats(resp http.ResponseWriter, req *http.Request) error {
+	importPath := strings.TrimPrefix(req.URL.Path, "/stats/")
+	days := sparklineDays
+	if s := req.Form.Get("days"); s != "" {
+		var err error
+		days, err = strconv.Atoi(s)
+		if err != nil || days < 1 || days > maxStatsDays {
+			return &httpError{status: http.StatusBadRequest}
+		}
+	}
+	pdoc, _, err := getDoc(req.Context(), importPath, cachedRequest)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	stats, err := db.PackageStats(importPath, days)
+	if err != nil {
+		return err
+	}
+	data := struct {
+		Results []database.DailyStats `json:"results"`
+	}{
+		stats,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
+
+// maxDependentsDepth is the maximum depth of the dependents API query.
+const maxDependentsDepth = 5
+
+func serveAPIDependents(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkAPIKey(resp, req); err != nil {
+		return err
+	}
+	importPath := strings.TrimPrefix(req.URL.Path, "/dependents/")
+	depth := 1
+	if s := req.Form.Get("depth"); s != "" {
+		var err error
+		depth, err = strconv.Atoi(s)
+		if err != nil || depth < 1 || depth > maxDependentsDepth {
+			return &httpError{status: http.StatusBadRequest}
+		}
+	}
+	pkgs, err := db.ReverseDependencyClosure(importPath, depth)
+	if err == nil {
+		pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+	}
 	if err != nil {
 		return err
 	}
@@ -657,11 +1291,11 @@ func serveAPIImporters(resp http.ResponseWriter, req *http.Request) error {
 
 func serveAPIImports(resp http.ResponseWriter, req *http.Request) error {
 	importPath := strings.TrimPrefix(req.URL.Path, "/imports/")
-	pdoc, _, err := getDoc(importPath, robotRequest)
+	pdoc, _, err := getDoc(req.Context(), importPath, robotRequest)
 	if err != nil {
 		return err
 	}
-	if pdoc == nil || pdoc.Name == "" {
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
 		return &httpError{status: http.StatusNotFound}
 	}
 	imports, err := db.Packages(pdoc.Imports)
@@ -683,17 +1317,158 @@ func serveAPIImports(resp http.ResponseWriter, req *http.Request) error {
 	return json.NewEncoder(resp).Encode(&data)
 }
 
+func serveAPIDeps(resp http.ResponseWriter, req *http.Request) error {
+	importPath := strings.TrimPrefix(req.URL.Path, "/deps/")
+	pdoc, _, err := getDoc(req.Context(), importPath, robotRequest)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Module == nil || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	r, err := db.DependencyReport(pdoc.Module.Root)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(r)
+}
+
+type apiDecl struct {
+	Name string `json:"name,omitempty"`
+	Decl string `json:"decl"`
+	Doc  string `json:"doc,omitempty"`
+}
+
+type apiType struct {
+	apiDecl
+	Consts  []apiDecl `json:"consts,omitempty"`
+	Vars    []apiDecl `json:"vars,omitempty"`
+	Funcs   []apiDecl `json:"funcs,omitempty"`
+	Methods []apiDecl `json:"methods,omitempty"`
+}
+
+func apiValues(values []*doc.Value) []apiDecl {
+	var result []apiDecl
+	for _, v := range values {
+		result = append(result, apiDecl{Decl: v.Decl.Text, Doc: v.Doc})
+	}
+	return result
+}
+
+func apiFuncs(funcs []*doc.Func) []apiDecl {
+	var result []apiDecl
+	for _, f := range funcs {
+		result = append(result, apiDecl{Name: f.Name, Decl: f.Decl.Text, Doc: f.Doc})
+	}
+	return result
+}
+
+func serveAPIDoc(resp http.ResponseWriter, req *http.Request) error {
+	importPath := strings.TrimPrefix(req.URL.Path, "/doc/")
+	pdoc, _, err := getDoc(req.Context(), importPath, apiRequest)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	var types []apiType
+	for _, t := range pdoc.Types {
+		types = append(types, apiType{
+			apiDecl: apiDecl{Name: t.Name, Decl: t.Decl.Text, Doc: t.Doc},
+			Consts:  apiValues(t.Consts),
+			Vars:    apiValues(t.Vars),
+			Funcs:   apiFuncs(t.Funcs),
+			Methods: apiFuncs(t.Methods),
+		})
+	}
+	data := struct {
+		ImportPath  string    `json:"importPath"`
+		Name        string    `json:"name"`
+		Synopsis    string    `json:"synopsis"`
+		Doc         string    `json:"doc"`
+		IsCmd       bool      `json:"isCmd"`
+		ProjectRoot string    `json:"projectRoot"`
+		Version     string    `json:"version,omitempty"`
+		Updated     time.Time `json:"updated"`
+		Imports     []string  `json:"imports"`
+		Consts      []apiDecl `json:"consts,omitempty"`
+		Vars        []apiDecl `json:"vars,omitempty"`
+		Funcs       []apiDecl `json:"funcs,omitempty"`
+		Types       []apiType `json:"types,omitempty"`
+	}{
+		ImportPath:  pdoc.ImportPath,
+		Name:        pdoc.Name,
+		Synopsis:    pdoc.Synopsis,
+		Doc:         pdoc.Doc,
+		IsCmd:       pdoc.IsCmd,
+		ProjectRoot: pdoc.ProjectRoot,
+		Version:     pdoc.Version,
+		Updated:     pdoc.Updated,
+		Imports:     pdoc.Imports,
+		Consts:      apiValues(pdoc.Consts),
+		Vars:        apiValues(pdoc.Vars),
+		Funcs:       apiFuncs(pdoc.Funcs),
+		Types:       types,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
+
+func serveAPIRefresh(resp http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+	if !checkAPIToken(req) {
+		return &httpError{status: http.StatusUnauthorized}
+	}
+	if err := checkRate(req.Context(), refreshLimiter); err != nil {
+		return err
+	}
+	importPath := req.Form.Get("path")
+	if !isAllowed(importPath) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	err := refreshDoc(req.Context(), importPath)
+	if e, ok := err.(gosrc.NotFoundError); ok && e.Redirect != "" {
+		importPath = e.Redirect
+		err = nil
+	}
+	switch {
+	case err == errUpdateTimeout:
+		return &httpError{status: http.StatusGatewayTimeout, err: err}
+	case err == errReadOnly:
+		return &httpError{status: http.StatusServiceUnavailable, err: err}
+	case err != nil:
+		return err
+	}
+	data := struct {
+		ImportPath string `json:"importPath"`
+	}{
+		importPath,
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
+
 func serveAPIHome(resp http.ResponseWriter, req *http.Request) error {
 	return &httpError{status: http.StatusNotFound}
 }
 
 func runHandler(resp http.ResponseWriter, req *http.Request,
 	fn func(resp http.ResponseWriter, req *http.Request) error, errfn httputil.Error) {
+	rb := httputil.NewResponseBuffer(resp)
 	defer func() {
 		if rv := recover(); rv != nil {
 			err := errors.New("handler panic")
 			logError(req, err, rv)
-			errfn(resp, req, http.StatusInternalServerError, err)
+			if !rb.Streaming() {
+				errfn(resp, req, http.StatusInternalServerError, err)
+			}
 		}
 	}()
 
@@ -701,19 +1476,49 @@ func runHandler(resp http.ResponseWriter, req *http.Request,
 		req.RemoteAddr = s
 	}
 
+	id := inboundRequestID(req)
+	resp.Header().Set(requestIDHeader, id)
+	req = req.WithContext(withClient(withRequestID(req.Context(), id), req))
+	ctx, sp := startServerSpan(req)
+	req = req.WithContext(ctx)
+	var err error
+	defer func() { sp.finish(err) }()
+
 	req.Body = http.MaxBytesReader(resp, req.Body, 2048)
 	req.ParseForm()
-	var rb httputil.ResponseBuffer
-	err := fn(&rb, req)
+	setSecurityHeaders(resp, req)
+	if translated() {
+		lang := negotiateLanguage(req)
+		req = req.WithContext(withLanguage(req.Context(), lang))
+		for _, h := range []http.Header{resp.Header(), rb.Header()} {
+			h.Set("Content-Language", lang)
+			h.Add("Vary", "Accept-Language, Cookie")
+		}
+	}
+	err = checkCSRF(resp, req)
+	if err == nil {
+		err = checkRobotRate(req)
+	}
 	if err == nil {
+		err = fn(rb, req)
+	}
+	if rb.Streaming() {
+		// The response is sent. An error can only end the response.
+		if err != nil {
+			logError(req, err, nil)
+		}
+	} else if err == nil {
 		rb.WriteTo(resp)
 	} else if e, ok := err.(*httpError); ok {
 		if e.status >= 500 {
 			logError(req, err, nil)
 		}
+		for k, v := range e.header {
+			resp.Header()[k] = v
+		}
 		errfn(resp, req, e.status, e.err)
 	} else if gosrc.IsNotFound(err) {
-		errfn(resp, req, http.StatusNotFound, nil)
+		errfn(resp, req, http.StatusNotFound, err)
 	} else {
 		logError(req, err, nil)
 		errfn(resp, req, http.StatusInternalServerError, err)
@@ -736,6 +1541,9 @@ func errorText(err error) string {
 	if err == errUpdateTimeout {
 		return "Timeout getting package files from the version control system."
 	}
+	if err == errReadOnly {
+		return "The site is read-only. Try again later."
+	}
 	if e, ok := err.(*gosrc.RemoteError); ok {
 		return "Error getting package files from " + e.Host + "."
 	}
@@ -745,9 +1553,34 @@ func errorText(err error) string {
 func handleError(resp http.ResponseWriter, req *http.Request, status int, err error) {
 	switch status {
 	case http.StatusNotFound:
-		executeTemplate(resp, "notfound"+templateExt(req), status, nil, map[string]interface{}{
+		data := map[string]interface{}{
 			"flashMessages": getFlashMessages(resp, req),
+		}
+		if p := strings.TrimPrefix(req.URL.Path, "/"); gosrc.IsNotFound(err) && gosrc.IsValidRemotePath(p) && req.URL.RawQuery == "" {
+			data["recheck"] = p
+		}
+		executeTemplate(resp, "notfound"+templateExt(req), status, nil, data)
+	case http.StatusGone, http.StatusUnavailableForLegalReasons:
+		data := map[string]interface{}{"legal": status == http.StatusUnavailableForLegalReasons}
+		if e, ok := err.(blockedError); ok {
+			data["entry"] = e.entry
+		}
+		executeTemplate(resp, "blocked"+templateExt(req), status, nil, data)
+	case http.StatusServiceUnavailable:
+		if err != errFetching {
+			resp.Header().Set("Content-Type", textMIMEType)
+			resp.WriteHeader(status)
+			io.WriteString(resp, errorText(err))
+			return
+		}
+		executeTemplate(resp, "fetching"+templateExt(req), status, nil, map[string]interface{}{
+			"path":  strings.TrimPrefix(req.URL.Path, "/"),
+			"retry": fetchRetrySeconds(),
 		})
+	case http.StatusTooManyRequests:
+		resp.Header().Set("Content-Type", textMIMEType)
+		resp.WriteHeader(status)
+		io.WriteString(resp, "Too many requests. Try again later.")
 	default:
 		resp.Header().Set("Content-Type", textMIMEType)
 		resp.WriteHeader(http.StatusInternalServerError)
@@ -818,69 +1651,171 @@ var (
 var (
 	robot             = flag.Float64("robot", 100, "Request counter threshold for robots.")
 	assetsDir         = flag.String("assets", filepath.Join(defaultBase("github.com/golang/gddo/gddo-server"), "assets"), "Base directory for templates and static files.")
+	overrideDir       = flag.String("template_override_dir", "", "Directory with templates and static files that replace the files with the same path in the -assets directory.")
 	getTimeout        = flag.Duration("get_timeout", 8*time.Second, "Time to wait for package update from the VCS.")
-	firstGetTimeout   = flag.Duration("first_get_timeout", 5*time.Second, "Time to wait for first fetch of package from the VCS.")
+	firstGetTimeout   = flag.Duration("first_get_timeout", 3*time.Second, "Time to wait for first fetch of package from the VCS. After the timeout, the request is asked to retry and the fetch continues in the background.")
 	maxAge            = flag.Duration("max_age", 24*time.Hour, "Update package documents older than this age.")
 	httpAddr          = flag.String("http", ":8080", "Listen for HTTP connections on this address.")
 	sidebarEnabled    = flag.Bool("sidebar", false, "Enable package page sidebar.")
 	defaultGOOS       = flag.String("default_goos", "", "Default GOOS to use when building package documents.")
+	buildTags         = flag.String("build_tags", "", "Comma separated list of additional build tags to satisfy when building package documents.")
+	maxDeclSize       = flag.Int("max_decl_size", 32<<10, "Maximum size in bytes of the code of a declaration in package documents. Larger declarations are collapsed. Zero disables the limit.")
+	maxDocSize        = flag.Int("max_doc_size", 1<<20, "Maximum size in bytes of the code and doc comments of the declarations of a package document. The declarations after the limit are omitted. Zero disables the limit.")
+	compress          = flag.Bool("compress", true, "Compress responses to clients that accept gzip or brotli content encoding.")
+	docMaxAge         = flag.Duration("doc_max_age", 0, "Clients may cache documentation pages for this duration. Zero requires clients to revalidate cached pages.")
+	canonicalRedirect = flag.Bool("canonical_redirect", true, "Redirect with status 301 from the pages of packages fetched from a non-canonical import path to the pages at the canonical import path.")
 	gitHubCredentials = ""
 	userAgent         = ""
 )
 
-func main() {
-	flag.Parse()
-	doc.SetDefaultGOOS(*defaultGOOS)
-	log.Printf("Starting server, os.Args=%s", strings.Join(os.Args, " "))
-
-	if err := parseHTMLTemplates([][]string{
+// htmlTemplateSets and textTemplateSets are the template sets parsed by
+// parseTemplates. The first file in a set names the template.
+var (
+	htmlTemplateSets = [][]string{
 		{"about.html", "common.html", "layout.html"},
+		{"account.html", "common.html", "layout.html"},
 		{"bot.html", "common.html", "layout.html"},
 		{"cmd.html", "common.html", "layout.html"},
 		{"dir.html", "common.html", "layout.html"},
+		{"dumps.html", "common.html", "layout.html"},
 		{"home.html", "common.html", "layout.html"},
 		{"importers.html", "common.html", "layout.html"},
 		{"importers_robot.html", "common.html", "layout.html"},
 		{"imports.html", "common.html", "layout.html"},
+		{"coverage.html", "common.html", "layout.html"},
 		{"index.html", "common.html", "layout.html"},
 		{"notfound.html", "common.html", "layout.html"},
+		{"fetching.html", "common.html", "layout.html"},
+		{"blocked.html", "common.html", "layout.html"},
 		{"pkg.html", "common.html", "layout.html"},
+		{"export.html", "pkg.html", "common.html"},
+		{"fragment.html", "pkg.html", "common.html"},
+		{"source.html", "common.html", "layout.html"},
+		{"changes.html", "common.html", "layout.html"},
+		{"compare.html", "common.html", "layout.html"},
 		{"results.html", "common.html", "layout.html"},
 		{"tools.html", "common.html", "layout.html"},
+		{"overview.html", "common.html", "layout.html"},
+		{"talk.html", "common.html", "layout.html"},
+		{"top.html", "common.html", "layout.html"},
+		{"notes.html", "common.html", "layout.html"},
+		{"reports.html", "common.html", "layout.html"},
 		{"std.html", "common.html", "layout.html"},
 		{"subrepo.html", "common.html", "layout.html"},
 		{"graph.html", "common.html"},
-	}); err != nil {
-		log.Fatal(err)
+		{"embed.html", "common.html"},
 	}
 
-	if err := parseTextTemplates([][]string{
+	textTemplateSets = [][]string{
 		{"cmd.txt", "common.txt"},
 		{"dir.txt", "common.txt"},
 		{"home.txt", "common.txt"},
 		{"notfound.txt", "common.txt"},
+		{"fetching.txt", "common.txt"},
+		{"blocked.txt", "common.txt"},
+		{"opensearch.xml"},
 		{"pkg.txt", "common.txt"},
 		{"results.txt", "common.txt"},
-	}); err != nil {
+	}
+)
+
+// newStaticServer returns the server of the files in the -assets directory.
+func newStaticServer() *httputil.StaticServer {
+	return &httputil.StaticServer{
+		Dir:         *assetsDir,
+		OverrideDir: *overrideDir,
+		MaxAge:      time.Hour,
+		Compress:    *compress,
+		MIMETypes: map[string]string{
+			".css": "text/css; charset=utf-8",
+			".js":  "text/javascript; charset=utf-8",
+		},
+	}
+}
+
+func main() {
+	flag.Parse()
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if err := configure(); err != nil {
+		log.Fatal(err)
+	}
+	setupTracing(*otlpEndpoint)
+	if *replicaID == "" {
+		*replicaID = defaultReplicaID()
+	}
+	gosrc.SetResponseCacheSize(*respCacheSize)
+	addGitLabHosts(*gitLabHosts)
+	if err := addGiteaHosts(*giteaHosts); err != nil {
+		log.Fatal(err)
+	}
+	if err := addBitbucketServerHosts(*bitbucketServerHosts); err != nil {
+		log.Fatal(err)
+	}
+	if err := addAzureDevOpsHosts(*azureDevOpsHosts); err != nil {
+		log.Fatal(err)
+	}
+	if err := addSourceHosts(*sourceHosts); err != nil {
 		log.Fatal(err)
 	}
+	if *scannerSpec != "" {
+		s, err := newScanner(*scannerSpec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gosrc.SetScanner(s)
+	}
+	if args := strings.Fields(*exampleCheckCommand); len(args) > 0 {
+		doc.SetExampleChecker(&execExampleChecker{args: args})
+	}
+	if *fetchWorker {
+		// The worker has no database: the resolved go-import meta tags
+		// are not cached and the vendored packages are not filtered.
+		if err := crawler.ServeFetchWorker(os.Stdin, os.Stdout, httpClient); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	slog.Info("starting server", "args", strings.Join(os.Args, " "))
+
+	if err := parseTemplates(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *localDir != "" {
+		log.Fatal(runLocal(*localDir))
+	}
 
 	var err error
 	db, err = database.New()
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 	}
+	if !*readOnly {
+		if err := db.Migrate(*replicaID, *migrateTimeout); err != nil {
+			log.Fatalf("Error migrating database: %v", err)
+		}
+	}
+	docCrawler.DB = db
+	if err := refreshAnnouncements(); err != nil {
+		log.Printf("Error reading announcements: %v", err)
+	}
+	if *metaCacheTTL > 0 {
+		gosrc.SetMetaCache(db, *metaCacheTTL)
+	}
+	gosrc.SetVendorFilter(db.VendorIndexed)
+	if !*readOnly {
+		if err := syncConfigWatches(); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	go runBackgroundTasks()
+	go runUpdateSubscriber()
+	go reloadOnSIGHUP()
 
-	staticServer := httputil.StaticServer{
-		Dir:    *assetsDir,
-		MaxAge: time.Hour,
-		MIMETypes: map[string]string{
-			".css": "text/css; charset=utf-8",
-			".js":  "text/javascript; charset=utf-8",
-		},
-	}
+	staticServer := newStaticServer()
 	statusImageHandlerPNG = staticServer.FileHandler("status.png")
 	statusImageHandlerSVG = staticServer.FileHandler("status.svg")
 
@@ -892,7 +1827,14 @@ func main() {
 	apiMux.Handle("/search", apiHandler(serveAPISearch))
 	apiMux.Handle("/packages", apiHandler(serveAPIPackages))
 	apiMux.Handle("/importers/", apiHandler(serveAPIImporters))
+	apiMux.Handle("/dependents/", apiHandler(serveAPIDependents))
+	apiMux.Handle("/stats/", apiHandler(serveAPIStats))
 	apiMux.Handle("/imports/", apiHandler(serveAPIImports))
+	apiMux.Handle("/deps/", apiHandler(serveAPIDeps))
+	apiMux.Handle("/doc/", apiHandler(serveAPIDoc))
+	apiMux.Handle("/refresh", apiHandler(serveAPIRefresh))
+	apiMux.Handle("/api/v1/", corsHandler(serveAPIV1))
+	apiMux.Handle("/graphql", corsHandler(serveGraphQL))
 	apiMux.Handle("/", apiHandler(serveAPIHome))
 
 	mux := http.NewServeMux()
@@ -904,18 +1846,55 @@ func main() {
 		mux.Handle("/-/sidebar.css", staticServer.FilesHandler("sidebar.css"))
 	}
 
+	mux.Handle("/src/", handler(serveSource))
+	mux.Handle("/changes/", handler(serveChanges))
+	mux.Handle("/compare", handler(serveCompare))
 	mux.Handle("/-/about", handler(serveAbout))
 	mux.Handle("/-/bot", handler(serveBot))
 	mux.Handle("/-/go", handler(serveGoIndex))
 	mux.Handle("/-/subrepo", handler(serveGoSubrepoIndex))
 	mux.Handle("/-/index", handler(serveIndex))
 	mux.Handle("/-/refresh", handler(serveRefresh))
+	mux.Handle("/-/owner", handler(serveOwner))
+	mux.Handle("/-/owner/github", handler(serveGitHubLogin))
+	mux.Handle("/-/owner/github/callback", handler(serveGitHubCallback))
+	mux.Handle("/-/auth/login", handler(serveAuthLogin))
+	mux.Handle("/-/auth/callback", handler(serveAuthCallback))
+	mux.Handle("/-/auth/logout", handler(serveAuthLogout))
+	mux.Handle("/-/watch/confirm", handler(serveWatchConfirm))
+	mux.Handle("/-/archive", handler(serveArchive))
+	mux.Handle("/-/oembed", handler(serveOEmbed))
+	mux.Handle("/-/opensearch.xml", handler(serveOpenSearch))
+	mux.Handle("/-/suggest", handler(serveSuggest))
+	mux.Handle("/search/suggest", handler(serveSuggest))
+	mux.Handle("/badge/", handler(serveBadge))
+	mux.Handle(imageProxyPath, handler(serveImageProxy))
+	mux.Handle("/feed/", handler(serveFeed))
+	mux.Handle("/top", handler(serveTop))
+	mux.Handle("/-/notes", handler(serveNotes))
+	mux.Handle("/-/events", handler(serveEvents))
+	mux.Handle("/healthz", handler(serveHealthz))
+	mux.Handle("/readyz", handler(serveReadyz))
+	mux.Handle("/-/lang", handler(serveLanguage))
+	mux.Handle("/-/docset/", handler(serveDocset))
+	mux.Handle("/-/stats", handler(serveReport))
+	mux.Handle("/-/audit", handler(serveAudit))
+	mux.Handle("/-/report", handler(serveReportAbuse))
+	mux.Handle("/-/admin/reports", handler(serveAbuseReports))
+	mux.Handle("/-/admin/blocklist", handler(serveBlocklist))
+	mux.Handle("/-/admin/delete", handler(serveAdminDelete))
+	mux.Handle("/-/admin/crawl", handler(serveAdminCrawl))
+	mux.Handle("/-/admin/tasks", handler(serveAdminTasks))
+	mux.Handle("/-/admin/announcements", handler(serveAdminAnnouncements))
+	mux.Handle("/-/dumps", handler(serveDumps))
+	mux.Handle("/-/dumps/", handler(serveDumpFile))
 	mux.Handle("/a/index", http.RedirectHandler("/-/index", http.StatusMovedPermanently))
 	mux.Handle("/about", http.RedirectHandler("/-/about", http.StatusMovedPermanently))
 	mux.Handle("/favicon.ico", staticServer.FileHandler("favicon.ico"))
 	mux.Handle("/google3d2f3cd4cc2bb44b.html", staticServer.FileHandler("google3d2f3cd4cc2bb44b.html"))
 	mux.Handle("/humans.txt", staticServer.FileHandler("humans.txt"))
-	mux.Handle("/robots.txt", staticServer.FileHandler("robots.txt"))
+	mux.Handle("/robots.txt", handler(serveRobots))
+	mux.Handle("/sitemap.xml", handler(serveSitemap))
 	mux.Handle("/BingSiteAuth.xml", staticServer.FileHandler("BingSiteAuth.xml"))
 	mux.Handle("/C", http.RedirectHandler("http://golang.org/doc/articles/c_go_cgo.html", http.StatusMovedPermanently))
 	mux.Handle("/ajax.googleapis.com/", http.NotFoundHandler())
@@ -923,7 +1902,37 @@ func main() {
 
 	cacheBusters.Handler = mux
 
-	if err := http.ListenAndServe(*httpAddr, rootHandler{{"api.", apiMux}, {"", mux}}); err != nil {
+	var root http.Handler = rootHandler{{"api.", apiMux}, {"", mux}}
+	if *authProvider != "" {
+		root = authHandler{root}
+	}
+	if *compress {
+		root = httputil.CompressHandler(root)
+	}
+	if *devMode {
+		dirs := []string{*assetsDir}
+		if *overrideDir != "" {
+			dirs = append(dirs, *overrideDir)
+		}
+		w, err := newAssetsWatcher(dirs...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		root = devHandler{root, w, func() error {
+			staticServer.Reset()
+			cacheBusters.Reset()
+			pages.reset()
+			return parseTemplates()
+		}}
+	}
+	if *grpcAddr != "" {
+		go func() {
+			if err := serveGRPC(); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+	if err := listenAndServe(root); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -9,6 +9,9 @@ package main
 import (
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
 )
 
 var robotTests = []string{
@@ -31,3 +34,68 @@ func TestRobots(t *testing.T) {
 		}
 	}
 }
+
+var etagMatchTests = []struct {
+	ifNoneMatch string
+	match       bool
+}{
+	{`"abc"`, true},
+	{`W/"abc"`, true},
+	{`"xyz", "abc"`, true},
+	{`*`, true},
+	{`"xyz"`, false},
+	{``, false},
+}
+
+func TestEtagMatch(t *testing.T) {
+	for _, tt := range etagMatchTests {
+		if match := etagMatch(tt.ifNoneMatch, `"abc"`); match != tt.match {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.ifNoneMatch, match, tt.match)
+		}
+	}
+}
+
+func TestDocHeader(t *testing.T) {
+	defer func(d time.Duration) { *docMaxAge = d }(*docMaxAge)
+	req := &http.Request{Header: http.Header{"If-None-Match": {`"abc"`}}}
+
+	header, status := docHeader(req, `"abc"`, false)
+	if status != http.StatusNotModified {
+		t.Errorf("status = %d, want %d", status, http.StatusNotModified)
+	}
+	if cc := header.Get("Cache-Control"); cc != "public, no-cache" {
+		t.Errorf("Cache-Control = %q, want %q", cc, "public, no-cache")
+	}
+
+	*docMaxAge = time.Hour
+	header, status = docHeader(req, `"xyz"`, true)
+	if status != http.StatusOK {
+		t.Errorf("status = %d, want %d", status, http.StatusOK)
+	}
+	if cc := header.Get("Cache-Control"); cc != "private, max-age=3600" {
+		t.Errorf("Cache-Control = %q, want %q", cc, "private, max-age=3600")
+	}
+}
+
+func TestStartCrawl(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	fn := func() (*doc.Package, error) {
+		calls++
+		<-release
+		return &doc.Package{ImportPath: "example.com/a"}, nil
+	}
+	c1 := startCrawl("example.com/a", fn)
+	c2 := startCrawl("example.com/a", fn)
+	if c1 != c2 || crawlInFlight("example.com/a") != c1 {
+		t.Error("concurrent crawls of a package not shared")
+	}
+	close(release)
+	<-c1.done
+	if calls != 1 || c2.pdoc == nil || c2.pdoc.ImportPath != "example.com/a" {
+		t.Errorf("calls = %d, pdoc = %v; want 1 call and the crawled package", calls, c2.pdoc)
+	}
+	if crawlInFlight("example.com/a") != nil {
+		t.Error("crawl in flight after the crawl is done")
+	}
+}
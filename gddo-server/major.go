@@ -0,0 +1,59 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/gosrc"
+)
+
+// searchResult is a package in the search results with the major versions of
+// the package found by the search.
+type searchResult struct {
+	database.Package
+
+	// Majors are the import paths of the major versions, oldest first, or
+	// nil if the search found one major version.
+	Majors []string
+}
+
+// collapseMajors returns the search results with the major versions of a
+// package collapsed into one result at the position of the first major
+// version. The package of the result is the newest major version.
+func collapseMajors(pkgs []database.Package) []searchResult {
+	groups := make(map[string]int)
+	var results []searchResult
+	for _, pkg := range pkgs {
+		base, _ := gosrc.MajorVersion(pkg.Path)
+		i, ok := groups[base]
+		if !ok {
+			groups[base] = len(results)
+			results = append(results, searchResult{Package: pkg})
+			continue
+		}
+		r := &results[i]
+		if r.Majors == nil {
+			r.Majors = []string{r.Path}
+		}
+		r.Majors = append(r.Majors, pkg.Path)
+		database.SortMajors(r.Majors)
+		if r.Majors[len(r.Majors)-1] == pkg.Path {
+			r.Package = pkg
+		}
+	}
+	return results
+}
+
+// majorVersion returns the major version of the import path shown in the
+// version pickers.
+func majorVersion(importPath string) string {
+	_, version := gosrc.MajorVersion(importPath)
+	if version == "" {
+		return "v1"
+	}
+	return version
+}
@@ -0,0 +1,45 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+func TestCollapseMajors(t *testing.T) {
+	pkgs := []database.Package{
+		{Path: "github.com/user/repo/v2", Synopsis: "v2"},
+		{Path: "gopkg.in/yaml.v2", Synopsis: "yaml v2"},
+		{Path: "github.com/user/repo", Synopsis: "v1"},
+		{Path: "github.com/user/other"},
+		{Path: "gopkg.in/yaml.v3", Synopsis: "yaml v3"},
+		{Path: "github.com/user/repo/v10", Synopsis: "v10"},
+	}
+	want := []searchResult{
+		{Package: database.Package{Path: "github.com/user/repo/v10", Synopsis: "v10"}, Majors: []string{"github.com/user/repo", "github.com/user/repo/v2", "github.com/user/repo/v10"}},
+		{Package: database.Package{Path: "gopkg.in/yaml.v3", Synopsis: "yaml v3"}, Majors: []string{"gopkg.in/yaml.v2", "gopkg.in/yaml.v3"}},
+		{Package: database.Package{Path: "github.com/user/other"}},
+	}
+	if got := collapseMajors(pkgs); !reflect.DeepEqual(got, want) {
+		t.Errorf("collapseMajors() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestMajorVersionLabel(t *testing.T) {
+	for path, want := range map[string]string{
+		"github.com/user/repo":    "v1",
+		"github.com/user/repo/v2": "v2",
+		"gopkg.in/yaml.v3":        "v3",
+	} {
+		if got := majorVersion(path); got != want {
+			t.Errorf("majorVersion(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,717 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	htemp "html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+// This file renders README files. Markdown READMEs are rendered with the
+// CommonMark block and inline constructs used in practice, plus GitHub
+// flavored tables and strikethrough. Raw HTML in the source is escaped, so
+// the output contains only the markup generated here. Relative link URLs are
+// resolved against the browse URL of the README and relative image URLs are
+// resolved against the raw URL of the README on the hosting service. Images
+// are loaded through the image proxy when the proxy is enabled.
+
+// rawURLRules rewrite the browse URL of a file to the URL of the raw file.
+var rawURLRules = []struct {
+	pat  *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`^https://github\.com/([^/]+/[^/]+)/blob/(.*)$`), "https://raw.githubusercontent.com/$1/$2"},
+	{regexp.MustCompile(`^(https?://[^/]+/.*?)/-/blob/(.*)$`), "$1/-/raw/$2"},
+	{regexp.MustCompile(`^(https?://[^/]+/[^/]+/[^/]+)/blob/(.*)$`), "$1/raw/$2"},
+	{regexp.MustCompile(`^(https?://[^/]+/[^/]+/[^/]+)/src/(.*)$`), "$1/raw/$2"},
+}
+
+func rawURL(browseURL string) string {
+	for _, r := range rawURLRules {
+		if r.pat.MatchString(browseURL) {
+			return r.pat.ReplaceAllString(browseURL, r.repl)
+		}
+	}
+	return browseURL
+}
+
+type markdownRenderer struct {
+	buf  bytes.Buffer
+	base *url.URL
+}
+
+// resolve returns the absolute URL for a link or image URL in the README or
+// "" if the URL is not safe to use in the page.
+func (r *markdownRenderer) resolve(s string, image bool) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u.String()
+	case "mailto":
+		if !image {
+			return u.String()
+		}
+		return ""
+	case "":
+	default:
+		return ""
+	}
+	if u.Host != "" {
+		return ""
+	}
+	if u.Path == "" && !image {
+		// Fragment or query only.
+		return u.String()
+	}
+	if r.base == nil {
+		return ""
+	}
+	s = r.base.ResolveReference(u).String()
+	if image {
+		s = rawURL(s)
+	}
+	return s
+}
+
+var (
+	atxHeadingPat   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextPat       = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fencePat        = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	thematicPat     = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	listItemPat     = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	blockquotePat   = regexp.MustCompile(`^ {0,3}> ?`)
+	tableDelimPat   = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	indentedCodePat = regexp.MustCompile(`^(?:    |\t)`)
+)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock returns true if the line starts a block that interrupts a
+// paragraph.
+func startsBlock(line string) bool {
+	return atxHeadingPat.MatchString(line) ||
+		fencePat.MatchString(line) ||
+		thematicPat.MatchString(line) ||
+		blockquotePat.MatchString(line) ||
+		listItemPat.MatchString(line) && !isBlank(listItemPat.ReplaceAllString(line, ""))
+}
+
+// renderMarkdown returns the HTML for the Markdown source src. Relative URLs
+// are resolved against browseURL.
+func renderMarkdown(src []byte, browseURL string) htemp.HTML {
+	r := &markdownRenderer{}
+	if browseURL != "" {
+		r.base, _ = url.Parse(browseURL)
+	}
+	s := strings.Replace(string(src), "\r\n", "\n", -1)
+	s = strings.Replace(s, "\t", "    ", -1)
+	r.blocks(strings.Split(s, "\n"))
+	return htemp.HTML(r.buf.String())
+}
+
+func cutIndent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return line[i:]
+}
+
+func (r *markdownRenderer) blocks(lines []string) {
+	for len(lines) > 0 {
+		line := lines[0]
+		switch {
+		case isBlank(line):
+			lines = lines[1:]
+		case atxHeadingPat.MatchString(line):
+			m := atxHeadingPat.FindStringSubmatch(line)
+			r.heading(len(m[1]), m[2])
+			lines = lines[1:]
+		case fencePat.MatchString(line):
+			lines = r.fencedCode(lines)
+		case thematicPat.MatchString(line):
+			r.buf.WriteString("<hr>\n")
+			lines = lines[1:]
+		case indentedCodePat.MatchString(line):
+			lines = r.indentedCode(lines)
+		case blockquotePat.MatchString(line):
+			lines = r.blockquote(lines)
+		case listItemPat.MatchString(line):
+			lines = r.list(lines)
+		case len(lines) > 1 && strings.Contains(line, "|") && tableDelimPat.MatchString(lines[1]):
+			lines = r.table(lines)
+		default:
+			lines = r.paragraph(lines)
+		}
+	}
+}
+
+func (r *markdownRenderer) heading(level int, text string) {
+	// Headings in the README are nested below the headings of the page.
+	level += 2
+	if level > 6 {
+		level = 6
+	}
+	tag := "h" + strconv.Itoa(level)
+	r.buf.WriteString("<" + tag + ">")
+	r.inline(strings.TrimSpace(text))
+	r.buf.WriteString("</" + tag + ">\n")
+}
+
+func (r *markdownRenderer) paragraph(lines []string) []string {
+	n := 1
+	for n < len(lines) && !isBlank(lines[n]) {
+		if setextPat.MatchString(lines[n]) {
+			level := 1
+			if strings.TrimSpace(lines[n])[0] == '-' {
+				level = 2
+			}
+			r.heading(level, strings.Join(trimLines(lines[:n]), "\n"))
+			return lines[n+1:]
+		}
+		if startsBlock(lines[n]) {
+			break
+		}
+		n++
+	}
+	r.buf.WriteString("<p>")
+	r.inline(strings.Join(trimLines(lines[:n]), "\n"))
+	r.buf.WriteString("</p>\n")
+	return lines[n:]
+}
+
+func trimLines(lines []string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = strings.TrimLeft(line, " ")
+	}
+	return result
+}
+
+func (r *markdownRenderer) code(lang string, lines []string) {
+	text := strings.Join(lines, "\n")
+	if lang != "" {
+		r.buf.WriteString(`<pre class="lang-` + htemp.HTMLEscapeString(lang) + `">`)
+	} else {
+		r.buf.WriteString("<pre>")
+	}
+	if lang == "go" {
+		for i, line := range highlightLines([]byte(text)) {
+			if i > 0 {
+				r.buf.WriteByte('\n')
+			}
+			r.buf.WriteString(string(line))
+		}
+	} else {
+		htemp.HTMLEscape(&r.buf, []byte(text))
+	}
+	r.buf.WriteString("</pre>\n")
+}
+
+func (r *markdownRenderer) fencedCode(lines []string) []string {
+	m := fencePat.FindStringSubmatch(lines[0])
+	indent, fence := len(m[1]), m[2]
+	lang := strings.Fields(m[3] + " ")
+	var code []string
+	lines = lines[1:]
+	for len(lines) > 0 {
+		line := lines[0]
+		lines = lines[1:]
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+			break
+		}
+		code = append(code, cutIndent(line, indent))
+	}
+	l := ""
+	if len(lang) > 0 {
+		l = lang[0]
+	}
+	r.code(l, code)
+	return lines
+}
+
+func (r *markdownRenderer) indentedCode(lines []string) []string {
+	var code []string
+	for len(lines) > 0 && (indentedCodePat.MatchString(lines[0]) || isBlank(lines[0])) {
+		code = append(code, cutIndent(lines[0], 4))
+		lines = lines[1:]
+	}
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+	r.code("", code)
+	return lines
+}
+
+func (r *markdownRenderer) blockquote(lines []string) []string {
+	var quoted []string
+	for len(lines) > 0 && !isBlank(lines[0]) {
+		line := lines[0]
+		if blockquotePat.MatchString(line) {
+			line = blockquotePat.ReplaceAllString(line, "")
+		} else if startsBlock(line) {
+			break
+		}
+		quoted = append(quoted, line)
+		lines = lines[1:]
+	}
+	r.buf.WriteString("<blockquote>\n")
+	r.blocks(quoted)
+	r.buf.WriteString("</blockquote>\n")
+	return lines
+}
+
+func (r *markdownRenderer) list(lines []string) []string {
+	m := listItemPat.FindStringSubmatch(lines[0])
+	marker := m[2]
+	ordered := marker[0] >= '0' && marker[0] <= '9'
+	delim := marker[len(marker)-1:]
+	sameList := func(m []string) bool {
+		mo := m[2][0] >= '0' && m[2][0] <= '9'
+		return mo == ordered && m[2][len(m[2])-1:] == delim
+	}
+
+	var items [][]string
+	loose := false
+	for len(lines) > 0 {
+		m := listItemPat.FindStringSubmatch(lines[0])
+		if m == nil || !sameList(m) {
+			break
+		}
+		indent := len(m[0])
+		if isBlank(m[3]) || len(m[3]) > 4 {
+			indent = len(m[1]) + len(m[2]) + 1
+		}
+		item := []string{strings.TrimLeft(lines[0][len(m[0]):], " ")}
+		lines = lines[1:]
+		for len(lines) > 0 {
+			line := lines[0]
+			if isBlank(line) {
+				// Continue the item if the next non-blank line is
+				// indented.
+				j := 0
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j < len(lines) && len(lines[j])-len(strings.TrimLeft(lines[j], " ")) >= indent {
+					item = append(item, lines[:j]...)
+					lines = lines[j:]
+					loose = true
+					continue
+				}
+				if j < len(lines) {
+					if m := listItemPat.FindStringSubmatch(lines[j]); m != nil && sameList(m) {
+						loose = true
+					}
+				}
+				lines = lines[j:]
+				break
+			}
+			if len(line)-len(strings.TrimLeft(line, " ")) >= indent {
+				item = append(item, cutIndent(line, indent))
+			} else if !startsBlock(line) {
+				// Lazy continuation line.
+				item = append(item, strings.TrimLeft(line, " "))
+			} else {
+				break
+			}
+			lines = lines[1:]
+		}
+		items = append(items, item)
+		if len(lines) > 0 && isBlank(lines[0]) {
+			break
+		}
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+		if start, _ := strconv.Atoi(marker[:len(marker)-1]); start != 1 {
+			r.buf.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+		} else {
+			r.buf.WriteString("<ol>\n")
+		}
+	} else {
+		r.buf.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		r.buf.WriteString("<li>")
+		if !loose && len(item) > 0 && !startsBlock(item[0]) {
+			// Render the leading paragraph of a tight item without a
+			// paragraph element.
+			n := 1
+			for n < len(item) && !isBlank(item[n]) && !startsBlock(item[n]) {
+				n++
+			}
+			r.inline(strings.Join(trimLines(item[:n]), "\n"))
+			item = item[n:]
+			if len(item) > 0 {
+				r.buf.WriteString("\n")
+			}
+		}
+		r.blocks(item)
+		r.buf.WriteString("</li>\n")
+	}
+	r.buf.WriteString("</" + tag + ">\n")
+	return lines
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell []byte
+	inCode := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell = append(cell, '|')
+			i++
+		case c == '`':
+			inCode = !inCode
+			cell = append(cell, c)
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(string(cell)))
+			cell = cell[:0]
+		default:
+			cell = append(cell, c)
+		}
+	}
+	return append(cells, strings.TrimSpace(string(cell)))
+}
+
+func (r *markdownRenderer) table(lines []string) []string {
+	header := splitTableRow(lines[0])
+	var aligns []string
+	for _, d := range splitTableRow(lines[1]) {
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(d, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(d, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	if len(aligns) != len(header) {
+		return r.paragraph(lines)
+	}
+	row := func(tag string, cells []string) {
+		r.buf.WriteString("<tr>")
+		for i, align := range aligns {
+			if align != "" {
+				r.buf.WriteString("<" + tag + ` style="text-align: ` + align + `">`)
+			} else {
+				r.buf.WriteString("<" + tag + ">")
+			}
+			if i < len(cells) {
+				r.inline(cells[i])
+			}
+			r.buf.WriteString("</" + tag + ">")
+		}
+		r.buf.WriteString("</tr>\n")
+	}
+	r.buf.WriteString(`<table class="table table-condensed">` + "\n<thead>\n")
+	row("th", header)
+	r.buf.WriteString("</thead>\n<tbody>\n")
+	lines = lines[2:]
+	for len(lines) > 0 && !isBlank(lines[0]) && !startsBlock(lines[0]) {
+		row("td", splitTableRow(lines[0]))
+		lines = lines[1:]
+	}
+	r.buf.WriteString("</tbody>\n</table>\n")
+	return lines
+}
+
+const markdownPunct = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+var (
+	autoLinkPat = regexp.MustCompile(`^<((?:https?|mailto):[^<>\s]+)>`)
+	bareURLPat  = regexp.MustCompile(`^https?://[^\s<]*[^\s<.,:;"')\]]`)
+)
+
+// maxInlineSpan is the maximum length of a link text or emphasis. The limit
+// bounds the time spent scanning for closing delimiters that do not exist.
+const maxInlineSpan = 2048
+
+func truncateSpan(s string) string {
+	if len(s) > maxInlineSpan {
+		return s[:maxInlineSpan]
+	}
+	return s
+}
+
+// linkEnd returns the index of the closing bracket matching the opening
+// bracket at s[0] or -1 if there is no closing bracket.
+func linkEnd(s string) int {
+	s = truncateSpan(s)
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			if j := strings.IndexByte(s[i+1:], '`'); j >= 0 {
+				i += j + 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// linkDest parses a link destination and optional title in parentheses at
+// the start of s. The function returns the destination and the length of
+// the parsed text or -1 if s does not start with a destination.
+func linkDest(s string) (string, int) {
+	if !strings.HasPrefix(s, "(") {
+		return "", -1
+	}
+	end := -1
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '(' {
+			depth++
+		} else if s[i] == ')' {
+			depth--
+			if depth == 0 {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return "", -1
+	}
+	dest := strings.TrimSpace(s[1:end])
+	if strings.HasPrefix(dest, "<") {
+		if i := strings.IndexByte(dest, '>'); i > 0 {
+			return dest[1:i], end + 1
+		}
+	}
+	if i := strings.IndexAny(dest, " \t\n"); i >= 0 {
+		// Discard the title.
+		dest = dest[:i]
+	}
+	return dest, end + 1
+}
+
+// emphasisEnd returns the index of the closing delimiter for an emphasis
+// opened with delim at the start of s or -1 if the emphasis is not closed.
+func emphasisEnd(s, delim string) int {
+	s = truncateSpan(s)
+	if len(s) <= len(delim) || s[len(delim)] == ' ' || s[len(delim)] == '\n' {
+		return -1
+	}
+	for i := len(delim) + 1; i+len(delim) <= len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '`':
+			if j := strings.IndexByte(s[i+1:], '`'); j >= 0 {
+				i += j + 1
+			}
+		case strings.HasPrefix(s[i:], delim) && s[i-1] != ' ' && s[i-1] != '\n':
+			if delim == "*" && strings.HasPrefix(s[i:], "**") {
+				i++
+				continue
+			}
+			if delim[0] == '_' && i+len(delim) < len(s) && isWordByte(s[i+len(delim)]) {
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+var emphasisDelims = []struct{ delim, tag string }{
+	{"**", "strong"},
+	{"__", "strong"},
+	{"~~", "del"},
+	{"*", "em"},
+	{"_", "em"},
+}
+
+// emphasis renders the emphasis at the start of s and returns the length of
+// the emphasis or 0 if s does not start with an emphasis. The function
+// calls flush before rendering the emphasis.
+func (r *markdownRenderer) emphasis(s string, flush func()) int {
+	for _, e := range emphasisDelims {
+		if !strings.HasPrefix(s, e.delim) {
+			continue
+		}
+		if end := emphasisEnd(s, e.delim); end >= 0 {
+			flush()
+			r.buf.WriteString("<" + e.tag + ">")
+			r.inline(s[len(e.delim):end])
+			r.buf.WriteString("</" + e.tag + ">")
+			return end + len(e.delim)
+		}
+	}
+	return 0
+}
+
+func (r *markdownRenderer) text(s string) {
+	htemp.HTMLEscape(&r.buf, []byte(s))
+}
+
+// inline renders the inline content s.
+func (r *markdownRenderer) inline(s string) {
+	start := 0
+	flush := func(i int) {
+		r.text(s[start:i])
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(markdownPunct, s[i+1]) >= 0:
+			flush(i)
+			r.text(s[i+1 : i+2])
+			i += 2
+			start = i
+			continue
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			flush(i)
+			r.buf.WriteString("<br>\n")
+			i += 2
+			start = i
+			continue
+		case c == '\n' && i >= 2 && s[i-1] == ' ' && s[i-2] == ' ':
+			r.text(strings.TrimRight(s[start:i], " "))
+			r.buf.WriteString("<br>\n")
+			i++
+			start = i
+			continue
+		case c == '`':
+			n := 0
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			ticks := s[i : i+n]
+			j := strings.Index(s[i+n:], ticks)
+			if j < 0 {
+				i += n
+				continue
+			}
+			flush(i)
+			code := strings.Replace(s[i+n:i+n+j], "\n", " ", -1)
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			r.buf.WriteString("<code>")
+			r.text(code)
+			r.buf.WriteString("</code>")
+			i += n + j + n
+			start = i
+			continue
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if end := linkEnd(s[i+1:]); end >= 0 {
+				if dest, n := linkDest(s[i+2+end:]); n >= 0 {
+					flush(i)
+					alt := s[i+2 : i+1+end]
+					if u := r.resolve(dest, true); u != "" {
+						r.buf.WriteString(`<img src="` + htemp.HTMLEscapeString(proxyImageURL(u)) + `" alt="`)
+						r.text(alt)
+						r.buf.WriteString(`">`)
+					} else {
+						r.text(alt)
+					}
+					i += 2 + end + n
+					start = i
+					continue
+				}
+			}
+		case c == '[':
+			if end := linkEnd(s[i:]); end >= 0 {
+				if dest, n := linkDest(s[i+end+1:]); n >= 0 {
+					flush(i)
+					if u := r.resolve(dest, false); u != "" {
+						r.buf.WriteString(`<a href="` + htemp.HTMLEscapeString(u) + `" rel="nofollow">`)
+						r.inline(s[i+1 : i+end])
+						r.buf.WriteString("</a>")
+					} else {
+						r.inline(s[i+1 : i+end])
+					}
+					i += end + 1 + n
+					start = i
+					continue
+				}
+			}
+		case c == '<':
+			if m := autoLinkPat.FindStringSubmatch(s[i:]); m != nil {
+				flush(i)
+				r.buf.WriteString(`<a href="` + htemp.HTMLEscapeString(m[1]) + `" rel="nofollow">`)
+				r.text(strings.TrimPrefix(m[1], "mailto:"))
+				r.buf.WriteString("</a>")
+				i += len(m[0])
+				start = i
+				continue
+			}
+		case c == 'h' && (i == 0 || !isWordByte(s[i-1])):
+			if m := bareURLPat.FindString(s[i:]); m != "" {
+				flush(i)
+				r.buf.WriteString(`<a href="` + htemp.HTMLEscapeString(m) + `" rel="nofollow">`)
+				r.text(m)
+				r.buf.WriteString("</a>")
+				i += len(m)
+				start = i
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if c == '_' && i > 0 && isWordByte(s[i-1]) {
+				break
+			}
+			if n := r.emphasis(s[i:], func() { flush(i) }); n > 0 {
+				i += n
+				start = i
+				continue
+			}
+		}
+		i++
+	}
+	flush(len(s))
+}
+
+// readmeHTML returns the HTML for a README file.
+func readmeHTML(readme *doc.Readme) htemp.HTML {
+	if readme == nil || len(bytes.TrimSpace(readme.Data)) == 0 {
+		return ""
+	}
+	if !doc.IsMarkdown(readme.Name) {
+		return htemp.HTML("<pre>" + htemp.HTMLEscapeString(string(readme.Data)) + "</pre>")
+	}
+	return renderMarkdown(readme.Data, readme.BrowseURL)
+}
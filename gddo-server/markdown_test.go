@@ -0,0 +1,67 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+)
+
+const readmeBrowseURL = "https://github.com/user/repo/blob/master/README.md"
+
+var markdownTests = []struct {
+	in, out string
+}{
+	{"# Title", "<h3>Title</h3>\n"},
+	{"Title\n=====\n\nSub\n---", "<h3>Title</h3>\n<h4>Sub</h4>\n"},
+	{"Hello *world* and **all**\nof _you_ ~~not~~ `a<b>`", "<p>Hello <em>world</em> and <strong>all</strong>\nof <em>you</em> <del>not</del> <code>a&lt;b&gt;</code></p>\n"},
+	{"snake_case_name", "<p>snake_case_name</p>\n"},
+	{`<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>\n"},
+	{"[doc](doc/README.md) [abs](https://example.com/) [top](#install) [bad](javascript:alert(1))",
+		`<p><a href="https://github.com/user/repo/blob/master/doc/README.md" rel="nofollow">doc</a> <a href="https://example.com/" rel="nofollow">abs</a> <a href="#install" rel="nofollow">top</a> bad</p>` + "\n"},
+	{"![logo](img/logo.png)", `<p><img src="https://raw.githubusercontent.com/user/repo/master/img/logo.png" alt="logo"></p>` + "\n"},
+	{"[![build](https://ci.example.com/badge.svg)](https://ci.example.com/)",
+		`<p><a href="https://ci.example.com/" rel="nofollow"><img src="https://ci.example.com/badge.svg" alt="build"></a></p>` + "\n"},
+	{"See <https://example.com> or https://golang.org.", `<p>See <a href="https://example.com" rel="nofollow">https://example.com</a> or <a href="https://golang.org" rel="nofollow">https://golang.org</a>.</p>` + "\n"},
+	{"```go\nfunc f() {}\n```", "<pre class=\"lang-go\"><span class=\"kwd\">func</span> f() {}</pre>\n"},
+	{"~~~\n<x>\n~~~", "<pre>&lt;x&gt;</pre>\n"},
+	{"    go get x\n", "<pre>go get x</pre>\n"},
+	{"- a\n- b\n  continued\n- c", "<ul>\n<li>a</li>\n<li>b\ncontinued</li>\n<li>c</li>\n</ul>\n"},
+	{"1. one\n2. two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+	{"3) three", "<ol start=\"3\">\n<li>three</li>\n</ol>\n"},
+	{"- a\n\n- b", "<ul>\n<li><p>a</p>\n</li>\n<li><p>b</p>\n</li>\n</ul>\n"},
+	{"- a\n  - b", "<ul>\n<li>a\n<ul>\n<li>b</li>\n</ul>\n</li>\n</ul>\n"},
+	{"> quoted\n> text", "<blockquote>\n<p>quoted\ntext</p>\n</blockquote>\n"},
+	{"---", "<hr>\n"},
+	{"| Name | Value |\n|:-----|------:|\n| `a\\|b` | 1 |\n| c |", "<table class=\"table table-condensed\">\n<thead>\n<tr><th style=\"text-align: left\">Name</th><th style=\"text-align: right\">Value</th></tr>\n</thead>\n<tbody>\n<tr><td style=\"text-align: left\"><code>a|b</code></td><td style=\"text-align: right\">1</td></tr>\n<tr><td style=\"text-align: left\">c</td><td style=\"text-align: right\"></td></tr>\n</tbody>\n</table>\n"},
+	{"line one  \nline two", "<p>line one<br>\nline two</p>\n"},
+	{`\*not emphasis\*`, "<p>*not emphasis*</p>\n"},
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	for _, tt := range markdownTests {
+		if out := string(renderMarkdown([]byte(tt.in), readmeBrowseURL)); out != tt.out {
+			t.Errorf("renderMarkdown(%q) =\n%q\nwant\n%q", tt.in, out, tt.out)
+		}
+	}
+}
+
+var rawURLTests = []struct {
+	in, out string
+}{
+	{"https://github.com/user/repo/blob/master/a.png", "https://raw.githubusercontent.com/user/repo/master/a.png"},
+	{"https://gitlab.com/group/sub/repo/-/blob/master/a.png", "https://gitlab.com/group/sub/repo/-/raw/master/a.png"},
+	{"https://bitbucket.org/user/repo/src/default/a.png", "https://bitbucket.org/user/repo/raw/default/a.png"},
+	{"https://example.com/a.png", "https://example.com/a.png"},
+}
+
+func TestRawURL(t *testing.T) {
+	for _, tt := range rawURLTests {
+		if out := rawURL(tt.in); out != tt.out {
+			t.Errorf("rawURL(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+// The package pages of the packages fetched from the module proxy have a
+// version picker with the versions in the version list of the module. The
+// documentation of a version that is not saved is built on request from the
+// module zip of the version and saved with the other versions of the
+// package.
+
+var moduleVersionBuilds = flag.Bool("module_version_builds", true, "Build the documentation of the module versions selected in the version picker of the packages fetched from module_proxy on request.")
+
+// buildModuleVersion builds and saves the documentation for the version of
+// the package or returns nil if the version is not in the module versions of
+// the package.
+func buildModuleVersion(ctx context.Context, importPath, version string) (*doc.Package, error) {
+	if !*moduleVersionBuilds || *readOnly {
+		return nil, nil
+	}
+	pdoc, _, err := db.GetDoc(importPath)
+	if err != nil || pdoc == nil {
+		return nil, err
+	}
+	found := false
+	for _, v := range pdoc.ModuleVersions {
+		if v == version {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	if err := checkRate(ctx, crawlLimiter); err != nil {
+		return nil, err
+	}
+	dir, t, err := gosrc.GetModuleVersion(fetchClient(ctx), importPath, version)
+	if gosrc.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	vdoc, err := doc.Build(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Save the version at the time of the version, not of the build, for
+	// the documentation as of a day.
+	vdoc.Updated = t
+	if err := db.PutVersion(vdoc); err != nil {
+		logger(ctx).Error("db.PutVersion", "path", importPath, "version", version, "err", err)
+	}
+	return vdoc, nil
+}
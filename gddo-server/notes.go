@@ -0,0 +1,97 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+// noteTags are the tags of the notes selectable on the notes page.
+var noteTags = []string{"BUG", "TODO"}
+
+// getNotes returns the notes of the packages below the prefix parameter with
+// the tag parameter. The notes of packages not allowed or in private
+// repositories are removed.
+func getNotes(req *http.Request) (prefix, tag string, notes []database.PackageNotes, err error) {
+	prefix = strings.Trim(req.Form.Get("prefix"), "/")
+	tag = strings.ToUpper(req.Form.Get("tag"))
+	if err := checkRate(req.Context(), searchLimiter); err != nil {
+		return "", "", nil, err
+	}
+	notes, err = db.Notes(prefix, tag)
+	if err != nil {
+		return "", "", nil, err
+	}
+	notes, err = filterPackageNotes(req, notes)
+	return prefix, tag, notes, err
+}
+
+// filterPackageNotes removes the packages not allowed or in private
+// repositories from notes.
+func filterPackageNotes(req *http.Request, notes []database.PackageNotes) ([]database.PackageNotes, error) {
+	var paths []database.Package
+	for _, pn := range notes {
+		paths = append(paths, database.Package{Path: pn.Path})
+	}
+	paths, err := filterPrivate(req, filterAllowed(paths))
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool)
+	for _, pkg := range paths {
+		visible[pkg.Path] = true
+	}
+	var result []database.PackageNotes
+	for _, pn := range notes {
+		if visible[pn.Path] {
+			result = append(result, pn)
+		}
+	}
+	return result, nil
+}
+
+// serveNotes serves the BUG, TODO and other notes in the source of the
+// packages below the prefix parameter, all packages if the prefix is empty.
+// The tag parameter selects the notes with the tag.
+func serveNotes(resp http.ResponseWriter, req *http.Request) error {
+	prefix, tag, notes, err := getNotes(req)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, pn := range notes {
+		n += len(pn.Notes)
+	}
+	return executeTemplate(resp, "notes.html", http.StatusOK, nil, map[string]interface{}{
+		"prefix": prefix,
+		"tag":    tag,
+		"tags":   noteTags,
+		"notes":  notes,
+		"count":  n,
+	})
+}
+
+// serveAPIV1Notes serves a page of the packages with notes below the prefix
+// parameter with the notes of each package.
+func serveAPIV1Notes(resp http.ResponseWriter, req *http.Request) error {
+	_, _, notes, err := getNotes(req)
+	if err != nil {
+		return err
+	}
+	start, end, p, err := paginate(resp, req, len(notes))
+	if err != nil {
+		return err
+	}
+	results := notes[start:end]
+	if results == nil {
+		results = []database.PackageNotes{}
+	}
+	return writeAPIV1List(resp, p, results)
+}
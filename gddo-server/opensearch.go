@@ -0,0 +1,63 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+const maxSuggestions = 10
+
+// serveOpenSearch serves the OpenSearch description document
+// (http://www.opensearch.org/) that browsers use to add the site as a search
+// engine.
+func serveOpenSearch(resp http.ResponseWriter, req *http.Request) error {
+	return executeTemplate(resp, "opensearch.xml", http.StatusOK, http.Header{"Cache-Control": {"public, max-age=86400"}}, pageURI(req, ""))
+}
+
+// suggestions returns the response to a suggestion request for the query q
+// in the OpenSearch suggestions format: the query followed by arrays of
+// completions, descriptions and URLs.
+func suggestions(req *http.Request, q string, pkgs []database.Package) []interface{} {
+	if len(pkgs) > maxSuggestions {
+		pkgs = pkgs[:maxSuggestions]
+	}
+	completions := []string{}
+	descriptions := []string{}
+	urls := []string{}
+	for _, pkg := range pkgs {
+		completions = append(completions, pkg.Path)
+		descriptions = append(descriptions, pkg.Synopsis)
+		urls = append(urls, pageURI(req, pkg.Path))
+	}
+	return []interface{}{q, completions, descriptions, urls}
+}
+
+// serveSuggest serves completions of the import path or package name prefix
+// in the q parameter from the suggestion index. The response is in the
+// OpenSearch suggestions format used by browsers and the search box.
+func serveSuggest(resp http.ResponseWriter, req *http.Request) error {
+	q := strings.TrimSpace(req.Form.Get("q"))
+	var pkgs []database.Package
+	if q != "" {
+		var err error
+		pkgs, err = db.Suggest(q, maxSuggestions)
+		if err == nil {
+			pkgs, err = filterPrivate(req, filterAllowed(pkgs))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	resp.Header().Set("Content-Type", "application/x-suggestions+json; charset=utf-8")
+	resp.Header().Set("Cache-Control", "public, max-age=300")
+	return json.NewEncoder(resp).Encode(suggestions(req, q, pkgs))
+}
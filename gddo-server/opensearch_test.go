@@ -0,0 +1,44 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+func TestSuggestions(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/-/suggest?q=json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkgs []database.Package
+	for i := 0; i < maxSuggestions+2; i++ {
+		pkgs = append(pkgs, database.Package{Path: "encoding/json", Synopsis: "Package json implements encoding of JSON."})
+	}
+	got := suggestions(req, "json", pkgs)
+	if len(got) != 4 || got[0] != "json" {
+		t.Fatalf("suggestions returned %v", got)
+	}
+	for i := 1; i < 4; i++ {
+		if n := len(got[i].([]string)); n != maxSuggestions {
+			t.Errorf("len(suggestions[%d]) = %d, want %d", i, n, maxSuggestions)
+		}
+	}
+	if u := got[3].([]string)[0]; u != "http://example.com/encoding/json" {
+		t.Errorf("url = %q, want http://example.com/encoding/json", u)
+	}
+
+	got = suggestions(req, "", nil)
+	want := []interface{}{"", []string{}, []string{}, []string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suggestions with no packages = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+// The overview page of a project, at the project root with the overview
+// query, shows the packages of the project as a tree with the synopses, the
+// statistics of the documentation of the packages and the README of the
+// project root. If the project is a module, the page shows the
+// requirements of the go.mod file that no package imports and the imports
+// that no requirement provides.
+
+var overviewMaxPackages = flag.Int("overview_max_packages", 1000, "Compute the statistics of the project overview page from at most this number of packages. The tree shows all the packages.")
+
+// overviewNode is a directory in the package tree of a project.
+type overviewNode struct {
+	// Name is the last element of the import path, or the import path of
+	// the project root.
+	Name     string
+	Path     string
+	Synopsis string
+
+	// Indexed is true if the directory is in the index. Directories without
+	// Go files between the packages are not always in the index.
+	Indexed bool
+
+	Children []*overviewNode
+}
+
+// overviewStats are the statistics of the documentation of a project.
+type overviewStats struct {
+	Packages int
+	Commands int
+
+	// Exported is the number of exported declarations of the packages and
+	// Examples is the number of examples.
+	Exported int
+	Examples int
+
+	// Licenses are the SPDX identifiers of the detected licenses, sorted.
+	Licenses []string
+
+	// Partial is true if the statistics are computed from the first
+	// -overview_max_packages packages only.
+	Partial bool
+}
+
+// projectTree returns the tree of the packages in the project with the root.
+func projectTree(root string, pkgs []database.Package) *overviewNode {
+	top := &overviewNode{Name: root, Path: root}
+	nodes := map[string]*overviewNode{root: top}
+	var node func(path string) *overviewNode
+	node = func(path string) *overviewNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		i := strings.LastIndex(path, "/")
+		parent := node(path[:i])
+		n := &overviewNode{Name: path[i+1:], Path: path}
+		parent.Children = append(parent.Children, n)
+		nodes[path] = n
+		return n
+	}
+	for _, pkg := range pkgs {
+		if pkg.Path != root && !strings.HasPrefix(pkg.Path, root+"/") {
+			continue
+		}
+		n := node(pkg.Path)
+		n.Synopsis = pkg.Synopsis
+		n.Indexed = true
+	}
+	var sortTree func(n *overviewNode)
+	sortTree = func(n *overviewNode) {
+		sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+		for _, c := range n.Children {
+			sortTree(c)
+		}
+	}
+	sortTree(top)
+	return top
+}
+
+// addStats adds the statistics of the package document to s.
+func (s *overviewStats) addStats(pdoc *doc.Package, licenses map[string]bool) {
+	for _, l := range pdoc.Licenses {
+		licenses[l.ID] = true
+	}
+	switch {
+	case pdoc.Name == "":
+		return
+	case pdoc.IsCmd:
+		s.Commands++
+	default:
+		s.Packages++
+		// The package clause is not an exported declaration.
+		s.Exported += pdoc.Coverage().Exported - 1
+	}
+	s.Examples += len(newTDoc(pdoc).AllExamples())
+}
+
+// projectStats returns the statistics of the packages in the project.
+func projectStats(pkgs []database.Package) (*overviewStats, error) {
+	s := &overviewStats{}
+	licenses := make(map[string]bool)
+	for i, pkg := range pkgs {
+		if *overviewMaxPackages > 0 && i >= *overviewMaxPackages {
+			s.Partial = true
+			break
+		}
+		pdoc, _, err := db.GetDoc(pkg.Path)
+		if err != nil {
+			return nil, err
+		}
+		if pdoc != nil {
+			s.addStats(pdoc, licenses)
+		}
+	}
+	for id := range licenses {
+		s.Licenses = append(s.Licenses, id)
+	}
+	sort.Strings(s.Licenses)
+	return s, nil
+}
+
+func serveOverview(resp http.ResponseWriter, req *http.Request, pdoc *doc.Package, flashMessages []flashMessage) error {
+	pkgs, err := db.Project(pdoc.ProjectRoot)
+	if err == nil {
+		pkgs, err = filterPrivate(req, pkgs)
+	}
+	if err != nil {
+		return err
+	}
+	stats, err := projectStats(pkgs)
+	if err != nil {
+		return err
+	}
+	var deps *database.DependencyReport
+	if pdoc.Module != nil {
+		deps, err = db.DependencyReport(pdoc.Module.Root)
+		if err != nil {
+			return err
+		}
+	}
+	return executeTemplate(resp, "overview.html", http.StatusOK, nil, map[string]interface{}{
+		"flashMessages": flashMessages,
+		"pdoc":          newTDoc(pdoc),
+		"tree":          projectTree(pdoc.ProjectRoot, pkgs),
+		"stats":         stats,
+		"deps":          deps,
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+)
+
+func TestProjectTree(t *testing.T) {
+	tree := projectTree("github.com/user/repo", []database.Package{
+		{Path: "github.com/user/repo", Synopsis: "Package repo."},
+		{Path: "github.com/user/repo/z"},
+		{Path: "github.com/user/repo/a/b/c", Synopsis: "Package c."},
+		{Path: "github.com/user/other"},
+	})
+	var walk func(n *overviewNode) []string
+	walk = func(n *overviewNode) []string {
+		s := []string{n.Name}
+		if n.Indexed {
+			s[0] += "*"
+		}
+		for _, c := range n.Children {
+			for _, e := range walk(c) {
+				s = append(s, n.Name+"/"+e)
+			}
+		}
+		return s
+	}
+	want := []string{
+		"github.com/user/repo*",
+		"github.com/user/repo/a",
+		"github.com/user/repo/a/b",
+		"github.com/user/repo/a/b/c*",
+		"github.com/user/repo/z*",
+	}
+	if got := walk(tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("projectTree() = %v, want %v", got, want)
+	}
+	if c := tree.Children[0].Children[0].Children[0]; c.Path != "github.com/user/repo/a/b/c" || c.Synopsis != "Package c." {
+		t.Errorf("leaf = %+v", c)
+	}
+}
+
+func TestOverviewStats(t *testing.T) {
+	s := &overviewStats{}
+	licenses := make(map[string]bool)
+	s.addStats(&doc.Package{
+		Name:     "a",
+		Funcs:    []*doc.Func{{Name: "F", Examples: []*doc.Example{{Name: ""}}}},
+		Types:    []*doc.Type{{Name: "T", Methods: []*doc.Func{{Name: "M"}}}},
+		Licenses: []doc.License{{ID: "MIT"}},
+	}, licenses)
+	s.addStats(&doc.Package{Name: "main", IsCmd: true, Licenses: []doc.License{{ID: "MIT"}, {ID: "Apache-2.0"}}}, licenses)
+	s.addStats(&doc.Package{}, licenses)
+	if s.Packages != 1 || s.Commands != 1 || s.Exported != 3 || s.Examples != 1 || len(licenses) != 2 {
+		t.Errorf("stats = %+v, licenses %v", s, licenses)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/gosrc"
+)
+
+// Ownership of a project is verified by a gosrc.OwnerFile file in the root of
+// the project or, for projects with a custom import path, by a gosrc.OwnerMeta
+// meta tag in the go-import page of the project root. The file contains the
+// line "sha256:<hash>" and the meta tag the content "sha256:<hash>" where hash
+// is the hex encoded SHA-256 hash of a key known only to the owner. Presenting
+// the key, or signing in with GitHub as an administrator of the repository,
+// authorizes owner-only actions on the packages in the project.
+
+var errBadOwnerKey = errors.New("owner key does not match")
+
+// newOwnerKey returns a random owner key and the hash of the key.
+func newOwnerKey() (key, keyHash string, err error) {
+	var p [16]byte
+	if _, err := rand.Read(p[:]); err != nil {
+		return "", "", err
+	}
+	key = hex.EncodeToString(p[:])
+	return key, hashOwnerKey(key), nil
+}
+
+func hashOwnerKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// checkOwnerKey returns nil if key is the key of the verified owner of the
+// project with the given root.
+func checkOwnerKey(projectRoot, key string) error {
+	if projectRoot == "" || key == "" {
+		return errBadOwnerKey
+	}
+	keyHash, err := db.Owner(projectRoot)
+	if err != nil {
+		return err
+	}
+	if keyHash == "" || subtle.ConstantTimeCompare([]byte(keyHash), []byte(hashOwnerKey(key))) != 1 {
+		return errBadOwnerKey
+	}
+	return nil
+}
+
+// isVerified returns true if the project with the given root has a verified
+// owner.
+func isVerified(projectRoot string) bool {
+	if projectRoot == "" {
+		return false
+	}
+	keyHash, err := db.Owner(projectRoot)
+	if err != nil {
+		slog.Error("db.Owner", "path", projectRoot, "err", err)
+		return false
+	}
+	return keyHash != ""
+}
+
+// serveOwner performs an owner-only action on a package.
+func serveOwner(resp http.ResponseWriter, req *http.Request) error {
+	if req.Method != "POST" {
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+	importPath := req.Form.Get("path")
+	pdoc, pkgs, _, err := db.Get(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	if *readOnly {
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{errorText(errReadOnly)}}})
+		http.Redirect(resp, req, "/"+importPath+"?tools", http.StatusFound)
+		return nil
+	}
+
+	switch err := checkOwner(req, pdoc.ProjectRoot); {
+	case err == errBadOwnerKey:
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{"The owner key is not valid for this project."}}})
+		http.Redirect(resp, req, "/"+importPath+"?tools", http.StatusFound)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	switch req.Form.Get("action") {
+	case "refresh":
+		// Unlike the public refresh, the saved etag is ignored and the
+		// result is waited for without the usual timeout.
+		if _, err := docCrawler.Crawl(req.Context(), "owner", importPath, nil, len(pkgs) > 0, time.Time{}); err != nil && !gosrc.IsNotFound(err) {
+			setFlashMessages(resp, []flashMessage{{ID: "refresh", Args: []string{errorText(err)}}})
+		}
+	case "remove":
+		logger(req.Context()).Info("owner removed package", "path", importPath)
+		if err := db.Block(importPath); err != nil {
+			return err
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: "owner of " + pdoc.ProjectRoot, Action: "block", Target: importPath, Reason: "removed by the verified owner"}); err != nil {
+			return err
+		}
+		pages.invalidate(importPath)
+		packageBlocked(importPath, "removed by the verified owner")
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{importPath + " has been removed."}}})
+	case "hide", "show":
+		s, err := db.ProjectSettings(pdoc.ProjectRoot)
+		if err != nil {
+			return err
+		}
+		s.Hidden = req.Form.Get("action") == "hide"
+		if err := db.SetProjectSettings(pdoc.ProjectRoot, s); err != nil {
+			return err
+		}
+		if err := hideProject(pdoc.ProjectRoot, s.Hidden); err != nil {
+			return err
+		}
+		if s.Hidden {
+			if err := db.AddWatchEvent(database.WatchEvent{Path: pdoc.ProjectRoot, Kind: database.WatchHidden}); err != nil {
+				return err
+			}
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: "owner of " + pdoc.ProjectRoot, Action: req.Form.Get("action"), Target: pdoc.ProjectRoot}); err != nil {
+			return err
+		}
+		message := pdoc.ProjectRoot + " is shown in search results."
+		if s.Hidden {
+			message = pdoc.ProjectRoot + " is hidden from search results."
+		}
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{message}}})
+	case "vendor", "novendor":
+		s, err := db.ProjectSettings(pdoc.ProjectRoot)
+		if err != nil {
+			return err
+		}
+		s.Vendor = req.Form.Get("action") == "vendor"
+		if err := db.SetProjectSettings(pdoc.ProjectRoot, s); err != nil {
+			return err
+		}
+		if s.Vendor {
+			if err := db.AddNewCrawl(pdoc.ProjectRoot + "/vendor"); err != nil {
+				return err
+			}
+		} else if err := deleteVendored(pdoc.ProjectRoot); err != nil {
+			return err
+		}
+		if err := db.AddAuditEntry(database.AuditEntry{Actor: "owner of " + pdoc.ProjectRoot, Action: req.Form.Get("action"), Target: pdoc.ProjectRoot}); err != nil {
+			return err
+		}
+		message := "The vendored packages of " + pdoc.ProjectRoot + " are no longer documented."
+		if s.Vendor {
+			message = "The vendored packages of " + pdoc.ProjectRoot + " will be documented after the vendor directory is crawled."
+		}
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{message}}})
+	case "canonical":
+		canonical := strings.Trim(strings.TrimSpace(req.Form.Get("canonical")), "/")
+		if canonical != "" && !gosrc.IsValidRemotePath(canonical) {
+			setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{canonical + " is not a valid import path."}}})
+			http.Redirect(resp, req, "/"+importPath+"?tools", http.StatusFound)
+			return nil
+		}
+		s, err := db.ProjectSettings(pdoc.ProjectRoot)
+		if err != nil {
+			return err
+		}
+		s.Canonical = canonical
+		if err := db.SetProjectSettings(pdoc.ProjectRoot, s); err != nil {
+			return err
+		}
+		pages.invalidate(pdoc.ProjectRoot)
+		message := "The canonical import path notice has been removed."
+		if canonical != "" {
+			message = "The pages of " + pdoc.ProjectRoot + " show a notice with the canonical import path " + canonical + "."
+		}
+		setFlashMessages(resp, []flashMessage{{ID: "owner", Args: []string{message}}})
+	default:
+		return &httpError{status: http.StatusBadRequest}
+	}
+	http.Redirect(resp, req, "/"+importPath, http.StatusFound)
+	return nil
+}
+
+// hideProject saves the documents of the packages in the project again to
+// hide the packages from the search results or to show the packages.
+func hideProject(projectRoot string, hidden bool) error {
+	pkgs, err := db.Project(projectRoot)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		pdoc, _, err := db.GetDoc(pkg.Path)
+		if err != nil {
+			return err
+		}
+		if pdoc == nil {
+			continue
+		}
+		hide := hidden || pdoc.OptOut || vendoredPath(pdoc.ImportPath) != nil
+		if !hide {
+			if hide, err = db.IsOptedOut(pdoc.ImportPath); err != nil {
+				return err
+			}
+		}
+		if err := db.Put(pdoc, time.Time{}, hide); err != nil {
+			return err
+		}
+	}
+	pages.invalidate(projectRoot)
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements a cache of rendered documentation pages for anonymous
+// requests.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	pageCacheTTL  = flag.Duration("page_cache_ttl", time.Minute, "Time to cache rendered documentation pages for requests without cookies or credentials. Zero disables the cache.")
+	pageCacheSize = flag.Int("page_cache_size", 1000, "Maximum number of rendered documentation pages in the cache.")
+)
+
+// cachedPage is a rendered documentation page.
+type cachedPage struct {
+	importPath string
+	header     http.Header
+	body       []byte
+	expires    time.Time
+
+	// countView is true if views of the page count toward the popular
+	// score of the package.
+	countView bool
+}
+
+// pageCache is an in-process cache of rendered documentation pages. Pages are
+// removed when they expire or the package is stored in the database.
+type pageCache struct {
+	mu    sync.Mutex
+	pages map[string]*cachedPage
+}
+
+var pages = &pageCache{pages: make(map[string]*cachedPage)}
+
+// pageCacheKey returns the key of the page for the request or "" if the
+// response to the request cannot be cached. Requests with cookies or
+// credentials can see flash messages or private packages and are not cached.
+// The csrf cookie set for all browsers does not change the pages.
+func pageCacheKey(req *http.Request, importPath string) string {
+	if *pageCacheTTL <= 0 ||
+		(req.Method != "GET" && req.Method != "HEAD") ||
+		len(req.Form) != 0 ||
+		hasCookiesOtherThan(req, csrfCookie) ||
+		req.Header.Get("Authorization") != "" ||
+		isAuthenticated(req) {
+		return ""
+	}
+	return req.Host + " " + templateExt(req) + " " + requestLanguage(req.Context()) + " " + importPath
+}
+
+// hasCookiesOtherThan returns true if the request has a cookie with another
+// name than name.
+func hasCookiesOtherThan(req *http.Request, name string) bool {
+	for _, c := range req.Cookies() {
+		if c.Name != name {
+			return true
+		}
+	}
+	return req.Header.Get("Cookie") != "" && len(req.Cookies()) == 0
+}
+
+// get returns the page with the key or nil if the page is not in the cache.
+func (c *pageCache) get(key string, now time.Time) *cachedPage {
+	if key == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.pages[key]
+	if p == nil || now.After(p.expires) {
+		return nil
+	}
+	return p
+}
+
+// put adds the page to the cache. If the cache is full, put removes the
+// expired pages and then arbitrary pages to make room.
+func (c *pageCache) put(key string, p *cachedPage, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pages) >= *pageCacheSize {
+		for k, q := range c.pages {
+			if now.After(q.expires) {
+				delete(c.pages, k)
+			}
+		}
+	}
+	for k := range c.pages {
+		if len(c.pages) < *pageCacheSize {
+			break
+		}
+		delete(c.pages, k)
+	}
+	if *pageCacheSize > 0 {
+		c.pages[key] = p
+	}
+}
+
+// invalidate removes the pages of the package, of the directories containing
+// the package and of the packages below the package.
+func (c *pageCache) invalidate(importPath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, p := range c.pages {
+		if p.importPath == importPath ||
+			strings.HasPrefix(importPath, p.importPath+"/") ||
+			strings.HasPrefix(p.importPath, importPath+"/") {
+			delete(c.pages, k)
+		}
+	}
+}
+
+// reset removes all pages.
+func (c *pageCache) reset() {
+	c.mu.Lock()
+	c.pages = make(map[string]*cachedPage)
+	c.mu.Unlock()
+}
+
+// serve writes the page to resp.
+func (p *cachedPage) serve(resp http.ResponseWriter, req *http.Request) {
+	for k, v := range p.header {
+		resp.Header()[k] = v
+	}
+	if etagMatch(req.Header.Get("If-None-Match"), p.header.Get("Etag")) {
+		resp.WriteHeader(http.StatusNotModified)
+		return
+	}
+	resp.Header().Set("Content-Length", strconv.Itoa(len(p.body)))
+	resp.WriteHeader(http.StatusOK)
+	resp.Write(p.body)
+}
+
+// pageRecorder records a page rendered by executeTemplate.
+type pageRecorder struct {
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (r *pageRecorder) Header() http.Header         { return r.header }
+func (r *pageRecorder) Write(p []byte) (int, error) { return r.buf.Write(p) }
+func (r *pageRecorder) WriteHeader(status int)      {}
+
+// executeCachedTemplate executes the template, adds the page to the cache
+// with the key and writes the page to resp.
+func executeCachedTemplate(resp http.ResponseWriter, req *http.Request, key string, p *cachedPage, name string, header http.Header, data interface{}) error {
+	r := &pageRecorder{header: make(http.Header)}
+	if err := executeTemplate(r, name, http.StatusOK, header, data); err != nil {
+		return err
+	}
+	now := time.Now()
+	p.header = r.header
+	p.body = r.buf.Bytes()
+	p.expires = now.Add(*pageCacheTTL)
+	pages.put(key, p, now)
+	p.serve(resp, req)
+	return nil
+}
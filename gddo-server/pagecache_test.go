@@ -0,0 +1,95 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPageCacheKey(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://godoc.org/github.com/user/repo", nil)
+	req.ParseForm()
+	if key := pageCacheKey(req, "github.com/user/repo"); key == "" {
+		t.Error("anonymous request not cached")
+	}
+	req.Header.Set("Cookie", csrfCookie+"=x")
+	if key := pageCacheKey(req, "github.com/user/repo"); key == "" {
+		t.Error("request with the csrf cookie not cached")
+	}
+
+	for _, set := range []func(*http.Request){
+		func(req *http.Request) { req.Method = "POST" },
+		func(req *http.Request) { req.Header.Set("Cookie", "flash=x") },
+		func(req *http.Request) { req.Header.Set("Cookie", csrfCookie+"=x; owner=y") },
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer x") },
+		func(req *http.Request) { req.Form.Set("imports", "") },
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/github.com/user/repo", nil)
+		req.ParseForm()
+		set(req)
+		if key := pageCacheKey(req, "github.com/user/repo"); key != "" {
+			t.Errorf("request %s %v %v cached", req.Method, req.Header, req.Form)
+		}
+	}
+}
+
+func TestPageCache(t *testing.T) {
+	defer func(n int) { *pageCacheSize = n }(*pageCacheSize)
+	*pageCacheSize = 2
+
+	c := &pageCache{pages: make(map[string]*cachedPage)}
+	now := time.Now()
+	c.put("a", &cachedPage{importPath: "github.com/user/repo", expires: now.Add(time.Minute)}, now)
+	c.put("b", &cachedPage{importPath: "github.com/user/repo/sub", expires: now.Add(time.Second)}, now)
+	if c.get("a", now) == nil || c.get("b", now) == nil {
+		t.Fatal("pages not cached")
+	}
+	if c.get("b", now.Add(2*time.Second)) != nil {
+		t.Error("expired page returned")
+	}
+
+	// The expired page is removed to make room.
+	c.put("c", &cachedPage{importPath: "github.com/other/repo", expires: now.Add(time.Minute)}, now.Add(2*time.Second))
+	if len(c.pages) != 2 || c.pages["a"] == nil || c.pages["c"] == nil {
+		t.Errorf("after eviction, pages = %v", c.pages)
+	}
+
+	c.put("b", &cachedPage{importPath: "github.com/user/repo/sub", expires: now.Add(time.Minute)}, now)
+	c.invalidate("github.com/user/repo/sub")
+	if c.pages["a"] != nil || c.pages["b"] != nil {
+		t.Error("pages of package and parent directory not invalidated")
+	}
+	c.put("d", &cachedPage{importPath: "github.com/other/repo/sub", expires: now.Add(time.Minute)}, now)
+	c.invalidate("github.com/other/repo")
+	if len(c.pages) != 0 {
+		t.Errorf("after invalidate, pages = %v", c.pages)
+	}
+}
+
+func TestCachedPageServe(t *testing.T) {
+	p := &cachedPage{
+		header: http.Header{"Etag": {`"abc"`}, "Content-Type": {htmlMIMEType}},
+		body:   []byte("<html></html>"),
+	}
+
+	req := httptest.NewRequest("GET", "/github.com/user/repo", nil)
+	resp := httptest.NewRecorder()
+	p.serve(resp, req)
+	if resp.Code != http.StatusOK || resp.Body.String() != "<html></html>" || resp.Header().Get("Etag") != `"abc"` {
+		t.Errorf("serve = %d %v %q", resp.Code, resp.Header(), resp.Body.String())
+	}
+
+	req.Header.Set("If-None-Match", `"abc"`)
+	resp = httptest.NewRecorder()
+	p.serve(resp, req)
+	if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Errorf("serve with If-None-Match = %d %q", resp.Code, resp.Body.String())
+	}
+}
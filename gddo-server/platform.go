@@ -0,0 +1,38 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"github.com/golang/gddo/doc"
+)
+
+// platformDoc returns the documentation of pdoc for the platform formatted
+// as GOOS/GOARCH or nil if there is no documentation for the platform. The
+// fields of the returned document describing the stored sources and the
+// other platforms are copied from pdoc.
+func platformDoc(pdoc *doc.Package, platform string) (*doc.Package, error) {
+	if platform == pdoc.Platform() {
+		return pdoc, nil
+	}
+	found := false
+	for _, p := range pdoc.Platforms {
+		found = found || p == platform
+	}
+	if !found {
+		return nil, nil
+	}
+	variant, err := db.GetVariant(pdoc.ImportPath, platform)
+	if err != nil || variant == nil {
+		return nil, err
+	}
+	variant.Platforms = pdoc.Platforms
+	variant.SourcesStored = pdoc.SourcesStored
+	if variant.Synopsis == "" {
+		variant.Synopsis = pdoc.Synopsis
+	}
+	return variant, nil
+}
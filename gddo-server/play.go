@@ -7,15 +7,20 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/golang/gddo/doc"
 )
 
+var playgroundURL = flag.String("playground_url", "https://play.golang.org", "Base URL of the Go Playground used to share and run examples.")
+
 func findExamples(pdoc *doc.Package, export, method string) []*doc.Example {
 	if "package" == export {
 		return pdoc.Examples
@@ -57,20 +62,86 @@ func findExample(pdoc *doc.Package, export, method, name string) *doc.Example {
 
 var exampleIDPat = regexp.MustCompile(`([^-]+)(?:-([^-]*)(?:-(.*))?)?`)
 
-func playURL(pdoc *doc.Package, id string) (string, error) {
+// findPlayExample returns the runnable example with the given ID.
+func findPlayExample(pdoc *doc.Package, id string) *doc.Example {
 	if m := exampleIDPat.FindStringSubmatch(id); m != nil {
 		if e := findExample(pdoc, m[1], m[2], m[3]); e != nil && e.Play != "" {
-			resp, err := httpClient.Post("http://play.golang.org/share", "text/plain", strings.NewReader(e.Play))
-			if err != nil {
-				return "", err
-			}
-			defer resp.Body.Close()
-			p, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return "", err
-			}
-			return fmt.Sprintf("http://play.golang.org/p/%s", p), nil
+			return e
+		}
+	}
+	return nil
+}
+
+func playURL(pdoc *doc.Package, id string) (string, error) {
+	e := findPlayExample(pdoc, id)
+	if e == nil {
+		return "", &httpError{status: http.StatusNotFound}
+	}
+	base := strings.TrimSuffix(*playgroundURL, "/")
+	resp, err := httpClient.Post(base+"/share", "text/plain", strings.NewReader(e.Play))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	p, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("playground share returned %d: %s", resp.StatusCode, p)
+	}
+	return fmt.Sprintf("%s/p/%s", base, p), nil
+}
+
+// playResult is the result of running an example in the playground.
+type playResult struct {
+	Output string `json:"output"`
+	Errors string `json:"errors"`
+}
+
+// runExample compiles and runs the example with the given ID in the
+// playground.
+func runExample(pdoc *doc.Package, id string) (*playResult, error) {
+	e := findPlayExample(pdoc, id)
+	if e == nil {
+		return nil, &httpError{status: http.StatusNotFound}
+	}
+	resp, err := httpClient.PostForm(strings.TrimSuffix(*playgroundURL, "/")+"/compile", url.Values{
+		"version": {"2"},
+		"body":    {e.Play},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("playground compile returned %d", resp.StatusCode)
+	}
+	var r struct {
+		Errors string
+		Events []struct {
+			Message string
+			Kind    string
 		}
 	}
-	return "", &httpError{status: http.StatusNotFound}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	result := &playResult{Errors: r.Errors}
+	for _, e := range r.Events {
+		result.Output += e.Message
+	}
+	return result, nil
+}
+
+func servePlayRun(resp http.ResponseWriter, req *http.Request, pdoc *doc.Package) error {
+	if req.Method != "POST" {
+		return &httpError{status: http.StatusMethodNotAllowed}
+	}
+	result, err := runExample(pdoc, req.Form.Get("run"))
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(result)
 }
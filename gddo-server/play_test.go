@@ -0,0 +1,65 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestRunExample(t *testing.T) {
+	const code = "package main\n\nfunc main() { println(1) }\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/compile":
+			if r.FormValue("body") != code {
+				http.Error(w, "unexpected body", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"Errors":"","Events":[{"Message":"1\n","Kind":"stdout","Delay":0},{"Message":"2\n","Kind":"stderr","Delay":0}]}`)
+		case "/share":
+			p, _ := ioutil.ReadAll(r.Body)
+			if string(p) != code {
+				http.Error(w, "unexpected body", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "abc")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer func(u string) { *playgroundURL = u }(*playgroundURL)
+	*playgroundURL = ts.URL + "/"
+
+	pdoc := &doc.Package{Funcs: []*doc.Func{{Name: "F", Examples: []*doc.Example{{Name: "", Play: code}}}}}
+
+	result, err := runExample(pdoc, "F")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (playResult{Output: "1\n2\n"}); *result != want {
+		t.Errorf("runExample returned %+v, want %+v", *result, want)
+	}
+
+	u, err := playURL(pdoc, "F")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ts.URL + "/p/abc"; u != want {
+		t.Errorf("playURL returned %q, want %q", u, want)
+	}
+
+	if _, err := runExample(pdoc, "G"); err == nil {
+		t.Errorf("runExample of missing example returned nil error")
+	}
+}
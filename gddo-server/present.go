@@ -0,0 +1,476 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements a parser for the present format of the .slide and
+// .article files in the indexed repositories. The parser supports the
+// header, the sections, the paragraphs, the lists, the preformatted text, the
+// inline markup and the .code, .play, .image, .link and .caption commands of
+// the format. The talks are rendered without the playground. The images are
+// shown through the image proxy. The .iframe command is not supported
+// because the Content-Security-Policy of the pages blocks the frames of other
+// sites.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// talkDoc is a parsed talk.
+type talkDoc struct {
+	Title    string
+	Subtitle string
+	Date     string
+	Summary  string
+	Tags     []string
+	Authors  []template.HTML
+	Sections []*talkSection
+}
+
+// talkSection is a section of a talk, a slide of a .slide file.
+type talkSection struct {
+	// Number is the number of the section in the outline, such as "2.1".
+	Number string
+	Level  int
+	Title  string
+	Body   template.HTML
+}
+
+var talkDateLayouts = []string{
+	"2 January 2006",
+	"2 Jan 2006",
+	"2 January 2006 15:04",
+	"2 Jan 2006 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2006-01-02",
+}
+
+// isTalkDate returns true if the header line is the date of the talk.
+func isTalkDate(s string) bool {
+	for _, layout := range talkDateLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// talkSectionLevel returns the level of the section with the title line or
+// 0 if the line is not a section title.
+func talkSectionLevel(line string) int {
+	n := 0
+	for n < len(line) && line[n] == '*' {
+		n++
+	}
+	if n == 0 || n >= len(line) || (line[n] != ' ' && line[n] != '\t') {
+		return 0
+	}
+	return n
+}
+
+// parseTalk parses the talk in the file with the name in files. The other
+// files are the files included with the .code and .play commands.
+func parseTalk(name string, files map[string][]byte) (*talkDoc, error) {
+	data, ok := files[name]
+	if !ok {
+		return nil, errors.New("talk file not found")
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	d := &talkDoc{}
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i == len(lines) {
+		return nil, errors.New("talk without a title")
+	}
+	d.Title = strings.TrimSpace(lines[i])
+	for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, "Tags:"):
+			for _, tag := range strings.Split(line[len("Tags:"):], ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					d.Tags = append(d.Tags, tag)
+				}
+			}
+		case strings.HasPrefix(line, "Summary:"):
+			d.Summary = strings.TrimSpace(line[len("Summary:"):])
+		case strings.HasPrefix(line, "OldURL:"):
+		case isTalkDate(line):
+			d.Date = line
+		case d.Subtitle == "":
+			d.Subtitle = line
+		}
+	}
+
+	// The author blocks, separated by blank lines, end at the first section.
+	var author []string
+	for ; i < len(lines) && talkSectionLevel(lines[i]) == 0; i++ {
+		line := strings.TrimSpace(lines[i])
+		if line != "" {
+			author = append(author, line)
+			continue
+		}
+		if author != nil {
+			d.Authors = append(d.Authors, talkAuthor(author))
+			author = nil
+		}
+	}
+	if author != nil {
+		d.Authors = append(d.Authors, talkAuthor(author))
+	}
+
+	var numbers []int
+	for i < len(lines) {
+		level := talkSectionLevel(lines[i])
+		for len(numbers) < level {
+			numbers = append(numbers, 0)
+		}
+		numbers = numbers[:level]
+		numbers[level-1]++
+		var number []string
+		for _, n := range numbers {
+			number = append(number, strconv.Itoa(n))
+		}
+		s := &talkSection{
+			Number: strings.Join(number, "."),
+			Level:  level,
+			Title:  strings.TrimSpace(lines[i][level:]),
+		}
+		i++
+		j := i
+		for j < len(lines) && talkSectionLevel(lines[j]) == 0 {
+			j++
+		}
+		s.Body = renderTalkBody(lines[i:j], files)
+		d.Sections = append(d.Sections, s)
+		i = j
+	}
+	return d, nil
+}
+
+// talkAuthor renders the lines of an author block. Lines with a URL or an
+// email address are links.
+func talkAuthor(lines []string) template.HTML {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("<br>")
+		}
+		switch {
+		case !strings.Contains(line, " ") && (strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://")):
+			fmt.Fprintf(&b, `<a href="%s" rel="nofollow">%s</a>`, html.EscapeString(line), html.EscapeString(line))
+		case !strings.Contains(line, " ") && strings.Contains(line, "@") && !strings.HasPrefix(line, "@"):
+			fmt.Fprintf(&b, `<a href="mailto:%s">%s</a>`, html.EscapeString(line), html.EscapeString(line))
+		default:
+			b.WriteString(string(talkStyle(line)))
+		}
+	}
+	return template.HTML(b.String())
+}
+
+// renderTalkBody renders the lines of a section.
+func renderTalkBody(lines []string, files map[string][]byte) template.HTML {
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case strings.HasPrefix(line, "."):
+			b.WriteString(string(renderTalkCommand(line, files)))
+			i++
+		case strings.HasPrefix(line, "- "):
+			b.WriteString("<ul>")
+			for i < len(lines) && strings.HasPrefix(lines[i], "- ") {
+				item := strings.TrimSpace(lines[i][2:])
+				for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "" && lines[i][0] != '.' && !strings.HasPrefix(lines[i], "- "); i++ {
+					item += " " + strings.TrimSpace(lines[i])
+				}
+				fmt.Fprintf(&b, "<li>%s</li>", talkStyle(item))
+			}
+			b.WriteString("</ul>")
+		case line[0] == ' ' || line[0] == '\t':
+			var pre []string
+			for i < len(lines) && (lines[i] == "" || lines[i][0] == ' ' || lines[i][0] == '\t') {
+				pre = append(pre, lines[i])
+				i++
+			}
+			for len(pre) > 0 && strings.TrimSpace(pre[len(pre)-1]) == "" {
+				pre = pre[:len(pre)-1]
+			}
+			fmt.Fprintf(&b, "<pre>%s</pre>", html.EscapeString(unindent(pre)))
+		default:
+			var text []string
+			for i < len(lines) && isTalkText(lines[i]) {
+				text = append(text, strings.TrimSpace(lines[i]))
+				i++
+			}
+			fmt.Fprintf(&b, "<p>%s</p>", talkStyle(strings.Join(text, " ")))
+		}
+	}
+	return template.HTML(b.String())
+}
+
+// isTalkText returns true if the line continues a paragraph or a list item.
+func isTalkText(line string) bool {
+	return strings.TrimSpace(line) != "" && line[0] != '.' && line[0] != ' ' && line[0] != '\t'
+}
+
+// unindent removes the common indentation of the lines.
+func unindent(lines []string) string {
+	prefix, first := "", true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTalkCommand renders a line with a command.
+func renderTalkCommand(line string, files map[string][]byte) template.HTML {
+	fields := strings.Fields(line)
+	args := strings.TrimSpace(line[len(fields[0]):])
+	switch fields[0] {
+	case ".code", ".play":
+		// Skip the flags, such as -numbers and -edit.
+		for len(fields) > 1 && strings.HasPrefix(fields[1], "-") {
+			args = strings.TrimSpace(args[len(fields[1]):])
+			fields = append(fields[:1], fields[2:]...)
+		}
+		if len(fields) < 2 {
+			return talkError("missing file name")
+		}
+		data, ok := files[fields[1]]
+		if !ok {
+			return talkError(fields[1] + " not found")
+		}
+		code, err := talkCode(string(data), strings.TrimSpace(args[len(fields[1]):]))
+		if err != nil {
+			return talkError(fields[1] + ": " + err.Error())
+		}
+		return template.HTML(`<div class="x-code"><pre>` + html.EscapeString(code) + `</pre></div>`)
+	case ".image":
+		if len(fields) < 2 || !isTalkURL(fields[1], false) {
+			return talkError("bad URL")
+		}
+		attrs := ""
+		if len(fields) >= 4 {
+			for i, name := range []string{"height", "width"} {
+				if n, err := strconv.Atoi(fields[2+i]); err == nil && n > 0 {
+					attrs += fmt.Sprintf(` %s="%d"`, name, n)
+				}
+			}
+		}
+		return template.HTML(fmt.Sprintf(`<div class="x-image"><img src="%s"%s></div>`, html.EscapeString(proxyImageURL(fields[1])), attrs))
+	case ".iframe":
+		return talkError("frames not supported")
+	case ".link":
+		if len(fields) < 2 || !isTalkURL(fields[1], true) {
+			return talkError("bad URL")
+		}
+		text := strings.TrimSpace(args[len(fields[1]):])
+		if text == "" {
+			text = fields[1]
+		}
+		return template.HTML(fmt.Sprintf(`<p class="x-link"><a href="%s" rel="nofollow">%s</a></p>`, html.EscapeString(fields[1]), talkStyle(text)))
+	case ".caption":
+		return template.HTML(`<p class="x-caption">` + string(talkStyle(args)) + `</p>`)
+	}
+	return talkError("unknown command " + fields[0])
+}
+
+func talkError(msg string) template.HTML {
+	return template.HTML(`<p class="text-danger">` + html.EscapeString("ERROR: "+msg) + `</p>`)
+}
+
+// isTalkURL returns true if the URL in a talk is an absolute http or https
+// URL, or with links also a relative or mailto URL.
+func isTalkURL(s string, link bool) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return link
+	case "":
+		return link && u.Host == ""
+	}
+	return false
+}
+
+var (
+	talkOmitPat      = regexp.MustCompile(`OMIT\s*$`)
+	talkHighlightPat = regexp.MustCompile(`\s*//\s*HL[a-zA-Z0-9_]*\s*$`)
+)
+
+// talkCode returns the lines of the code selected by the address of a .code
+// command. The address is empty for the whole file, a line number, $ for the
+// last line or a /regexp/ matching the line, or two of those separated by a
+// comma for a range of lines. The lines ending with OMIT are removed and the
+// HL highlight comments are removed from the lines.
+func talkCode(data string, addr string) (string, error) {
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	start, end := 0, len(lines)-1
+	if addr != "" {
+		var parts []string
+		if strings.HasPrefix(addr, "/") {
+			// The regexps can contain commas.
+			i := strings.Index(addr[1:], "/")
+			if i < 0 {
+				return "", errors.New("bad address")
+			}
+			parts = append(parts, addr[:i+2])
+			if rest := strings.TrimSpace(addr[i+2:]); rest != "" {
+				if !strings.HasPrefix(rest, ",") {
+					return "", errors.New("bad address")
+				}
+				parts = append(parts, strings.TrimSpace(rest[1:]))
+			}
+		} else {
+			parts = strings.SplitN(addr, ",", 2)
+		}
+		var err error
+		start, err = talkLine(lines, strings.TrimSpace(parts[0]), 0)
+		if err != nil {
+			return "", err
+		}
+		end = start
+		if len(parts) == 2 {
+			end, err = talkLine(lines, strings.TrimSpace(parts[1]), start)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	var out []string
+	for _, line := range lines[start : end+1] {
+		if talkOmitPat.MatchString(line) {
+			continue
+		}
+		out = append(out, talkHighlightPat.ReplaceAllString(line, ""))
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// talkLine returns the index of the line with the address, searching the
+// regexps from the line with index from.
+func talkLine(lines []string, addr string, from int) (int, error) {
+	switch {
+	case addr == "$":
+		return len(lines) - 1, nil
+	case len(addr) >= 2 && addr[0] == '/' && addr[len(addr)-1] == '/':
+		re, err := regexp.Compile(addr[1 : len(addr)-1])
+		if err != nil {
+			return 0, err
+		}
+		for i := from; i < len(lines); i++ {
+			if re.MatchString(lines[i]) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no match for %s", addr)
+	}
+	n, err := strconv.Atoi(addr)
+	if err != nil || n < 1 || n > len(lines) || n-1 < from {
+		return 0, fmt.Errorf("bad address %s", addr)
+	}
+	return n - 1, nil
+}
+
+var (
+	talkLinkPat   = regexp.MustCompile(`\[\[([^\]\s]+)\](?:\[([^\]]*)\])?\]`)
+	talkCodePat   = regexp.MustCompile("`[^`]+`")
+	talkBoldPat   = regexp.MustCompile(`(^|[\s(])\*([^\s*](?:\S*[^\s*])?)\*($|[\s).,;:!?])`)
+	talkItalicPat = regexp.MustCompile(`(^|[\s(])_([^\s_](?:\S*[^\s_])?)_($|[\s).,;:!?])`)
+)
+
+// talkStyle renders the inline markup of the text: [[url][text]] links,
+// `code`, *bold* and _italic_.
+func talkStyle(s string) template.HTML {
+	var b strings.Builder
+	i := 0
+	for _, m := range talkLinkPat.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(talkStyleText(s[i:m[0]]))
+		u := s[m[2]:m[3]]
+		text := u
+		if m[4] >= 0 {
+			text = s[m[4]:m[5]]
+		}
+		if isTalkURL(u, true) {
+			fmt.Fprintf(&b, `<a href="%s" rel="nofollow">%s</a>`, html.EscapeString(u), talkStyleText(text))
+		} else {
+			b.WriteString(talkStyleText(text))
+		}
+		i = m[1]
+	}
+	b.WriteString(talkStyleText(s[i:]))
+	return template.HTML(b.String())
+}
+
+// talkStyleText renders the code, bold and italic markup of text without
+// links.
+func talkStyleText(s string) string {
+	var b strings.Builder
+	i := 0
+	for _, m := range talkCodePat.FindAllStringIndex(s, -1) {
+		b.WriteString(talkEmphasis(s[i:m[0]]))
+		b.WriteString("<code>" + html.EscapeString(s[m[0]+1:m[1]-1]) + "</code>")
+		i = m[1]
+	}
+	b.WriteString(talkEmphasis(s[i:]))
+	return b.String()
+}
+
+func talkEmphasis(s string) string {
+	s = html.EscapeString(s)
+	// The patterns are applied twice because adjacent matches share the
+	// separator between the matches. The markers inside the words are
+	// spaces, as in *bold*words*.
+	for i := 0; i < 2; i++ {
+		s = talkBoldPat.ReplaceAllStringFunc(s, func(m string) string {
+			p := talkBoldPat.FindStringSubmatch(m)
+			return p[1] + "<b>" + strings.Replace(p[2], "*", " ", -1) + "</b>" + p[3]
+		})
+		s = talkItalicPat.ReplaceAllStringFunc(s, func(m string) string {
+			p := talkItalicPat.FindStringSubmatch(m)
+			return p[1] + "<i>" + strings.Replace(p[2], "_", " ", -1) + "</i>" + p[3]
+		})
+	}
+	return s
+}
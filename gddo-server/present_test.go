@@ -0,0 +1,134 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+const testTalk = `# A comment.
+Go Concurrency Patterns
+Concurrency is not parallelism
+15 Jul 2012
+Tags: go, concurrency
+
+Rob Pike
+Google
+https://golang.org
+r@golang.org
+
+* Introduction
+
+Goroutines are *cheap*, see [[https://golang.org/doc][the docs]] and _Effective_Go_.
+The ` + "`go`" + ` statement starts one.
+
+- First
+- Second
+  continued
+
+	func main() {
+		go f()
+	}
+
+.code main.go /^func main/,/^}/
+.image https://example.com/gopher.png 100 _
+.link javascript:alert(1) Bad
+
+** Details
+
+.play -edit main.go
+.iframe https://example.com/
+.unknown
+`
+
+const testTalkCode = `package main
+
+func main() {
+	go f() // HLgo
+	select {} // OMIT
+}
+`
+
+func TestParseTalk(t *testing.T) {
+	d, err := parseTalk("talk.slide", map[string][]byte{
+		"talk.slide": []byte(testTalk),
+		"main.go":    []byte(testTalkCode),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Title != "Go Concurrency Patterns" || d.Subtitle != "Concurrency is not parallelism" || d.Date != "15 Jul 2012" || strings.Join(d.Tags, ",") != "go,concurrency" {
+		t.Errorf("header = %q, %q, %q, %q", d.Title, d.Subtitle, d.Date, d.Tags)
+	}
+	wantAuthor := template.HTML(`Rob Pike<br>Google<br><a href="https://golang.org" rel="nofollow">https://golang.org</a><br><a href="mailto:r@golang.org">r@golang.org</a>`)
+	if len(d.Authors) != 1 || d.Authors[0] != wantAuthor {
+		t.Errorf("authors = %q, want %q", d.Authors, wantAuthor)
+	}
+	if len(d.Sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(d.Sections))
+	}
+	if s := d.Sections[1]; s.Number != "1.1" || s.Level != 2 || s.Title != "Details" {
+		t.Errorf("section = %+v", s)
+	}
+
+	for i, tt := range []struct {
+		section int
+		want    string
+	}{
+		{0, `<p>Goroutines are <b>cheap</b>, see <a href="https://golang.org/doc" rel="nofollow">the docs</a> and <i>Effective Go</i>. The <code>go</code> statement starts one.</p>`},
+		{0, `<ul><li>First</li><li>Second continued</li></ul>`},
+		{0, "<pre>func main() {\n\tgo f()\n}</pre>"},
+		{0, "<div class=\"x-code\"><pre>func main() {\n\tgo f()\n}</pre></div>"},
+		{0, `<div class="x-image"><img src="https://example.com/gopher.png" height="100"></div>`},
+		{0, `<p class="text-danger">ERROR: bad URL</p>`},
+		{1, "<div class=\"x-code\"><pre>package main\n\nfunc main() {\n\tgo f()\n}</pre></div>"},
+		{1, `<p class="text-danger">ERROR: frames not supported</p><p class="text-danger">ERROR: unknown command .unknown</p>`},
+	} {
+		if body := string(d.Sections[tt.section].Body); !strings.Contains(body, tt.want) {
+			t.Errorf("%d: section %d body %q does not contain %q", i, tt.section, body, tt.want)
+		}
+	}
+
+	defer func(secret string) { *imageProxySecret = secret }(*imageProxySecret)
+	*imageProxySecret = "secret"
+	d, err = parseTalk("talk.slide", map[string][]byte{"talk.slide": []byte("Title\n\n* Image\n\n.image https://example.com/gopher.png\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := html.EscapeString(proxyImageURL("https://example.com/gopher.png")); !strings.Contains(string(d.Sections[0].Body), `<img src="`+want+`">`) {
+		t.Errorf("image body %q does not contain the proxied URL %q", d.Sections[0].Body, want)
+	}
+
+	if _, err := parseTalk("talk.slide", map[string][]byte{"talk.slide": []byte("\n\n")}); err == nil {
+		t.Error("parseTalk(empty) returned no error")
+	}
+}
+
+func TestTalkCode(t *testing.T) {
+	const data = "a\nb, c\nd\ne OMIT\nf\n"
+	for _, tt := range []struct {
+		addr, want string
+	}{
+		{"", "a\nb, c\nd\nf"},
+		{"2", "b, c"},
+		{"2,$", "b, c\nd\nf"},
+		{"/b, c/", "b, c"},
+		{"/^b/,/f/", "b, c\nd\nf"},
+	} {
+		if got, err := talkCode(data, tt.addr); err != nil || got != tt.want {
+			t.Errorf("talkCode(%q) = %q, %v, want %q", tt.addr, got, err, tt.want)
+		}
+	}
+	for _, addr := range []string{"9", "/x/", "/b", "3,2"} {
+		if _, err := talkCode(data, addr); err == nil {
+			t.Errorf("talkCode(%q) returned no error", addr)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+var (
+	credentialsFile = flag.String("credentials", "", "File with the tokens and SSH keys used to fetch private repositories. Each line has the form: host token <token> or host ssh-key <path>.")
+	authHeader      = flag.String("auth_header", "", "Request header set by an authenticating proxy to the name of the signed in user. Private packages are only served to requests with the header or a valid API token.")
+)
+
+// readCredentials returns the credentials in the file.
+func readCredentials(fname string) (map[string]*gosrc.Credentials, error) {
+	if fname == "" {
+		return nil, nil
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return gosrc.ReadCredentials(f)
+}
+
+// isAuthenticated returns true if the request is from a user allowed to view
+// private packages.
+func isAuthenticated(req *http.Request) bool {
+	return requestRole(req) >= roleViewer
+}
+
+// canView returns true if the request is allowed to view pdoc.
+func canView(req *http.Request, pdoc *doc.Package) bool {
+	return pdoc == nil || !pdoc.Private || isAuthenticated(req)
+}
+
+// filterPrivate removes the packages in private repositories from pkgs if the
+// request is not authenticated.
+func filterPrivate(req *http.Request, pkgs []database.Package) ([]database.Package, error) {
+	if isAuthenticated(req) {
+		return pkgs, nil
+	}
+	return db.FilterPrivate(pkgs)
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/golang/gddo/doc"
+)
+
+type memberLink struct {
+	Name string
+	URL  string
+}
+
+// promotedMembers are the fields and methods promoted from a type embedded
+// in a struct.
+type promotedMembers struct {
+	From    memberLink
+	Fields  []memberLink
+	Methods []memberLink
+}
+
+// promoted returns the promoted members of the struct types in pdoc keyed
+// by type name. The members of embedded types declared in other packages
+// are found in the stored documentation of the packages. Methods promoted
+// from types declared in pdoc are not included because the methods are
+// listed with the methods of the struct. Members shadowed by fields or
+// methods of the struct are not included.
+func promoted(pdoc *doc.Package) map[string][]*promotedMembers {
+	pdocs := map[string]*doc.Package{"": pdoc}
+	getDoc := func(importPath string) *doc.Package {
+		if p, ok := pdocs[importPath]; ok {
+			return p
+		}
+		p, _, _, err := db.Get(importPath)
+		if err != nil {
+			slog.Error("db.Get", "path", importPath, "err", err)
+		}
+		pdocs[importPath] = p
+		return p
+	}
+
+	result := make(map[string][]*promotedMembers)
+	for _, t := range pdoc.Types {
+		shadowed := make(map[string]bool)
+		for _, name := range t.Fields {
+			shadowed[name] = true
+		}
+		for _, m := range t.Methods {
+			shadowed[m.Name] = true
+		}
+		for _, e := range t.Embedded {
+			shadowed[e.Name] = true
+		}
+
+		for _, e := range t.Embedded {
+			epdoc := getDoc(e.ImportPath)
+			if epdoc == nil || (epdoc.Private && epdoc != pdoc) {
+				continue
+			}
+			var et *doc.Type
+			for _, candidate := range epdoc.Types {
+				if candidate.Name == e.Name {
+					et = candidate
+				}
+			}
+			if et == nil {
+				continue
+			}
+			base := ""
+			name := e.Name
+			if e.ImportPath != "" {
+				base = "/" + e.ImportPath
+				name = e.Package + "." + e.Name
+			}
+			pm := &promotedMembers{From: memberLink{Name: name, URL: base + "#" + e.Name}}
+			for _, f := range et.Fields {
+				if !shadowed[f] {
+					pm.Fields = append(pm.Fields, memberLink{Name: f, URL: base + "#" + e.Name})
+				}
+			}
+			if e.ImportPath != "" {
+				for _, m := range et.Methods {
+					if !shadowed[m.Name] {
+						pm.Methods = append(pm.Methods, memberLink{Name: m.Name, URL: base + "#" + e.Name + "." + m.Name})
+					}
+				}
+			}
+			if len(pm.Fields) > 0 || len(pm.Methods) > 0 {
+				result[t.Name] = append(result[t.Name], pm)
+			}
+		}
+	}
+	return result
+}
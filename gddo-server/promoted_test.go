@@ -0,0 +1,38 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestPromoted(t *testing.T) {
+	pdoc := &doc.Package{
+		ImportPath: "example.com/p",
+		Types: []*doc.Type{
+			{Name: "Base", Fields: []string{"ID", "Name"}, Methods: []*doc.Func{{Name: "Close"}}},
+			{
+				Name:     "T",
+				Fields:   []string{"Name"},
+				Embedded: []*doc.Embedded{{TypeRef: doc.TypeRef{Name: "Base"}}},
+			},
+		},
+	}
+	got := promoted(pdoc)
+	want := map[string][]*promotedMembers{
+		"T": {{
+			From:   memberLink{Name: "Base", URL: "#Base"},
+			Fields: []memberLink{{Name: "ID", URL: "#Base"}},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("promoted = %+v, want %+v", got["T"], want["T"])
+	}
+}
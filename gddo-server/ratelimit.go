@@ -0,0 +1,133 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements per-client rate limits on the requests that are
+// expensive to serve.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/httputil"
+)
+
+var (
+	searchRate  = flag.Float64("rate_limit_search", 60, "Searches per minute allowed for each client. Zero disables the limit.")
+	refreshRate = flag.Float64("rate_limit_refresh", 6, "Refreshes per minute allowed for each client. Zero disables the limit.")
+	crawlRate   = flag.Float64("rate_limit_crawl", 20, "On-demand crawls per minute allowed for each client. Zero disables the limit.")
+	reportRate  = flag.Float64("rate_limit_report", 2, "Abuse reports per minute allowed for each client. Zero disables the limit.")
+)
+
+// rateSweepInterval is the time between removals of the buckets of idle
+// clients.
+const rateSweepInterval = time.Minute
+
+// tokenBucket is the state of the limit for one client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket rate limit for each client. A client can
+// burst up to one minute of requests.
+type rateLimiter struct {
+	perMinute *float64
+
+	mu      sync.Mutex
+	clients map[string]*tokenBucket
+	swept   time.Time
+}
+
+func newRateLimiter(perMinute *float64) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, clients: make(map[string]*tokenBucket)}
+}
+
+var (
+	searchLimiter  = newRateLimiter(searchRate)
+	refreshLimiter = newRateLimiter(refreshRate)
+	crawlLimiter   = newRateLimiter(crawlRate)
+	reportLimiter  = newRateLimiter(reportRate)
+)
+
+// allow takes a token from the bucket of the client at time now. If the
+// bucket is empty, allow returns false and the time until the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	rate := *l.perMinute / 60
+	if rate <= 0 {
+		return true, 0
+	}
+	burst := math.Max(1, *l.perMinute)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) > rateSweepInterval {
+		for c, b := range l.clients {
+			if b.tokens+now.Sub(b.last).Seconds()*rate >= burst {
+				delete(l.clients, c)
+			}
+		}
+		l.swept = now
+	}
+
+	b := l.clients[client]
+	if b == nil {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+type clientKey struct{}
+
+// withClient returns a copy of ctx with the rate limit client of the request.
+// The client is the API token or the hash of the API key of the request or
+// the IP address of the remote host.
+func withClient(ctx context.Context, req *http.Request) context.Context {
+	client := "ip:" + httputil.StripPort(req.RemoteAddr)
+	if validAPIToken(req) {
+		client = "token:" + bearerToken(req)
+	} else if key := bearerToken(req); strings.HasPrefix(key, database.APIKeyPrefix) {
+		if k, err := db.APIKey(key); err == nil && k != nil {
+			client = "key:" + database.APIKeyID(key)
+		}
+	}
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// checkRate returns an error with status 429 if the client of ctx exceeds
+// the limit. Requests without a client, such as background crawls, are not
+// limited.
+func checkRate(ctx context.Context, l *rateLimiter) error {
+	client, _ := ctx.Value(clientKey{}).(string)
+	if client == "" {
+		return nil
+	}
+	ok, wait := l.allow(client, time.Now())
+	if ok {
+		return nil
+	}
+	secs := int(math.Ceil(wait.Seconds()))
+	return &httpError{
+		status: http.StatusTooManyRequests,
+		header: http.Header{"Retry-After": {strconv.Itoa(secs)}},
+	}
+}
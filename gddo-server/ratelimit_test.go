@@ -0,0 +1,78 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	perMinute := 3.0
+	l := newRateLimiter(&perMinute)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d in burst denied", i)
+		}
+	}
+	ok, wait := l.allow("a", now)
+	if ok || wait != 20*time.Second {
+		t.Errorf("request after burst = %v, %v; want false, 20s", ok, wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("other client denied")
+	}
+	if ok, _ := l.allow("a", now.Add(20*time.Second)); !ok {
+		t.Error("request after refill denied")
+	}
+
+	// Idle clients with full buckets are removed.
+	l.allow("a", now.Add(10*time.Minute))
+	if _, found := l.clients["b"]; found {
+		t.Error("idle client not removed")
+	}
+
+	perMinute = 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatal("request denied with limit disabled")
+		}
+	}
+}
+
+func TestCheckRate(t *testing.T) {
+	perMinute := 1.0
+	l := newRateLimiter(&perMinute)
+	h := apiHandler(func(resp http.ResponseWriter, req *http.Request) error {
+		return checkRate(req.Context(), l)
+	})
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/search", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("first request status %d, want 200", w.Code)
+	}
+	w := serve("192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	if w := serve("192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other client status %d, want 200", w.Code)
+	}
+}
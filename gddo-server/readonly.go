@@ -0,0 +1,16 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"errors"
+	"flag"
+)
+
+var readOnly = flag.Bool("read_only", false, "Serve only the documentation in the database. Crawling, refreshing, owner actions, view and API key counting and the background tasks that write to the database are disabled.")
+
+var errReadOnly = errors.New("read-only mode")
@@ -0,0 +1,51 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadOnly(t *testing.T) {
+	defer func(r bool) { *readOnly = r }(*readOnly)
+	*readOnly = true
+
+	ctx := context.Background()
+	if err := refreshDoc(ctx, "github.com/user/repo"); err != errReadOnly {
+		t.Errorf("refreshDoc() = %v, want %v", err, errReadOnly)
+	}
+
+	// The database is not used in read-only mode.
+	countView(ctx, "github.com/user/repo")
+	req := httptest.NewRequest("GET", "/github.com/user/repo", nil)
+	if isRobot(req) {
+		t.Error("isRobot() = true for browser")
+	}
+}
+
+func TestBackgroundTaskEnabled(t *testing.T) {
+	defer func(r bool) { *readOnly = r }(*readOnly)
+	d := time.Minute
+	for _, tt := range []struct {
+		readOnly bool
+		task     backgroundTask
+		want     bool
+	}{
+		{false, backgroundTask{interval: new(time.Duration)}, false},
+		{false, backgroundTask{interval: &d}, true},
+		{true, backgroundTask{interval: &d}, false},
+		{true, backgroundTask{interval: &d, readsOnly: true}, true},
+	} {
+		*readOnly = tt.readOnly
+		if got := tt.task.enabled(); got != tt.want {
+			t.Errorf("read only %v, interval %v, reads only %v: enabled() = %v, want %v", tt.readOnly, *tt.task.interval, tt.task.readsOnly, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/gddo/database"
+)
+
+func updateReport() error {
+	return db.UpdateReport()
+}
+
+// serveReport serves the statistics about the instance as JSON to requests
+// with an API token. The statistics are computed by the background task or on
+// each request before the task first runs.
+func serveReport(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkRole(req, roleViewer); err != nil {
+		return err
+	}
+	r, err := db.Report()
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		if r, err = db.NewReport(); err != nil {
+			return err
+		}
+	}
+	data := struct {
+		Packages int `json:"packages"`
+		*database.Report
+	}{r.Packages(), r}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	resp.Header().Set("Cache-Control", "private")
+	return json.NewEncoder(resp).Encode(&data)
+}
@@ -0,0 +1,21 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeReportUnauthorized(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://godoc.org/-/stats", nil)
+	err := serveReport(httptest.NewRecorder(), req)
+	if e, ok := err.(*httpError); !ok || e.status != http.StatusUnauthorized {
+		t.Errorf("err = %v, want status %d", err, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Robots are asked by robots.txt to skip the views that are expensive to
+// serve. Requests from robots are served the documents in the database and
+// do not crawl packages unless -robot_crawl is set. The -robot_rate_limits
+// flag throttles the robots by user agent, for all the hosts the robot
+// crawls from.
+
+var (
+	robotsDisallow   = flag.String("robots_disallow", "", "Comma separated list of paths disallowed for all robots in robots.txt in addition to the expensive views.")
+	robotsCrawlDelay = flag.Int("robots_crawl_delay", 0, "Seconds between requests asked from robots in robots.txt. Zero omits the Crawl-delay line.")
+	robotCrawl       = flag.Bool("robot_crawl", false, "Crawl the packages requested by robots. If false, robots are served the documents in the database only.")
+	robotRateLimits  = flag.String("robot_rate_limits", "", "Comma separated list of throttles, each as agent=N. Robots with the string agent in the user agent are allowed N requests per minute in total.")
+)
+
+// robotsDisallowed are the paths of the views that fetch sources, render
+// graphs or query the database at length.
+var robotsDisallowed = []string{
+	"/*?imports",
+	"/*?importers",
+	"/*?import-graph*",
+	"/*?gosrc*",
+	"/*?file*",
+	"/*?play*",
+	"/*?tools",
+	"/*?recheck",
+	"/changes/",
+	"/-/archive",
+	"/-/events",
+	"/-/refresh",
+	"/-/suggest",
+}
+
+// robotsTxt returns the robots.txt file of the site.
+func robotsTxt() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\n")
+	for _, p := range robotsDisallowed {
+		fmt.Fprintf(&buf, "Disallow: %s\n", p)
+	}
+	for _, p := range strings.Split(*robotsDisallow, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			fmt.Fprintf(&buf, "Disallow: %s\n", p)
+		}
+	}
+	if *robotsCrawlDelay > 0 {
+		fmt.Fprintf(&buf, "Crawl-delay: %d\n", *robotsCrawlDelay)
+	}
+	return buf.Bytes()
+}
+
+func serveRobots(resp http.ResponseWriter, req *http.Request) error {
+	resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	resp.Header().Set("Cache-Control", "public, max-age=3600")
+	_, err := resp.Write(robotsTxt())
+	return err
+}
+
+// robotThrottle is the rate limit of the robots with agent in the user agent.
+type robotThrottle struct {
+	agent     string
+	perMinute float64
+	limiter   *rateLimiter
+}
+
+var (
+	robotThrottlesMu sync.Mutex
+	robotThrottles   []*robotThrottle
+)
+
+var errBadRobotRateLimit = errors.New("robot_rate_limits: want agent=N")
+
+// setRobotRateLimits sets the throttles of the -robot_rate_limits flag.
+func setRobotRateLimits(spec string) error {
+	var throttles []*robotThrottle
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		i := strings.LastIndex(f, "=")
+		if i <= 0 {
+			return errBadRobotRateLimit
+		}
+		n, err := strconv.ParseFloat(f[i+1:], 64)
+		if err != nil || n < 0 {
+			return errBadRobotRateLimit
+		}
+		t := &robotThrottle{agent: strings.ToLower(f[:i]), perMinute: n}
+		t.limiter = newRateLimiter(&t.perMinute)
+		throttles = append(throttles, t)
+	}
+	robotThrottlesMu.Lock()
+	robotThrottles = throttles
+	robotThrottlesMu.Unlock()
+	return nil
+}
+
+// checkRobotRate returns an error with status 429 if the request is from a
+// robot over the throttle of its user agent.
+func checkRobotRate(req *http.Request) error {
+	ua := strings.ToLower(req.Header.Get("User-Agent"))
+	if ua == "" {
+		return nil
+	}
+	robotThrottlesMu.Lock()
+	throttles := robotThrottles
+	robotThrottlesMu.Unlock()
+	for _, t := range throttles {
+		if strings.Contains(ua, t.agent) {
+			ok, wait := t.limiter.allow(t.agent, time.Now())
+			if ok {
+				return nil
+			}
+			return &httpError{
+				status: http.StatusTooManyRequests,
+				header: http.Header{"Retry-After": {strconv.Itoa(int(math.Ceil(wait.Seconds())))}},
+			}
+		}
+	}
+	return nil
+}
+
+// robotRequestType returns the type of the requests for documents by robots.
+func robotRequestType() int {
+	if *robotCrawl {
+		return robotRequest
+	}
+	return cachedRequest
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRobotsTxt(t *testing.T) {
+	defer func(disallow string, delay int) {
+		*robotsDisallow, *robotsCrawlDelay = disallow, delay
+	}(*robotsDisallow, *robotsCrawlDelay)
+	*robotsDisallow = " /-/stats, ,/api/"
+	*robotsCrawlDelay = 5
+
+	s := string(robotsTxt())
+	if !strings.HasPrefix(s, "User-agent: *\n") {
+		t.Errorf("robots.txt does not start with User-agent:\n%s", s)
+	}
+	for _, line := range []string{"Disallow: /*?importers\n", "Disallow: /-/refresh\n", "Disallow: /-/stats\n", "Disallow: /api/\n", "Crawl-delay: 5\n"} {
+		if !strings.Contains(s, line) {
+			t.Errorf("robots.txt does not contain %q:\n%s", line, s)
+		}
+	}
+	if strings.Contains(s, "Disallow: \n") {
+		t.Errorf("robots.txt contains an empty Disallow line:\n%s", s)
+	}
+}
+
+func TestRobotRateLimits(t *testing.T) {
+	defer setRobotRateLimits("")
+	for _, spec := range []string{"Googlebot", "=5", "Googlebot=x", "Googlebot=-1"} {
+		if err := setRobotRateLimits(spec); err == nil {
+			t.Errorf("setRobotRateLimits(%q) returned no error", spec)
+		}
+	}
+	if err := setRobotRateLimits("Googlebot=2, bingbot=0"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := func(ua string) *http.Request {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("User-Agent", ua)
+		return r
+	}
+	const google = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	for i := 0; i < 2; i++ {
+		if err := checkRobotRate(req(google)); err != nil {
+			t.Fatalf("request %d denied: %v", i, err)
+		}
+	}
+	err := checkRobotRate(req(google))
+	if e, ok := err.(*httpError); !ok || e.status != http.StatusTooManyRequests || e.header.Get("Retry-After") == "" {
+		t.Errorf("request over the limit returned %v, want status 429 with Retry-After", err)
+	}
+	for _, ua := range []string{"Mozilla/5.0 (compatible; bingbot/2.0)", "Mozilla/5.0", ""} {
+		if err := checkRobotRate(req(ua)); err != nil {
+			t.Errorf("request from %q denied: %v", ua, err)
+		}
+	}
+}
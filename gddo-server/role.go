@@ -0,0 +1,101 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"net/http"
+)
+
+// The roles of the users of the administrative endpoints. Each role has the
+// permissions of the roles before it:
+//
+//	viewer: view private packages, the audit log, the statistics, the
+//	        blocklist, the moderation queue and the background tasks.
+//	operator: trigger crawls, dismiss abuse reports and pause or resume
+//	        background tasks.
+//	admin: block and unblock packages and delete packages.
+//
+// A request has the role of the token in the -api_tokens file, of the groups
+// of the signed in user or of the -auth_header header, whichever is highest.
+type role int
+
+const (
+	roleNone role = iota
+	roleViewer
+	roleOperator
+	roleAdmin
+)
+
+var roleNames = []string{"none", "viewer", "operator", "admin"}
+
+func (r role) String() string {
+	return roleNames[r]
+}
+
+// parseRole returns the role with the name.
+func parseRole(name string) (role, bool) {
+	for i, n := range roleNames {
+		if n == name {
+			return role(i), true
+		}
+	}
+	return roleNone, false
+}
+
+var (
+	authOperatorGroups = flag.String("auth_operator_groups", "", "Comma separated list of the groups of the signed in users with the operator role. Empty gives the role to no user.")
+	authAdminGroups    = flag.String("auth_admin_groups", "", "Comma separated list of the groups of the signed in users with the admin role. Empty gives the role to no user.")
+	authHeaderRole     = flag.String("auth_header_role", "viewer", "Role of the requests with the auth_header header: viewer, operator or admin. Give the operator and admin roles only if the proxy setting the header removes it from the requests of clients.")
+)
+
+// sessionRole returns the role of the signed in user.
+func sessionRole(u *authUser) role {
+	switch {
+	case u == nil:
+		return roleNone
+	case *authAdminGroups != "" && u.inGroups(*authAdminGroups):
+		return roleAdmin
+	case *authOperatorGroups != "" && u.inGroups(*authOperatorGroups):
+		return roleOperator
+	case u.inGroups(*authPrivateGroups):
+		return roleViewer
+	}
+	return roleNone
+}
+
+// requestRole returns the role of the request.
+func requestRole(req *http.Request) role {
+	r := roleNone
+	if *authHeader != "" && req.Header.Get(*authHeader) != "" {
+		configMu.RLock()
+		r, _ = parseRole(*authHeaderRole)
+		configMu.RUnlock()
+	}
+	if t := requestToken(req); t != nil && t.role > r {
+		r = t.role
+	}
+	if s := sessionRole(authSession(req)); s > r {
+		r = s
+	}
+	return r
+}
+
+// checkRole returns an error with status 401 for requests without a role and
+// status 403 for requests with a role lower than r.
+func checkRole(req *http.Request, r role) error {
+	switch have := requestRole(req); {
+	case have == roleNone:
+		return &httpError{
+			status: http.StatusUnauthorized,
+			header: http.Header{"Www-Authenticate": {`Bearer realm="api"`}},
+		}
+	case have < r:
+		return &httpError{status: http.StatusForbidden}
+	}
+	return nil
+}
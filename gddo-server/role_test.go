@@ -0,0 +1,136 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func setTestTokens(t *testing.T, content string) {
+	t.Helper()
+	fname := filepath.Join(t.TempDir(), "tokens")
+	if err := ioutil.WriteFile(fname, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := readAPITokens(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := apiTokens
+	t.Cleanup(func() { apiTokens = old })
+	apiTokens = tokens
+}
+
+func TestReadAPITokens(t *testing.T) {
+	setTestTokens(t, "# comment\nplain\nroot admin\nops operator CI deploy\n\n")
+	want := []apiToken{
+		{"plain", roleViewer, "api"},
+		{"root", roleAdmin, "api"},
+		{"ops", roleOperator, "CI deploy"},
+	}
+	if len(apiTokens) != len(want) {
+		t.Fatalf("apiTokens = %v, want %v", apiTokens, want)
+	}
+	for i := range want {
+		if apiTokens[i] != want[i] {
+			t.Errorf("apiTokens[%d] = %v, want %v", i, apiTokens[i], want[i])
+		}
+	}
+
+	fname := filepath.Join(t.TempDir(), "bad")
+	ioutil.WriteFile(fname, []byte("token owner\n"), 0600)
+	if _, err := readAPITokens(fname); err == nil {
+		t.Error("readAPITokens(unknown role) returned no error")
+	}
+}
+
+func TestRequestRole(t *testing.T) {
+	setTestTokens(t, "view viewer\nops operator\n")
+	setAuthFlags(t, "github")
+	defer func(h, op, admin string) { *authHeader, *authOperatorGroups, *authAdminGroups = h, op, admin }(*authHeader, *authOperatorGroups, *authAdminGroups)
+	*authHeader = "X-User"
+	*authOperatorGroups = "sre"
+	*authAdminGroups = "leads"
+	*authPrivateGroups = "eng"
+
+	session := func(groups ...string) *http.Cookie {
+		w := httptest.NewRecorder()
+		setAuthSession(w, httptest.NewRequest("GET", "http://godoc.org/", nil), &authUser{Name: "gopher", Groups: groups})
+		return w.Result().Cookies()[0]
+	}
+	for _, tt := range []struct {
+		name   string
+		token  string
+		header string
+		cookie *http.Cookie
+		want   role
+	}{
+		{name: "none", want: roleNone},
+		{name: "bad token", token: "bad", want: roleNone},
+		{name: "viewer token", token: "view", want: roleViewer},
+		{name: "operator token", token: "ops", want: roleOperator},
+		{name: "header", header: "gopher", want: roleViewer},
+		{name: "other group", cookie: session("docs"), want: roleNone},
+		{name: "private group", cookie: session("eng"), want: roleViewer},
+		{name: "operator group", cookie: session("eng", "sre"), want: roleOperator},
+		{name: "admin group", cookie: session("leads"), want: roleAdmin},
+		{name: "highest role", token: "view", cookie: session("sre"), want: roleOperator},
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/-/admin/tasks", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if tt.header != "" {
+			req.Header.Set("X-User", tt.header)
+		}
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		if got := requestRole(req); got != tt.want {
+			t.Errorf("%s: requestRole() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRole(t *testing.T) {
+	setTestTokens(t, "view viewer\n")
+	req := httptest.NewRequest("GET", "http://godoc.org/-/admin/tasks", nil)
+	if err, ok := checkRole(req, roleViewer).(*httpError); !ok || err.status != http.StatusUnauthorized {
+		t.Errorf("checkRole(no token) = %v, want status 401", err)
+	}
+	req.Header.Set("Authorization", "Bearer view")
+	if err := checkRole(req, roleViewer); err != nil {
+		t.Errorf("checkRole(viewer) = %v", err)
+	}
+	if err, ok := checkRole(req, roleOperator).(*httpError); !ok || err.status != http.StatusForbidden {
+		t.Errorf("checkRole(operator) = %v, want status 403", err)
+	}
+}
+
+func TestCheckAPIToken(t *testing.T) {
+	setTestTokens(t, "")
+	req := httptest.NewRequest("POST", "http://api.godoc.org/refresh", nil)
+	if checkAPIToken(req) {
+		t.Error("checkAPIToken() without tokens = true, want false")
+	}
+	setTestTokens(t, "# comment\n")
+	if checkAPIToken(req) {
+		t.Error("checkAPIToken() with only comments = true, want false")
+	}
+	setTestTokens(t, "view\n")
+	if checkAPIToken(req) {
+		t.Error("checkAPIToken(no token) = true, want false")
+	}
+	req.Header.Set("Authorization", "Bearer view")
+	if !checkAPIToken(req) {
+		t.Error("checkAPIToken(token) = false, want true")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/gddo/gosrc"
+)
+
+var (
+	scannerSpec    = flag.String("scanner", "", "Scan fetched files for malicious content with exec:<command>, clamd:<network>:<address> or icap://<host>/<service>. Empty disables scanning.")
+	scannerTimeout = flag.Duration("scanner_timeout", 30*time.Second, "Time to wait for the scanner.")
+)
+
+// newScanner returns the scanner for the given -scanner flag value.
+func newScanner(spec string) (gosrc.Scanner, error) {
+	switch {
+	case strings.HasPrefix(spec, "exec:"):
+		args := strings.Fields(spec[len("exec:"):])
+		if len(args) == 0 {
+			return nil, errors.New("scanner: missing command")
+		}
+		return &execScanner{args: args}, nil
+	case strings.HasPrefix(spec, "clamd:"):
+		parts := strings.SplitN(spec[len("clamd:"):], ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("scanner: bad clamd address %q", spec)
+		}
+		return &clamdScanner{network: parts[0], address: parts[1]}, nil
+	case strings.HasPrefix(spec, "icap://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := net.SplitHostPort(u.Host); err != nil {
+			u.Host += ":1344"
+		}
+		return &icapScanner{url: u}, nil
+	}
+	return nil, fmt.Errorf("scanner: unknown scanner %q", spec)
+}
+
+// execScanner runs a command with the file on stdin. Following the convention
+// of clamscan, exit status 1 reports a threat described by the output of the
+// command.
+type execScanner struct {
+	args []string
+}
+
+func (s *execScanner) Scan(name string, data []byte) (string, error) {
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	t := time.AfterFunc(*scannerTimeout, func() { cmd.Process.Kill() })
+	err := cmd.Wait()
+	t.Stop()
+	if e, ok := err.(*exec.ExitError); ok && e.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+		threat := strings.TrimSpace(out.String())
+		if threat == "" {
+			threat = "flagged by " + s.args[0]
+		}
+		return threat, nil
+	}
+	return "", err
+}
+
+// clamdScanner sends the file to clamd with the INSTREAM command.
+type clamdScanner struct {
+	network, address string
+}
+
+func (s *clamdScanner) Scan(name string, data []byte) (string, error) {
+	c, err := net.DialTimeout(s.network, s.address, *scannerTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(*scannerTimeout))
+
+	w := bufio.NewWriter(c)
+	w.WriteString("zINSTREAM\x00")
+	const chunkSize = 1 << 16
+	for p := data; ; {
+		n := len(p)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		binary.Write(w, binary.BigEndian, uint32(n))
+		if n == 0 {
+			break
+		}
+		w.Write(p[:n])
+		p = p[n:]
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(c).ReadString(0)
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), "\x00"))
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	}
+	return "", fmt.Errorf("clamd: %s", reply)
+}
+
+// icapScanner sends the file to an ICAP server as the body of a RESPMOD
+// request. A 204 response means the file is clean.
+type icapScanner struct {
+	url *url.URL
+}
+
+func (s *icapScanner) Scan(name string, data []byte) (string, error) {
+	c, err := net.DialTimeout("tcp", s.url.Host, *scannerTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(*scannerTimeout))
+
+	resHdr := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Length: %d\r\n\r\n", len(data))
+	w := bufio.NewWriter(c)
+	fmt.Fprintf(w, "RESPMOD %s ICAP/1.0\r\n", s.url)
+	fmt.Fprintf(w, "Host: %s\r\n", s.url.Host)
+	fmt.Fprintf(w, "Allow: 204\r\n")
+	fmt.Fprintf(w, "Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(resHdr))
+	w.WriteString(resHdr)
+	if len(data) > 0 {
+		fmt.Fprintf(w, "%x\r\n", len(data))
+		w.Write(data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(c))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return "", err
+	}
+	f := strings.Fields(line)
+	if len(f) < 2 || !strings.HasPrefix(f[0], "ICAP/") {
+		return "", fmt.Errorf("icap: bad response %q", line)
+	}
+	switch f[1] {
+	case "204":
+		return "", nil
+	case "200":
+		for _, k := range []string{"X-Infection-Found", "X-Violations-Found", "X-Virus-Id"} {
+			if v := header.Get(k); v != "" {
+				return v, nil
+			}
+		}
+		return "flagged by ICAP server", nil
+	}
+	return "", fmt.Errorf("icap: %s", line)
+}
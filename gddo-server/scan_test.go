@@ -0,0 +1,107 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"testing"
+)
+
+// serveOne accepts one connection on l and handles it with fn.
+func serveOne(t *testing.T, l net.Listener, fn func(c net.Conn)) {
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close()
+		fn(c)
+	}()
+}
+
+func TestClamdScanner(t *testing.T) {
+	for _, tt := range []struct {
+		reply, threat string
+	}{
+		{"stream: OK\x00", ""},
+		{"stream: Eicar-Test-Signature FOUND\x00", "Eicar-Test-Signature"},
+	} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		serveOne(t, l, func(c net.Conn) {
+			r := bufio.NewReader(c)
+			if cmd, _ := r.ReadString(0); cmd != "zINSTREAM\x00" {
+				t.Errorf("command = %q", cmd)
+			}
+			for {
+				var n uint32
+				if err := binary.Read(r, binary.BigEndian, &n); err != nil || n == 0 {
+					break
+				}
+				io.CopyN(&got, r, int64(n))
+			}
+			io.WriteString(c, tt.reply)
+		})
+		s := &clamdScanner{network: "tcp", address: l.Addr().String()}
+		threat, err := s.Scan("x.go", []byte("package x"))
+		l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if threat != tt.threat {
+			t.Errorf("Scan() = %q, want %q", threat, tt.threat)
+		}
+		if got.String() != "package x" {
+			t.Errorf("clamd got %q, want %q", got.String(), "package x")
+		}
+	}
+}
+
+func TestICAPScanner(t *testing.T) {
+	for _, tt := range []struct {
+		reply, threat string
+	}{
+		{"ICAP/1.0 204 No Content\r\n\r\n", ""},
+		{"ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=Eicar;\r\n\r\n", "Type=0; Resolution=2; Threat=Eicar;"},
+	} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		serveOne(t, l, func(c net.Conn) {
+			tp := textproto.NewReader(bufio.NewReader(c))
+			if line, _ := tp.ReadLine(); line != "RESPMOD icap://"+l.Addr().String()+"/avscan ICAP/1.0" {
+				t.Errorf("request line = %q", line)
+			}
+			tp.ReadMIMEHeader()
+			io.WriteString(c, tt.reply)
+			io.Copy(ioutil.Discard, tp.R)
+		})
+		s, err := newScanner("icap://" + l.Addr().String() + "/avscan")
+		if err != nil {
+			t.Fatal(err)
+		}
+		threat, err := s.Scan("x.go", []byte("package x"))
+		l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if threat != tt.threat {
+			t.Errorf("Scan() = %q, want %q", threat, tt.threat)
+		}
+	}
+}
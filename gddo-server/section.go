@@ -0,0 +1,101 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"flag"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/gddo/doc"
+)
+
+// The package pages of packages with many declarations show one section of
+// the declarations per page. A section has the declarations with names
+// starting with the same letter. The declarations of a type, including its
+// methods and the constructors grouped with it, are in the section of the
+// type.
+
+var indexPageSize = flag.Int("index_page_size", 1000, "Show the declarations of packages with more declarations than this one section per page, by the first letter of the names. Zero disables the sections.")
+
+// declSection returns the section of the declaration with the name.
+func declSection(name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r))
+}
+
+func valueSection(v *doc.Value) string {
+	if len(v.Names) == 0 {
+		return ""
+	}
+	return declSection(v.Names[0])
+}
+
+// docSections returns the sorted sections of the declarations of a package
+// with more than -index_page_size declarations or nil if the declarations of
+// the package are shown on one page.
+func docSections(pdoc *doc.Package) []string {
+	if *indexPageSize <= 0 || pdoc.Name == "" || pdoc.IsCmd {
+		return nil
+	}
+	n := len(pdoc.Consts) + len(pdoc.Vars) + len(pdoc.Funcs) + len(pdoc.Types)
+	for _, t := range pdoc.Types {
+		n += len(t.Consts) + len(t.Vars) + len(t.Funcs) + len(t.Methods)
+	}
+	if n <= *indexPageSize {
+		return nil
+	}
+	m := make(map[string]bool)
+	for _, v := range pdoc.Consts {
+		m[valueSection(v)] = true
+	}
+	for _, v := range pdoc.Vars {
+		m[valueSection(v)] = true
+	}
+	for _, f := range pdoc.Funcs {
+		m[declSection(f.Name)] = true
+	}
+	for _, t := range pdoc.Types {
+		m[declSection(t.Name)] = true
+	}
+	var sections []string
+	for s := range m {
+		sections = append(sections, s)
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// sectionDoc returns a copy of pdoc with the declarations in the section.
+func sectionDoc(pdoc *doc.Package, section string) *doc.Package {
+	sdoc := *pdoc
+	filterValues := func(values []*doc.Value) []*doc.Value {
+		var result []*doc.Value
+		for _, v := range values {
+			if valueSection(v) == section {
+				result = append(result, v)
+			}
+		}
+		return result
+	}
+	sdoc.Consts = filterValues(pdoc.Consts)
+	sdoc.Vars = filterValues(pdoc.Vars)
+	sdoc.Funcs = nil
+	for _, f := range pdoc.Funcs {
+		if declSection(f.Name) == section {
+			sdoc.Funcs = append(sdoc.Funcs, f)
+		}
+	}
+	sdoc.Types = nil
+	for _, t := range pdoc.Types {
+		if declSection(t.Name) == section {
+			sdoc.Types = append(sdoc.Types, t)
+		}
+	}
+	return &sdoc
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestDocSections(t *testing.T) {
+	defer func(n int) { *indexPageSize = n }(*indexPageSize)
+	pdoc := &doc.Package{
+		Name:   "a",
+		Consts: []*doc.Value{{Names: []string{"Big", "Small"}}},
+		Vars:   []*doc.Value{{Names: []string{"ErrX"}}},
+		Funcs:  []*doc.Func{{Name: "Add"}, {Name: "Êtes"}},
+		Types: []*doc.Type{
+			{Name: "Buffer", Funcs: []*doc.Func{{Name: "NewBuffer"}}, Methods: []*doc.Func{{Name: "Write"}}},
+			{Name: "Event"},
+		},
+	}
+
+	*indexPageSize = 8
+	if s := docSections(pdoc); s != nil {
+		t.Errorf("docSections() with page size 8 = %v, want nil", s)
+	}
+
+	*indexPageSize = 5
+	want := []string{"A", "B", "E", "Ê"}
+	if s := docSections(pdoc); !reflect.DeepEqual(s, want) {
+		t.Errorf("docSections() = %v, want %v", s, want)
+	}
+
+	sdoc := sectionDoc(pdoc, "B")
+	if len(sdoc.Consts) != 1 || len(sdoc.Vars) != 0 || len(sdoc.Funcs) != 0 || len(sdoc.Types) != 1 || sdoc.Types[0].Name != "Buffer" {
+		t.Errorf("sectionDoc(B) = %+v", sdoc)
+	}
+	if len(pdoc.Funcs) != 2 || len(pdoc.Types) != 2 {
+		t.Errorf("sectionDoc modified the package document")
+	}
+
+	pdoc.IsCmd = true
+	if s := docSections(pdoc); s != nil {
+		t.Errorf("docSections() of command = %v, want nil", s)
+	}
+}
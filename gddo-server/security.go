@@ -0,0 +1,151 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"flag"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+// The state-changing requests from browsers, such as the refresh, report,
+// owner and admin forms, carry a CSRF token: the value of the csrf cookie in
+// the csrf form field or in the X-CSRF-Token header. The cookie is set on the
+// first response to a browser. The forms and the requests from site.js read
+// the cookie with JavaScript because the cached pages are shared by all
+// visitors. Requests with a bearer token accepted as an API token or an API
+// key, such as the requests of gddo-admin and the API clients, and requests
+// without credentials do not need a token. Browsers send the cookies, the
+// Basic and Negotiate authorizations and, through the proxy of -auth_header,
+// the header of the signed in user with cross-site requests, so requests with
+// these credentials need a token.
+
+const (
+	csrfCookie = "csrf"
+	csrfField  = "csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' maxcdn.bootstrapcdn.com ajax.googleapis.com ssl.google-analytics.com www.google-analytics.com; " +
+	"style-src 'self' 'unsafe-inline' maxcdn.bootstrapcdn.com; " +
+	"img-src * data:; " +
+	"font-src 'self' maxcdn.bootstrapcdn.com; " +
+	"frame-ancestors 'self'"
+
+var (
+	csrfProtection        = flag.Bool("csrf", true, "Reject the POST, PUT, PATCH and DELETE requests with cookies and without an Authorization header that do not have the CSRF token of the csrf cookie.")
+	contentSecurityPolicy = flag.String("content_security_policy", defaultContentSecurityPolicy, "Content-Security-Policy header of the responses. The frame-ancestors directive is removed for the embedded documentation cards. Empty disables the header.")
+	frameOptions          = flag.String("frame_options", "SAMEORIGIN", "X-Frame-Options header of the responses other than the embedded documentation cards. Empty disables the header.")
+	referrerPolicy        = flag.String("referrer_policy", "strict-origin-when-cross-origin", "Referrer-Policy header of the responses. Empty disables the header.")
+)
+
+var errBadCSRFToken = &httpError{status: http.StatusForbidden}
+
+// frameable returns true if the response to the request can be shown in a
+// frame on other sites.
+func frameable(req *http.Request) bool {
+	return isView(req, "embed")
+}
+
+// withoutFrameAncestors returns the Content-Security-Policy without the
+// frame-ancestors directive.
+func withoutFrameAncestors(policy string) string {
+	var directives []string
+	for _, d := range strings.Split(policy, ";") {
+		d = strings.TrimSpace(d)
+		if d != "" && !strings.HasPrefix(d, "frame-ancestors") {
+			directives = append(directives, d)
+		}
+	}
+	return strings.Join(directives, "; ")
+}
+
+// setSecurityHeaders sets the security headers of the response to the
+// request.
+func setSecurityHeaders(resp http.ResponseWriter, req *http.Request) {
+	h := resp.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	if *referrerPolicy != "" {
+		h.Set("Referrer-Policy", *referrerPolicy)
+	}
+	csp := *contentSecurityPolicy
+	if frameable(req) {
+		csp = withoutFrameAncestors(csp)
+	} else if *frameOptions != "" {
+		h.Set("X-Frame-Options", *frameOptions)
+	}
+	if csp != "" {
+		h.Set("Content-Security-Policy", csp)
+	}
+}
+
+// needsCSRFToken returns true if the request must have a CSRF token.
+func needsCSRFToken(req *http.Request) (bool, error) {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false, nil
+	}
+	if ok, err := acceptedBearerToken(req); ok || err != nil {
+		return false, err
+	}
+	return len(req.Cookies()) > 0 || req.Header.Get("Authorization") != "" || requestRole(req) != roleNone, nil
+}
+
+// acceptedBearerToken returns true if the bearer token of the request is a
+// token in the -api_tokens file or an API key.
+func acceptedBearerToken(req *http.Request) (bool, error) {
+	if validAPIToken(req) {
+		return true, nil
+	}
+	key := bearerToken(req)
+	if !strings.HasPrefix(key, database.APIKeyPrefix) {
+		return false, nil
+	}
+	k, err := db.APIKey(key)
+	return k != nil, err
+}
+
+// checkCSRF sets the csrf cookie for browsers without the cookie and returns
+// an error with status 403 if the request does not have the CSRF token of
+// the cookie. The form of the request is parsed.
+func checkCSRF(resp http.ResponseWriter, req *http.Request) error {
+	c, err := req.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		if !strings.HasPrefix(req.Host, "api.") {
+			p := make([]byte, 24)
+			rand.Read(p)
+			http.SetCookie(resp, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    base64.RawURLEncoding.EncodeToString(p),
+				Path:     "/",
+				Secure:   req.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		c = nil
+	}
+	if !*csrfProtection {
+		return nil
+	}
+	if needs, err := needsCSRFToken(req); !needs || err != nil {
+		return err
+	}
+	token := req.Header.Get(csrfHeader)
+	if token == "" {
+		token = req.PostForm.Get(csrfField)
+	}
+	if c == nil || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.Value)) != 1 {
+		return errBadCSRFToken
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	setSecurityHeaders(w, httptest.NewRequest("GET", "http://godoc.org/github.com/user/repo", nil))
+	h := w.Header()
+	if h.Get("X-Frame-Options") != "SAMEORIGIN" || h.Get("X-Content-Type-Options") != "nosniff" ||
+		h.Get("Referrer-Policy") == "" || !strings.Contains(h.Get("Content-Security-Policy"), "frame-ancestors 'self'") {
+		t.Errorf("page headers = %v", h)
+	}
+
+	w = httptest.NewRecorder()
+	setSecurityHeaders(w, httptest.NewRequest("GET", "http://godoc.org/github.com/user/repo?embed", nil))
+	h = w.Header()
+	if h.Get("X-Frame-Options") != "" || strings.Contains(h.Get("Content-Security-Policy"), "frame-ancestors") || h.Get("Content-Security-Policy") == "" {
+		t.Errorf("embed headers = %v", h)
+	}
+}
+
+func TestWithoutFrameAncestors(t *testing.T) {
+	got := withoutFrameAncestors("default-src 'self'; frame-ancestors 'self';img-src *")
+	if want := "default-src 'self'; img-src *"; got != want {
+		t.Errorf("withoutFrameAncestors() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	setTestTokens(t, "api\n")
+	setAuthFlags(t, "")
+	defer func(h string) { *authHeader = h }(*authHeader)
+	*authHeader = "X-User"
+	const token = "tok"
+	for _, tt := range []struct {
+		name    string
+		method  string
+		cookie  string
+		field   string
+		header  string
+		auth    string
+		user    string
+		wantErr bool
+	}{
+		{name: "get", method: "GET", cookie: token},
+		{name: "post without cookies", method: "POST"},
+		{name: "form token", method: "POST", cookie: token, field: token},
+		{name: "header token", method: "POST", cookie: token, header: token},
+		{name: "bearer token", method: "POST", cookie: token, auth: "Bearer api"},
+		{name: "unknown bearer token", method: "POST", cookie: token, auth: "Bearer other", wantErr: true},
+		{name: "basic auth", method: "POST", cookie: token, auth: "Basic Z286cGhlcg==", wantErr: true},
+		{name: "proxy user", method: "POST", cookie: token, user: "gopher", wantErr: true},
+		{name: "proxy user with token", method: "POST", cookie: token, user: "gopher", header: token},
+		{name: "missing token", method: "POST", cookie: token, wantErr: true},
+		{name: "wrong token", method: "DELETE", cookie: token, header: "other", wantErr: true},
+		{name: "other cookies", method: "POST", field: token, wantErr: true},
+	} {
+		req := httptest.NewRequest(tt.method, "http://godoc.org/-/refresh", strings.NewReader("csrf="+tt.field))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: csrfCookie, Value: tt.cookie})
+		} else if tt.wantErr {
+			req.AddCookie(&http.Cookie{Name: "owner", Value: "x"})
+		}
+		if tt.header != "" {
+			req.Header.Set(csrfHeader, tt.header)
+		}
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		if tt.user != "" {
+			req.Header.Set("X-User", tt.user)
+		}
+		req.ParseForm()
+		w := httptest.NewRecorder()
+		err := checkCSRF(w, req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkCSRF() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		setCookie := strings.HasPrefix(w.Header().Get("Set-Cookie"), csrfCookie+"=")
+		if setCookie != (tt.cookie == "") {
+			t.Errorf("%s: Set-Cookie = %q", tt.name, w.Header().Get("Set-Cookie"))
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+// Sitemaps (https://www.sitemaps.org/) are served at /sitemap.xml. Without
+// the page parameter, the response is a sitemap index listing the pages
+// /sitemap.xml?page=N. The pages are generated by a background task and the
+// URLs are made absolute with the host of the request.
+
+// sitemapPageSize is the maximum number of URLs allowed in a sitemap.
+const sitemapPageSize = 50000
+
+type sitemapURL struct {
+	path    string
+	lastmod time.Time
+}
+
+var sitemaps struct {
+	mu      sync.Mutex
+	pages   [][]sitemapURL
+	updated time.Time
+}
+
+// setSitemaps splits urls into pages and replaces the served sitemaps.
+func setSitemaps(urls []sitemapURL, updated time.Time) {
+	sort.Sort(bySitemapPath(urls))
+	var pages [][]sitemapURL
+	for len(urls) > 0 {
+		n := sitemapPageSize
+		if n > len(urls) {
+			n = len(urls)
+		}
+		pages = append(pages, urls[:n])
+		urls = urls[n:]
+	}
+	sitemaps.mu.Lock()
+	sitemaps.pages = pages
+	sitemaps.updated = updated
+	sitemaps.mu.Unlock()
+}
+
+// writeSitemaps generates the sitemaps from the packages in the database.
+// Directories, packages hidden from search, private packages, blocked
+// packages and packages not in the allow list are excluded.
+func writeSitemaps() error {
+	var urls []sitemapURL
+	err := db.Do(func(pi *database.PackageInfo) error {
+		if pi.Kind == "d" || pi.Score == 0 {
+			return nil
+		}
+		pdoc := pi.PDoc
+		if pdoc.Private || !isAllowed(pdoc.ImportPath) {
+			return nil
+		}
+		if blocked, err := db.IsBlocked(pdoc.ImportPath); err != nil || blocked {
+			return err
+		}
+		urls = append(urls, sitemapURL{path: pdoc.ImportPath, lastmod: pdoc.Updated})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	setSitemaps(urls, time.Now())
+	slog.Info("generated sitemaps", "packages", len(urls))
+	return nil
+}
+
+type bySitemapPath []sitemapURL
+
+func (p bySitemapPath) Len() int           { return len(p) }
+func (p bySitemapPath) Less(i, j int) bool { return p[i].path < p[j].path }
+func (p bySitemapPath) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+type sitemapIndexXML struct {
+	XMLName  xml.Name        `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapLocXML `xml:"sitemap"`
+}
+
+type sitemapURLSetXML struct {
+	XMLName xml.Name        `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapLocXML `xml:"url"`
+}
+
+type sitemapLocXML struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod,omitempty"`
+}
+
+func sitemapLastmod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// writeSitemap writes the sitemap index if page is 0 or the given page of
+// the sitemap. The function returns false if the page does not exist.
+func writeSitemap(w io.Writer, req *http.Request, pages [][]sitemapURL, updated time.Time, page int) (bool, error) {
+	var v interface{}
+	if page == 0 {
+		var index sitemapIndexXML
+		for i := range pages {
+			index.Sitemaps = append(index.Sitemaps, sitemapLocXML{
+				Loc:     pageURI(req, "sitemap.xml?page="+strconv.Itoa(i+1)),
+				Lastmod: sitemapLastmod(updated),
+			})
+		}
+		v = &index
+	} else {
+		if page > len(pages) {
+			return false, nil
+		}
+		var set sitemapURLSetXML
+		for _, u := range pages[page-1] {
+			set.URLs = append(set.URLs, sitemapLocXML{
+				Loc:     pageURI(req, u.path),
+				Lastmod: sitemapLastmod(u.lastmod),
+			})
+		}
+		v = &set
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return false, err
+	}
+	return true, xml.NewEncoder(w).Encode(v)
+}
+
+func serveSitemap(resp http.ResponseWriter, req *http.Request) error {
+	page := 0
+	if s := req.Form.Get("page"); s != "" {
+		var err error
+		page, err = strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return &httpError{status: http.StatusNotFound}
+		}
+	}
+
+	sitemaps.mu.Lock()
+	pages, updated := sitemaps.pages, sitemaps.updated
+	sitemaps.mu.Unlock()
+	if updated.IsZero() {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	var buf bytes.Buffer
+	ok, err := writeSitemap(&buf, req, pages, updated, page)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &httpError{status: http.StatusNotFound}
+	}
+	resp.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	resp.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeContent(resp, req, "", updated, bytes.NewReader(buf.Bytes()))
+	return nil
+}
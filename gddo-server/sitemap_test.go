@@ -0,0 +1,56 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSitemap(t *testing.T) {
+	defer setSitemaps(nil, time.Time{})
+	updated := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	urls := make([]sitemapURL, sitemapPageSize+1)
+	for i := range urls {
+		urls[i].path = "github.com/user/repo"
+	}
+	urls[0] = sitemapURL{path: "a.org/pkg", lastmod: updated}
+	setSitemaps(urls, updated)
+	if len(sitemaps.pages) != 2 || len(sitemaps.pages[1]) != 1 {
+		t.Fatalf("got %d pages, want 2", len(sitemaps.pages))
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/sitemap.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if ok, err := writeSitemap(&buf, req, sitemaps.pages, updated, 0); !ok || err != nil {
+		t.Fatalf("writeSitemap(index) = %v, %v", ok, err)
+	}
+	for _, s := range []string{"<sitemapindex", "<loc>http://example.com/sitemap.xml?page=2</loc>", "<lastmod>2015-01-02</lastmod>"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("index does not contain %q:\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if ok, err := writeSitemap(&buf, req, sitemaps.pages, updated, 1); !ok || err != nil {
+		t.Fatalf("writeSitemap(1) = %v, %v", ok, err)
+	}
+	if s := "<url><loc>http://example.com/a.org/pkg</loc><lastmod>2015-01-02</lastmod></url>"; !strings.Contains(buf.String(), s) {
+		t.Errorf("page 1 does not contain %q", s)
+	}
+
+	if ok, _ := writeSitemap(&buf, req, sitemaps.pages, updated, 3); ok {
+		t.Errorf("writeSitemap(3) returned ok for missing page")
+	}
+}
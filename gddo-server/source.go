@@ -0,0 +1,148 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	htemp "html/template"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+// tokenClass returns the CSS class used to highlight a token.
+func tokenClass(tok token.Token, lit string) string {
+	switch {
+	case tok == token.COMMENT:
+		return "com"
+	case tok.IsKeyword():
+		return "kwd"
+	case tok == token.STRING || tok == token.CHAR:
+		return "str"
+	case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+		return "num"
+	case tok == token.IDENT && (lit == "true" || lit == "false" || lit == "nil" || lit == "iota"):
+		return "num"
+	}
+	return ""
+}
+
+// highlightLines returns the lines of the Go source src with the tokens
+// wrapped in spans for syntax highlighting. Tokens that span lines are split
+// so that each line is well formed HTML.
+func highlightLines(src []byte) []htemp.HTML {
+	var lines []htemp.HTML
+	var buf bytes.Buffer
+	emit := func(class string, text []byte) {
+		for {
+			i := bytes.IndexByte(text, '\n')
+			chunk := text
+			if i >= 0 {
+				chunk = text[:i]
+			}
+			if len(chunk) > 0 {
+				if class != "" {
+					buf.WriteString(`<span class="` + class + `">`)
+				}
+				htemp.HTMLEscape(&buf, chunk)
+				if class != "" {
+					buf.WriteString(`</span>`)
+				}
+			}
+			if i < 0 {
+				return
+			}
+			lines = append(lines, htemp.HTML(buf.String()))
+			buf.Reset()
+			text = text[i+1:]
+		}
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		class := tokenClass(tok, lit)
+		if class == "" {
+			continue
+		}
+		offset := file.Offset(pos)
+		end := offset + len(lit)
+		if offset < last || end > len(src) {
+			continue
+		}
+		emit("", src[last:offset])
+		emit(class, src[offset:end])
+		last = end
+	}
+	emit("", src[last:])
+	if buf.Len() > 0 {
+		lines = append(lines, htemp.HTML(buf.String()))
+	}
+	return lines
+}
+
+type sourceLine struct {
+	N    int
+	HTML htemp.HTML
+}
+
+// serveSource serves the stored source of a file at /src/<import path>/<file
+// name>.
+func serveSource(resp http.ResponseWriter, req *http.Request) error {
+	importPath, name := path.Split(strings.TrimPrefix(req.URL.Path, "/src/"))
+	importPath = strings.TrimSuffix(importPath, "/")
+	if importPath == "" || !strings.HasSuffix(name, ".go") || !isAllowed(importPath) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	pdoc, _, _, err := db.Get(importPath)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || !pdoc.SourcesStored || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	src, err := db.GetSource(importPath, name)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+
+	browseURL := ""
+	for _, files := range [][]*doc.File{pdoc.Files, pdoc.TestFiles} {
+		for _, f := range files {
+			if f.Name == name {
+				browseURL = f.URL
+			}
+		}
+	}
+
+	var lines []sourceLine
+	for i, l := range highlightLines(src.Data) {
+		lines = append(lines, sourceLine{N: i + 1, HTML: l})
+	}
+
+	return executeTemplate(resp, "source.html", http.StatusOK, nil, map[string]interface{}{
+		"flashMessages": getFlashMessages(resp, req),
+		"pdoc":          newTDoc(pdoc),
+		"name":          name,
+		"browseURL":     browseURL,
+		"lines":         lines,
+	})
+}
@@ -0,0 +1,28 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	htemp "html/template"
+	"reflect"
+	"testing"
+)
+
+func TestHighlightLines(t *testing.T) {
+	src := "package p\n\n/* a\nb */\nvar x = \"<s>\" + 1 // c\n"
+	want := []htemp.HTML{
+		`<span class="kwd">package</span> p`,
+		``,
+		`<span class="com">/* a</span>`,
+		`<span class="com">b */</span>`,
+		`<span class="kwd">var</span> x = <span class="str">&#34;&lt;s&gt;&#34;</span> + <span class="num">1</span> <span class="com">// c</span>`,
+	}
+	got := highlightLines([]byte(src))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("highlightLines =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+const (
+	sparklineDays   = 30
+	sparklineWidth  = 120
+	sparklineHeight = 20
+)
+
+// sparklinePoints returns the points of an SVG polyline plotting values in a
+// box with the given width and height. Values are scaled to the maximum value.
+func sparklinePoints(values []int, width, height int) string {
+	max := 1
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var points []string
+	for i, v := range values {
+		x := 0.0
+		if len(values) > 1 {
+			x = float64(i) * float64(width) / float64(len(values)-1)
+		}
+		y := float64(height) - float64(v)*float64(height)/float64(max)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(points, " ")
+}
+
+func renderSparkline(stats []database.DailyStats) []byte {
+	views := make([]int, len(stats))
+	importers := make([]int, len(stats))
+	for i, s := range stats {
+		views[i] = s.Views
+		importers[i] = s.Importers
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="-1 -1 %d %d">`, sparklineWidth, sparklineHeight, sparklineWidth+2, sparklineHeight+2)
+	fmt.Fprintf(&buf, `<title>Page views and importers in the last %d days</title>`, len(stats))
+	fmt.Fprintf(&buf, `<polyline fill="none" stroke="#999" stroke-width="1" points="%s"/>`, sparklinePoints(importers, sparklineWidth, sparklineHeight))
+	fmt.Fprintf(&buf, `<polyline fill="none" stroke="#337ab7" stroke-width="1.5" points="%s"/>`, sparklinePoints(views, sparklineWidth, sparklineHeight))
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// servePopularityImage serves a sparkline of the recent page views and
+// importer counts for a package.
+func servePopularityImage(resp http.ResponseWriter, req *http.Request, importPath string) error {
+	stats, err := db.PackageStats(importPath, sparklineDays)
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Content-Type", "image/svg+xml")
+	resp.Header().Set("Cache-Control", "max-age=3600")
+	_, err = resp.Write(renderSparkline(stats))
+	return err
+}
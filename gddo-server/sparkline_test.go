@@ -0,0 +1,28 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"testing"
+)
+
+var sparklinePointsTests = []struct {
+	values []int
+	points string
+}{
+	{[]int{0, 0}, "0.0,20.0 120.0,20.0"},
+	{[]int{0, 5, 10}, "0.0,20.0 60.0,10.0 120.0,0.0"},
+	{[]int{3}, "0.0,0.0"},
+}
+
+func TestSparklinePoints(t *testing.T) {
+	for _, tt := range sparklinePointsTests {
+		if points := sparklinePoints(tt.values, 120, 20); points != tt.points {
+			t.Errorf("sparklinePoints(%v) = %q, want %q", tt.values, points, tt.points)
+		}
+	}
+}
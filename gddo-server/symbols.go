@@ -0,0 +1,151 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	godoc "go/doc"
+	"net/http"
+	"strings"
+
+	"github.com/golang/gddo/doc"
+)
+
+// apiV1Symbol is an exported symbol of a package in the compact index of
+// /api/v1/pkg/<import path>/symbols. Editor plugins look up the symbol under
+// the cursor in the index and open the URL.
+type apiV1Symbol struct {
+	// Name is the name of the symbol. Methods and fields are named
+	// Type.Name.
+	Name string `json:"name"`
+
+	// Kind is one of const, var, func, type, method or field.
+	Kind string `json:"kind"`
+
+	// Signature is the line of the declaration of the symbol.
+	Signature string `json:"signature"`
+
+	// Synopsis is the first sentence of the doc comment of the declaration.
+	Synopsis string `json:"synopsis,omitempty"`
+
+	// Anchor is the fragment of the symbol on the package page and URL
+	// the path of the package page with the fragment.
+	Anchor string `json:"anchor"`
+	URL    string `json:"url"`
+}
+
+// declLine returns the trimmed line of the code containing the position.
+func declLine(c doc.Code, pos int32) string {
+	text := c.Text
+	i := strings.LastIndex(text[:pos], "\n") + 1
+	j := strings.Index(text[pos:], "\n")
+	if j < 0 {
+		j = len(text)
+	} else {
+		j += int(pos)
+	}
+	return strings.TrimSpace(text[i:j])
+}
+
+// apiV1Symbols returns the index of the exported symbols of the package in
+// the order of the package page.
+func apiV1Symbols(pdoc *doc.Package) []apiV1Symbol {
+	symbols := []apiV1Symbol{}
+	add := func(name, kind, signature, comment string) {
+		symbols = append(symbols, apiV1Symbol{
+			Name:      name,
+			Kind:      kind,
+			Signature: signature,
+			Synopsis:  godoc.Synopsis(comment),
+			Anchor:    name,
+			URL:       "/" + pdoc.ImportPath + "#" + name,
+		})
+	}
+	// addAnchors adds the names with anchors in the declaration, such as
+	// the names of a group of constants or the fields of a struct.
+	addAnchors := func(c doc.Code, typeName, kind, comment string) {
+		for _, a := range c.Annotations {
+			if a.Kind != doc.AnchorAnnotation {
+				continue
+			}
+			name := c.Text[a.Pos:a.End]
+			if typeName != "" {
+				name = typeName + "." + name
+			}
+			add(name, kind, declLine(c, a.Pos), comment)
+		}
+	}
+	addValues := func(values []*doc.Value) {
+		for _, v := range values {
+			kind := "var"
+			if strings.HasPrefix(v.Decl.Text, "const") {
+				kind = "const"
+			}
+			addAnchors(v.Decl, "", kind, v.Doc)
+		}
+	}
+	addFuncs := func(typeName string, funcs []*doc.Func) {
+		kind := "func"
+		for _, f := range funcs {
+			name := f.Name
+			if typeName != "" {
+				name = typeName + "." + f.Name
+				kind = "method"
+			}
+			add(name, kind, f.Decl.Text, f.Doc)
+		}
+	}
+
+	addValues(pdoc.Consts)
+	addValues(pdoc.Vars)
+	addFuncs("", pdoc.Funcs)
+	for _, t := range pdoc.Types {
+		add(t.Name, "type", strings.TrimSuffix(declLine(t.Decl, 0), " {"), t.Doc)
+		kind := "field"
+		if isInterfaceFn(t) {
+			kind = "method"
+		}
+		addAnchors(t.Decl, t.Name, kind, "")
+		addValues(t.Consts)
+		addValues(t.Vars)
+		addFuncs("", t.Funcs)
+		addFuncs(t.Name, t.Methods)
+	}
+	return symbols
+}
+
+// serveAPIV1Symbols serves the index of the exported symbols of a package.
+// The response is cached and revalidated with the Etag of the document.
+func serveAPIV1Symbols(resp http.ResponseWriter, req *http.Request, importPath string) error {
+	pdoc, _, err := getDoc(req.Context(), importPath, apiRequest)
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || pdoc.Name == "" || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	etag := httpEtag("symbols", "", pdoc, nil, nil, 0, false, "", nil, nil, nil)
+	header, status := docHeader(req, etag, pdoc.Private)
+	for k, v := range header {
+		resp.Header()[k] = v
+	}
+	if status == http.StatusNotModified {
+		resp.WriteHeader(status)
+		return nil
+	}
+	data := struct {
+		ImportPath string        `json:"importPath"`
+		Name       string        `json:"name"`
+		Symbols    []apiV1Symbol `json:"symbols"`
+	}{
+		pdoc.ImportPath,
+		pdoc.Name,
+		apiV1Symbols(pdoc),
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&data)
+}
@@ -0,0 +1,44 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestAPIV1Symbols(t *testing.T) {
+	pdoc := &doc.Package{
+		ImportPath: "example.com/p",
+		Consts: []*doc.Value{{
+			Doc:  "Modes of the reader. More text.",
+			Decl: doc.Code{Text: "const (\n\tA = 1\n\tB = 2\n)", Annotations: []doc.Annotation{{Pos: 9, End: 10, Kind: doc.AnchorAnnotation}, {Pos: 16, End: 17, Kind: doc.AnchorAnnotation}}},
+		}},
+		Funcs: []*doc.Func{{Name: "F", Doc: "F does things.", Decl: doc.Code{Text: "func F(x int) error"}}},
+		Types: []*doc.Type{{
+			Name:    "T",
+			Doc:     "T is a thing.",
+			Decl:    doc.Code{Text: "type T struct {\n\tX int\n}", Annotations: []doc.Annotation{{Pos: 17, End: 18, Kind: doc.AnchorAnnotation}}},
+			Funcs:   []*doc.Func{{Name: "NewT", Decl: doc.Code{Text: "func NewT() *T"}}},
+			Methods: []*doc.Func{{Name: "M", Decl: doc.Code{Text: "func (t *T) M()"}}},
+		}},
+	}
+	want := []apiV1Symbol{
+		{Name: "A", Kind: "const", Signature: "A = 1", Synopsis: "Modes of the reader.", Anchor: "A", URL: "/example.com/p#A"},
+		{Name: "B", Kind: "const", Signature: "B = 2", Synopsis: "Modes of the reader.", Anchor: "B", URL: "/example.com/p#B"},
+		{Name: "F", Kind: "func", Signature: "func F(x int) error", Synopsis: "F does things.", Anchor: "F", URL: "/example.com/p#F"},
+		{Name: "T", Kind: "type", Signature: "type T struct", Synopsis: "T is a thing.", Anchor: "T", URL: "/example.com/p#T"},
+		{Name: "T.X", Kind: "field", Signature: "X int", Anchor: "T.X", URL: "/example.com/p#T.X"},
+		{Name: "NewT", Kind: "func", Signature: "func NewT() *T", Anchor: "NewT", URL: "/example.com/p#NewT"},
+		{Name: "T.M", Kind: "method", Signature: "func (t *T) M()", Anchor: "T.M", URL: "/example.com/p#T.M"},
+	}
+	if got := apiV1Symbols(pdoc); !reflect.DeepEqual(got, want) {
+		t.Errorf("apiV1Symbols() =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+// A mirror is a read-only copy of the packages of a primary instance plus the
+// private packages crawled by the mirror. The mirror pulls the packages saved
+// or deleted on the primary since the last sync from /api/v1/sync on the
+// primary. Packages copied from the primary are not crawled by the mirror.
+
+var (
+	syncPrimary = flag.String("sync_primary", "", "Base URL of the primary instance mirrored by this instance, for example https://godoc.org. Empty disables mirroring.")
+	syncToken   = flag.String("sync_token", "", "API key or token sent to the primary. A token in the -api_tokens file of the primary also copies the private packages of the primary.")
+)
+
+const (
+	syncPerPage   = 50
+	syncCursorKey = "syncCursor"
+)
+
+// syncRecord is the last change to a package in a response of the sync
+// endpoint. Package is nil for deleted packages.
+type syncRecord struct {
+	Path    string       `json:"path"`
+	Deleted bool         `json:"deleted,omitempty"`
+	Package *doc.Package `json:"package,omitempty"`
+}
+
+// syncPage is a response of the sync endpoint.
+type syncPage struct {
+	NextCursor string       `json:"nextCursor"`
+	Results    []syncRecord `json:"results"`
+}
+
+// serveAPIV1Sync serves the packages saved or deleted after the cursor
+// parameter. An empty cursor requests the changes from the start. The
+// nextCursor of the response is the cursor for the changes after the
+// response, even if there are no more changes yet. Packages that the request
+// is not allowed to view are reported as deleted.
+func serveAPIV1Sync(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkAPIKey(resp, req); err != nil {
+		return err
+	}
+	perPage, err := cursorPerPage(req)
+	if err != nil {
+		return err
+	}
+	var seq int64
+	if s := req.Form.Get("cursor"); s != "" {
+		seq, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || seq < 0 {
+			return &httpError{status: http.StatusBadRequest}
+		}
+	}
+	changes, err := db.Changes(seq, perPage)
+	if err != nil {
+		return err
+	}
+
+	page := syncPage{NextCursor: strconv.FormatInt(seq, 10), Results: []syncRecord{}}
+	for _, ch := range changes {
+		r := syncRecord{Path: ch.Path, Deleted: true}
+		if !ch.Deleted && isAllowed(ch.Path) {
+			pdoc, _, err := db.GetDoc(ch.Path)
+			if err != nil {
+				return err
+			}
+			if pdoc != nil && canView(req, pdoc) {
+				r = syncRecord{Path: ch.Path, Package: pdoc}
+			}
+		}
+		page.Results = append(page.Results, r)
+		page.NextCursor = strconv.FormatInt(ch.Seq, 10)
+	}
+	resp.Header().Set("Content-Type", jsonMIMEType)
+	return json.NewEncoder(resp).Encode(&page)
+}
+
+// isMirrored returns true if the package was copied from the primary.
+func isMirrored(pdoc *doc.Package) bool {
+	return *syncPrimary != "" && pdoc != nil && !pdoc.Private
+}
+
+// fetchSyncPage fetches the changes after the cursor from the primary.
+func fetchSyncPage(cursor string) (*syncPage, error) {
+	u := strings.TrimSuffix(*syncPrimary, "/") + "/api/v1/sync?" + url.Values{
+		"cursor":   {cursor},
+		"per_page": {strconv.Itoa(syncPerPage)},
+	}.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if *syncToken != "" {
+		req.Header.Set("Authorization", "Bearer "+*syncToken)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		p, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s: %s", u, resp.Status, strings.TrimSpace(string(p)))
+	}
+	var page syncPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+	return &page, nil
+}
+
+// applySyncRecord saves or deletes the package in the record. Private
+// packages crawled by the mirror are not replaced or deleted by public
+// packages of the primary.
+func applySyncRecord(r syncRecord) error {
+	if !isAllowed(r.Path) {
+		return nil
+	}
+	if blocked, err := db.IsBlocked(r.Path); err != nil || blocked {
+		return err
+	}
+	local, _, err := db.GetDoc(r.Path)
+	if err != nil {
+		return err
+	}
+	if local != nil && local.Private && (r.Package == nil || !r.Package.Private) {
+		return nil
+	}
+	switch {
+	case r.Package != nil && r.Package.ImportPath == r.Path:
+		err = db.Put(r.Package, time.Time{}, false)
+	case local != nil:
+		err = db.Delete(r.Path)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	packageChanged(r.Path)
+	return nil
+}
+
+// syncFromPrimary copies the changes since the last sync from the primary.
+func syncFromPrimary() error {
+	if *syncPrimary == "" {
+		return nil
+	}
+	var cursor string
+	if err := db.GetGob(syncCursorKey, &cursor); err != nil {
+		return err
+	}
+	n := 0
+	for {
+		page, err := fetchSyncPage(cursor)
+		if err != nil {
+			return err
+		}
+		for _, r := range page.Results {
+			if err := applySyncRecord(r); err != nil {
+				return fmt.Errorf("sync %s: %v", r.Path, err)
+			}
+		}
+		n += len(page.Results)
+		if page.NextCursor != "" {
+			cursor = page.NextCursor
+		}
+		if err := db.PutGob(syncCursorKey, cursor); err != nil {
+			return err
+		}
+		if len(page.Results) < syncPerPage {
+			break
+		}
+	}
+	if n > 0 {
+		slog.Info("synced from primary", "primary", *syncPrimary, "changes", n, "cursor", cursor)
+	}
+	return nil
+}
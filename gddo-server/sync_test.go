@@ -0,0 +1,93 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/doc"
+)
+
+func TestServeAPIV1SyncBadCursor(t *testing.T) {
+	defer func(r bool) { *requireAPIKeys = r }(*requireAPIKeys)
+	*requireAPIKeys = false
+	for _, cursor := range []string{"x", "-1"} {
+		req := httptest.NewRequest("GET", "/api/v1/sync?cursor="+cursor, nil)
+		req.ParseForm()
+		err := serveAPIV1Sync(httptest.NewRecorder(), req)
+		if e, ok := err.(*httpError); !ok || e.status != http.StatusBadRequest {
+			t.Errorf("cursor %q: err = %v, want bad request", cursor, err)
+		}
+	}
+}
+
+func TestFetchSyncPage(t *testing.T) {
+	pdoc := &doc.Package{
+		ImportPath:  "github.com/user/repo",
+		ProjectRoot: "github.com/user/repo",
+		Name:        "repo",
+		Updated:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Funcs: []*doc.Func{{
+			Name: "New",
+			Decl: doc.Code{Text: "func New() *T", Annotations: []doc.Annotation{{Pos: 12, End: 13, Kind: doc.LinkAnnotation}}},
+		}},
+	}
+	var gotQuery, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		gotQuery = req.URL.RawQuery
+		gotAuth = req.Header.Get("Authorization")
+		json.NewEncoder(resp).Encode(&syncPage{
+			NextCursor: "8",
+			Results: []syncRecord{
+				{Path: pdoc.ImportPath, Package: pdoc},
+				{Path: "github.com/user/old", Deleted: true},
+			},
+		})
+	}))
+	defer server.Close()
+
+	defer func(p, t string) { *syncPrimary, *syncToken = p, t }(*syncPrimary, *syncToken)
+	*syncPrimary, *syncToken = server.URL+"/", "secret"
+
+	page, err := fetchSyncPage("6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotQuery != "cursor=6&per_page=50" || gotAuth != "Bearer secret" {
+		t.Errorf("request query %q, authorization %q", gotQuery, gotAuth)
+	}
+	if page.NextCursor != "8" || len(page.Results) != 2 || !page.Results[1].Deleted {
+		t.Fatalf("page = %+v", page)
+	}
+	if !reflect.DeepEqual(page.Results[0].Package, pdoc) {
+		t.Errorf("package = %+v, want %+v", page.Results[0].Package, pdoc)
+	}
+}
+
+func TestIsMirrored(t *testing.T) {
+	defer func(p string) { *syncPrimary = p }(*syncPrimary)
+	for _, tt := range []struct {
+		primary string
+		pdoc    *doc.Package
+		want    bool
+	}{
+		{"", &doc.Package{}, false},
+		{"https://godoc.org", nil, false},
+		{"https://godoc.org", &doc.Package{}, true},
+		{"https://godoc.org", &doc.Package{Private: true}, false},
+	} {
+		*syncPrimary = tt.primary
+		if got := isMirrored(tt.pdoc); got != tt.want {
+			t.Errorf("isMirrored(%q, %+v) = %v, want %v", tt.primary, tt.pdoc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/gosrc"
+)
+
+// The talks, the .slide and .article files in the present format found in
+// the indexed directories, are served at the import path of the directory
+// followed by the file name. The package page links to the talks of the
+// directory.
+
+var talkCacheTTL = flag.Duration("talk_cache_ttl", time.Hour, "Time to cache the fetched talks before fetching the talks again. The talks are also fetched again after the directory is crawled. Zero disables the cache.")
+
+// cachedTalk is a parsed talk. The talk is fetched again if the directory
+// was crawled after the talk was fetched.
+type cachedTalk struct {
+	talk    *talkDoc
+	etag    string
+	expires time.Time
+}
+
+var talks = struct {
+	mu    sync.Mutex
+	talks map[string]*cachedTalk
+}{talks: make(map[string]*cachedTalk)}
+
+// getTalk returns the talk at the path in the directory with the document
+// pdoc.
+func getTalk(ctx context.Context, talkPath string, pdoc *doc.Package) (*talkDoc, error) {
+	etag := pdoc.Etag + " " + pdoc.Updated.String()
+	now := time.Now()
+	talks.mu.Lock()
+	c := talks.talks[talkPath]
+	talks.mu.Unlock()
+	if c != nil && c.etag == etag && now.Before(c.expires) {
+		return c.talk, nil
+	}
+
+	pres, err := gosrc.GetPresentation(fetchClient(ctx), talkPath)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseTalk(pres.Filename, pres.Files)
+	if err != nil {
+		return nil, &httpError{status: http.StatusNotFound, err: err}
+	}
+	if *talkCacheTTL > 0 {
+		talks.mu.Lock()
+		for p, c := range talks.talks {
+			if now.After(c.expires) {
+				delete(talks.talks, p)
+			}
+		}
+		talks.talks[talkPath] = &cachedTalk{talk: d, etag: etag, expires: now.Add(*talkCacheTTL)}
+		talks.mu.Unlock()
+	}
+	return d, nil
+}
+
+// serveTalk serves the talk at the path. The talk must be in the talks of the
+// indexed directory.
+func serveTalk(resp http.ResponseWriter, req *http.Request, talkPath string) error {
+	dir, name := path.Split(talkPath)
+	pdoc, _, err := db.GetDoc(strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return err
+	}
+	if pdoc == nil || !canView(req, pdoc) {
+		return &httpError{status: http.StatusNotFound}
+	}
+	var talk *doc.Talk
+	for _, t := range pdoc.Talks {
+		if t.Name == name {
+			talk = t
+		}
+	}
+	if talk == nil {
+		return &httpError{status: http.StatusNotFound}
+	}
+	d, err := getTalk(req.Context(), talkPath, pdoc)
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Cache-Control": {"public, no-cache"}}
+	if pdoc.Private {
+		header.Set("Cache-Control", "private, no-cache")
+	}
+	return executeTemplate(resp, "talk.html", http.StatusOK, header, map[string]interface{}{
+		"flashMessages": getFlashMessages(resp, req),
+		"pdoc":          newTDoc(pdoc),
+		"talk":          d,
+		"file":          talk,
+		"slides":        path.Ext(name) == ".slide",
+	})
+}
@@ -8,20 +8,27 @@ package main
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	godoc "go/doc"
+	"go/doc/comment"
 	htemp "html/template"
 	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	ttemp "text/template"
 	"time"
 
@@ -79,6 +86,12 @@ func setFlashMessages(resp http.ResponseWriter, messages []flashMessage) {
 type tdoc struct {
 	*doc.Package
 	allExamples []*texample
+
+	// The types with expanded declarations and the section of the page;
+	// see TypeDecl.
+	expand    map[string]bool
+	expandAll bool
+	section   string
 }
 
 type texample struct {
@@ -93,6 +106,11 @@ func newTDoc(pdoc *doc.Package) *tdoc {
 }
 
 func (pdoc *tdoc) SourceLink(pos doc.Pos, text string, textOnlyOK bool) htemp.HTML {
+	if pos.Line != 0 && pdoc.SourcesStored {
+		return htemp.HTML(fmt.Sprintf(`<a title="View Source" href="%s#L%d">%s</a>`,
+			htemp.HTMLEscapeString(pdoc.FileURL(pdoc.Files[pos.File])), pos.Line,
+			htemp.HTMLEscapeString(text)))
+	}
 	if pos.Line == 0 || pdoc.LineFmt == "" || pdoc.Files[pos.File].URL == "" {
 		if textOnlyOK {
 			return htemp.HTML(htemp.HTMLEscapeString(text))
@@ -105,6 +123,81 @@ func (pdoc *tdoc) SourceLink(pos doc.Pos, text string, textOnlyOK bool) htemp.HT
 		htemp.HTMLEscapeString(text)))
 }
 
+// Comment formats a doc comment of the package as HTML. The doc links in the
+// comment are resolved with the link targets found when the package was
+// built.
+func (pdoc *tdoc) Comment(v string) htemp.HTML {
+	return commentHTML(v, &comment.Parser{
+		LookupPackage: pdoc.lookupPackage,
+		LookupSym:     pdoc.lookupSym,
+	})
+}
+
+// lookupPackage returns the import path of the package with the name in the
+// doc links of the package.
+func (pdoc *tdoc) lookupPackage(name string) (string, bool) {
+	for text, link := range pdoc.DocLinks {
+		if link.ImportPath != "" && (text == name || strings.HasPrefix(text, name+".")) {
+			return link.ImportPath, true
+		}
+	}
+	return "", false
+}
+
+// lookupSym returns true if the doc links of the package link to the
+// declaration recv.name or name of the package.
+func (pdoc *tdoc) lookupSym(recv, name string) bool {
+	if recv != "" {
+		name = recv + "." + name
+	}
+	link, ok := pdoc.DocLinks[name]
+	return ok && link.ImportPath == "" && link.Name == name
+}
+
+// ReadmeHTML returns the rendered README of the package directory.
+func (pdoc *tdoc) ReadmeHTML() htemp.HTML {
+	return readmeHTML(pdoc.Readme)
+}
+
+// FileURL returns the URL of the source view for the file. The source is
+// served by the site if the source was stored when the package was crawled.
+func (pdoc *tdoc) FileURL(f *doc.File) string {
+	if pdoc.SourcesStored {
+		return "/src/" + pdoc.ImportPath + "/" + f.Name
+	}
+	return f.URL
+}
+
+// HasDeprecated returns true if the package declares a deprecated constant,
+// variable, function, type or method.
+func (pdoc *tdoc) HasDeprecated() bool {
+	values := func(vs []*doc.Value) bool {
+		for _, v := range vs {
+			if v.Deprecated != "" {
+				return true
+			}
+		}
+		return false
+	}
+	funcs := func(fs []*doc.Func) bool {
+		for _, f := range fs {
+			if f.Deprecated != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if values(pdoc.Consts) || values(pdoc.Vars) || funcs(pdoc.Funcs) {
+		return true
+	}
+	for _, t := range pdoc.Types {
+		if t.Deprecated != "" || values(t.Consts) || values(t.Vars) || funcs(t.Funcs) || funcs(t.Methods) {
+			return true
+		}
+	}
+	return false
+}
+
 func (pdoc *tdoc) PageName() string {
 	if pdoc.Name != "" && !pdoc.IsCmd {
 		return pdoc.Name
@@ -173,6 +266,70 @@ func (pdoc *tdoc) ObjExamples(obj interface{}) []*texample {
 	return examples
 }
 
+// tsymbol is an identifier listed in the jump to identifier dialog.
+type tsymbol struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+}
+
+// anchorSymbols returns the symbols for the anchors in a declaration.
+func anchorSymbols(symbols []tsymbol, c doc.Code, typeName, kind string) []tsymbol {
+	for _, a := range c.Annotations {
+		if a.Kind != doc.AnchorAnnotation {
+			continue
+		}
+		id := c.Text[a.Pos:a.End]
+		if typeName != "" {
+			id = typeName + "." + id
+		}
+		symbols = append(symbols, tsymbol{ID: id, Kind: kind})
+	}
+	return symbols
+}
+
+// Symbols returns the JSON encoded list of the identifiers in the package
+// documentation with the anchors used on the package page.
+func (pdoc *tdoc) Symbols() (string, error) {
+	p, err := json.Marshal(pdoc.symbols())
+	return string(p), err
+}
+
+// symbols returns the identifiers in the package documentation with the
+// anchors used on the package page.
+func (pdoc *tdoc) symbols() []tsymbol {
+	symbols := []tsymbol{}
+	for _, v := range pdoc.Consts {
+		symbols = anchorSymbols(symbols, v.Decl, "", "constant")
+	}
+	for _, v := range pdoc.Vars {
+		symbols = anchorSymbols(symbols, v.Decl, "", "variable")
+	}
+	for _, f := range pdoc.Funcs {
+		symbols = append(symbols, tsymbol{ID: f.Name, Kind: "function"})
+	}
+	for _, t := range pdoc.Types {
+		symbols = append(symbols, tsymbol{ID: t.Name, Kind: "type"})
+		kind := "field"
+		if isInterfaceFn(t) {
+			kind = "method"
+		}
+		symbols = anchorSymbols(symbols, t.Decl, t.Name, kind)
+		for _, v := range t.Consts {
+			symbols = anchorSymbols(symbols, v.Decl, "", "constant")
+		}
+		for _, v := range t.Vars {
+			symbols = anchorSymbols(symbols, v.Decl, "", "variable")
+		}
+		for _, f := range t.Funcs {
+			symbols = append(symbols, tsymbol{ID: f.Name, Kind: "function"})
+		}
+		for _, m := range t.Methods {
+			symbols = append(symbols, tsymbol{ID: t.Name + "." + m.Name, Kind: "method"})
+		}
+	}
+	return symbols
+}
+
 func (pdoc *tdoc) Breadcrumbs(templateName string) htemp.HTML {
 	if !strings.HasPrefix(pdoc.ImportPath, pdoc.ProjectRoot) {
 		return ""
@@ -293,9 +450,14 @@ func replaceAll(src []byte, re *regexp.Regexp, replace func(out, src []byte, m [
 
 // commentFn formats a source code comment as HTML.
 func commentFn(v string) htemp.HTML {
-	var buf bytes.Buffer
-	godoc.ToHTML(&buf, v, nil)
-	p := buf.Bytes()
+	return commentHTML(v, &comment.Parser{})
+}
+
+// commentHTML formats a doc comment parsed by the parser as HTML. Headings,
+// lists, links and doc links follow the syntax of go/doc/comment.
+func commentHTML(v string, parser *comment.Parser) htemp.HTML {
+	printer := &comment.Printer{DocLinkURL: docLinkURL}
+	p := printer.HTML(parser.Parse(v))
 	p = replaceAll(p, h3Pat, func(out, src []byte, m []int) []byte {
 		out = append(out, `<h4 id="`...)
 		out = append(out, src[m[2]:m[3]]...)
@@ -341,27 +503,47 @@ func commentFn(v string) htemp.HTML {
 // commentTextFn formats a source code comment as text.
 func commentTextFn(v string) string {
 	const indent = "    "
-	var buf bytes.Buffer
-	godoc.ToText(&buf, v, indent, "\t", 80-2*len(indent))
-	p := buf.Bytes()
-	return string(p)
+	printer := &comment.Printer{
+		DocLinkURL:     docLinkURL,
+		TextPrefix:     indent,
+		TextCodePrefix: indent + "\t",
+		TextWidth:      80 - 2*len(indent),
+	}
+	return string(printer.Text(new(comment.Parser).Parse(v)))
+}
+
+// docLinkURL returns the URL of the page of the target of a doc link.
+func docLinkURL(link *comment.DocLink) string {
+	name := link.Name
+	if link.Recv != "" {
+		name = link.Recv + "." + name
+	}
+	return formatPathFrag(link.ImportPath, name)
 }
 
 var period = []byte{'.'}
 
 func codeFn(c doc.Code, typ *doc.Type) htemp.HTML {
 	var buf bytes.Buffer
+	buf.WriteString("<pre>")
+	writeCode(&buf, c, typ)
+	buf.WriteString("</pre>")
+	return htemp.HTML(buf.String())
+}
+
+// writeCode writes the HTML of the annotated code. The anchors of the fields
+// and methods in the declaration of typ link to themselves.
+func writeCode(buf *bytes.Buffer, c doc.Code, typ *doc.Type) {
 	last := 0
 	src := []byte(c.Text)
-	buf.WriteString("<pre>")
 	for _, a := range c.Annotations {
-		htemp.HTMLEscape(&buf, src[last:a.Pos])
+		htemp.HTMLEscape(buf, src[last:a.Pos])
 		switch a.Kind {
 		case doc.PackageLinkAnnotation:
 			buf.WriteString(`<a href="`)
 			buf.WriteString(formatPathFrag(c.Paths[a.PathIndex], ""))
 			buf.WriteString(`">`)
-			htemp.HTMLEscape(&buf, src[a.Pos:a.End])
+			htemp.HTMLEscape(buf, src[a.Pos:a.End])
 			buf.WriteString(`</a>`)
 		case doc.LinkAnnotation, doc.BuiltinAnnotation:
 			var p string
@@ -375,30 +557,36 @@ func codeFn(c doc.Code, typ *doc.Type) htemp.HTML {
 			buf.WriteString(`<a href="`)
 			buf.WriteString(formatPathFrag(p, string(n)))
 			buf.WriteString(`">`)
-			htemp.HTMLEscape(&buf, src[a.Pos:a.End])
+			htemp.HTMLEscape(buf, src[a.Pos:a.End])
 			buf.WriteString(`</a>`)
 		case doc.CommentAnnotation:
 			buf.WriteString(`<span class="com">`)
-			htemp.HTMLEscape(&buf, src[a.Pos:a.End])
+			htemp.HTMLEscape(buf, src[a.Pos:a.End])
 			buf.WriteString(`</span>`)
 		case doc.AnchorAnnotation:
-			buf.WriteString(`<span id="`)
+			id := string(src[a.Pos:a.End])
 			if typ != nil {
-				htemp.HTMLEscape(&buf, []byte(typ.Name))
-				buf.WriteByte('.')
+				id = typ.Name + "." + id
 			}
-			htemp.HTMLEscape(&buf, src[a.Pos:a.End])
+			buf.WriteString(`<span id="`)
+			htemp.HTMLEscape(buf, []byte(id))
 			buf.WriteString(`">`)
-			htemp.HTMLEscape(&buf, src[a.Pos:a.End])
+			if typ != nil {
+				buf.WriteString(`<a class="x-anchor" href="#`)
+				htemp.HTMLEscape(buf, []byte(id))
+				buf.WriteString(`">`)
+				htemp.HTMLEscape(buf, src[a.Pos:a.End])
+				buf.WriteString(`</a>`)
+			} else {
+				htemp.HTMLEscape(buf, src[a.Pos:a.End])
+			}
 			buf.WriteString(`</span>`)
 		default:
-			htemp.HTMLEscape(&buf, src[a.Pos:a.End])
+			htemp.HTMLEscape(buf, src[a.Pos:a.End])
 		}
 		last = int(a.End)
 	}
-	htemp.HTMLEscape(&buf, src[last:])
-	buf.WriteString("</pre>")
-	return htemp.HTML(buf.String())
+	htemp.HTMLEscape(buf, src[last:])
 }
 
 var isInterfacePat = regexp.MustCompile(`^type [^ ]+ interface`)
@@ -413,6 +601,11 @@ func gaAccountFn() string {
 	return gaAccount
 }
 
+// percentFn returns the fraction f as a rounded percentage.
+func percentFn(f float64) int {
+	return int(math.Round(f * 100))
+}
+
 func noteTitleFn(s string) string {
 	return strings.Title(strings.ToLower(s))
 }
@@ -424,6 +617,31 @@ func htmlCommentFn(s string) htemp.HTML {
 var mimeTypes = map[string]string{
 	".html": htmlMIMEType,
 	".txt":  textMIMEType,
+	".xml":  "application/opensearchdescription+xml; charset=utf-8",
+}
+
+// streamTemplate executes the template like executeTemplate, but the page
+// is sent while the template executes if the response is buffered by
+// runHandler. The browser starts to render the header and the index of a
+// large package page before the declarations are written, and the server
+// does not hold the page in memory. A template error after the page started
+// ends the response.
+func streamTemplate(resp http.ResponseWriter, name string, status int, header http.Header, data interface{}) error {
+	if rb, ok := resp.(*httputil.ResponseBuffer); ok && status == http.StatusOK {
+		resp = streamWriter{rb}
+	}
+	return executeTemplate(resp, name, status, header, data)
+}
+
+// streamWriter streams the response buffered by the ResponseBuffer from
+// the call to WriteHeader.
+type streamWriter struct {
+	*httputil.ResponseBuffer
+}
+
+func (w streamWriter) WriteHeader(status int) {
+	w.ResponseBuffer.WriteHeader(status)
+	w.Stream()
 }
 
 func executeTemplate(resp http.ResponseWriter, name string, status int, header http.Header, data interface{}) error {
@@ -435,7 +653,12 @@ func executeTemplate(resp http.ResponseWriter, name string, status int, header h
 		mimeType = textMIMEType
 	}
 	resp.Header().Set("Content-Type", mimeType)
+	templatesMu.RLock()
 	t := templates[name]
+	if tt := translatedTemplates[resp.Header().Get("Content-Language")][name]; tt != nil {
+		t = tt
+	}
+	templatesMu.RUnlock()
 	if t == nil {
 		return fmt.Errorf("template %s not found", name)
 	}
@@ -450,61 +673,175 @@ var templates = map[string]interface {
 	Execute(io.Writer, interface{}) error
 }{}
 
+// templatesMu guards templates, templateVersions and the message catalogs
+// while the templates are parsed again in development mode.
+var templatesMu sync.RWMutex
+
+// translatedTemplates maps languages to the templates with the messages
+// translated to the language. Guarded by templatesMu.
+var translatedTemplates = map[string]map[string]*htemp.Template{}
+
+// parseTemplates reads the message catalogs and parses htmlTemplateSets and
+// textTemplateSets.
+func parseTemplates() error {
+	c, err := readCatalogs(*assetsDir, *overrideDir)
+	if err != nil {
+		return err
+	}
+	setCatalogs(c)
+	templatesMu.Lock()
+	translatedTemplates = map[string]map[string]*htemp.Template{}
+	templatesMu.Unlock()
+	if err := parseHTMLTemplates(htmlTemplateSets); err != nil {
+		return err
+	}
+	return parseTextTemplates(textTemplateSets)
+}
+
+// joinTemplateDir returns the paths of the template files in base. Files in
+// the -template_override_dir directory replace the files in base.
 func joinTemplateDir(base string, files []string) []string {
 	result := make([]string, len(files))
 	for i := range files {
-		result[i] = filepath.Join(base, "templates", files[i])
+		result[i] = assetFile(base, filepath.Join("templates", files[i]))
 	}
 	return result
 }
 
+// assetFile returns the path of the file in base or, if the file exists in
+// the -template_override_dir directory, in the override directory.
+func assetFile(base, name string) string {
+	if *overrideDir != "" {
+		p := filepath.Join(*overrideDir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(base, name)
+}
+
+// templateVersions maps template names to a hash of the files in the
+// template set. The hash is included in entity tags so that pages cached by
+// clients are invalidated when the templates change.
+var templateVersions = map[string]string{}
+
+func hashTemplateFiles(files []string) (string, error) {
+	h := md5.New()
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func parseHTMLTemplates(sets [][]string) error {
+	templatesMu.RLock()
+	langs := catalogs
+	templatesMu.RUnlock()
 	for _, set := range sets {
-		templateName := set[0]
-		t := htemp.New("")
-		t.Funcs(htemp.FuncMap{
-			"code":              codeFn,
-			"comment":           commentFn,
-			"equal":             reflect.DeepEqual,
-			"gaAccount":         gaAccountFn,
-			"host":              hostFn,
-			"htmlComment":       htmlCommentFn,
-			"importPath":        importPathFn,
-			"isInterface":       isInterfaceFn,
-			"isValidImportPath": gosrc.IsValidPath,
-			"map":               mapFn,
-			"noteTitle":         noteTitleFn,
-			"relativePath":      relativePathFn,
-			"sidebarEnabled":    func() bool { return *sidebarEnabled },
-			"staticPath":        func(p string) string { return cacheBusters.AppendQueryParam(p, "v") },
-			"templateName":      func() string { return templateName },
-		})
-		if _, err := t.ParseFiles(joinTemplateDir(*assetsDir, set)...); err != nil {
+		t, version, err := parseHTMLTemplateSet(set, langs[defaultLanguage], defaultLanguage)
+		if err != nil {
 			return err
 		}
-		t = t.Lookup("ROOT")
-		if t == nil {
-			return fmt.Errorf("ROOT template not found in %v", set)
+		translations := make(map[string]*htemp.Template)
+		for lang, c := range langs {
+			if lang == defaultLanguage {
+				continue
+			}
+			translations[lang], _, err = parseHTMLTemplateSet(set, c, lang)
+			if err != nil {
+				return err
+			}
 		}
+		templatesMu.Lock()
+		templateVersions[set[0]] = version
 		templates[set[0]] = t
+		for lang, t := range translations {
+			if translatedTemplates[lang] == nil {
+				translatedTemplates[lang] = make(map[string]*htemp.Template)
+			}
+			translatedTemplates[lang][set[0]] = t
+		}
+		templatesMu.Unlock()
 	}
 	return nil
 }
 
+// parseHTMLTemplateSet parses the set with the messages translated to the
+// language lang by the catalog c and returns the ROOT template and the hash of
+// the files.
+func parseHTMLTemplateSet(set []string, c *catalog, lang string) (*htemp.Template, string, error) {
+	templateName := set[0]
+	t := htemp.New("")
+	t.Funcs(htemp.FuncMap{
+		"alertClass":        alertClassFn,
+		"announcements":     activeAnnouncements,
+		"code":              codeFn,
+		"comment":           commentFn,
+		"equal":             reflect.DeepEqual,
+		"gaAccount":         gaAccountFn,
+		"goVersions":        gosrc.GoRootVersions,
+		"majorVersion":      majorVersion,
+		"host":              hostFn,
+		"htmlComment":       htmlCommentFn,
+		"importPath":        importPathFn,
+		"isInterface":       isInterfaceFn,
+		"isValidImportPath": gosrc.IsValidPath,
+		"lang":              func() string { return lang },
+		"liveUpdates":       func() bool { return *liveUpdates },
+		"languages":         languageOptions,
+		"map":               mapFn,
+		"vendoredPath":      vendoredPath,
+		"msg":               c.msg,
+		"noteTitle":         noteTitleFn,
+		"percent":           percentFn,
+		"relativePath":      relativePathFn,
+		"readOnly":          func() bool { return *readOnly },
+		"sidebarEnabled":    func() bool { return *sidebarEnabled },
+		"staticPath":        func(p string) string { return cacheBusters.AppendQueryParam(p, "v") },
+		"templateName":      func() string { return templateName },
+		"translated":        translated,
+	})
+	files := joinTemplateDir(*assetsDir, set)
+	if _, err := t.ParseFiles(files...); err != nil {
+		return nil, "", err
+	}
+	version, err := hashTemplateFiles(files)
+	if err != nil {
+		return nil, "", err
+	}
+	t = t.Lookup("ROOT")
+	if t == nil {
+		return nil, "", fmt.Errorf("ROOT template not found in %v", set)
+	}
+	return t, version, nil
+}
+
 func parseTextTemplates(sets [][]string) error {
 	for _, set := range sets {
 		t := ttemp.New("")
 		t.Funcs(ttemp.FuncMap{
 			"comment": commentTextFn,
 		})
-		if _, err := t.ParseFiles(joinTemplateDir(*assetsDir, set)...); err != nil {
+		files := joinTemplateDir(*assetsDir, set)
+		if _, err := t.ParseFiles(files...); err != nil {
+			return err
+		}
+		version, err := hashTemplateFiles(files)
+		if err != nil {
 			return err
 		}
 		t = t.Lookup("ROOT")
 		if t == nil {
 			return fmt.Errorf("ROOT template not found in %v", set)
 		}
+		templatesMu.Lock()
+		templateVersions[set[0]] = version
 		templates[set[0]] = t
+		templatesMu.Unlock()
 	}
 	return nil
 }
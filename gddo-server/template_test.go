@@ -7,11 +7,18 @@
 package main
 
 import (
+	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/golang/gddo/doc"
+	"github.com/golang/gddo/httputil"
 )
 
 func TestFlashMessages(t *testing.T) {
@@ -31,3 +38,119 @@ func TestFlashMessages(t *testing.T) {
 		t.Errorf("got messages %+v, want %+v", actualMessages, expectedMessages)
 	}
 }
+
+func TestSymbols(t *testing.T) {
+	pdoc := newTDoc(&doc.Package{
+		Consts: []*doc.Value{{Decl: doc.Code{Text: "const A = 1", Annotations: []doc.Annotation{{Pos: 6, End: 7, Kind: doc.AnchorAnnotation}}}}},
+		Funcs:  []*doc.Func{{Name: "F"}},
+		Types: []*doc.Type{{
+			Name:    "T",
+			Decl:    doc.Code{Text: "type T struct { X int }", Annotations: []doc.Annotation{{Pos: 16, End: 17, Kind: doc.AnchorAnnotation}}},
+			Funcs:   []*doc.Func{{Name: "NewT"}},
+			Methods: []*doc.Func{{Name: "M"}},
+		}},
+	})
+	got, err := pdoc.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"id":"A","kind":"constant"},{"id":"F","kind":"function"},{"id":"T","kind":"type"},{"id":"T.X","kind":"field"},{"id":"NewT","kind":"function"},{"id":"T.M","kind":"method"}]`
+	if got != want {
+		t.Errorf("Symbols() = %s, want %s", got, want)
+	}
+}
+
+func TestDocLinks(t *testing.T) {
+	pdoc := newTDoc(&doc.Package{DocLinks: map[string]doc.DocLink{
+		"Reader":       {Name: "Reader"},
+		"http.Handler": {ImportPath: "net/http", Name: "Handler"},
+	}})
+	got := string(pdoc.Comment("Serve reads from a [*Reader] and calls [http.Handler] or [Other].\n\n\tr := [Reader]\n\nSee [http.Handler]."))
+	for _, want := range []string{
+		`from a <a href="#Reader">*Reader</a> and calls <a href="/net/http#Handler">http.Handler</a> or [Other].`,
+		`<pre>r := [Reader]`,
+		`See <a href="/net/http#Handler">http.Handler</a>.`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Comment() = %s, want %s in output", got, want)
+		}
+	}
+}
+
+func TestCommentSyntax(t *testing.T) {
+	pdoc := newTDoc(&doc.Package{DocLinks: map[string]doc.DocLink{"File": {Name: "File"}}})
+	got := string(pdoc.Comment(`Package a reads [io.Reader] values. See the [Go spec].
+
+# Usage
+
+The steps are:
+  - open the [*File]
+  - read it
+
+[Go spec]: https://go.dev/ref/spec
+`))
+	for _, want := range []string{
+		`<a href="/io#Reader">io.Reader</a>`,
+		`<a href="https://go.dev/ref/spec">Go spec</a>`,
+		`<h4 id="hdr-Usage">Usage <a class="permalink" href="#hdr-Usage">&para</a></h4>`,
+		`<li>open the <a href="#File">*File</a>`,
+		`<li>read it`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Comment() = %s, want %s in output", got, want)
+		}
+	}
+	if strings.Contains(got, "[Go spec]:") {
+		t.Errorf("Comment() = %s, want link definition removed", got)
+	}
+
+	if got, want := commentTextFn("See [io.Reader]."), "    See io.Reader.\n"; got != want {
+		t.Errorf("commentTextFn() = %q, want %q", got, want)
+	}
+}
+
+func TestStreamTemplate(t *testing.T) {
+	*assetsDir, _ = filepath.Abs("assets")
+	cacheBusters.Handler = http.NotFoundHandler()
+	if err := parseHTMLTemplates([][]string{{"notfound.html", "common.html", "layout.html"}}); err != nil {
+		t.Fatal(err)
+	}
+	var streaming bool
+	h := handler(func(resp http.ResponseWriter, req *http.Request) error {
+		if err := streamTemplate(resp, "notfound.html", http.StatusOK, nil, map[string]interface{}{}); err != nil {
+			return err
+		}
+		streaming = resp.(*httputil.ResponseBuffer).Streaming()
+		return errors.New("error after the page started")
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !streaming {
+		t.Error("response not streamed")
+	}
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "</html>") {
+		t.Errorf("status %d, body %q; want the complete page with status 200", w.Code, w.Body.String())
+	}
+}
+
+func TestJoinTemplateDirOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "override")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "templates"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "templates", "common.html"), []byte(`{{define "Header"}}{{end}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer func(d string) { *overrideDir = d }(*overrideDir)
+	*overrideDir = dir
+
+	got := joinTemplateDir("assets", []string{"home.html", "common.html"})
+	want := []string{filepath.Join("assets", "templates", "home.html"), filepath.Join(dir, "templates", "common.html")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("joinTemplateDir() = %v, want %v", got, want)
+	}
+}
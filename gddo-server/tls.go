@@ -0,0 +1,92 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements serving HTTPS with certificates from files or from
+// Let's Encrypt.
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/golang/gddo/httputil"
+)
+
+var (
+	httpsAddr     = flag.String("https", "", "Listen for HTTPS connections on this address. Requires -tls_cert and -tls_key or -acme_hosts.")
+	tlsCertFile   = flag.String("tls_cert", "", "File with the PEM encoded TLS certificate chain.")
+	tlsKeyFile    = flag.String("tls_key", "", "File with the PEM encoded TLS private key.")
+	acmeHosts     = flag.String("acme_hosts", "", "Comma separated host names to get certificates for from Let's Encrypt. The -http address must be reachable on port 80 for the challenges.")
+	acmeCacheDir  = flag.String("acme_cache_dir", "acme-cache", "Directory to store the certificates from Let's Encrypt.")
+	acmeEmail     = flag.String("acme_email", "", "Contact email for the Let's Encrypt account.")
+	redirectHTTPS = flag.Bool("redirect_https", false, "Redirect HTTP requests to HTTPS.")
+)
+
+// httpsRedirectHandler redirects requests to the same URL on the HTTPS
+// address addr.
+type httpsRedirectHandler struct {
+	addr string
+}
+
+func (h httpsRedirectHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(resp, "Use HTTPS", http.StatusBadRequest)
+		return
+	}
+	host := httputil.StripPort(req.Host)
+	if _, port, err := net.SplitHostPort(h.addr); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(resp, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// listenAndServe serves h on the -http address and, if set, the -https
+// address.
+func listenAndServe(h http.Handler) error {
+	if *httpsAddr == "" {
+		return http.ListenAndServe(*httpAddr, h)
+	}
+
+	srv := &http.Server{
+		Addr:      *httpsAddr,
+		Handler:   h,
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	httpHandler := h
+	if *redirectHTTPS {
+		httpHandler = httpsRedirectHandler{*httpsAddr}
+	}
+	switch {
+	case *tlsCertFile != "" && *tlsKeyFile != "":
+	case *acmeHosts != "":
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(*acmeCacheDir),
+			HostPolicy: autocert.HostWhitelist(strings.Split(*acmeHosts, ",")...),
+			Email:      *acmeEmail,
+		}
+		srv.TLSConfig = m.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+		httpHandler = m.HTTPHandler(httpHandler)
+	default:
+		return errors.New("-https requires -tls_cert and -tls_key or -acme_hosts")
+	}
+
+	go func() {
+		if err := http.ListenAndServe(*httpAddr, httpHandler); err != nil {
+			slog.Error("serve HTTP", "err", err)
+		}
+	}()
+	return srv.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile)
+}
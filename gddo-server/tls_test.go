@@ -0,0 +1,35 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var httpsRedirectTests = []struct {
+	addr, method, url string
+	status            int
+	location          string
+}{
+	{":443", "GET", "http://godoc.org/github.com/user/repo?imports", http.StatusMovedPermanently, "https://godoc.org/github.com/user/repo?imports"},
+	{":443", "HEAD", "http://godoc.org:8080/", http.StatusMovedPermanently, "https://godoc.org/"},
+	{":8443", "GET", "http://localhost:8080/-/about", http.StatusMovedPermanently, "https://localhost:8443/-/about"},
+	{":443", "POST", "http://godoc.org/-/refresh", http.StatusBadRequest, ""},
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	for _, tt := range httpsRedirectTests {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		resp := httptest.NewRecorder()
+		httpsRedirectHandler{tt.addr}.ServeHTTP(resp, req)
+		if resp.Code != tt.status || resp.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s on %s: got %d %q, want %d %q", tt.method, tt.url, tt.addr, resp.Code, resp.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
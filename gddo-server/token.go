@@ -0,0 +1,99 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var apiTokensFile = flag.String("api_tokens", "", "File with the tokens, one per line, accepted by the API refresh endpoint, by the administrative endpoints and, without a quota, by the bulk API endpoints. A line is a token optionally followed by the role of the token, viewer by default, operator or admin, and by the name of the token recorded in the audit log. If empty, the refresh endpoint is open only to signed in users with the operator role.")
+
+// apiToken is a token in the -api_tokens file.
+type apiToken struct {
+	value string
+	role  role
+	name  string
+}
+
+// apiTokens is the list of tokens read from the -api_tokens file. It is
+// guarded by configMu.
+var apiTokens []apiToken
+
+// readAPITokens returns the tokens in the file.
+func readAPITokens(fname string) ([]apiToken, error) {
+	if fname == "" {
+		return nil, nil
+	}
+	p, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []apiToken
+	for i, line := range strings.Split(string(p), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+		t := apiToken{value: fields[0], role: roleViewer, name: "api"}
+		if len(fields) > 1 {
+			var ok bool
+			if t.role, ok = parseRole(fields[1]); !ok || t.role == roleNone {
+				return nil, fmt.Errorf("%s:%d: unknown role %q", fname, i+1, fields[1])
+			}
+		}
+		if len(fields) > 2 {
+			t.name = strings.Join(fields[2:], " ")
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// checkAPIToken returns true if the request has a bearer token in the
+// -api_tokens file or has the operator role.
+func checkAPIToken(req *http.Request) bool {
+	return validAPIToken(req) || requestRole(req) >= roleOperator
+}
+
+// validAPIToken returns true if the request has a bearer token in the
+// -api_tokens file.
+func validAPIToken(req *http.Request) bool {
+	return requestToken(req) != nil
+}
+
+// requestToken returns the token in the -api_tokens file of the bearer token
+// of the request or nil.
+func requestToken(req *http.Request) *apiToken {
+	token := []byte(bearerToken(req))
+	if len(token) == 0 {
+		return nil
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	for i := range apiTokens {
+		if subtle.ConstantTimeCompare([]byte(apiTokens[i].value), token) == 1 {
+			return &apiTokens[i]
+		}
+	}
+	return nil
+}
+
+// bearerToken returns the bearer token in the Authorization header of the
+// request or "".
+func bearerToken(req *http.Request) string {
+	const prefix = "Bearer "
+	h := req.Header.Get("Authorization")
+	if !strings.HasPrefix(h, prefix) {
+		return ""
+	}
+	return h[len(prefix):]
+}
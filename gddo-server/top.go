@@ -0,0 +1,84 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/golang/gddo/database"
+)
+
+// topTabs are the tabs of the top packages page in display order.
+var topTabs = []struct {
+	Name  string
+	Title string
+}{
+	{"imported", "Most imported"},
+	{"viewed", "Most viewed this week"},
+	{"trending", "Trending"},
+}
+
+// serveTop serves the page of top packages computed by the top packages
+// background task.
+func serveTop(resp http.ResponseWriter, req *http.Request) error {
+	tab := req.Form.Get("tab")
+	if tab == "" {
+		tab = topTabs[0].Name
+	}
+	top, err := db.Top()
+	if err != nil {
+		return err
+	}
+	var pkgs []database.TopPackage
+	if top != nil {
+		switch tab {
+		case "imported":
+			pkgs = top.Imported
+		case "viewed":
+			pkgs = top.Viewed
+		case "trending":
+			pkgs = top.Trending
+		default:
+			return &httpError{status: http.StatusNotFound}
+		}
+	}
+	pkgs, err = filterTopPackages(req, pkgs)
+	if err != nil {
+		return err
+	}
+	resp.Header().Set("Cache-Control", "public, max-age=3600")
+	return executeTemplate(resp, "top.html", http.StatusOK, nil, map[string]interface{}{
+		"tabs": topTabs,
+		"tab":  tab,
+		"top":  top,
+		"pkgs": pkgs,
+	})
+}
+
+// filterTopPackages removes the packages not allowed or in private
+// repositories from pkgs.
+func filterTopPackages(req *http.Request, pkgs []database.TopPackage) ([]database.TopPackage, error) {
+	var paths []database.Package
+	for _, pkg := range pkgs {
+		paths = append(paths, database.Package{Path: pkg.Path})
+	}
+	paths, err := filterPrivate(req, filterAllowed(paths))
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool)
+	for _, pkg := range paths {
+		visible[pkg.Path] = true
+	}
+	var result []database.TopPackage
+	for _, pkg := range pkgs {
+		if visible[pkg.Path] {
+			result = append(result, pkg)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// This file implements tracing of requests, database calls and fetches. The
+// spans are exported to an OpenTelemetry collector with the JSON encoding of
+// the OTLP/HTTP protocol, and the trace context is propagated with the W3C
+// traceparent header.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	otlpEndpoint     = flag.String("otlp_endpoint", "", "Base URL of the OTLP/HTTP collector to export trace spans to, for example http://localhost:4318. Empty disables tracing.")
+	traceServiceName = flag.String("trace_service_name", "gddo-server", "Service name of the exported trace spans.")
+)
+
+const (
+	traceparentHeader = "Traceparent"
+
+	// spanBatchSize is the maximum number of spans exported in one request.
+	spanBatchSize = 512
+
+	// spanExportInterval is the maximum time a finished span waits to be
+	// exported.
+	spanExportInterval = 5 * time.Second
+
+	// spanQueueSize is the number of finished spans waiting to be exported.
+	// Spans are dropped when the queue is full.
+	spanQueueSize = 4 * spanBatchSize
+)
+
+// Span kinds and status codes defined by OTLP.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+// span is a timed operation in a trace. The methods of a nil span do
+// nothing so that callers do not check whether tracing is enabled.
+type span struct {
+	traceID  [16]byte
+	id       [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []otlpKeyValue
+	err   error
+}
+
+type spanKey struct{}
+
+// spanFromContext returns the current span in ctx or nil.
+func spanFromContext(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+// startSpan starts a span with the name as a child of the current span in
+// ctx and returns a copy of ctx with the new span. The span is nil if
+// tracing is disabled.
+func startSpan(ctx context.Context, name string, kind int) (context.Context, *span) {
+	if spans == nil {
+		return ctx, nil
+	}
+	s := &span{name: name, kind: kind, start: time.Now()}
+	if parent := spanFromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.id
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.id[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// startServerSpan starts the span of the inbound request. The span continues
+// the trace in the traceparent header of the request if any.
+func startServerSpan(req *http.Request) (context.Context, *span) {
+	ctx := req.Context()
+	if spans == nil {
+		return ctx, nil
+	}
+	if traceID, parentID, ok := parseTraceparent(req.Header.Get(traceparentHeader)); ok {
+		ctx = context.WithValue(ctx, spanKey{}, &span{traceID: traceID, id: parentID})
+	}
+	ctx, s := startSpan(ctx, "HTTP "+req.Method, spanKindServer)
+	s.setAttr("http.method", req.Method)
+	s.setAttr("http.target", req.URL.RequestURI())
+	if id := requestID(ctx); id != "" {
+		s.setAttr("request_id", id)
+	}
+	return ctx, s
+}
+
+// setAttr sets an attribute of the span. The value is a string, an int or a
+// bool.
+func (s *span) setAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	var v otlpValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case int:
+		i := strconv.Itoa(value)
+		v.IntValue = &i
+	case bool:
+		v.BoolValue = &value
+	default:
+		str := fmt.Sprint(value)
+		v.StringValue = &str
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, otlpKeyValue{key, v})
+	s.mu.Unlock()
+}
+
+// finish ends the span with the error and queues the span for export.
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.err = err
+	s.mu.Unlock()
+	select {
+	case spans <- s:
+	default:
+	}
+}
+
+// traceparent returns the value of the traceparent header propagating the
+// span.
+func (s *span) traceparent() string {
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.id[:]) + "-01"
+}
+
+// parseTraceparent returns the trace ID and parent span ID in the value of a
+// version 00 traceparent header.
+func parseTraceparent(h string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(h, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return traceID, parentID, false
+	}
+	if traceID == [16]byte{} || parentID == [8]byte{} {
+		return traceID, parentID, false
+	}
+	return traceID, parentID, true
+}
+
+// traceDB times the database call with the name in a span of ctx.
+func traceDB(ctx context.Context, name string, fn func() error) error {
+	_, s := startSpan(ctx, name, spanKindClient)
+	s.setAttr("db.system", "redis")
+	err := fn()
+	s.finish(err)
+	return err
+}
+
+// spans is the queue of finished spans or nil if tracing is disabled.
+var spans chan *span
+
+// setupTracing starts exporting spans to the collector at the endpoint. The
+// spans are not recorded if the endpoint is empty.
+func setupTracing(endpoint string) {
+	if endpoint == "" {
+		return
+	}
+	spans = make(chan *span, spanQueueSize)
+	e := &spanExporter{
+		url:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	go e.run(spans)
+}
+
+type spanExporter struct {
+	url    string
+	client *http.Client
+}
+
+func (e *spanExporter) run(c chan *span) {
+	ticker := time.NewTicker(spanExportInterval)
+	var batch []*span
+	for {
+		select {
+		case s := <-c:
+			batch = append(batch, s)
+			if len(batch) < spanBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := e.export(batch); err != nil {
+			slog.Warn("export spans", "spans", len(batch), "err", err)
+		}
+		batch = nil
+	}
+}
+
+func (e *spanExporter) export(batch []*span) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(otlpTraces(*traceServiceName, batch)); err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", &buf)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", e.url, resp.Status)
+	}
+	return nil
+}
+
+// The types below are the JSON encoding of an OTLP trace export request.
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// otlpTraces returns the export request for the spans of the service.
+func otlpTraces(service string, batch []*span) *otlpTracesRequest {
+	var ss otlpScopeSpans
+	ss.Scope.Name = "github.com/golang/gddo/gddo-server"
+	for _, s := range batch {
+		s.mu.Lock()
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.id[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        s.attrs,
+		}
+		if s.parentID != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: spanStatusError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		ss.Spans = append(ss.Spans, o)
+	}
+	var rs otlpResourceSpans
+	rs.Resource.Attributes = []otlpKeyValue{{"service.name", otlpValue{StringValue: &service}}}
+	rs.ScopeSpans = []otlpScopeSpans{ss}
+	return &otlpTracesRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
@@ -0,0 +1,111 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var parseTraceparentTests = []struct {
+	h  string
+	ok bool
+}{
+	{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true},
+	{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", true},
+	{"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", false},
+	{"00-00000000000000000000000000000000-b7ad6b7169203331-01", false},
+	{"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", false},
+	{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b716920333x-01", false},
+	{"", false},
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for _, tt := range parseTraceparentTests {
+		traceID, parentID, ok := parseTraceparent(tt.h)
+		if ok != tt.ok {
+			t.Errorf("parseTraceparent(%q) ok = %v, want %v", tt.h, ok, tt.ok)
+			continue
+		}
+		if ok {
+			s := &span{traceID: traceID, id: parentID}
+			if got, want := s.traceparent(), tt.h[:len(tt.h)-2]+"01"; got != want {
+				t.Errorf("traceparent() = %q, want %q", got, want)
+			}
+		}
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	saved := spans
+	spans = make(chan *span, 10)
+	defer func() { spans = saved }()
+
+	var outbound string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Get(traceparentHeader)
+	}))
+	defer ts.Close()
+
+	h := handler(func(resp http.ResponseWriter, req *http.Request) error {
+		r, err := fetchClient(req.Context()).Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		r.Body.Close()
+		return traceDB(req.Context(), "db.Get", func() error { return errors.New("db down") })
+	})
+	const inbound = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(traceparentHeader, inbound)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var batch []*span
+	for len(spans) > 0 {
+		batch = append(batch, <-spans)
+	}
+	if len(batch) != 3 {
+		t.Fatalf("got %d spans, want 3", len(batch))
+	}
+	fetch, dbs, server := batch[0], batch[1], batch[2]
+	if server.kind != spanKindServer || server.traceID != fetch.traceID || server.traceID != dbs.traceID {
+		t.Errorf("spans not in one trace: %+v", batch)
+	}
+	if got := server.traceparent()[:36]; got != inbound[:36] {
+		t.Errorf("server span trace %q, want inbound trace %q", got, inbound[:36])
+	}
+	if fetch.parentID != server.id || dbs.parentID != server.id {
+		t.Errorf("child spans not parented by server span")
+	}
+	if outbound != fetch.traceparent() {
+		t.Errorf("outbound traceparent %q, want %q", outbound, fetch.traceparent())
+	}
+	if dbs.err == nil || server.err == nil {
+		t.Errorf("database error not recorded in spans")
+	}
+
+	p, err := json.Marshal(otlpTraces("test", batch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"test"}}]}`,
+		`"traceId":"0af7651916cd43dd8448eb211c80319c"`,
+		`"parentSpanId":"b7ad6b7169203331"`,
+		`"name":"db.Get","kind":3`,
+		`"status":{"code":2,"message":"db down"}`,
+		`{"key":"http.status_code","value":{"intValue":"200"}}`,
+	} {
+		if !strings.Contains(string(p), want) {
+			t.Errorf("export request %s does not contain %s", p, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/golang/gddo/database"
+)
+
+// The owner of a project can set the packages in the vendor directory at the
+// root of the project to be indexed. The vendored packages are documented at
+// their import paths in the vendor directory, labelled with the project
+// vendoring them and the original import path, and are hidden from the
+// search results. The pages of the project link the vendor directory instead
+// of listing the vendored packages with the subdirectories.
+
+// vendored is the vendoring of a package or of a vendor directory.
+type vendored struct {
+	// Parent is the import path of the directory with the vendor directory.
+	Parent string
+
+	// Path is the original import path of the vendored package or "" for
+	// the vendor directory.
+	Path string
+}
+
+// vendoredPath returns the vendoring of the package or directory with the
+// import path or nil if the import path is not in a vendor directory.
+func vendoredPath(importPath string) *vendored {
+	if strings.HasSuffix(importPath, "/vendor") {
+		return &vendored{Parent: strings.TrimSuffix(importPath, "/vendor")}
+	}
+	i := strings.LastIndex(importPath, "/vendor/")
+	if i < 0 {
+		return nil
+	}
+	return &vendored{Parent: importPath[:i], Path: importPath[i+len("/vendor/"):]}
+}
+
+// splitVendored returns the packages under the import path not in a vendor
+// directory below the import path and the import path of the vendor
+// directory of the import path, if packages are vendored in it.
+func splitVendored(importPath string, pkgs []database.Package) ([]database.Package, string) {
+	var result []database.Package
+	vendorDir := ""
+	for _, pkg := range pkgs {
+		v := vendoredPath(pkg.Path)
+		if v == nil || len(v.Parent) < len(importPath) {
+			result = append(result, pkg)
+			continue
+		}
+		if v.Parent == importPath {
+			vendorDir = importPath + "/vendor"
+		}
+	}
+	return result, vendorDir
+}
+
+// deleteVendored deletes the vendored packages of the project.
+func deleteVendored(projectRoot string) error {
+	pkgs, err := db.Project(projectRoot)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if vendoredPath(pkg.Path) == nil {
+			continue
+		}
+		if err := db.Delete(pkg.Path); err != nil {
+			return err
+		}
+		pages.invalidate(pkg.Path)
+	}
+	pages.invalidate(projectRoot)
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+var vendoredPathTests = []struct {
+	importPath string
+	want       *vendored
+}{
+	{"github.com/user/repo", nil},
+	{"github.com/user/repo/vendorlib", nil},
+	{"github.com/user/repo/vendor", &vendored{Parent: "github.com/user/repo"}},
+	{"github.com/user/repo/vendor/example.com/x", &vendored{Parent: "github.com/user/repo", Path: "example.com/x"}},
+	{"github.com/user/repo/vendor/example.com/x/vendor/example.com/y", &vendored{Parent: "github.com/user/repo/vendor/example.com/x", Path: "example.com/y"}},
+}
+
+func TestVendoredPath(t *testing.T) {
+	for _, tt := range vendoredPathTests {
+		if got := vendoredPath(tt.importPath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("vendoredPath(%q) = %+v, want %+v", tt.importPath, got, tt.want)
+		}
+	}
+}
+
+func TestSplitVendored(t *testing.T) {
+	pkgs := []database.Package{
+		{Path: "github.com/user/repo/sub"},
+		{Path: "github.com/user/repo/vendor"},
+		{Path: "github.com/user/repo/vendor/example.com/x"},
+		{Path: "github.com/user/repo/vendor/example.com/x/vendor/example.com/y"},
+	}
+
+	got, vendorDir := splitVendored("github.com/user/repo", pkgs)
+	if want := pkgs[:1]; !reflect.DeepEqual(got, want) || vendorDir != "github.com/user/repo/vendor" {
+		t.Errorf("splitVendored(root) = %v, %q, want %v and the vendor directory", got, vendorDir, want)
+	}
+
+	got, vendorDir = splitVendored("github.com/user/repo/vendor", pkgs[2:])
+	if want := pkgs[2:3]; !reflect.DeepEqual(got, want) || vendorDir != "" {
+		t.Errorf("splitVendored(vendor) = %v, %q, want %v", got, vendorDir, want)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+)
+
+// The known vulnerabilities of the modules in the database are copied from
+// the Go vulnerability database (https://go.dev/security/vuln/database) by a
+// background task. The task reads the index of the modules with
+// vulnerabilities and fetches the OSV entries of the modules found in the
+// database.
+
+var vulndbURL = flag.String("vulndb_url", "https://vuln.go.dev", "Go vulnerability database read by the vulnerability updates. Empty disables the updates.")
+
+// vulnModule is an entry of the index/modules.json file of the
+// vulnerability database.
+type vulnModule struct {
+	Path  string `json:"path"`
+	Vulns []struct {
+		ID       string    `json:"id"`
+		Modified time.Time `json:"modified"`
+	} `json:"vulns"`
+}
+
+// osvEntry is the subset of an entry in the Open Source Vulnerability format
+// (https://ossf.github.io/osv-schema/) used by the package pages.
+type osvEntry struct {
+	ID       string    `json:"id"`
+	Modified time.Time `json:"modified"`
+	Aliases  []string  `json:"aliases"`
+	Summary  string    `json:"summary"`
+	Details  string    `json:"details"`
+	Affected []struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced string `json:"introduced"`
+				Fixed      string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+		EcosystemSpecific struct {
+			Imports []struct {
+				Path    string   `json:"path"`
+				Symbols []string `json:"symbols"`
+			} `json:"imports"`
+		} `json:"ecosystem_specific"`
+	} `json:"affected"`
+	References []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"references"`
+	DatabaseSpecific struct {
+		URL string `json:"url"`
+	} `json:"database_specific"`
+}
+
+// osvEntries caches the fetched entries by ID. It is used only by the
+// background task.
+var osvEntries = make(map[string]*osvEntry)
+
+// getVulnJSON decodes the JSON file at the path of the vulnerability
+// database at base to v.
+func getVulnJSON(base, p string, v interface{}) error {
+	u := strings.TrimSuffix(base, "/") + "/" + p
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		p, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s: %s", u, resp.Status, strings.TrimSpace(string(p)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", u, err)
+	}
+	return nil
+}
+
+// fetchVulns returns the vulnerabilities of the modules in the vulnerability
+// database at base for which known returns true. Entries are fetched again
+// only when they are modified.
+func fetchVulns(base string, known func(module string) (bool, error)) (map[string][]database.Vuln, error) {
+	var modules []vulnModule
+	if err := getVulnJSON(base, "index/modules.json", &modules); err != nil {
+		return nil, err
+	}
+	result := make(map[string][]database.Vuln)
+	for _, m := range modules {
+		ok, err := known(m.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		for _, v := range m.Vulns {
+			e := osvEntries[v.ID]
+			if e == nil || e.Modified.Before(v.Modified) {
+				e = &osvEntry{}
+				if err := getVulnJSON(base, "ID/"+url.PathEscape(v.ID)+".json", e); err != nil {
+					return nil, err
+				}
+				osvEntries[v.ID] = e
+			}
+			if vuln, ok := e.vuln(m.Path); ok {
+				result[m.Path] = append(result[m.Path], vuln)
+			}
+		}
+	}
+	return result, nil
+}
+
+// vuln returns the vulnerability of the module described by the entry or
+// false if the entry does not affect the module.
+func (e *osvEntry) vuln(module string) (database.Vuln, bool) {
+	v := database.Vuln{
+		ID:       e.ID,
+		Aliases:  e.Aliases,
+		Summary:  e.Summary,
+		URL:      e.DatabaseSpecific.URL,
+		Modified: e.Modified,
+	}
+	if v.Summary == "" {
+		v.Summary = strings.SplitN(strings.TrimSpace(e.Details), "\n\n", 2)[0]
+	}
+	if v.URL == "" {
+		for _, r := range e.References {
+			if r.Type == "ADVISORY" || (v.URL == "" && r.Type == "WEB") {
+				v.URL = r.URL
+			}
+		}
+	}
+	found := false
+	for _, a := range e.Affected {
+		if a.Package.Name != module || a.Package.Ecosystem != "Go" {
+			continue
+		}
+		found = true
+		for _, r := range a.Ranges {
+			if r.Type != "SEMVER" {
+				continue
+			}
+			for _, ev := range r.Events {
+				if ev.Fixed == "" {
+					continue
+				}
+				if module == database.StdlibModule {
+					v.Fixed = append(v.Fixed, "go"+ev.Fixed)
+				} else {
+					v.Fixed = append(v.Fixed, "v"+ev.Fixed)
+				}
+			}
+		}
+		for _, imp := range a.EcosystemSpecific.Imports {
+			v.Packages = append(v.Packages, database.VulnPackage{Path: imp.Path, Symbols: imp.Symbols})
+		}
+	}
+	return v, found
+}
+
+// knownModule returns true if the module is the standard library or a
+// package or project in the database.
+func knownModule(module string) (bool, error) {
+	if module == database.StdlibModule {
+		return true, nil
+	}
+	if ok, err := db.Exists(module); err != nil || ok {
+		return ok, err
+	}
+	pkgs, err := db.Project(module)
+	return len(pkgs) > 0, err
+}
+
+// updateVulns copies the vulnerabilities of the modules in the database from
+// the -vulndb_url.
+func updateVulns() error {
+	if *vulndbURL == "" {
+		return nil
+	}
+	vulns, err := fetchVulns(*vulndbURL, knownModule)
+	if err != nil {
+		return err
+	}
+	if err := db.SetVulns(vulns); err != nil {
+		return err
+	}
+	pages.reset()
+	slog.Info("updated vulnerabilities", "modules", len(vulns))
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/gddo/database"
+)
+
+const testVulnEntry = `{
+  "id": "GO-2023-0001",
+  "modified": "2023-05-01T00:00:00Z",
+  "aliases": ["CVE-2023-1234"],
+  "details": "Parse panics on malformed input.\n\nMore details.",
+  "affected": [{
+    "package": {"name": "github.com/user/repo", "ecosystem": "Go"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.3"}]}],
+    "ecosystem_specific": {"imports": [{"path": "github.com/user/repo/parse", "symbols": ["Parse", "Parser.Next"]}]}
+  }],
+  "references": [{"type": "WEB", "url": "https://example.com/issue"}],
+  "database_specific": {"url": "https://pkg.go.dev/vuln/GO-2023-0001"}
+}`
+
+func TestFetchVulns(t *testing.T) {
+	defer func() { osvEntries = make(map[string]*osvEntry) }()
+	entryFetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index/modules.json":
+			w.Write([]byte(`[
+  {"path": "github.com/user/repo", "vulns": [{"id": "GO-2023-0001", "modified": "2023-05-01T00:00:00Z"}]},
+  {"path": "github.com/other/repo", "vulns": [{"id": "GO-2023-0002", "modified": "2023-05-01T00:00:00Z"}]}
+]`))
+		case "/ID/GO-2023-0001.json":
+			entryFetches++
+			w.Write([]byte(testVulnEntry))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	known := func(module string) (bool, error) { return module == "github.com/user/repo", nil }
+	for i := 0; i < 2; i++ {
+		vulns, err := fetchVulns(ts.URL, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vulns) != 1 || len(vulns["github.com/user/repo"]) != 1 {
+			t.Fatalf("fetchVulns() = %v", vulns)
+		}
+		v := vulns["github.com/user/repo"][0]
+		want := database.Vuln{
+			ID:       "GO-2023-0001",
+			Aliases:  []string{"CVE-2023-1234"},
+			Summary:  "Parse panics on malformed input.",
+			URL:      "https://pkg.go.dev/vuln/GO-2023-0001",
+			Modified: v.Modified,
+			Fixed:    []string{"v1.2.3"},
+			Packages: []database.VulnPackage{{Path: "github.com/user/repo/parse", Symbols: []string{"Parse", "Parser.Next"}}},
+		}
+		if !reflect.DeepEqual(v, want) || v.Modified.IsZero() {
+			t.Errorf("fetchVulns() vuln = %+v, want %+v", v, want)
+		}
+	}
+	if entryFetches != 1 {
+		t.Errorf("entry fetched %d times, want 1", entryFetches)
+	}
+
+	if _, err := fetchVulns(ts.URL+"/missing", known); err == nil {
+		t.Error("fetchVulns() with missing index did not return an error")
+	}
+}
+
+func TestOSVEntryVuln(t *testing.T) {
+	var e osvEntry
+	if err := json.Unmarshal([]byte(`{
+  "id": "GO-2023-0003",
+  "summary": "Excessive memory use in net/http",
+  "affected": [{
+    "package": {"name": "stdlib", "ecosystem": "Go"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.20.5"}]}]
+  }],
+  "references": [{"type": "WEB", "url": "https://example.com/web"}, {"type": "ADVISORY", "url": "https://example.com/advisory"}]
+}`), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok := e.vuln(database.StdlibModule)
+	if !ok || !reflect.DeepEqual(v.Fixed, []string{"go1.20.5"}) || v.URL != "https://example.com/advisory" || len(v.Packages) != 0 {
+		t.Errorf("vuln(stdlib) = %+v, %v", v, ok)
+	}
+	if _, ok := e.vuln("github.com/user/repo"); ok {
+		t.Error("vuln() returned entry for unaffected module")
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/gosrc"
+)
+
+// Clients with an API key watch the packages below an import path prefix
+// with /api/v1/watches. The watches in the -watch_webhooks flag are owned by
+// the server. The crawls, the owners and the blocklist queue events of the
+// watched packages in the database. The Watch notifications background task
+// sends one notification per watch for the queued events, by email through
+// the -smtp_addr server or as a Slack-compatible webhook. The email watches
+// of the API keys are pending until the address follows the signed link of
+// the confirmation email. The notifications of the watches of the API keys
+// do not list the packages in private repositories.
+
+var (
+	watchWebhooks = flag.String("watch_webhooks", "", "Comma separated list of watches notified by webhook, each as prefix=URL.")
+	notifyBaseURL = flag.String("notify_base_url", "https://godoc.org", "Base URL of the links in the watch notifications.")
+	smtpAddr      = flag.String("smtp_addr", "", "Address host:port of the SMTP server sending the watch notifications by email. Empty disables the email notifications.")
+	smtpFrom      = flag.String("smtp_from", "", "Sender of the watch notifications sent by email.")
+	smtpUser      = flag.String("smtp_user", "", "User name of the SMTP server. Empty disables the authentication.")
+	smtpPassword  = flag.String("smtp_password", "", "Password of smtp_user.")
+)
+
+// configWatchOwner is the owner of the watches in the -watch_webhooks flag.
+const configWatchOwner = "config"
+
+// maxWatchesPerKey is the number of watches of an API key.
+const maxWatchesPerKey = 100
+
+const (
+	// watchConfirmMaxAge is the time to confirm an email watch.
+	watchConfirmMaxAge = 7 * 24 * time.Hour
+
+	// watchConfirmPrefix is the prefix of the signed IDs of the watches in
+	// the confirmation links.
+	watchConfirmPrefix = "watch:"
+)
+
+var errBadWatchWebhooks = errors.New("watch_webhooks: want prefix=URL")
+
+// parseWatchWebhooks parses the -watch_webhooks flag.
+func parseWatchWebhooks(s string) ([]database.Watch, error) {
+	var watches []database.Watch
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		i := strings.Index(f, "=")
+		if i <= 0 || !isWebhookURL(f[i+1:]) {
+			return nil, errBadWatchWebhooks
+		}
+		watches = append(watches, database.Watch{Owner: configWatchOwner, Prefix: f[:i], Webhook: f[i+1:]})
+	}
+	return watches, nil
+}
+
+func isWebhookURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// checkKeyWebhook returns an error if the webhook URL of a watch of an API
+// key is not an https URL allowed by the outbound policy.
+func checkKeyWebhook(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("webhook is not an https URL")
+	}
+	return gosrc.CheckOutboundHost(u.Host)
+}
+
+// syncConfigWatches replaces the watches owned by the server with the
+// watches in the -watch_webhooks flag. The watches are stored in the
+// database because the events are queued only when there are watches.
+func syncConfigWatches() error {
+	configured, err := parseWatchWebhooks(*watchWebhooks)
+	if err != nil {
+		return err
+	}
+	watches, err := db.Watches()
+	if err != nil {
+		return err
+	}
+	for _, w := range watches {
+		if w.Owner == configWatchOwner {
+			if _, err := db.RemoveWatch(w.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, w := range configured {
+		if _, err := db.AddWatch(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendWatchNotifications sends the queued events to the watches. A failed
+// notification is logged and not retried.
+func sendWatchNotifications() error {
+	events, err := db.PopWatchEvents()
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	watches, err := db.Watches()
+	if err != nil {
+		return err
+	}
+	public, err := publicPaths(events)
+	if err != nil {
+		return err
+	}
+	for _, w := range watches {
+		if w.Pending {
+			continue
+		}
+		var matched []database.WatchEvent
+		for _, e := range events {
+			if w.Matches(e) && (w.Owner == configWatchOwner || public[e.Path]) {
+				matched = append(matched, e)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		text := watchMessage(w.Prefix, matched)
+		if w.Webhook != "" {
+			err = postWebhook(w.Webhook, text, w.Owner != configWatchOwner)
+		} else {
+			err = sendEmail(w.Email, "Package changes below "+w.Prefix, text)
+		}
+		if err != nil {
+			slog.Error("watch notification", "watch", w.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+// publicPaths returns the set of the paths of the events that are not
+// packages in private repositories.
+func publicPaths(events []database.WatchEvent) (map[string]bool, error) {
+	var pkgs []database.Package
+	for _, e := range events {
+		pkgs = append(pkgs, database.Package{Path: e.Path})
+	}
+	pkgs, err := db.FilterPrivate(pkgs)
+	if err != nil {
+		return nil, err
+	}
+	public := make(map[string]bool)
+	for _, pkg := range pkgs {
+		public[pkg.Path] = true
+	}
+	return public, nil
+}
+
+// watchMessage returns the text of the notification of the events of the
+// packages below prefix.
+func watchMessage(prefix string, events []database.WatchEvent) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Changes of the packages below %s:\n\n", prefix)
+	seen := make(map[database.WatchEvent]bool)
+	for _, e := range events {
+		k := database.WatchEvent{Path: e.Path, Kind: e.Kind}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		switch e.Kind {
+		case database.WatchAPIChanged:
+			fmt.Fprintf(&buf, "%s: the exported API changed, %s/changes/%s\n", e.Path, *notifyBaseURL, e.Path)
+		case database.WatchHidden:
+			fmt.Fprintf(&buf, "%s: hidden from the search results by the owner\n", e.Path)
+		case database.WatchBlocked:
+			fmt.Fprintf(&buf, "%s: removed and blocked\n", e.Path)
+		default:
+			fmt.Fprintf(&buf, "%s: %s\n", e.Path, e.Kind)
+		}
+	}
+	return buf.String()
+}
+
+// keyWebhookClient posts the notifications of the watches of the API keys.
+// The connections and the redirects are checked against the outbound policy.
+var keyWebhookClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: timeoutDial,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		return checkKeyWebhook(req.URL.String())
+	},
+}
+
+// postWebhook posts the text in the JSON payload of Slack incoming webhooks.
+// The webhooks of the API keys, keyed true, are posted with the outbound
+// policy; the webhooks in the -watch_webhooks flag are trusted.
+func postWebhook(u, text string, keyed bool) error {
+	p, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{text})
+	if err != nil {
+		return err
+	}
+	c := httpClient
+	if keyed {
+		if err := checkKeyWebhook(u); err != nil {
+			return err
+		}
+		c = keyWebhookClient
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(p))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if keyed {
+		req = req.WithContext(gosrc.WithOutboundPolicy(req.Context()))
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func sendEmail(to, subject, text string) error {
+	if *smtpAddr == "" {
+		return errors.New("smtp_addr not set")
+	}
+	var auth smtp.Auth
+	if *smtpUser != "" {
+		host, _, _ := net.SplitHostPort(*smtpAddr)
+		auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+	}
+	msg := "From: " + *smtpFrom + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		strings.Replace(text, "\n", "\r\n", -1)
+	return smtp.SendMail(*smtpAddr, auth, *smtpFrom, []string{to}, []byte(msg))
+}
+
+// sendWatchConfirmation sends the link confirming the pending email watch.
+func sendWatchConfirmation(w database.Watch) error {
+	link := *notifyBaseURL + "/-/watch/confirm?token=" + url.QueryEscape(signValue(watchConfirmPrefix+w.ID, time.Now().Add(watchConfirmMaxAge)))
+	text := fmt.Sprintf("A watch of the changes of the packages below %s was requested for this address.\n\n"+
+		"Open %s within %d days to receive the notifications. Ignore this email if you did not request the watch.\n",
+		w.Prefix, link, int(watchConfirmMaxAge/(24*time.Hour)))
+	return sendEmail(w.Email, "Confirm the watch of "+w.Prefix, text)
+}
+
+// serveWatchConfirm activates the pending email watch of the signed token
+// parameter of the confirmation link.
+func serveWatchConfirm(resp http.ResponseWriter, req *http.Request) error {
+	v, ok := verifyValue(req.Form.Get("token"))
+	if !ok || !strings.HasPrefix(v, watchConfirmPrefix) {
+		return &httpError{status: http.StatusBadRequest}
+	}
+	if *readOnly {
+		return errReadOnly
+	}
+	ok, err := db.ConfirmWatch(v[len(watchConfirmPrefix):])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &httpError{status: http.StatusNotFound}
+	}
+	resp.Header().Set("Content-Type", textMIMEType)
+	_, err = io.WriteString(resp, "The watch is confirmed.\n")
+	return err
+}
+
+// serveAPIV1Watches serves the watches of the API key of the request. A GET
+// request lists the watches, a POST request with the prefix and the email or
+// webhook parameters adds a watch and a DELETE request removes the watch
+// with the id parameter. The prefix must be allowed by -allow_prefixes. The
+// webhook is an https URL of a host allowed by the outbound policy. An email
+// watch is pending until the address confirms the watch.
+func serveAPIV1Watches(resp http.ResponseWriter, req *http.Request) error {
+	if err := checkAPIKey(resp, req); err != nil {
+		return err
+	}
+	key := bearerToken(req)
+	if k, err := db.APIKey(key); err != nil {
+		return err
+	} else if k == nil {
+		return &httpError{status: http.StatusUnauthorized, header: http.Header{"Www-Authenticate": {`Bearer realm="api"`}}}
+	}
+	owner := database.APIKeyID(key)
+	if req.Method != "GET" && req.Method != "HEAD" && *readOnly {
+		return errReadOnly
+	}
+
+	watches, err := db.Watches()
+	if err != nil {
+		return err
+	}
+	own := []database.Watch{}
+	for _, w := range watches {
+		if w.Owner == owner {
+			w.Owner = ""
+			own = append(own, w)
+		}
+	}
+
+	switch req.Method {
+	case "GET", "HEAD":
+		start, end, p, err := paginate(resp, req, len(own))
+		if err != nil {
+			return err
+		}
+		return writeAPIV1List(resp, p, own[start:end])
+	case "POST":
+		w := database.Watch{
+			Owner:   owner,
+			Prefix:  strings.Trim(req.Form.Get("prefix"), "/"),
+			Email:   req.Form.Get("email"),
+			Webhook: req.Form.Get("webhook"),
+		}
+		if w.Prefix == "" || strings.ContainsAny(w.Prefix, " \r\n") || (w.Email == "") == (w.Webhook == "") || !isAllowed(w.Prefix) {
+			return &httpError{status: http.StatusBadRequest}
+		}
+		if w.Email != "" {
+			a, err := mail.ParseAddress(w.Email)
+			if err != nil || a.Address != w.Email {
+				return &httpError{status: http.StatusBadRequest}
+			}
+			w.Pending = true
+		}
+		if w.Webhook != "" {
+			if err := checkKeyWebhook(w.Webhook); err != nil {
+				return &httpError{status: http.StatusBadRequest, err: err}
+			}
+		}
+		if len(own) >= maxWatchesPerKey {
+			return &httpError{status: http.StatusForbidden}
+		}
+		if w.ID, err = db.AddWatch(w); err != nil {
+			return err
+		}
+		if w.Pending {
+			if err := sendWatchConfirmation(w); err != nil {
+				db.RemoveWatch(w.ID)
+				return err
+			}
+		}
+		w.Owner = ""
+		resp.Header().Set("Content-Type", jsonMIMEType)
+		resp.WriteHeader(http.StatusCreated)
+		return json.NewEncoder(resp).Encode(&w)
+	case "DELETE":
+		id := req.Form.Get("id")
+		for _, w := range own {
+			if w.ID == id {
+				if _, err := db.RemoveWatch(id); err != nil {
+					return err
+				}
+				resp.WriteHeader(http.StatusNoContent)
+				return nil
+			}
+		}
+		return &httpError{status: http.StatusNotFound}
+	}
+	return &httpError{status: http.StatusMethodNotAllowed}
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/gddo/database"
+	"github.com/golang/gddo/gosrc"
+)
+
+func TestParseWatchWebhooks(t *testing.T) {
+	watches, err := parseWatchWebhooks(" github.com/user=https://hooks.example.com/a, ,example.com/x=http://localhost:8080/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watches) != 2 || watches[0].Prefix != "github.com/user" || watches[0].Webhook != "https://hooks.example.com/a" || watches[1].Owner != configWatchOwner {
+		t.Errorf("parseWatchWebhooks() = %+v", watches)
+	}
+	for _, s := range []string{"github.com/user", "=https://hooks.example.com/a", "github.com/user=ftp://example.com/"} {
+		if _, err := parseWatchWebhooks(s); err == nil {
+			t.Errorf("parseWatchWebhooks(%q) returned no error", s)
+		}
+	}
+}
+
+func TestWatchMessage(t *testing.T) {
+	defer func(s string) { *notifyBaseURL = s }(*notifyBaseURL)
+	*notifyBaseURL = "https://example.org"
+	got := watchMessage("github.com/user", []database.WatchEvent{
+		{Path: "github.com/user/repo", Kind: database.WatchAPIChanged},
+		{Path: "github.com/user/repo", Kind: database.WatchAPIChanged},
+		{Path: "github.com/user", Kind: database.WatchHidden},
+		{Path: "github.com/user/bad", Kind: database.WatchBlocked},
+	})
+	want := "Changes of the packages below github.com/user:\n\n" +
+		"github.com/user/repo: the exported API changed, https://example.org/changes/github.com/user/repo\n" +
+		"github.com/user: hidden from the search results by the owner\n" +
+		"github.com/user/bad: removed and blocked\n"
+	if got != want {
+		t.Errorf("watchMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	var text string
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var payload struct{ Text string }
+		json.NewDecoder(req.Body).Decode(&payload)
+		text = payload.Text
+		if strings.Contains(text, "fail") {
+			resp.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	if err := postWebhook(ts.URL, "hello", false); err != nil || text != "hello" {
+		t.Errorf("postWebhook() = %v, server received %q", err, text)
+	}
+	if err := postWebhook(ts.URL, "fail", false); err == nil {
+		t.Error("postWebhook() returned no error for status 400")
+	}
+	// The webhooks of the API keys are https URLs of public hosts.
+	if err := postWebhook(ts.URL, "key", true); err == nil || text == "key" {
+		t.Errorf("postWebhook(http URL of an API key) = %v, server received %q", err, text)
+	}
+}
+
+func TestCheckKeyWebhook(t *testing.T) {
+	if err := gosrc.SetOutboundPolicy(true, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer gosrc.SetOutboundPolicy(false, nil, nil)
+	for _, tt := range []struct {
+		url string
+		ok  bool
+	}{
+		{"https://8.8.8.8/hook", true},
+		{"http://8.8.8.8/hook", false},
+		{"https://127.0.0.1/hook", false},
+		{"https://169.254.169.254/latest/meta-data/", false},
+		{"https://localhost/hook", false},
+		{"https:///hook", false},
+	} {
+		if err := checkKeyWebhook(tt.url); (err == nil) != tt.ok {
+			t.Errorf("checkKeyWebhook(%q) = %v, want allowed %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestServeWatchConfirm(t *testing.T) {
+	for _, token := range []string{
+		"",
+		signValue("github.com/user/repo", time.Now().Add(time.Hour)),
+		signValue(watchConfirmPrefix+"id", time.Now().Add(-time.Hour)),
+	} {
+		req := httptest.NewRequest("GET", "http://godoc.org/-/watch/confirm?token="+url.QueryEscape(token), nil)
+		req.ParseForm()
+		if err, ok := serveWatchConfirm(httptest.NewRecorder(), req).(*httpError); !ok || err.status != http.StatusBadRequest {
+			t.Errorf("serveWatchConfirm(%q) = %v, want status 400", token, err)
+		}
+	}
+}
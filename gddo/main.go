@@ -0,0 +1,339 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// Command gddo is a command line client for the GoDoc.org API.
+//
+// Usage:
+//
+//	gddo [flags] search <query>
+//	gddo [flags] doc <path> [<symbol>]
+//	gddo [flags] refresh <path>
+//	gddo [flags] importers <path>
+//
+// Results are printed as tab separated lines unless the -json flag is set.
+// The doc command with a symbol, such as Reader or Reader.Read, prints the
+// declaration and the documentation of the symbol in the format of go doc.
+// The API token used to refresh packages is read from the -token flag or the
+// GDDO_TOKEN environment variable.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	apiURL     = flag.String("api", "https://api.godoc.org", "Base URL of the GoDoc API.")
+	token      = flag.String("token", os.Getenv("GDDO_TOKEN"), "API token.")
+	jsonOutput = flag.Bool("json", false, "Print the JSON response from the API.")
+)
+
+type command struct {
+	name    string
+	run     func(args []string) error
+	usage   string
+	maxArgs int
+}
+
+var commands = []*command{
+	{"search", runSearch, "search <query>", 1},
+	{"doc", runDoc, "doc <path> [<symbol>]", 2},
+	{"refresh", runRefresh, "refresh <path>", 1},
+	{"importers", runImporters, "importers <path>", 1},
+}
+
+func printUsage() {
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "%s [flags] %s\n", os.Args[0], c.usage)
+	}
+	flag.PrintDefaults()
+}
+
+// call sends a request to the API and decodes the JSON response to v. If the
+// -json flag is set, then the response is copied to stdout instead.
+func call(method, path string, form url.Values, v interface{}) error {
+	body, err := request(method, path, form)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if *jsonOutput {
+		_, err := io.Copy(os.Stdout, body)
+		return err
+	}
+	return json.NewDecoder(body).Decode(v)
+}
+
+// request sends a request to the API and returns the body of the response.
+func request(method, path string, form url.Values) (io.ReadCloser, error) {
+	u := strings.TrimSuffix(*apiURL, "/") + path
+	var body io.Reader
+	if method == "GET" && form != nil {
+		u += "?" + form.Encode()
+	} else if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if *token != "" {
+		req.Header.Set("Authorization", "Bearer "+*token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && e.Error.Message != "" {
+			return nil, errors.New(e.Error.Message)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+type pkgResults struct {
+	Results []struct {
+		Path     string `json:"path"`
+		Synopsis string `json:"synopsis"`
+	} `json:"results"`
+}
+
+func (r *pkgResults) print() {
+	for _, pkg := range r.Results {
+		fmt.Printf("%s\t%s\n", pkg.Path, pkg.Synopsis)
+	}
+}
+
+func runSearch(args []string) error {
+	var r pkgResults
+	if err := call("GET", "/search", url.Values{"q": {args[0]}}, &r); err != nil {
+		return err
+	}
+	r.print()
+	return nil
+}
+
+func runImporters(args []string) error {
+	var r pkgResults
+	if err := call("GET", "/importers/"+args[0], nil, &r); err != nil {
+		return err
+	}
+	r.print()
+	return nil
+}
+
+type decl struct {
+	Name string `json:"name,omitempty"`
+	Decl string `json:"decl"`
+	Doc  string `json:"doc,omitempty"`
+}
+
+type typeDecl struct {
+	decl
+	Consts  []decl `json:"consts,omitempty"`
+	Vars    []decl `json:"vars,omitempty"`
+	Funcs   []decl `json:"funcs,omitempty"`
+	Methods []decl `json:"methods,omitempty"`
+}
+
+type docResult struct {
+	ImportPath string     `json:"importPath"`
+	Name       string     `json:"name"`
+	Synopsis   string     `json:"synopsis"`
+	Version    string     `json:"version"`
+	Consts     []decl     `json:"consts"`
+	Vars       []decl     `json:"vars"`
+	Funcs      []decl     `json:"funcs"`
+	Types      []typeDecl `json:"types"`
+}
+
+func printDecls(kind string, decls []decl) {
+	for _, d := range decls {
+		fmt.Printf("%s\t%s\t%s\n", kind, d.Name, strings.Replace(d.Decl, "\n", " ", -1))
+	}
+}
+
+func runDoc(args []string) error {
+	if len(args) == 2 {
+		return runSymbolDoc(args[0], args[1])
+	}
+	var r docResult
+	if err := call("GET", "/doc/"+args[0], nil, &r); err != nil {
+		return err
+	}
+	fmt.Printf("package\t%s\t%s\t%s\n", r.Name, r.ImportPath, r.Version)
+	fmt.Printf("synopsis\t%s\n", r.Synopsis)
+	printDecls("const", r.Consts)
+	printDecls("var", r.Vars)
+	printDecls("func", r.Funcs)
+	for _, t := range r.Types {
+		printDecls("type", []decl{t.decl})
+		printDecls("const", t.Consts)
+		printDecls("var", t.Vars)
+		printDecls("func", t.Funcs)
+		printDecls("method", t.Methods)
+	}
+	return nil
+}
+
+// runSymbolDoc prints the documentation of the symbol in the package. The
+// symbol is the name of a constant, variable, function or type or a method
+// in the form Type.Method.
+func runSymbolDoc(importPath, symbol string) error {
+	body, err := request("GET", "/doc/"+importPath, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	var r docResult
+	if err := json.NewDecoder(body).Decode(&r); err != nil {
+		return err
+	}
+
+	// Constants and variables are matched by the names in the declaration.
+	flags := "(?m)"
+	if symbol == strings.ToLower(symbol) {
+		flags = "(?im)"
+	}
+	valuePat := regexp.MustCompile(flags + `^(?:const|var)?[ \t(]*` + regexp.QuoteMeta(symbol) + `\b`)
+
+	var found []interface{}
+	matchDecls := func(decls []decl, value bool) {
+		for _, d := range decls {
+			if (value && valuePat.MatchString(d.Decl)) || (!value && nameMatch(d.Name, symbol)) {
+				found = append(found, d)
+			}
+		}
+	}
+	if i := strings.Index(symbol, "."); i >= 0 {
+		for _, t := range r.Types {
+			if !nameMatch(t.Name, symbol[:i]) {
+				continue
+			}
+			for _, m := range t.Methods {
+				if nameMatch(m.Name, symbol[i+1:]) {
+					found = append(found, m)
+				}
+			}
+		}
+	} else {
+		matchDecls(r.Consts, true)
+		matchDecls(r.Vars, true)
+		matchDecls(r.Funcs, false)
+		for _, t := range r.Types {
+			if nameMatch(t.Name, symbol) {
+				found = append(found, t)
+				continue
+			}
+			matchDecls(t.Consts, true)
+			matchDecls(t.Vars, true)
+			matchDecls(t.Funcs, false)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no symbol %s in package %s", symbol, importPath)
+	}
+
+	if *jsonOutput {
+		e := json.NewEncoder(os.Stdout)
+		for _, v := range found {
+			if err := e.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	fmt.Printf("package %s // import %q\n\n", r.Name, r.ImportPath)
+	for _, v := range found {
+		switch v := v.(type) {
+		case decl:
+			printSymbol(v)
+		case typeDecl:
+			printSymbol(v.decl)
+			for _, decls := range [][]decl{v.Consts, v.Vars, v.Funcs, v.Methods} {
+				for _, d := range decls {
+					fmt.Println(d.Decl)
+				}
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// nameMatch returns true if name is the symbol name want. A lower case want
+// matches the name in any case.
+func nameMatch(name, want string) bool {
+	return name == want || (want == strings.ToLower(want) && strings.EqualFold(name, want))
+}
+
+// printSymbol prints the declaration and the indented documentation of a
+// symbol.
+func printSymbol(d decl) {
+	fmt.Println(d.Decl)
+	if doc := strings.TrimRight(d.Doc, "\n"); doc != "" {
+		for _, line := range strings.Split(doc, "\n") {
+			if line == "" {
+				fmt.Println()
+			} else {
+				fmt.Println("    " + line)
+			}
+		}
+	}
+	fmt.Println()
+}
+
+func runRefresh(args []string) error {
+	var r struct {
+		ImportPath string `json:"importPath"`
+	}
+	if err := call("POST", "/refresh", url.Values{"path": {args[0]}}, &r); err != nil {
+		return err
+	}
+	fmt.Println(r.ImportPath)
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gddo: ")
+	flag.Usage = printUsage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) >= 2 {
+		for _, c := range commands {
+			if args[0] == c.name && len(args)-1 <= c.maxArgs {
+				if err := c.run(args[1:]); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+		}
+	}
+	printUsage()
+	os.Exit(2)
+}
@@ -0,0 +1,154 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+var maxArchiveSize int64
+
+// SetMaxArchiveSize enables fetching of directory files from a single
+// repository archive instead of one request per file. Archives larger than
+// maxSize bytes, or larger than ten times maxSize decompressed, are not read;
+// the files are fetched one by one instead. A maxSize of zero disables
+// archive downloads.
+func SetMaxArchiveSize(maxSize int64) {
+	maxArchiveSize = maxSize
+}
+
+// maxArchiveExpansion bounds the size of the decompressed archive to a
+// multiple of the archive size limit.
+const maxArchiveExpansion = 10
+
+var errArchiveTooLarge = errors.New("archive too large")
+
+// archiveReader returns errArchiveTooLarge after more than n bytes are read.
+type archiveReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *archiveReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, errArchiveTooLarge
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n+1]
+	}
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	if r.n < 0 {
+		return n, errArchiveTooLarge
+	}
+	return n, err
+}
+
+// getFilesFromArchive sets the data for files in directory dir from the
+// gzipped tar archive at archiveURL when archive downloads are enabled. The
+// files are fetched from urls if archive downloads are disabled or if the
+// archive cannot be used.
+func (c *httpClient) getFilesFromArchive(archiveURL, dir string, urls []string, files []*File) error {
+	if maxArchiveSize > 0 && len(files) > 0 {
+		err := c.getArchiveFiles(archiveURL, dir, files)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Fetching files one by one after error fetching %s: %v", archiveURL, err)
+	}
+	return c.getFiles(urls, files)
+}
+
+// getArchiveFiles sets the data for files in directory dir from the gzipped
+// tar archive at archiveURL. Paths in the archive are expected to start with
+// a single top-level directory.
+func (c *httpClient) getArchiveFiles(archiveURL, dir string, files []*File) error {
+	resp, err := c.get(archiveURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return c.err(resp)
+	}
+	if resp.ContentLength > maxArchiveSize {
+		return errArchiveTooLarge
+	}
+
+	zr, err := gzip.NewReader(&archiveReader{r: resp.Body, n: maxArchiveSize})
+	if err != nil {
+		return &RemoteError{resp.Request.URL.Host, err}
+	}
+	byName := make(map[string]*File)
+	for _, f := range files {
+		byName[f.Name] = f
+	}
+
+	// The entries skipped by the tar reader are decompressed too, so the
+	// limit applies to all the entries.
+	dr := &archiveReader{r: zr, n: maxArchiveExpansion * maxArchiveSize}
+
+	dir = strings.Trim(dir, "/")
+	found := 0
+	tr := tar.NewReader(dr)
+	for found < len(files) {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err == errArchiveTooLarge {
+				return err
+			}
+			return &RemoteError{resp.Request.URL.Host, err}
+		}
+		if hdr.Size > dr.n {
+			return errArchiveTooLarge
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		// Remove the top-level directory.
+		name := hdr.Name
+		i := strings.Index(name, "/")
+		if i < 0 {
+			continue
+		}
+		name = name[i+1:]
+		fileDir, fileName := "", name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			fileDir, fileName = name[:i], name[i+1:]
+		}
+		f := byName[fileName]
+		if fileDir != dir || f == nil || f.Data != nil {
+			continue
+		}
+		// The data of a file larger than the file size limit is read up
+		// to the limit only.
+		f.Data, err = readFileData(tr)
+		if err != nil {
+			if err == errArchiveTooLarge {
+				return err
+			}
+			return &RemoteError{resp.Request.URL.Host, err}
+		}
+		found++
+	}
+	if found < len(files) {
+		for _, f := range files {
+			f.Data = nil
+		}
+		return fmt.Errorf("%d of %d files not found in archive", len(files)-found, len(files))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testArchive(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestGetFilesFromArchive(t *testing.T) {
+	archive := testArchive(t, map[string]string{
+		"repo-abc/a.go":     "package repo",
+		"repo-abc/sub/a.go": "package sub",
+		"repo-abc/sub/b.go": "package sub // b",
+	})
+	client := &http.Client{Transport: testTransport{
+		"https://example.com/archive.tar.gz": archive,
+		"https://example.com/raw/sub/a.go":   "package sub // raw",
+		"https://example.com/raw/sub/b.go":   "package sub // raw",
+	}}
+	urls := []string{"https://example.com/raw/sub/a.go", "https://example.com/raw/sub/b.go"}
+
+	defer SetMaxArchiveSize(0)
+	for _, tt := range []struct {
+		maxSize int64
+		a, b    string
+	}{
+		{1 << 20, "package sub", "package sub // b"},
+		{10, "package sub // raw", "package sub // raw"},
+		{0, "package sub // raw", "package sub // raw"},
+	} {
+		SetMaxArchiveSize(tt.maxSize)
+		files := []*File{{Name: "a.go"}, {Name: "b.go"}}
+		c := &httpClient{client: client}
+		if err := c.getFilesFromArchive("https://example.com/archive.tar.gz", "/sub", urls, files); err != nil {
+			t.Fatalf("maxSize %d: getFilesFromArchive returned error %v", tt.maxSize, err)
+		}
+		if string(files[0].Data) != tt.a || string(files[1].Data) != tt.b {
+			t.Errorf("maxSize %d: got %q, %q; want %q, %q", tt.maxSize, files[0].Data, files[1].Data, tt.a, tt.b)
+		}
+	}
+}
+
+func TestGetFilesFromArchiveDecompressedSize(t *testing.T) {
+	archive := testArchive(t, map[string]string{
+		"repo-abc/sub/a.go": "package sub\n" + strings.Repeat("\n", 1<<20),
+	})
+	if len(archive) > 4096 {
+		t.Fatalf("archive size %d, want at most 4096", len(archive))
+	}
+	client := &http.Client{Transport: testTransport{
+		"https://example.com/archive.tar.gz": archive,
+		"https://example.com/raw/sub/a.go":   "package sub // raw",
+	}}
+
+	defer SetMaxArchiveSize(0)
+	SetMaxArchiveSize(4096)
+	files := []*File{{Name: "a.go"}}
+	c := &httpClient{client: client}
+	if err := c.getArchiveFiles("https://example.com/archive.tar.gz", "/sub", files); err != errArchiveTooLarge {
+		t.Errorf("getArchiveFiles returned error %v, want %v", err, errArchiveTooLarge)
+	}
+	if err := c.getFilesFromArchive("https://example.com/archive.tar.gz", "/sub", []string{"https://example.com/raw/sub/a.go"}, files); err != nil {
+		t.Fatalf("getFilesFromArchive returned error %v", err)
+	}
+	if string(files[0].Data) != "package sub // raw" {
+		t.Errorf("got %q, want %q", files[0].Data, "package sub // raw")
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxAzurePages limits the number of pages fetched from a paginated Azure
+// DevOps API resource.
+const maxAzurePages = 10
+
+// azureAPIVersion is the version of the Azure DevOps REST API used by the
+// service.
+const azureAPIVersion = "7.0"
+
+// AddAzureDevOpsHost adds a service for the Azure DevOps organization or
+// Azure DevOps Server collection with the given base URL, for example
+// "https://dev.azure.com/example". Packages in the organization have import
+// paths of the form <host and path of base URL>/<project>/_git/<repo>[/dir].
+// If token is not "", then the token is sent as a personal access token with
+// every API request to the organization.
+func AddAzureDevOpsHost(baseURL, token string) error {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("azure devops: bad base URL %q", baseURL)
+	}
+	trustHost(u.Host)
+	var header http.Header
+	if token != "" {
+		header = http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(":"+token))}}
+	}
+	s := &azureService{baseURL: u.String(), prefix: u.Host + u.Path, header: header}
+	addService(&service{
+		pattern:    regexp.MustCompile(`^` + regexp.QuoteMeta(s.prefix) + `/(?P<project>[a-z0-9A-Z_.\-]+)/_git/(?P<repo>[a-z0-9A-Z_.\-]+)(?P<dir>/.*)?$`),
+		prefix:     s.prefix + "/",
+		get:        s.getDir,
+		getProject: s.getProject,
+	})
+	return nil
+}
+
+type azureService struct {
+	baseURL string
+	prefix  string
+	header  http.Header
+}
+
+func (s *azureService) client(client *http.Client) *httpClient {
+	return &httpClient{client: client, header: s.header, errFn: azureError}
+}
+
+func (s *azureService) setMatch(match map[string]string) {
+	match["base"] = s.baseURL
+	match["prefix"] = s.prefix
+	match["api"] = expand("{base}/{project}/_apis/git/repositories/{repo}", match)
+	match["version"] = azureAPIVersion
+}
+
+func azureError(resp *http.Response) error {
+	// Requests without valid credentials are answered with a sign in page.
+	if resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: authentication required (%s)", resp.StatusCode, resp.Request.URL.String())}
+	}
+	var e struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && e.Message != "" {
+		return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: %s (%s)", resp.StatusCode, e.Message, resp.Request.URL.String())}
+	}
+	return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: (%s)", resp.StatusCode, resp.Request.URL.String())}
+}
+
+// getAzurePages fetches the pages of a paginated Azure DevOps API resource.
+// The function next returns the value that the JSON for each page is decoded
+// to.
+func getAzurePages(c *httpClient, u string, next func() interface{}) error {
+	token := ""
+	for i := 0; i < maxAzurePages; i++ {
+		pu := u
+		if token != "" {
+			pu += "&continuationToken=" + url.QueryEscape(token)
+		}
+		resp, err := c.getJSON(pu, next())
+		if err != nil {
+			return err
+		}
+		token = resp.Header.Get("X-Ms-Continuationtoken")
+		if token == "" {
+			break
+		}
+	}
+	return nil
+}
+
+type azureRepo struct {
+	Name          string `json:"name"`
+	DefaultBranch string `json:"defaultBranch"`
+	Project       struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	} `json:"project"`
+}
+
+func (s *azureService) getRepo(c *httpClient, match map[string]string) (*azureRepo, error) {
+	var repo azureRepo
+	if _, err := c.getJSON(expand("{api}?api-version={version}", match), &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func (s *azureService) getDir(client *http.Client, match map[string]string, savedEtag string) (*Directory, error) {
+	c := s.client(client)
+	s.setMatch(match)
+
+	repo, err := s.getRepo(c, match)
+	if err != nil {
+		return nil, err
+	}
+
+	// Azure DevOps project and repository names are case-insensitive.
+	// Redirect if the requested names do not match the canonical names in the
+	// API response.
+	if repo.Name != "" && repo.Project.Name != "" && (repo.Project.Name != match["project"] || repo.Name != match["repo"]) {
+		match["project"] = repo.Project.Name
+		match["repo"] = repo.Name
+		return nil, NotFoundError{
+			Message:  "Azure DevOps import path has incorrect case.",
+			Redirect: expand("{prefix}/{project}/_git/{repo}{dir}", match),
+		}
+	}
+
+	type refsJSON struct {
+		Value []struct {
+			Name           string `json:"name"`
+			ObjectID       string `json:"objectId"`
+			PeeledObjectID string `json:"peeledObjectId"`
+		} `json:"value"`
+	}
+
+	tags := make(map[string]string)
+	releases := make(map[string]string)
+	for _, kind := range []string{"heads", "tags"} {
+		var pages []*refsJSON
+		err := getAzurePages(c, expand("{api}/refs?filter={0}/&peelTags=true&api-version={version}", match, kind), func() interface{} {
+			pages = append(pages, &refsJSON{})
+			return pages[len(pages)-1]
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, refs := range pages {
+			for _, ref := range refs.Value {
+				name := strings.TrimPrefix(ref.Name, "refs/"+kind+"/")
+				commit := ref.ObjectID
+				if ref.PeeledObjectID != "" {
+					// The object of an annotated tag is the tag, not the commit.
+					commit = ref.PeeledObjectID
+				}
+				tags[name] = commit
+				if kind == "tags" {
+					releases[name] = commit
+				}
+			}
+		}
+	}
+
+	defaultBranch := strings.TrimPrefix(repo.DefaultBranch, "refs/heads/")
+	if defaultBranch == "" {
+		defaultBranch = "master"
+	}
+
+	var commit string
+	match["tag"], commit, err = bestTag(tags, defaultBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit == savedEtag {
+		return nil, ErrNotModified
+	}
+	match["commit"] = commit
+
+	scopePath := match["dir"]
+	if scopePath == "" {
+		scopePath = "/"
+	}
+	var items struct {
+		Value []struct {
+			ObjectID      string `json:"objectId"`
+			GitObjectType string `json:"gitObjectType"`
+			Path          string `json:"path"`
+		} `json:"value"`
+	}
+	if _, err := c.getJSON(expand("{api}/items?scopePath={0}&recursionLevel=OneLevel&versionDescriptor.version={commit}&versionDescriptor.versionType=commit&api-version={version}", match, url.QueryEscape(scopePath)), &items); err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	var dataURLs []string
+	var subdirs []string
+
+	for _, item := range items.Value {
+		// The listing includes the directory itself.
+		if item.Path == scopePath {
+			continue
+		}
+		name := path.Base(item.Path)
+		switch {
+		case item.GitObjectType == "tree":
+			if isValidPathElement(name) {
+				subdirs = append(subdirs, name)
+			}
+		case item.GitObjectType == "blob" && isDocFile(name):
+			files = append(files, &File{Name: name, BrowseURL: expand("{base}/{project}/_git/{repo}?path={0}&version=GC{commit}", match, url.QueryEscape(item.Path))})
+			dataURLs = append(dataURLs, expand("{api}/blobs/{0}?$format=octetStream&api-version={version}", match, item.ObjectID))
+		}
+	}
+
+	if len(files) == 0 && len(subdirs) == 0 {
+		return nil, NotFoundError{Message: "No files in directory."}
+	}
+
+	// Azure DevOps serves repository archives only as zip files, so the
+	// files are always fetched one by one.
+	if err := c.getFiles(dataURLs, files); err != nil {
+		return nil, err
+	}
+
+	browseURL := expand("{base}/{project}/_git/{repo}", match)
+	if match["dir"] != "" {
+		browseURL = expand("{base}/{project}/_git/{repo}?path={0}&version=GC{commit}", match, url.QueryEscape(match["dir"]))
+	}
+
+	return &Directory{
+		BrowseURL:      browseURL,
+		Etag:           commit,
+		Version:        releaseTag(releases, commit),
+		Files:          files,
+		LineFmt:        "%s&line=%d",
+		ProjectName:    match["repo"],
+		ProjectRoot:    expand("{prefix}/{project}/_git/{repo}", match),
+		ProjectURL:     expand("{base}/{project}/_git/{repo}", match),
+		Subdirectories: subdirs,
+		VCS:            "git",
+		Private:        repo.Project.Visibility != "public",
+	}, nil
+}
+
+func (s *azureService) getProject(client *http.Client, match map[string]string) (*Project, error) {
+	s.setMatch(match)
+	repo, err := s.getRepo(s.client(client), match)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{
+		Description: repo.Project.Description,
+	}, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"net/http"
+	"testing"
+)
+
+var azureTestWeb = map[string]string{
+	"https://example.com/org/prj/_apis/git/repositories/pkg":           `{"name": "pkg", "defaultBranch": "refs/heads/main", "project": {"name": "prj", "visibility": "public"}}`,
+	"https://example.com/org/prj/_apis/git/repositories/pkg/refs":      `{"value": [{"name": "refs/heads/main", "objectId": "abc"}, {"name": "refs/tags/v1.0.0", "objectId": "def", "peeledObjectId": "abc"}]}`,
+	"https://example.com/org/prj/_apis/git/repositories/pkg/items":     `{"value": [{"path": "/sub", "gitObjectType": "tree"}, {"path": "/sub/sub.go", "gitObjectType": "blob", "objectId": "123"}, {"path": "/sub/.git", "gitObjectType": "tree"}, {"path": "/sub/x", "gitObjectType": "tree"}]}`,
+	"https://example.com/org/prj/_apis/git/repositories/pkg/blobs/123": "package sub",
+	"https://example.com/org/PRJ/_apis/git/repositories/pkg":           `{"name": "pkg", "project": {"name": "prj"}}`,
+}
+
+func TestGetAzureDevOpsDir(t *testing.T) {
+	savedServices := services
+	defer func() { services = savedServices }()
+	if err := AddAzureDevOpsHost("https://example.com/org/", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddAzureDevOpsHost("example.com", ""); err == nil {
+		t.Error("AddAzureDevOpsHost accepted base URL without scheme")
+	}
+
+	client := &http.Client{Transport: testTransport(azureTestWeb)}
+
+	dir, err := getStatic(client, "example.com/org/prj/_git/pkg/sub", "")
+	if err != nil {
+		t.Fatalf("getStatic returned error %v", err)
+	}
+	if dir.ProjectRoot != "example.com/org/prj/_git/pkg" || dir.Etag != "abc" || dir.Version != "v1.0.0" || dir.Private {
+		t.Errorf("dir = %+v, want public project root example.com/org/prj/_git/pkg, etag abc, version v1.0.0", dir)
+	}
+	if want := "https://example.com/org/prj/_git/pkg?path=%2Fsub&version=GCabc"; dir.BrowseURL != want {
+		t.Errorf("dir.BrowseURL = %q, want %q", dir.BrowseURL, want)
+	}
+	if len(dir.Subdirectories) != 1 || dir.Subdirectories[0] != "x" {
+		t.Errorf("dir.Subdirectories = %v, want [x]", dir.Subdirectories)
+	}
+	if len(dir.Files) != 1 || string(dir.Files[0].Data) != "package sub" {
+		t.Errorf("dir.Files = %+v, want sub.go", dir.Files)
+	}
+
+	_, err = getStatic(client, "example.com/org/PRJ/_git/pkg", "")
+	if e, ok := err.(NotFoundError); !ok || e.Redirect != "example.com/org/prj/_git/pkg" {
+		t.Errorf("getStatic with incorrect case returned %v, want redirect", err)
+	}
+}
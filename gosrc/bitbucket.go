@@ -25,6 +25,7 @@ var bitbucketEtagRe = regexp.MustCompile(`^(hg|git)-`)
 
 type bitbucketRepo struct {
 	Scm         string
+	IsPrivate   bool   `json:"is_private"`
 	CreatedOn   string `json:"created_on"`
 	LastUpdated string `json:"last_updated"`
 	ForkOf      struct {
@@ -100,7 +101,7 @@ func getBitbucketDir(client *http.Client, match map[string]string, savedEtag str
 		}
 	}
 
-	if err := c.getFiles(dataURLs, files); err != nil {
+	if err := c.getFilesFromArchive(expand("https://bitbucket.org/{owner}/{repo}/get/{commit}.tar.gz", match), match["dir"], dataURLs, files); err != nil {
 		return nil, err
 	}
 
@@ -115,6 +116,7 @@ func getBitbucketDir(client *http.Client, match map[string]string, savedEtag str
 		Subdirectories: contents.Directories,
 		VCS:            match["vcs"],
 		DeadEndFork:    isBitbucketDeadEndFork(repo),
+		Private:        repo.IsPrivate,
 	}, nil
 }
 
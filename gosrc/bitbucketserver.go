@@ -0,0 +1,274 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxBitbucketServerPages limits the number of pages fetched from a paginated
+// Bitbucket Server API resource.
+const maxBitbucketServerPages = 10
+
+// AddBitbucketServerHost adds a service for the Bitbucket Server or
+// Bitbucket Data Center instance with the given base URL, for example
+// "https://bitbucket.example.com". Packages on the instance have import paths
+// of the form <host and path of base URL>/<project key>/<repo slug>[/dir]. If
+// token is not "", then the token is sent as an HTTP access token with every
+// API request to the instance.
+func AddBitbucketServerHost(baseURL, token string) error {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("bitbucket server: bad base URL %q", baseURL)
+	}
+	trustHost(u.Host)
+	var header http.Header
+	if token != "" {
+		header = http.Header{"Authorization": {"Bearer " + token}}
+	}
+	s := &bitbucketServerService{baseURL: u.String(), prefix: u.Host + u.Path, header: header}
+	addService(&service{
+		pattern:    regexp.MustCompile(`^` + regexp.QuoteMeta(s.prefix) + `/(?P<project>[a-z0-9A-Z_.\-~]+)/(?P<repo>[a-z0-9A-Z_.\-]+)(?P<dir>/.*)?$`),
+		prefix:     s.prefix + "/",
+		get:        s.getDir,
+		getProject: s.getProject,
+	})
+	return nil
+}
+
+type bitbucketServerService struct {
+	baseURL string
+	prefix  string
+	header  http.Header
+}
+
+func (s *bitbucketServerService) client(client *http.Client) *httpClient {
+	return &httpClient{client: client, header: s.header, errFn: bitbucketServerError}
+}
+
+func (s *bitbucketServerService) setMatch(match map[string]string) {
+	match["base"] = s.baseURL
+	match["prefix"] = s.prefix
+	match["api"] = expand("{base}/rest/api/1.0/projects/{project}/repos/{repo}", match)
+}
+
+func bitbucketServerError(resp *http.Response) error {
+	var e struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && len(e.Errors) > 0 {
+		return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: %s (%s)", resp.StatusCode, e.Errors[0].Message, resp.Request.URL.String())}
+	}
+	return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: (%s)", resp.StatusCode, resp.Request.URL.String())}
+}
+
+// getBitbucketServerPages fetches the pages of a paginated Bitbucket Server
+// API resource. The function next returns the value that the JSON for each
+// page is decoded to and the paging fields in the value.
+func getBitbucketServerPages(c *httpClient, u string, next func() (interface{}, *bitbucketServerPage)) error {
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	start := 0
+	for i := 0; i < maxBitbucketServerPages; i++ {
+		v, page := next()
+		if _, err := c.getJSON(fmt.Sprintf("%s%slimit=100&start=%d", u, sep, start), v); err != nil {
+			return err
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			break
+		}
+		start = page.NextPageStart
+	}
+	return nil
+}
+
+type bitbucketServerPage struct {
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+type bitbucketServerRepo struct {
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	Project     struct {
+		Key string `json:"key"`
+	} `json:"project"`
+}
+
+func (s *bitbucketServerService) getRepo(c *httpClient, match map[string]string) (*bitbucketServerRepo, error) {
+	var repo bitbucketServerRepo
+	if _, err := c.getJSON(expand("{api}", match), &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func (s *bitbucketServerService) getDir(client *http.Client, match map[string]string, savedEtag string) (*Directory, error) {
+	c := s.client(client)
+	s.setMatch(match)
+
+	repo, err := s.getRepo(c, match)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bitbucket Server project keys are case-insensitive. Redirect if the
+	// requested names do not match the canonical names in the API response.
+	if repo.Project.Key != "" && repo.Slug != "" && (repo.Project.Key != match["project"] || repo.Slug != match["repo"]) {
+		match["project"] = repo.Project.Key
+		match["repo"] = repo.Slug
+		return nil, NotFoundError{
+			Message:  "Bitbucket Server import path has incorrect case.",
+			Redirect: expand("{prefix}/{project}/{repo}{dir}", match),
+		}
+	}
+
+	var defaultBranch struct {
+		DisplayID string `json:"displayId"`
+	}
+	if _, err := c.getJSON(expand("{api}/branches/default", match), &defaultBranch); err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	if defaultBranch.DisplayID == "" {
+		defaultBranch.DisplayID = "master"
+	}
+
+	type refsJSON struct {
+		bitbucketServerPage
+		Values []struct {
+			DisplayID    string `json:"displayId"`
+			LatestCommit string `json:"latestCommit"`
+		} `json:"values"`
+	}
+
+	tags := make(map[string]string)
+	releases := make(map[string]string)
+	for _, kind := range []string{"branches", "tags"} {
+		var pages []*refsJSON
+		err := getBitbucketServerPages(c, expand("{api}/{0}", match, kind), func() (interface{}, *bitbucketServerPage) {
+			p := &refsJSON{}
+			pages = append(pages, p)
+			return p, &p.bitbucketServerPage
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, refs := range pages {
+			for _, ref := range refs.Values {
+				tags[ref.DisplayID] = ref.LatestCommit
+				if kind == "tags" {
+					releases[ref.DisplayID] = ref.LatestCommit
+				}
+			}
+		}
+	}
+
+	var commit string
+	match["tag"], commit, err = bestTag(tags, defaultBranch.DisplayID)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit == savedEtag {
+		return nil, ErrNotModified
+	}
+	match["commit"] = commit
+
+	type browseJSON struct {
+		Children struct {
+			bitbucketServerPage
+			Values []struct {
+				Path struct {
+					Name     string `json:"name"`
+					ToString string `json:"toString"`
+				} `json:"path"`
+				Type string `json:"type"`
+			} `json:"values"`
+		} `json:"children"`
+	}
+	var pages []*browseJSON
+	err = getBitbucketServerPages(c, expand("{api}/browse{dir}?at={commit}", match), func() (interface{}, *bitbucketServerPage) {
+		p := &browseJSON{}
+		pages = append(pages, p)
+		return p, &p.Children.bitbucketServerPage
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	var dataURLs []string
+	var subdirs []string
+
+	for _, p := range pages {
+		for _, item := range p.Children.Values {
+			name := item.Path.Name
+			switch {
+			case item.Type == "DIRECTORY":
+				if isValidPathElement(name) {
+					subdirs = append(subdirs, name)
+				}
+			case item.Type == "FILE" && isDocFile(name):
+				filePath := path.Join(strings.TrimPrefix(match["dir"], "/"), item.Path.ToString)
+				files = append(files, &File{Name: name, BrowseURL: expand("{base}/projects/{project}/repos/{repo}/browse/{0}?at={commit}", match, filePath)})
+				dataURLs = append(dataURLs, expand("{base}/projects/{project}/repos/{repo}/raw/{0}?at={commit}", match, filePath))
+			}
+		}
+	}
+
+	if len(files) == 0 && len(subdirs) == 0 {
+		return nil, NotFoundError{Message: "No files in directory."}
+	}
+
+	if err := c.getFilesFromArchive(expand("{api}/archive?at={commit}&format=tar.gz&prefix={repo}/", match), match["dir"], dataURLs, files); err != nil {
+		return nil, err
+	}
+
+	browseURL := expand("{base}/projects/{project}/repos/{repo}/browse", match)
+	if match["dir"] != "" {
+		browseURL = expand("{base}/projects/{project}/repos/{repo}/browse{dir}?at={commit}", match)
+	}
+
+	return &Directory{
+		BrowseURL:      browseURL,
+		Etag:           commit,
+		Version:        releaseTag(releases, commit),
+		Files:          files,
+		LineFmt:        "%s#%d",
+		ProjectName:    match["repo"],
+		ProjectRoot:    expand("{prefix}/{project}/{repo}", match),
+		ProjectURL:     expand("{base}/projects/{project}/repos/{repo}", match),
+		Subdirectories: subdirs,
+		VCS:            "git",
+		Private:        !repo.Public,
+	}, nil
+}
+
+func (s *bitbucketServerService) getProject(client *http.Client, match map[string]string) (*Project, error) {
+	s.setMatch(match)
+	repo, err := s.getRepo(s.client(client), match)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{
+		Description: repo.Description,
+	}, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"net/http"
+	"testing"
+)
+
+var bitbucketServerTestWeb = map[string]string{
+	"https://example.com/bitbucket/rest/api/1.0/projects/PRJ/repos/pkg":                  `{"slug": "pkg", "public": true, "project": {"key": "PRJ"}}`,
+	"https://example.com/bitbucket/rest/api/1.0/projects/PRJ/repos/pkg/branches/default": `{"displayId": "main", "latestCommit": "abc"}`,
+	"https://example.com/bitbucket/rest/api/1.0/projects/PRJ/repos/pkg/branches":         `{"isLastPage": true, "values": [{"displayId": "main", "latestCommit": "abc"}]}`,
+	"https://example.com/bitbucket/rest/api/1.0/projects/PRJ/repos/pkg/tags":             `{"isLastPage": true, "values": [{"displayId": "v1.0.0", "latestCommit": "abc"}]}`,
+	"https://example.com/bitbucket/rest/api/1.0/projects/PRJ/repos/pkg/browse/sub":       `{"children": {"isLastPage": true, "values": [{"path": {"name": "sub.go", "toString": "sub.go"}, "type": "FILE"}, {"path": {"name": ".git", "toString": ".git"}, "type": "DIRECTORY"}, {"path": {"name": "x", "toString": "x"}, "type": "DIRECTORY"}]}}`,
+	"https://example.com/bitbucket/projects/PRJ/repos/pkg/raw/sub/sub.go":                "package sub",
+	"https://example.com/bitbucket/rest/api/1.0/projects/prj/repos/pkg":                  `{"slug": "pkg", "public": true, "project": {"key": "PRJ"}}`,
+}
+
+func TestGetBitbucketServerDir(t *testing.T) {
+	savedServices := services
+	defer func() { services = savedServices }()
+	if err := AddBitbucketServerHost("https://example.com/bitbucket/", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddBitbucketServerHost("example.com", ""); err == nil {
+		t.Error("AddBitbucketServerHost accepted base URL without scheme")
+	}
+
+	client := &http.Client{Transport: testTransport(bitbucketServerTestWeb)}
+
+	dir, err := getStatic(client, "example.com/bitbucket/PRJ/pkg/sub", "")
+	if err != nil {
+		t.Fatalf("getStatic returned error %v", err)
+	}
+	if dir.ProjectRoot != "example.com/bitbucket/PRJ/pkg" || dir.Etag != "abc" || dir.Version != "v1.0.0" || dir.Private {
+		t.Errorf("dir = %+v, want public project root example.com/bitbucket/PRJ/pkg, etag abc, version v1.0.0", dir)
+	}
+	if want := "https://example.com/bitbucket/projects/PRJ/repos/pkg/browse/sub?at=abc"; dir.BrowseURL != want {
+		t.Errorf("dir.BrowseURL = %q, want %q", dir.BrowseURL, want)
+	}
+	if len(dir.Subdirectories) != 1 || dir.Subdirectories[0] != "x" {
+		t.Errorf("dir.Subdirectories = %v, want [x]", dir.Subdirectories)
+	}
+	if len(dir.Files) != 1 || string(dir.Files[0].Data) != "package sub" {
+		t.Errorf("dir.Files = %+v, want sub.go", dir.Files)
+	}
+
+	_, err = getStatic(client, "example.com/bitbucket/prj/pkg", "")
+	if e, ok := err.(NotFoundError); !ok || e.Redirect != "example.com/bitbucket/PRJ/pkg" {
+		t.Errorf("getStatic with incorrect case returned %v, want redirect", err)
+	}
+}
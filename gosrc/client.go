@@ -12,6 +12,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync/atomic"
 )
 
 type httpClient struct {
@@ -32,6 +33,12 @@ func (c *httpClient) err(resp *http.Response) error {
 
 // get issues a GET to the specified URL.
 func (c *httpClient) get(url string) (*http.Response, error) {
+	return c.getHeader(url, nil)
+}
+
+// getHeader issues a GET to the specified URL with the headers of c and
+// header.
+func (c *httpClient) getHeader(url string, header http.Header) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -39,11 +46,21 @@ func (c *httpClient) get(url string) (*http.Response, error) {
 	for k, vs := range c.header {
 		req.Header[k] = vs
 	}
-	resp, err := c.client.Do(req)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if cred := hostCredentials(req.URL.Host); cred != nil && cred.Token != "" && req.Header.Get("Private-Token") == "" {
+		req.Header.Set("Authorization", "Bearer "+cred.Token)
+	}
+	if err := checkOutboundURL(req.URL); err != nil {
+		return nil, err
+	}
+	req = req.WithContext(WithOutboundPolicy(req.Context()))
+	resp, err := fixtureClient(outboundClient(c.client)).Do(req)
 	if err != nil {
 		return nil, &RemoteError{req.URL.Host, err}
 	}
-	return resp, err
+	return resp, nil
 }
 
 // getNoFollow issues a GET to the specified URL without following redirects.
@@ -55,10 +72,15 @@ func (c *httpClient) getNoFollow(url string) (*http.Response, error) {
 	for k, vs := range c.header {
 		req.Header[k] = vs
 	}
+	if err := checkOutboundURL(req.URL); err != nil {
+		return nil, err
+	}
+	req = req.WithContext(WithOutboundPolicy(req.Context()))
 	t := c.client.Transport
 	if t == nil {
-		t = http.DefaultTransport
+		t = outboundTransport
 	}
+	t, _ = fixtureRoundTripper(t)
 	resp, err := t.RoundTrip(req)
 	if err != nil {
 		return nil, &RemoteError{req.URL.Host, err}
@@ -93,11 +115,18 @@ func (c *httpClient) getReader(url string) (io.ReadCloser, error) {
 }
 
 func (c *httpClient) getJSON(url string, v interface{}) (*http.Response, error) {
-	resp, err := c.get(url)
+	return c.getJSONHeader(url, nil, v)
+}
+
+func (c *httpClient) getJSONHeader(url string, header http.Header, v interface{}) (*http.Response, error) {
+	resp, err := c.getHeader(url, header)
 	if err != nil {
 		return resp, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && header != nil {
+		return resp, nil
+	}
 	if resp.StatusCode != 200 {
 		return resp, c.err(resp)
 	}
@@ -110,6 +139,7 @@ func (c *httpClient) getJSON(url string, v interface{}) (*http.Response, error)
 
 func (c *httpClient) getFiles(urls []string, files []*File) error {
 	ch := make(chan error, len(files))
+	var total int64
 	for i := range files {
 		go func(i int) {
 			resp, err := c.get(urls[i])
@@ -128,11 +158,17 @@ func (c *httpClient) getFiles(urls []string, files []*File) error {
 				ch <- err
 				return
 			}
-			files[i].Data, err = ioutil.ReadAll(resp.Body)
+			files[i].Data, err = readFileData(resp.Body)
 			if err != nil {
 				ch <- &RemoteError{resp.Request.URL.Host, err}
 				return
 			}
+			// Stop before the files of a pathological directory exhaust
+			// the memory. Files over the size limit are removed later.
+			if n := int64(len(files[i].Data)); maxFetchSize > 0 && (maxFileSize <= 0 || n <= maxFileSize) && atomic.AddInt64(&total, n) > maxFetchSize {
+				ch <- errFetchTooLarge
+				return
+			}
 			ch <- nil
 		}(i)
 	}
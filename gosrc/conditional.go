@@ -0,0 +1,120 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// responseCache is an LRU cache of the validators, the ETag and
+// Last-Modified headers, of the responses that alone determine the etag of a
+// directory, such as the refs of a GitHub repository. The cache is bounded by
+// the total size of the entries. When the directory is fetched again with the
+// etag produced by a cached response, the request is sent with the
+// validators; a not modified response means the directory is not modified,
+// so unchanged resources are not transferred again and, for services like
+// GitHub, do not count against the API rate limit.
+type responseCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type cachedResponse struct {
+	url          string
+	etag         string // etag of the directory produced by the response
+	validator    string // ETag header
+	lastModified string
+}
+
+// cachedResponseOverhead approximates the memory used by an entry in
+// addition to the strings.
+const cachedResponseOverhead = 128
+
+func (cr *cachedResponse) bytes() int {
+	return len(cr.url) + len(cr.etag) + len(cr.validator) + len(cr.lastModified) + cachedResponseOverhead
+}
+
+var respCache *responseCache
+
+// SetResponseCacheSize sets the maximum size in bytes of the validators kept
+// for conditional requests. A size of zero disables conditional requests.
+func SetResponseCacheSize(n int) {
+	if n <= 0 {
+		respCache = nil
+		return
+	}
+	respCache = &responseCache{maxSize: n, ll: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *responseCache) get(url string) *cachedResponse {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	if !ok {
+		return nil
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*cachedResponse)
+}
+
+// add records the validators of resp, the response to the request for url
+// that produced the directory etag.
+func (c *responseCache) add(url string, resp *http.Response, etag string) {
+	if c == nil || etag == "" {
+		return
+	}
+	cr := &cachedResponse{url: url, etag: etag, validator: resp.Header.Get("Etag"), lastModified: resp.Header.Get("Last-Modified")}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[url]; ok {
+		c.size -= e.Value.(*cachedResponse).bytes()
+		c.ll.Remove(e)
+		delete(c.entries, url)
+	}
+	if cr.validator == "" && cr.lastModified == "" {
+		return
+	}
+	c.entries[url] = c.ll.PushFront(cr)
+	c.size += cr.bytes()
+	for c.size > c.maxSize {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		c.size -= e.Value.(*cachedResponse).bytes()
+		delete(c.entries, e.Value.(*cachedResponse).url)
+	}
+}
+
+// getJSONIfModified gets the JSON resource at url like getJSON. If the last
+// response cached for url produced the directory etag savedEtag, the request
+// is sent with the validators of the response and ErrNotModified is returned
+// if the resource is not modified. The caller adds the response to respCache
+// with the etag produced by the response.
+func (c *httpClient) getJSONIfModified(url, savedEtag string, v interface{}) (*http.Response, error) {
+	var header http.Header
+	if cr := respCache.get(url); cr != nil && savedEtag != "" && cr.etag == savedEtag {
+		header = make(http.Header)
+		if cr.validator != "" {
+			header.Set("If-None-Match", cr.validator)
+		}
+		if cr.lastModified != "" {
+			header.Set("If-Modified-Since", cr.lastModified)
+		}
+	}
+	resp, err := c.getJSONHeader(url, header, v)
+	if resp != nil && resp.StatusCode == http.StatusNotModified && header != nil {
+		return nil, ErrNotModified
+	}
+	return resp, err
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type conditionalTransport struct {
+	requests    int
+	notModified int
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	if req.Header.Get("If-None-Match") == `"v1"` {
+		t.notModified++
+		return &http.Response{
+			StatusCode: http.StatusNotModified,
+			Header:     http.Header{"Etag": {`"v1"`}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"v1"`}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"sha":"abc"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestConditionalRequests(t *testing.T) {
+	SetResponseCacheSize(1 << 20)
+	defer SetResponseCacheSize(0)
+
+	transport := &conditionalTransport{}
+	c := &httpClient{client: &http.Client{Transport: transport}}
+	const u = "https://api.example.com/refs"
+	var v struct{ Sha string }
+	resp, err := c.getJSONIfModified(u, "", &v)
+	if err != nil || v.Sha != "abc" {
+		t.Fatalf("getJSONIfModified() = %q, %v, want sha abc", v.Sha, err)
+	}
+	respCache.add(u, resp, v.Sha)
+
+	// The validators are sent only with the etag produced by the response.
+	if _, err := c.getJSONIfModified(u, "other", &v); err != nil {
+		t.Errorf("getJSONIfModified(other etag) returned %v", err)
+	}
+	if _, err := c.getJSONIfModified(u, "abc", &v); err != ErrNotModified {
+		t.Errorf("getJSONIfModified(saved etag) returned %v, want ErrNotModified", err)
+	}
+	if transport.requests != 3 || transport.notModified != 1 {
+		t.Errorf("requests = %d, not modified = %d; want 3, 1", transport.requests, transport.notModified)
+	}
+}
+
+func TestResponseCacheSize(t *testing.T) {
+	SetResponseCacheSize(3 * (cachedResponseOverhead + 40))
+	defer SetResponseCacheSize(0)
+
+	resp := &http.Response{Header: http.Header{"Etag": {`"v1"`}}}
+	for _, u := range []string{"https://a.example/x", "https://b.example/x", "https://c.example/x", "https://d.example/x"} {
+		respCache.add(u, resp, "etag")
+	}
+	if respCache.get("https://a.example/x") != nil || respCache.get("https://d.example/x") == nil {
+		t.Error("least recently used response not evicted")
+	}
+	if respCache.size > respCache.maxSize {
+		t.Errorf("size = %d, want at most %d", respCache.size, respCache.maxSize)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Credentials are used to fetch private repositories from a host.
+type Credentials struct {
+	// Token sent as a bearer token with API requests to the host.
+	Token string
+
+	// Path of the SSH private key used by VCS commands to access the host.
+	SSHKey string
+}
+
+// credentials maps hosts to credentials. The map is replaced, not modified,
+// by SetCredentials.
+var credentials struct {
+	mu sync.Mutex
+	m  map[string]*Credentials
+}
+
+// apiHosts maps the hosts of service APIs to the host in import paths.
+var apiHosts = map[string]string{
+	"api.github.com":    "github.com",
+	"raw.github.com":    "github.com",
+	"api.bitbucket.org": "bitbucket.org",
+}
+
+// SetCredentials sets the credentials used to fetch private repositories.
+// The map is keyed by the host in import paths, for example "github.com".
+func SetCredentials(c map[string]*Credentials) {
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+	credentials.m = c
+}
+
+// ReadCredentials reads a credentials file. Each line of the file has the
+// form
+//
+//	<host> token <token>
+//	<host> ssh-key <path>
+//
+// Blank lines and lines starting with # are ignored.
+func ReadCredentials(r io.Reader) (map[string]*Credentials, error) {
+	m := make(map[string]*Credentials)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("credentials:%d: want three fields", n)
+		}
+		c := m[fields[0]]
+		if c == nil {
+			c = &Credentials{}
+			m[fields[0]] = c
+		}
+		switch fields[1] {
+		case "token":
+			c.Token = fields[2]
+		case "ssh-key":
+			c.SSHKey = fields[2]
+		default:
+			return nil, fmt.Errorf("credentials:%d: unknown kind %q", n, fields[1])
+		}
+	}
+	return m, s.Err()
+}
+
+// hostCredentials returns the credentials for a request or import path host.
+func hostCredentials(host string) *Credentials {
+	if h, ok := apiHosts[host]; ok {
+		host = h
+	}
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+	return credentials.m[host]
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testCredentials = `
+# Tokens for the hosted services.
+github.com token abc
+gitlab.com token def
+
+git.example.com ssh-key /etc/gddo/id_rsa
+`
+
+type authTransport map[string]string
+
+func (t authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t[req.URL.Host] = req.Header.Get("Authorization")
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestCredentials(t *testing.T) {
+	c, err := ReadCredentials(strings.NewReader(testCredentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c["github.com"].Token != "abc" || c["git.example.com"].SSHKey != "/etc/gddo/id_rsa" {
+		t.Errorf("ReadCredentials() = %v", c)
+	}
+	if _, err := ReadCredentials(strings.NewReader("github.com password abc")); err == nil {
+		t.Error("ReadCredentials() accepted unknown kind")
+	}
+
+	defer SetCredentials(nil)
+	SetCredentials(c)
+
+	auth := make(authTransport)
+	hc := &httpClient{client: &http.Client{Transport: auth}}
+	for _, u := range []string{"https://api.github.com/repos/a/b", "https://gitlab.com/api/v4/projects/a%2Fb", "https://example.org/"} {
+		if _, err := hc.get(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if auth["api.github.com"] != "Bearer abc" || auth["gitlab.com"] != "Bearer def" || auth["example.org"] != "" {
+		t.Errorf("Authorization headers = %v", auth)
+	}
+
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The responses of the hosts can be recorded to a directory of fixtures and
+// replayed later, for deterministic tests of the crawler and development
+// without network access or API tokens. Each response is stored in the HTTP
+// wire format in the file <dir>/<host>/<hash of method and URL>. In replay
+// mode, requests without a fixture fail. Repositories fetched with VCS
+// commands are not recorded.
+
+// The fixture modes.
+const (
+	FixturesOff    = ""
+	FixturesRecord = "record"
+	FixturesReplay = "replay"
+)
+
+var fixtures struct {
+	mu   sync.RWMutex
+	mode string
+	dir  string
+}
+
+// SetFixtures sets the mode and the directory of the recorded responses. The
+// mode is FixturesOff, FixturesRecord or FixturesReplay.
+func SetFixtures(mode, dir string) error {
+	switch mode {
+	case FixturesOff:
+	case FixturesRecord, FixturesReplay:
+		if dir == "" {
+			return fmt.Errorf("fixtures: %s mode needs a directory", mode)
+		}
+	default:
+		return fmt.Errorf("fixtures: unknown mode %q", mode)
+	}
+	fixtures.mu.Lock()
+	fixtures.mode = mode
+	fixtures.dir = dir
+	fixtures.mu.Unlock()
+	return nil
+}
+
+// fixturePath returns the path of the fixture for the request.
+func fixturePath(dir string, req *http.Request) string {
+	h := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	return filepath.Join(dir, filepath.Clean("/" + req.URL.Host)[1:], hex.EncodeToString(h[:16]))
+}
+
+// fixtureTransport records the responses of rt or replays the recorded
+// responses.
+type fixtureTransport struct {
+	mode string
+	dir  string
+	rt   http.RoundTripper
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Key the fixture by the URL before the transport changes the request.
+	name := fixturePath(t.dir, req)
+	if t.mode == FixturesReplay {
+		p, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("no fixture for %s %s", req.Method, req.URL)
+		}
+		return http.ReadResponse(bufio.NewReader(bytes.NewReader(p)), req)
+	}
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	p, err := httputil.DumpResponse(resp, true)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFixture(name, p); err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(p)), req)
+}
+
+// writeFixture writes the fixture file atomically.
+func writeFixture(name string, p []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), ".fixture")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(p)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// fixtureRoundTripper returns rt wrapped to record or replay the responses
+// and true if fixtures are enabled.
+func fixtureRoundTripper(rt http.RoundTripper) (http.RoundTripper, bool) {
+	fixtures.mu.RLock()
+	mode, dir := fixtures.mode, fixtures.dir
+	fixtures.mu.RUnlock()
+	if mode == FixturesOff {
+		return rt, false
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &fixtureTransport{mode: mode, dir: dir, rt: rt}, true
+}
+
+// fixtureClient returns the client with the transport wrapped to record or
+// replay the responses if fixtures are enabled.
+func fixtureClient(c *http.Client) *http.Client {
+	rt, ok := fixtureRoundTripper(c.Transport)
+	if !ok {
+		return c
+	}
+	fc := *c
+	fc.Transport = rt
+	return &fc
+}
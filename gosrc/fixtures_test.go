@@ -0,0 +1,54 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+type failTransport struct{}
+
+func (failTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("network down")
+}
+
+func TestFixtures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer SetFixtures(FixturesOff, "")
+
+	if err := SetFixtures("bogus", dir); err == nil {
+		t.Error("SetFixtures accepted unknown mode")
+	}
+
+	const u = "https://example.com/a"
+	if err := SetFixtures(FixturesRecord, dir); err != nil {
+		t.Fatal(err)
+	}
+	c := &httpClient{client: &http.Client{Transport: testTransport{u: "recorded"}}}
+	if p, err := c.getBytes(u); err != nil || string(p) != "recorded" {
+		t.Fatalf("record getBytes = %q, %v, want recorded", p, err)
+	}
+
+	if err := SetFixtures(FixturesReplay, dir); err != nil {
+		t.Fatal(err)
+	}
+	c = &httpClient{client: &http.Client{Transport: failTransport{}}}
+	if p, err := c.getBytes(u); err != nil || string(p) != "recorded" {
+		t.Errorf("replay getBytes = %q, %v, want recorded", p, err)
+	}
+	if _, err := c.getBytes("https://example.com/b"); err == nil {
+		t.Error("replay of request without fixture returned nil error")
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// giteaPageLimit is the number of items requested per page from paginated
+// Gitea API resources.
+const giteaPageLimit = 50
+
+// AddGiteaHost adds a service for the Gitea or Forgejo instance with the
+// given base URL, for example "https://git.example.com". Packages on the
+// instance have import paths with the host and path of the base URL as prefix.
+// If token is not "", then the token is sent with every API request to the
+// instance.
+func AddGiteaHost(baseURL, token string) error {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("gitea: bad base URL %q", baseURL)
+	}
+	trustHost(u.Host)
+	var header http.Header
+	if token != "" {
+		header = http.Header{"Authorization": {"token " + token}}
+	}
+	s := &giteaService{baseURL: u.String(), prefix: u.Host + u.Path, header: header}
+	addService(&service{
+		pattern:    regexp.MustCompile(`^` + regexp.QuoteMeta(s.prefix) + `/(?P<owner>[a-z0-9A-Z_.\-]+)/(?P<repo>[a-z0-9A-Z_.\-]+)(?P<dir>/.*)?$`),
+		prefix:     s.prefix + "/",
+		get:        s.getDir,
+		getProject: s.getProject,
+	})
+	return nil
+}
+
+type giteaService struct {
+	baseURL string
+	prefix  string
+	header  http.Header
+}
+
+// client returns an HTTP client for the service. The Gitea API reports errors
+// the same way as the GitHub API.
+func (s *giteaService) client(client *http.Client) *httpClient {
+	return &httpClient{client: client, header: s.header, errFn: gitHubError}
+}
+
+type giteaRepo struct {
+	Description   string    `json:"description"`
+	DefaultBranch string    `json:"default_branch"`
+	FullName      string    `json:"full_name"`
+	Fork          bool      `json:"fork"`
+	Private       bool      `json:"private"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (s *giteaService) getRepo(c *httpClient, match map[string]string) (*giteaRepo, error) {
+	var repo giteaRepo
+	if _, err := c.getJSON(expand("{base}/api/v1/repos/{owner}/{repo}", match), &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func (s *giteaService) getDir(client *http.Client, match map[string]string, savedEtag string) (*Directory, error) {
+	c := s.client(client)
+	match["base"] = s.baseURL
+	match["prefix"] = s.prefix
+
+	repo, err := s.getRepo(c, match)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gitea owner and repo names are case-insensitive. Redirect if requested
+	// names do not match the canonical names in the API response.
+	if repo.FullName != "" && repo.FullName != expand("{owner}/{repo}", match) {
+		if i := strings.Index(repo.FullName, "/"); i > 0 {
+			match["owner"] = repo.FullName[:i]
+			match["repo"] = repo.FullName[i+1:]
+			return nil, NotFoundError{
+				Message:  "Gitea import path has incorrect case.",
+				Redirect: expand("{prefix}/{owner}/{repo}{dir}", match),
+			}
+		}
+	}
+
+	tags := make(map[string]string)
+	releases := make(map[string]string)
+	for page := 1; ; page++ {
+		var branches []*struct {
+			Name   string
+			Commit struct {
+				ID string
+			}
+		}
+		if _, err := c.getJSON(expand("{base}/api/v1/repos/{owner}/{repo}/branches?limit={0}&page={1}", match, fmt.Sprint(giteaPageLimit), fmt.Sprint(page)), &branches); err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			tags[b.Name] = b.Commit.ID
+		}
+		if len(branches) < giteaPageLimit {
+			break
+		}
+	}
+	for page := 1; ; page++ {
+		var refs []*struct {
+			Name   string
+			Commit struct {
+				SHA string
+			}
+		}
+		if _, err := c.getJSON(expand("{base}/api/v1/repos/{owner}/{repo}/tags?limit={0}&page={1}", match, fmt.Sprint(giteaPageLimit), fmt.Sprint(page)), &refs); err != nil {
+			return nil, err
+		}
+		for _, t := range refs {
+			tags[t.Name] = t.Commit.SHA
+			releases[t.Name] = t.Commit.SHA
+		}
+		if len(refs) < giteaPageLimit {
+			break
+		}
+	}
+
+	defaultBranch := repo.DefaultBranch
+	if defaultBranch == "" {
+		defaultBranch = "master"
+	}
+
+	var commit string
+	match["tag"], commit, err = bestTag(tags, defaultBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit == savedEtag {
+		return nil, ErrNotModified
+	}
+
+	var contents []*struct {
+		Type        string
+		Name        string
+		Path        string
+		HTMLURL     string `json:"html_url"`
+		DownloadURL string `json:"download_url"`
+	}
+
+	if _, err := c.getJSON(expand("{base}/api/v1/repos/{owner}/{repo}/contents{dir}?ref={tag}", match), &contents); err != nil {
+		return nil, err
+	}
+
+	if len(contents) == 0 {
+		return nil, NotFoundError{Message: "No files in directory."}
+	}
+
+	var files []*File
+	var dataURLs []string
+	var subdirs []string
+
+	for _, item := range contents {
+		switch {
+		case item.Type == "dir":
+			if isValidPathElement(item.Name) {
+				subdirs = append(subdirs, item.Name)
+			}
+		case item.Type == "file" && isDocFile(item.Name):
+			files = append(files, &File{Name: item.Name, BrowseURL: item.HTMLURL})
+			dataURLs = append(dataURLs, expand("{base}/api/v1/repos/{owner}/{repo}/raw/{0}?ref={tag}", match, item.Path))
+		}
+	}
+
+	if err := c.getFilesFromArchive(expand("{base}/api/v1/repos/{owner}/{repo}/archive/{0}.tar.gz", match, commit), match["dir"], dataURLs, files); err != nil {
+		return nil, err
+	}
+
+	browseURL := expand("{base}/{owner}/{repo}", match)
+	if match["dir"] != "" {
+		browseURL = expand("{base}/{owner}/{repo}/src/commit/{0}{dir}", match, commit)
+	}
+
+	return &Directory{
+		BrowseURL:      browseURL,
+		Etag:           commit,
+		Version:        releaseTag(releases, commit),
+		Files:          files,
+		LineFmt:        "%s#L%d",
+		ProjectName:    match["repo"],
+		ProjectRoot:    expand("{prefix}/{owner}/{repo}", match),
+		ProjectURL:     expand("{base}/{owner}/{repo}", match),
+		Subdirectories: subdirs,
+		VCS:            "git",
+		DeadEndFork:    repo.Fork && !repo.UpdatedAt.After(repo.CreatedAt),
+		Private:        repo.Private,
+	}, nil
+}
+
+func (s *giteaService) getProject(client *http.Client, match map[string]string) (*Project, error) {
+	match["base"] = s.baseURL
+	repo, err := s.getRepo(s.client(client), match)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{
+		Description: repo.Description,
+	}, nil
+}
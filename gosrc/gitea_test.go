@@ -0,0 +1,56 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"net/http"
+	"testing"
+)
+
+var giteaTestWeb = map[string]string{
+	"https://example.com/gitea/api/v1/repos/alice/pkg":                `{"default_branch": "main", "full_name": "alice/pkg"}`,
+	"https://example.com/gitea/api/v1/repos/alice/pkg/branches":       `[{"name": "main", "commit": {"id": "abc"}}]`,
+	"https://example.com/gitea/api/v1/repos/alice/pkg/tags":           `[{"name": "v1.0.0", "commit": {"sha": "abc"}}]`,
+	"https://example.com/gitea/api/v1/repos/alice/pkg/contents/sub":   `[{"name": "sub.go", "path": "sub/sub.go", "type": "file", "html_url": "https://example.com/gitea/alice/pkg/src/branch/main/sub/sub.go"}, {"name": ".git", "path": "sub/.git", "type": "dir"}, {"name": "x", "path": "sub/x", "type": "dir"}]`,
+	"https://example.com/gitea/api/v1/repos/alice/pkg/raw/sub/sub.go": "package sub",
+	"https://example.com/gitea/api/v1/repos/Alice/pkg":                `{"default_branch": "main", "full_name": "alice/pkg"}`,
+}
+
+func TestGetGiteaDir(t *testing.T) {
+	savedServices := services
+	defer func() { services = savedServices }()
+	if err := AddGiteaHost("https://example.com/gitea/", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddGiteaHost("example.com", ""); err == nil {
+		t.Error("AddGiteaHost accepted base URL without scheme")
+	}
+
+	client := &http.Client{Transport: testTransport(giteaTestWeb)}
+
+	dir, err := getStatic(client, "example.com/gitea/alice/pkg/sub", "")
+	if err != nil {
+		t.Fatalf("getStatic returned error %v", err)
+	}
+	if dir.ProjectRoot != "example.com/gitea/alice/pkg" || dir.Etag != "abc" || dir.Version != "v1.0.0" {
+		t.Errorf("dir = %+v, want project root example.com/gitea/alice/pkg, etag abc, version v1.0.0", dir)
+	}
+	if want := "https://example.com/gitea/alice/pkg/src/commit/abc/sub"; dir.BrowseURL != want {
+		t.Errorf("dir.BrowseURL = %q, want %q", dir.BrowseURL, want)
+	}
+	if len(dir.Subdirectories) != 1 || dir.Subdirectories[0] != "x" {
+		t.Errorf("dir.Subdirectories = %v, want [x]", dir.Subdirectories)
+	}
+	if len(dir.Files) != 1 || string(dir.Files[0].Data) != "package sub" {
+		t.Errorf("dir.Files = %+v, want sub.go", dir.Files)
+	}
+
+	_, err = getStatic(client, "example.com/gitea/Alice/pkg", "")
+	if e, ok := err.(NotFoundError); !ok || e.Redirect != "example.com/gitea/alice/pkg" {
+		t.Errorf("getStatic with incorrect case returned %v, want redirect", err)
+	}
+}
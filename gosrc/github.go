@@ -63,7 +63,8 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 	}
 	var refs []*refJSON
 
-	resp, err := c.getJSON(expand("https://api.github.com/repos/{owner}/{repo}/git/refs", match), &refs)
+	refsURL := expand("https://api.github.com/repos/{owner}/{repo}/git/refs", match)
+	resp, err := c.getJSONIfModified(refsURL, savedEtag, &refs)
 	if err != nil {
 		return nil, err
 	}
@@ -82,12 +83,14 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 	}
 
 	tags := make(map[string]string)
+	releases := make(map[string]string)
 	for _, ref := range refs {
 		switch {
 		case strings.HasPrefix(ref.Ref, "refs/heads/"):
 			tags[ref.Ref[len("refs/heads/"):]] = ref.Object.Sha
 		case strings.HasPrefix(ref.Ref, "refs/tags/"):
 			tags[ref.Ref[len("refs/tags/"):]] = ref.Object.Sha
+			releases[ref.Ref[len("refs/tags/"):]] = ref.Object.Sha
 		}
 	}
 
@@ -97,6 +100,10 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 		return nil, err
 	}
 
+	if resp.Header.Get("Link") == "" {
+		// The refs in the response alone determine the commit.
+		respCache.add(refsURL, resp, commit)
+	}
 	if commit == savedEtag {
 		return nil, ErrNotModified
 	}
@@ -117,14 +124,21 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 	}
 
 	// GitHub owner and repo names are case-insensitive. Redirect if requested
-	// names do not match the canonical names in API response.
+	// names do not match the canonical names in API response. The API follows
+	// the redirects of renamed and transferred repositories; the names of
+	// these differ in more than the case.
 	if m := ownerRepoPat.FindStringSubmatch(contents[0].GitURL); m != nil && (m[1] != match["owner"] || m[2] != match["repo"]) {
+		e := NotFoundError{Message: "Github import path has incorrect case."}
+		if !strings.EqualFold(m[1], match["owner"]) || !strings.EqualFold(m[2], match["repo"]) {
+			e.Message = "GitHub repository moved."
+			e.Moved = true
+			e.MovedFrom = expand("github.com/{owner}/{repo}", match)
+			e.MovedTo = "github.com/" + m[1] + "/" + m[2]
+		}
 		match["owner"] = m[1]
 		match["repo"] = m[2]
-		return nil, NotFoundError{
-			Message:  "Github import path has incorrect case.",
-			Redirect: expand("github.com/{owner}/{repo}{dir}", match),
-		}
+		e.Redirect = expand("github.com/{owner}/{repo}{dir}", match)
+		return nil, e
 	}
 
 	var files []*File
@@ -144,7 +158,14 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 	}
 
 	c.header = gitHubRawHeader
-	if err := c.getFiles(dataURLs, files); err != nil {
+	if err := c.getFilesFromArchive(expand("https://api.github.com/repos/{owner}/{repo}/tarball/{tag}", match), match["dir"], dataURLs, files); err != nil {
+		return nil, err
+	}
+
+	parentGoMods, err := getParentGoMods(expand("github.com/{owner}/{repo}", match), match["dir"], func(dir string) ([]byte, error) {
+		return c.getBytes(expand("https://api.github.com/repos/{owner}/{repo}/contents{0}/go.mod?ref={tag}", match, dir))
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -154,7 +175,10 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 	}
 
 	var repo = struct {
+		Private   bool      `json:"private"`
 		Fork      bool      `json:"fork"`
+		Archived  bool      `json:"archived"`
+		Stars     int       `json:"stargazers_count"`
 		CreatedAt time.Time `json:"created_at"`
 		PushedAt  time.Time `json:"pushed_at"`
 	}{}
@@ -168,6 +192,7 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 	return &Directory{
 		BrowseURL:      browseURL,
 		Etag:           commit,
+		Version:        releaseTag(releases, commit),
 		Files:          files,
 		LineFmt:        "%s#L%d",
 		ProjectName:    match["repo"],
@@ -176,6 +201,12 @@ func getGitHubDir(client *http.Client, match map[string]string, savedEtag string
 		Subdirectories: subdirs,
 		VCS:            "git",
 		DeadEndFork:    isDeadEndFork,
+		Fork:           repo.Fork,
+		Archived:       repo.Archived,
+		Stars:          repo.Stars,
+		Pushed:         repo.PushedAt,
+		Private:        repo.Private,
+		parentGoMods:   parentGoMods,
 	}, nil
 }
 
@@ -290,7 +321,9 @@ func getGistDir(client *http.Client, match map[string]string, savedEtag string)
 		}
 	}
 
-	if _, err := c.getJSON(expand("https://api.github.com/gists/{gist}", match), &gist); err != nil {
+	gistURL := expand("https://api.github.com/gists/{gist}", match)
+	resp, err := c.getJSONIfModified(gistURL, savedEtag, &gist)
+	if err != nil {
 		return nil, err
 	}
 
@@ -298,6 +331,7 @@ func getGistDir(client *http.Client, match map[string]string, savedEtag string)
 		return nil, NotFoundError{Message: "History not found."}
 	}
 	commit := gist.History[0].Version
+	respCache.add(gistURL, resp, commit)
 
 	if commit == savedEtag {
 		return nil, ErrNotModified
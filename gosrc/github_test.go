@@ -0,0 +1,34 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"net/http"
+	"testing"
+)
+
+var gitHubTestWeb = map[string]string{
+	"https://api.github.com/repos/alice/old/git/refs":     `[{"ref": "refs/heads/master", "object": {"type": "commit", "sha": "abc"}}]`,
+	"https://api.github.com/repos/alice/old/contents/sub": `[{"type": "file", "name": "a.go", "git_url": "https://api.github.com/repos/bob/new/git/blobs/123"}]`,
+	"https://api.github.com/repos/Bob/New/git/refs":       `[{"ref": "refs/heads/master", "object": {"type": "commit", "sha": "abc"}}]`,
+	"https://api.github.com/repos/Bob/New/contents":       `[{"type": "file", "name": "a.go", "git_url": "https://api.github.com/repos/bob/new/git/blobs/123"}]`,
+}
+
+func TestGitHubRedirect(t *testing.T) {
+	client := &http.Client{Transport: testTransport(gitHubTestWeb)}
+
+	_, err := getStatic(client, "github.com/alice/old/sub", "")
+	if e, ok := err.(NotFoundError); !ok || e.Redirect != "github.com/bob/new/sub" || !e.Moved ||
+		e.MovedFrom != "github.com/alice/old" || e.MovedTo != "github.com/bob/new" {
+		t.Errorf("getStatic of a moved repository returned %#v, want moved redirect", err)
+	}
+
+	_, err = getStatic(client, "github.com/Bob/New", "")
+	if e, ok := err.(NotFoundError); !ok || e.Redirect != "github.com/bob/new" || e.Moved {
+		t.Errorf("getStatic with incorrect case returned %#v, want redirect", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	addService(newGitLabService("gitlab.com", ""))
+}
+
+// maxGitLabPages limits the number of pages fetched from a paginated GitLab
+// API resource.
+const maxGitLabPages = 10
+
+// AddGitLabHost adds a service for the GitLab instance at host. If token is
+// not "", then the token is sent with every API request to the host. Calling
+// AddGitLabHost with "gitlab.com" replaces the default service for
+// gitlab.com.
+func AddGitLabHost(host, token string) {
+	trustHost(host)
+	addService(newGitLabService(host, token))
+}
+
+func newGitLabService(host, token string) *service {
+	var header http.Header
+	if token != "" {
+		header = http.Header{"Private-Token": {token}}
+	}
+	s := &gitLabService{host: host, header: header}
+	return &service{
+		pattern:    regexp.MustCompile(`^` + regexp.QuoteMeta(host) + `/(?P<owner>[a-z0-9A-Z_.\-]+)/(?P<repo>[a-z0-9A-Z_.\-]+)(?P<dir>/.*)?$`),
+		prefix:     host + "/",
+		get:        s.getDir,
+		getProject: s.getProject,
+	}
+}
+
+type gitLabService struct {
+	host   string
+	header http.Header
+}
+
+func (s *gitLabService) client(client *http.Client) *httpClient {
+	return &httpClient{client: client, header: s.header, errFn: gitLabError}
+}
+
+func (s *gitLabService) setMatch(match map[string]string) {
+	match["host"] = s.host
+	match["id"] = url.QueryEscape(expand("{owner}/{repo}", match))
+}
+
+func gitLabError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retry := resp.Header.Get("Retry-After")
+		if t, err := strconv.ParseInt(resp.Header.Get("RateLimit-Reset"), 10, 64); err == nil {
+			retry = time.Unix(t, 0).UTC().Format(time.RFC3339)
+		}
+		return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: rate limit exceeded, retry after %s (%s)", resp.StatusCode, retry, resp.Request.URL.String())}
+	}
+	var e struct {
+		Message interface{} `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&e); err == nil && e.Message != nil {
+		return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: %v (%s)", resp.StatusCode, e.Message, resp.Request.URL.String())}
+	}
+	return &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: (%s)", resp.StatusCode, resp.Request.URL.String())}
+}
+
+// getGitLabPages fetches the pages of a paginated GitLab API resource. The
+// function next returns the value that the JSON for each page is decoded to.
+func getGitLabPages(c *httpClient, u string, next func() interface{}) error {
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	page := "1"
+	for i := 0; i < maxGitLabPages && page != ""; i++ {
+		resp, err := c.getJSON(u+sep+"per_page=100&page="+page, next())
+		if err != nil {
+			return err
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return nil
+}
+
+type gitLabProject struct {
+	Description       string    `json:"description"`
+	DefaultBranch     string    `json:"default_branch"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Visibility        string    `json:"visibility"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	ForkedFromProject *struct {
+		ID int `json:"id"`
+	} `json:"forked_from_project"`
+}
+
+func (s *gitLabService) getDir(client *http.Client, match map[string]string, savedEtag string) (*Directory, error) {
+	c := s.client(client)
+	s.setMatch(match)
+
+	var project gitLabProject
+	if _, err := c.getJSON(expand("https://{host}/api/v4/projects/{id}", match), &project); err != nil {
+		return nil, err
+	}
+
+	// GitLab paths are case-insensitive. Redirect if requested names do not
+	// match the canonical names in the API response.
+	if project.PathWithNamespace != "" && project.PathWithNamespace != expand("{owner}/{repo}", match) {
+		if i := strings.Index(project.PathWithNamespace, "/"); i > 0 && !strings.Contains(project.PathWithNamespace[i+1:], "/") {
+			match["owner"] = project.PathWithNamespace[:i]
+			match["repo"] = project.PathWithNamespace[i+1:]
+			return nil, NotFoundError{
+				Message:  "GitLab import path has incorrect case.",
+				Redirect: expand("{host}/{owner}/{repo}{dir}", match),
+			}
+		}
+	}
+
+	type refJSON struct {
+		Name   string
+		Commit struct {
+			ID string
+		}
+	}
+
+	tags := make(map[string]string)
+	releases := make(map[string]string)
+	for _, kind := range []string{"branches", "tags"} {
+		var pages [][]*refJSON
+		err := getGitLabPages(c, expand("https://{host}/api/v4/projects/{id}/repository/{0}", match, kind), func() interface{} {
+			pages = append(pages, nil)
+			return &pages[len(pages)-1]
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, refs := range pages {
+			for _, ref := range refs {
+				tags[ref.Name] = ref.Commit.ID
+				if kind == "tags" {
+					releases[ref.Name] = ref.Commit.ID
+				}
+			}
+		}
+	}
+
+	defaultBranch := project.DefaultBranch
+	if defaultBranch == "" {
+		defaultBranch = "master"
+	}
+
+	var commit string
+	var err error
+	match["tag"], commit, err = bestTag(tags, defaultBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit == savedEtag {
+		return nil, ErrNotModified
+	}
+
+	type treeJSON struct {
+		Name string
+		Type string
+		Path string
+	}
+	var pages [][]*treeJSON
+	err = getGitLabPages(c, expand("https://{host}/api/v4/projects/{id}/repository/tree?path={0}&ref={tag}", match, url.QueryEscape(strings.TrimPrefix(match["dir"], "/"))), func() interface{} {
+		pages = append(pages, nil)
+		return &pages[len(pages)-1]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*File
+	var dataURLs []string
+	var subdirs []string
+
+	for _, items := range pages {
+		for _, item := range items {
+			switch {
+			case item.Type == "tree":
+				if isValidPathElement(item.Name) {
+					subdirs = append(subdirs, item.Name)
+				}
+			case item.Type == "blob" && isDocFile(item.Name):
+				files = append(files, &File{Name: item.Name, BrowseURL: expand("https://{host}/{owner}/{repo}/blob/{tag}/{0}", match, item.Path)})
+				dataURLs = append(dataURLs, expand("https://{host}/api/v4/projects/{id}/repository/files/{0}/raw?ref={tag}", match, url.QueryEscape(item.Path)))
+			}
+		}
+	}
+
+	if len(files) == 0 && len(subdirs) == 0 {
+		return nil, NotFoundError{Message: "No files in directory."}
+	}
+
+	if err := c.getFilesFromArchive(expand("https://{host}/api/v4/projects/{id}/repository/archive.tar.gz?sha={tag}", match), match["dir"], dataURLs, files); err != nil {
+		return nil, err
+	}
+
+	browseURL := expand("https://{host}/{owner}/{repo}", match)
+	if match["dir"] != "" {
+		browseURL = expand("https://{host}/{owner}/{repo}/tree/{tag}{dir}", match)
+	}
+
+	isDeadEndFork := project.ForkedFromProject != nil && !project.LastActivityAt.After(project.CreatedAt)
+
+	return &Directory{
+		BrowseURL:      browseURL,
+		Etag:           commit,
+		Version:        releaseTag(releases, commit),
+		Files:          files,
+		LineFmt:        "%s#L%d",
+		ProjectName:    match["repo"],
+		ProjectRoot:    expand("{host}/{owner}/{repo}", match),
+		ProjectURL:     expand("https://{host}/{owner}/{repo}", match),
+		Subdirectories: subdirs,
+		VCS:            "git",
+		DeadEndFork:    isDeadEndFork,
+		Private:        project.Visibility != "" && project.Visibility != "public",
+	}, nil
+}
+
+func (s *gitLabService) getProject(client *http.Client, match map[string]string) (*Project, error) {
+	c := s.client(client)
+	s.setMatch(match)
+
+	var project gitLabProject
+	if _, err := c.getJSON(expand("https://{host}/api/v4/projects/{id}", match), &project); err != nil {
+		return nil, err
+	}
+
+	return &Project{
+		Description: project.Description,
+	}, nil
+}
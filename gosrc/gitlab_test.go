@@ -0,0 +1,73 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var gitLabTestWeb = map[string]string{
+	"https://git.example.com/api/v4/projects/alice%2Fpkg":                                `{"default_branch": "main", "path_with_namespace": "alice/pkg"}`,
+	"https://git.example.com/api/v4/projects/alice%2Fpkg/repository/branches":            `[{"name": "main", "commit": {"id": "abc"}}]`,
+	"https://git.example.com/api/v4/projects/alice%2Fpkg/repository/tags":                `[{"name": "v1.0.0", "commit": {"id": "abc"}}]`,
+	"https://git.example.com/api/v4/projects/alice%2Fpkg/repository/tree":                `[{"name": "sub", "type": "tree", "path": "sub"}, {"name": "pkg.go", "type": "blob", "path": "pkg.go"}, {"name": "README.md", "type": "blob", "path": "README.md"}, {"name": "Makefile", "type": "blob", "path": "Makefile"}]`,
+	"https://git.example.com/api/v4/projects/alice%2Fpkg/repository/files/pkg.go/raw":    "package pkg",
+	"https://git.example.com/api/v4/projects/alice%2Fpkg/repository/files/README.md/raw": "# pkg",
+	"https://git.example.com/api/v4/projects/Alice%2FPkg":                                `{"default_branch": "main", "path_with_namespace": "alice/pkg"}`,
+}
+
+func TestGetGitLabDir(t *testing.T) {
+	savedServices := services
+	defer func() { services = savedServices }()
+	AddGitLabHost("git.example.com", "secret")
+
+	client := &http.Client{Transport: testTransport(gitLabTestWeb)}
+
+	dir, err := getStatic(client, "git.example.com/alice/pkg", "")
+	if err != nil {
+		t.Fatalf("getStatic returned error %v", err)
+	}
+	if dir.ProjectRoot != "git.example.com/alice/pkg" || dir.Etag != "abc" || dir.Version != "v1.0.0" {
+		t.Errorf("dir = %+v, want project root git.example.com/alice/pkg, etag abc, version v1.0.0", dir)
+	}
+	if len(dir.Subdirectories) != 1 || dir.Subdirectories[0] != "sub" {
+		t.Errorf("dir.Subdirectories = %v, want [sub]", dir.Subdirectories)
+	}
+	if len(dir.Files) != 2 || string(dir.Files[0].Data) != "package pkg" || string(dir.Files[1].Data) != "# pkg" {
+		t.Errorf("dir.Files = %+v, want pkg.go and README.md", dir.Files)
+	}
+	if want := "https://git.example.com/alice/pkg/blob/main/pkg.go"; dir.Files[0].BrowseURL != want {
+		t.Errorf("BrowseURL = %q, want %q", dir.Files[0].BrowseURL, want)
+	}
+
+	if _, err := getStatic(client, "git.example.com/alice/pkg", "abc"); err != ErrNotModified {
+		t.Errorf("getStatic with saved etag returned %v, want ErrNotModified", err)
+	}
+
+	_, err = getStatic(client, "git.example.com/Alice/Pkg", "")
+	if e, ok := err.(NotFoundError); !ok || e.Redirect != "git.example.com/alice/pkg" {
+		t.Errorf("getStatic with incorrect case returned %v, want redirect", err)
+	}
+}
+
+func TestGitLabRateLimitError(t *testing.T) {
+	u, _ := url.Parse("https://gitlab.com/api/v4/projects/alice%2Fpkg")
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Ratelimit-Reset": {"1500000000"}},
+		Body:       ioutil.NopCloser(strings.NewReader("Retry later")),
+		Request:    &http.Request{URL: u},
+	}
+	err := gitLabError(resp)
+	if _, ok := err.(*RemoteError); !ok || !strings.Contains(err.Error(), "2017-07-14T02:40:00Z") {
+		t.Errorf("gitLabError() = %v, want rate limit error with reset time", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// goRoot is a tree of the Go distribution on the local file system.
+type goRoot struct {
+	version string
+	dir     string
+}
+
+// goRoots are the trees the standard library is read from, newest first.
+var goRoots []goRoot
+
+// SetGoRoots sets the trees of the Go distribution read for the standard
+// library instead of golang.org. Each element of roots is a directory or
+// version=directory. The version defaults to the first line of the VERSION
+// file of the tree. The first tree is the current version of the standard
+// library and the other trees are its previous versions. SetGoRoots with no
+// roots reads the standard library from golang.org.
+func SetGoRoots(roots []string) error {
+	var m []goRoot
+	seen := make(map[string]bool)
+	for _, r := range roots {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		var version string
+		if i := strings.Index(r, "="); i >= 0 {
+			version, r = r[:i], r[i+1:]
+		}
+		if fi, err := os.Stat(filepath.Join(r, "src")); err != nil || !fi.IsDir() {
+			return errors.New("Go tree not found: " + r)
+		}
+		if version == "" {
+			p, err := ioutil.ReadFile(filepath.Join(r, "VERSION"))
+			if err != nil {
+				return err
+			}
+			if i := bytes.IndexByte(p, '\n'); i >= 0 {
+				p = p[:i]
+			}
+			version = strings.TrimSpace(string(p))
+		}
+		if version == "" || strings.ContainsAny(version, "/@ ") {
+			return errors.New("bad Go version for tree " + r)
+		}
+		if seen[version] {
+			return errors.New("duplicate Go version " + version)
+		}
+		seen[version] = true
+		m = append(m, goRoot{version: version, dir: r})
+	}
+	goRoots = m
+	return nil
+}
+
+// GoRootVersions returns the versions of the standard library set by
+// SetGoRoots, newest first.
+func GoRootVersions() []string {
+	var versions []string
+	for _, r := range goRoots {
+		versions = append(versions, r.version)
+	}
+	return versions
+}
+
+// GetGoRoot returns the directory with the import path in the standard
+// library of the given version set by SetGoRoots.
+func GetGoRoot(importPath, version string) (*Directory, error) {
+	for _, r := range goRoots {
+		if r.version == version {
+			return getGoRootDir(r, importPath, "")
+		}
+	}
+	return nil, NotFoundError{Message: "Go version not found."}
+}
+
+func getGoRootDir(r goRoot, importPath, savedEtag string) (*Directory, error) {
+	dir, err := readLocalDir(filepath.Join(r.dir, "src", filepath.FromSlash(importPath)), importPath)
+	if os.IsNotExist(err) {
+		return nil, NotFoundError{Message: "Directory not found in " + r.version + "."}
+	} else if err != nil {
+		return nil, err
+	}
+	dir.Etag = r.version + "-" + dir.Etag
+	if dir.Etag == savedEtag {
+		return nil, ErrNotModified
+	}
+	dir.Version = r.version
+	dir.ResolvedPath = importPath
+	dir.ProjectName = "Go"
+	return dir, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGoRoots(t *testing.T) {
+	root, err := ioutil.TempDir("", "goroot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for name, data := range map[string]string{
+		"new/VERSION":          "go1.22.1\ntime 2024-03-05T22:59:59Z\n",
+		"new/src/fmt/print.go": "package fmt\n",
+		"new/src/fmt/doc.go":   "// Package fmt implements formatted I/O.\npackage fmt\n",
+		"old/src/fmt/print.go": "package fmt\n",
+	} {
+		fname := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer SetGoRoots(nil)
+
+	for _, roots := range [][]string{
+		{filepath.Join(root, "missing")},
+		{filepath.Join(root, "old")},
+		{"go1=" + filepath.Join(root, "new"), "go1=" + filepath.Join(root, "old")},
+	} {
+		if err := SetGoRoots(roots); err == nil {
+			t.Errorf("SetGoRoots(%q) returned nil error", roots)
+		}
+	}
+
+	if err := SetGoRoots([]string{filepath.Join(root, "new"), " go1.21=" + filepath.Join(root, "old"), ""}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"go1.22.1", "go1.21"}; !reflect.DeepEqual(GoRootVersions(), want) {
+		t.Errorf("GoRootVersions() = %v, want %v", GoRootVersions(), want)
+	}
+
+	dir, err := Get(nil, "fmt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Version != "go1.22.1" || len(dir.Files) != 2 || dir.ProjectRoot != "" {
+		t.Errorf("Get(fmt) = %+v", dir)
+	}
+	if _, err := Get(nil, "fmt", dir.Etag); err != ErrNotModified {
+		t.Errorf("Get(fmt) with saved etag returned %v, want ErrNotModified", err)
+	}
+
+	dir, err = GetGoRoot("fmt", "go1.21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Version != "go1.21" || len(dir.Files) != 1 {
+		t.Errorf("GetGoRoot(fmt, go1.21) = %+v", dir)
+	}
+	for _, version := range []string{"go1.20", ""} {
+		if _, err := GetGoRoot("fmt", version); !IsNotFound(err) {
+			t.Errorf("GetGoRoot(fmt, %q) returned %v, want not found error", version, err)
+		}
+	}
+	if _, err := GetGoRoot("net/http", "go1.21"); !IsNotFound(err) {
+		t.Errorf("GetGoRoot(net/http, go1.21) returned %v, want not found error", err)
+	}
+}
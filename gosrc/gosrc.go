@@ -12,10 +12,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // File represents a file.
@@ -53,10 +55,54 @@ type Directory struct {
 	// Version control: belongs to a dead end fork
 	DeadEndFork bool
 
+	// Repository signals reported by the service. The fields are zero if the
+	// service does not report the signal.
+	Fork     bool      // the repository is a fork of another repository
+	Archived bool      // the repository is archived
+	Stars    int       // number of stars
+	Pushed   time.Time // time of the last push
+
+	// True if the directory is in a private repository.
+	Private bool
+
+	// True if the files are from a module zip matching the checksum database
+	// set by SetChecksumDB.
+	Verified bool
+
+	// True if the directory is the root of a module nested in the
+	// repository. The project root of a nested module is the module path,
+	// also for the directories below the root of the module.
+	NestedModule bool
+
+	// Contents of the go.mod files in the directories between the project
+	// root and the directory, by import path of the directory. Set by the
+	// services with the files of the repository at hand.
+	parentGoMods map[string][]byte
+
+	// True if the directory contains an IgnoreFile or if the fetched version
+	// is retracted in the go.mod file.
+	OptOut bool
+
+	// Module path declared in the go.mod file of the directory if the module
+	// path is not the import path, or "".
+	Canonical string
+
+	// Hash of the project owner's key in the OwnerMeta meta tag of the
+	// project root, if any. An OwnerFile in the directory takes precedence.
+	OwnerKeyHash string
+
 	// Cache validation tag. This tag is not necessarily an HTTP entity tag.
 	// The tag is "" if there is no meaningful cache validation for the VCS.
 	Etag string
 
+	// Release tag that refers to the fetched revision, or "" if the revision
+	// is not tagged or the service does not report tags.
+	Version string
+
+	// Versions of the module in the module proxy, newest first, for
+	// directories fetched from the module proxy.
+	ModuleVersions []string
+
 	// Files.
 	Files []*File
 
@@ -82,6 +128,16 @@ type NotFoundError struct {
 
 	// Redirect specifies the path where package can be found.
 	Redirect string
+
+	// Moved is true if the repository of the directory moved to the
+	// repository of the Redirect path, for example a GitHub repository
+	// renamed or transferred to another owner.
+	Moved bool
+
+	// MovedFrom and MovedTo are the project roots of the repository before
+	// and after the move if Moved is true.
+	MovedFrom string
+	MovedTo   string
 }
 
 func (e NotFoundError) Error() string {
@@ -103,6 +159,23 @@ func (e *RemoteError) Error() string {
 	return e.err.Error()
 }
 
+// NewRemoteError returns the error of a request to the host.
+func NewRemoteError(host string, err error) *RemoteError {
+	return &RemoteError{host, err}
+}
+
+// IsRateLimited returns true if the error is a response of a host limiting
+// the rate of the requests, such as the responses with status 429 and the
+// GitHub API responses with status 403 after the rate limit is exceeded.
+func IsRateLimited(err error) bool {
+	e, ok := err.(*RemoteError)
+	if !ok {
+		return false
+	}
+	msg := e.err.Error()
+	return strings.HasPrefix(msg, "429:") || strings.HasPrefix(msg, "403:") && strings.Contains(strings.ToLower(msg), "rate limit")
+}
+
 // ErrNotModified indicates that the directory matches the specified etag.
 var ErrNotModified = errors.New("package not modified")
 
@@ -147,13 +220,17 @@ func (s *service) match(importPath string) (map[string]string, error) {
 	return match, nil
 }
 
-// importMeta represents the values in a go-import meta tag.
+// importMeta represents the values in a go-import meta tag and the owner key
+// hash in a godoc-owner meta tag on the same page.
 type importMeta struct {
-	projectRoot string
-	vcs         string
-	repo        string
+	projectRoot  string
+	vcs          string
+	repo         string
+	ownerKeyHash string
 }
 
+var ownerMetaPat = regexp.MustCompile(`^sha256:([0-9a-f]{64})$`)
+
 // sourceMeta represents the values in a go-source meta tag.
 type sourceMeta struct {
 	projectRoot  string
@@ -216,6 +293,7 @@ var refreshToGodocPat = regexp.MustCompile(`(?i)^\d+; url=https?://godoc\.org/`)
 
 func parseMeta(scheme, importPath string, r io.Reader) (im *importMeta, sm *sourceMeta, redir bool, err error) {
 	errorMessage := "go-import meta tag not found"
+	ownerKeyHash := ""
 
 	d := xml.NewDecoder(r)
 	d.Strict = false
@@ -243,6 +321,12 @@ metaScan:
 				continue metaScan
 			}
 			nameAttr := attrValue(t.Attr, "name")
+			if nameAttr == OwnerMeta {
+				if m := ownerMetaPat.FindStringSubmatch(strings.TrimSpace(attrValue(t.Attr, "content"))); m != nil {
+					ownerKeyHash = m[1]
+				}
+				continue metaScan
+			}
 			if nameAttr != "go-import" && nameAttr != "go-source" {
 				continue metaScan
 			}
@@ -293,6 +377,7 @@ metaScan:
 	if im == nil {
 		return nil, nil, redir, NotFoundError{Message: fmt.Sprintf("%s at %s://%s", errorMessage, scheme, importPath)}
 	}
+	im.ownerKeyHash = ownerKeyHash
 	if sm != nil && sm.projectRoot != im.projectRoot {
 		sm = nil
 	}
@@ -306,8 +391,100 @@ var getVCSDirFn = func(client *http.Client, m map[string]string, etag string) (*
 	return nil, errNoMatch
 }
 
-// getDynamic gets a directory from a service that is not statically known.
-func getDynamic(client *http.Client, importPath, etag string) (*Directory, error) {
+var gitFallback bool
+
+// SetGitFallback enables or disables cloning of import paths that do not have
+// a go-import meta tag as Git repositories over HTTPS. The repository root is
+// assumed to be the first three or two elements of the import path.
+func SetGitFallback(enabled bool) {
+	gitFallback = enabled
+}
+
+// getGitFallback gets a directory by cloning the repository at a prefix of
+// the import path.
+func getGitFallback(client *http.Client, importPath, etag string) (*Directory, error) {
+	parts := strings.Split(importPath, "/")
+	for n := 3; n >= 2; n-- {
+		if len(parts) < n {
+			continue
+		}
+		root := strings.Join(parts[:n], "/")
+		match := map[string]string{
+			"dir":        importPath[len(root):],
+			"importPath": importPath,
+			"repo":       root,
+			"scheme":     "https",
+			"vcs":        "git",
+		}
+		dir, err := getVCSDirFn(client, match, etag)
+		if err == errNoMatch || IsNotFound(err) {
+			continue
+		}
+		if err != nil || dir == nil {
+			return nil, err
+		}
+		dir.ImportPath = importPath
+		dir.ResolvedPath = importPath
+		dir.ProjectRoot = root
+		dir.ProjectName = path.Base(root)
+		dir.ProjectURL = "https://" + root
+		return dir, nil
+	}
+	return nil, NotFoundError{Message: "go-import meta tag not found and no Git repository at https://" + importPath}
+}
+
+// ImportMeta is the resolved go-import and go-source meta tags for an import
+// path.
+type ImportMeta struct {
+	// Scheme used to fetch the meta tags.
+	Scheme string
+
+	// Values from the go-import meta tag.
+	ProjectRoot string
+	VCS         string
+	Repo        string
+
+	// Values from the go-source meta tag, if any.
+	ProjectURL   string
+	DirTemplate  string
+	FileTemplate string
+
+	// True if the page redirects back to godoc.org.
+	Redirect bool
+
+	// Hash of the project owner's key in the OwnerMeta meta tag of the
+	// project root, if any.
+	OwnerKeyHash string
+
+	// Time the meta tags were fetched.
+	Fetched time.Time
+}
+
+// MetaCache stores the resolved meta tags for import paths.
+type MetaCache interface {
+	// GetImportMeta returns the cached meta for the import path or nil if
+	// the meta is not cached.
+	GetImportMeta(importPath string) (*ImportMeta, error)
+
+	// PutImportMeta caches the meta for the import path.
+	PutImportMeta(importPath string, m *ImportMeta) error
+}
+
+var (
+	metaCache    MetaCache
+	metaCacheTTL time.Duration
+)
+
+// SetMetaCache sets the cache used for go-import meta tag resolution. Cached
+// meta tags are fetched again after ttl. A stale entry is used if the meta
+// tags cannot be fetched.
+func SetMetaCache(c MetaCache, ttl time.Duration) {
+	metaCache = c
+	metaCacheTTL = ttl
+}
+
+// fetchImportMeta fetches and checks the meta tags for an import path.
+func fetchImportMeta(client *http.Client, importPath string) (*ImportMeta, error) {
 	metaProto, im, sm, redir, err := fetchMeta(client, importPath)
 	if err != nil {
 		return nil, err
@@ -319,30 +496,112 @@ func getDynamic(client *http.Client, importPath, etag string) (*Directory, error
 		if err != nil {
 			return nil, err
 		}
+		// The owner of the project is verified on the page of the project
+		// root.
+		im.ownerKeyHash = imRoot.ownerKeyHash
 		if *imRoot != *im {
 			return nil, NotFoundError{Message: "project root mismatch."}
 		}
 	}
 
-	repo := strings.TrimSuffix(im.repo, "."+im.vcs)
+	m := &ImportMeta{
+		Scheme:      metaProto,
+		ProjectRoot: im.projectRoot,
+		VCS:         im.vcs,
+		Repo:        im.repo,
+		Redirect:    redir,
+		Fetched:     time.Now(),
+
+		OwnerKeyHash: im.ownerKeyHash,
+	}
+	if sm != nil {
+		m.ProjectURL = sm.projectURL
+		m.DirTemplate = sm.dirTemplate
+		m.FileTemplate = sm.fileTemplate
+	}
+	return m, nil
+}
+
+// resolveMeta returns the meta tags for an import path from the cache or by
+// fetching the tags. If useCache is false, then fresh cache entries are
+// ignored. The returned bool is true if the meta is from the cache.
+func resolveMeta(client *http.Client, importPath string, useCache bool) (*ImportMeta, bool, error) {
+	var cached *ImportMeta
+	if metaCache != nil {
+		var err error
+		cached, err = metaCache.GetImportMeta(importPath)
+		if err != nil {
+			log.Printf("Error getting cached meta for %s: %v", importPath, err)
+		}
+		if useCache && cached != nil && time.Since(cached.Fetched) < metaCacheTTL {
+			return cached, true, nil
+		}
+	}
+
+	m, err := fetchImportMeta(client, importPath)
+	if err != nil {
+		if cached != nil && !IsNotFound(err) {
+			log.Printf("Using stale meta for %s after error: %v", importPath, err)
+			return cached, true, nil
+		}
+		return nil, false, err
+	}
+
+	if metaCache != nil {
+		if err := metaCache.PutImportMeta(importPath, m); err != nil {
+			log.Printf("Error caching meta for %s: %v", importPath, err)
+		}
+	}
+	return m, false, nil
+}
+
+// getDynamic gets a directory from a service that is not statically known.
+func getDynamic(client *http.Client, importPath, etag string) (*Directory, error) {
+	m, cached, err := resolveMeta(client, importPath, true)
+	if gitFallback && IsNotFound(err) {
+		return getGitFallback(client, importPath, etag)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := getDynamicDir(client, importPath, etag, m)
+	if cached && IsNotFound(err) {
+		// The repository may have moved. Fetch the meta tags again.
+		mNew, _, errNew := resolveMeta(client, importPath, false)
+		if errNew != nil {
+			return nil, errNew
+		}
+		mNew.Fetched = m.Fetched
+		if *mNew != *m {
+			dir, err = getDynamicDir(client, importPath, etag, mNew)
+		}
+	}
+	return dir, err
+}
+
+// getDynamicDir gets a directory using the resolved meta tags for the import
+// path.
+func getDynamicDir(client *http.Client, importPath, etag string, m *ImportMeta) (*Directory, error) {
+	repo := strings.TrimSuffix(m.Repo, "."+m.VCS)
 	i := strings.Index(repo, "://")
 	if i < 0 {
-		return nil, NotFoundError{Message: "bad repo URL: " + im.repo}
+		return nil, NotFoundError{Message: "bad repo URL: " + m.Repo}
 	}
 	proto := repo[:i]
 	repo = repo[i+len("://"):]
-	dirName := importPath[len(im.projectRoot):]
+	dirName := importPath[len(m.ProjectRoot):]
 
 	resolvedPath := repo + dirName
 	dir, err := getStatic(client, resolvedPath, etag)
 	if err == errNoMatch {
-		resolvedPath = repo + "." + im.vcs + dirName
+		resolvedPath = repo + "." + m.VCS + dirName
 		match := map[string]string{
 			"dir":        dirName,
 			"importPath": importPath,
 			"repo":       repo,
 			"scheme":     proto,
-			"vcs":        im.vcs,
+			"vcs":        m.VCS,
 		}
 		dir, err = getVCSDirFn(client, match, etag)
 	}
@@ -351,27 +610,24 @@ func getDynamic(client *http.Client, importPath, etag string) (*Directory, error
 	}
 
 	dir.ImportPath = importPath
-	dir.ProjectRoot = im.projectRoot
+	dir.ProjectRoot = m.ProjectRoot
 	dir.ResolvedPath = resolvedPath
-	dir.ProjectName = path.Base(im.projectRoot)
-	if !redir {
-		dir.ProjectURL = metaProto + "://" + im.projectRoot
+	dir.OwnerKeyHash = m.OwnerKeyHash
+	dir.ProjectName = path.Base(m.ProjectRoot)
+	if !m.Redirect {
+		dir.ProjectURL = m.Scheme + "://" + m.ProjectRoot
 	}
 
-	if sm == nil {
-		return dir, nil
+	if isHTTPURL(m.ProjectURL) {
+		dir.ProjectURL = m.ProjectURL
 	}
 
-	if isHTTPURL(sm.projectURL) {
-		dir.ProjectURL = sm.projectURL
+	if isHTTPURL(m.DirTemplate) {
+		dir.BrowseURL = replaceDir(m.DirTemplate, dirName)
 	}
 
-	if isHTTPURL(sm.dirTemplate) {
-		dir.BrowseURL = replaceDir(sm.dirTemplate, dirName)
-	}
-
-	if isHTTPURL(sm.fileTemplate) {
-		fileTemplate := replaceDir(sm.fileTemplate, dirName)
+	if isHTTPURL(m.FileTemplate) {
+		fileTemplate := replaceDir(m.FileTemplate, dirName)
 		parts := strings.SplitN(fileTemplate, "#", 2)
 		if strings.Contains(parts[0], "{file}") {
 			for _, f := range dir.Files {
@@ -413,12 +669,22 @@ func getStatic(client *http.Client, importPath, etag string) (*Directory, error)
 
 func Get(client *http.Client, importPath string, etag string) (dir *Directory, err error) {
 	switch {
+	case !IsGoRepoPath(importPath) && inSkippedDir(importPath):
+		err = NotFoundError{Message: "Directory skipped."}
 	case localPath != "":
 		dir, err = getLocal(importPath)
+	case IsGoRepoPath(importPath) && len(goRoots) > 0:
+		dir, err = getGoRootDir(goRoots[0], importPath, etag)
 	case IsGoRepoPath(importPath):
 		dir, err = getStandardDir(client, importPath, etag)
 	case IsValidRemotePath(importPath):
-		dir, err = getStatic(client, importPath, etag)
+		err = errNoMatch
+		if proxyURL != "" {
+			dir, err = getProxyDir(client, importPath, etag)
+		}
+		if err == errNoMatch {
+			dir, err = getStatic(client, importPath, etag)
+		}
 		if err == errNoMatch {
 			dir, err = getDynamic(client, importPath, etag)
 		}
@@ -430,13 +696,27 @@ func Get(client *http.Client, importPath string, etag string) (dir *Directory, e
 		err = NotFoundError{Message: "Import path not valid:"}
 	}
 
+	if err == nil && !IsGoRepoPath(importPath) {
+		if err = applyFetchLimits(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if err == nil && localPath == "" && !IsGoRepoPath(importPath) {
+		setNestedModule(dir)
+		setOptOut(dir)
+		setCanonical(dir)
+		if err = scanDirectory(dir); err != nil {
+			dir = nil
+		}
+	}
+
 	return dir, err
 }
 
 // GetPresentation gets a presentation from the the given path.
 func GetPresentation(client *http.Client, importPath string) (*Presentation, error) {
-	ext := path.Ext(importPath)
-	if ext != ".slide" && ext != ".article" {
+	if !IsTalkFile(importPath) {
 		return nil, NotFoundError{Message: "unknown file extension."}
 	}
 
@@ -7,6 +7,7 @@
 package gosrc
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 var testWeb = map[string]string{
@@ -64,6 +66,15 @@ var testWeb = map[string]string{
 	"https://bob.com/pkg/source": `<head>` +
 		`<meta name="go-import" content="bob.com/pkg git https://vcs.net/bob/pkg.git">` +
 		`<meta name="go-source" content="bob.com/pkg http://bob.com/pkg http://bob.com/pkg{/dir}/ http://bob.com/pkg{/dir}/?f={file}#Line{line}">`,
+	// Project verified with a godoc-owner meta tag on the page of the root.
+	"https://carol.org/pkg": `<head>` +
+		`<meta name="go-import" content="carol.org/pkg git https://github.com/carol/pkg">` +
+		`<meta name="godoc-owner" content="sha256:` + testOwnerKeyHash + `">` +
+		`</head>`,
+	"https://carol.org/pkg/sub": `<head>` +
+		`<meta name="go-import" content="carol.org/pkg git https://github.com/carol/pkg">` +
+		`<meta name="godoc-owner" content="sha256:not-a-hash">` +
+		`</head>`,
 	// Meta refresh to godoc.org
 	"http://rsc.io/benchstat": `<head>` +
 		`<!DOCTYPE html><html><head>` +
@@ -232,6 +243,124 @@ func testGet(client *http.Client, match map[string]string, etag string) (*Direct
 	return nil, errNoMatch
 }
 
+type testMetaCache map[string]*ImportMeta
+
+func (c testMetaCache) GetImportMeta(importPath string) (*ImportMeta, error) {
+	return c[importPath], nil
+}
+
+func (c testMetaCache) PutImportMeta(importPath string, m *ImportMeta) error {
+	c[importPath] = m
+	return nil
+}
+
+type errorTransport struct{}
+
+func (errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("network down")
+}
+
+func TestMetaCache(t *testing.T) {
+	savedServices := services
+	savedGetVCSDirFn := getVCSDirFn
+	defer func() {
+		services = savedServices
+		getVCSDirFn = savedGetVCSDirFn
+		SetMetaCache(nil, 0)
+	}()
+	services = []*service{{pattern: regexp.MustCompile(".*"), get: testGet}}
+	getVCSDirFn = testGet
+	cache := make(testMetaCache)
+	SetMetaCache(cache, time.Hour)
+
+	client := &http.Client{Transport: testTransport(testWeb)}
+	want, err := getDynamic(client, "alice.org/pkg/source", "")
+	if err != nil {
+		t.Fatalf("getDynamic returned error %v", err)
+	}
+	m := cache["alice.org/pkg/source"]
+	if m == nil || m.ProjectRoot != "alice.org/pkg" || m.FileTemplate == "" {
+		t.Fatalf("cached meta = %+v, want alice.org/pkg with go-source", m)
+	}
+
+	// Fresh and stale entries are used when the meta tags cannot be fetched.
+	client = &http.Client{Transport: errorTransport{}}
+	for _, fetched := range []time.Time{time.Now(), time.Now().Add(-2 * time.Hour)} {
+		m.Fetched = fetched
+		dir, err := getDynamic(client, "alice.org/pkg/source", "")
+		if err != nil {
+			t.Errorf("getDynamic with cached meta fetched at %v returned error %v", fetched, err)
+			continue
+		}
+		if !reflect.DeepEqual(dir, want) {
+			t.Errorf("getDynamic with cached meta =\n     %+v,\nwant %+v", dir, want)
+		}
+	}
+}
+
+const testOwnerKeyHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestOwnerMeta(t *testing.T) {
+	savedServices := services
+	defer func() { services = savedServices }()
+	services = []*service{{pattern: regexp.MustCompile(".*"), get: testGet}}
+
+	client := &http.Client{Transport: testTransport(testWeb)}
+	for _, importPath := range []string{"carol.org/pkg", "carol.org/pkg/sub"} {
+		dir, err := getDynamic(client, importPath, "")
+		if err != nil {
+			t.Errorf("getDynamic(%q) returned error %v", importPath, err)
+			continue
+		}
+		if dir.OwnerKeyHash != testOwnerKeyHash {
+			t.Errorf("getDynamic(%q).OwnerKeyHash = %q, want %q", importPath, dir.OwnerKeyHash, testOwnerKeyHash)
+		}
+	}
+	dir, err := getDynamic(client, "alice.org/pkg", "")
+	if err != nil || dir.OwnerKeyHash != "" {
+		t.Errorf("getDynamic(alice.org/pkg) = %+v, %v, want no owner", dir, err)
+	}
+}
+
+func TestGetGitFallback(t *testing.T) {
+	savedServices := services
+	savedGetVCSDirFn := getVCSDirFn
+	defer func() {
+		services = savedServices
+		getVCSDirFn = savedGetVCSDirFn
+		SetGitFallback(false)
+	}()
+	services = []*service{{pattern: regexp.MustCompile(".*"), get: testGet}}
+	getVCSDirFn = testGet
+	client := &http.Client{Transport: testTransport(testWeb)}
+
+	if _, err := getDynamic(client, "vcs.net/alice/pkg/sub", ""); !IsNotFound(err) {
+		t.Errorf("getDynamic without fallback returned %v, want not found", err)
+	}
+
+	SetGitFallback(true)
+	dir, err := getDynamic(client, "vcs.net/alice/pkg/sub", "")
+	if err != nil {
+		t.Fatalf("getDynamic returned error %v", err)
+	}
+	want := &Directory{
+		ImportPath:   "vcs.net/alice/pkg/sub",
+		ResolvedPath: "vcs.net/alice/pkg/sub",
+		ProjectName:  "pkg",
+		ProjectRoot:  "vcs.net/alice/pkg",
+		ProjectURL:   "https://vcs.net/alice/pkg",
+		VCS:          "git",
+		Files:        []*File{{Name: "main.go"}},
+	}
+	if !reflect.DeepEqual(dir, want) {
+		t.Errorf("getDynamic() =\n     %+v,\nwant %+v", dir, want)
+	}
+
+	if _, err := getDynamic(client, "example.org/pkg", ""); !IsNotFound(err) {
+		t.Errorf("getDynamic for unknown repository returned %v, want not found", err)
+	}
+}
+
 func TestGetDynamic(t *testing.T) {
 	savedServices := services
 	savedGetVCSDirFn := getVCSDirFn
@@ -270,3 +399,20 @@ func TestGetDynamic(t *testing.T) {
 		}
 	}
 }
+
+func TestIsRateLimited(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{&RemoteError{"gitlab.com", errors.New("429: rate limit exceeded, retry after 10 (https://gitlab.com/api)")}, true},
+		{&RemoteError{"api.github.com", errors.New("403: API rate limit exceeded for 1.2.3.4. (https://api.github.com/repos)")}, true},
+		{&RemoteError{"api.github.com", errors.New("403: Repository access blocked (https://api.github.com/repos)")}, false},
+		{&RemoteError{"example.com", errors.New("500: (https://example.com)")}, false},
+		{NotFoundError{Message: "429: not a remote error"}, false},
+	} {
+		if got := IsRateLimited(tt.err); got != tt.want {
+			t.Errorf("IsRateLimited(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moduleIndexLimit is the number of module versions requested from a module
+// index at a time.
+const moduleIndexLimit = 2000
+
+// GetModuleIndexUpdates returns the paths of the modules with versions
+// published to the module index at indexURL after the time since and the
+// time of the last version read. The index has the protocol of
+// https://index.golang.org/index. The time is in RFC 3339 format. If since is
+// empty, the versions of the last day are returned.
+func GetModuleIndexUpdates(client *http.Client, indexURL, since string) (last string, modules []string, err error) {
+	c := &httpClient{client: client}
+	if since == "" {
+		since = time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339Nano)
+	}
+	sep := "?"
+	if strings.Contains(indexURL, "?") {
+		sep = "&"
+	}
+	q := url.Values{"since": {since}, "limit": {strconv.Itoa(moduleIndexLimit)}}
+	r, err := c.getReader(indexURL + sep + q.Encode())
+	if err != nil {
+		return since, nil, err
+	}
+	defer r.Close()
+
+	lastTime, _ := time.Parse(time.RFC3339Nano, since)
+	last = since
+	seen := make(map[string]bool)
+	d := json.NewDecoder(r)
+	for {
+		var v struct {
+			Path      string
+			Version   string
+			Timestamp time.Time
+		}
+		if err := d.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return since, nil, &RemoteError{Host: hostOf(indexURL), err: err}
+		}
+		if !seen[v.Path] {
+			seen[v.Path] = true
+			modules = append(modules, v.Path)
+		}
+		if v.Timestamp.After(lastTime) {
+			lastTime = v.Timestamp
+			last = v.Timestamp.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return last, modules, nil
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
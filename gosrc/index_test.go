@@ -0,0 +1,45 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type indexTransport struct {
+	query string
+}
+
+func (t *indexTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.query = req.URL.RawQuery
+	body := `{"Path":"example.com/a","Version":"v1.0.0","Timestamp":"2020-01-02T03:04:05.5Z"}
+{"Path":"example.com/b","Version":"v0.1.0","Timestamp":"2020-01-02T03:04:06.25Z"}
+{"Path":"example.com/a","Version":"v1.0.1","Timestamp":"2020-01-02T03:04:06.3Z"}
+`
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestGetModuleIndexUpdates(t *testing.T) {
+	tr := &indexTransport{}
+	last, modules, err := GetModuleIndexUpdates(&http.Client{Transport: tr}, "https://index.example.com/index", "2020-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/a", "example.com/b"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules = %v, want %v", modules, want)
+	}
+	if last != "2020-01-02T03:04:06.3Z" {
+		t.Errorf("last = %q, want 2020-01-02T03:04:06.3Z", last)
+	}
+	if tr.query != "limit=2000&since=2020-01-01T00%3A00%3A00Z" {
+		t.Errorf("query = %q", tr.query)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var (
+	maxFileSize  int64
+	maxFetchSize int64
+	skipDirs     = []string{"testdata", "vendor"}
+	vendorFilter func(dir string) bool
+)
+
+// errFetchTooLarge is the error for directories with files larger than the
+// limit set by SetFetchLimits in total.
+var errFetchTooLarge = NotFoundError{Message: "Directory files larger than the limit."}
+
+// SetFetchLimits sets the limits on the files fetched for a directory. Files
+// larger than fileSize bytes are skipped and directories with files larger
+// than fetchSize bytes in total are not found. Zero disables a limit.
+func SetFetchLimits(fileSize, fetchSize int64) {
+	maxFileSize = fileSize
+	maxFetchSize = fetchSize
+}
+
+// SetSkipDirs sets the names of the directories that are not fetched, by
+// default testdata and vendor. Packages in the directories are not found and
+// the directories are not listed as subdirectories.
+func SetSkipDirs(names []string) {
+	var dirs []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			dirs = append(dirs, name)
+		}
+	}
+	skipDirs = dirs
+}
+
+// SetVendorFilter sets the function reporting whether the vendor directory
+// in the directory with the import path is fetched even if vendor is a
+// skipped directory. The vendored packages are fetched as packages in the
+// vendor directory, as the project has them.
+func SetVendorFilter(f func(dir string) bool) {
+	vendorFilter = f
+}
+
+func isSkippedDir(name string) bool {
+	for _, d := range skipDirs {
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+// isSkippedSubdir returns true if the subdirectory with the name of the
+// directory with the import path is skipped.
+func isSkippedSubdir(dir, name string) bool {
+	if !isSkippedDir(name) {
+		return false
+	}
+	return name != "vendor" || vendorFilter == nil || !vendorFilter(dir)
+}
+
+// inSkippedDir returns true if a directory in the import path is skipped.
+func inSkippedDir(importPath string) bool {
+	names := strings.Split(importPath, "/")
+	for i, name := range names {
+		if i > 0 && isSkippedSubdir(strings.Join(names[:i], "/"), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// readFileData reads the contents of a file up to one byte more than the
+// file size limit. The extra byte marks the file for removal by
+// applyFetchLimits.
+func readFileData(r io.Reader) ([]byte, error) {
+	if maxFileSize > 0 {
+		r = io.LimitReader(r, maxFileSize+1)
+	}
+	return ioutil.ReadAll(r)
+}
+
+// isBinary returns true if the data looks like the contents of a binary
+// file, using the heuristic of git: a NUL byte in the first 8000 bytes.
+func isBinary(p []byte) bool {
+	if len(p) > 8000 {
+		p = p[:8000]
+	}
+	return bytes.IndexByte(p, 0) >= 0
+}
+
+// applyFetchLimits removes the large files, the binary files and the
+// skipped subdirectories from the directory.
+func applyFetchLimits(dir *Directory) error {
+	var files []*File
+	var total int64
+	for _, f := range dir.Files {
+		if (maxFileSize > 0 && int64(len(f.Data)) > maxFileSize) || isBinary(f.Data) {
+			continue
+		}
+		total += int64(len(f.Data))
+		files = append(files, f)
+	}
+	if maxFetchSize > 0 && total > maxFetchSize {
+		return errFetchTooLarge
+	}
+	dir.Files = files
+
+	var subdirs []string
+	for _, d := range dir.Subdirectories {
+		if !isSkippedSubdir(dir.ImportPath, d) {
+			subdirs = append(subdirs, d)
+		}
+	}
+	dir.Subdirectories = subdirs
+	return nil
+}
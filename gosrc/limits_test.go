@@ -0,0 +1,83 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFetchLimits(t *testing.T) {
+	defer SetFetchLimits(0, 0)
+	defer SetSkipDirs([]string{"testdata", "vendor"})
+	SetFetchLimits(10, 15)
+	SetSkipDirs([]string{" vendor ", ""})
+
+	dir := &Directory{
+		Files: []*File{
+			{Name: "a.go", Data: []byte("package a")},
+			{Name: "big.go", Data: []byte("package big // ...")},
+			{Name: "bin.go", Data: []byte("pack\x00age")},
+			{Name: "b.go", Data: []byte("package b")},
+		},
+		Subdirectories: []string{"sub", "vendor", "testdata"},
+	}
+	if err := applyFetchLimits(dir); err != errFetchTooLarge {
+		t.Errorf("applyFetchLimits() over the total limit returned %v, want %v", err, errFetchTooLarge)
+	}
+
+	dir.Files = dir.Files[1:]
+	if err := applyFetchLimits(dir); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range dir.Files {
+		names = append(names, f.Name)
+	}
+	if want := []string{"b.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+	if want := []string{"sub", "testdata"}; !reflect.DeepEqual(dir.Subdirectories, want) {
+		t.Errorf("subdirectories = %v, want %v", dir.Subdirectories, want)
+	}
+	if !inSkippedDir("github.com/user/repo/vendor/x") || inSkippedDir("github.com/user/repo/testdata") {
+		t.Error("inSkippedDir does not use the skipped directories")
+	}
+}
+
+func TestVendorFilter(t *testing.T) {
+	defer SetVendorFilter(nil)
+	SetVendorFilter(func(dir string) bool { return dir == "github.com/user/repo" })
+
+	if inSkippedDir("github.com/user/repo/vendor/example.com/x") {
+		t.Error("inSkippedDir returned true for a package in an indexed vendor directory")
+	}
+	if !inSkippedDir("github.com/user/other/vendor/example.com/x") {
+		t.Error("inSkippedDir returned false for a package in a vendor directory not indexed")
+	}
+	if !inSkippedDir("github.com/user/repo/vendor/example.com/x/testdata") {
+		t.Error("inSkippedDir returned false for testdata in an indexed vendor directory")
+	}
+
+	dir := &Directory{ImportPath: "github.com/user/repo", Subdirectories: []string{"sub", "vendor", "testdata"}}
+	if err := applyFetchLimits(dir); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sub", "vendor"}; !reflect.DeepEqual(dir.Subdirectories, want) {
+		t.Errorf("subdirectories = %v, want %v", dir.Subdirectories, want)
+	}
+}
+
+func TestReadFileData(t *testing.T) {
+	defer SetFetchLimits(0, 0)
+	SetFetchLimits(4, 0)
+	p, err := readFileData(strings.NewReader("0123456789"))
+	if err != nil || string(p) != "01234" {
+		t.Errorf("readFileData() = %q, %v, want the limit plus one byte", p, err)
+	}
+}
@@ -9,8 +9,12 @@ package gosrc
 import (
 	"go/build"
 	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,15 +36,27 @@ func getLocal(importPath string) (*Directory, error) {
 	if err != nil {
 		return nil, err
 	}
-	dir := filepath.Join(bpkg.SrcRoot, filepath.FromSlash(importPath))
+	return readLocalDir(filepath.Join(bpkg.SrcRoot, filepath.FromSlash(importPath)), importPath)
+}
+
+// readLocalDir returns the directory with the import path read from dir in
+// the local file system.
+func readLocalDir(dir, importPath string) (*Directory, error) {
 	fis, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 	var modTime time.Time
 	var files []*File
+	var subdirs []string
 	for _, fi := range fis {
-		if fi.IsDir() || !isDocFile(fi.Name()) {
+		if fi.IsDir() {
+			if isLocalPackageDir(fi.Name()) {
+				subdirs = append(subdirs, fi.Name())
+			}
+			continue
+		}
+		if !isDocFile(fi.Name()) {
 			continue
 		}
 		if fi.ModTime().After(modTime) {
@@ -56,8 +72,116 @@ func getLocal(importPath string) (*Directory, error) {
 		})
 	}
 	return &Directory{
-		ImportPath: importPath,
-		Etag:       strconv.FormatInt(modTime.Unix(), 16),
-		Files:      files,
+		ImportPath:     importPath,
+		Etag:           strconv.FormatInt(modTime.Unix(), 16),
+		Files:          files,
+		Subdirectories: subdirs,
 	}, nil
 }
+
+// isLocalPackageDir returns true if the go command looks for packages in the
+// directory with the name.
+func isLocalPackageDir(name string) bool {
+	return !isSkippedDir(name) && !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "_")
+}
+
+// LocalModule is a module in a directory of the local file system.
+type LocalModule struct {
+	// Path is the module path.
+	Path string
+
+	// Dir is the root directory of the module.
+	Dir string
+}
+
+// OpenLocalModule returns the module with the root directory dir. The module
+// path is declared in the go.mod file of the directory. Without a go.mod
+// file, the module path is the base name of the directory.
+func OpenLocalModule(dir string) (*LocalModule, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	m := &LocalModule{Path: filepath.Base(dir), Dir: dir}
+	p, err := ioutil.ReadFile(filepath.Join(dir, goModFile))
+	switch {
+	case err == nil:
+		if mp := modulePath(p); mp != "" {
+			m.Path = mp
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	return m, nil
+}
+
+// Get returns the directory of the module with the import path. Get returns
+// a NotFoundError if the import path is not in the module.
+func (m *LocalModule) Get(importPath string) (*Directory, error) {
+	rel := ""
+	if importPath != m.Path {
+		if !strings.HasPrefix(importPath, m.Path+"/") {
+			return nil, NotFoundError{Message: "not in module " + m.Path}
+		}
+		rel = importPath[len(m.Path)+1:]
+		for _, elem := range strings.Split(rel, "/") {
+			if elem == "" || !isLocalPackageDir(elem) {
+				return nil, NotFoundError{Message: "invalid path"}
+			}
+		}
+	}
+	dir, err := readLocalDir(filepath.Join(m.Dir, filepath.FromSlash(rel)), importPath)
+	if os.IsNotExist(err) {
+		return nil, NotFoundError{Message: "directory not found"}
+	} else if err != nil {
+		return nil, err
+	}
+	dir.ResolvedPath = importPath
+	dir.ProjectRoot = m.Path
+	dir.ProjectName = path.Base(m.Path)
+	if importPath != m.Path {
+		for _, f := range dir.Files {
+			if f.Name == goModFile {
+				// The directory is the root of a nested module.
+				return nil, NotFoundError{Message: "not in module " + m.Path}
+			}
+		}
+	}
+	return dir, nil
+}
+
+// Packages returns the import paths of the directories of the module with Go
+// files, sorted by import path. Directories of nested modules are skipped.
+func (m *LocalModule) Packages() ([]string, error) {
+	var paths []string
+	err := filepath.Walk(m.Dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if p != m.Dir {
+			if !isLocalPackageDir(fi.Name()) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, goModFile)); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		if matches, _ := filepath.Glob(filepath.Join(p, "*.go")); len(matches) > 0 {
+			rel, err := filepath.Rel(m.Dir, p)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				paths = append(paths, m.Path)
+			} else {
+				paths = append(paths, m.Path+"/"+filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
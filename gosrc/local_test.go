@@ -0,0 +1,83 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLocalModule(t *testing.T) {
+	root, err := ioutil.TempDir("", "module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for name, data := range map[string]string{
+		"go.mod":                    "module example.com/mod\n",
+		"mod.go":                    "package mod\n",
+		"README.md":                 "# mod\n",
+		"sub/sub.go":                "package sub\n",
+		"sub/testdata/x.go":         "package x\n",
+		"docs/index.txt":            "docs\n",
+		"nested/go.mod":             "module example.com/mod/nested\n",
+		"nested/nested.go":          "package nested\n",
+		".hidden/hidden.go":         "package hidden\n",
+		"vendor/example.com/v/v.go": "package v\n",
+	} {
+		fname := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := OpenLocalModule(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Path != "example.com/mod" {
+		t.Errorf("Path = %q, want example.com/mod", m.Path)
+	}
+
+	paths, err := m.Packages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/mod", "example.com/mod/sub"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Packages() = %v, want %v", paths, want)
+	}
+
+	dir, err := m.Get("example.com/mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range dir.Files {
+		names = append(names, f.Name)
+	}
+	if want := []string{"README.md", "go.mod", "mod.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Files = %v, want %v", names, want)
+	}
+	if dir.ProjectRoot != "example.com/mod" || dir.ProjectName != "mod" {
+		t.Errorf("ProjectRoot, ProjectName = %q, %q", dir.ProjectRoot, dir.ProjectName)
+	}
+	if want := []string{"docs", "nested", "sub"}; !reflect.DeepEqual(dir.Subdirectories, want) {
+		t.Errorf("Subdirectories = %v, want %v", dir.Subdirectories, want)
+	}
+
+	for _, p := range []string{"example.com/other", "example.com/modx", "example.com/mod/missing", "example.com/mod/nested", "example.com/mod/.hidden", "example.com/mod/sub/../sub", "example.com/mod//sub"} {
+		if _, err := m.Get(p); !IsNotFound(err) {
+			t.Errorf("Get(%q) = %v, want not found error", p, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	gopkgInElement = regexp.MustCompile(`^(.+)\.(v[0-9]+)$`)
+	majorElement   = regexp.MustCompile(`^v[1-9][0-9]*$`)
+)
+
+// MajorVersion returns the import path with the major version removed and
+// the major version of the import path, such as "v2". The major version of a
+// gopkg.in path is the suffix of the package element, gopkg.in/yaml.v2 or
+// gopkg.in/user/pkg.v3. The major version of a module path is a vN element
+// after the host and the first element, github.com/user/repo/v2 or
+// example.com/mod/v2, for N >= 2. The major version
+// of other import paths is "".
+func MajorVersion(importPath string) (base, version string) {
+	parts := strings.Split(importPath, "/")
+	if parts[0] == "gopkg.in" {
+		for i := 1; i < len(parts) && i <= 2; i++ {
+			if m := gopkgInElement.FindStringSubmatch(parts[i]); m != nil {
+				parts[i] = m[1]
+				return strings.Join(parts, "/"), m[2]
+			}
+		}
+		return importPath, ""
+	}
+	for i := 2; i < len(parts); i++ {
+		if majorElement.MatchString(parts[i]) && parts[i] != "v1" {
+			return strings.Join(append(parts[:i:i], parts[i+1:]...), "/"), parts[i]
+		}
+	}
+	return importPath, ""
+}
+
+// MajorNumber returns the number of the major version returned by
+// MajorVersion. The number of "" is 1.
+func MajorNumber(version string) int {
+	if version == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	return n
+}
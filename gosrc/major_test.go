@@ -0,0 +1,44 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"testing"
+)
+
+var majorVersionTests = []struct {
+	importPath, base, version string
+}{
+	{"github.com/user/repo", "github.com/user/repo", ""},
+	{"github.com/user/repo/v2", "github.com/user/repo", "v2"},
+	{"github.com/user/repo/v12/sub", "github.com/user/repo/sub", "v12"},
+	{"github.com/user/repo/v1", "github.com/user/repo/v1", ""},
+	{"example.com/mod/v3", "example.com/mod", "v3"},
+	{"example.com/v3", "example.com/v3", ""},
+	{"gopkg.in/yaml.v2", "gopkg.in/yaml", "v2"},
+	{"gopkg.in/yaml.v2/sub", "gopkg.in/yaml/sub", "v2"},
+	{"gopkg.in/user/pkg.v0", "gopkg.in/user/pkg", "v0"},
+	{"gopkg.in/user/pkg", "gopkg.in/user/pkg", ""},
+	{"net/http", "net/http", ""},
+}
+
+func TestMajorVersion(t *testing.T) {
+	for _, tt := range majorVersionTests {
+		base, version := MajorVersion(tt.importPath)
+		if base != tt.base || version != tt.version {
+			t.Errorf("MajorVersion(%q) = %q, %q, want %q, %q", tt.importPath, base, version, tt.base, tt.version)
+		}
+	}
+}
+
+func TestMajorNumber(t *testing.T) {
+	for version, want := range map[string]int{"": 1, "v0": 0, "v2": 2, "v10": 10} {
+		if n := MajorNumber(version); n != want {
+			t.Errorf("MajorNumber(%q) = %d, want %d", version, n, want)
+		}
+	}
+}
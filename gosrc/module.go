@@ -0,0 +1,183 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goModFile is the name of the file in the root directory of a module.
+const goModFile = "go.mod"
+
+// modulePath returns the module path declared in the contents of a go.mod
+// file or "" if the file does not declare a module path.
+func modulePath(p []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(p))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		line = strings.TrimSpace(line[len("module"):])
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "`") {
+			var err error
+			line, err = strconv.Unquote(line)
+			if err != nil {
+				return ""
+			}
+		}
+		return line
+	}
+	return ""
+}
+
+// retractions returns the version intervals retracted by the retract
+// directives in the contents of a go.mod file. A single version is returned
+// as an interval with equal bounds.
+func retractions(p []byte) [][2]string {
+	var result [][2]string
+	inBlock := false
+	s := bufio.NewScanner(bytes.NewReader(p))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case strings.HasPrefix(line, "retract"):
+			line = strings.TrimSpace(line[len("retract"):])
+			if line == "(" {
+				inBlock = true
+				continue
+			}
+		default:
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			bounds := strings.Split(line[1:len(line)-1], ",")
+			if len(bounds) == 2 {
+				result = append(result, [2]string{strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])})
+			}
+		} else if line != "" {
+			result = append(result, [2]string{line, line})
+		}
+	}
+	return result
+}
+
+// isRetracted returns true if version is in one of the retracted intervals.
+func isRetracted(version string, retracted [][2]string) bool {
+	v, ok := parseSemver(version)
+	if !ok {
+		return false
+	}
+	for _, r := range retracted {
+		lo, okLo := parseSemver(r[0])
+		hi, okHi := parseSemver(r[1])
+		if okLo && okHi && !v.less(lo) && !hi.less(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOptOut marks the directory as opted out of indexing if the directory
+// contains an IgnoreFile or if the go.mod file retracts the fetched version.
+func setOptOut(dir *Directory) {
+	for _, f := range dir.Files {
+		switch {
+		case f.Name == IgnoreFile:
+			dir.OptOut = true
+		case f.Name == goModFile && isRetracted(dir.Version, retractions(f.Data)):
+			dir.OptOut = true
+		}
+	}
+}
+
+// setNestedModule makes the directory the root of a separate project if the
+// directory contains the go.mod file of a module nested below the project
+// root. The project root of a directory below the root of a nested module is
+// the module path declared in the nearest go.mod file of the parent
+// directories.
+func setNestedModule(dir *Directory) {
+	if dir.ProjectRoot == "" || dir.ImportPath == dir.ProjectRoot {
+		return
+	}
+	for _, f := range dir.Files {
+		if f.Name == goModFile {
+			if modulePath(f.Data) == dir.ImportPath {
+				dir.NestedModule = true
+				dir.ProjectRoot = dir.ImportPath
+				dir.ProjectName = path.Base(dir.ImportPath)
+			}
+			return
+		}
+	}
+	for p := path.Dir(dir.ImportPath); strings.HasPrefix(p, dir.ProjectRoot+"/"); p = path.Dir(p) {
+		if data, ok := dir.parentGoMods[p]; ok {
+			if modulePath(data) == p {
+				dir.ProjectRoot = p
+				dir.ProjectName = path.Base(p)
+			}
+			return
+		}
+	}
+}
+
+// getParentGoMods returns the contents of the go.mod files in the parent
+// directories of the directory dir below the repository root, by import path
+// of the directory. The root is the import path of the repository root and
+// the paths of the directories start with "/". The function get returns the
+// contents of the go.mod file in a directory or an error satisfying
+// IsNotFound if the directory has no go.mod file.
+func getParentGoMods(root, dir string, get func(dir string) ([]byte, error)) (map[string][]byte, error) {
+	var m map[string][]byte
+	for d := path.Dir(dir); d != "/" && d != "." && d != ""; d = path.Dir(d) {
+		data, err := get(d)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m == nil {
+			m = make(map[string][]byte)
+		}
+		m[root+d] = data
+	}
+	return m, nil
+}
+
+var majorVersionSuffix = regexp.MustCompile(`/v[0-9]+$`)
+
+// setCanonical records the module path declared in the go.mod file of the
+// directory as the canonical import path if the directory is fetched from a
+// different path, such as the repository of a module with a custom import
+// path. The major version suffix of a module path is not a different path.
+func setCanonical(dir *Directory) {
+	for _, f := range dir.Files {
+		if f.Name == goModFile {
+			p := modulePath(f.Data)
+			if p != "" && p != dir.ImportPath && majorVersionSuffix.ReplaceAllString(p, "") != dir.ImportPath && IsValidRemotePath(p) {
+				dir.Canonical = p
+			}
+			return
+		}
+	}
+}
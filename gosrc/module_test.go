@@ -0,0 +1,155 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"reflect"
+	"testing"
+)
+
+var modulePathTests = []struct {
+	data string
+	path string
+}{
+	{"module example.com/a\n\nrequire example.com/b v1.0.0\n", "example.com/a"},
+	{"// comment\nmodule \"example.com/a/v2\" // major version\n", "example.com/a/v2"},
+	{"require example.com/b v1.0.0\n", ""},
+}
+
+func TestModulePath(t *testing.T) {
+	for _, tt := range modulePathTests {
+		if path := modulePath([]byte(tt.data)); path != tt.path {
+			t.Errorf("modulePath(%q) = %q, want %q", tt.data, path, tt.path)
+		}
+	}
+}
+
+func TestSetNestedModule(t *testing.T) {
+	dir := &Directory{
+		ImportPath:  "github.com/user/repo/tools",
+		ProjectRoot: "github.com/user/repo",
+		ProjectName: "repo",
+		Files:       []*File{{Name: "go.mod", Data: []byte("module github.com/user/repo/tools\n")}},
+	}
+	setNestedModule(dir)
+	if !dir.NestedModule || dir.ProjectRoot != dir.ImportPath || dir.ProjectName != "tools" {
+		t.Errorf("setNestedModule: NestedModule=%v, ProjectRoot=%q, ProjectName=%q; want true, %q, tools", dir.NestedModule, dir.ProjectRoot, dir.ProjectName, dir.ImportPath)
+	}
+
+	dir = &Directory{
+		ImportPath:  "github.com/user/repo/internal",
+		ProjectRoot: "github.com/user/repo",
+		Files:       []*File{{Name: "go.mod", Data: []byte("module example.com/other\n")}},
+	}
+	setNestedModule(dir)
+	if dir.NestedModule || dir.ProjectRoot != "github.com/user/repo" {
+		t.Errorf("setNestedModule with other module path: NestedModule=%v, ProjectRoot=%q; want false, github.com/user/repo", dir.NestedModule, dir.ProjectRoot)
+	}
+
+	dir = &Directory{
+		ImportPath:  "github.com/user/repo/tools/cmd/lint",
+		ProjectRoot: "github.com/user/repo",
+		ProjectName: "repo",
+		parentGoMods: map[string][]byte{
+			"github.com/user/repo/tools": []byte("module github.com/user/repo/tools\n"),
+		},
+	}
+	setNestedModule(dir)
+	if dir.NestedModule || dir.ProjectRoot != "github.com/user/repo/tools" || dir.ProjectName != "tools" {
+		t.Errorf("setNestedModule in nested module: NestedModule=%v, ProjectRoot=%q, ProjectName=%q; want false, github.com/user/repo/tools, tools", dir.NestedModule, dir.ProjectRoot, dir.ProjectName)
+	}
+}
+
+func TestGetParentGoMods(t *testing.T) {
+	var dirs []string
+	m, err := getParentGoMods("github.com/user/repo", "/tools/cmd/lint", func(dir string) ([]byte, error) {
+		dirs = append(dirs, dir)
+		if dir == "/tools" {
+			return []byte("module github.com/user/repo/tools\n"), nil
+		}
+		return nil, NotFoundError{Message: "not found"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/tools/cmd", "/tools"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("getParentGoMods got dirs %v, want %v", dirs, want)
+	}
+	if want := map[string][]byte{"github.com/user/repo/tools": []byte("module github.com/user/repo/tools\n")}; !reflect.DeepEqual(m, want) {
+		t.Errorf("getParentGoMods = %q, want %q", m, want)
+	}
+}
+
+var isRetractedTests = []struct {
+	version   string
+	retracted bool
+}{
+	{"v1.0.0", true},
+	{"v1.1.0", false},
+	{"v1.2.5", true},
+	{"v2.0.0", false},
+	{"master", false},
+}
+
+func TestIsRetracted(t *testing.T) {
+	retracted := retractions([]byte(`module example.com/a
+
+retract v1.0.0 // published by mistake
+retract (
+	[v1.2.0, v1.3.0]
+	v1.9.0
+)
+`))
+	if len(retracted) != 3 {
+		t.Fatalf("retractions returned %v, want 3 intervals", retracted)
+	}
+	for _, tt := range isRetractedTests {
+		if r := isRetracted(tt.version, retracted); r != tt.retracted {
+			t.Errorf("isRetracted(%q) = %v, want %v", tt.version, r, tt.retracted)
+		}
+	}
+}
+
+func TestSetOptOut(t *testing.T) {
+	dir := &Directory{Files: []*File{{Name: IgnoreFile}}}
+	setOptOut(dir)
+	if !dir.OptOut {
+		t.Errorf("setOptOut with %s: OptOut = false, want true", IgnoreFile)
+	}
+
+	dir = &Directory{Version: "v1.0.0", Files: []*File{{Name: "go.mod", Data: []byte("module example.com/a\nretract v1.0.0\n")}}}
+	setOptOut(dir)
+	if !dir.OptOut {
+		t.Errorf("setOptOut with retracted version: OptOut = false, want true")
+	}
+
+	dir = &Directory{Version: "v1.1.0", Files: []*File{{Name: "go.mod", Data: []byte("module example.com/a\nretract v1.0.0\n")}}}
+	setOptOut(dir)
+	if dir.OptOut {
+		t.Errorf("setOptOut with current version: OptOut = true, want false")
+	}
+}
+
+var setCanonicalTests = []struct {
+	importPath, module string
+	canonical          string
+}{
+	{"github.com/user/repo", "example.com/repo", "example.com/repo"},
+	{"github.com/user/repo", "github.com/user/repo", ""},
+	{"github.com/user/repo", "github.com/user/repo/v2", ""},
+	{"github.com/user/repo", "repo", ""},
+}
+
+func TestSetCanonical(t *testing.T) {
+	for _, tt := range setCanonicalTests {
+		dir := &Directory{ImportPath: tt.importPath, Files: []*File{{Name: "go.mod", Data: []byte("module " + tt.module + "\n")}}}
+		setCanonical(dir)
+		if dir.Canonical != tt.canonical {
+			t.Errorf("setCanonical(%s, module %s): Canonical = %q, want %q", tt.importPath, tt.module, dir.Canonical, tt.canonical)
+		}
+	}
+}
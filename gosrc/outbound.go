@@ -0,0 +1,362 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The import paths of the packages fetched on demand are chosen by the
+// visitors of the site. With the outbound policy set by SetOutboundPolicy,
+// the requests of the fetchers, the redirects they follow and the version
+// control commands only go to hosts resolving to public addresses: the
+// private, loopback, link-local, such as the cloud metadata endpoints, and
+// reserved address ranges and the hosts in the deny list are not fetched.
+// The hosts are checked before the requests and the addresses again when
+// connecting, and the version control commands connect through a proxy
+// applying the policy.
+// The hosts in the allow list and the hosts configured for the fetchers,
+// such as the GitLab instances, the module proxy and the hosts with
+// credentials, are always fetched.
+
+// defaultDeniedNets are the address ranges denied by the outbound policy.
+var defaultDeniedNets = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// defaultDeniedHosts are the host patterns denied by the outbound policy.
+var defaultDeniedHosts = []string{"localhost", "*.localhost", "metadata.google.internal"}
+
+var outbound struct {
+	mu         sync.RWMutex
+	enabled    bool
+	allowHosts []string
+	allowNets  []*net.IPNet
+	denyHosts  []string
+	denyNets   []*net.IPNet
+
+	// trusted are the hosts configured for the fetchers.
+	trusted map[string]bool
+}
+
+// outboundLookupTimeout limits the time to resolve the host of a request.
+const outboundLookupTimeout = 5 * time.Second
+
+// lookupIPAddr is replaced in tests.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// parseOutboundRules splits rules, each an IP address, a CIDR network, a host
+// name or a *.domain pattern matching the subdomains of domain, into host
+// patterns and networks.
+func parseOutboundRules(rules []string) ([]string, []*net.IPNet, error) {
+	var hosts []string
+	var nets []*net.IPNet
+	for _, r := range rules {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if r == "" {
+			continue
+		}
+		if ip := net.ParseIP(r); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if strings.Contains(r, "/") {
+			_, n, err := net.ParseCIDR(r)
+			if err != nil {
+				return nil, nil, fmt.Errorf("outbound rule %q: %v", r, err)
+			}
+			nets = append(nets, n)
+			continue
+		}
+		hosts = append(hosts, r)
+	}
+	return hosts, nets, nil
+}
+
+// SetOutboundPolicy enables or disables the outbound policy and sets the
+// rules of the hosts always fetched and of the hosts never fetched in
+// addition to the default denied hosts. A rule is an IP address, a CIDR
+// network, a host name or a pattern such as *.example.com matching the
+// subdomains of a domain.
+func SetOutboundPolicy(enabled bool, allow, deny []string) error {
+	allowHosts, allowNets, err := parseOutboundRules(allow)
+	if err != nil {
+		return err
+	}
+	denyHosts, denyNets, err := parseOutboundRules(append(append([]string(nil), defaultDeniedNets...), deny...))
+	if err != nil {
+		return err
+	}
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+	outbound.enabled = enabled
+	outbound.allowHosts, outbound.allowNets = allowHosts, allowNets
+	outbound.denyHosts, outbound.denyNets = append(denyHosts, defaultDeniedHosts...), denyNets
+	return nil
+}
+
+// trustHost adds the host configured for a fetcher to the hosts always
+// fetched.
+func trustHost(host string) {
+	if host == "" {
+		return
+	}
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+	if outbound.trusted == nil {
+		outbound.trusted = make(map[string]bool)
+	}
+	outbound.trusted[strings.ToLower(host)] = true
+}
+
+// trustURLHost trusts the host of the URL.
+func trustURLHost(u string) {
+	if p, err := url.Parse(u); err == nil {
+		trustHost(p.Host)
+		trustHost(p.Hostname())
+	}
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if p == host || strings.HasPrefix(p, "*.") && strings.HasSuffix(host, p[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNet(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// outboundDenied returns the error for a host denied by the outbound policy.
+func outboundDenied(host string) error {
+	return NotFoundError{Message: "Fetching from " + host + " is not allowed."}
+}
+
+// outboundHost returns the host without the port, lower cased, and true if
+// the host is always fetched. Called with outbound.mu held.
+func outboundHost(host string) (string, bool) {
+	host = strings.ToLower(host)
+	if outbound.trusted[host] {
+		return host, true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	return host, outbound.trusted[host] || hostCredentials(host) != nil || matchHost(outbound.allowHosts, host)
+}
+
+// checkOutboundIP returns a NotFoundError if the outbound policy denies
+// connecting to the address of the host. Called with outbound.mu held.
+func checkOutboundIP(host string, ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if !matchNet(outbound.allowNets, ip) && matchNet(outbound.denyNets, ip) {
+		return outboundDenied(host)
+	}
+	return nil
+}
+
+// CheckOutboundHost returns a NotFoundError if the outbound policy denies
+// requests to the host and a RemoteError if the host cannot be resolved. The
+// host can have a port.
+func CheckOutboundHost(host string) error {
+	outbound.mu.RLock()
+	defer outbound.mu.RUnlock()
+	if !outbound.enabled {
+		return nil
+	}
+	host, allowed := outboundHost(host)
+	if allowed {
+		return nil
+	}
+	if matchHost(outbound.denyHosts, host) {
+		return outboundDenied(host)
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), outboundLookupTimeout)
+		addrs, err := lookupIPAddr(ctx, host)
+		cancel()
+		if err != nil {
+			// The addresses of the host are not known, so the host is
+			// denied. The error is not a NotFoundError because the lookup
+			// can fail temporarily.
+			return &RemoteError{host, err}
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		if err := checkOutboundIP(host, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outboundEnabled returns true if the outbound policy is enabled.
+func outboundEnabled() bool {
+	outbound.mu.RLock()
+	defer outbound.mu.RUnlock()
+	return outbound.enabled
+}
+
+// outboundAllowedByName returns true if the outbound policy is disabled or the
+// host is always fetched.
+func outboundAllowedByName(host string) bool {
+	outbound.mu.RLock()
+	defer outbound.mu.RUnlock()
+	_, allowed := outboundHost(host)
+	return !outbound.enabled || allowed
+}
+
+type outboundKey struct{}
+
+// WithOutboundPolicy returns a copy of ctx marking the connections dialed
+// with the context by the functions returned by OutboundDialContext to be
+// checked against the outbound policy.
+func WithOutboundPolicy(ctx context.Context) context.Context {
+	return context.WithValue(ctx, outboundKey{}, true)
+}
+
+// OutboundDialContext returns a dial function for http.Transport dialing with
+// d. The connections dialed with a context marked by WithOutboundPolicy are
+// refused if the outbound policy denies the host or the address connected
+// to. The address is checked after the host is resolved, so a host resolving
+// to a public address when the request is checked and to a private address
+// when connecting is denied.
+func OutboundDialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if marked, _ := ctx.Value(outboundKey{}).(bool); !marked {
+			return d.DialContext(ctx, network, addr)
+		}
+		outbound.mu.RLock()
+		host, allowed := outboundHost(addr)
+		allowed = allowed || !outbound.enabled
+		denied := !allowed && matchHost(outbound.denyHosts, host)
+		outbound.mu.RUnlock()
+		if denied {
+			return nil, outboundDenied(host)
+		}
+		if allowed {
+			return d.DialContext(ctx, network, addr)
+		}
+		dd := *d
+		dd.Control = nil
+		dd.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) error {
+			h, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(h)
+			if ip == nil {
+				return outboundDenied(host)
+			}
+			outbound.mu.RLock()
+			err = checkOutboundIP(host, ip)
+			outbound.mu.RUnlock()
+			if err != nil {
+				return err
+			}
+			if d.ControlContext != nil {
+				return d.ControlContext(ctx, network, address, c)
+			}
+			if d.Control != nil {
+				return d.Control(network, address, c)
+			}
+			return nil
+		}
+		return dd.DialContext(ctx, network, addr)
+	}
+}
+
+// outboundTransport is the transport of the clients without a transport.
+var outboundTransport http.RoundTripper = func() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = OutboundDialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	return t
+}()
+
+// checkOutboundURL returns a NotFoundError if the outbound policy denies
+// requests to the URL.
+func checkOutboundURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return NotFoundError{Message: "Fetching " + u.Scheme + " URLs is not allowed."}
+	}
+	return CheckOutboundHost(u.Host)
+}
+
+// outboundClient returns the client with the redirects checked against the
+// outbound policy. The requests must have a context marked by
+// WithOutboundPolicy for the addresses connected to to be checked.
+func outboundClient(c *http.Client) *http.Client {
+	outbound.mu.RLock()
+	enabled := outbound.enabled
+	outbound.mu.RUnlock()
+	if !enabled {
+		return c
+	}
+	oc := *c
+	if oc.Transport == nil {
+		oc.Transport = outboundTransport
+	}
+	checkRedirect := c.CheckRedirect
+	oc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := checkOutboundURL(req.URL); err != nil {
+			return err
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &oc
+}
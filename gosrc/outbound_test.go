@@ -0,0 +1,142 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func setTestOutboundPolicy(t *testing.T, allow, deny []string) {
+	t.Helper()
+	if err := SetOutboundPolicy(true, allow, deny); err != nil {
+		t.Fatal(err)
+	}
+	lookup := lookupIPAddr
+	t.Cleanup(func() { lookupIPAddr = lookup })
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		addrs := map[string]string{
+			"github.com":           "140.82.112.3",
+			"example.com":          "93.184.216.34",
+			"internal.example.com": "10.1.2.3",
+			"git.corp.example":     "10.0.0.5",
+			"rebind.example.com":   "::ffff:127.0.0.1",
+		}
+		if a, ok := addrs[host]; ok {
+			return []net.IPAddr{{IP: net.ParseIP(a)}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	// Other tests trust the hosts of the fetchers they configure.
+	trusted := outbound.trusted
+	outbound.trusted = nil
+	t.Cleanup(func() {
+		SetOutboundPolicy(false, nil, nil)
+		outbound.trusted = trusted
+	})
+}
+
+func TestCheckOutboundHost(t *testing.T) {
+	setTestOutboundPolicy(t, []string{"git.corp.example", "10.9.0.0/16"}, []string{"*.blocked.com", "93.184.216.0/24"})
+	trustHost("gitlab.corp.example:8443")
+
+	for _, tt := range []struct {
+		host string
+		ok   bool
+	}{
+		{"github.com", true},
+		{"example.com", false},
+		{"internal.example.com", false},
+		{"rebind.example.com", false},
+		{"git.corp.example", true},
+		{"gitlab.corp.example:8443", true},
+		{"10.9.1.1", true},
+		{"10.8.1.1", false},
+		{"127.0.0.1:80", false},
+		{"[::1]:443", false},
+		{"169.254.169.254", false},
+		{"metadata.google.internal", false},
+		{"LOCALHOST.", false},
+		{"a.blocked.com", false},
+		{"8.8.8.8", true},
+	} {
+		err := CheckOutboundHost(tt.host)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckOutboundHost(%q) = %v, want allowed %v", tt.host, err, tt.ok)
+		}
+		if err != nil && !IsNotFound(err) {
+			t.Errorf("CheckOutboundHost(%q) = %v, want NotFoundError", tt.host, err)
+		}
+	}
+
+	if _, ok := CheckOutboundHost("unresolved.example").(*RemoteError); !ok {
+		t.Errorf("CheckOutboundHost(unresolved host) = %v, want RemoteError", CheckOutboundHost("unresolved.example"))
+	}
+
+	SetOutboundPolicy(false, nil, nil)
+	if err := CheckOutboundHost("127.0.0.1"); err != nil {
+		t.Errorf("CheckOutboundHost(127.0.0.1) with the policy disabled = %v", err)
+	}
+}
+
+func TestOutboundRedirect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		http.Redirect(resp, req, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer ts.Close()
+	// The test server listens on a loopback address.
+	setTestOutboundPolicy(t, []string{"127.0.0.1"}, nil)
+
+	c := &httpClient{client: http.DefaultClient}
+	if _, err := c.get(ts.URL); err == nil {
+		t.Error("redirect to the metadata endpoint followed")
+	}
+	if _, err := c.get("file:///etc/passwd"); !IsNotFound(err) {
+		t.Errorf("get(file URL) = %v, want NotFoundError", err)
+	}
+}
+
+func TestSetOutboundPolicyBadRule(t *testing.T) {
+	if err := SetOutboundPolicy(true, []string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("SetOutboundPolicy(bad CIDR) returned no error")
+	}
+	SetOutboundPolicy(false, nil, nil)
+}
+
+func TestOutboundDialContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+	setTestOutboundPolicy(t, nil, nil)
+
+	c := &http.Client{Transport: &http.Transport{DialContext: OutboundDialContext(&net.Dialer{})}}
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		resp, err := c.Do(req.WithContext(ctx))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	if err := get(context.Background()); err != nil {
+		t.Errorf("get(unmarked context) = %v", err)
+	}
+	// The address connected to is checked, not the address the host
+	// resolved to when the request was checked.
+	c.Transport.(*http.Transport).CloseIdleConnections()
+	if err := get(WithOutboundPolicy(context.Background())); err == nil {
+		t.Error("connected to a loopback address")
+	}
+
+	SetOutboundPolicy(true, []string{"127.0.0.1"}, nil)
+	if err := get(WithOutboundPolicy(context.Background())); err != nil {
+		t.Errorf("get(allowed address) = %v", err)
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxModuleZipSize is the maximum size of a module zip file downloaded from
+// the module proxy.
+const maxModuleZipSize = 100 << 20
+
+var proxyURL string
+
+// SetModuleProxy sets the base URL of the Go module proxy used by Get, for
+// example "https://proxy.golang.org". When set, Get fetches packages in
+// modules from the proxy and falls back to the version control service for
+// packages that are not in a module known to the proxy. An empty URL
+// disables the proxy.
+func SetModuleProxy(u string) {
+	proxyURL = strings.TrimSuffix(u, "/")
+	trustURLHost(proxyURL)
+}
+
+// escapeModulePath escapes upper case letters in a module path or version as
+// required by the module proxy protocol.
+func escapeModulePath(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			buf.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+type moduleInfo struct {
+	Version string
+	Time    time.Time
+}
+
+// getProxy issues a GET for a module proxy resource such as "@v/list". A nil
+// response indicates that the proxy does not have the resource.
+func getProxy(c *httpClient, module, resource string) (*http.Response, error) {
+	resp, err := c.get(proxyURL + "/" + escapeModulePath(module) + "/" + resource)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, nil
+	}
+	resp.Body.Close()
+	return nil, &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: (%s)", resp.StatusCode, resp.Request.URL.String())}
+}
+
+// getModuleVersion returns the latest version of module and the versions in
+// the version list of the module, newest first. An empty version is returned
+// if the proxy does not know the module.
+func getModuleVersion(c *httpClient, module string) (string, []string, error) {
+	resp, err := getProxy(c, module, "@v/list")
+	if err != nil || resp == nil {
+		return "", nil, err
+	}
+	p, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", nil, &RemoteError{resp.Request.URL.Host, err}
+	}
+	versions := sortVersions(strings.Fields(string(p)))
+	if version := latestVersion(versions); version != "" {
+		return version, versions, nil
+	}
+
+	// The module has no tagged versions. Use the pseudo-version of the latest
+	// commit.
+	resp, err = getProxy(c, module, "@latest")
+	if err != nil || resp == nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	var info moduleInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", nil, &RemoteError{resp.Request.URL.Host, err}
+	}
+	return info.Version, versions, nil
+}
+
+// getProxyDir gets a directory from the module proxy. getProxyDir returns
+// errNoMatch if the import path is not in a module known to the proxy.
+func getProxyDir(client *http.Client, importPath string, savedEtag string) (*Directory, error) {
+	c := &httpClient{client: client}
+
+	var module, version string
+	var versions []string
+	for module = importPath; ; module = path.Dir(module) {
+		var err error
+		version, versions, err = getModuleVersion(c, module)
+		if err != nil {
+			return nil, err
+		}
+		if version != "" {
+			break
+		}
+		if !strings.Contains(module, "/") {
+			return nil, errNoMatch
+		}
+	}
+
+	etag := "mod-" + version
+	if etag == savedEtag {
+		return nil, ErrNotModified
+	}
+	dir, err := getModuleDir(c, importPath, module, version)
+	if err != nil {
+		return nil, err
+	}
+	dir.ModuleVersions = versions
+	return dir, nil
+}
+
+// GetModuleVersion gets a directory at a version of the module from the
+// module proxy. GetModuleVersion also returns the time of the version. The
+// version must be a semantic version known to the proxy.
+func GetModuleVersion(client *http.Client, importPath, version string) (*Directory, time.Time, error) {
+	if proxyURL == "" {
+		return nil, time.Time{}, NotFoundError{Message: "Module proxy not configured."}
+	}
+	if !IsValidRemotePath(importPath) || inSkippedDir(importPath) {
+		return nil, time.Time{}, NotFoundError{Message: "Import path not valid:"}
+	}
+	if _, ok := parseSemver(version); !ok {
+		return nil, time.Time{}, NotFoundError{Message: "Invalid module version."}
+	}
+	c := &httpClient{client: client}
+
+	var info moduleInfo
+	module := importPath
+	for ; ; module = path.Dir(module) {
+		resp, err := getProxy(c, module, "@v/"+escapeModulePath(version)+".info")
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if resp != nil {
+			err := json.NewDecoder(resp.Body).Decode(&info)
+			resp.Body.Close()
+			if err != nil {
+				return nil, time.Time{}, &RemoteError{resp.Request.URL.Host, err}
+			}
+			break
+		}
+		if !strings.Contains(module, "/") {
+			return nil, time.Time{}, NotFoundError{Message: "Module version not found."}
+		}
+	}
+	if info.Version != version {
+		return nil, time.Time{}, NotFoundError{Message: "Module version not found."}
+	}
+
+	dir, err := getModuleDir(c, importPath, module, version)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	_, dir.ModuleVersions, err = getModuleVersion(c, module)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if err := applyFetchLimits(dir); err != nil {
+		return nil, time.Time{}, err
+	}
+	setNestedModule(dir)
+	setOptOut(dir)
+	setCanonical(dir)
+	if err := scanDirectory(dir); err != nil {
+		return nil, time.Time{}, err
+	}
+	return dir, info.Time, nil
+}
+
+// getModuleDir gets the directory of the import path from the zip of the
+// module version.
+func getModuleDir(c *httpClient, importPath, module, version string) (*Directory, error) {
+	resp, err := getProxy(c, module, "@v/"+escapeModulePath(version)+".zip")
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, NotFoundError{Message: "Module zip not found."}
+	}
+	p, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxModuleZipSize+1))
+	resp.Body.Close()
+	if err != nil {
+		return nil, &RemoteError{resp.Request.URL.Host, err}
+	}
+	if len(p) > maxModuleZipSize {
+		return nil, NotFoundError{Message: "Module zip too large."}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(p), int64(len(p)))
+	if err != nil {
+		return nil, &RemoteError{resp.Request.URL.Host, err}
+	}
+	verified, err := verifyModuleZip(c, module, version, zr)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := module + "@" + version + "/"
+	if dir := importPath[len(module):]; dir != "" {
+		prefix += dir[1:] + "/"
+	}
+
+	var files []*File
+	subdirs := make(map[string]bool)
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		name := f.Name[len(prefix):]
+		if i := strings.Index(name, "/"); i >= 0 {
+			if isValidPathElement(name[:i]) {
+				subdirs[name[:i]] = true
+			}
+			continue
+		}
+		if !isDocFile(name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readFileData(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &File{Name: name, Data: data})
+	}
+
+	if len(files) == 0 && len(subdirs) == 0 {
+		return nil, NotFoundError{Message: "Directory not found in module."}
+	}
+
+	var dirs []string
+	for d := range subdirs {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+
+	return &Directory{
+		Etag:           "mod-" + version,
+		Version:        version,
+		Files:          files,
+		ImportPath:     importPath,
+		ProjectName:    path.Base(module),
+		ProjectRoot:    module,
+		ProjectURL:     "https://" + module,
+		ResolvedPath:   importPath,
+		Subdirectories: dirs,
+		VCS:            "mod",
+		Verified:       verified,
+	}, nil
+}
+
+// sortVersions returns the semantic versions in versions, newest first.
+func sortVersions(versions []string) []string {
+	var sorted []string
+	svs := make(map[string]semver)
+	for _, v := range versions {
+		if sv, ok := parseSemver(v); ok {
+			if _, dup := svs[v]; !dup {
+				sorted = append(sorted, v)
+			}
+			svs[v] = sv
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return svs[sorted[j]].less(svs[sorted[i]]) })
+	return sorted
+}
+
+// latestVersion returns the greatest semantic version in versions. Release
+// versions are preferred over pre-release versions.
+func latestVersion(versions []string) string {
+	best, bestPre := "", ""
+	var bestV, bestPreV semver
+	for _, v := range versions {
+		sv, ok := parseSemver(v)
+		switch {
+		case !ok:
+		case sv.pre != "":
+			if bestPre == "" || bestPreV.less(sv) {
+				bestPre, bestPreV = v, sv
+			}
+		case best == "" || bestV.less(sv):
+			best, bestV = v, sv
+		}
+	}
+	if best == "" {
+		return bestPre
+	}
+	return best
+}
+
+type semver struct {
+	n   [3]int
+	pre string
+}
+
+func parseSemver(v string) (semver, bool) {
+	var sv semver
+	if !strings.HasPrefix(v, "v") {
+		return sv, false
+	}
+	v = v[1:]
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		v, sv.pre = v[:i], v[i+1:]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return sv, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return sv, false
+		}
+		sv.n[i] = n
+	}
+	return sv, true
+}
+
+func (a semver) less(b semver) bool {
+	for i := range a.n {
+		if a.n[i] != b.n[i] {
+			return a.n[i] < b.n[i]
+		}
+	}
+	switch {
+	case a.pre == b.pre:
+		return false
+	case a.pre == "":
+		return false
+	case b.pre == "":
+		return true
+	}
+	return comparePrerelease(a.pre, b.pre) < 0
+}
+
+// comparePrerelease compares dot separated pre-release identifiers as
+// specified by semantic versioning.
+func comparePrerelease(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,141 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func moduleZip(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestGetProxyDir(t *testing.T) {
+	defer SetModuleProxy("")
+	SetModuleProxy("https://proxy.example.com/")
+
+	proxyWeb := map[string]string{
+		"https://proxy.example.com/example.com/!alice/mod/@v/list": "v1.0.0\nv1.2.0-rc.1\nv1.1.0\n",
+		"https://proxy.example.com/example.com/!alice/mod/@v/v1.1.0.zip": moduleZip(t, map[string]string{
+			"example.com/Alice/mod@v1.1.0/go.mod":        "module example.com/Alice/mod",
+			"example.com/Alice/mod@v1.1.0/mod.go":        "package mod",
+			"example.com/Alice/mod@v1.1.0/sub/sub.go":    "package sub",
+			"example.com/Alice/mod@v1.1.0/sub/x/x.go":    "package x",
+			"example.com/Alice/mod@v1.1.0/sub/README.md": "sub",
+		}),
+		"https://proxy.example.com/example.com/bob/@v/list": "",
+		"https://proxy.example.com/example.com/bob/@latest": `{"Version": "v0.0.0-20170101000000-abcdefabcdef"}`,
+	}
+	client := &http.Client{Transport: testTransport(proxyWeb)}
+
+	dir, err := getProxyDir(client, "example.com/Alice/mod/sub", "")
+	if err != nil {
+		t.Fatalf("getProxyDir returned error %v", err)
+	}
+	if dir.ProjectRoot != "example.com/Alice/mod" || dir.Version != "v1.1.0" || dir.Etag != "mod-v1.1.0" {
+		t.Errorf("dir = %+v, want project root example.com/Alice/mod, version v1.1.0", dir)
+	}
+	if want := []string{"v1.2.0-rc.1", "v1.1.0", "v1.0.0"}; !reflect.DeepEqual(dir.ModuleVersions, want) {
+		t.Errorf("dir.ModuleVersions = %v, want %v", dir.ModuleVersions, want)
+	}
+	if !reflect.DeepEqual(dir.Subdirectories, []string{"x"}) {
+		t.Errorf("dir.Subdirectories = %v, want [x]", dir.Subdirectories)
+	}
+	names := make(map[string]string)
+	for _, f := range dir.Files {
+		names[f.Name] = string(f.Data)
+	}
+	if !reflect.DeepEqual(names, map[string]string{"sub.go": "package sub", "README.md": "sub"}) {
+		t.Errorf("dir.Files = %v, want sub.go and README.md", names)
+	}
+
+	if _, err := getProxyDir(client, "example.com/Alice/mod", "mod-v1.1.0"); err != ErrNotModified {
+		t.Errorf("getProxyDir with saved etag returned %v, want ErrNotModified", err)
+	}
+
+	if _, err := getProxyDir(client, "example.com/bob/pkg", "mod-v0.0.0-20170101000000-abcdefabcdef"); err != ErrNotModified {
+		t.Errorf("getProxyDir for untagged module returned %v, want ErrNotModified", err)
+	}
+
+	if _, err := getProxyDir(client, "example.com/carol/pkg", ""); err != errNoMatch {
+		t.Errorf("getProxyDir for unknown module returned %v, want errNoMatch", err)
+	}
+}
+
+func TestGetModuleVersion(t *testing.T) {
+	defer SetModuleProxy("")
+	SetModuleProxy("https://proxy.example.com")
+
+	proxyWeb := map[string]string{
+		"https://proxy.example.com/example.com/mod/@v/v1.0.0.info": `{"Version": "v1.0.0", "Time": "2020-01-02T03:04:05Z"}`,
+		"https://proxy.example.com/example.com/mod/@v/list":        "v1.0.0\nv1.1.0\n",
+		"https://proxy.example.com/example.com/mod/@v/v1.0.0.zip": moduleZip(t, map[string]string{
+			"example.com/mod@v1.0.0/go.mod":     "module example.com/mod",
+			"example.com/mod@v1.0.0/sub/sub.go": "package sub",
+		}),
+	}
+	client := &http.Client{Transport: testTransport(proxyWeb)}
+
+	dir, tm, err := GetModuleVersion(client, "example.com/mod/sub", "v1.0.0")
+	if err != nil {
+		t.Fatalf("GetModuleVersion returned error %v", err)
+	}
+	if dir.ProjectRoot != "example.com/mod" || dir.Version != "v1.0.0" || tm.Year() != 2020 {
+		t.Errorf("dir = %+v, time %v, want project root example.com/mod, version v1.0.0", dir, tm)
+	}
+	if want := []string{"v1.1.0", "v1.0.0"}; !reflect.DeepEqual(dir.ModuleVersions, want) {
+		t.Errorf("dir.ModuleVersions = %v, want %v", dir.ModuleVersions, want)
+	}
+
+	for _, version := range []string{"master", "v2.0.0"} {
+		if _, _, err := GetModuleVersion(client, "example.com/mod/sub", version); !IsNotFound(err) {
+			t.Errorf("GetModuleVersion(%s) returned %v, want not found", version, err)
+		}
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	got := sortVersions([]string{"v1.0.0", "bad", "v1.10.0", "v1.2.0", "v1.10.0-rc.1", "v1.2.0"})
+	if want := []string{"v1.10.0", "v1.10.0-rc.1", "v1.2.0", "v1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sortVersions() = %v, want %v", got, want)
+	}
+}
+
+var latestVersionTests = []struct {
+	versions []string
+	latest   string
+}{
+	{nil, ""},
+	{[]string{"v1.0.0", "v1.10.0", "v1.9.0"}, "v1.10.0"},
+	{[]string{"v2.0.0-beta.2", "v1.0.0"}, "v1.0.0"},
+	{[]string{"v2.0.0-beta.2", "v2.0.0-beta.10", "v2.0.0-alpha"}, "v2.0.0-beta.10"},
+	{[]string{"v2.0.0+incompatible", "v1.5.0", "bad"}, "v2.0.0+incompatible"},
+}
+
+func TestLatestVersion(t *testing.T) {
+	for _, tt := range latestVersionTests {
+		if latest := latestVersion(tt.versions); latest != tt.latest {
+			t.Errorf("latestVersion(%v) = %q, want %q", tt.versions, latest, tt.latest)
+		}
+	}
+}
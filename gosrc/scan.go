@@ -0,0 +1,63 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+// Scanner checks fetched files for malicious content.
+type Scanner interface {
+	// Scan returns a description of the threat found in the file or "" if
+	// the file is clean. An error is returned if the file cannot be scanned.
+	Scan(name string, data []byte) (threat string, err error)
+}
+
+var scanner Scanner
+
+// SetScanner sets the scanner applied to the files of every directory
+// returned by Get. A nil scanner disables scanning.
+func SetScanner(s Scanner) {
+	scanner = s
+}
+
+// QuarantineError indicates that the scanner found a threat in the files of a
+// project.
+type QuarantineError struct {
+	// Import path prefix for all packages in the flagged project.
+	ProjectRoot string
+
+	// Diagnostic message describing the threat.
+	Message string
+}
+
+func (e QuarantineError) Error() string {
+	return e.ProjectRoot + ": " + e.Message
+}
+
+// IsQuarantined returns true if err is a QuarantineError.
+func IsQuarantined(err error) bool {
+	_, ok := err.(QuarantineError)
+	return ok
+}
+
+// scanDirectory applies the scanner to the files of dir.
+func scanDirectory(dir *Directory) error {
+	if scanner == nil {
+		return nil
+	}
+	for _, f := range dir.Files {
+		threat, err := scanner.Scan(f.Name, f.Data)
+		if err != nil {
+			return &RemoteError{"scanner", err}
+		}
+		if threat != "" {
+			root := dir.ProjectRoot
+			if root == "" {
+				root = dir.ImportPath
+			}
+			return QuarantineError{ProjectRoot: root, Message: f.Name + ": " + threat}
+		}
+	}
+	return nil
+}
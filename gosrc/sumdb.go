@@ -0,0 +1,127 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var checksumDBURL string
+
+// errChecksumMismatch is the error for module zips with a hash different
+// from the hash in the checksum database.
+var errChecksumMismatch = NotFoundError{Message: "Module zip does not match the checksum database."}
+
+// SetChecksumDB sets the base URL of the Go checksum database the module
+// zips fetched from the module proxy are verified against, for example
+// "https://sum.golang.org". Modules with zips that do not match the checksum
+// database are not found. Modules unknown to the checksum database, such as
+// private modules, are not verified. The checksum database is trusted over
+// HTTPS: the signed tree of the database is not checked. An empty URL
+// disables the verification.
+func SetChecksumDB(u string) {
+	checksumDBURL = strings.TrimSuffix(u, "/")
+	trustURLHost(checksumDBURL)
+}
+
+// hashZip returns the h1: hash of the files in a module zip, the hash of the
+// go.sum file and of the checksum database.
+func hashZip(zr *zip.Reader) (string, error) {
+	var names []string
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		if strings.Contains(f.Name, "\n") {
+			return "", errors.New("file name with a newline in module zip")
+		}
+		names = append(names, f.Name)
+		files[f.Name] = f
+	}
+	sort.Strings(names)
+	summary := sha256.New()
+	for _, name := range names {
+		rc, err := files[name].Open()
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// lookupChecksum returns the hash of the module version in the checksum
+// database or "" if the module is not in the checksum database.
+func lookupChecksum(c *httpClient, module, version string) (string, error) {
+	resp, err := c.get(checksumDBURL + "/lookup/" + escapeModulePath(module) + "@" + escapeModulePath(version))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return "", nil
+	default:
+		return "", &RemoteError{resp.Request.URL.Host, fmt.Errorf("%d: (%s)", resp.StatusCode, resp.Request.URL.String())}
+	}
+
+	// The records of the module version, "module version hash" lines for
+	// the zip and the go.mod file, end at the first blank line.
+	s := bufio.NewScanner(io.LimitReader(resp.Body, 64<<10))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			break
+		}
+		if len(fields) == 3 && fields[0] == module && fields[1] == version {
+			return fields[2], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", &RemoteError{resp.Request.URL.Host, err}
+	}
+	return "", nil
+}
+
+// verifyModuleZip returns true if the module zip matches the checksum
+// database, false if the module is not in the checksum database or the
+// verification is disabled, and errChecksumMismatch if the zip does not
+// match.
+func verifyModuleZip(c *httpClient, module, version string, zr *zip.Reader) (bool, error) {
+	if checksumDBURL == "" {
+		return false, nil
+	}
+	want, err := lookupChecksum(c, module, version)
+	if err != nil || want == "" {
+		return false, err
+	}
+	got, err := hashZip(zr)
+	if err != nil {
+		return false, err
+	}
+	if got != want {
+		return false, errChecksumMismatch
+	}
+	return true, nil
+}
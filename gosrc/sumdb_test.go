@@ -0,0 +1,66 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package gosrc
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+const testModuleHash = "h1:fCHMqo5ggHEQvwcrsN81zr5orRk5lClR36KRHpfUjKg="
+
+func testModuleZip(t *testing.T, files map[string]string) *zip.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestVerifyModuleZip(t *testing.T) {
+	defer SetChecksumDB("")
+	SetChecksumDB("https://sum.example.com/")
+
+	c := &httpClient{client: &http.Client{Transport: testTransport{
+		"https://sum.example.com/lookup/example.com/m@v1.0.0": "1\nexample.com/m v1.0.0 " + testModuleHash + "\nexample.com/m v1.0.0/go.mod h1:x\n\ngo.sum database tree\n",
+	}}}
+	zr := testModuleZip(t, map[string]string{
+		"example.com/m@v1.0.0/m.go":   "package m\n",
+		"example.com/m@v1.0.0/go.mod": "module example.com/m\n",
+	})
+	if h, err := hashZip(zr); err != nil || h != testModuleHash {
+		t.Errorf("hashZip() = %q, %v, want %q", h, err, testModuleHash)
+	}
+	if ok, err := verifyModuleZip(c, "example.com/m", "v1.0.0", zr); !ok || err != nil {
+		t.Errorf("verifyModuleZip() = %v, %v, want verified", ok, err)
+	}
+
+	changed := testModuleZip(t, map[string]string{
+		"example.com/m@v1.0.0/m.go":   "package m // changed\n",
+		"example.com/m@v1.0.0/go.mod": "module example.com/m\n",
+	})
+	if _, err := verifyModuleZip(c, "example.com/m", "v1.0.0", changed); err != errChecksumMismatch {
+		t.Errorf("verifyModuleZip(changed) returned %v, want %v", err, errChecksumMismatch)
+	}
+	if ok, err := verifyModuleZip(c, "example.com/private", "v1.0.0", zr); ok || err != nil {
+		t.Errorf("verifyModuleZip(unknown module) = %v, %v, want not verified", ok, err)
+	}
+}
@@ -7,7 +7,9 @@
 package gosrc
 
 import (
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,6 +26,22 @@ func bestTag(tags map[string]string, defaultTag string) (string, string, error)
 	return "", "", NotFoundError{Message: "Tag or branch not found."}
 }
 
+// releaseTag returns the greatest tag name in tags that refers to commit or ""
+// if there is no such tag.
+func releaseTag(tags map[string]string, commit string) string {
+	var names []string
+	for name, c := range tags {
+		if c == commit {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[len(names)-1]
+}
+
 // expand replaces {k} in template with match[k] or subs[atoi(k)] if k is not in match.
 func expand(template string, match map[string]string, subs ...string) string {
 	var p []byte
@@ -50,13 +68,46 @@ func expand(template string, match map[string]string, subs ...string) string {
 
 var readmePat = regexp.MustCompile(`(?i)^readme(?:$|\.)`)
 
+var licensePat = regexp.MustCompile(`(?i)^(?:licen[cs]e|copying|unlicense)(?:$|[.\-_])`)
+
+// IsLicenseFile returns true if the file with the name is a license file,
+// such as LICENSE, LICENSE.md or COPYING.
+func IsLicenseFile(name string) bool {
+	return licensePat.MatchString(name)
+}
+
+// IsTalkFile returns true if the file with the name is a talk in the present
+// format, a .slide or .article file.
+func IsTalkFile(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".slide" || ext == ".article"
+}
+
+// OwnerFile is the name of the file in the root of a project that holds the
+// hash of the project owner's key.
+const OwnerFile = ".godoc-owner"
+
+// OwnerMeta is the name of the meta tag in the go-import page of a project
+// root that holds the hash of the project owner's key in the same format as
+// an OwnerFile. The meta tag verifies the owner of projects with a custom
+// import path.
+const OwnerMeta = "godoc-owner"
+
+// IgnoreFile is the name of the file that marks a directory, or all
+// directories in a project if the file is in the project root, as opted out
+// of indexing.
+const IgnoreFile = ".godoc-ignore"
+
 // isDocFile returns true if a file with name n should be included in the
 // documentation.
 func isDocFile(n string) bool {
 	if strings.HasSuffix(n, ".go") && n[0] != '_' && n[0] != '.' {
 		return true
 	}
-	return readmePat.MatchString(n)
+	if n == OwnerFile || n == IgnoreFile || n == goModFile {
+		return true
+	}
+	return readmePat.MatchString(n) || licensePat.MatchString(n) || IsTalkFile(n)
 }
 
 var linePat = regexp.MustCompile(`(?m)^//line .*$`)
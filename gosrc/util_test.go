@@ -29,3 +29,22 @@ func TestOverwriteLineComments(t *testing.T) {
 		}
 	}
 }
+
+var releaseTagTests = []struct {
+	tags   map[string]string
+	commit string
+	out    string
+}{
+	{nil, "abc", ""},
+	{map[string]string{"v1.0": "def"}, "abc", ""},
+	{map[string]string{"v1.0": "abc", "v1.1": "def"}, "abc", "v1.0"},
+	{map[string]string{"v1.0": "abc", "v1.0.1": "abc"}, "abc", "v1.0.1"},
+}
+
+func TestReleaseTag(t *testing.T) {
+	for _, tt := range releaseTagTests {
+		if out := releaseTag(tt.tags, tt.commit); out != tt.out {
+			t.Errorf("releaseTag(%v, %q) = %q, want %q", tt.tags, tt.commit, out, tt.out)
+		}
+	}
+}
@@ -11,6 +11,7 @@ package gosrc
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -76,6 +77,48 @@ var vcsServices = []*urlTemplates{
 	},
 }
 
+// sourceHostTemplates are the templates for source browsers that are
+// installed on self-hosted servers. The templates are expanded with the base
+// URL of the installation as {base}.
+var sourceHostTemplates = map[string]*urlTemplates{
+	"cgit": {
+		fileBrowse: "{base}/{repo}/tree/{dir}{0}?h={tag}",
+		project:    "{base}/{repo}",
+		line:       "%s#n%d",
+	},
+	"gitweb": {
+		fileBrowse: "{base}?p={repo}.git;a=blob;f={dir}{0};hb={tag}",
+		project:    "{base}?p={repo}.git;a=summary",
+		line:       "%s#l%d",
+	},
+	"sourcegraph": {
+		fileBrowse: "{base}/{host}/{repo}@{tag}/-/blob/{dir}{0}",
+		project:    "{base}/{host}/{repo}",
+		line:       "%s#L%d",
+	},
+}
+
+// AddSourceHost sets the source links for repositories on host to a source
+// browser at baseURL. The kind of source browser is cgit, gitweb or
+// sourcegraph.
+func AddSourceHost(host, kind, baseURL string) error {
+	t := sourceHostTemplates[kind]
+	if t == nil {
+		return fmt.Errorf("unknown source browser %q", kind)
+	}
+	if !isHTTPURL(baseURL) {
+		return fmt.Errorf("source browser URL %q is not an HTTP URL", baseURL)
+	}
+	r := strings.NewReplacer("{base}", strings.TrimSuffix(baseURL, "/"), "{host}", host)
+	vcsServices = append([]*urlTemplates{{
+		re:         regexp.MustCompile(`^` + regexp.QuoteMeta(host) + `/(?P<repo>.+)$`),
+		fileBrowse: r.Replace(t.fileBrowse),
+		project:    r.Replace(t.project),
+		line:       t.line,
+	}}, vcsServices...)
+	return nil
+}
+
 // lookupURLTemplate finds an expand() template, match map and line number
 // format for well known repositories.
 func lookupURLTemplate(repo, dir, tag string) (*urlTemplates, map[string]string) {
@@ -109,19 +152,82 @@ var vcsCmds = map[string]*vcsCmd{
 		schemes:  []string{"http", "https", "ssh", "git"},
 		download: downloadGit,
 	},
+	"hg": {
+		schemes:  []string{"https", "http", "ssh"},
+		download: downloadHg,
+	},
 	"svn": {
 		schemes:  []string{"http", "https", "svn"},
 		download: downloadSVN,
 	},
 }
 
+// enabledVCS is the set of VCS commands that can be run.
+var enabledVCS = map[string]bool{"git": true, "svn": true}
+
+// SetVCSCommands sets the version control commands run to fetch repositories
+// that are not hosted on a known service. Supported commands are git, hg and
+// svn. The default is git and svn.
+func SetVCSCommands(names []string) error {
+	m := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if vcsCmds[name] == nil {
+			return errors.New("VCS not supported: " + name)
+		}
+		m[name] = true
+	}
+	enabledVCS = m
+	return nil
+}
+
+// sshKey returns the SSH key configured for the host of repo or "".
+func sshKey(repo string) string {
+	host := repo
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	if cred := hostCredentials(host); cred != nil {
+		return cred.SSHKey
+	}
+	return ""
+}
+
+// gitCommand returns a git command for repo. The command uses the SSH key
+// configured for the repository host, if any.
+func gitCommand(repo string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	if key := sshKey(repo); key != "" {
+		cmd.Env = append(os.Environ(), "GIT_SSH_COMMAND=ssh -i "+key+" -o IdentitiesOnly=yes -o BatchMode=yes")
+	}
+	return cmd
+}
+
+// gitRemote returns the URL of the remote repository.
+func gitRemote(scheme, repo string) string {
+	if scheme == "ssh" && sshKey(repo) != "" {
+		return "ssh://git@" + repo + ".git"
+	}
+	return scheme + "://" + repo + ".git"
+}
+
 var lsremoteRe = regexp.MustCompile(`(?m)^([0-9a-f]{40})\s+refs/(?:tags|heads)/(.+)$`)
 
 func downloadGit(schemes []string, repo, savedEtag string) (string, string, error) {
 	var p []byte
 	var scheme string
+	if sshKey(repo) != "" {
+		// Private repositories are accessed with the configured key.
+		schemes = []string{"ssh"}
+	}
 	for i := range schemes {
-		cmd := exec.Command("git", "ls-remote", "--heads", "--tags", schemes[i]+"://"+repo+".git")
+		cmd := gitCommand(repo, "ls-remote", "--heads", "--tags", gitRemote(schemes[i], repo))
 		log.Println(strings.Join(cmd.Args, " "))
 		var err error
 		p, err = outputWithTimeout(cmd, lsRemoteTimeout)
@@ -158,7 +264,7 @@ func downloadGit(schemes []string, repo, savedEtag string) (string, string, erro
 		if err := os.MkdirAll(dir, 0777); err != nil {
 			return "", "", err
 		}
-		cmd := exec.Command("git", "clone", scheme+"://"+repo+".git", dir)
+		cmd := gitCommand(repo, "clone", "--depth", "1", "--branch", tag, gitRemote(scheme, repo), dir)
 		log.Println(strings.Join(cmd.Args, " "))
 		if err := runWithTimeout(cmd, cloneTimeout); err != nil {
 			return "", "", err
@@ -166,7 +272,7 @@ func downloadGit(schemes []string, repo, savedEtag string) (string, string, erro
 	case string(bytes.TrimRight(p, "\n")) == commit:
 		return tag, etag, nil
 	default:
-		cmd := exec.Command("git", "fetch")
+		cmd := gitCommand(repo, "fetch", "--depth", "1", "origin", tag)
 		log.Println(strings.Join(cmd.Args, " "))
 		cmd.Dir = dir
 		if err := runWithTimeout(cmd, fetchTimeout); err != nil {
@@ -183,6 +289,67 @@ func downloadGit(schemes []string, repo, savedEtag string) (string, string, erro
 	return tag, etag, nil
 }
 
+var hgTags = []string{"go1", "default"}
+
+func downloadHg(schemes []string, repo, savedEtag string) (string, string, error) {
+	var scheme, tag, commit string
+schemeLoop:
+	for i := range schemes {
+		for _, t := range hgTags {
+			cmd := exec.Command("hg", "identify", "--debug", "--id", "-r", t, schemes[i]+"://"+repo)
+			log.Println(strings.Join(cmd.Args, " "))
+			p, err := outputWithTimeout(cmd, lsRemoteTimeout)
+			if err == nil {
+				scheme, tag, commit = schemes[i], t, strings.TrimSpace(string(p))
+				break schemeLoop
+			}
+		}
+	}
+
+	if scheme == "" {
+		return "", "", NotFoundError{Message: "VCS not found"}
+	}
+
+	etag := scheme + "-" + commit
+	if etag == savedEtag {
+		return "", "", ErrNotModified
+	}
+
+	dir := filepath.Join(TempDir, repo+".hg")
+	cmd := exec.Command("hg", "identify", "--debug", "--id")
+	cmd.Dir = dir
+	p, err := outputWithTimeout(cmd, lsRemoteTimeout)
+	switch {
+	case err != nil:
+		if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+			return "", "", err
+		}
+		os.RemoveAll(dir)
+		cmd := exec.Command("hg", "clone", "--noupdate", scheme+"://"+repo, dir)
+		log.Println(strings.Join(cmd.Args, " "))
+		if err := runWithTimeout(cmd, cloneTimeout); err != nil {
+			return "", "", err
+		}
+	case strings.TrimSpace(string(p)) == commit:
+		return tag, etag, nil
+	default:
+		cmd := exec.Command("hg", "pull")
+		log.Println(strings.Join(cmd.Args, " "))
+		cmd.Dir = dir
+		if err := runWithTimeout(cmd, fetchTimeout); err != nil {
+			return "", "", err
+		}
+	}
+
+	cmd = exec.Command("hg", "update", "--clean", "-r", commit)
+	cmd.Dir = dir
+	if err := runWithTimeout(cmd, checkoutTimeout); err != nil {
+		return "", "", err
+	}
+
+	return tag, etag, nil
+}
+
 func downloadSVN(schemes []string, repo, savedEtag string) (string, string, error) {
 	var scheme string
 	var revno string
@@ -212,13 +379,19 @@ func downloadSVN(schemes []string, repo, savedEtag string) (string, string, erro
 		if err := os.MkdirAll(dir, 0777); err != nil {
 			return "", "", err
 		}
-		cmd := exec.Command("svn", "checkout", scheme+"://"+repo, "-r", revno, dir)
+		cmd, err := svnCommand("checkout", scheme+"://"+repo, "-r", revno, dir)
+		if err != nil {
+			return "", "", err
+		}
 		log.Println(strings.Join(cmd.Args, " "))
 		if err := runWithTimeout(cmd, cloneTimeout); err != nil {
 			return "", "", err
 		}
 	case localRevno != revno:
-		cmd := exec.Command("svn", "update", "-r", revno)
+		cmd, err := svnCommand("update", "-r", revno)
+		if err != nil {
+			return "", "", err
+		}
 		log.Println(strings.Join(cmd.Args, " "))
 		cmd.Dir = dir
 		if err := runWithTimeout(cmd, fetchTimeout); err != nil {
@@ -232,7 +405,10 @@ func downloadSVN(schemes []string, repo, savedEtag string) (string, string, erro
 var svnrevRe = regexp.MustCompile(`(?m)^Last Changed Rev: ([0-9]+)$`)
 
 func getSVNRevision(target string) (string, error) {
-	cmd := exec.Command("svn", "info", target)
+	cmd, err := svnCommand("info", target)
+	if err != nil {
+		return "", err
+	}
 	log.Println(strings.Join(cmd.Args, " "))
 	out, err := outputWithTimeout(cmd, lsRemoteTimeout)
 	if err != nil {
@@ -247,7 +423,7 @@ func getSVNRevision(target string) (string, error) {
 
 func getVCSDir(client *http.Client, match map[string]string, etagSaved string) (*Directory, error) {
 	cmd := vcsCmds[match["vcs"]]
-	if cmd == nil {
+	if cmd == nil || !enabledVCS[match["vcs"]] {
 		return nil, NotFoundError{Message: expand("VCS not supported: {vcs}", match)}
 	}
 
@@ -259,11 +435,22 @@ func getVCSDir(client *http.Client, match map[string]string, etagSaved string) (
 		}
 	}
 
-	schemes := cmd.schemes
+	host := match["repo"]
+	if i := strings.IndexAny(host, "/:"); i >= 0 {
+		host = host[:i]
+	}
+	if err := CheckOutboundHost(host); err != nil {
+		return nil, err
+	}
+
+	schemes := outboundSchemes(host, cmd.schemes)
+	if len(schemes) == 0 {
+		return nil, NotFoundError{Message: expand("Fetching from {repo} with {vcs} is not allowed.", match)}
+	}
 	if scheme != "" {
-		for i := range cmd.schemes {
-			if cmd.schemes[i] == scheme {
-				schemes = cmd.schemes[i : i+1]
+		for i := range schemes {
+			if schemes[i] == scheme {
+				schemes = schemes[i : i+1]
 				break
 			}
 		}
@@ -303,7 +490,7 @@ func getVCSDir(client *http.Client, match map[string]string, etagSaved string) (
 			if isValidPathElement(fi.Name()) {
 				subdirs = append(subdirs, fi.Name())
 			}
-		case isDocFile(fi.Name()):
+		case isDocFile(fi.Name()) && (maxFileSize <= 0 || fi.Size() <= maxFileSize):
 			b, err := ioutil.ReadFile(path.Join(d, fi.Name()))
 			if err != nil {
 				return nil, err
@@ -316,6 +503,17 @@ func getVCSDir(client *http.Client, match map[string]string, etagSaved string) (
 		}
 	}
 
+	parentGoMods, err := getParentGoMods(expand("{repo}.{vcs}", match), match["dir"], func(dir string) ([]byte, error) {
+		b, err := ioutil.ReadFile(path.Join(TempDir, expand("{repo}.{vcs}", match), dir, goModFile))
+		if os.IsNotExist(err) {
+			err = NotFoundError{Message: err.Error()}
+		}
+		return b, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &Directory{
 		LineFmt:        template.line,
 		ProjectRoot:    expand("{repo}.{vcs}", match),
@@ -326,10 +524,17 @@ func getVCSDir(client *http.Client, match map[string]string, etagSaved string) (
 		VCS:            match["vcs"],
 		Subdirectories: subdirs,
 		Files:          files,
+		Private:        match["vcs"] == "git" && sshKey(match["repo"]) != "",
+		parentGoMods:   parentGoMods,
 	}, nil
 }
 
+// runWithTimeout runs the version control command, connecting through the
+// proxy of the outbound policy.
 func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	if err := setVCSProxy(cmd); err != nil {
+		return err
+	}
 	if err := cmd.Start(); err != nil {
 		return err
 	}
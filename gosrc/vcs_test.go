@@ -0,0 +1,78 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// +build !appengine
+
+package gosrc
+
+import "testing"
+
+func TestSSHKey(t *testing.T) {
+	defer SetCredentials(nil)
+	SetCredentials(map[string]*Credentials{"git.example.com": {SSHKey: "/etc/gddo/id_rsa"}})
+
+	if key := sshKey("git.example.com:2222/alice/pkg"); key != "/etc/gddo/id_rsa" {
+		t.Errorf("sshKey() = %q, want /etc/gddo/id_rsa", key)
+	}
+	if remote := gitRemote("ssh", "git.example.com/alice/pkg"); remote != "ssh://git@git.example.com/alice/pkg.git" {
+		t.Errorf("gitRemote() = %q, want ssh://git@git.example.com/alice/pkg.git", remote)
+	}
+	if remote := gitRemote("https", "example.org/bob/pkg"); remote != "https://example.org/bob/pkg.git" {
+		t.Errorf("gitRemote() = %q, want https://example.org/bob/pkg.git", remote)
+	}
+}
+
+func TestSetVCSCommands(t *testing.T) {
+	saved := enabledVCS
+	defer func() { enabledVCS = saved }()
+
+	if err := SetVCSCommands([]string{"git", " hg", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if !enabledVCS["hg"] || !enabledVCS["git"] || enabledVCS["svn"] {
+		t.Errorf("enabledVCS = %v, want git and hg", enabledVCS)
+	}
+	if err := SetVCSCommands([]string{"bzr"}); err == nil {
+		t.Error("SetVCSCommands accepted unsupported command")
+	}
+
+	_, err := getVCSDir(nil, map[string]string{"vcs": "svn", "repo": "example.org/r"}, "")
+	if !IsNotFound(err) {
+		t.Errorf("getVCSDir with disabled command returned %v, want not found", err)
+	}
+}
+
+var sourceHostTests = []struct {
+	kind, base string
+	file       string
+	project    string
+}{
+	{"cgit", "https://git.example.com/cgit/", "https://git.example.com/cgit/team/pkg/tree/sub/a.go?h=master", "https://git.example.com/cgit/team/pkg"},
+	{"gitweb", "https://git.example.com/gitweb", "https://git.example.com/gitweb?p=team/pkg.git;a=blob;f=sub/a.go;hb=master", "https://git.example.com/gitweb?p=team/pkg.git;a=summary"},
+	{"sourcegraph", "https://sourcegraph.example.com", "https://sourcegraph.example.com/git.example.com/team/pkg@master/-/blob/sub/a.go", "https://sourcegraph.example.com/git.example.com/team/pkg"},
+}
+
+func TestAddSourceHost(t *testing.T) {
+	saved := vcsServices
+	defer func() { vcsServices = saved }()
+
+	for _, tt := range sourceHostTests {
+		if err := AddSourceHost("git.example.com", tt.kind, tt.base); err != nil {
+			t.Fatalf("AddSourceHost(%q) returned error %v", tt.kind, err)
+		}
+		template, match := lookupURLTemplate("git.example.com/team/pkg", "/sub", "master")
+		if file := expand(template.fileBrowse, match, "a.go"); file != tt.file {
+			t.Errorf("%s file URL = %q, want %q", tt.kind, file, tt.file)
+		}
+		if project := expand(template.project, match); project != tt.project {
+			t.Errorf("%s project URL = %q, want %q", tt.kind, project, tt.project)
+		}
+	}
+
+	if err := AddSourceHost("git.example.com", "trac", "https://git.example.com"); err == nil {
+		t.Error("AddSourceHost accepted unknown source browser")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// +build !appengine
+
+package gosrc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// The version control commands resolve the hosts and follow the redirects
+// themselves. With the outbound policy enabled, the commands connect through
+// an HTTP proxy listening on the loopback interface and dialing with the
+// outbound policy, and the hosts not always fetched are fetched only with
+// the http and https schemes.
+
+var vcsProxy struct {
+	once sync.Once
+	host string
+	port string
+	user string
+	pass string
+	err  error
+}
+
+// startVCSProxy starts the proxy of the version control commands on first
+// use.
+func startVCSProxy() error {
+	vcsProxy.once.Do(func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			vcsProxy.err = err
+			return
+		}
+		p := make([]byte, 16)
+		if _, err := rand.Read(p); err != nil {
+			ln.Close()
+			vcsProxy.err = err
+			return
+		}
+		vcsProxy.host, vcsProxy.port, _ = net.SplitHostPort(ln.Addr().String())
+		vcsProxy.user, vcsProxy.pass = "gddo", hex.EncodeToString(p)
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(vcsProxy.user+":"+vcsProxy.pass))
+		go http.Serve(ln, &proxyHandler{auth: auth})
+	})
+	return vcsProxy.err
+}
+
+// vcsProxyURL returns the URL of the proxy of the version control commands
+// with the credentials.
+func vcsProxyURL() (string, error) {
+	if err := startVCSProxy(); err != nil {
+		return "", err
+	}
+	return "http://" + vcsProxy.user + ":" + vcsProxy.pass + "@" + net.JoinHostPort(vcsProxy.host, vcsProxy.port), nil
+}
+
+// setVCSProxy sets the environment of the git or hg command to connect
+// through the proxy if the outbound policy is enabled.
+func setVCSProxy(cmd *exec.Cmd) error {
+	if !outboundEnabled() {
+		return nil
+	}
+	u, err := vcsProxyURL()
+	if err != nil {
+		return err
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env,
+		"http_proxy="+u, "https_proxy="+u, "HTTP_PROXY="+u, "HTTPS_PROXY="+u,
+		"all_proxy=", "ALL_PROXY=", "no_proxy=", "NO_PROXY=")
+	return nil
+}
+
+// svnCommand returns an svn command connecting through the proxy if the
+// outbound policy is enabled.
+func svnCommand(args ...string) (*exec.Cmd, error) {
+	if outboundEnabled() {
+		if err := startVCSProxy(); err != nil {
+			return nil, err
+		}
+		args = append([]string{
+			"--non-interactive",
+			"--config-option", "servers:global:http-proxy-host=" + vcsProxy.host,
+			"--config-option", "servers:global:http-proxy-port=" + vcsProxy.port,
+			"--config-option", "servers:global:http-proxy-username=" + vcsProxy.user,
+			"--config-option", "servers:global:http-proxy-password=" + vcsProxy.pass,
+			"--config-option", "servers:global:http-proxy-exceptions=",
+		}, args...)
+	}
+	return exec.Command("svn", args...), nil
+}
+
+// outboundSchemes returns the schemes in schemes used to fetch from the host
+// with the outbound policy.
+func outboundSchemes(host string, schemes []string) []string {
+	if outboundAllowedByName(host) {
+		return schemes
+	}
+	var s []string
+	for _, scheme := range schemes {
+		if scheme == "http" || scheme == "https" {
+			s = append(s, scheme)
+		}
+	}
+	return s
+}
+
+// proxyDial dials the connections of the proxy.
+var proxyDial = OutboundDialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+
+var proxyTransport = &http.Transport{
+	DialContext:         proxyDial,
+	TLSHandshakeTimeout: 10 * time.Second,
+}
+
+// proxyHandler is the HTTP proxy of the version control commands.
+type proxyHandler struct {
+	auth string
+}
+
+func (p *proxyHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get("Proxy-Authorization")), []byte(p.auth)) != 1 {
+		resp.Header().Set("Proxy-Authenticate", `Basic realm="gddo"`)
+		http.Error(resp, "Proxy authentication required.", http.StatusProxyAuthRequired)
+		return
+	}
+	ctx := WithOutboundPolicy(req.Context())
+
+	if req.Method == "CONNECT" {
+		c, err := proxyDial(ctx, "tcp", req.Host)
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusForbidden)
+			return
+		}
+		defer c.Close()
+		hj, ok := resp.(http.Hijacker)
+		if !ok {
+			http.Error(resp, "Hijacking not supported.", http.StatusInternalServerError)
+			return
+		}
+		conn, brw, err := hj.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return
+		}
+		done := make(chan struct{})
+		go func() {
+			io.Copy(c, brw)
+			if cw, ok := c.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+			}
+			close(done)
+		}()
+		io.Copy(conn, c)
+		conn.Close()
+		<-done
+		return
+	}
+
+	if req.URL.Scheme != "http" || req.URL.Host == "" {
+		http.Error(resp, "Only http URLs are proxied.", http.StatusBadRequest)
+		return
+	}
+	out := req.Clone(ctx)
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Authorization")
+	out.Header.Del("Proxy-Connection")
+	r, err := proxyTransport.RoundTrip(out)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer r.Body.Close()
+	for k, vs := range r.Header {
+		resp.Header()[k] = vs
+	}
+	resp.WriteHeader(r.StatusCode)
+	io.Copy(resp, r.Body)
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// +build !appengine
+
+package gosrc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestVCSProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+	setTestOutboundPolicy(t, nil, nil)
+
+	u, err := vcsProxyURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pu, _ := url.Parse(u)
+	status := func(pu *url.URL) int {
+		c := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(pu)}}
+		resp, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if s := status(pu); s != http.StatusBadGateway {
+		t.Errorf("proxied request to a loopback address returned %d, want %d", s, http.StatusBadGateway)
+	}
+	SetOutboundPolicy(true, []string{"127.0.0.1"}, nil)
+	if s := status(pu); s != http.StatusOK {
+		t.Errorf("proxied request to an allowed address returned %d, want %d", s, http.StatusOK)
+	}
+	pu.User = nil
+	if s := status(pu); s != http.StatusProxyAuthRequired {
+		t.Errorf("proxied request without credentials returned %d, want %d", s, http.StatusProxyAuthRequired)
+	}
+
+	if s := outboundSchemes("example.com", []string{"http", "https", "ssh", "git"}); len(s) != 2 {
+		t.Errorf("outboundSchemes(example.com) = %v, want [http https]", s)
+	}
+}
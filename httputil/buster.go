@@ -85,6 +85,14 @@ func (cb *CacheBusters) Get(path string) string {
 	return token
 }
 
+// Reset clears the cached tokens so that the tokens of changed files are
+// computed again.
+func (cb *CacheBusters) Reset() {
+	cb.mu.Lock()
+	cb.tokens = nil
+	cb.mu.Unlock()
+}
+
 // AppendQueryParam appends the token as a query parameter to path.
 func (cb *CacheBusters) AppendQueryParam(path string, name string) string {
 	token := cb.Get(path)
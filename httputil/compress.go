@@ -0,0 +1,145 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package httputil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the size of the smallest response body compressed by
+// CompressHandler.
+const minCompressSize = 1024
+
+// isCompressible returns true if responses with the given content type
+// benefit from compression.
+func isCompressible(contentType string) bool {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	switch {
+	case strings.HasPrefix(contentType, "text/"),
+		strings.HasSuffix(contentType, "+xml"),
+		strings.HasSuffix(contentType, "+json"):
+		return true
+	}
+	switch contentType {
+	case "application/json", "application/javascript", "application/xml", "application/x-javascript":
+		return true
+	}
+	return false
+}
+
+// weakEtag returns the weak form of etag. The entity tag of a response is
+// weakened when the response is compressed because the encoded body is not
+// byte for byte identical to the body returned by the handler.
+func weakEtag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return "W/" + etag
+}
+
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+// CompressHandler returns a handler that compresses the responses of h with
+// gzip content encoding when the client accepts the encoding. Responses that
+// already have a content encoding, responses with content types that do not
+// benefit from compression and small responses are not compressed.
+func CompressHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{
+			ResponseWriter: w,
+			accept:         NegotiateContentEncoding(r, []string{"gzip"}) == "gzip" && r.Method != "HEAD",
+		}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	accept      bool
+	wroteHeader bool
+	gz          *gzip.Writer
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" && isCompressible(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+		n, err := strconv.Atoi(h.Get("Content-Length"))
+		if w.accept && status == http.StatusOK && h.Get("Content-Range") == "" && (err != nil || n >= minCompressSize) {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", "gzip")
+			if etag := h.Get("Etag"); etag != "" {
+				h.Set("Etag", weakEtag(etag))
+			}
+			w.gz = gzipWriters.Get().(*gzip.Writer)
+			w.gz.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends the compressed data written so far to the client, for
+// responses such as event streams that are written over time.
+func (w *compressWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// gzipBytes returns the gzip encoding of the content read from r.
+func gzipBytes(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(gz, r); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
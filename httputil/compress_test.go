@@ -0,0 +1,113 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package httputil_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/gddo/httputil"
+)
+
+var compressTests = []struct {
+	name           string
+	acceptEncoding string
+	contentType    string
+	body           string
+	encoding       string
+}{
+	{"gzip", "gzip, deflate", "text/html; charset=utf-8", strings.Repeat("x", 2048), "gzip"},
+	{"not accepted", "", "text/html; charset=utf-8", strings.Repeat("x", 2048), ""},
+	{"small", "gzip", "text/html; charset=utf-8", "x", ""},
+	{"image", "gzip", "image/png", strings.Repeat("x", 2048), ""},
+}
+
+func TestCompressHandler(t *testing.T) {
+	for _, tt := range compressTests {
+		h := httputil.CompressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.Header().Set("Etag", `"abc"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+			w.Write([]byte(tt.body))
+		}))
+		r := &http.Request{Method: "GET", Header: http.Header{"Accept-Encoding": {tt.acceptEncoding}}}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if encoding := w.HeaderMap.Get("Content-Encoding"); encoding != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, encoding, tt.encoding)
+			continue
+		}
+		body := w.Body.String()
+		if tt.encoding == "gzip" {
+			if etag := w.HeaderMap.Get("Etag"); etag != `W/"abc"` {
+				t.Errorf("%s: Etag = %q, want weak etag", tt.name, etag)
+			}
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			p, err := ioutil.ReadAll(gz)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			body = string(p)
+		}
+		if body != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.body)
+		}
+	}
+}
+
+func TestStaticServerCompress(t *testing.T) {
+	ss := &httputil.StaticServer{Compress: true, MIMETypes: map[string]string{".go": "text/plain; charset=utf-8"}}
+	h := ss.FilesHandler("static_test.go")
+	r := &http.Request{
+		Method: "GET",
+		URL:    mustParseURL("/"),
+		Header: http.Header{"Accept-Encoding": {"br, gzip"}},
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if encoding := w.HeaderMap.Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", encoding)
+	}
+	etag := w.HeaderMap.Get("Etag")
+	if etag != "W/"+testEtag {
+		t.Errorf("Etag = %q, want %q", etag, "W/"+testEtag)
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status with weak If-None-Match = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestCompressHandlerFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	h := httputil.CompressHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("event: updated\n\n"))
+		rw.(http.Flusher).Flush()
+		if !w.Flushed || w.Body.Len() == 0 {
+			t.Errorf("Flush did not send the response, flushed %v, %d bytes", w.Flushed, w.Body.Len())
+		}
+	}))
+	h.ServeHTTP(w, &http.Request{Method: "GET", Header: http.Header{"Accept-Encoding": {"gzip"}}})
+	if encoding := w.HeaderMap.Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", encoding)
+	}
+}
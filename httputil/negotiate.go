@@ -77,3 +77,33 @@ func NegotiateContentType(r *http.Request, offers []string, defaultOffer string)
 	}
 	return bestOffer
 }
+
+// NegotiateLanguage returns the best offered language for the request's
+// Accept-Language header. A language range matches an offer with the same
+// tag, and a language range and an offer match with lower weight if one is a
+// prefix of the other, such as "en" and "en-GB". If two offers match with
+// equal weight, then the offer earlier in the list is preferred. If no offers
+// match, then defaultOffer is returned.
+func NegotiateLanguage(r *http.Request, offers []string, defaultOffer string) string {
+	bestOffer := defaultOffer
+	bestQ := 0.0
+	specs := header.ParseAccept(r.Header, "Accept-Language")
+	for _, offer := range offers {
+		for _, spec := range specs {
+			q := spec.Q
+			switch value := strings.ToLower(spec.Value); {
+			case value == strings.ToLower(offer) || value == "*":
+			case strings.HasPrefix(value, strings.ToLower(offer)+"-") ||
+				strings.HasPrefix(strings.ToLower(offer), value+"-"):
+				q *= 0.9
+			default:
+				continue
+			}
+			if q > bestQ {
+				bestQ = q
+				bestOffer = offer
+			}
+		}
+	}
+	return bestOffer
+}
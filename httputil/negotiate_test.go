@@ -69,3 +69,29 @@ func TestNegotiateContentType(t *testing.T) {
 		}
 	}
 }
+
+var negotiateLanguageTests = []struct {
+	s      string
+	offers []string
+	expect string
+}{
+	{"", []string{"en", "es"}, "en"},
+	{"es", []string{"en", "es"}, "es"},
+	{"es-MX, en;q=0.5", []string{"en", "es"}, "es"},
+	{"pt-BR, pt;q=0.8", []string{"en", "pt", "pt-BR"}, "pt-BR"},
+	{"pt", []string{"en", "pt-BR"}, "pt-BR"},
+	{"fr, en;q=0.5", []string{"en", "es"}, "en"},
+	{"fr", []string{"en", "es"}, "en"},
+	{"es;q=0", []string{"es"}, "en"},
+	{"EN-gb", []string{"es", "en"}, "en"},
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	for _, tt := range negotiateLanguageTests {
+		r := &http.Request{Header: http.Header{"Accept-Language": {tt.s}}}
+		actual := httputil.NegotiateLanguage(r, tt.offers, "en")
+		if actual != tt.expect {
+			t.Errorf("NegotiateLanguage(%q, %#v)=%q, want %q", tt.s, tt.offers, actual, tt.expect)
+		}
+	}
+}
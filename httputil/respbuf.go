@@ -8,24 +8,84 @@ package httputil
 
 import (
 	"bytes"
+	"errors"
 	"net/http"
 	"strconv"
 )
 
+// ResponseBuffer buffers a response so that the handler can replace the
+// response with an error page until the response is complete. The
+// buffered response is written with WriteTo. A buffer created with
+// NewResponseBuffer can instead stream the response to its writer.
 type ResponseBuffer struct {
 	buf    bytes.Buffer
 	status int
 	header http.Header
+
+	// w is the writer of the streamed response and streaming is true after
+	// Stream.
+	w         http.ResponseWriter
+	streaming bool
+}
+
+// NewResponseBuffer returns a buffer for a response to w.
+func NewResponseBuffer(w http.ResponseWriter) *ResponseBuffer {
+	return &ResponseBuffer{w: w}
 }
 
 func (rb *ResponseBuffer) Write(p []byte) (int, error) {
+	if rb.streaming {
+		return rb.w.Write(p)
+	}
 	return rb.buf.Write(p)
 }
 
 func (rb *ResponseBuffer) WriteHeader(status int) {
+	if rb.streaming {
+		return
+	}
 	rb.status = status
 }
 
+// Stream writes the buffered response to the writer of the buffer and
+// writes the rest of the response directly to the writer, so that a large
+// response is sent while it is generated and not held in memory. After
+// Stream, the response can no longer be replaced with an error page.
+func (rb *ResponseBuffer) Stream() error {
+	if rb.w == nil {
+		return errors.New("httputil: response buffer without writer")
+	}
+	if rb.streaming {
+		return nil
+	}
+	for k, v := range rb.header {
+		rb.w.Header()[k] = v
+	}
+	if rb.status != 0 {
+		rb.w.WriteHeader(rb.status)
+	}
+	rb.streaming = true
+	if rb.buf.Len() > 0 {
+		_, err := rb.w.Write(rb.buf.Bytes())
+		rb.buf.Reset()
+		return err
+	}
+	return nil
+}
+
+// Flush sends the data written to a streamed response to the client. Flush
+// does nothing if the response is not streamed.
+func (rb *ResponseBuffer) Flush() {
+	if f, ok := rb.w.(http.Flusher); ok && rb.streaming {
+		f.Flush()
+	}
+}
+
+// Streaming returns true if the response is streamed.
+func (rb *ResponseBuffer) Streaming() bool {
+	return rb.streaming
+}
+
 func (rb *ResponseBuffer) Header() http.Header {
 	if rb.header == nil {
 		rb.header = make(http.Header)
@@ -33,7 +93,12 @@ func (rb *ResponseBuffer) Header() http.Header {
 	return rb.header
 }
 
+// WriteTo writes the buffered response to w. WriteTo does nothing if the
+// response is streamed.
 func (rb *ResponseBuffer) WriteTo(w http.ResponseWriter) error {
+	if rb.streaming {
+		return nil
+	}
 	for k, v := range rb.header {
 		w.Header()[k] = v
 	}
@@ -0,0 +1,66 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/gddo/httputil"
+)
+
+func TestResponseBufferStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	rb := httputil.NewResponseBuffer(w)
+	rb.Header().Set("Content-Type", "text/plain")
+	rb.WriteHeader(http.StatusCreated)
+	rb.Write([]byte("head "))
+	if w.Body.Len() != 0 {
+		t.Fatalf("body written before Stream: %q", w.Body.String())
+	}
+
+	if err := rb.Stream(); err != nil {
+		t.Fatal(err)
+	}
+	if !rb.Streaming() {
+		t.Error("Streaming() = false after Stream")
+	}
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "text/plain" || w.Body.String() != "head " {
+		t.Errorf("after Stream: status %d, type %q, body %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	rb.Write([]byte("body"))
+	if w.Body.String() != "head body" {
+		t.Errorf("body = %q, want %q", w.Body.String(), "head body")
+	}
+	if err := rb.WriteTo(w); err != nil || w.Body.String() != "head body" {
+		t.Errorf("WriteTo after Stream changed the body to %q, err %v", w.Body.String(), err)
+	}
+
+	var zero httputil.ResponseBuffer
+	if err := zero.Stream(); err == nil {
+		t.Error("Stream of buffer without writer returned nil error")
+	}
+}
+
+func TestResponseBufferFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	rb := httputil.NewResponseBuffer(w)
+	rb.Write([]byte("data"))
+	rb.Flush()
+	if w.Flushed {
+		t.Error("buffered response flushed")
+	}
+	if err := rb.Stream(); err != nil {
+		t.Fatal(err)
+	}
+	rb.Flush()
+	if !w.Flushed || w.Body.String() != "data" {
+		t.Errorf("after Stream and Flush: flushed %v, body %q", w.Flushed, w.Body.String())
+	}
+}
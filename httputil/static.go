@@ -30,6 +30,10 @@ type StaticServer struct {
 	// Dir specifies the location of the directory containing the files to serve.
 	Dir string
 
+	// OverrideDir specifies the location of an optional directory with files
+	// that are served in place of the files with the same path in Dir.
+	OverrideDir string
+
 	// MaxAge specifies the maximum age for the cache control and expiration
 	// headers.
 	MaxAge time.Duration
@@ -41,19 +45,46 @@ type StaticServer struct {
 	// MIMETypes is a map from file extensions to MIME types.
 	MIMETypes map[string]string
 
-	mu    sync.Mutex
-	etags map[string]string
+	// Compress specifies whether compressible files are served with gzip or
+	// brotli content encoding to clients that accept the encodings. The gzip
+	// encoding of a file is computed once and cached. The gzip encoding of
+	// the files served by FilesHandler is computed when the handler is
+	// created. The brotli encoding of a file is read from the file with the
+	// same name and the extension .br appended, if the file exists.
+	Compress bool
+
+	mu      sync.Mutex
+	etags   map[string]string
+	encoded map[string][]byte
+	files   map[string][]byte
+}
+
+// Reset clears the cached entity tags and encodings of the files so that
+// changed files are served. Handlers created by FilesHandler read the files
+// again.
+func (ss *StaticServer) Reset() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.etags = nil
+	ss.encoded = nil
+	ss.files = nil
 }
 
 func (ss *StaticServer) resolve(fname string) string {
 	if path.IsAbs(fname) {
 		panic("Absolute path not allowed when creating a StaticServer handler")
 	}
+	fname = filepath.FromSlash(fname)
+	if ss.OverrideDir != "" {
+		p := filepath.Join(ss.OverrideDir, fname)
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			return p
+		}
+	}
 	dir := ss.Dir
 	if dir == "" {
 		dir = "."
 	}
-	fname = filepath.FromSlash(fname)
 	return filepath.Join(dir, fname)
 }
 
@@ -90,16 +121,33 @@ func (ss *StaticServer) openFile(fname string) (io.ReadCloser, int64, string, er
 	return f, fi.Size(), ss.mimeType(fname), nil
 }
 
+func (ss *StaticServer) getEncoded(key string) ([]byte, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	b, ok := ss.encoded[key]
+	return b, ok
+}
+
+func (ss *StaticServer) setEncoded(key string, b []byte) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.encoded == nil {
+		ss.encoded = make(map[string][]byte)
+	}
+	ss.encoded[key] = b
+}
+
 // FileHandler returns a handler that serves a single file. The file is
 // specified by a slash separated path relative to the static server's Dir
 // field.
 func (ss *StaticServer) FileHandler(fileName string) http.Handler {
 	id := fileName
-	fileName = ss.resolve(fileName)
+	ss.resolve(fileName)
 	return &staticHandler{
-		ss:   ss,
-		id:   func(_ string) string { return id },
-		open: func(_ string) (io.ReadCloser, int64, string, error) { return ss.openFile(fileName) },
+		ss:         ss,
+		id:         func(_ string) string { return id },
+		open:       func(_ string) (io.ReadCloser, int64, string, error) { return ss.openFile(ss.resolve(id)) },
+		brotliFile: func(_ string) string { return ss.resolve(id) + ".br" },
 	}
 }
 
@@ -111,7 +159,7 @@ func (ss *StaticServer) DirectoryHandler(prefix, dirName string) http.Handler {
 		prefix += "/"
 	}
 	idBase := dirName
-	dirName = ss.resolve(dirName)
+	ss.resolve(dirName)
 	return &staticHandler{
 		ss: ss,
 		id: func(p string) string {
@@ -124,8 +172,13 @@ func (ss *StaticServer) DirectoryHandler(prefix, dirName string) http.Handler {
 			if !strings.HasPrefix(p, prefix) {
 				return nil, 0, "", errors.New("request url does not match directory prefix")
 			}
-			p = p[len(prefix):]
-			return ss.openFile(filepath.Join(dirName, filepath.FromSlash(p)))
+			return ss.openFile(ss.resolve(path.Join(idBase, p[len(prefix):])))
+		},
+		brotliFile: func(p string) string {
+			if !strings.HasPrefix(p, prefix) {
+				return ""
+			}
+			return ss.resolve(path.Join(idBase, p[len(prefix):])) + ".br"
 		},
 	}
 }
@@ -138,34 +191,96 @@ func (ss *StaticServer) FilesHandler(fileNames ...string) http.Handler {
 	// todo: cache concatenated files on disk and serve from there.
 
 	mimeType := ss.mimeType(fileNames[0])
-	var buf []byte
-	var openErr error
-
-	for _, fileName := range fileNames {
-		p, err := ioutil.ReadFile(ss.resolve(fileName))
-		if err != nil {
-			openErr = err
-			buf = nil
-			break
+	id := strings.Join(fileNames, " ")
+
+	load := func() ([]byte, error) {
+		ss.mu.Lock()
+		buf, ok := ss.files[id]
+		ss.mu.Unlock()
+		if ok {
+			return buf, nil
+		}
+		for _, fileName := range fileNames {
+			p, err := ioutil.ReadFile(ss.resolve(fileName))
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, p...)
 		}
-		buf = append(buf, p...)
+		ss.mu.Lock()
+		if ss.files == nil {
+			ss.files = make(map[string][]byte)
+		}
+		ss.files[id] = buf
+		ss.mu.Unlock()
+		return buf, nil
 	}
 
-	id := strings.Join(fileNames, " ")
+	buf, err := load()
+	if ss.Compress && err == nil && isCompressible(mimeType) {
+		if b, err := gzipBytes(bytes.NewReader(buf)); err == nil {
+			ss.setEncoded("gzip "+id, b)
+		}
+	}
 
 	return &staticHandler{
 		ss: ss,
 		id: func(_ string) string { return id },
 		open: func(p string) (io.ReadCloser, int64, string, error) {
-			return ioutil.NopCloser(bytes.NewReader(buf)), int64(len(buf)), mimeType, openErr
+			buf, err := load()
+			return ioutil.NopCloser(bytes.NewReader(buf)), int64(len(buf)), mimeType, err
 		},
 	}
 }
 
 type staticHandler struct {
-	id   func(fname string) string
-	open func(p string) (io.ReadCloser, int64, string, error)
-	ss   *StaticServer
+	id         func(fname string) string
+	open       func(p string) (io.ReadCloser, int64, string, error)
+	brotliFile func(p string) string
+	ss         *StaticServer
+}
+
+// encode returns the best content encoding of the file at p accepted by the
+// client and the encoded file. If the file is not encoded, then encode
+// returns "".
+func (h *staticHandler) encode(r *http.Request, p string) (string, []byte) {
+	id := h.id(p)
+	offers := []string{"gzip"}
+	var br []byte
+	if h.brotliFile != nil {
+		var ok bool
+		br, ok = h.ss.getEncoded("br " + id)
+		if !ok {
+			if fname := h.brotliFile(p); fname != "" {
+				br, _ = ioutil.ReadFile(fname)
+			}
+			h.ss.setEncoded("br "+id, br)
+		}
+		if br != nil {
+			offers = []string{"br", "gzip"}
+		}
+	}
+
+	switch NegotiateContentEncoding(r, offers) {
+	case "br":
+		return "br", br
+	case "gzip":
+		gz, ok := h.ss.getEncoded("gzip " + id)
+		if !ok {
+			rc, _, _, err := h.open(p)
+			if err != nil {
+				return "", nil
+			}
+			gz, err = gzipBytes(rc)
+			rc.Close()
+			if err != nil {
+				return "", nil
+			}
+			h.ss.setEncoded("gzip "+id, gz)
+		}
+		return "gzip", gz
+	}
+	return "", nil
 }
 
 func (h *staticHandler) error(w http.ResponseWriter, r *http.Request, status int, err error) {
@@ -235,7 +350,7 @@ func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cacheControl := fmt.Sprintf("public, max-age=%d", maxAge/time.Second)
 
 	for _, e := range header.ParseList(r.Header, "If-None-Match") {
-		if e == etag {
+		if strings.TrimPrefix(e, "W/") == etag {
 			w.Header().Set("Cache-Control", cacheControl)
 			w.Header().Set("Etag", etag)
 			w.WriteHeader(http.StatusNotModified)
@@ -250,6 +365,17 @@ func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rc.Close()
 
+	var body io.Reader = rc
+	if h.ss.Compress && isCompressible(ct) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding, b := h.encode(r, p); encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+			etag = weakEtag(etag)
+			body = bytes.NewReader(b)
+			cl = int64(len(b))
+		}
+	}
+
 	w.Header().Set("Cache-Control", cacheControl)
 	w.Header().Set("Etag", etag)
 	if ct != "" {
@@ -260,6 +386,6 @@ func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusOK)
 	if r.Method != "HEAD" {
-		io.Copy(w, rc)
+		io.Copy(w, body)
 	}
 }
@@ -15,6 +15,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
@@ -172,3 +173,78 @@ func TestDirectoryHandler(t *testing.T) {
 func TestFilesHandler(t *testing.T) {
 	testStaticServer(t, func(ss *httputil.StaticServer) http.Handler { return ss.FilesHandler("static_test.go") })
 }
+
+func TestStaticServerReset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := dir + "/a.css"
+	if err := ioutil.WriteFile(fname, []byte("old"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ss := &httputil.StaticServer{Dir: dir}
+	handlers := map[string]http.Handler{
+		"FileHandler":  ss.FileHandler("a.css"),
+		"FilesHandler": ss.FilesHandler("a.css"),
+	}
+	get := func(h http.Handler) (string, string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/a.css", nil))
+		return w.Body.String(), w.Header().Get("Etag")
+	}
+	etags := make(map[string]string)
+	for name, h := range handlers {
+		_, etags[name] = get(h)
+	}
+	if err := ioutil.WriteFile(fname, []byte("new"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ss.Reset()
+	for name, h := range handlers {
+		body, etag := get(h)
+		if body != "new" || etag == etags[name] {
+			t.Errorf("%s after Reset: body %q, etag %s; want new body and etag", name, body, etag)
+		}
+	}
+}
+
+func TestStaticServerOverrideDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string]string{
+		"base/a.css":     "base a",
+		"base/b.css":     "base b",
+		"override/a.css": "override a",
+	} {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fname, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ss := &httputil.StaticServer{Dir: filepath.Join(dir, "base"), OverrideDir: filepath.Join(dir, "override")}
+	for _, tt := range []struct {
+		h    http.Handler
+		url  string
+		want string
+	}{
+		{ss.FileHandler("a.css"), "/a.css", "override a"},
+		{ss.FileHandler("b.css"), "/b.css", "base b"},
+		{ss.DirectoryHandler("/static/", "."), "/static/a.css", "override a"},
+		{ss.DirectoryHandler("/static/", "."), "/static/b.css", "base b"},
+		{ss.FilesHandler("a.css", "b.css"), "/all.css", "override abase b"},
+	} {
+		w := httptest.NewRecorder()
+		tt.h.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Body.String() != tt.want {
+			t.Errorf("%s: body %q, want %q", tt.url, w.Body.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,538 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// File is a parsed .proto file. ParseFile supports the subset of the proto3
+// language used by the .proto files of this repository: messages with
+// scalar, message, enum and map fields, enums and services. Nested
+// declarations, oneofs and options other than go_package are not supported.
+type File struct {
+	Name    string
+	Package string
+	Imports []string
+
+	goPackage string
+	messages  []*message
+	enums     []*enum
+	services  []*service
+}
+
+type message struct {
+	name     string
+	fields   []*field
+	entries  []*message // map entries
+	mapEntry bool
+}
+
+type field struct {
+	name     string
+	number   int
+	repeated bool
+	typ      string
+}
+
+type enum struct {
+	name   string
+	values []enumValue
+}
+
+type enumValue struct {
+	name   string
+	number int
+}
+
+type service struct {
+	name    string
+	methods []*method
+}
+
+type method struct {
+	name                             string
+	input, output                    string
+	clientStreaming, serverStreaming bool
+}
+
+// scalarTypes maps the scalar types to the values of
+// google.protobuf.FieldDescriptorProto.Type.
+var scalarTypes = map[string]int{
+	"double":   1,
+	"float":    2,
+	"int64":    3,
+	"uint64":   4,
+	"int32":    5,
+	"fixed64":  6,
+	"fixed32":  7,
+	"bool":     8,
+	"string":   9,
+	"bytes":    12,
+	"uint32":   13,
+	"sfixed32": 15,
+	"sfixed64": 16,
+	"sint32":   17,
+	"sint64":   18,
+}
+
+const (
+	messageFieldType = 11
+	enumFieldType    = 14
+)
+
+type parser struct {
+	name   string
+	toks   []string
+	lines  []int
+	i      int
+	err    error
+	result *File
+}
+
+func tokenize(src string) ([]string, []int, error) {
+	var toks []string
+	var lines []int
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			j := strings.Index(src[i+2:], "*/")
+			if j < 0 {
+				return nil, nil, fmt.Errorf("%d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+j], "\n")
+			i += j + 4
+		case c == '"':
+			j := strings.IndexByte(src[i+1:], '"')
+			if j < 0 {
+				return nil, nil, fmt.Errorf("%d: unterminated string", line)
+			}
+			toks = append(toks, src[i:i+j+2])
+			lines = append(lines, line)
+			i += j + 2
+		case c == '_' || c == '.' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || c == '-':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			lines = append(lines, line)
+			i = j
+		default:
+			toks = append(toks, src[i:i+1])
+			lines = append(lines, line)
+			i++
+		}
+	}
+	return toks, lines, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	line := 0
+	if p.i < len(p.lines) {
+		line = p.lines[p.i]
+	} else if len(p.lines) > 0 {
+		line = p.lines[len(p.lines)-1]
+	}
+	p.err = fmt.Errorf("%s:%d: %s", p.name, line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() string {
+	if p.err != nil || p.i >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.i]
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	if t == "" {
+		p.errorf("unexpected end of file")
+		return ""
+	}
+	p.i++
+	return t
+}
+
+func (p *parser) expect(want string) {
+	if t := p.next(); t != want && p.err == nil {
+		p.errorf("got %q, want %q", t, want)
+	}
+}
+
+func (p *parser) ident() string {
+	t := p.next()
+	if p.err == nil && !isIdent(t) {
+		p.errorf("got %q, want identifier", t)
+	}
+	return t
+}
+
+func (p *parser) number() int {
+	t := p.next()
+	n, err := strconv.Atoi(t)
+	if err != nil {
+		p.errorf("got %q, want number", t)
+	}
+	return n
+}
+
+func (p *parser) str() string {
+	t := p.next()
+	s, err := strconv.Unquote(t)
+	if err != nil && p.err == nil {
+		p.errorf("got %s, want string", t)
+	}
+	return s
+}
+
+// skipStatement skips the tokens up to and including the next ";".
+func (p *parser) skipStatement() {
+	for p.err == nil && p.next() != ";" {
+	}
+}
+
+func isIdent(t string) bool {
+	return t != "" && (t[0] == '_' || t[0] == '.' || unicode.IsLetter(rune(t[0])))
+}
+
+// ParseFile parses the .proto file src with the given name. The name is the
+// path used by the import statements of other files.
+func ParseFile(name, src string) (*File, error) {
+	toks, lines, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", name, err)
+	}
+	p := &parser{name: name, toks: toks, lines: lines, result: &File{Name: name}}
+	for p.err == nil && p.peek() != "" {
+		switch p.next() {
+		case "syntax":
+			p.expect("=")
+			if s := p.str(); s != "proto3" && p.err == nil {
+				p.errorf("syntax %q not supported", s)
+			}
+			p.expect(";")
+		case "package":
+			p.result.Package = p.ident()
+			p.expect(";")
+		case "import":
+			p.result.Imports = append(p.result.Imports, p.str())
+			p.expect(";")
+		case "option":
+			if p.ident() == "go_package" {
+				p.expect("=")
+				p.result.goPackage = p.str()
+				p.expect(";")
+			} else {
+				p.skipStatement()
+			}
+		case "message":
+			p.result.messages = append(p.result.messages, p.message())
+		case "enum":
+			p.result.enums = append(p.result.enums, p.enum())
+		case "service":
+			p.result.services = append(p.result.services, p.service())
+		case ";":
+		default:
+			p.i--
+			p.errorf("unexpected %q", p.peek())
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.result, nil
+}
+
+func (p *parser) message() *message {
+	m := &message{name: p.ident()}
+	p.expect("{")
+	for p.err == nil {
+		switch t := p.next(); t {
+		case "}":
+			return m
+		case "reserved", "option":
+			p.skipStatement()
+		case "map":
+			p.expect("<")
+			key := p.ident()
+			p.expect(",")
+			value := p.ident()
+			p.expect(">")
+			f := p.field(m.name + "." + entryName(p.peek()))
+			f.repeated = true
+			m.fields = append(m.fields, f)
+			m.entries = append(m.entries, &message{
+				name:     entryName(f.name),
+				fields:   []*field{{name: "key", number: 1, typ: key}, {name: "value", number: 2, typ: value}},
+				mapEntry: true,
+			})
+		case "repeated":
+			f := p.field(p.ident())
+			f.repeated = true
+			m.fields = append(m.fields, f)
+		default:
+			if !isIdent(t) {
+				p.errorf("unexpected %q in message %s", t, m.name)
+			}
+			m.fields = append(m.fields, p.field(t))
+		}
+	}
+	return m
+}
+
+// field parses the name and number of a field of type typ.
+func (p *parser) field(typ string) *field {
+	f := &field{typ: typ, name: p.ident()}
+	p.expect("=")
+	f.number = p.number()
+	if p.peek() == "[" {
+		// Skip the field options.
+		for p.err == nil && p.next() != "]" {
+		}
+	}
+	p.expect(";")
+	return f
+}
+
+// entryName returns the name of the message of the entries of the map field
+// name, as generated by protoc.
+func entryName(name string) string {
+	return camelCase(name, true) + "Entry"
+}
+
+// camelCase converts the snake case name to camel case.
+func camelCase(name string, upper bool) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (p *parser) enum() *enum {
+	e := &enum{name: p.ident()}
+	p.expect("{")
+	for p.err == nil {
+		switch t := p.next(); t {
+		case "}":
+			return e
+		case "reserved", "option":
+			p.skipStatement()
+		default:
+			v := enumValue{name: t}
+			p.expect("=")
+			v.number = p.number()
+			p.expect(";")
+			e.values = append(e.values, v)
+		}
+	}
+	return e
+}
+
+func (p *parser) service() *service {
+	s := &service{name: p.ident()}
+	p.expect("{")
+	for p.err == nil {
+		switch t := p.next(); t {
+		case "}":
+			return s
+		case "option":
+			p.skipStatement()
+		case "rpc":
+			m := &method{name: p.ident()}
+			p.expect("(")
+			if p.peek() == "stream" {
+				p.next()
+				m.clientStreaming = true
+			}
+			m.input = p.ident()
+			p.expect(")")
+			p.expect("returns")
+			p.expect("(")
+			if p.peek() == "stream" {
+				p.next()
+				m.serverStreaming = true
+			}
+			m.output = p.ident()
+			p.expect(")")
+			if p.peek() == "{" {
+				for p.err == nil && p.next() != "}" {
+				}
+			} else {
+				p.expect(";")
+			}
+			s.methods = append(s.methods, m)
+		default:
+			p.errorf("unexpected %q in service %s", t, s.name)
+		}
+	}
+	return s
+}
+
+// Symbols returns the full names of the messages, enums, services and
+// methods declared in the file.
+func (f *File) Symbols() []string {
+	var symbols []string
+	for _, m := range f.messages {
+		symbols = append(symbols, f.Package+"."+m.name)
+	}
+	for _, e := range f.enums {
+		symbols = append(symbols, f.Package+"."+e.name)
+	}
+	for _, s := range f.services {
+		symbols = append(symbols, f.Package+"."+s.name)
+		for _, m := range s.methods {
+			symbols = append(symbols, f.Package+"."+s.name+"."+m.name)
+		}
+	}
+	return symbols
+}
+
+// Services returns the full names of the services declared in the file.
+func (f *File) Services() []string {
+	var names []string
+	for _, s := range f.services {
+		names = append(names, f.Package+"."+s.name)
+	}
+	return names
+}
+
+// Descriptors returns the google.protobuf.FileDescriptorProto messages of the
+// files by file name. The types used in the files must be declared in the
+// files.
+func Descriptors(files ...*File) (map[string][]byte, error) {
+	// kinds maps the full names of the types to the field type.
+	kinds := make(map[string]int)
+	for _, f := range files {
+		for _, m := range f.messages {
+			kinds[f.Package+"."+m.name] = messageFieldType
+			for _, e := range m.entries {
+				kinds[f.Package+"."+m.name+"."+e.name] = messageFieldType
+			}
+		}
+		for _, e := range f.enums {
+			kinds[f.Package+"."+e.name] = enumFieldType
+		}
+	}
+
+	result := make(map[string][]byte)
+	for _, f := range files {
+		var err error
+		// resolve returns the fully qualified name of the type name
+		// referenced in f and the kind of the type.
+		resolve := func(name string) (string, int) {
+			for _, full := range []string{f.Package + "." + name, name} {
+				if kind, ok := kinds[full]; ok {
+					return "." + full, kind
+				}
+			}
+			if err == nil {
+				err = fmt.Errorf("%s: type %s not found", f.Name, name)
+			}
+			return "", 0
+		}
+
+		var b Buffer
+		b.AppendString(1, f.Name)
+		b.AppendString(2, f.Package)
+		b.AppendStrings(3, f.Imports)
+		for _, m := range f.messages {
+			b.AppendMessage(4, func(b *Buffer) { appendMessageDescriptor(b, m, resolve) })
+		}
+		for _, e := range f.enums {
+			b.AppendMessage(5, func(b *Buffer) {
+				b.AppendString(1, e.name)
+				for _, v := range e.values {
+					b.AppendMessage(2, func(b *Buffer) {
+						b.AppendString(1, v.name)
+						b.AppendInt(2, int64(v.number))
+					})
+				}
+			})
+		}
+		for _, s := range f.services {
+			b.AppendMessage(6, func(b *Buffer) {
+				b.AppendString(1, s.name)
+				for _, m := range s.methods {
+					b.AppendMessage(2, func(b *Buffer) {
+						b.AppendString(1, m.name)
+						input, _ := resolve(m.input)
+						output, _ := resolve(m.output)
+						b.AppendString(2, input)
+						b.AppendString(3, output)
+						b.AppendBool(5, m.clientStreaming)
+						b.AppendBool(6, m.serverStreaming)
+					})
+				}
+			})
+		}
+		if f.goPackage != "" {
+			b.AppendMessage(8, func(b *Buffer) { b.AppendString(11, f.goPackage) })
+		}
+		b.AppendString(12, "proto3")
+		if err != nil {
+			return nil, err
+		}
+		result[f.Name] = b.Bytes()
+	}
+	return result, nil
+}
+
+func appendMessageDescriptor(b *Buffer, m *message, resolve func(string) (string, int)) {
+	b.AppendString(1, m.name)
+	for _, f := range m.fields {
+		b.AppendMessage(2, func(b *Buffer) {
+			b.AppendString(1, f.name)
+			b.AppendInt(3, int64(f.number))
+			if f.repeated {
+				b.AppendInt(4, 3) // LABEL_REPEATED
+			} else {
+				b.AppendInt(4, 1) // LABEL_OPTIONAL
+			}
+			if t, ok := scalarTypes[f.typ]; ok {
+				b.AppendInt(5, int64(t))
+			} else {
+				name, kind := resolve(f.typ)
+				b.AppendInt(5, int64(kind))
+				b.AppendString(6, name)
+			}
+			b.AppendString(10, camelCase(f.name, false))
+		})
+	}
+	for _, e := range m.entries {
+		b.AppendMessage(3, func(b *Buffer) { appendMessageDescriptor(b, e, resolve) })
+	}
+	if m.mapEntry {
+		b.AppendMessage(7, func(b *Buffer) { b.AppendBool(7, true) })
+	}
+}
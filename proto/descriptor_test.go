@@ -0,0 +1,209 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package proto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testProto = `
+// Comment.
+syntax = "proto3";
+
+package test.v1;
+
+option go_package = "example.com/test";
+
+import "other.proto";
+
+/* A message. */
+message Item {
+  string name = 1;
+  repeated other.Kind kinds = 2 [deprecated = true];
+  map<string, Item> item_children = 3;
+  reserved 4;
+}
+
+service Items {
+  rpc List(Item) returns (stream Item);
+}
+`
+
+const otherProto = `
+syntax = "proto3";
+package other;
+enum Kind {
+  KIND_UNKNOWN = 0;
+  KIND_A = 1;
+}
+`
+
+// descriptorField is a field of a message in a FileDescriptorProto.
+type descriptorField struct {
+	name     string
+	number   int64
+	label    int64
+	typ      int64
+	typeName string
+	jsonName string
+}
+
+func TestDescriptors(t *testing.T) {
+	f, err := ParseFile("test.proto", testProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseFile("other.proto", otherProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"test.v1.Item", "test.v1.Items", "test.v1.Items.List"}; !reflect.DeepEqual(f.Symbols(), want) {
+		t.Errorf("Symbols() = %q, want %q", f.Symbols(), want)
+	}
+	if want := []string{"other.proto"}; !reflect.DeepEqual(f.Imports, want) {
+		t.Errorf("Imports = %q, want %q", f.Imports, want)
+	}
+
+	descriptors, err := Descriptors(f, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		pkg, syntax, goPackage string
+		fields                 []descriptorField
+		entry                  string
+		mapEntry               bool
+		output                 string
+		serverStreaming        bool
+	)
+	decodeField := func(d *Decoder) descriptorField {
+		var f descriptorField
+		d.Message(func(d *Decoder) {
+			for d.Next() {
+				switch d.Field() {
+				case 1:
+					f.name = d.String()
+				case 3:
+					f.number = d.Int()
+				case 4:
+					f.label = d.Int()
+				case 5:
+					f.typ = d.Int()
+				case 6:
+					f.typeName = d.String()
+				case 10:
+					f.jsonName = d.String()
+				}
+			}
+		})
+		return f
+	}
+	d := NewDecoder(descriptors["test.proto"])
+	for d.Next() {
+		switch d.Field() {
+		case 2:
+			pkg = d.String()
+		case 4:
+			d.Message(func(d *Decoder) {
+				for d.Next() {
+					switch d.Field() {
+					case 2:
+						fields = append(fields, decodeField(d))
+					case 3:
+						d.Message(func(d *Decoder) {
+							for d.Next() {
+								switch d.Field() {
+								case 1:
+									entry = d.String()
+								case 7:
+									d.Message(func(d *Decoder) {
+										for d.Next() {
+											mapEntry = d.Bool()
+										}
+									})
+								}
+							}
+						})
+					}
+				}
+			})
+		case 6:
+			d.Message(func(d *Decoder) {
+				for d.Next() {
+					if d.Field() != 2 {
+						continue
+					}
+					d.Message(func(d *Decoder) {
+						for d.Next() {
+							switch d.Field() {
+							case 3:
+								output = d.String()
+							case 6:
+								serverStreaming = d.Bool()
+							}
+						}
+					})
+				}
+			})
+		case 8:
+			d.Message(func(d *Decoder) {
+				for d.Next() {
+					goPackage = d.String()
+				}
+			})
+		case 12:
+			syntax = d.String()
+		}
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pkg != "test.v1" || syntax != "proto3" || goPackage != "example.com/test" {
+		t.Errorf("package %q, syntax %q, go_package %q", pkg, syntax, goPackage)
+	}
+	wantFields := []descriptorField{
+		{"name", 1, 1, 9, "", "name"},
+		{"kinds", 2, 3, 14, ".other.Kind", "kinds"},
+		{"item_children", 3, 3, 11, ".test.v1.Item.ItemChildrenEntry", "itemChildren"},
+	}
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("fields = %+v, want %+v", fields, wantFields)
+	}
+	if entry != "ItemChildrenEntry" || !mapEntry {
+		t.Errorf("map entry %q, map_entry %v", entry, mapEntry)
+	}
+	if output != ".test.v1.Item" || !serverStreaming {
+		t.Errorf("method output %q, server_streaming %v", output, serverStreaming)
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	for _, src := range []string{
+		`syntax = "proto2";`,
+		`message M { string name = ; }`,
+		`message M { string name = 1; `,
+		`enum E { A = 0 }`,
+		`/* unterminated`,
+		`frobnicate;`,
+	} {
+		if _, err := ParseFile("x.proto", src); err == nil || !strings.HasPrefix(err.Error(), "x.proto:") {
+			t.Errorf("ParseFile(%q) returned error %v", src, err)
+		}
+	}
+
+	f, err := ParseFile("x.proto", `syntax = "proto3"; package x; message M { Missing m = 1; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Descriptors(f); err == nil {
+		t.Errorf("Descriptors() with an undeclared type returned no error")
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// Package proto encodes and decodes messages in the protocol buffer wire
+// format.
+//
+// The package implements the subset of the format used by the messages of
+// the stored package documents and the gRPC API: varint, 64-bit and
+// length-delimited fields. Repeated scalar fields are not supported; the
+// messages of the API only repeat strings and messages. ParseFile and
+// Descriptors build the descriptors of .proto files served by the gRPC
+// server reflection service.
+package proto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Wire types.
+const (
+	varintType  = 0
+	fixed64Type = 1
+	bytesType   = 2
+	fixed32Type = 5
+)
+
+// Buffer is an encoded message. The Append methods append fields to the
+// message. Scalar fields with the zero value are omitted, as in proto3.
+type Buffer struct {
+	b []byte
+}
+
+// Bytes returns the encoded message.
+func (b *Buffer) Bytes() []byte {
+	return b.b
+}
+
+func (b *Buffer) appendTag(field, typ int) {
+	b.b = binary.AppendUvarint(b.b, uint64(field)<<3|uint64(typ))
+}
+
+// AppendString appends a string field.
+func (b *Buffer) AppendString(field int, s string) {
+	if s == "" {
+		return
+	}
+	b.appendTag(field, bytesType)
+	b.b = binary.AppendUvarint(b.b, uint64(len(s)))
+	b.b = append(b.b, s...)
+}
+
+// AppendStrings appends a repeated string field.
+func (b *Buffer) AppendStrings(field int, ss []string) {
+	for _, s := range ss {
+		b.appendTag(field, bytesType)
+		b.b = binary.AppendUvarint(b.b, uint64(len(s)))
+		b.b = append(b.b, s...)
+	}
+}
+
+// AppendBytes appends a bytes field or a message field encoded with another
+// Buffer.
+func (b *Buffer) AppendBytes(field int, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	b.appendTag(field, bytesType)
+	b.b = binary.AppendUvarint(b.b, uint64(len(p)))
+	b.b = append(b.b, p...)
+}
+
+// AppendBool appends a bool field.
+func (b *Buffer) AppendBool(field int, v bool) {
+	if v {
+		b.AppendUint(field, 1)
+	}
+}
+
+// AppendInt appends an int32, int64 or enum field.
+func (b *Buffer) AppendInt(field int, v int64) {
+	b.AppendUint(field, uint64(v))
+}
+
+// AppendUint appends a uint32 or uint64 field.
+func (b *Buffer) AppendUint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.appendTag(field, varintType)
+	b.b = binary.AppendUvarint(b.b, v)
+}
+
+// AppendDouble appends a double field.
+func (b *Buffer) AppendDouble(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	b.appendTag(field, fixed64Type)
+	b.b = binary.LittleEndian.AppendUint64(b.b, math.Float64bits(v))
+}
+
+// AppendMessage appends a message field with the fields appended by f. The
+// field is appended even if the message is empty, to encode the presence of
+// the message.
+func (b *Buffer) AppendMessage(field int, f func(b *Buffer)) {
+	var m Buffer
+	f(&m)
+	b.appendTag(field, bytesType)
+	b.b = binary.AppendUvarint(b.b, uint64(len(m.b)))
+	b.b = append(b.b, m.b...)
+}
+
+var errInvalid = errors.New("proto: invalid message")
+
+// Decoder reads the fields of an encoded message. Decoders are used as
+//
+//	d := proto.NewDecoder(p)
+//	for d.Next() {
+//		switch d.Field() {
+//		case 1:
+//			m.Name = d.String()
+//		}
+//	}
+//	if err := d.Err(); err != nil {
+//		...
+//	}
+//
+// Next skips the fields that are not read, such as fields added to the
+// message after the decoder was written.
+type Decoder struct {
+	b     []byte
+	field int
+	typ   int
+	read  bool
+	err   error
+}
+
+// NewDecoder returns a decoder of the message p.
+func NewDecoder(p []byte) *Decoder {
+	return &Decoder{b: p, read: true}
+}
+
+// Next advances the decoder to the next field of the message. Next returns
+// false at the end of the message or after an error.
+func (d *Decoder) Next() bool {
+	if d.err != nil {
+		return false
+	}
+	if !d.read {
+		d.skip()
+		if d.err != nil {
+			return false
+		}
+	}
+	if len(d.b) == 0 {
+		return false
+	}
+	tag := d.uvarint()
+	if d.err != nil {
+		return false
+	}
+	if tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+		d.err = fmt.Errorf("proto: invalid field number %d", tag>>3)
+		return false
+	}
+	d.field, d.typ, d.read = int(tag>>3), int(tag&7), false
+	return true
+}
+
+// Field returns the number of the current field.
+func (d *Decoder) Field() int {
+	return d.field
+}
+
+// Err returns the first error found by the decoder.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+func (d *Decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errInvalid
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// value returns the value of the current field if the field has wire type
+// typ.
+func (d *Decoder) value(typ int) []byte {
+	if d.err != nil || d.read {
+		return nil
+	}
+	d.read = true
+	if d.typ != typ {
+		d.err = fmt.Errorf("proto: field %d has wire type %d, want %d", d.field, d.typ, typ)
+		return nil
+	}
+	var n uint64
+	switch typ {
+	case varintType:
+		_, m := binary.Uvarint(d.b)
+		if m <= 0 {
+			d.err = errInvalid
+			return nil
+		}
+		n = uint64(m)
+	case fixed64Type:
+		n = 8
+	case fixed32Type:
+		n = 4
+	case bytesType:
+		n = d.uvarint()
+		if d.err != nil {
+			return nil
+		}
+	default:
+		d.err = fmt.Errorf("proto: field %d has unsupported wire type %d", d.field, typ)
+		return nil
+	}
+	if n > uint64(len(d.b)) {
+		d.err = errInvalid
+		return nil
+	}
+	p := d.b[:n]
+	d.b = d.b[n:]
+	return p
+}
+
+func (d *Decoder) skip() {
+	d.value(d.typ)
+}
+
+func (d *Decoder) varint() uint64 {
+	v, _ := binary.Uvarint(d.value(varintType))
+	return v
+}
+
+// String returns the value of the current string field.
+func (d *Decoder) String() string {
+	return string(d.value(bytesType))
+}
+
+// Bytes returns a copy of the value of the current bytes field.
+func (d *Decoder) Bytes() []byte {
+	p := d.value(bytesType)
+	if p == nil {
+		return nil
+	}
+	return append([]byte{}, p...)
+}
+
+// Bool returns the value of the current bool field.
+func (d *Decoder) Bool() bool {
+	return d.varint() != 0
+}
+
+// Int returns the value of the current int32, int64 or enum field.
+func (d *Decoder) Int() int64 {
+	return int64(d.varint())
+}
+
+// Uint returns the value of the current uint32 or uint64 field.
+func (d *Decoder) Uint() uint64 {
+	return d.varint()
+}
+
+// Double returns the value of the current double field.
+func (d *Decoder) Double() float64 {
+	p := d.value(fixed64Type)
+	if p == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(p))
+}
+
+// Message decodes the current message field with f. An error found by the
+// decoder passed to f is returned by Err.
+func (d *Decoder) Message(f func(d *Decoder)) {
+	p := d.value(bytesType)
+	if d.err != nil {
+		return
+	}
+	m := NewDecoder(p)
+	f(m)
+	d.err = m.err
+}
@@ -0,0 +1,107 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package proto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	var b Buffer
+	b.AppendString(1, "testing")
+	b.AppendInt(2, 150)
+	b.AppendString(3, "")
+	b.AppendBool(4, false)
+	if got, want := b.Bytes(), []byte{0x0a, 7, 't', 'e', 's', 't', 'i', 'n', 'g', 0x10, 0x96, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = % x, want % x", got, want)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	var b Buffer
+	b.AppendString(1, "name")
+	b.AppendStrings(2, []string{"a", ""})
+	b.AppendInt(3, -1)
+	b.AppendDouble(4, 0.5)
+	b.AppendMessage(5, func(b *Buffer) {
+		b.AppendBool(1, true)
+	})
+	b.AppendMessage(6, func(b *Buffer) {})
+	b.AppendUint(7, 1<<40)
+	b.AppendBytes(8, []byte("data"))
+
+	var (
+		name     string
+		strs     []string
+		i        int64
+		f        float64
+		nested   bool
+		messages int
+		data     []byte
+	)
+	d := NewDecoder(b.Bytes())
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			name = d.String()
+		case 2:
+			strs = append(strs, d.String())
+		case 3:
+			i = d.Int()
+		case 4:
+			f = d.Double()
+		case 5, 6:
+			messages++
+			d.Message(func(d *Decoder) {
+				for d.Next() {
+					nested = d.Bool()
+				}
+			})
+		case 8:
+			data = d.Bytes()
+		}
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if name != "name" || len(strs) != 2 || strs[0] != "a" || strs[1] != "" || i != -1 || f != 0.5 || !nested || messages != 2 || string(data) != "data" {
+		t.Errorf("decoded %q %q %d %v %v %d %q", name, strs, i, f, nested, messages, data)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	for _, p := range [][]byte{
+		{0x0a, 7, 't'},        // truncated string
+		{0x10},                // truncated varint
+		{0x00, 0x01},          // field number 0
+		{0x0b},                // group
+		{0x2a, 2, 0x08, 0x80}, // truncated varint in a message
+	} {
+		d := NewDecoder(p)
+		for d.Next() {
+			if d.Field() == 5 {
+				d.Message(func(d *Decoder) {
+					for d.Next() {
+						d.Bool()
+					}
+				})
+			}
+		}
+		if d.Err() == nil {
+			t.Errorf("decoding % x returned no error", p)
+		}
+	}
+
+	d := NewDecoder([]byte{0x08, 0x01})
+	for d.Next() {
+		_ = d.String()
+	}
+	if d.Err() == nil {
+		t.Errorf("reading a varint as a string returned no error")
+	}
+}
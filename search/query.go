@@ -0,0 +1,124 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+// Package search parses package search queries.
+//
+// A query is a list of words and filters separated by spaces. A filter has
+// the form name:value. The filters are:
+//
+//	host:github.com               packages hosted on github.com
+//	org:gorilla                   packages in a repository owned by gorilla
+//	is:command                    commands
+//	is:library                    packages that are not commands
+//	has:examples                  packages with examples
+//	imports:github.com/foo/bar    packages importing github.com/foo/bar
+//	license:mit                   packages with a license, by SPDX identifier
+//	quality:75                    packages with a quality score of at least 50, 75 or 90
+//
+// A word with the form of a filter that is not a valid filter is treated as
+// text.
+package search
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Filter is a filter in a query.
+type Filter struct {
+	Name  string
+	Value string
+}
+
+func (f Filter) String() string {
+	return f.Name + ":" + f.Value
+}
+
+// Query is a parsed search query.
+type Query struct {
+	// Words of the query that are not filters, separated by spaces.
+	Text string
+
+	Filters []Filter
+}
+
+// QualityThresholds are the values of the quality filter. A package matches
+// the filter with a threshold if the quality score of the package is at least
+// the threshold.
+var QualityThresholds = []int{50, 75, 90}
+
+// filterValues maps filter names to the allowed values of the filter or nil
+// if any value is allowed.
+var filterValues = map[string][]string{
+	"host":    nil,
+	"org":     nil,
+	"is":      {"command", "library"},
+	"has":     {"examples"},
+	"imports": nil,
+	"license": nil,
+	"quality": qualityValues(),
+}
+
+func qualityValues() []string {
+	values := make([]string, len(QualityThresholds))
+	for i, t := range QualityThresholds {
+		values[i] = strconv.Itoa(t)
+	}
+	return values
+}
+
+// parseFilter returns the filter in word and true or false if word is not a
+// valid filter.
+func parseFilter(word string) (Filter, bool) {
+	i := strings.Index(word, ":")
+	if i <= 0 || i == len(word)-1 {
+		return Filter{}, false
+	}
+	f := Filter{Name: strings.ToLower(word[:i]), Value: word[i+1:]}
+	values, ok := filterValues[f.Name]
+	if !ok {
+		return Filter{}, false
+	}
+	if f.Name != "imports" {
+		f.Value = strings.ToLower(f.Value)
+	}
+	if values == nil {
+		return f, true
+	}
+	for _, v := range values {
+		if f.Value == v {
+			return f, true
+		}
+	}
+	return Filter{}, false
+}
+
+// Parse parses the query q.
+func Parse(q string) *Query {
+	var query Query
+	var text []string
+	for _, word := range strings.Fields(q) {
+		if f, ok := parseFilter(word); ok {
+			query.Filters = append(query.Filters, f)
+		} else {
+			text = append(text, word)
+		}
+	}
+	query.Text = strings.Join(text, " ")
+	return &query
+}
+
+// String returns the query in the syntax accepted by Parse.
+func (q *Query) String() string {
+	words := make([]string, 0, len(q.Filters)+1)
+	if q.Text != "" {
+		words = append(words, q.Text)
+	}
+	for _, f := range q.Filters {
+		words = append(words, f.String())
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,42 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd.
+
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseTests = []struct {
+	q     string
+	query Query
+}{
+	{"http router", Query{Text: "http router"}},
+	{"  router   host:GitHub.com ", Query{Text: "router", Filters: []Filter{{"host", "github.com"}}}},
+	{"is:command org:golang", Query{Filters: []Filter{{"is", "command"}, {"org", "golang"}}}},
+	{"is:program has:examples", Query{Text: "is:program", Filters: []Filter{{"has", "examples"}}}},
+	{"imports:github.com/Foo/bar json", Query{Text: "json", Filters: []Filter{{"imports", "github.com/Foo/bar"}}}},
+	{"host: :x http://example.com", Query{Text: "host: :x http://example.com"}},
+	{"router license:Apache-2.0", Query{Text: "router", Filters: []Filter{{"license", "apache-2.0"}}}},
+	{"quality:75 quality:60", Query{Text: "quality:60", Filters: []Filter{{"quality", "75"}}}},
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range parseTests {
+		query := Parse(tt.q)
+		if !reflect.DeepEqual(*query, tt.query) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.q, *query, tt.query)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	q := &Query{Text: "router", Filters: []Filter{{"host", "github.com"}, {"is", "library"}}}
+	if s := q.String(); s != "router host:github.com is:library" {
+		t.Errorf("String() = %q", s)
+	}
+}